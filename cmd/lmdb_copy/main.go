@@ -3,7 +3,11 @@ Command lmdb_copy is a clone of mdb_copy that copies an LMDB environment.  A
 consistent copy is made even if the source database is in use.
 
 Command line flags mirror the flags for the original program.  For information
-about, run lmdb_copy with the -h flag.
+about, run lmdb_copy with the -h flag.  In addition -rate limits the copy to a
+number of MiB per second, so that copying a large environment in use does not
+saturate the disk, and -progress reports the progress of the copy on standard
+error.  With -json a summary of the copy, see
+Report, is written to standard output, which requires a destination path.
 
 	lmdb_copy -h
 */
@@ -11,8 +15,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
 	"github.com/PowerDNS/lmdb-go/lmdb"
@@ -21,6 +28,9 @@ import (
 func main() {
 	opt := &Options{}
 	flag.BoolVar(&opt.Compact, "c", false, "Compact while copying.")
+	flag.Float64Var(&opt.Rate, "rate", 0, "Limit the copy to `MiB` per second.")
+	flag.BoolVar(&opt.Progress, "progress", false, "Report progress on standard error.")
+	lmdbcmd.RegisterJSON()
 	flag.Parse()
 
 	lmdbcmd.PrintVersion()
@@ -38,30 +48,121 @@ func main() {
 		dstpath = flag.Arg(1)
 	}
 
-	copyEnv(srcpath, dstpath, opt)
+	if lmdbcmd.JSON() && dstpath == "" {
+		log.Fatalf("a destination path is required with -json")
+	}
+
+	rep, err := copyEnv(srcpath, dstpath, opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if lmdbcmd.JSON() {
+		err = lmdbcmd.WriteJSON(rep)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
 // Options contain the command line options for an lmdb_copy command.
 type Options struct {
-	Compact bool
+	Compact  bool
+	Rate     float64 // MiB per second
+	Progress bool
+}
+
+// Report summarizes a copy when the -json flag is given.
+type Report struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Compact     bool   `json:"compact"`
+	TxnID       int64  `json:"txn_id"`    // The last transaction ID of the source at the time of the copy
+	Size        int64  `json:"size"`      // Size of the source data file in bytes
+	CopySize    int64  `json:"copy_size"` // Size of the copied data file in bytes
 }
 
-func copyEnv(srcpath, dstpath string, opt *Options) error {
+func copyEnv(srcpath, dstpath string, opt *Options) (*Report, error) {
 	env, err := lmdb.NewEnv()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = env.Open(srcpath, lmdbcmd.OpenFlag(), 0644)
+	defer env.Close()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	rep := &Report{Source: srcpath, Destination: dstpath}
 	var flags uint
 	if opt != nil && opt.Compact {
 		flags |= lmdb.CopyCompact
+		rep.Compact = true
+	}
+	copt := copyOptions(opt, flags)
+	if dstpath == "" {
+		if copt != nil {
+			_, err = env.CopyTo(os.Stdout, copt)
+			return rep, err
+		}
+		fd := os.Stdout.Fd()
+		return rep, env.CopyFDFlag(fd, flags)
+	}
+
+	info, err := env.Info()
+	if err != nil {
+		return nil, err
+	}
+	rep.TxnID = info.LastTxnID
+	if copt != nil {
+		err = env.CopyWith(dstpath, copt)
+	} else {
+		err = env.CopyFlag(dstpath, flags)
+	}
+	if err != nil {
+		return nil, err
 	}
-	if dstpath != "" {
-		return env.CopyFlag(dstpath, flags)
+	rep.Size, err = dataSize(srcpath)
+	if err != nil {
+		return nil, err
+	}
+	rep.CopySize, err = dataSize(dstpath)
+	if err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// copyOptions returns the options of lmdb.Env.CopyTo for opt, or nil if the
+// copy is neither limited nor reported.
+func copyOptions(opt *Options, flags uint) *lmdb.CopyOptions {
+	if opt == nil || opt.Rate <= 0 && !opt.Progress {
+		return nil
+	}
+	copt := &lmdb.CopyOptions{
+		Flags: flags,
+		Rate:  int64(opt.Rate * (1 << 20)),
+	}
+	if opt.Progress {
+		var last time.Time
+		copt.Progress = func(p lmdb.CopyProgress) {
+			if time.Since(last) < time.Second {
+				return
+			}
+			last = time.Now()
+			fmt.Fprintf(os.Stderr, "copied %.1f MiB (%d pages) in %v\n",
+				float64(p.Bytes)/(1<<20), p.Pages, p.Elapsed.Round(time.Second))
+		}
+	}
+	return copt
+}
+
+// dataSize returns the size of the data file of the environment at path.
+func dataSize(path string) (int64, error) {
+	if lmdbcmd.OpenFlag()&lmdb.NoSubdir == 0 {
+		path = filepath.Join(path, "data.mdb")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
 	}
-	fd := os.Stdout.Fd()
-	return env.CopyFDFlag(fd, flags)
+	return fi.Size(), nil
 }
@@ -0,0 +1,129 @@
+/*
+Command lmdb_freelist summarizes the free list of an LMDB environment: how
+many pages are free, how many consecutive runs they form and how fragmented
+they are.  It helps to explain why an environment keeps growing despite
+deletes.
+
+	lmdb_freelist [-n] [-json] path
+
+With -json the summary is written as a JSON document, see Report.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func main() {
+	lmdbcmd.RegisterJSON()
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() > 1 {
+		log.Fatalf("too many argument provided")
+	}
+	if flag.NArg() == 0 {
+		log.Fatalf("missing argument")
+	}
+
+	err := doMain(flag.Arg(0))
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
+
+func doMain(path string) error {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return err
+	}
+	err = env.Open(path, lmdbcmd.OpenFlag()|lmdb.Readonly, 0644)
+	defer env.Close()
+	if err != nil {
+		return err
+	}
+
+	stat, err := env.FreelistStat()
+	if err != nil {
+		return err
+	}
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	readers, err := env.StaleReaders()
+	if err != nil {
+		return err
+	}
+
+	if lmdbcmd.JSON() {
+		return lmdbcmd.WriteJSON(newReport(stat, info, readers))
+	}
+
+	fmt.Println("Freelist Status")
+	fmt.Println("  Entries:", stat.Entries)
+	fmt.Println("  Pages in use:", stat.Pages)
+	fmt.Printf("  Free pages: %d (%.1f%%)\n", stat.FreePages, percent(stat.FreePages, stat.Pages))
+	fmt.Println("  Runs:", stat.Runs)
+	fmt.Println("  Largest run:", stat.LargestRun)
+	fmt.Printf("  Fragmentation: %.3f\n", stat.Fragmentation)
+	if stat.Unsorted > 0 {
+		fmt.Println("  Unsorted entries:", stat.Unsorted, "[bad sequence]")
+	}
+	for _, r := range readers {
+		fmt.Printf("  Reader pid %d holds transaction %d (%d behind)\n", r.PID, r.TxnID, info.LastTxnID-r.TxnID)
+	}
+	return nil
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// Report is the output of lmdb_freelist when the -json flag is given.
+type Report struct {
+	Entries       int           `json:"entries"`
+	Pages         int64         `json:"pages"`
+	FreePages     int64         `json:"free_pages"`
+	Runs          int64         `json:"runs"`
+	LargestRun    int64         `json:"largest_run"`
+	Fragmentation float64       `json:"fragmentation"`
+	Unsorted      int           `json:"unsorted"`
+	StaleReaders  []StaleReader `json:"stale_readers"`
+}
+
+// StaleReader is a reader holding a snapshot older than the last
+// transaction, which keeps the pages it references off the free list.
+type StaleReader struct {
+	PID    int   `json:"pid"`
+	TxnID  int64 `json:"txn_id"`
+	Behind int64 `json:"behind"`
+}
+
+func newReport(stat *lmdb.FreelistStat, info *lmdb.EnvInfo, readers []lmdb.ReaderInfo) *Report {
+	rep := &Report{
+		Entries:       stat.Entries,
+		Pages:         stat.Pages,
+		FreePages:     stat.FreePages,
+		Runs:          stat.Runs,
+		LargestRun:    stat.LargestRun,
+		Fragmentation: stat.Fragmentation,
+		Unsorted:      stat.Unsorted,
+		StaleReaders:  []StaleReader{},
+	}
+	for _, r := range readers {
+		rep.StaleReaders = append(rep.StaleReaders, StaleReader{r.PID, r.TxnID, info.LastTxnID - r.TxnID})
+	}
+	return rep
+}
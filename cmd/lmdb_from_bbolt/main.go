@@ -0,0 +1,134 @@
+/*
+Command lmdb_from_bbolt imports a bolt or bbolt database into an LMDB
+environment, creating a named database for each top-level bucket.  See package
+exp/lmdbbolt for how nested buckets are stored.
+
+	lmdb_from_bbolt [-b bucket,...] [-mapsize bytes] [-v] bolt.db lmdb-env
+
+The environment is created if it does not exist.  Unless -mapsize is given its
+map size is set to twice the size of the bolt file, so that the imported data
+fits.  With -json the imported buckets are written to standard output.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbbolt"
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// minMapSize is the smallest map size set when -mapsize is not given.
+const minMapSize = 64 << 20
+
+func main() {
+	opt := &Options{}
+	var buckets string
+	flag.StringVar(&buckets, "b", "", "Comma separated top-level `buckets` to import instead of all.")
+	flag.Int64Var(&opt.MapSize, "mapsize", 0, "Map size of the environment in `bytes`.")
+	flag.IntVar(&opt.ChunkSize, "chunk", 0, "Items written per transaction.")
+	flag.BoolVar(&opt.Verbose, "v", false, "Report progress on standard error.")
+	lmdbcmd.RegisterJSON()
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() != 2 {
+		log.Fatalf("a bolt file and an environment path must be specified")
+	}
+	if buckets != "" {
+		opt.Buckets = strings.Split(buckets, ",")
+	}
+
+	rep, err := importBolt(flag.Arg(0), flag.Arg(1), opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if lmdbcmd.JSON() {
+		err = lmdbcmd.WriteJSON(rep)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// Options contain the command line options for an lmdb_from_bbolt command.
+type Options struct {
+	Buckets   []string
+	MapSize   int64
+	ChunkSize int
+	Verbose   bool
+}
+
+// Bucket reports an imported bucket when the -json flag is given.
+type Bucket struct {
+	Name  string `json:"name"`
+	Items uint64 `json:"items"`
+}
+
+func importBolt(boltpath, envpath string, opt *Options) ([]Bucket, error) {
+	names, err := lmdbbolt.Buckets(boltpath)
+	if err != nil {
+		return nil, err
+	}
+	mapSize := opt.MapSize
+	if mapSize <= 0 {
+		fi, err := os.Stat(boltpath)
+		if err != nil {
+			return nil, err
+		}
+		mapSize = 2 * fi.Size()
+		if mapSize < minMapSize {
+			mapSize = minMapSize
+		}
+	}
+
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+	err = env.SetMaxDBs(len(names))
+	if err != nil {
+		return nil, err
+	}
+	err = env.SetMapSize(mapSize)
+	if err != nil {
+		return nil, err
+	}
+	flags := lmdbcmd.OpenFlag()
+	if flags&lmdb.NoSubdir == 0 {
+		err = os.MkdirAll(envpath, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = env.Open(envpath, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	bopt := &lmdbbolt.Options{
+		Buckets:   opt.Buckets,
+		ChunkSize: opt.ChunkSize,
+		NoSync:    true,
+	}
+	if opt.Verbose {
+		bopt.Progress = func(bucket string, items uint64) {
+			log.Printf("%s: %d items", bucket, items)
+		}
+	}
+	imported, err := lmdbbolt.Import(env, boltpath, bopt)
+	if err != nil {
+		return nil, err
+	}
+	rep := make([]Bucket, len(imported))
+	for i, b := range imported {
+		rep[i] = Bucket{Name: b.Name, Items: b.Items}
+	}
+	return rep, nil
+}
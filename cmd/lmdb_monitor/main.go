@@ -0,0 +1,290 @@
+/*
+Command lmdb_monitor displays live statistics of an LMDB environment in the
+terminal, in the manner of top.  Every interval it redraws the environment
+info, the map utilization, the commit rate, the reader table and the
+statistics of each database.
+
+	lmdb_monitor [-n] [-json] [-i interval] [-c count] [-top n] path
+
+The monitor opens the environment read-only and holds a reader slot only
+while it samples, so it can be left running against a busy service.  With -c
+it exits after count refreshes, and with -top it limits the database table to
+the n databases with the most pages.  With -json every refresh is written as a
+line of JSON, see Report, instead of redrawing the screen.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+func main() {
+	opt := &Options{}
+	flag.DurationVar(&opt.Interval, "i", time.Second, "Refresh interval.")
+	flag.IntVar(&opt.Count, "c", 0, "Exit after count refreshes (0 runs until interrupted).")
+	flag.IntVar(&opt.Top, "top", 20, "Number of databases displayed (0 displays all).")
+	lmdbcmd.RegisterJSON()
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() > 1 {
+		log.Fatalf("too many argument provided")
+	}
+	if flag.NArg() == 0 {
+		log.Fatalf("missing argument")
+	}
+	opt.Path = flag.Arg(0)
+
+	err := doMain(opt)
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
+
+// Options contains the configuration of the monitor.
+type Options struct {
+	Path     string
+	Interval time.Duration
+	Count    int
+	Top      int
+}
+
+// Sample is a snapshot of the state of the environment.
+type Sample struct {
+	Time     time.Time
+	Info     *lmdb.EnvInfo
+	PSize    uint
+	Stats    map[string]*lmdb.Stat
+	Readers  []lmdb.ReaderInfo
+	Stale    int
+	Commits  int64   // transactions committed since the previous sample
+	Rate     float64 // commits per second since the previous sample
+	Utilized float64 // fraction of the map in use
+}
+
+func doMain(opt *Options) error {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return err
+	}
+	err = env.SetMaxDBs(4096)
+	if err != nil {
+		return err
+	}
+	err = env.Open(opt.Path, lmdbcmd.OpenFlag()|lmdb.Readonly, 0644)
+	defer env.Close()
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	var prev *Sample
+	for i := 0; opt.Count == 0 || i < opt.Count; i++ {
+		if i > 0 {
+			time.Sleep(opt.Interval)
+		}
+		s, err := sample(env, prev)
+		if err != nil {
+			return err
+		}
+		if lmdbcmd.JSON() {
+			err = json.NewEncoder(w).Encode(newReport(s))
+		} else {
+			io.WriteString(w, clearScreen)
+			display(w, opt, s)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		prev = s
+	}
+	return nil
+}
+
+// sample collects the state of env and computes rates relative to prev, which
+// may be nil.
+func sample(env *lmdb.Env, prev *Sample) (*Sample, error) {
+	// A writer in another process may have grown the map.  Adopting its size
+	// keeps the utilization accurate.
+	err := env.SetMapSize(0)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sample{Time: time.Now()}
+	s.Info, err = env.Info()
+	if err != nil {
+		return nil, err
+	}
+	s.Stats, err = env.StatAll()
+	if err != nil {
+		return nil, err
+	}
+	s.PSize = s.Stats[""].PSize
+	s.Readers, err = env.Readers()
+	if err != nil {
+		return nil, err
+	}
+	stale, err := env.StaleReaders()
+	if err != nil {
+		return nil, err
+	}
+	s.Stale = len(stale)
+
+	s.Utilized = float64(s.Info.LastPNO+1) * float64(s.PSize) / float64(s.Info.MapSize)
+	if prev != nil {
+		s.Commits = s.Info.LastTxnID - prev.Info.LastTxnID
+		s.Rate = float64(s.Commits) / s.Time.Sub(prev.Time).Seconds()
+	}
+	return s, nil
+}
+
+// Report is a sample written as JSON when the -json flag is given.
+type Report struct {
+	Time       time.Time         `json:"time"`
+	MapSize    int64             `json:"map_size"`
+	PageSize   uint              `json:"page_size"`
+	PagesUsed  int64             `json:"pages_used"`
+	Utilized   float64           `json:"utilized"`
+	LastTxnID  int64             `json:"last_txn_id"`
+	Commits    int64             `json:"commits"`
+	Rate       float64           `json:"rate"`
+	MaxReaders uint              `json:"max_readers"`
+	NumReaders uint              `json:"num_readers"`
+	Stale      int               `json:"stale_readers"`
+	Readers    []Reader          `json:"readers"`
+	DBs        map[string]DBStat `json:"dbs"`
+}
+
+// Reader is a slot of the reader table.  TxnID is -1 if the slot holds no
+// snapshot.
+type Reader struct {
+	PID    int    `json:"pid"`
+	Thread uint64 `json:"thread"`
+	TxnID  int64  `json:"txn_id"`
+}
+
+// DBStat describes a database.
+type DBStat struct {
+	Entries       uint64 `json:"entries"`
+	Depth         uint   `json:"depth"`
+	BranchPages   uint64 `json:"branch_pages"`
+	LeafPages     uint64 `json:"leaf_pages"`
+	OverflowPages uint64 `json:"overflow_pages"`
+}
+
+func newReport(s *Sample) *Report {
+	rep := &Report{
+		Time:       s.Time,
+		MapSize:    s.Info.MapSize,
+		PageSize:   s.PSize,
+		PagesUsed:  s.Info.LastPNO + 1,
+		Utilized:   s.Utilized,
+		LastTxnID:  s.Info.LastTxnID,
+		Commits:    s.Commits,
+		Rate:       s.Rate,
+		MaxReaders: s.Info.MaxReaders,
+		NumReaders: s.Info.NumReaders,
+		Stale:      s.Stale,
+		Readers:    []Reader{},
+		DBs:        make(map[string]DBStat, len(s.Stats)),
+	}
+	for _, r := range s.Readers {
+		rep.Readers = append(rep.Readers, Reader(r))
+	}
+	for name, stat := range s.Stats {
+		rep.DBs[name] = DBStat{stat.Entries, stat.Depth, stat.BranchPages, stat.LeafPages, stat.OverflowPages}
+	}
+	return rep
+}
+
+func display(w io.Writer, opt *Options, s *Sample) {
+	info := s.Info
+	fmt.Fprintf(w, "lmdb_monitor %s  %s  every %v\n\n", opt.Path, s.Time.Format("15:04:05"), opt.Interval)
+
+	fmt.Fprintf(w, "Map:      %d of %d pages (%.1f%% of %s)\n",
+		info.LastPNO+1, info.MapSize/int64(s.PSize), 100*s.Utilized, size(info.MapSize))
+	fmt.Fprintf(w, "Commits:  txn %d, %d since last refresh (%.1f/s)\n", info.LastTxnID, s.Commits, s.Rate)
+	fmt.Fprintf(w, "Readers:  %d of %d slots, %d stale\n\n", info.NumReaders, info.MaxReaders, s.Stale)
+
+	if len(s.Readers) > 0 {
+		fmt.Fprintf(w, "%10s %16s %10s %8s\n", "PID", "THREAD", "TXNID", "LAG")
+		for _, r := range s.Readers {
+			if r.TxnID < 0 {
+				fmt.Fprintf(w, "%10d %16x %10s %8s\n", r.PID, r.Thread, "-", "-")
+				continue
+			}
+			fmt.Fprintf(w, "%10d %16x %10d %8d\n", r.PID, r.Thread, r.TxnID, info.LastTxnID-r.TxnID)
+		}
+		fmt.Fprintln(w)
+	}
+
+	names := make([]string, 0, len(s.Stats))
+	for name := range s.Stats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := pages(s.Stats[names[i]]), pages(s.Stats[names[j]])
+		if pi != pj {
+			return pi > pj
+		}
+		return names[i] < names[j]
+	})
+	hidden := 0
+	if opt.Top > 0 && len(names) > opt.Top {
+		hidden = len(names) - opt.Top
+		names = names[:opt.Top]
+	}
+	fmt.Fprintf(w, "%-24s %12s %6s %10s %10s %10s %10s\n", "DATABASE", "ENTRIES", "DEPTH", "BRANCH", "LEAF", "OVERFLOW", "SIZE")
+	for _, name := range names {
+		stat := s.Stats[name]
+		label := name
+		if label == "" {
+			label = "(main)"
+		}
+		fmt.Fprintf(w, "%-24s %12d %6d %10d %10d %10d %10s\n",
+			label, stat.Entries, stat.Depth, stat.BranchPages, stat.LeafPages, stat.OverflowPages,
+			size(int64(pages(stat))*int64(s.PSize)))
+	}
+	if hidden > 0 {
+		fmt.Fprintf(w, "... %d more databases\n", hidden)
+	}
+}
+
+// pages returns the number of pages used by a database.
+func pages(stat *lmdb.Stat) uint64 {
+	return stat.BranchPages + stat.LeafPages + stat.OverflowPages
+}
+
+// size formats n bytes in binary units.
+func size(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
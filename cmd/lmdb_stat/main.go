@@ -3,7 +3,8 @@ Command lmdb_stat is a clone of mdb_stat that displays the status an LMDB
 environment.
 
 Command line flags mirror the flags for the original program.  For information
-about, run lmdb_stat with the -h flag.
+about, run lmdb_stat with the -h flag.  With -json the requested sections are
+written as a single JSON document, see Report.
 
 	lmdb_stat -h
 */
@@ -44,6 +45,7 @@ func main() {
 	flag.BoolVar(&opt.PrintStatAll, "a", false, "Display the status of all databases in the environment")
 	flag.StringVar(&opt.PrintStatSub, "s", "", "Display the status of a specific subdatabase.")
 	flag.BoolVar(&opt.Debug, "D", false, "print debug information")
+	lmdbcmd.RegisterJSON()
 	flag.Parse()
 
 	lmdbcmd.PrintVersion()
@@ -98,6 +100,85 @@ type Options struct {
 	Debug bool
 }
 
+// Report is the output of lmdb_stat when the -json flag is given.  Sections
+// which were not requested are omitted.
+type Report struct {
+	Info     *Info            `json:"info,omitempty"`
+	Readers  *Readers         `json:"readers,omitempty"`
+	Freelist *Freelist        `json:"freelist,omitempty"`
+	Main     *Stat            `json:"main"`
+	DBs      map[string]*Stat `json:"dbs,omitempty"`
+}
+
+// Stat describes a database.
+type Stat struct {
+	Depth         uint   `json:"depth"`
+	BranchPages   uint64 `json:"branch_pages"`
+	LeafPages     uint64 `json:"leaf_pages"`
+	OverflowPages uint64 `json:"overflow_pages"`
+	Entries       uint64 `json:"entries"`
+}
+
+func newStat(stat *lmdb.Stat) *Stat {
+	return &Stat{
+		Depth:         stat.Depth,
+		BranchPages:   stat.BranchPages,
+		LeafPages:     stat.LeafPages,
+		OverflowPages: stat.OverflowPages,
+		Entries:       stat.Entries,
+	}
+}
+
+// Info describes the environment.
+type Info struct {
+	MapSize    int64 `json:"map_size"`
+	PageSize   int   `json:"page_size"`
+	MaxPages   int64 `json:"max_pages"`
+	PagesUsed  int64 `json:"pages_used"`
+	LastTxnID  int64 `json:"last_txn_id"`
+	MaxReaders uint  `json:"max_readers"`
+	NumReaders uint  `json:"num_readers"`
+}
+
+// Readers lists the reader table.  Cleared is the number of stale readers
+// cleared when -rr is given, in which case Slots is the table after the
+// check.
+type Readers struct {
+	Slots   []Reader `json:"slots"`
+	Cleared int      `json:"cleared"`
+}
+
+// Reader is a slot of the reader table.  TxnID is -1 if the slot holds no
+// snapshot.
+type Reader struct {
+	PID    int    `json:"pid"`
+	Thread uint64 `json:"thread"`
+	TxnID  int64  `json:"txn_id"`
+}
+
+// Freelist describes the free list.  Txns is only reported with -ff or -fff,
+// and the page spans of each transaction only with -fff.
+type Freelist struct {
+	Stat      *Stat     `json:"stat"`
+	FreePages int64     `json:"free_pages"`
+	Txns      []FreeTxn `json:"txns,omitempty"`
+}
+
+// FreeTxn describes the pages freed by a transaction.
+type FreeTxn struct {
+	TxnID   uint64     `json:"txn_id"`
+	Pages   int64      `json:"pages"`
+	MaxSpan uint64     `json:"max_span"`
+	Bad     bool       `json:"bad_sequence,omitempty"`
+	Spans   []FreeSpan `json:"spans,omitempty"`
+}
+
+// FreeSpan is a run of consecutive free pages.
+type FreeSpan struct {
+	Page uint64 `json:"page"`
+	Len  uint64 `json:"len"`
+}
+
 func doMain(opt *Options) error {
 	env, err := lmdb.NewEnv()
 	if err != nil {
@@ -115,48 +196,53 @@ func doMain(opt *Options) error {
 		return err
 	}
 
+	rep := &Report{}
+
 	if opt.PrintInfo {
-		err = doPrintInfo(env, opt)
+		err = doPrintInfo(env, opt, rep)
 		if err != nil {
 			return err
 		}
 	}
 
 	if opt.PrintReaders || opt.PrintReadersCheck {
-		err = doPrintReaders(env, opt)
+		err = doPrintReaders(env, opt, rep)
 		if err != nil {
 			return err
 		}
 	}
 
 	if opt.PrintFree || opt.PrintFreeSummary || opt.PrintFreeFull {
-		err = doPrintFree(env, opt)
+		err = doPrintFree(env, opt, rep)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = doPrintStatRoot(env, opt)
+	err = doPrintStatRoot(env, opt, rep)
 	if err != nil {
 		return err
 	}
 
 	if opt.PrintStatAll {
-		err = doPrintStatAll(env, opt)
+		err = doPrintStatAll(env, opt, rep)
 		if err != nil {
 			return err
 		}
 	} else if opt.PrintStatSub != "" {
-		err = doPrintStatDB(env, opt.PrintStatSub, opt)
+		err = doPrintStatDB(env, opt.PrintStatSub, opt, rep)
 		if err != nil {
 			return err
 		}
 	}
 
+	if lmdbcmd.JSON() {
+		return lmdbcmd.WriteJSON(rep)
+	}
 	return nil
 }
 
-func doPrintInfo(env *lmdb.Env, opt *Options) error {
+func doPrintInfo(env *lmdb.Env, opt *Options, rep *Report) error {
 	info, err := env.Info()
 	if err != nil {
 		return err
@@ -164,6 +250,19 @@ func doPrintInfo(env *lmdb.Env, opt *Options) error {
 
 	pagesize := os.Getpagesize()
 
+	if lmdbcmd.JSON() {
+		rep.Info = &Info{
+			MapSize:    info.MapSize,
+			PageSize:   pagesize,
+			MaxPages:   info.MapSize / int64(pagesize),
+			PagesUsed:  info.LastPNO + 1,
+			LastTxnID:  info.LastTxnID,
+			MaxReaders: info.MaxReaders,
+			NumReaders: info.NumReaders,
+		}
+		return nil
+	}
+
 	fmt.Println("Environment Info")
 	fmt.Println("  Map address:", nil)
 	fmt.Println("  Map size:", info.MapSize)
@@ -177,7 +276,26 @@ func doPrintInfo(env *lmdb.Env, opt *Options) error {
 	return nil
 }
 
-func doPrintReaders(env *lmdb.Env, opt *Options) error {
+func doPrintReaders(env *lmdb.Env, opt *Options, rep *Report) error {
+	if lmdbcmd.JSON() {
+		rep.Readers = &Readers{Slots: []Reader{}}
+		if opt.PrintReadersCheck {
+			numstale, err := env.ReaderCheck()
+			if err != nil {
+				return err
+			}
+			rep.Readers.Cleared = numstale
+		}
+		readers, err := env.Readers()
+		if err != nil {
+			return err
+		}
+		for _, r := range readers {
+			rep.Readers.Slots = append(rep.Readers.Slots, Reader(r))
+		}
+		return nil
+	}
+
 	fmt.Println("Reader Table Status")
 	w := bufio.NewWriter(os.Stdout)
 	err := printReaders(env, w, opt)
@@ -213,17 +331,20 @@ func printReaders(env *lmdb.Env, w io.Writer, opt *Options) error {
 	})
 }
 
-func doPrintFree(env *lmdb.Env, opt *Options) error {
+func doPrintFree(env *lmdb.Env, opt *Options, rep *Report) error {
+	text := !lmdbcmd.JSON()
 	return env.View(func(txn *lmdb.Txn) (err error) {
 		txn.RawRead = true
 
-		fmt.Println("Freelist Status")
-
 		stat, err := txn.Stat(0)
 		if err != nil {
 			return err
 		}
-		printStat(stat, opt)
+		free := &Freelist{Stat: newStat(stat)}
+		if text {
+			fmt.Println("Freelist Status")
+			printStat(stat, opt)
+		}
 
 		var numpages int64
 		s := lmdbscan.New(txn, 0)
@@ -253,7 +374,10 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 						pg++
 					}
 				}
-				fmt.Printf("    Transaction %d, %d pages, maxspan %d%s\n", txid, ipages, span, bad)
+				ftxn := FreeTxn{TxnID: uint64(txid), Pages: ipages, MaxSpan: uint64(span), Bad: bad != ""}
+				if text {
+					fmt.Printf("    Transaction %d, %d pages, maxspan %d%s\n", txid, ipages, span, bad)
+				}
 
 				if opt.PrintFreeFull {
 					for j := ipages - 1; j >= 0; {
@@ -264,6 +388,10 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 							j--
 							span++
 						}
+						ftxn.Spans = append(ftxn.Spans, FreeSpan{Page: uint64(pg), Len: uint64(span)})
+						if !text {
+							continue
+						}
 						if span > 1 {
 							fmt.Printf("     %9d[%d]\n", pg, span)
 						} else {
@@ -271,6 +399,7 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 						}
 					}
 				}
+				free.Txns = append(free.Txns, ftxn)
 			}
 		}
 		err = s.Err()
@@ -278,18 +407,27 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 			return err
 		}
 
-		fmt.Println("  Free pages:", numpages)
+		free.FreePages = numpages
+		rep.Freelist = free
+		if text {
+			fmt.Println("  Free pages:", numpages)
+		}
 
 		return nil
 	})
 }
 
-func doPrintStatRoot(env *lmdb.Env, opt *Options) error {
+func doPrintStatRoot(env *lmdb.Env, opt *Options, rep *Report) error {
 	stat, err := env.Stat()
 	if err != nil {
 		return err
 	}
 
+	if lmdbcmd.JSON() {
+		rep.Main = newStat(stat)
+		return nil
+	}
+
 	fmt.Println("Status of Main DB")
 	fmt.Println("  Tree depth:", stat.Depth)
 	fmt.Println("  Branch pages:", stat.BranchPages)
@@ -300,9 +438,9 @@ func doPrintStatRoot(env *lmdb.Env, opt *Options) error {
 	return nil
 }
 
-func doPrintStatDB(env *lmdb.Env, db string, opt *Options) error {
+func doPrintStatDB(env *lmdb.Env, db string, opt *Options, rep *Report) error {
 	err := env.View(func(txn *lmdb.Txn) (err error) {
-		return printStatDB(env, txn, db, opt)
+		return printStatDB(env, txn, db, opt, rep)
 	})
 	if err != nil {
 		return fmt.Errorf("%v (%s)", err, db)
@@ -310,7 +448,7 @@ func doPrintStatDB(env *lmdb.Env, db string, opt *Options) error {
 	return nil
 }
 
-func printStatDB(env *lmdb.Env, txn *lmdb.Txn, db string, opt *Options) error {
+func printStatDB(env *lmdb.Env, txn *lmdb.Txn, db string, opt *Options, rep *Report) error {
 	dbi, err := txn.OpenDBI(db, 0)
 	if err != nil {
 		return err
@@ -322,6 +460,14 @@ func printStatDB(env *lmdb.Env, txn *lmdb.Txn, db string, opt *Options) error {
 		return err
 	}
 
+	if lmdbcmd.JSON() {
+		if rep.DBs == nil {
+			rep.DBs = make(map[string]*Stat)
+		}
+		rep.DBs[db] = newStat(stat)
+		return nil
+	}
+
 	fmt.Println("Status of", db)
 	printStat(stat, opt)
 
@@ -338,7 +484,7 @@ func printStat(stat *lmdb.Stat, opt *Options) error {
 	return nil
 }
 
-func doPrintStatAll(env *lmdb.Env, opt *Options) error {
+func doPrintStatAll(env *lmdb.Env, opt *Options, rep *Report) error {
 	return env.View(func(txn *lmdb.Txn) (err error) {
 		dbi, err := txn.OpenRoot(0)
 		if err != nil {
@@ -349,7 +495,7 @@ func doPrintStatAll(env *lmdb.Env, opt *Options) error {
 		s := lmdbscan.New(txn, dbi)
 		defer s.Close()
 		for s.Scan() {
-			err = printStatDB(env, txn, string(s.Key()), opt)
+			err = printStatDB(env, txn, string(s.Key()), opt, rep)
 			if e, ok := err.(*lmdb.OpError); ok {
 				if e.Op == "mdb_dbi_open" {
 					continue
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/PowerDNS/lmdb-go/lmdbscan"
+)
+
+// loadBatch is the number of items written per transaction by load.
+const loadBatch = 10000
+
+// jsonItem is an item in the JSON lines dump format.
+type jsonItem struct {
+	Key []byte `json:"key"`
+	Val []byte `json:"val"`
+}
+
+func cmdDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		txn.RawRead = true
+		dbi, err := openDBI(txn, name, 0)
+		if err != nil {
+			return err
+		}
+		if !lmdbcmd.JSON() {
+			err = writeDumpHeader(w, env, txn, dbi, name)
+			if err != nil {
+				return err
+			}
+		}
+		enc := json.NewEncoder(w)
+		s := lmdbscan.New(txn, dbi)
+		defer s.Close()
+		for s.Scan() {
+			if lmdbcmd.JSON() {
+				err = enc.Encode(jsonItem{s.Key(), s.Val()})
+			} else {
+				err = writePrintable(w, s.Key())
+				if err == nil {
+					err = writePrintable(w, s.Val())
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if s.Err() != nil {
+			return s.Err()
+		}
+		if !lmdbcmd.JSON() {
+			_, err = io.WriteString(w, "DATA=END\n")
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writeDumpHeader(w io.Writer, env *lmdb.Env, txn *lmdb.Txn, dbi lmdb.DBI, name string) error {
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		return err
+	}
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "VERSION=3")
+	fmt.Fprintln(w, "format=print")
+	fmt.Fprintln(w, "type=btree")
+	fmt.Fprintln(w, "mapsize="+fmt.Sprint(info.MapSize))
+	fmt.Fprintln(w, "maxreaders="+fmt.Sprint(info.MaxReaders))
+	fmt.Fprintln(w, "db_pagesize="+fmt.Sprint(stat.PSize))
+	if name != "" {
+		fmt.Fprintln(w, "database="+name)
+	}
+	for _, f := range dumpFlags {
+		if flags&f.flag != 0 {
+			fmt.Fprintln(w, f.name+"=1")
+		}
+	}
+	_, err = fmt.Fprintln(w, "HEADER=END")
+	return err
+}
+
+// dumpFlags are the database flags recorded in a dump header.
+var dumpFlags = []struct {
+	flag uint
+	name string
+}{
+	{lmdb.ReverseKey, "reversekey"},
+	{lmdb.DupSort, "dupsort"},
+	{lmdb.IntegerKey, "integerkey"},
+	{lmdb.DupFixed, "dupfixed"},
+	{lmdb.IntegerDup, "integerdup"},
+	{lmdb.ReverseDup, "reversedup"},
+}
+
+// writePrintable writes b as a line of the mdb_dump print format.
+func writePrintable(w *bufio.Writer, b []byte) error {
+	w.WriteByte(' ')
+	for _, c := range b {
+		switch {
+		case c == '\\':
+			w.WriteString(`\\`)
+		case c >= 0x20 && c < 0x7f:
+			w.WriteByte(c)
+		default:
+			w.WriteByte('\\')
+			w.WriteString(hex.EncodeToString([]byte{c}))
+		}
+	}
+	return w.WriteByte('\n')
+}
+
+// readPrintable decodes a line of the mdb_dump print or bytevalue format.
+func readPrintable(line string, print bool) ([]byte, error) {
+	if !strings.HasPrefix(line, " ") {
+		return nil, fmt.Errorf("malformed dump line %q", line)
+	}
+	line = line[1:]
+	if !print {
+		return hex.DecodeString(line)
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == '\\' {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+		if i+2 >= len(line) {
+			return nil, fmt.Errorf("malformed escape in %q", line)
+		}
+		p, err := hex.DecodeString(line[i+1 : i+3])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(p)
+		i += 2
+	}
+	return b.Bytes(), nil
+}
+
+func cmdLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	first, err := r.Peek(1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var items []jsonItem
+	var flags uint
+	if len(first) > 0 && first[0] == '{' {
+		items, err = readJSONItems(r)
+	} else {
+		items, flags, err = readDump(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	env, err := openEnv(args[0], 0)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	for len(items) > 0 {
+		n := len(items)
+		if n > loadBatch {
+			n = loadBatch
+		}
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			dbi, err := openDBI(txn, name, flags|lmdb.Create)
+			if err != nil {
+				return err
+			}
+			for _, item := range items[:n] {
+				err = txn.Put(dbi, item.Key, item.Val, 0)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+func readJSONItems(r io.Reader) ([]jsonItem, error) {
+	var items []jsonItem
+	dec := json.NewDecoder(r)
+	for {
+		var item jsonItem
+		err := dec.Decode(&item)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// readDump reads a dump in the format written by mdb_dump and returns its
+// items and database flags.
+func readDump(r io.Reader) ([]jsonItem, uint, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<30)
+	var flags uint
+	print := false
+	for {
+		if !s.Scan() {
+			return nil, 0, fmt.Errorf("missing dump header")
+		}
+		line := s.Text()
+		if line == "HEADER=END" {
+			break
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, 0, fmt.Errorf("malformed header line %q", line)
+		}
+		switch kv[0] {
+		case "format":
+			print = kv[1] == "print"
+		case "type":
+			if kv[1] != "btree" {
+				return nil, 0, fmt.Errorf("unsupported dump type %q", kv[1])
+			}
+		}
+		for _, f := range dumpFlags {
+			if kv[0] == f.name && kv[1] == "1" {
+				flags |= f.flag
+			}
+		}
+	}
+
+	var items []jsonItem
+	for s.Scan() {
+		line := s.Text()
+		if line == "DATA=END" {
+			return items, flags, nil
+		}
+		key, err := readPrintable(line, print)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !s.Scan() {
+			break
+		}
+		val, err := readPrintable(s.Text(), print)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, jsonItem{key, val})
+	}
+	if s.Err() != nil {
+		return nil, 0, s.Err()
+	}
+	return nil, 0, fmt.Errorf("unexpected end of dump")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbexport"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// exportFlags defines the flags shared by export and import and returns the
+// options they set once fs has been parsed.
+func exportFlags(fs *flag.FlagSet) func() (*lmdbexport.Options, error) {
+	format := fs.String("format", "jsonl", "Item `format`, jsonl or csv.")
+	encoding := fs.String("encoding", "base64", "`Encoding` of keys and values, base64 or hex.")
+	return func() (*lmdbexport.Options, error) {
+		var err error
+		opt := &lmdbexport.Options{}
+		opt.Format, err = lmdbexport.ParseFormat(*format)
+		if err != nil {
+			return nil, err
+		}
+		opt.Encoding, err = lmdbexport.ParseEncoding(*encoding)
+		if err != nil {
+			return nil, err
+		}
+		return opt, nil
+	}
+}
+
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	options := exportFlags(fs)
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	opt, err := options()
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	return env.View(func(txn *lmdb.Txn) (err error) {
+		dbi, err := openDBI(txn, name, 0)
+		if err != nil {
+			return err
+		}
+		_, err = lmdbexport.Export(txn, dbi, os.Stdout, opt)
+		return err
+	})
+}
+
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	options := exportFlags(fs)
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	opt, err := options()
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+	env, err := openEnv(args[0], 0)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = openDBI(txn, name, lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	n, err := lmdbexport.Import(env, dbi, os.Stdin, opt)
+	if err != nil {
+		return err
+	}
+	return output(map[string]interface{}{"imported": n}, func() {})
+}
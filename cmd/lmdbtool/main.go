@@ -0,0 +1,392 @@
+/*
+Command lmdbtool bundles the operational tasks for LMDB environments in a
+single binary, so that operators need not install the C utilities.
+
+	lmdbtool [-n] [-json] command [arguments]
+
+The commands are
+
+	stat path [db ...]     statistics of the root database or the named databases
+	stat -a path           statistics of all databases
+	info path              information about the environment
+	dump path [db]         write the items of a database to standard output
+	load path [db]         read items from standard input into a database
+	export path [db]       write the items of a database as JSON lines or CSV
+	import path [db]       read items as JSON lines or CSV into a database
+	copy [-c] src dst      copy the environment, compacting it with -c
+	check path             check the consistency of the environment
+	readers [-check] path  list the reader table, clearing stale entries with -check
+	freelist path          summarize the free list
+	drop [-delete] path db empty a database, deleting it with -delete
+
+With -json the output of every command is JSON.  The dump format is that of
+mdb_dump with printable characters escaped (mdb_dump -p), which mdb_load reads,
+or JSON lines of base64 encoded keys and values with -json.  The load command
+accepts either format.  The export and import commands stream items in the
+formats of package exp/lmdbexport, selected with their -format and -encoding
+flags.  The -n flag opens environments which do not use subdirectories.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// maxDBs is the number of named databases the tool can open in one
+// environment.
+const maxDBs = 4096
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"stat":     {"stat [-a] path [db ...]", cmdStat},
+		"info":     {"info path", cmdInfo},
+		"dump":     {"dump path [db]", cmdDump},
+		"load":     {"load path [db]", cmdLoad},
+		"export":   {"export [-format jsonl|csv] [-encoding base64|hex] path [db]", cmdExport},
+		"import":   {"import [-format jsonl|csv] [-encoding base64|hex] path [db]", cmdImport},
+		"copy":     {"copy [-c] src dst", cmdCopy},
+		"check":    {"check path", cmdCheck},
+		"readers":  {"readers [-check] path", cmdReaders},
+		"freelist": {"freelist path", cmdFreelist},
+		"drop":     {"drop [-delete] path db", cmdDrop},
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	lmdbcmd.RegisterJSON()
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		log.Printf("unknown command %q", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	err := cmd.run(flag.Args()[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lmdbtool [-n] [-json] command [arguments]")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  lmdbtool", commands[name].usage)
+	}
+	flag.PrintDefaults()
+}
+
+// parseArgs parses the flags of a command and checks the number of remaining
+// arguments.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	n := fs.NArg()
+	if n < min || (max >= 0 && n > max) {
+		return nil, fmt.Errorf("usage: lmdbtool %s", commands[fs.Name()].usage)
+	}
+	return fs.Args(), nil
+}
+
+// openEnv opens the environment at path.
+func openEnv(path string, flags uint) (*lmdb.Env, error) {
+	return lmdb.Open(path, lmdb.WithMaxDBs(maxDBs), lmdb.WithFlags(lmdbcmd.OpenFlag()|flags))
+}
+
+// openDBI opens the named database, or the root database if name is empty.
+func openDBI(txn *lmdb.Txn, name string, flags uint) (lmdb.DBI, error) {
+	if name == "" {
+		return txn.OpenRoot(flags)
+	}
+	return txn.OpenDBI(name, flags)
+}
+
+// output writes v as JSON or calls text to write it as text.
+func output(v interface{}, text func()) error {
+	if lmdbcmd.JSON() {
+		return lmdbcmd.WriteJSON(v)
+	}
+	text()
+	return nil
+}
+
+func cmdStat(args []string) error {
+	fs := flag.NewFlagSet("stat", flag.ExitOnError)
+	all := fs.Bool("a", false, "Display the statistics of all databases.")
+	args, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	var stats map[string]*lmdb.Stat
+	if *all {
+		stats, err = env.StatAll()
+	} else {
+		stats = make(map[string]*lmdb.Stat)
+		names := args[1:]
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			for _, name := range names {
+				dbi, err := openDBI(txn, name, 0)
+				if err != nil {
+					return fmt.Errorf("%q: %v", name, err)
+				}
+				stats[name], err = txn.Stat(dbi)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return output(stats, func() {
+		for _, name := range names {
+			stat := stats[name]
+			if name == "" {
+				fmt.Println("Status of Main DB")
+			} else {
+				fmt.Printf("Status of %s\n", name)
+			}
+			fmt.Println("  Tree depth:", stat.Depth)
+			fmt.Println("  Branch pages:", stat.BranchPages)
+			fmt.Println("  Leaf pages:", stat.LeafPages)
+			fmt.Println("  Overflow pages:", stat.OverflowPages)
+			fmt.Println("  Entries:", stat.Entries)
+		}
+	})
+}
+
+func cmdInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		return err
+	}
+	v := struct {
+		*lmdb.EnvInfo
+		PageSize uint
+		Version  string
+	}{info, stat.PSize, lmdb.VersionString()}
+	return output(v, func() {
+		fmt.Println("Environment Info")
+		fmt.Println("  Map size:", info.MapSize)
+		fmt.Println("  Page size:", stat.PSize)
+		fmt.Println("  Max pages:", info.MapSize/int64(stat.PSize))
+		fmt.Println("  Number of pages used:", info.LastPNO+1)
+		fmt.Println("  Last transaction ID:", info.LastTxnID)
+		fmt.Println("  Max readers:", info.MaxReaders)
+		fmt.Println("  Number of readers used:", info.NumReaders)
+	})
+}
+
+func cmdCopy(args []string) error {
+	fs := flag.NewFlagSet("copy", flag.ExitOnError)
+	compact := fs.Bool("c", false, "Compact while copying.")
+	args, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	var flags uint
+	if *compact {
+		flags |= lmdb.CopyCompact
+	}
+	err = env.CopyFlag(args[1], flags)
+	if err != nil {
+		return err
+	}
+	return output(map[string]string{"copied": args[1]}, func() {})
+}
+
+func cmdCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	env, err := lmdb.Open(args[0],
+		lmdb.WithMaxDBs(maxDBs),
+		lmdb.WithFlags(lmdbcmd.OpenFlag()|lmdb.Readonly),
+		lmdb.WithSelfCheck(),
+	)
+	if d, ok := err.(*lmdb.Diagnosis); ok {
+		output(d, func() {
+			fmt.Println("Problems")
+			for _, p := range d.Problems {
+				fmt.Println("  " + p)
+			}
+			fmt.Println("Recovery")
+			for _, r := range d.Recovery {
+				fmt.Println("  " + r)
+			}
+		})
+		return fmt.Errorf("%s failed the check", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	env.Close()
+	return output(map[string]interface{}{"ok": true}, func() {
+		fmt.Println("No problems found")
+	})
+}
+
+func cmdReaders(args []string) error {
+	fs := flag.NewFlagSet("readers", flag.ExitOnError)
+	check := fs.Bool("check", false, "Clear stale entries from the reader table.")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	var cleared int
+	if *check {
+		cleared, err = env.ReaderCheck()
+		if err != nil {
+			return err
+		}
+	}
+	readers, err := env.Readers()
+	if err != nil {
+		return err
+	}
+	v := struct {
+		Readers []lmdb.ReaderInfo
+		Cleared int
+	}{readers, cleared}
+	return output(v, func() {
+		fmt.Println("Reader Table Status")
+		fmt.Printf("  %10s %16s %10s\n", "pid", "thread", "txnid")
+		for _, r := range readers {
+			txnid := fmt.Sprint(r.TxnID)
+			if r.TxnID < 0 {
+				txnid = "-"
+			}
+			fmt.Printf("  %10d %16x %10s\n", r.PID, r.Thread, txnid)
+		}
+		if *check {
+			fmt.Println(" ", cleared, "stale readers cleared.")
+		}
+	})
+}
+
+func cmdFreelist(args []string) error {
+	fs := flag.NewFlagSet("freelist", flag.ExitOnError)
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], lmdb.Readonly)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	stat, err := env.FreelistStat()
+	if err != nil {
+		return err
+	}
+	return output(stat, func() {
+		fmt.Println("Freelist Status")
+		fmt.Println("  Entries:", stat.Entries)
+		fmt.Println("  Pages in use:", stat.Pages)
+		fmt.Println("  Free pages:", stat.FreePages)
+		fmt.Println("  Runs:", stat.Runs)
+		fmt.Println("  Largest run:", stat.LargestRun)
+		fmt.Printf("  Fragmentation: %.3f\n", stat.Fragmentation)
+	})
+}
+
+func cmdDrop(args []string) error {
+	fs := flag.NewFlagSet("drop", flag.ExitOnError)
+	del := fs.Bool("delete", false, "Delete the database instead of emptying it.")
+	args, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	env, err := openEnv(args[0], 0)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenDBI(args[1], 0)
+		if err != nil {
+			return err
+		}
+		return txn.Drop(dbi, *del)
+	})
+	if err != nil {
+		return err
+	}
+	return output(map[string]interface{}{"dropped": args[1], "deleted": *del}, func() {})
+}
@@ -0,0 +1,132 @@
+// Command lmdb_stats prints the statistics history recorded in an environment
+// by the lmdbstats package as a text graph.
+//
+//	lmdb_stats [-db name] [-field used] [-since 24h] path
+//
+// Fields are used, mapsize, readers, txnid and, for a database recorded as
+// name, entries:name and pages:name.  The root database has an empty name
+// ("entries:").  With -json the records are written as a JSON array of time
+// and value pairs instead of a graph.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbstats"
+	"github.com/PowerDNS/lmdb-go/internal/lmdbcmd"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func main() {
+	dbname := flag.String("db", lmdbstats.DefaultDBName, "Name of the database holding the statistics history.")
+	field := flag.String("field", "used", "Statistic to graph.")
+	since := flag.Duration("since", 0, "Only show records newer than the given duration.")
+	width := flag.Int("width", 60, "Width of the graph bars.")
+	lmdbcmd.RegisterJSON()
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() != 1 {
+		log.Fatal("usage: lmdb_stats [flags] path")
+	}
+	value, err := fieldFunc(*field)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer env.Close()
+	err = env.SetMaxDBs(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = env.Open(flag.Arg(0), lmdbcmd.OpenFlag()|lmdb.Readonly, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	recs, err := lmdbstats.Query(env, *dbname, from, time.Time{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if lmdbcmd.JSON() {
+		err = lmdbcmd.WriteJSON(points(recs, value))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	graph(recs, value, *width)
+}
+
+// Point is a value of the graphed statistic at a time.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+func points(recs []lmdbstats.Record, value func(*lmdbstats.Record) float64) []Point {
+	ps := make([]Point, len(recs))
+	for i := range recs {
+		ps[i] = Point{recs[i].Time, value(&recs[i])}
+	}
+	return ps
+}
+
+func fieldFunc(field string) (func(*lmdbstats.Record) float64, error) {
+	switch field {
+	case "used":
+		return func(r *lmdbstats.Record) float64 { return float64(r.Used()) }, nil
+	case "mapsize":
+		return func(r *lmdbstats.Record) float64 { return float64(r.MapSize) }, nil
+	case "readers":
+		return func(r *lmdbstats.Record) float64 { return float64(r.NumReaders) }, nil
+	case "txnid":
+		return func(r *lmdbstats.Record) float64 { return float64(r.LastTxnID) }, nil
+	}
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	name := field[i+1:]
+	switch field[:i] {
+	case "entries":
+		return func(r *lmdbstats.Record) float64 { return float64(r.DBs[name].Entries) }, nil
+	case "pages":
+		return func(r *lmdbstats.Record) float64 {
+			s := r.DBs[name]
+			return float64(s.BranchPages + s.LeafPages + s.OverflowPages)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+func graph(recs []lmdbstats.Record, value func(*lmdbstats.Record) float64, width int) {
+	var max float64
+	for i := range recs {
+		if v := value(&recs[i]); v > max {
+			max = v
+		}
+	}
+	for i := range recs {
+		v := value(&recs[i])
+		n := 0
+		if max > 0 {
+			n = int(v / max * float64(width))
+		}
+		fmt.Fprintf(os.Stdout, "%s %14.0f %s\n", recs[i].Time.Format(time.RFC3339), v, strings.Repeat("#", n))
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Package lmdbanalyze provides routines that analyze the contents of LMDB
+databases to help with capacity planning and schema design.  Analysis is
+performed inside a caller-provided transaction and reads every item in the
+databases analyzed, so it can be expensive on large databases.
+*/
+package lmdbanalyze
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// scanBatch is the number of items read from a cursor with each call to
+// lmdb.Cursor.GetBatch.
+const scanBatch = 256
+
+// Bucketizer maps a key to the bucket it is counted in.  The returned slice
+// may reference key.
+type Bucketizer func(key []byte) []byte
+
+// PrefixBucketizer returns a Bucketizer that buckets keys by their first n
+// bytes.  Keys shorter than n bytes are bucketed by the entire key.
+func PrefixBucketizer(n int) Bucketizer {
+	return func(key []byte) []byte {
+		if len(key) < n {
+			return key
+		}
+		return key[:n]
+	}
+}
+
+// Bucket contains counts for the items in one bucket of a Heatmap.
+type Bucket struct {
+	Bucket   []byte `json:"-"`
+	Entries  uint64 `json:"entries"`
+	KeyBytes uint64 `json:"key_bytes"`
+	ValBytes uint64 `json:"val_bytes"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.  The bucket is encoded
+// in hexadecimal because keys are not necessarily valid UTF-8.
+func (b *Bucket) MarshalJSON() ([]byte, error) {
+	type bucket Bucket
+	return json.Marshal(&struct {
+		Bucket string `json:"bucket"`
+		*bucket
+	}{hex.EncodeToString(b.Bucket), (*bucket)(b)})
+}
+
+// Heatmap describes the distribution of the items in a database over the
+// buckets of a Bucketizer.  Buckets are ordered by their bucket value.
+type Heatmap struct {
+	Entries  uint64    `json:"entries"`
+	KeyBytes uint64    `json:"key_bytes"`
+	ValBytes uint64    `json:"val_bytes"`
+	Buckets  []*Bucket `json:"buckets"`
+}
+
+// KeyHeatmap scans all items in dbi and counts them in the bucket returned by
+// fn for their key.  If fn is nil the keys are bucketed by their first byte.
+func KeyHeatmap(txn *lmdb.Txn, dbi lmdb.DBI, fn Bucketizer) (*Heatmap, error) {
+	if fn == nil {
+		fn = PrefixBucketizer(1)
+	}
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	// Only the lengths of values are needed, so they do not have to be copied
+	// out of the memory map.  Keys given to fn are copied when a new bucket is
+	// created.
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+
+	h := &Heatmap{}
+	buckets := make(map[string]*Bucket)
+	for {
+		keys, vals, err := cur.GetBatch(scanBatch)
+		if lmdb.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys {
+			b := fn(keys[i])
+			bucket, ok := buckets[string(b)]
+			if !ok {
+				bucket = &Bucket{Bucket: append([]byte{}, b...)}
+				buckets[string(b)] = bucket
+				h.Buckets = append(h.Buckets, bucket)
+			}
+			bucket.Entries++
+			bucket.KeyBytes += uint64(len(keys[i]))
+			bucket.ValBytes += uint64(len(vals[i]))
+			h.Entries++
+			h.KeyBytes += uint64(len(keys[i]))
+			h.ValBytes += uint64(len(vals[i]))
+		}
+	}
+
+	sort.Slice(h.Buckets, func(i, j int) bool {
+		return string(h.Buckets[i].Bucket) < string(h.Buckets[j].Bucket)
+	})
+	return h, nil
+}
+
+// WriteJSON writes h to w as a JSON object.
+func (h *Heatmap) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(h)
+}
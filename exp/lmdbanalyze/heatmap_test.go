@@ -0,0 +1,81 @@
+package lmdbanalyze
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestKeyHeatmap(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items lmdbtest.SimpleItemList
+	for i := 0; i < 10; i++ {
+		items = append(items, &lmdbtest.SimpleItem{K: fmt.Sprintf("a%d", i), V: "vv"})
+	}
+	for i := 0; i < 5; i++ {
+		items = append(items, &lmdbtest.SimpleItem{K: fmt.Sprintf("b%d", i), V: "v"})
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h *Heatmap
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		h, err = KeyHeatmap(txn, dbi, nil)
+		if txn.RawRead {
+			t.Errorf("RawRead was not restored")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Entries != 15 || h.KeyBytes != 30 || h.ValBytes != 25 {
+		t.Errorf("unexpected totals: %d %d %d", h.Entries, h.KeyBytes, h.ValBytes)
+	}
+	if len(h.Buckets) != 2 {
+		t.Fatalf("unexpected number of buckets: %d", len(h.Buckets))
+	}
+	a, b := h.Buckets[0], h.Buckets[1]
+	if string(a.Bucket) != "a" || a.Entries != 10 || a.ValBytes != 20 {
+		t.Errorf("unexpected bucket: %+v", a)
+	}
+	if string(b.Bucket) != "b" || b.Entries != 5 || b.ValBytes != 5 {
+		t.Errorf("unexpected bucket: %+v", b)
+	}
+
+	var buf bytes.Buffer
+	err = h.WriteJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Buckets []struct {
+			Bucket  string `json:"bucket"`
+			Entries uint64 `json:"entries"`
+		} `json:"buckets"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Buckets) != 2 || decoded.Buckets[0].Bucket != "61" || decoded.Buckets[0].Entries != 10 {
+		t.Errorf("unexpected json: %s", buf.Bytes())
+	}
+}
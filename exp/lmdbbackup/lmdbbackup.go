@@ -0,0 +1,338 @@
+/*
+Package lmdbbackup maintains full and incremental backups of an environment in
+a backup directory.
+
+A full backup is a copy of the environment made with lmdb.Env.Copy, or with
+lmdb.Env.CopyWith by FullCopy to limit its impact on the live workload.  Between
+full backups a Capture records the logical changes of every write transaction
+(see the lmdbrepl package) in increment files, so that the cost of a backup
+is proportional to the amount of data written rather than the size of the
+environment.  Writers must route their changes through the lmdbrepl.Leader of
+the Capture; changes made by other means are not captured.
+
+A Capture should be started before the first full backup.  A full backup
+records the ID of a transaction which its copy is known to contain and
+replaying frames which the copy already contains is harmless, so full backups
+do not need to block writers.
+
+To restore, Restore places the latest full backup at a path, where it is
+opened as an environment, and Replay applies the captured increments.  Replay
+uses an lmdbrepl.Follower, which requires one extra named database in the
+restored environment.
+
+The backup directory contains the following files:
+
+	full-<txnid>/data.mdb   a full backup containing transaction txnid
+	incr-<seq>.log          captured frames, in lmdbrepl encoding
+*/
+package lmdbbackup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbrepl"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrNoBackup is returned by Restore when a directory contains no full backup.
+var ErrNoBackup = errors.New("lmdbbackup: no full backup")
+
+const (
+	fullPrefix = "full-"
+	incrPrefix = "incr-"
+	incrSuffix = ".log"
+	dataFile   = "data.mdb"
+)
+
+// Full writes a full backup of env to dir and returns the ID of a transaction
+// that the backup is guaranteed to contain.  The backup may contain later
+// transactions as well.
+func Full(env *lmdb.Env, dir string) (uint64, error) {
+	return FullCopy(env, dir, nil)
+}
+
+// FullCopy is like Full but copies env with lmdb.Env.CopyWith and opt, which
+// can limit the rate of the copy and report its progress.
+func FullCopy(env *lmdb.Env, dir string, opt *lmdb.CopyOptions) (uint64, error) {
+	var base uint64
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		base = uint64(txn.ID())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("%s%020d", fullPrefix, base))
+	tmp := name + ".tmp"
+	err = os.RemoveAll(tmp)
+	if err != nil {
+		return 0, err
+	}
+	err = os.MkdirAll(tmp, 0755)
+	if err != nil {
+		return 0, err
+	}
+	if opt == nil {
+		err = env.Copy(tmp)
+	} else {
+		err = env.CopyWith(tmp, opt)
+	}
+	if err == nil {
+		err = os.RemoveAll(name)
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return 0, err
+	}
+	return base, nil
+}
+
+// Capture writes the frames sent by a Leader to increment files.
+type Capture struct {
+	dir string
+	l   *lmdbrepl.Leader
+	n   int
+
+	mu  sync.Mutex
+	seq int
+	f   *os.File
+	s   *lmdbrepl.Stream
+}
+
+// NewCapture starts capturing the frames sent by l to a new increment file in
+// dir.  Up to n frames are buffered while the file is being written; if the
+// buffer overflows the capture stops and Err returns lmdbrepl.ErrLagging.
+func NewCapture(l *lmdbrepl.Leader, dir string, n int) (*Capture, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	incrs, err := increments(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Capture{dir: dir, l: l, n: n}
+	if len(incrs) > 0 {
+		c.seq = incrs[len(incrs)-1].seq
+	}
+	err = c.Rotate()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Rotate closes the current increment file and continues capturing in a new
+// one, after which the old file may be copied elsewhere.  Frames committed
+// while Rotate runs may be written to both files.
+func (c *Capture) Rotate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	name := filepath.Join(c.dir, fmt.Sprintf("%s%020d%s", incrPrefix, c.seq, incrSuffix))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	s := c.l.Attach(f, c.n)
+	err = c.close()
+	c.f, c.s = f, s
+	return err
+}
+
+// Err returns the error which stopped the capture of the current increment
+// file, or nil if it is still running.
+func (c *Capture) Err() error {
+	c.mu.Lock()
+	s := c.s
+	c.mu.Unlock()
+	select {
+	case <-s.Done():
+		return s.Err()
+	default:
+		return nil
+	}
+}
+
+// Close stops capturing and syncs the current increment file.
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.close()
+}
+
+func (c *Capture) close() error {
+	if c.s == nil {
+		return nil
+	}
+	c.s.Close()
+	err := c.s.Err()
+	if serr := c.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	c.f, c.s = nil, nil
+	return err
+}
+
+// Restore copies the latest full backup in dir to the directory path, which is
+// created if necessary, and returns the transaction ID of the backup.
+func Restore(dir, path string) (uint64, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var latest string
+	var base uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, fullPrefix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(name, fullPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		if latest == "" || id > base {
+			latest, base = name, id
+		}
+	}
+	if latest == "" {
+		return 0, ErrNoBackup
+	}
+
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return 0, err
+	}
+	err = copyFile(filepath.Join(path, dataFile), filepath.Join(dir, latest, dataFile))
+	if err != nil {
+		return 0, err
+	}
+	return base, nil
+}
+
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if serr := w.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Replay applies the frames captured in the increment files of dir to env, in
+// the order they were captured, skipping frames of transactions not later
+// than base.  Replay returns the ID of the last transaction applied to env.
+// An increment file which ends in a partial frame, because the capture was
+// interrupted, ends the replay without an error.
+func Replay(env *lmdb.Env, dir string, base uint64) (uint64, error) {
+	f, err := lmdbrepl.NewFollower(env)
+	if err != nil {
+		return 0, err
+	}
+	last, err := f.Last()
+	if err != nil {
+		return 0, err
+	}
+	if last < base {
+		last = base
+	}
+	incrs, err := increments(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, incr := range incrs {
+		var partial bool
+		last, partial, err = replayFile(f, filepath.Join(dir, incr.name), last)
+		if err != nil {
+			return last, err
+		}
+		if partial {
+			break
+		}
+	}
+	return last, nil
+}
+
+func replayFile(f *lmdbrepl.Follower, path string, last uint64) (uint64, bool, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return last, false, err
+	}
+	defer r.Close()
+	dec := lmdbrepl.NewDecoder(r)
+	for {
+		fr, err := dec.Decode()
+		if err == io.EOF {
+			return last, false, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return last, true, nil
+		}
+		if err != nil {
+			return last, false, err
+		}
+		if fr.TxnID <= last {
+			continue
+		}
+		err = f.Apply(fr)
+		if err != nil {
+			return last, false, err
+		}
+		last = fr.TxnID
+	}
+}
+
+type increment struct {
+	name string
+	seq  int
+}
+
+// increments returns the increment files in dir ordered by sequence number.
+func increments(dir string) ([]increment, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var incrs []increment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, incrPrefix) || !strings.HasSuffix(name, incrSuffix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, incrPrefix), incrSuffix))
+		if err != nil {
+			continue
+		}
+		incrs = append(incrs, increment{name, seq})
+	}
+	sort.Slice(incrs, func(i, j int) bool { return incrs[i].seq < incrs[j].seq })
+	return incrs, nil
+}
@@ -0,0 +1,159 @@
+package lmdbbackup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbrepl"
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestBackup(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dir, err := ioutil.TempDir("", "lmdbbackup-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := lmdbrepl.NewLeader()
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenDBI("items", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		return l.AddDBI(txn, "items", dbi)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Restore(dir, filepath.Join(dir, "none"))
+	if err != ErrNoBackup {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	c, err := NewCapture(l, dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(k, v string) {
+		err := l.Update(env, func(txn *lmdbrepl.Txn) error {
+			return txn.Put(dbi, []byte(k), []byte(v), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	del := func(k string) {
+		err := l.Update(env, func(txn *lmdbrepl.Txn) error {
+			return txn.Del(dbi, []byte(k), nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put("a", "1")
+	put("b", "1")
+	var progress lmdb.CopyProgress
+	base, err := FullCopy(env, dir, &lmdb.CopyOptions{
+		Progress: func(p lmdb.CopyProgress) { progress = p },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Bytes == 0 {
+		t.Errorf("no progress reported")
+	}
+	put("a", "2")
+	del("b")
+	err = c.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	put("c", "3")
+	err = c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "restored")
+	id, err := Restore(dir, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != base {
+		t.Errorf("unexpected base: %d (!= %d)", id, base)
+	}
+
+	renv, err := lmdb.NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renv.Close()
+	err = renv.SetMaxDBs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = renv.Open(path, 0, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := Replay(renv, dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != base+3 {
+		t.Errorf("unexpected last txnid: %d (!= %d)", last, base+3)
+	}
+
+	err = renv.View(func(txn *lmdb.Txn) (err error) {
+		rdbi, err := txn.OpenDBI("items", 0)
+		if err != nil {
+			return err
+		}
+		var items []string
+		cur, err := txn.OpenCursor(rdbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for {
+			k, v, err := cur.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			items = append(items, fmt.Sprintf("%s=%s", k, v))
+		}
+		if fmt.Sprint(items) != "[a=2 c=3]" {
+			t.Errorf("unexpected items: %v", items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaying again applies nothing new.
+	again, err := Replay(renv, dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != last {
+		t.Errorf("unexpected last txnid: %d (!= %d)", again, last)
+	}
+}
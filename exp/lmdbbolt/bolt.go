@@ -0,0 +1,325 @@
+package lmdbbolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// The layout of bolt data files, as written by bbolt on little-endian
+// machines.  Bolt writes its structures in native byte order, so files
+// created on big-endian machines are rejected as having an invalid magic.
+const (
+	boltMagic   = 0xED0CDAED
+	boltVersion = 2
+
+	pageHeaderSize = 16 // id uint64, flags uint16, count uint16, overflow uint32
+	elemSize       = 16 // Size of branch and leaf page elements
+	bucketSize     = 16 // root uint64, sequence uint64
+	metaSize       = 64 // Size of the meta structure, ending with its checksum
+
+	branchPageFlag = 0x01
+	leafPageFlag   = 0x02
+	metaPageFlag   = 0x04
+	bucketLeafFlag = 0x01
+
+	minPageSize     = 512
+	maxPageSize     = 1 << 20
+	defaultPageSize = 4096
+)
+
+// ErrInvalid is returned when the file is not a valid bolt database.
+var ErrInvalid = errors.New("lmdbbolt: invalid bolt database")
+
+// boltFile reads the pages of a bolt data file.
+type boltFile struct {
+	f        *os.File
+	pageSize int
+	root     uint64 // Root page of the root bucket
+}
+
+func openBolt(path string) (*boltFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &boltFile{f: f}
+	err = b.readMeta()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *boltFile) Close() error {
+	return b.f.Close()
+}
+
+// meta is the content of a meta page used by the reader.
+type meta struct {
+	pageSize int
+	root     uint64 // Root page of the root bucket
+	txid     uint64
+}
+
+// readMeta selects the valid meta page with the latest transaction.
+func (b *boltFile) readMeta() error {
+	m0, ok0, err := b.readMetaAt(0)
+	if err != nil {
+		return err
+	}
+	// The first meta page may be torn, in which case the page size needed to
+	// find the second is unknown and the common default is assumed.
+	b.pageSize = defaultPageSize
+	if ok0 {
+		b.pageSize = m0.pageSize
+	}
+	m1, ok1, err := b.readMetaAt(int64(b.pageSize))
+	if err != nil {
+		return err
+	}
+	switch {
+	case ok0 && (!ok1 || m0.txid >= m1.txid):
+		b.root = m0.root
+	case ok1:
+		b.pageSize, b.root = m1.pageSize, m1.root
+	default:
+		return ErrInvalid
+	}
+	return nil
+}
+
+// readMetaAt reads the meta page at off and returns false if it is invalid.
+func (b *boltFile) readMetaAt(off int64) (m meta, ok bool, err error) {
+	p := make([]byte, pageHeaderSize+metaSize)
+	_, err = b.f.ReadAt(p, off)
+	if err == io.EOF {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, err
+	}
+	le := binary.LittleEndian
+	if le.Uint16(p[8:])&metaPageFlag == 0 {
+		return m, false, nil
+	}
+	d := p[pageHeaderSize:]
+	if le.Uint32(d[0:]) != boltMagic || le.Uint32(d[4:]) != boltVersion {
+		return m, false, nil
+	}
+	h := fnv.New64a()
+	h.Write(d[:metaSize-8])
+	if h.Sum64() != le.Uint64(d[metaSize-8:]) {
+		return m, false, nil
+	}
+	m.pageSize = int(le.Uint32(d[8:]))
+	if m.pageSize < minPageSize || m.pageSize > maxPageSize {
+		return m, false, nil
+	}
+	m.root = le.Uint64(d[16:])
+	m.txid = le.Uint64(d[48:])
+	return m, true, nil
+}
+
+// page reads page id and its overflow pages.
+func (b *boltFile) page(id uint64) ([]byte, error) {
+	off := int64(id) * int64(b.pageSize)
+	p := make([]byte, b.pageSize)
+	_, err := b.f.ReadAt(p, off)
+	if err != nil {
+		return nil, fmt.Errorf("lmdbbolt: page %d: %v", id, err)
+	}
+	if binary.LittleEndian.Uint64(p) != id {
+		return nil, fmt.Errorf("lmdbbolt: page %d: %w", id, ErrInvalid)
+	}
+	if overflow := binary.LittleEndian.Uint32(p[12:]); overflow > 0 {
+		p = make([]byte, (int(overflow)+1)*b.pageSize)
+		_, err = b.f.ReadAt(p, off)
+		if err != nil {
+			return nil, fmt.Errorf("lmdbbolt: page %d: %v", id, err)
+		}
+	}
+	return p, nil
+}
+
+// bucketPage returns the root page of the bucket whose value is v, which is
+// stored in v itself for inline buckets.
+func (b *boltFile) bucketPage(v []byte) ([]byte, error) {
+	if len(v) < bucketSize {
+		return nil, ErrInvalid
+	}
+	root := binary.LittleEndian.Uint64(v)
+	if root == 0 {
+		return v[bucketSize:], nil
+	}
+	return b.page(root)
+}
+
+// element returns element i of the branch or leaf page p.  For branch pages
+// the child page is returned in child, for leaf pages the value and flags in
+// val and flags.
+func element(p []byte, i int) (key, val []byte, child uint64, flags uint32, err error) {
+	le := binary.LittleEndian
+	if len(p) < pageHeaderSize+(i+1)*elemSize {
+		return nil, nil, 0, 0, ErrInvalid
+	}
+	e := pageHeaderSize + i*elemSize
+	if le.Uint16(p[8:])&branchPageFlag != 0 {
+		pos, ksize := int(le.Uint32(p[e:])), int(le.Uint32(p[e+4:]))
+		if e+pos+ksize > len(p) {
+			return nil, nil, 0, 0, ErrInvalid
+		}
+		return p[e+pos : e+pos+ksize], nil, le.Uint64(p[e+8:]), 0, nil
+	}
+	flags = le.Uint32(p[e:])
+	pos, ksize, vsize := int(le.Uint32(p[e+4:])), int(le.Uint32(p[e+8:])), int(le.Uint32(p[e+12:]))
+	if e+pos+ksize+vsize > len(p) {
+		return nil, nil, 0, 0, ErrInvalid
+	}
+	return p[e+pos : e+pos+ksize], p[e+pos+ksize : e+pos+ksize+vsize], 0, flags, nil
+}
+
+// pageKind returns the flags and element count of page p.
+func pageKind(p []byte) (flags uint16, count int, err error) {
+	if len(p) < pageHeaderSize {
+		return 0, 0, ErrInvalid
+	}
+	flags = binary.LittleEndian.Uint16(p[8:])
+	if flags&(branchPageFlag|leafPageFlag) == 0 {
+		return 0, 0, ErrInvalid
+	}
+	return flags, int(binary.LittleEndian.Uint16(p[10:])), nil
+}
+
+// walker iterates over the items of a bucket and its nested buckets in key
+// order.  The keys of items in nested buckets are prefixed with the names of
+// the buckets, each followed by a zero byte.
+type walker struct {
+	b     *boltFile
+	stack []frame
+	key   []byte
+	last  []byte
+}
+
+type frame struct {
+	page   []byte
+	count  int
+	branch bool
+	i      int
+	prefix []byte
+}
+
+func newWalker(b *boltFile, root []byte) (*walker, error) {
+	w := &walker{b: b}
+	return w, w.push(root, nil)
+}
+
+func (w *walker) push(p, prefix []byte) error {
+	flags, count, err := pageKind(p)
+	if err != nil {
+		return err
+	}
+	w.stack = append(w.stack, frame{
+		page:   p,
+		count:  count,
+		branch: flags&branchPageFlag != 0,
+		prefix: prefix,
+	})
+	return nil
+}
+
+// Next implements lmdbload.Source.
+func (w *walker) Next() (key, val []byte, err error) {
+	for len(w.stack) > 0 {
+		f := &w.stack[len(w.stack)-1]
+		if f.i >= f.count {
+			w.stack = w.stack[:len(w.stack)-1]
+			continue
+		}
+		k, v, child, flags, err := element(f.page, f.i)
+		f.i++
+		if err != nil {
+			return nil, nil, err
+		}
+		prefix := f.prefix
+		if f.branch {
+			p, err := w.b.page(child)
+			if err != nil {
+				return nil, nil, err
+			}
+			err = w.push(p, prefix)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if flags&bucketLeafFlag != 0 {
+			p, err := w.b.bucketPage(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			sub := append(append(append([]byte(nil), prefix...), k...), 0)
+			err = w.push(p, sub)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		w.key = append(append(w.key[:0], prefix...), k...)
+		if w.last != nil && string(w.key) <= string(w.last) {
+			return nil, nil, fmt.Errorf("lmdbbolt: key %q collides with a nested bucket", w.key)
+		}
+		w.last = append(w.last[:0], w.key...)
+		return w.key, v, nil
+	}
+	return nil, nil, io.EOF
+}
+
+// bucketRef is an entry of the root bucket.
+type bucketRef struct {
+	name  string
+	value []byte
+}
+
+// buckets returns the entries of the root bucket, which are all buckets.
+func (b *boltFile) buckets() ([]bucketRef, error) {
+	p, err := b.page(b.root)
+	if err != nil {
+		return nil, err
+	}
+	var refs []bucketRef
+	var walk func(p []byte) error
+	walk = func(p []byte) error {
+		flags, count, err := pageKind(p)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			k, v, child, eflags, err := element(p, i)
+			if err != nil {
+				return err
+			}
+			if flags&branchPageFlag != 0 {
+				cp, err := b.page(child)
+				if err != nil {
+					return err
+				}
+				err = walk(cp)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if eflags&bucketLeafFlag == 0 {
+				return fmt.Errorf("lmdbbolt: key %q of the root bucket: %w", k, ErrInvalid)
+			}
+			refs = append(refs, bucketRef{name: string(k), value: v})
+		}
+		return nil
+	}
+	return refs, walk(p)
+}
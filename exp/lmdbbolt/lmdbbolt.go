@@ -0,0 +1,177 @@
+/*
+Package lmdbbolt imports bolt and bbolt databases into LMDB environments, for
+applications migrating from bolt.
+
+Import reads the bolt data file directly, without depending on a bolt package,
+and copies each top-level bucket into the named LMDB database of the same name
+with an lmdbload.BulkLoader.  The items of nested buckets are stored in the
+database of their top-level bucket, under their key prefixed with the names of
+the nested buckets, each followed by a zero byte.  An item with key "k" in
+bucket "b" nested in bucket "a" is stored in database "a" under "b\x00k".
+Because the zero byte sorts before all others the prefixed keys keep the order
+of bolt, so they can be appended by the bulk loader.  A key containing a zero
+byte may collide with the keys of a nested bucket, which Import reports as an
+error.  Empty nested buckets and bucket sequence numbers are not preserved.
+
+The bolt database must not be modified while it is imported.
+*/
+package lmdbbolt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbload"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Options configure an import.
+type Options struct {
+	// Buckets, if not empty, restricts the import to the named top-level
+	// buckets.
+	Buckets []string
+
+	// ChunkSize and NoSync are passed to the bulk loader, see
+	// lmdbload.Options.
+	ChunkSize int
+	NoSync    bool
+
+	// Progress, if not nil, is called with the number of items of bucket
+	// imported so far, after every ChunkSize items and once the bucket is
+	// complete.
+	Progress func(bucket string, items uint64)
+}
+
+// Bucket describes a top-level bucket that was imported.
+type Bucket struct {
+	Name  string
+	Items uint64 // Items imported, including those of nested buckets
+}
+
+// Buckets returns the names of the top-level buckets of the bolt database at
+// path, in order.
+func Buckets(path string) ([]string, error) {
+	b, err := openBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	refs, err := b.buckets()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(refs))
+	for i, r := range refs {
+		names[i] = r.name
+	}
+	return names, nil
+}
+
+// Import copies the buckets of the bolt database at path into env, which must
+// allow for a named database per bucket, see lmdb.Env.SetMaxDBs.  The
+// databases are created if necessary and must be empty.  A nil opt uses the
+// zero Options.
+func Import(env *lmdb.Env, path string, opt *Options) ([]Bucket, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	b, err := openBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	refs, err := b.buckets()
+	if err != nil {
+		return nil, err
+	}
+	var imported []Bucket
+	for _, r := range refs {
+		if len(opt.Buckets) > 0 && !contains(opt.Buckets, r.name) {
+			continue
+		}
+		n, err := importBucket(env, b, r, opt)
+		if err != nil {
+			return imported, fmt.Errorf("lmdbbolt: bucket %q: %w", r.name, err)
+		}
+		imported = append(imported, Bucket{Name: r.name, Items: n})
+	}
+	return imported, nil
+}
+
+func importBucket(env *lmdb.Env, b *boltFile, r bucketRef, opt *Options) (uint64, error) {
+	if strings.IndexByte(r.name, 0) >= 0 {
+		return 0, errors.New("name contains a zero byte")
+	}
+	var dbi lmdb.DBI
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenDBI(r.name, lmdb.Create)
+		if err != nil {
+			return err
+		}
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries > 0 {
+			return errors.New("database is not empty")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	root, err := b.bucketPage(r.value)
+	if err != nil {
+		return 0, err
+	}
+	w, err := newWalker(b, root)
+	if err != nil {
+		return 0, err
+	}
+	l, err := lmdbload.New(env, dbi, &lmdbload.Options{ChunkSize: opt.ChunkSize, NoSync: opt.NoSync})
+	if err != nil {
+		return 0, err
+	}
+	chunk := opt.ChunkSize
+	if chunk <= 0 {
+		chunk = lmdbload.DefaultChunkSize
+	}
+	var added uint64
+	for {
+		k, v, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = l.Add(k, v)
+		}
+		if err != nil {
+			l.Close()
+			return l.Count(), err
+		}
+		added++
+		if opt.Progress != nil && added%uint64(chunk) == 0 {
+			opt.Progress(r.name, l.Count())
+		}
+	}
+	err = l.Close()
+	if err != nil {
+		return l.Count(), err
+	}
+	if opt.Progress != nil {
+		opt.Progress(r.name, l.Count())
+	}
+	return l.Count(), nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package lmdbbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+const testPageSize = 4096
+
+type testElem struct {
+	bucket bool
+	key    string
+	val    []byte
+	child  uint64
+}
+
+// testPage encodes a branch or leaf page.  If id is zero the page is inline
+// and not padded.
+func testPage(id uint64, branch bool, elems []testElem) []byte {
+	le := binary.LittleEndian
+	p := make([]byte, pageHeaderSize+len(elems)*elemSize)
+	le.PutUint64(p, id)
+	if branch {
+		le.PutUint16(p[8:], branchPageFlag)
+	} else {
+		le.PutUint16(p[8:], leafPageFlag)
+	}
+	le.PutUint16(p[10:], uint16(len(elems)))
+	for i, e := range elems {
+		off := pageHeaderSize + i*elemSize
+		pos := uint32(len(p) - off)
+		if branch {
+			le.PutUint32(p[off:], pos)
+			le.PutUint32(p[off+4:], uint32(len(e.key)))
+			le.PutUint64(p[off+8:], e.child)
+		} else {
+			if e.bucket {
+				le.PutUint32(p[off:], bucketLeafFlag)
+			}
+			le.PutUint32(p[off+4:], pos)
+			le.PutUint32(p[off+8:], uint32(len(e.key)))
+			le.PutUint32(p[off+12:], uint32(len(e.val)))
+		}
+		p = append(p, e.key...)
+		p = append(p, e.val...)
+	}
+	if id == 0 {
+		return p
+	}
+	n := (len(p) + testPageSize - 1) / testPageSize
+	le.PutUint32(p[12:], uint32(n-1))
+	return append(p, make([]byte, n*testPageSize-len(p))...)
+}
+
+// testBucket returns the value of a bucket with its root at page root, or the
+// inline page if root is zero.
+func testBucket(root uint64, inline []byte) []byte {
+	v := make([]byte, bucketSize)
+	binary.LittleEndian.PutUint64(v, root)
+	return append(v, inline...)
+}
+
+func testMeta(id, root, txid uint64) []byte {
+	le := binary.LittleEndian
+	p := make([]byte, testPageSize)
+	le.PutUint64(p, id)
+	le.PutUint16(p[8:], metaPageFlag)
+	m := p[pageHeaderSize:]
+	le.PutUint32(m[0:], boltMagic)
+	le.PutUint32(m[4:], boltVersion)
+	le.PutUint32(m[8:], testPageSize)
+	le.PutUint64(m[16:], root)
+	le.PutUint64(m[48:], txid)
+	h := fnv.New64a()
+	h.Write(m[:metaSize-8])
+	le.PutUint64(m[metaSize-8:], h.Sum64())
+	return p
+}
+
+func writeTestBolt(t *testing.T, path string) {
+	big := bytes.Repeat([]byte("x"), 6000)
+	pages := map[uint64][]byte{
+		// Meta page 0 is older and points to an empty root.
+		0: testMeta(0, 2, 1),
+		1: testMeta(1, 3, 2),
+		2: testPage(2, false, nil),
+		3: testPage(3, false, []testElem{
+			{bucket: true, key: "a", val: testBucket(0, testPage(0, false, []testElem{
+				{key: "k1", val: []byte("v1")},
+				{key: "k2", val: []byte("v2")},
+				{bucket: true, key: "n", val: testBucket(0, testPage(0, false, []testElem{
+					{key: "x", val: []byte("y")},
+				}))},
+			}))},
+			{bucket: true, key: "b", val: testBucket(4, nil)},
+			{bucket: true, key: "c", val: testBucket(0, testPage(0, false, []testElem{
+				{bucket: true, key: "n", val: testBucket(0, testPage(0, false, []testElem{
+					{key: "x", val: []byte("y")},
+				}))},
+				{key: "n\x00a", val: []byte("z")},
+			}))},
+		}),
+		4: testPage(4, true, []testElem{{key: "m1", child: 5}, {key: "m2", child: 6}}),
+		5: testPage(5, false, []testElem{{key: "m1", val: []byte("v")}}),
+		6: testPage(6, false, []testElem{{key: "m2", val: big}}),
+	}
+	var data []byte
+	for id := uint64(0); id < 8; id++ {
+		p, ok := pages[id]
+		if !ok {
+			continue
+		}
+		if len(data) < int(id)*testPageSize {
+			data = append(data, make([]byte, int(id)*testPageSize-len(data))...)
+		}
+		data = append(data, p...)
+	}
+	err := ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lmdbbolt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bolt.db")
+	writeTestBolt(t, path)
+
+	names, err := Buckets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("buckets %q", names)
+	}
+
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	var progress []uint64
+	buckets, err := Import(env, path, &Options{
+		Buckets:   []string{"a", "b"},
+		ChunkSize: 1,
+		Progress: func(bucket string, items uint64) {
+			progress = append(progress, items)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Bucket{{"a", 3}, {"b", 2}}
+	if !reflect.DeepEqual(buckets, expect) {
+		t.Errorf("imported %v (!= %v)", buckets, expect)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 2 {
+		t.Errorf("progress %v", progress)
+	}
+
+	items := map[string]map[string]string{}
+	err = env.View(func(txn *lmdb.Txn) error {
+		for _, name := range []string{"a", "b"} {
+			dbi, err := txn.OpenDBI(name, 0)
+			if err != nil {
+				return err
+			}
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			items[name] = map[string]string{}
+			for {
+				k, v, err := cur.Get(nil, nil, lmdb.Next)
+				if lmdb.IsNotFound(err) {
+					break
+				}
+				if err != nil {
+					cur.Close()
+					return err
+				}
+				items[name][string(k)] = string(v)
+			}
+			cur.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items["a"], map[string]string{"k1": "v1", "k2": "v2", "n\x00x": "y"}) {
+		t.Errorf("bucket a: %q", items["a"])
+	}
+	if len(items["b"]) != 2 || items["b"]["m1"] != "v" || len(items["b"]["m2"]) != 6000 {
+		t.Errorf("bucket b: %d items", len(items["b"]))
+	}
+
+	_, err = Import(env, path, &Options{Buckets: []string{"c"}})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err = Import(env, path, &Options{Buckets: []string{"a"}})
+	if err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
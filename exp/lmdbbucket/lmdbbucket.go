@@ -0,0 +1,394 @@
+/*
+Package lmdbbucket aggregates time-stamped values into fixed time buckets
+stored in IntegerKey databases, as is common for telemetry.
+
+A Series has one or more levels of increasing bucket width, for example
+minutes, hours and days.  Values are added to the buckets of the finest level
+and merged with the values already in the bucket by a MergeFunc.  Series.Rollup
+periodically merges the complete buckets of each level into the buckets of the
+next coarser level and deletes buckets older than the retention of their level.
+Series.Range reads the buckets of a level in a time window.
+
+Each level is a database keyed by the start of its buckets in Unix seconds,
+encoded with lmdb.EncodeUint, so buckets are ordered by time.  Stats is a
+ready-made aggregate of numeric samples.
+*/
+package lmdbbucket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// MergeFunc combines the value v into the aggregate value acc of a bucket and
+// returns the result.  It may modify and return acc, which is never memory
+// owned by LMDB, but must not retain v.  Acc is empty for the first value of a
+// bucket.  Merging must be associative so that rollups produce the same
+// result as adding every value to a coarse bucket directly.
+type MergeFunc func(acc, v []byte) ([]byte, error)
+
+// Level describes the buckets of a level of a Series.
+type Level struct {
+	Name  string        // Name of the database holding the buckets
+	Width time.Duration // Width of the buckets, a multiple of a second
+
+	// Retention is how long buckets are kept after their end.  Zero keeps
+	// buckets forever.  Buckets of a level are only deleted once they have
+	// been rolled up into the next level.
+	Retention time.Duration
+}
+
+// Options configure a Series.
+type Options struct {
+	// Levels are ordered from the finest to the coarsest.  The width of a
+	// level must be a multiple of the width of the previous level.
+	Levels []Level
+
+	Merge MergeFunc
+}
+
+var errNoMerge = errors.New("lmdbbucket: Options.Merge must be set")
+
+// Series stores values aggregated in time buckets.  A Series is safe for
+// concurrent use.
+type Series struct {
+	env    *lmdb.Env
+	levels []Level
+	dbis   []lmdb.DBI
+	merge  MergeFunc
+}
+
+// Open returns a Series stored in env, creating the databases of its levels if
+// they do not exist.  The environment must allow enough named databases for
+// the levels.
+func Open(env *lmdb.Env, opt *Options) (*Series, error) {
+	if opt == nil || opt.Merge == nil {
+		return nil, errNoMerge
+	}
+	if len(opt.Levels) == 0 {
+		return nil, errors.New("lmdbbucket: no levels")
+	}
+	for i, l := range opt.Levels {
+		if l.Width < time.Second || l.Width%time.Second != 0 {
+			return nil, fmt.Errorf("lmdbbucket: level %q: width %v is not a multiple of a second", l.Name, l.Width)
+		}
+		if i > 0 && l.Width%opt.Levels[i-1].Width != 0 {
+			return nil, fmt.Errorf("lmdbbucket: level %q: width %v is not a multiple of %v", l.Name, l.Width, opt.Levels[i-1].Width)
+		}
+	}
+	s := &Series{
+		env:    env,
+		levels: append([]Level(nil), opt.Levels...),
+		merge:  opt.Merge,
+	}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, l := range s.levels {
+			dbi, err := txn.OpenDBI(l.Name, lmdb.Create|lmdb.IntegerKey)
+			if err != nil {
+				return err
+			}
+			err = txn.CheckDBI(dbi, lmdb.IntegerKey)
+			if err != nil {
+				return err
+			}
+			s.dbis = append(s.dbis, dbi)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start returns the start of the bucket of width w containing t in Unix
+// seconds.
+func start(t time.Time, w time.Duration) (uint64, error) {
+	sec := t.Unix()
+	if sec < 0 {
+		return 0, fmt.Errorf("lmdbbucket: time %v precedes the Unix epoch", t)
+	}
+	ws := uint64(w / time.Second)
+	return uint64(sec) / ws * ws, nil
+}
+
+// Add merges v into the bucket of the finest level containing t.
+func (s *Series) Add(txn *lmdb.Txn, t time.Time, v []byte) error {
+	b, err := start(t, s.levels[0].Width)
+	if err != nil {
+		return err
+	}
+	return s.mergeInto(txn, s.dbis[0], lmdb.EncodeUint(b), v)
+}
+
+func (s *Series) mergeInto(txn *lmdb.Txn, dbi lmdb.DBI, key, v []byte) error {
+	acc, err := txn.Get(dbi, key)
+	if lmdb.IsNotFound(err) {
+		acc, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	acc, err = s.merge(append([]byte(nil), acc...), v)
+	if err != nil {
+		return err
+	}
+	return txn.Put(dbi, key, acc, 0)
+}
+
+// Range calls fn with the start and value of each bucket of the given level
+// that starts in [from, to), in ascending order.  The value passed to fn must
+// not be retained after fn returns.
+func (s *Series) Range(txn *lmdb.Txn, level int, from, to time.Time, fn func(start time.Time, v []byte) error) error {
+	if level < 0 || level >= len(s.levels) {
+		return fmt.Errorf("lmdbbucket: no level %d", level)
+	}
+	lo, err := start(from, s.levels[level].Width)
+	if err != nil {
+		return err
+	}
+	if uint64(from.Unix()) > lo {
+		lo += uint64(s.levels[level].Width / time.Second)
+	}
+	return s.scan(txn, s.dbis[level], lo, uint64(to.Unix()), fn)
+}
+
+// scan calls fn for the buckets in dbi starting in [lo, hi) Unix seconds.
+func (s *Series) scan(txn *lmdb.Txn, dbi lmdb.DBI, lo, hi uint64, fn func(start time.Time, v []byte) error) error {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	k, v, err := cur.Get(lmdb.EncodeUint(lo), nil, lmdb.SetRange)
+	for err == nil {
+		b, derr := lmdb.DecodeUint(k)
+		if derr != nil {
+			return derr
+		}
+		if b >= hi {
+			return nil
+		}
+		err = fn(time.Unix(int64(b), 0), v)
+		if err != nil {
+			return err
+		}
+		k, v, err = cur.Get(nil, nil, lmdb.Next)
+	}
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Rollup merges the buckets of each level into the buckets of the next level
+// whose windows have ended by now, and deletes the buckets which have been
+// rolled up and are older than the retention of their level.  Rollup returns
+// the number of coarse buckets written.
+//
+// Each coarse bucket after the last one written by a previous rollup is
+// computed from scratch, so running Rollup repeatedly is harmless.  Values
+// added to a fine bucket after its coarse bucket was written are not reflected
+// in the coarse bucket.
+func (s *Series) Rollup(now time.Time) (int, error) {
+	var n int
+	err := s.env.Update(func(txn *lmdb.Txn) (err error) {
+		n = 0
+		for i := 1; i < len(s.levels); i++ {
+			m, err := s.rollup(txn, i, now)
+			if err != nil {
+				return err
+			}
+			n += m
+		}
+		for i := range s.levels {
+			err = s.expire(txn, i, now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// rollup writes the buckets of level i from the buckets of level i-1.
+func (s *Series) rollup(txn *lmdb.Txn, i int, now time.Time) (int, error) {
+	width := uint64(s.levels[i].Width / time.Second)
+	cutoff, err := start(now, s.levels[i].Width)
+	if err != nil {
+		return 0, err
+	}
+	lo, ok, err := s.last(txn, s.dbis[i])
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		lo += width
+	}
+
+	var n int
+	var cur uint64
+	var acc []byte
+	flush := func() error {
+		if acc == nil {
+			return nil
+		}
+		n++
+		return txn.Put(s.dbis[i], lmdb.EncodeUint(cur), acc, 0)
+	}
+	err = s.scan(txn, s.dbis[i-1], lo, cutoff, func(t time.Time, v []byte) error {
+		b := uint64(t.Unix()) / width * width
+		if acc != nil && b != cur {
+			err := flush()
+			if err != nil {
+				return err
+			}
+			acc = nil
+		}
+		cur = b
+		var err error
+		acc, err = s.merge(acc, v)
+		return err
+	})
+	if err == nil {
+		err = flush()
+	}
+	return n, err
+}
+
+// last returns the start of the last bucket in dbi.  If dbi is empty ok is
+// false.
+func (s *Series) last(txn *lmdb.Txn, dbi lmdb.DBI) (b uint64, ok bool, err error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return 0, false, err
+	}
+	defer cur.Close()
+	k, _, err := cur.Get(nil, nil, lmdb.Last)
+	if lmdb.IsNotFound(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	b, err = lmdb.DecodeUint(k)
+	return b, err == nil, err
+}
+
+// expire deletes the buckets of level i that ended more than its retention
+// before now, and that have been rolled up if there is a coarser level.
+func (s *Series) expire(txn *lmdb.Txn, i int, now time.Time) error {
+	l := s.levels[i]
+	if l.Retention <= 0 {
+		return nil
+	}
+	end := now.Add(-l.Retention).Add(-l.Width).Unix()
+	if end <= 0 {
+		return nil
+	}
+	hi := uint64(end) + 1
+	if i+1 < len(s.levels) {
+		rolled, err := start(now, s.levels[i+1].Width)
+		if err != nil {
+			return err
+		}
+		if rolled < hi {
+			hi = rolled
+		}
+	}
+	cur, err := txn.OpenCursor(s.dbis[i])
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for {
+		k, _, err := cur.Get(nil, nil, lmdb.First)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b, err := lmdb.DecodeUint(k)
+		if err != nil {
+			return err
+		}
+		if b >= hi {
+			return nil
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Stats is an aggregate of numeric samples.  Its encoding is 32 bytes.
+type Stats struct {
+	Count uint64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Sample returns the Stats of a single sample.
+func Sample(x float64) Stats {
+	return Stats{Count: 1, Sum: x, Min: x, Max: x}
+}
+
+// Mean returns the mean of the samples, or zero if there are none.
+func (s Stats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Bytes encodes s.
+func (s Stats) Bytes() []byte {
+	b := make([]byte, 32)
+	binary.BigEndian.PutUint64(b, s.Count)
+	binary.BigEndian.PutUint64(b[8:], math.Float64bits(s.Sum))
+	binary.BigEndian.PutUint64(b[16:], math.Float64bits(s.Min))
+	binary.BigEndian.PutUint64(b[24:], math.Float64bits(s.Max))
+	return b
+}
+
+// DecodeStats decodes Stats encoded by Stats.Bytes.
+func DecodeStats(b []byte) (Stats, error) {
+	if len(b) != 32 {
+		return Stats{}, fmt.Errorf("lmdbbucket: stats have %d bytes, expected 32", len(b))
+	}
+	return Stats{
+		Count: binary.BigEndian.Uint64(b),
+		Sum:   math.Float64frombits(binary.BigEndian.Uint64(b[8:])),
+		Min:   math.Float64frombits(binary.BigEndian.Uint64(b[16:])),
+		Max:   math.Float64frombits(binary.BigEndian.Uint64(b[24:])),
+	}, nil
+}
+
+// MergeStats is a MergeFunc for values encoded by Stats.Bytes.
+func MergeStats(acc, v []byte) ([]byte, error) {
+	s, err := DecodeStats(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(acc) == 0 {
+		return s.Bytes(), nil
+	}
+	a, err := DecodeStats(acc)
+	if err != nil {
+		return nil, err
+	}
+	a.Count += s.Count
+	a.Sum += s.Sum
+	a.Min = math.Min(a.Min, s.Min)
+	a.Max = math.Max(a.Max, s.Max)
+	return a.Bytes(), nil
+}
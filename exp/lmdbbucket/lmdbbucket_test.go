@@ -0,0 +1,107 @@
+package lmdbbucket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestSeries(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	s, err := Open(env, &Options{
+		Levels: []Level{
+			{Name: "minutes", Width: time.Minute, Retention: time.Hour},
+			{Name: "hours", Width: time.Hour},
+		},
+		Merge: MergeStats,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one sample every 30 seconds for three hours, valued by the minute.
+	t0 := time.Unix(1700000000, 0).Truncate(time.Hour)
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 360; i++ {
+			err = s.Add(txn, t0.Add(time.Duration(i)*30*time.Second), Sample(float64(i/2)).Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// in the middle of the third hour two hours are complete.
+	now := t0.Add(150 * time.Minute)
+	n, err := s.Rollup(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("unexpected number of buckets rolled up: %d", n)
+	}
+	n, err = s.Rollup(now)
+	if err != nil || n != 0 {
+		t.Errorf("unexpected second rollup: %d %v", n, err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		var hours []Stats
+		err = s.Range(txn, 1, t0, t0.Add(24*time.Hour), func(start time.Time, v []byte) error {
+			st, err := DecodeStats(v)
+			hours = append(hours, st)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(hours) != 2 {
+			t.Fatalf("unexpected hours: %v", hours)
+		}
+		if hours[1].Count != 120 || hours[1].Min != 60 || hours[1].Max != 119 || hours[1].Mean() != 89.5 {
+			t.Errorf("unexpected second hour: %+v", hours[1])
+		}
+
+		// minute buckets ending more than an hour ago were deleted once
+		// rolled up, the third hour was not rolled up yet.
+		var first time.Time
+		var minutes int
+		err = s.Range(txn, 0, time.Unix(0, 0), now, func(start time.Time, v []byte) error {
+			if minutes == 0 {
+				first = start
+			}
+			minutes++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !first.Equal(t0.Add(90*time.Minute)) || minutes != 60 {
+			t.Errorf("unexpected minutes: %d from %v", minutes, first.Sub(t0))
+		}
+
+		// a window which does not start on a bucket boundary.
+		minutes = 0
+		err = s.Range(txn, 0, t0.Add(100*time.Minute+time.Second), t0.Add(103*time.Minute), func(start time.Time, v []byte) error {
+			minutes++
+			return nil
+		})
+		if minutes != 2 {
+			t.Errorf("unexpected minutes in window: %d", minutes)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
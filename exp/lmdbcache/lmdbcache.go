@@ -0,0 +1,416 @@
+/*
+Package lmdbcache caches values of an LMDB environment in process memory, so
+that reads of very hot keys do not even take a read-only transaction.
+
+Cache.Get reads through the cache: a miss reads the value in a read-only
+transaction and caches it, as well as the absence of a key.  Entries expire
+after Options.TTL and the least recently used entries are evicted beyond
+Options.MaxEntries.
+
+Writes must be made through a Txn wrapper, created by Cache.Txn or used by
+Cache.Update, which invalidates the entries of the keys it changes once the
+transaction commits, using lmdb.Txn.OnCommit.  Changes made otherwise, for
+example by other processes, are only observed once their entries expire.
+
+Cache.Set and Cache.Delete buffer writes behind the cache instead: they are
+visible to Cache.Get at once and written to the environment in a single
+transaction by Cache.Flush, periodically when started with Cache.Start.
+Buffered writes are lost if the process exits before they are flushed.
+*/
+package lmdbcache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultMaxEntries is the number of entries cached when Options.MaxEntries
+// is not set.
+const DefaultMaxEntries = 10000
+
+// Options configure a Cache.
+type Options struct {
+	// MaxEntries is the number of entries beyond which the least recently
+	// used ones are evicted.  Absent keys count as entries.
+	MaxEntries int
+
+	// TTL is how long an entry is served after it was read from the
+	// environment.  If zero entries only leave the cache when invalidated or
+	// evicted.
+	TTL time.Duration
+}
+
+// Stats are counters of a Cache.
+type Stats struct {
+	Hits      uint64 // Reads served by the cache or the buffered writes
+	Misses    uint64 // Reads served by a transaction
+	Evictions uint64 // Entries evicted for MaxEntries
+	Entries   int    // Entries currently cached
+	Pending   int    // Buffered writes not yet flushed
+}
+
+type cacheKey struct {
+	dbi lmdb.DBI
+	key string
+}
+
+type entry struct {
+	k       cacheKey
+	val     []byte
+	missing bool
+	expires time.Time
+}
+
+// write is a buffered write.
+type write struct {
+	val []byte
+	del bool
+}
+
+// Cache caches the values of the databases of an environment.  A Cache is
+// safe for concurrent use.
+type Cache struct {
+	env *lmdb.Env
+	max int
+	ttl time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[cacheKey]*list.Element
+	gen     uint64 // incremented by every invalidation
+	stats   Stats
+
+	pending  map[cacheKey]write
+	flushing map[cacheKey]write
+	flushMu  sync.Mutex // serializes Flush
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns an empty Cache of the values of env.  A nil opt uses the zero
+// Options.
+func New(env *lmdb.Env, opt *Options) *Cache {
+	if opt == nil {
+		opt = &Options{}
+	}
+	max := opt.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxEntries
+	}
+	return &Cache{
+		env:     env,
+		max:     max,
+		ttl:     opt.TTL,
+		lru:     list.New(),
+		entries: make(map[cacheKey]*list.Element),
+		pending: make(map[cacheKey]write),
+	}
+}
+
+// Get returns the value of key in dbi, from the cache if possible.  If key
+// does not exist Get returns an error for which lmdb.IsNotFound is true.  The
+// returned slice is shared with the cache and must not be modified.
+func (c *Cache) Get(dbi lmdb.DBI, key []byte) ([]byte, error) {
+	k := cacheKey{dbi, string(key)}
+	c.mu.Lock()
+	val, found, ok := c.lookup(k)
+	gen := c.gen
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	c.mu.Unlock()
+	if ok {
+		if !found {
+			return nil, notFound()
+		}
+		return val, nil
+	}
+
+	err := c.env.View(func(txn *lmdb.Txn) (err error) {
+		val, err = txn.Get(dbi, key)
+		return err
+	})
+	if err != nil && !lmdb.IsNotFound(err) {
+		return nil, err
+	}
+	c.mu.Lock()
+	// A transaction committed since gen was read may have changed the value
+	// read, which is then not cached.
+	if c.gen == gen {
+		c.add(k, val, err != nil)
+	}
+	c.mu.Unlock()
+	return val, err
+}
+
+// notFound returns the error of a cached absent key.
+func notFound() error {
+	return &lmdb.OpError{Op: "mdb_get", Errno: lmdb.NotFound}
+}
+
+// lookup returns the value of k from the buffered writes or the cache.  The
+// value is only valid if ok is true, and only exists if found is true.
+func (c *Cache) lookup(k cacheKey) (val []byte, found, ok bool) {
+	for _, writes := range []map[cacheKey]write{c.pending, c.flushing} {
+		if w, ok := writes[k]; ok {
+			return w.val, !w.del, true
+		}
+	}
+	el := c.entries[k]
+	if el == nil {
+		return nil, false, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false, false
+	}
+	c.lru.MoveToFront(el)
+	return e.val, !e.missing, true
+}
+
+func (c *Cache) add(k cacheKey, val []byte, missing bool) {
+	e := &entry{k: k, val: val, missing: missing}
+	if c.ttl > 0 {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	if el := c.entries[k]; el != nil {
+		c.remove(el)
+	}
+	c.entries[k] = c.lru.PushFront(e)
+	for c.lru.Len() > c.max {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).k)
+}
+
+// Invalidate removes the entries of keys in dbi from the cache.
+func (c *Cache) Invalidate(dbi lmdb.DBI, keys ...[]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, key := range keys {
+		if el := c.entries[cacheKey{dbi, string(key)}]; el != nil {
+			c.remove(el)
+		}
+	}
+}
+
+// InvalidateDBI removes all entries of dbi from the cache.
+func (c *Cache) InvalidateDBI(dbi lmdb.DBI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for k, el := range c.entries {
+		if k.dbi == dbi {
+			c.remove(el)
+		}
+	}
+}
+
+// Purge removes all entries from the cache.  Buffered writes are kept.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.lru.Init()
+	c.entries = make(map[cacheKey]*list.Element)
+}
+
+// Stats returns the counters of c.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	s.Pending = len(c.pending) + len(c.flushing)
+	return s
+}
+
+// Txn wraps a write transaction and invalidates the keys changed by its Put
+// and Del methods when the transaction commits.  Changes made by calling
+// methods on the embedded lmdb.Txn, or through cursors, are not invalidated.
+type Txn struct {
+	*lmdb.Txn
+	c    *Cache
+	keys []cacheKey
+	dbis []lmdb.DBI
+}
+
+// Txn returns a wrapper for txn invalidating the entries of c for the keys it
+// changes after txn commits.
+func (c *Cache) Txn(txn *lmdb.Txn) *Txn {
+	t := &Txn{Txn: txn, c: c}
+	txn.OnCommit(t.commit)
+	return t
+}
+
+// Update runs fn in a write transaction wrapped by Txn.
+func (c *Cache) Update(fn func(txn *Txn) error) error {
+	return c.env.Update(func(txn *lmdb.Txn) error {
+		return fn(c.Txn(txn))
+	})
+}
+
+func (t *Txn) commit(uintptr) {
+	c := t.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, k := range t.keys {
+		if el := c.entries[k]; el != nil {
+			c.remove(el)
+		}
+	}
+	for _, dbi := range t.dbis {
+		for k, el := range c.entries {
+			if k.dbi == dbi {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+// Put calls lmdb.Txn.Put and records key for invalidation if it succeeds.
+func (t *Txn) Put(dbi lmdb.DBI, key, val []byte, flags uint) error {
+	err := t.Txn.Put(dbi, key, val, flags)
+	if err == nil {
+		t.keys = append(t.keys, cacheKey{dbi, string(key)})
+	}
+	return err
+}
+
+// Del calls lmdb.Txn.Del and records key for invalidation if it succeeds.
+func (t *Txn) Del(dbi lmdb.DBI, key, val []byte) error {
+	err := t.Txn.Del(dbi, key, val)
+	if err == nil {
+		t.keys = append(t.keys, cacheKey{dbi, string(key)})
+	}
+	return err
+}
+
+// Drop calls lmdb.Txn.Drop and records dbi for invalidation if it succeeds.
+func (t *Txn) Drop(dbi lmdb.DBI, del bool) error {
+	err := t.Txn.Drop(dbi, del)
+	if err == nil {
+		t.dbis = append(t.dbis, dbi)
+	}
+	return err
+}
+
+// Set buffers a write of val for key in dbi.  The value is returned by Get at
+// once and written by the next Flush.  Set copies key and val.
+func (c *Cache) Set(dbi lmdb.DBI, key, val []byte) {
+	c.buffer(cacheKey{dbi, string(key)}, write{val: append([]byte(nil), val...)})
+}
+
+// Delete buffers the deletion of key in dbi.  Get reports key as absent at
+// once and the next Flush deletes it.
+func (c *Cache) Delete(dbi lmdb.DBI, key []byte) {
+	c.buffer(cacheKey{dbi, string(key)}, write{del: true})
+}
+
+func (c *Cache) buffer(k cacheKey, w write) {
+	c.mu.Lock()
+	c.pending[k] = w
+	c.mu.Unlock()
+}
+
+// Flush writes the buffered writes in a single transaction.  If the
+// transaction fails the writes remain buffered, unless they have been
+// superseded meanwhile, and Flush returns its error.
+func (c *Cache) Flush() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	writes := c.pending
+	c.flushing = writes
+	c.pending = make(map[cacheKey]write)
+	c.mu.Unlock()
+
+	err := c.Update(func(txn *Txn) error {
+		for k, w := range writes {
+			var err error
+			if w.del {
+				err = txn.Del(k.dbi, []byte(k.key), nil)
+				if lmdb.IsNotFound(err) {
+					err = nil
+				}
+			} else {
+				err = txn.Put(k.dbi, []byte(k.key), w.val, 0)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushing = nil
+	if err != nil {
+		for k, w := range writes {
+			if _, ok := c.pending[k]; !ok {
+				c.pending[k] = w
+			}
+		}
+	}
+	return err
+}
+
+// Start calls Flush every interval in a goroutine until Stop is called.
+// Errors returned by Flush are passed to errfn if it is not nil.
+func (c *Cache) Start(interval time.Duration, errfn func(error)) error {
+	if c.stop != nil {
+		return errors.New("lmdbcache: flushing already started")
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			err := c.Flush()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(c.stop, c.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start, waits for it to exit and
+// flushes the remaining buffered writes.
+func (c *Cache) Stop() error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+		c.done = nil
+	}
+	return c.Flush()
+}
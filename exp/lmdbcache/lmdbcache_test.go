@@ -0,0 +1,154 @@
+package lmdbcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func openDBI(t *testing.T) (*lmdb.Env, lmdb.DBI) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lmdbtest.Destroy(env) })
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("a"), []byte("1"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, dbi
+}
+
+func TestCache(t *testing.T) {
+	env, dbi := openDBI(t)
+	c := New(env, &Options{MaxEntries: 2})
+
+	for i := 0; i < 2; i++ {
+		val, err := c.Get(dbi, []byte("a"))
+		if err != nil || string(val) != "1" {
+			t.Fatalf("get: %q %v", val, err)
+		}
+		_, err = c.Get(dbi, []byte("b"))
+		if !lmdb.IsNotFound(err) {
+			t.Fatalf("get missing: %v", err)
+		}
+	}
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 2 || s.Entries != 2 {
+		t.Errorf("stats %+v", s)
+	}
+
+	// Writing directly is not observed, writing through the wrapper is.
+	err := env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("a"), []byte("2"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, _ := c.Get(dbi, []byte("a"))
+	if string(val) != "1" {
+		t.Errorf("unexpected value %q", val)
+	}
+	err = c.Update(func(txn *Txn) error {
+		err := txn.Put(dbi, []byte("a"), []byte("3"), 0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("b"), []byte("4"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err = c.Get(dbi, []byte("a"))
+	if err != nil || string(val) != "3" {
+		t.Errorf("get after update: %q %v", val, err)
+	}
+	val, err = c.Get(dbi, []byte("b"))
+	if err != nil || string(val) != "4" {
+		t.Errorf("get after update: %q %v", val, err)
+	}
+
+	// Eviction.
+	_, err = c.Get(dbi, []byte("c"))
+	if !lmdb.IsNotFound(err) {
+		t.Fatalf("get missing: %v", err)
+	}
+	s = c.Stats()
+	if s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats after eviction %+v", s)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	env, dbi := openDBI(t)
+	c := New(env, &Options{TTL: time.Millisecond})
+	_, err := c.Get(dbi, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("a"), []byte("2"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	val, err := c.Get(dbi, []byte("a"))
+	if err != nil || string(val) != "2" {
+		t.Errorf("get after expiry: %q %v", val, err)
+	}
+}
+
+func TestCache_writeBehind(t *testing.T) {
+	env, dbi := openDBI(t)
+	c := New(env, nil)
+
+	c.Set(dbi, []byte("b"), []byte("2"))
+	c.Delete(dbi, []byte("a"))
+	val, err := c.Get(dbi, []byte("b"))
+	if err != nil || string(val) != "2" {
+		t.Errorf("get buffered: %q %v", val, err)
+	}
+	_, err = c.Get(dbi, []byte("a"))
+	if !lmdb.IsNotFound(err) {
+		t.Errorf("get buffered deletion: %v", err)
+	}
+	if s := c.Stats(); s.Pending != 2 {
+		t.Errorf("stats %+v", s)
+	}
+
+	err = c.Start(time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Pending != 0 {
+		t.Errorf("stats after flush %+v", s)
+	}
+	err = env.View(func(txn *lmdb.Txn) error {
+		val, err := txn.Get(dbi, []byte("b"))
+		if err != nil || string(val) != "2" {
+			t.Errorf("flushed value: %q %v", val, err)
+		}
+		_, err = txn.Get(dbi, []byte("a"))
+		if !lmdb.IsNotFound(err) {
+			t.Errorf("flushed deletion: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,367 @@
+/*
+Package lmdbchunk stores values too large to handle comfortably as single
+LMDB values by splitting them into chunks.
+
+A Store uses two databases.  The heads database holds a record for each key,
+which contains the value itself when it is at most Options.ChunkSize bytes
+long, and otherwise the size of the value and the number of its chunks.  The
+chunks database holds the chunks of large values under the key followed by "#"
+and the big-endian 32-bit index of the chunk.  Both databases must be used
+only through the Store.
+
+Values can be written and read whole with Put and Get, or streamed with
+NewWriter and NewReader so that a value never needs to be held in memory at
+once.  All operations happen in a transaction supplied by the caller, so a
+value is replaced atomically when the transaction commits and a reader sees a
+consistent value.
+*/
+package lmdbchunk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultChunkSize is the chunk size used when Options.ChunkSize is not set.
+// It is slightly less than a multiple of the common 4 KiB page size, so that a
+// chunk together with its page header fills whole overflow pages.
+const DefaultChunkSize = 256<<10 - 64
+
+const (
+	tagInline  = 0
+	tagChunked = 1
+)
+
+// ErrCorrupt is returned when the records of a value are inconsistent.
+var ErrCorrupt = errors.New("lmdbchunk: corrupt value")
+
+var errClosed = errors.New("lmdbchunk: writer is closed")
+
+// Options configure a Store.
+type Options struct {
+	// ChunkSize is the size of the chunks of large values.  Values up to
+	// ChunkSize bytes are stored in their head record.
+	ChunkSize int
+}
+
+// Store stores values in a heads and a chunks database.  A Store is safe for
+// concurrent use.
+type Store struct {
+	heads     lmdb.DBI
+	chunks    lmdb.DBI
+	chunkSize int
+}
+
+// New returns a Store using the heads and chunks databases, which must be
+// distinct.  A nil opt uses the zero Options.
+func New(heads, chunks lmdb.DBI, opt *Options) (*Store, error) {
+	if heads == chunks {
+		return nil, errors.New("lmdbchunk: heads and chunks must be distinct databases")
+	}
+	s := &Store{heads: heads, chunks: chunks, chunkSize: DefaultChunkSize}
+	if opt != nil && opt.ChunkSize > 0 {
+		s.chunkSize = opt.ChunkSize
+	}
+	return s, nil
+}
+
+// chunkKey appends the key of chunk i of key to buf.
+func chunkKey(buf, key []byte, i uint32) []byte {
+	buf = append(append(buf[:0], key...), '#')
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], i)
+	return append(buf, idx[:]...)
+}
+
+// head is the decoded head record of a value.
+type head struct {
+	inline []byte // The value, if it is stored inline
+	size   int64
+	chunks uint32
+}
+
+func (s *Store) head(txn *lmdb.Txn, key []byte) (*head, error) {
+	rec, err := txn.Get(s.heads, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rec) == 0 {
+		return nil, ErrCorrupt
+	}
+	switch rec[0] {
+	case tagInline:
+		return &head{inline: rec[1:], size: int64(len(rec) - 1)}, nil
+	case tagChunked:
+		size, n := binary.Uvarint(rec[1:])
+		if n <= 0 {
+			return nil, ErrCorrupt
+		}
+		chunks, m := binary.Uvarint(rec[1+n:])
+		if m <= 0 || chunks > 1<<32-1 {
+			return nil, ErrCorrupt
+		}
+		return &head{size: int64(size), chunks: uint32(chunks)}, nil
+	}
+	return nil, ErrCorrupt
+}
+
+// Size returns the size of the value of key.
+func (s *Store) Size(txn *lmdb.Txn, key []byte) (int64, error) {
+	h, err := s.head(txn, key)
+	if err != nil {
+		return 0, err
+	}
+	return h.size, nil
+}
+
+// Put stores val under key, replacing any previous value.
+func (s *Store) Put(txn *lmdb.Txn, key, val []byte) error {
+	w, err := s.NewWriter(txn, key)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(val)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Get returns the value of key.  The value is never memory owned by LMDB.
+func (s *Store) Get(txn *lmdb.Txn, key []byte) ([]byte, error) {
+	r, err := s.NewReader(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	val := make([]byte, r.Size())
+	_, err = io.ReadFull(r, val)
+	if err == io.ErrUnexpectedEOF {
+		err = ErrCorrupt
+	}
+	return val, err
+}
+
+// Del deletes key and its chunks.
+func (s *Store) Del(txn *lmdb.Txn, key []byte) error {
+	h, err := s.head(txn, key)
+	if err != nil {
+		return err
+	}
+	err = s.delChunks(txn, key, 0, h.chunks)
+	if err != nil {
+		return err
+	}
+	return txn.Del(s.heads, key, nil)
+}
+
+// delChunks deletes chunks [from, to) of key.
+func (s *Store) delChunks(txn *lmdb.Txn, key []byte, from, to uint32) error {
+	var ck []byte
+	for i := from; i < to; i++ {
+		ck = chunkKey(ck, key, i)
+		err := txn.Del(s.chunks, ck, nil)
+		if err != nil && !lmdb.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writer streams a value into a Store.  The value replaces the previous value
+// of its key when Close is called.  If Write or Close fails the transaction
+// should be aborted.
+type Writer struct {
+	s      *Store
+	txn    *lmdb.Txn
+	key    []byte
+	buf    []byte
+	ck     []byte
+	size   int64
+	chunks uint32
+	closed bool
+	err    error
+}
+
+// NewWriter returns a Writer storing a value under key in txn, which must be a
+// write transaction.  The Writer must be closed to store the value.
+func (s *Store) NewWriter(txn *lmdb.Txn, key []byte) (*Writer, error) {
+	if len(key) == 0 {
+		return nil, errors.New("lmdbchunk: empty key")
+	}
+	return &Writer{
+		s:   s,
+		txn: txn,
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
+// Write appends p to the value.  Full chunks are written to the chunks
+// database as they fill.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errClosed
+	}
+	n := 0
+	for len(p) > 0 && w.err == nil {
+		if len(w.buf) == w.s.chunkSize {
+			w.err = w.flush()
+			if w.err != nil {
+				break
+			}
+		}
+		k := w.s.chunkSize - len(w.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+		n += k
+		w.size += int64(k)
+	}
+	return n, w.err
+}
+
+// flush writes the buffered chunk.
+func (w *Writer) flush() error {
+	if w.chunks == 1<<32-1 {
+		return fmt.Errorf("lmdbchunk: value of %q has too many chunks", w.key)
+	}
+	w.ck = chunkKey(w.ck, w.key, w.chunks)
+	err := w.txn.Put(w.s.chunks, w.ck, w.buf, 0)
+	if err != nil {
+		return err
+	}
+	w.chunks++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close stores the head record of the value and deletes the chunks of the
+// previous value that were not overwritten.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+
+	var old uint32
+	h, err := w.s.head(w.txn, w.key)
+	if err == nil {
+		old = h.chunks
+	} else if !lmdb.IsNotFound(err) && err != ErrCorrupt {
+		return err
+	}
+
+	var rec []byte
+	if w.chunks == 0 {
+		rec = append([]byte{tagInline}, w.buf...)
+	} else {
+		if len(w.buf) > 0 {
+			err = w.flush()
+			if err != nil {
+				return err
+			}
+		}
+		rec = make([]byte, 1, 1+2*binary.MaxVarintLen64)
+		rec[0] = tagChunked
+		rec = appendUvarint(rec, uint64(w.size))
+		rec = appendUvarint(rec, uint64(w.chunks))
+	}
+	if old > w.chunks {
+		err = w.s.delChunks(w.txn, w.key, w.chunks, old)
+		if err != nil {
+			return err
+		}
+	}
+	return w.txn.Put(w.s.heads, w.key, rec, 0)
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// Reader streams a value from a Store.  A Reader reads the chunks of the value
+// as they are needed and must only be used while its transaction is active.
+type Reader struct {
+	s     *Store
+	txn   *lmdb.Txn
+	key   []byte
+	h     *head
+	ck    []byte
+	chunk []byte // Unread part of the current chunk
+	buf   []byte
+	next  uint32
+	read  int64
+}
+
+// NewReader returns a Reader of the value of key in txn.  If key does not exist
+// a NotFound error is returned.
+func (s *Store) NewReader(txn *lmdb.Txn, key []byte) (*Reader, error) {
+	h, err := s.head(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{s: s, txn: txn, key: append([]byte(nil), key...), h: h}
+	if h.chunks == 0 {
+		r.chunk = append([]byte(nil), h.inline...)
+	}
+	return r, nil
+}
+
+// Size returns the size of the value.
+func (r *Reader) Size() int64 {
+	return r.h.size
+}
+
+// Read reads the next bytes of the value.
+func (r *Reader) Read(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if len(r.chunk) == 0 {
+			if r.next >= r.h.chunks {
+				break
+			}
+			err := r.load()
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		k := copy(p, r.chunk)
+		r.chunk = r.chunk[k:]
+		p = p[k:]
+		n += k
+		r.read += int64(k)
+	}
+	if n == 0 && len(p) > 0 {
+		if r.read != r.h.size {
+			return 0, ErrCorrupt
+		}
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// load reads the next chunk into the buffer of r.
+func (r *Reader) load() error {
+	r.ck = chunkKey(r.ck, r.key, r.next)
+	var err error
+	r.buf, err = r.txn.GetTo(r.s.chunks, r.ck, r.buf)
+	if lmdb.IsNotFound(err) || (err == nil && len(r.buf) == 0) {
+		return ErrCorrupt
+	}
+	if err != nil {
+		return err
+	}
+	r.chunk = r.buf
+	r.next++
+	return nil
+}
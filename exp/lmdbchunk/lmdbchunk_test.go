@@ -0,0 +1,140 @@
+package lmdbchunk
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestStore(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	heads, err := lmdbtest.OpenDBI(env, "heads", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := lmdbtest.OpenDBI(env, "chunks", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(heads, chunks, &Options{ChunkSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countChunks := func() (n int) {
+		err := env.View(func(txn *lmdb.Txn) (err error) {
+			stat, err := txn.Stat(chunks)
+			n = int(stat.Entries)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	key := []byte("blob")
+	for _, size := range []int{0, 10, 1000, 1001, 5500, 2000, 10} {
+		val := make([]byte, size)
+		rnd.Read(val)
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			return s.Put(txn, key, val)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if size > 1000 {
+			want = (size + 999) / 1000
+		}
+		if n := countChunks(); n != want {
+			t.Errorf("size %d: %d chunks (!= %d)", size, n, want)
+		}
+
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			got, err := s.Get(txn, key)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(got, val) {
+				t.Errorf("size %d: value differs", size)
+			}
+			r, err := s.NewReader(txn, key)
+			if err != nil {
+				return err
+			}
+			if r.Size() != int64(size) {
+				t.Errorf("size %d: reader size %d", size, r.Size())
+			}
+			got, err = ioutil.ReadAll(io.LimitReader(r, int64(size)+1))
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(got, val) {
+				t.Errorf("size %d: streamed value differs", size)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An aborted write leaves the previous value in place.
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		w, err := s.NewWriter(txn, key)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(make([]byte, 3500))
+		if err != nil {
+			return err
+		}
+		return io.ErrUnexpectedEOF
+	})
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		size, err := s.Size(txn, key)
+		if size != 10 {
+			t.Errorf("size %d after abort (!= 10)", size)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		err = s.Put(txn, key, make([]byte, 4000))
+		if err != nil {
+			return err
+		}
+		return s.Del(txn, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countChunks(); n != 0 {
+		t.Errorf("%d chunks after Del", n)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		_, err = s.Get(txn, key)
+		return err
+	})
+	if !lmdb.IsNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Package lmdbcodec transforms values as they are written to and read from an
+LMDB database.  Codecs compress or encrypt values transparently so that
+application code reads and writes logical values while the memory map only
+contains transformed bytes.
+
+A DB pairs a database handle with a Codec.  Values written with DB.Put are
+encoded and values read with DB.Get, or with a Cursor opened by DB.OpenCursor,
+are decoded.  Values accessed through the lmdb package directly are not
+transformed.
+
+Because duplicates in a database with the lmdb.DupSort flag are ordered by
+their encoded bytes, only deterministic codecs should be used with such
+databases and the order of duplicates will generally not match the order of
+the logical values.
+*/
+package lmdbcodec
+
+import "github.com/PowerDNS/lmdb-go/lmdb"
+
+// Codec encodes values before they are stored and decodes them after they
+// are read.  The key of the item is passed to both methods so that codecs may
+// bind a value to its key.  Decode must not retain or modify val, which may
+// reference memory owned by LMDB.
+type Codec interface {
+	Encode(key, val []byte) ([]byte, error)
+	Decode(key, val []byte) ([]byte, error)
+}
+
+// Chain returns a Codec that encodes values with each of codecs in order and
+// decodes them in reverse order.  For example, a value should be compressed
+// before it is encrypted:
+//
+//	lmdbcodec.Chain(compression, encryption)
+func Chain(codecs ...Codec) Codec {
+	return chain(codecs)
+}
+
+type chain []Codec
+
+func (c chain) Encode(key, val []byte) ([]byte, error) {
+	var err error
+	for _, codec := range c {
+		val, err = codec.Encode(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func (c chain) Decode(key, val []byte) ([]byte, error) {
+	var err error
+	for i := len(c) - 1; i >= 0; i-- {
+		val, err = c[i].Decode(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// DB is a database whose values are transformed by a Codec.
+type DB struct {
+	DBI   lmdb.DBI
+	Codec Codec
+}
+
+// Get retrieves and decodes the value of key.
+func (db *DB) Get(txn *lmdb.Txn, key []byte) ([]byte, error) {
+	val, err := txn.Get(db.DBI, key)
+	if err != nil {
+		return nil, err
+	}
+	return db.Codec.Decode(key, val)
+}
+
+// Put encodes val and stores it under key.
+func (db *DB) Put(txn *lmdb.Txn, key, val []byte, flags uint) error {
+	enc, err := db.Codec.Encode(key, val)
+	if err != nil {
+		return err
+	}
+	return txn.Put(db.DBI, key, enc, flags)
+}
+
+// Del deletes key.  If the database has the lmdb.DupSort flag and val is not
+// empty only the duplicate val is deleted, which requires a deterministic
+// codec.
+func (db *DB) Del(txn *lmdb.Txn, key, val []byte) error {
+	if len(val) > 0 {
+		var err error
+		val, err = db.Codec.Encode(key, val)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Del(db.DBI, key, val)
+}
+
+// Cursor is a cursor which decodes the values it reads.
+type Cursor struct {
+	*lmdb.Cursor
+	codec Codec
+}
+
+// OpenCursor opens a Cursor on db in txn.
+func (db *DB) OpenCursor(txn *lmdb.Txn) (*Cursor, error) {
+	cur, err := txn.OpenCursor(db.DBI)
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{Cursor: cur, codec: db.Codec}, nil
+}
+
+// Get calls lmdb.Cursor.Get and decodes the value retrieved.  A non-empty
+// setval is encoded before it is passed to lmdb.Cursor.Get, which requires a
+// deterministic codec.  Get must not be used with the lmdb.GetMultiple and
+// lmdb.NextMultiple ops.
+func (c *Cursor) Get(setkey, setval []byte, op uint) (key, val []byte, err error) {
+	if len(setval) > 0 {
+		setval, err = c.codec.Encode(setkey, setval)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	key, val, err = c.Cursor.Get(setkey, setval, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err = c.codec.Decode(key, val)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, val, nil
+}
+
+// Put encodes val and stores it under key.
+func (c *Cursor) Put(key, val []byte, flags uint) error {
+	enc, err := c.codec.Encode(key, val)
+	if err != nil {
+		return err
+	}
+	return c.Cursor.Put(key, enc, flags)
+}
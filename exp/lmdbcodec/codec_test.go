@@ -0,0 +1,119 @@
+package lmdbcodec
+
+import (
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestCompress(t *testing.T) {
+	codec := Compress(Flate{Level: flate.BestSpeed}, 64)
+	for _, val := range []string{
+		"",
+		"short",
+		strings.Repeat("compressible ", 100),
+	} {
+		enc, err := codec.Encode(nil, []byte(val))
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := enc[0] == HeaderFlate
+		if compressed != (len(val) >= 64) {
+			t.Errorf("%q: unexpected header %#x", val, enc[0])
+		}
+		if compressed && len(enc) >= len(val) {
+			t.Errorf("%q: value did not shrink: %d", val, len(enc))
+		}
+		dec, err := codec.Decode(nil, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(dec) != val {
+			t.Errorf("%q: unexpected decoded value %q", val, dec)
+		}
+	}
+
+	_, err := codec.Decode(nil, []byte{0x7f, 'x'})
+	if err == nil {
+		t.Errorf("expected error for unknown header")
+	}
+}
+
+type xorCodec byte
+
+func (x xorCodec) Encode(key, val []byte) ([]byte, error) {
+	p := make([]byte, len(val))
+	for i := range val {
+		p[i] = val[i] ^ byte(x)
+	}
+	return p, nil
+}
+
+func (x xorCodec) Decode(key, val []byte) ([]byte, error) {
+	return x.Encode(key, val)
+}
+
+func TestDB(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{DBI: dbi, Codec: Chain(Compress(Flate{}, 16), xorCodec(0x5a))}
+
+	big := strings.Repeat("payload ", 50)
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		err = db.Put(txn, []byte("a"), []byte("small"), 0)
+		if err != nil {
+			return err
+		}
+		return db.Put(txn, []byte("b"), []byte(big), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		raw, err := txn.Get(dbi, []byte("b"))
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(raw, []byte("payload")) || len(raw) >= len(big) {
+			t.Errorf("value stored without transformation")
+		}
+
+		v, err := db.Get(txn, []byte("b"))
+		if err != nil {
+			return err
+		}
+		if string(v) != big {
+			t.Errorf("unexpected value: %q", v)
+		}
+
+		cur, err := db.OpenCursor(txn)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		k, v, err := cur.Get(nil, nil, lmdb.First)
+		if err != nil {
+			return err
+		}
+		if string(k) != "a" || string(v) != "small" {
+			t.Errorf("unexpected item: %q %q", k, v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
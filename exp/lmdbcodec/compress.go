@@ -0,0 +1,132 @@
+package lmdbcodec
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Header bytes of values encoded by a compression Codec.
+const (
+	// HeaderRaw marks a value stored without compression.
+	HeaderRaw byte = 0
+
+	// HeaderFlate marks a value compressed with the Flate compressor.
+	HeaderFlate byte = 1
+)
+
+// Compressor is a compression algorithm used by Compress.  Implementations
+// wrapping other algorithms, such as zstd or snappy, may use any header byte
+// other than HeaderRaw and HeaderFlate.
+type Compressor interface {
+	// Header returns the byte identifying values compressed by the
+	// Compressor.
+	Header() byte
+
+	// Compress appends the compressed form of src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress appends the decompressed form of src to dst.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// Compress returns a Codec that compresses values of at least threshold bytes
+// with c.  Each stored value is prefixed by a header byte, which is HeaderRaw
+// for values stored uncompressed because they are below threshold or did not
+// shrink.  Values with any other header than HeaderRaw and c.Header() cannot
+// be decoded.
+func Compress(c Compressor, threshold int) Codec {
+	return &compression{c: c, threshold: threshold}
+}
+
+type compression struct {
+	c         Compressor
+	threshold int
+}
+
+func (z *compression) Encode(key, val []byte) ([]byte, error) {
+	if len(val) >= z.threshold {
+		dst := make([]byte, 1, len(val)/2+1)
+		dst[0] = z.c.Header()
+		dst, err := z.c.Compress(dst, val)
+		if err != nil {
+			return nil, err
+		}
+		if len(dst) < len(val)+1 {
+			return dst, nil
+		}
+	}
+	dst := make([]byte, len(val)+1)
+	dst[0] = HeaderRaw
+	copy(dst[1:], val)
+	return dst, nil
+}
+
+func (z *compression) Decode(key, val []byte) ([]byte, error) {
+	if len(val) == 0 {
+		return nil, errors.New("lmdbcodec: missing compression header")
+	}
+	switch val[0] {
+	case HeaderRaw:
+		return append([]byte(nil), val[1:]...), nil
+	case z.c.Header():
+		return z.c.Decompress(nil, val[1:])
+	}
+	return nil, fmt.Errorf("lmdbcodec: unknown compression header %#x", val[0])
+}
+
+// Flate is a Compressor using the DEFLATE algorithm from the standard library.
+type Flate struct {
+	// Level is a compression level defined by the compress/flate package.
+	// The zero value selects flate.DefaultCompression because storing
+	// values with flate.NoCompression has no benefit.
+	Level int
+}
+
+var flateWriters sync.Map // Level -> *sync.Pool
+
+// Header implements Compressor.
+func (f Flate) Header() byte { return HeaderFlate }
+
+// Compress implements Compressor.
+func (f Flate) Compress(dst, src []byte) ([]byte, error) {
+	if f.Level == flate.NoCompression {
+		f.Level = flate.DefaultCompression
+	}
+	pool, _ := flateWriters.LoadOrStore(f.Level, new(sync.Pool))
+	buf := bytes.NewBuffer(dst)
+	w, _ := pool.(*sync.Pool).Get().(*flate.Writer)
+	if w == nil {
+		var err error
+		w, err = flate.NewWriter(buf, f.Level)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		w.Reset(buf)
+	}
+	_, err := w.Write(src)
+	if err == nil {
+		err = w.Close()
+	}
+	pool.(*sync.Pool).Put(w)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Compressor.
+func (f Flate) Decompress(dst, src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	buf := bytes.NewBuffer(dst)
+	_, err := io.Copy(buf, r)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package lmdbcodec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// HeaderAEAD is the first byte of values encoded by an Encrypt Codec.
+const HeaderAEAD byte = 0xae
+
+var errDecrypt = errors.New("lmdbcodec: value cannot be decrypted")
+
+// AEADFunc constructs a cipher.AEAD from a 32 byte key, for example
+// NewAESGCM or chacha20poly1305.NewX from golang.org/x/crypto.
+type AEADFunc func(key []byte) (cipher.AEAD, error)
+
+// NewAESGCM is an AEADFunc returning AES-256 in Galois Counter Mode.
+func NewAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt returns a Codec that encrypts values with the AEAD constructed by
+// fn.  A separate encryption key is derived for each item from master and
+// the item's key using HMAC-SHA256, and the item's key is authenticated as
+// additional data, so a value copied to a different key fails to decrypt.
+//
+// Each encoded value consists of HeaderAEAD, a random nonce and the sealed
+// value.  Because nonces are random the same value encrypts differently
+// every time it is stored, so the Codec must not be used with databases
+// that have the lmdb.DupSort flag.  When combined with compression, compress
+// first:
+//
+//	lmdbcodec.Chain(lmdbcodec.Compress(lmdbcodec.Flate{}, 256), encryption)
+//
+// Master should be at least 32 bytes of uniformly random data.
+func Encrypt(fn AEADFunc, master []byte) Codec {
+	return &aeadCodec{fn: fn, master: append([]byte(nil), master...)}
+}
+
+type aeadCodec struct {
+	fn     AEADFunc
+	master []byte
+}
+
+func (c *aeadCodec) aead(key []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, c.master)
+	mac.Write(key)
+	return c.fn(mac.Sum(nil))
+}
+
+func (c *aeadCodec) Encode(key, val []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	n := aead.NonceSize()
+	dst := make([]byte, 1+n, 1+n+len(val)+aead.Overhead())
+	dst[0] = HeaderAEAD
+	_, err = io.ReadFull(rand.Reader, dst[1:])
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(dst, dst[1:], val, key), nil
+}
+
+func (c *aeadCodec) Decode(key, val []byte) ([]byte, error) {
+	if len(val) == 0 || val[0] != HeaderAEAD {
+		return nil, fmt.Errorf("lmdbcodec: unknown encryption header")
+	}
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	n := aead.NonceSize()
+	if len(val) < 1+n+aead.Overhead() {
+		return nil, errDecrypt
+	}
+	p, err := aead.Open(nil, val[1:1+n], val[1+n:], key)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return p, nil
+}
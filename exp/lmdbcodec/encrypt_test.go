@@ -0,0 +1,49 @@
+package lmdbcodec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	master := bytes.Repeat([]byte{7}, 32)
+	codec := Encrypt(NewAESGCM, master)
+
+	val := []byte("secret credentials")
+	enc, err := codec.Encode([]byte("k1"), val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(enc, val) {
+		t.Errorf("value stored in plaintext")
+	}
+	enc2, err := codec.Encode([]byte("k1"), val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(enc, enc2) {
+		t.Errorf("expected distinct ciphertexts")
+	}
+
+	dec, err := codec.Decode([]byte("k1"), enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, val) {
+		t.Errorf("unexpected decoded value: %q", dec)
+	}
+
+	_, err = codec.Decode([]byte("k2"), enc)
+	if err == nil {
+		t.Errorf("expected error decrypting under a different key")
+	}
+	_, err = Encrypt(NewAESGCM, bytes.Repeat([]byte{8}, 32)).Decode([]byte("k1"), enc)
+	if err == nil {
+		t.Errorf("expected error decrypting with a different master key")
+	}
+	enc[len(enc)-1] ^= 1
+	_, err = codec.Decode([]byte("k1"), enc)
+	if err == nil {
+		t.Errorf("expected error decrypting a modified value")
+	}
+}
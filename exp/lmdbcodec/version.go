@@ -0,0 +1,162 @@
+package lmdbcodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+var errVersionHeader = errors.New("lmdbcodec: missing version header")
+
+// MigrateFunc upgrades a logical value of key by one version.
+type MigrateFunc func(key, val []byte) ([]byte, error)
+
+// Versions is a Codec which stores values in an envelope recording the
+// version of their encoding, so that applications can change the format of
+// their values without rewriting the database at once.
+//
+// Values are written with the current version.  Values of older versions are
+// upgraded when they are read by applying the migrations from their version
+// up to the current one, while the stored bytes are left unchanged until the
+// value is written again or rewritten by Sweep.
+//
+// The envelope is a uvarint version followed by the value encoded by an inner
+// Codec, such as a compression codec, so that the version can be inspected
+// without decoding the value.
+type Versions struct {
+	current    uint64
+	inner      Codec
+	migrations map[uint64]MigrateFunc
+}
+
+// Versioned returns a Versions codec writing values of version current.
+// migrations[v] upgrades a value of version v to version v+1 and must be
+// given for every version older than current which may be stored.  If inner
+// is not nil it transforms values inside the envelope; it applies to values
+// of all versions and cannot itself be changed by a migration.
+func Versioned(current uint64, inner Codec, migrations map[uint64]MigrateFunc) *Versions {
+	return &Versions{current: current, inner: inner, migrations: migrations}
+}
+
+// Encode implements Codec.
+func (v *Versions) Encode(key, val []byte) ([]byte, error) {
+	if v.inner != nil {
+		var err error
+		val, err = v.inner.Encode(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(val))
+	n := binary.PutUvarint(dst, v.current)
+	return append(dst[:n], val...), nil
+}
+
+// Decode implements Codec.
+func (v *Versions) Decode(key, val []byte) ([]byte, error) {
+	version, n := binary.Uvarint(val)
+	if n <= 0 {
+		return nil, errVersionHeader
+	}
+	if version > v.current {
+		return nil, fmt.Errorf("lmdbcodec: value version %d is newer than %d", version, v.current)
+	}
+	val = val[n:]
+	var err error
+	if v.inner != nil {
+		val, err = v.inner.Decode(key, val)
+	} else {
+		val = append([]byte(nil), val...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for ; version < v.current; version++ {
+		m := v.migrations[version]
+		if m == nil {
+			return nil, fmt.Errorf("lmdbcodec: no migration from value version %d", version)
+		}
+		val, err = m(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// Version returns the version of the stored value val.
+func (v *Versions) Version(val []byte) (uint64, error) {
+	version, n := binary.Uvarint(val)
+	if n <= 0 {
+		return 0, errVersionHeader
+	}
+	return version, nil
+}
+
+// Sweep rewrites the values in dbi, encoded by v, which are older than the
+// current version.  Sweep writes at most batch values per transaction, so
+// that it can run in the background without holding the write lock for long,
+// and returns the number of values rewritten.  Sweep is not meant for
+// databases with the lmdb.DupSort flag.
+func (v *Versions) Sweep(env *lmdb.Env, dbi lmdb.DBI, batch int) (int, error) {
+	if batch <= 0 {
+		batch = 1000
+	}
+	var start []byte
+	total := 0
+	for done := false; !done; {
+		err := env.Update(func(txn *lmdb.Txn) (err error) {
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+
+			var k, val []byte
+			if start == nil {
+				k, val, err = cur.Get(nil, nil, lmdb.First)
+			} else {
+				k, val, err = cur.Get(start, nil, lmdb.SetRange)
+			}
+			n := 0
+			for ; err == nil; k, val, err = cur.Get(nil, nil, lmdb.Next) {
+				if n >= batch {
+					start = append(start[:0], k...)
+					return nil
+				}
+				version, err := v.Version(val)
+				if err != nil {
+					return err
+				}
+				if version >= v.current {
+					continue
+				}
+				dec, err := v.Decode(k, val)
+				if err != nil {
+					return err
+				}
+				enc, err := v.Encode(k, dec)
+				if err != nil {
+					return err
+				}
+				err = cur.Put(k, enc, lmdb.Current)
+				if err != nil {
+					return err
+				}
+				n++
+				total++
+			}
+			if !lmdb.IsNotFound(err) {
+				return err
+			}
+			done = true
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,112 @@
+package lmdbcodec
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestVersions(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// version 0 stores names, version 1 upper case names and version 2
+	// prefixes them with a greeting.
+	v0 := Versioned(0, Compress(Flate{}, 0), nil)
+	v2 := Versioned(2, Compress(Flate{}, 0), map[uint64]MigrateFunc{
+		0: func(key, val []byte) ([]byte, error) { return bytes.ToUpper(val), nil },
+		1: func(key, val []byte) ([]byte, error) { return append([]byte("HELLO "), val...), nil },
+	})
+
+	old := &DB{DBI: dbi, Codec: v0}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 5; i++ {
+			err = old.Put(txn, []byte{byte(i)}, []byte(fmt.Sprint("name", i)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &DB{DBI: dbi, Codec: v2}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		val, err := db.Get(txn, []byte{1})
+		if err != nil {
+			return err
+		}
+		if string(val) != "HELLO NAME1" {
+			t.Errorf("unexpected value: %q", val)
+		}
+		_, err = old.Get(txn, []byte{1})
+		if err != nil {
+			return err
+		}
+		// rewriting a value upgrades it.
+		err = db.Put(txn, []byte{1}, val, 0)
+		if err != nil {
+			return err
+		}
+		_, err = old.Get(txn, []byte{1})
+		if err == nil {
+			t.Errorf("expected error decoding newer version")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := v2.Sweep(env, dbi, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("unexpected number of values rewritten: %d", n)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 5; i++ {
+			raw, err := txn.Get(dbi, []byte{byte(i)})
+			if err != nil {
+				return err
+			}
+			version, err := v2.Version(raw)
+			if err != nil {
+				return err
+			}
+			if version != 2 {
+				t.Errorf("%d: unexpected version %d", i, version)
+			}
+			val, err := db.Get(txn, []byte{byte(i)})
+			if err != nil {
+				return err
+			}
+			if exp := fmt.Sprint("HELLO NAME", i); string(val) != exp {
+				t.Errorf("unexpected value: %q (!= %q)", val, exp)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Versioned(1, nil, nil).Decode(nil, []byte{0})
+	if err == nil {
+		t.Errorf("expected error for missing migration")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Package lmdbcontainer provides helpers for running LMDB environments in
+containers.
+
+# Read-only data in an image layer
+
+An environment shipped in a container image is read-only, but LMDB creates
+its lock file next to the data file, which fails on a read-only layer.
+OpenSplit opens such a data file through a symbolic link placed in a writable
+directory, where LMDB creates the lock file instead.
+
+# Overlay file systems
+
+Files of an image that are written by the container are copied up into the
+container's writable layer by overlay file systems, which is slow for large
+data files, loses the changes when the container is recreated and has caused
+inconsistent memory maps with some kernels.  CheckPath detects environments
+stored on an overlay file system and advises moving them to a volume.
+
+# Termination
+
+Containers are stopped with SIGTERM followed by SIGKILL after a grace period.
+An environment using lmdb.NoSync or lmdb.MapAsync loses the transactions that
+were not yet flushed if the process is killed.  SyncOnTerm syncs the
+environment when SIGTERM or SIGINT is received.
+*/
+package lmdbcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrOverlay is returned by CheckPath for a path on an overlay file system.
+var ErrOverlay = errors.New("lmdbcontainer: environment is on an overlay file system, copy it to a volume")
+
+// OpenSplit opens the data file at data, which may be on a read-only file
+// system, with its lock file in the writable directory lockdir.  The
+// environment is opened with the lmdb.Readonly flag, in addition to flags,
+// through a symbolic link named data.mdb in lockdir, which is created if
+// missing.  The lock file is lockdir/lock.mdb.  Each container should use a
+// separate lockdir unless the containers share an IPC namespace and may
+// thus share the reader table.
+func OpenSplit(env *lmdb.Env, data, lockdir string, flags uint) error {
+	if flags&lmdb.NoSubdir != 0 {
+		return fmt.Errorf("lmdbcontainer: OpenSplit does not support NoSubdir")
+	}
+	data, err := filepath.Abs(data)
+	if err != nil {
+		return err
+	}
+	link := filepath.Join(lockdir, "data.mdb")
+	target, err := os.Readlink(link)
+	switch {
+	case os.IsNotExist(err):
+		err = os.Symlink(data, link)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("lmdbcontainer: %s is not a symbolic link: %v", link, err)
+	case target != data:
+		return fmt.Errorf("lmdbcontainer: %s links to %s instead of %s", link, target, data)
+	}
+	return env.Open(lockdir, flags|lmdb.Readonly, 0644)
+}
+
+// CheckPath returns ErrOverlay if the environment at path, a directory or a
+// data file, is stored on an overlay file system.  CheckPath returns nil if
+// the file system cannot be determined.
+func CheckPath(path string) error {
+	if isOverlay(path) {
+		return ErrOverlay
+	}
+	return nil
+}
+
+// SyncOnTerm syncs env when the process receives SIGTERM or SIGINT, calls
+// fn, if it is not nil, with the result and stops handling the signals.  If
+// fn is nil the signal is raised again afterwards, which terminates the
+// process as if SyncOnTerm had not been called.  Otherwise fn is responsible
+// for shutting down the application, and must close env.
+//
+// The returned function stops handling the signals without syncing.
+func SyncOnTerm(env *lmdb.Env, fn func(sig os.Signal, err error)) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		var sig os.Signal
+		select {
+		case <-done:
+			return
+		case sig = <-sigs:
+		}
+		signal.Stop(sigs)
+		err := env.Sync(true)
+		if fn != nil {
+			fn(sig, err)
+			return
+		}
+		p, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			p.Signal(sig)
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+	}
+}
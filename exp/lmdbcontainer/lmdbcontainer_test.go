@@ -0,0 +1,117 @@
+package lmdbcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestOpenSplit(t *testing.T) {
+	src, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(src)
+	dbi, err := lmdbtest.OpenRoot(src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.Update(func(txn *lmdb.Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := src.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lockdir, err := ioutil.TempDir("", "lmdbcontainer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(lockdir)
+
+	for i := 0; i < 2; i++ {
+		env, err := lmdb.NewEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = OpenSplit(env, filepath.Join(path, "data.mdb"), lockdir, 0)
+		if err != nil {
+			env.Close()
+			t.Fatal(err)
+		}
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Get(dbi, []byte("k"))
+			return err
+		})
+		env.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = os.Stat(filepath.Join(lockdir, "lock.mdb"))
+	if err != nil {
+		t.Errorf("lock file not in lockdir: %v", err)
+	}
+
+	// a link to another file is not replaced.
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	err = OpenSplit(env, filepath.Join(lockdir, "other.mdb"), lockdir, 0)
+	if err == nil {
+		t.Errorf("expected error for mismatched link")
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	err := CheckPath(os.TempDir())
+	if err != nil && err != ErrOverlay {
+		t.Error(err)
+	}
+}
+
+func TestSyncOnTerm(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{Flags: lmdb.NoSync})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	synced := make(chan error, 1)
+	stop := SyncOnTerm(env, func(sig os.Signal, err error) {
+		synced <- err
+	})
+	defer stop()
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Signal(os.Interrupt)
+	if err != nil {
+		t.Skip(err)
+	}
+	select {
+	case err := <-synced:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not handled")
+	}
+}
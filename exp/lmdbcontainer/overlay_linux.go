@@ -0,0 +1,16 @@
+package lmdbcontainer
+
+import "syscall"
+
+// overlayMagic is OVERLAYFS_SUPER_MAGIC.
+const overlayMagic = 0x794c7630
+
+// isOverlay returns true if path is on an overlay file system.
+func isOverlay(path string) bool {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return false
+	}
+	return int64(st.Type) == overlayMagic
+}
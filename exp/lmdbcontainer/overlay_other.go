@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package lmdbcontainer
+
+// isOverlay returns false because overlay file systems are specific to Linux.
+func isOverlay(path string) bool {
+	return false
+}
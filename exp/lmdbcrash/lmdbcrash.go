@@ -0,0 +1,388 @@
+/*
+Package lmdbcrash tests that environments survive crashes in a consistent
+state.
+
+LMDB never overwrites the pages of the last committed snapshot, so after a
+crash an environment opens at the last transaction whose meta page reached the
+disk, provided the file system honored the synchronous writes LMDB asked for.
+The durability settings NoSync, NoMetaSync and MapAsync relax this in ways
+that are easy to misjudge.  Package lmdbcrash simulates two kinds of crashes
+so that applications can check their settings:
+
+Kill runs a writer in a subprocess, started with package lmdbharness, and
+kills it with SIGKILL while it commits.  The page cache survives, so the
+environment must reopen at the last acknowledged transaction or later,
+whatever the durability settings.
+
+Tear simulates a power loss after a transaction by combining two raw copies
+of the data file, taken with Snapshot before and after the transaction, one
+sector at a time.  Sectors written by the transaction are lost at random, as
+if the disk had not persisted them.  With synchronous commits LMDB writes the
+meta page only after the data pages are durable, which Tear models by keeping
+the old meta pages unless TearOptions.DropSync is set.
+
+Recover opens an environment in a subprocess, because a corrupt environment
+can crash the process reading it, checks it and reports the sequence number
+of the recovered snapshot.  The test binary must call lmdbharness.Main at the
+start of TestMain.
+*/
+package lmdbcrash
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbharness"
+	"github.com/PowerDNS/lmdb-go/exp/lmdbpages"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// SeqKey is the key in the root database under which Writer stores the
+// sequence number of each transaction as a decimal string.
+const SeqKey = "lmdbcrash.seq"
+
+// Names of the helpers registered by the package.
+const (
+	// Writer opens the environment with the flags given as its first
+	// argument and commits transactions until it is killed.  Transaction n
+	// rewrites a number of items and stores n under SeqKey, and n is sent
+	// once the transaction has committed.
+	Writer = "lmdbcrash.writer"
+
+	// Checker checks the pages of the data file with lmdbpages, opens the
+	// environment with lmdb.WithSelfCheck, reads every item of every
+	// database and sends "seq n", where n is the value of SeqKey, or -1 if
+	// it does not exist.
+	Checker = "lmdbcrash.checker"
+)
+
+// Timeout bounds the time Kill and Recover wait for a helper to respond.
+var Timeout = time.Minute
+
+func init() {
+	lmdbharness.Register(Writer, writer)
+	lmdbharness.Register(Checker, checker)
+}
+
+func writer(h *lmdbharness.Helper) error {
+	var flags uint64
+	if len(h.Args) > 0 {
+		var err error
+		flags, err = strconv.ParseUint(h.Args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+	env, err := h.Open(lmdb.WithMapSize(64<<20), lmdb.WithFlags(uint(flags)))
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	val := make([]byte, 512)
+	for n := int64(0); ; n++ {
+		err = env.Update(func(txn *lmdb.Txn) error {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 64; i++ {
+				key := fmt.Sprintf("item%04d", (n*64+int64(i))%1024)
+				copy(val, key)
+				err = txn.Put(dbi, []byte(key), val, 0)
+				if err != nil {
+					return err
+				}
+			}
+			return txn.Put(dbi, []byte(SeqKey), []byte(strconv.FormatInt(n, 10)), 0)
+		})
+		if err != nil {
+			return err
+		}
+		err = h.Send(strconv.FormatInt(n, 10))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func checker(h *lmdbharness.Helper) error {
+	err := checkPages(h.Path)
+	if err != nil {
+		return err
+	}
+	env, err := h.Open(lmdb.WithSelfCheck(), lmdb.WithMaxDBs(128))
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	seq := int64(-1)
+	err = env.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+		names, err := txn.ListDBIs()
+		if err != nil {
+			return err
+		}
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		dbis := []lmdb.DBI{root}
+		for _, name := range names {
+			dbi, err := txn.OpenDBI(name, 0)
+			if err != nil {
+				return err
+			}
+			dbis = append(dbis, dbi)
+		}
+		for _, dbi := range dbis {
+			err = walk(txn, dbi)
+			if err != nil {
+				return err
+			}
+		}
+		v, err := txn.Get(root, []byte(SeqKey))
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		seq, err = strconv.ParseInt(string(v), 10, 64)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return h.Send("seq " + strconv.FormatInt(seq, 10))
+}
+
+// checkPages checks the data file of the environment at path before LMDB
+// reads it.
+func checkPages(path string) error {
+	f, err := lmdbpages.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	res, err := f.Check()
+	if err != nil {
+		return err
+	}
+	if !res.OK() {
+		return fmt.Errorf("lmdbcrash: %s: %s", f.Name(), strings.Join(res.Problems, "; "))
+	}
+	return nil
+}
+
+// walk reads every item of dbi.
+func walk(txn *lmdb.Txn, dbi lmdb.DBI) error {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for {
+		_, _, err = cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// KillOptions configure Kill.
+type KillOptions struct {
+	// Flags are the environment flags the writer opens the environment
+	// with, such as lmdb.NoSync.
+	Flags uint
+
+	// Commits is the number of transactions acknowledged by the writer
+	// before it is killed.  Zero kills the writer after a random number of
+	// transactions between 1 and 100.
+	Commits int
+
+	// Helper is the name of the writer helper.  If empty Writer is used.
+	// A custom writer receives Flags as its first argument and must send
+	// the sequence number of each transaction after it has committed.
+	Helper string
+}
+
+// Kill runs a writer on the environment at path and kills it with SIGKILL
+// while it is writing.  Kill returns the sequence number of the last
+// transaction the writer acknowledged.
+func Kill(path string, opt *KillOptions) (int64, error) {
+	var o KillOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.Helper == "" {
+		o.Helper = Writer
+	}
+	if o.Commits <= 0 {
+		o.Commits = 1 + rand.Intn(100)
+	}
+	p, err := lmdbharness.Start(o.Helper, path, strconv.FormatUint(uint64(o.Flags), 10))
+	if err != nil {
+		return 0, err
+	}
+	acked := int64(-1)
+	for i := 0; i < o.Commits; i++ {
+		line, err := p.Recv(Timeout)
+		if err != nil {
+			p.Kill()
+			if err == io.EOF {
+				err = p.Wait()
+			}
+			return 0, err
+		}
+		acked, err = strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			p.Kill()
+			return 0, fmt.Errorf("lmdbcrash: %s sent %q", o.Helper, line)
+		}
+	}
+	return acked, p.Kill()
+}
+
+// Recover opens the environment at path in a subprocess running the helper
+// name, which defaults to Checker when empty, and returns the sequence number
+// it reports.  An error is returned if the environment cannot be opened or
+// read, including when it crashes the helper.
+func Recover(path, name string) (int64, error) {
+	if name == "" {
+		name = Checker
+	}
+	p, err := lmdbharness.Start(name, path)
+	if err != nil {
+		return 0, err
+	}
+	line, err := p.Recv(Timeout)
+	if err != nil {
+		p.Kill()
+		if err == io.EOF {
+			err = p.Wait()
+		}
+		return 0, err
+	}
+	err = p.Wait()
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(line, "seq ") {
+		return 0, fmt.Errorf("lmdbcrash: %s sent %q", name, line)
+	}
+	return strconv.ParseInt(strings.TrimPrefix(line, "seq "), 10, 64)
+}
+
+// dataFileName is the name of the data file in an environment directory.
+const dataFileName = "data.mdb"
+
+// Snapshot copies the data file of the environment directory path to the
+// directory dst, which is created if needed, as a disk image would hold it.
+// Unlike lmdb.Env.Copy the copy is taken byte for byte, including pages of
+// transactions in progress.  The lock file is not copied.
+func Snapshot(path, dst string) error {
+	b, err := os.ReadFile(filepath.Join(path, dataFileName))
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dst, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, dataFileName), b, 0644)
+}
+
+// DefaultSectorSize is the unit in which Tear loses writes when
+// TearOptions.SectorSize is zero.
+const DefaultSectorSize = 512
+
+// TearOptions configure Tear.
+type TearOptions struct {
+	// SectorSize is the unit in which writes are lost.
+	SectorSize int
+
+	// DropSync simulates a disk or file system that ignores fsync, or the
+	// NoSync and NoMetaSync flags, by losing writes to the meta pages like
+	// any other write.  Otherwise the meta pages of the snapshot taken
+	// before the transaction are kept, as they are when LMDB syncs the data
+	// pages before writing the meta page.
+	DropSync bool
+
+	// PageSize is the page size of the environment, used to locate the
+	// meta pages.  If zero the OS page size is assumed.
+	PageSize int
+
+	// Rand is the source of randomness.  If nil the global source of
+	// package math/rand is used.
+	Rand *rand.Rand
+}
+
+// Tear writes to the directory dst a data file combining the snapshots in the
+// directories before and after, taken with Snapshot around a single
+// transaction.  Each sector that differs between the snapshots is taken from
+// after with probability one half, and from before otherwise.
+func Tear(dst, before, after string, opt *TearOptions) error {
+	var o TearOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.SectorSize <= 0 {
+		o.SectorSize = DefaultSectorSize
+	}
+	if o.PageSize <= 0 {
+		o.PageSize = os.Getpagesize()
+	}
+	intn := rand.Intn
+	if o.Rand != nil {
+		intn = o.Rand.Intn
+	}
+
+	b, err := os.ReadFile(filepath.Join(before, dataFileName))
+	if err != nil {
+		return err
+	}
+	a, err := os.ReadFile(filepath.Join(after, dataFileName))
+	if err != nil {
+		return err
+	}
+	torn := make([]byte, len(a))
+	copy(torn, b)
+	metas := 2 * o.PageSize
+	for off := 0; off < len(a); off += o.SectorSize {
+		end := off + o.SectorSize
+		if end > len(a) {
+			end = len(a)
+		}
+		if off < len(b) && bytes.Equal(a[off:end], b[off:minInt(end, len(b))]) {
+			continue
+		}
+		if off < metas && !o.DropSync {
+			continue
+		}
+		if intn(2) == 0 {
+			copy(torn[off:end], a[off:end])
+		}
+	}
+	err = os.MkdirAll(dst, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, dataFileName), torn, 0644)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,123 @@
+package lmdbcrash
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbharness"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestMain(m *testing.M) {
+	lmdbharness.Main()
+	os.Exit(m.Run())
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "lmdbcrash-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestKill(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, flags := range []uint{0, lmdb.NoSync} {
+		acked, err := Kill(dir, &KillOptions{Flags: flags, Commits: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := Recover(dir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq < acked {
+			t.Errorf("flags %#x: recovered %d (< %d acknowledged)", flags, seq, acked)
+		}
+	}
+}
+
+func TestTear(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "env")
+	err := os.Mkdir(path, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := lmdb.Open(path, lmdb.WithMapSize(16<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	commit := func(n int) {
+		err := env.Update(func(txn *lmdb.Txn) error {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			val := make([]byte, 300)
+			for i := 0; i < 200; i++ {
+				val[0] = byte(n)
+				err = txn.Put(dbi, []byte(strconv.Itoa(i)), val, 0)
+				if err != nil {
+					return err
+				}
+			}
+			return txn.Put(dbi, []byte(SeqKey), []byte(strconv.Itoa(n)), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for n := 0; n < 5; n++ {
+		commit(n)
+	}
+	before := filepath.Join(dir, "before")
+	err = Snapshot(path, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(5)
+	after := filepath.Join(dir, "after")
+	err = Snapshot(path, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5; i++ {
+		torn := filepath.Join(dir, "torn"+strconv.Itoa(i))
+		err = Tear(torn, before, after, &TearOptions{Rand: rnd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := Recover(torn, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != 4 {
+			t.Errorf("recovered %d (!= 4)", seq)
+		}
+	}
+
+	// Without sync ordering the outcome depends on which sectors survive,
+	// but recovering must not crash the test process.
+	torn := filepath.Join(dir, "dropsync")
+	err = Tear(torn, before, after, &TearOptions{Rand: rnd, DropSync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := Recover(torn, "")
+	if err == nil && seq != 4 && seq != 5 {
+		t.Errorf("recovered %d", seq)
+	}
+}
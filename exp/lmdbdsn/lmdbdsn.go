@@ -0,0 +1,199 @@
+/*
+Package lmdbdsn opens LMDB environments described by a single configuration
+string, which is convenient for services that take datastore configuration
+from flags or environment variables.
+
+A DSN is a URL with the scheme "lmdb", the path of the environment and query
+parameters configuring it.
+
+	lmdb:///var/db/foo?mapsize=64GiB&maxdbs=16&nosync=true
+
+The following parameters are recognized.
+
+	mapsize     size of the memory map, with an optional KiB, MiB, GiB or TiB suffix
+	maxdbs      maximum number of named databases
+	maxreaders  maximum number of reader slots
+	mode        permissions of created files, in octal (default 0644)
+
+Boolean parameters set the environment flag of the same name, see the flags
+defined for lmdb.Env.Open.
+
+	fixedmap, nosubdir, readonly, writemap, nometasync, nosync, mapasync,
+	notls, nolock, nordahead, nomeminit
+
+Unless the nosubdir or readonly flag is set the directory of the environment is
+created if it does not exist.
+*/
+package lmdbdsn
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Config is a parsed DSN.
+type Config struct {
+	Path       string
+	Flags      uint
+	Mode       os.FileMode
+	MapSize    int64
+	MaxDBs     int
+	MaxReaders int
+}
+
+var flags = map[string]uint{
+	"fixedmap":   lmdb.FixedMap,
+	"nosubdir":   lmdb.NoSubdir,
+	"readonly":   lmdb.Readonly,
+	"writemap":   lmdb.WriteMap,
+	"nometasync": lmdb.NoMetaSync,
+	"nosync":     lmdb.NoSync,
+	"mapasync":   lmdb.MapAsync,
+	"notls":      lmdb.NoTLS,
+	"nolock":     lmdb.NoLock,
+	"nordahead":  lmdb.NoReadahead,
+	"nomeminit":  lmdb.NoMemInit,
+}
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+}
+
+// Parse parses dsn.
+func Parse(dsn string) (*Config, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "lmdb" {
+		return nil, fmt.Errorf("lmdbdsn: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host != "" {
+		return nil, fmt.Errorf("lmdbdsn: unexpected host %q", u.Host)
+	}
+	if u.Path == "" {
+		return nil, fmt.Errorf("lmdbdsn: missing path")
+	}
+	c := &Config{Path: u.Path, Mode: 0644}
+	for name, vals := range u.Query() {
+		if len(vals) != 1 {
+			return nil, fmt.Errorf("lmdbdsn: parameter %s given %d times", name, len(vals))
+		}
+		err = c.set(name, vals[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Config) set(name, val string) error {
+	var err error
+	switch name {
+	case "mapsize":
+		c.MapSize, err = parseSize(val)
+	case "maxdbs":
+		c.MaxDBs, err = strconv.Atoi(val)
+	case "maxreaders":
+		c.MaxReaders, err = strconv.Atoi(val)
+	case "mode":
+		var mode uint64
+		mode, err = strconv.ParseUint(val, 8, 32)
+		c.Mode = os.FileMode(mode)
+	default:
+		flag, ok := flags[name]
+		if !ok {
+			return fmt.Errorf("lmdbdsn: unknown parameter %s", name)
+		}
+		var b bool
+		b, err = strconv.ParseBool(val)
+		if b {
+			c.Flags |= flag
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("lmdbdsn: invalid %s: %q", name, val)
+	}
+	return nil
+}
+
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			mult = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	if n > (1<<63-1)/mult {
+		return 0, fmt.Errorf("size overflows")
+	}
+	return n * mult, nil
+}
+
+// Open creates and opens an environment configured by dsn.
+func Open(dsn string) (*lmdb.Env, error) {
+	c, err := Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Open()
+}
+
+// Open creates and opens an environment configured by c.
+func (c *Config) Open() (*lmdb.Env, error) {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = c.open(env)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	return env, nil
+}
+
+func (c *Config) open(env *lmdb.Env) error {
+	if c.MapSize > 0 {
+		err := env.SetMapSize(c.MapSize)
+		if err != nil {
+			return err
+		}
+	}
+	if c.MaxDBs > 0 {
+		err := env.SetMaxDBs(c.MaxDBs)
+		if err != nil {
+			return err
+		}
+	}
+	if c.MaxReaders > 0 {
+		err := env.SetMaxReaders(c.MaxReaders)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Flags&(lmdb.NoSubdir|lmdb.Readonly) == 0 {
+		err := os.MkdirAll(c.Path, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	return env.Open(c.Path, c.Flags, c.Mode)
+}
@@ -0,0 +1,80 @@
+package lmdbdsn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse("lmdb:///var/db/foo?mapsize=64GiB&maxdbs=16&maxreaders=500&nosync=true&nosubdir=1&writemap=false&mode=0600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Config{
+		Path:       "/var/db/foo",
+		Flags:      lmdb.NoSync | lmdb.NoSubdir,
+		Mode:       0600,
+		MapSize:    64 << 30,
+		MaxDBs:     16,
+		MaxReaders: 500,
+	}
+	if *c != expect {
+		t.Errorf("unexpected config: %+v (!= %+v)", *c, expect)
+	}
+
+	for _, dsn := range []string{
+		"file:///var/db/foo",
+		"lmdb://host/var/db/foo",
+		"lmdb://",
+		"lmdb:///var/db/foo?mapsize=64GB",
+		"lmdb:///var/db/foo?mapsize=-1",
+		"lmdb:///var/db/foo?nosync=maybe",
+		"lmdb:///var/db/foo?unknown=1",
+		"lmdb:///var/db/foo?maxdbs=1&maxdbs=2",
+	} {
+		_, err := Parse(dsn)
+		if err == nil {
+			t.Errorf("%s: expected error", dsn)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lmdbdsn-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := Open("lmdb://" + filepath.Join(dir, "db") + "?mapsize=2MiB&maxdbs=1&nometasync=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 2<<20 {
+		t.Errorf("unexpected map size: %d", info.MapSize)
+	}
+	flags, err := env.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&lmdb.NoMetaSync == 0 {
+		t.Errorf("unexpected flags: %#x", flags)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		_, err = txn.OpenDBI("one", lmdb.Create)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+package lmdbexport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/PowerDNS/lmdb-go/lmdbscan"
+)
+
+// ArchiveVersion is the version of the archive format written by
+// WriteArchive.
+const ArchiveVersion = 1
+
+// Snapshot calls fn for each of the named databases in a single read-only
+// transaction, so that together the calls see a consistent state of the
+// environment, and returns the ID of the snapshot.  The empty name refers to
+// the root database.  A database missing from the environment fails the
+// snapshot with a NotFound error.
+func Snapshot(env *lmdb.Env, names []string, fn func(txn *lmdb.Txn, name string, dbi lmdb.DBI) error) (uint64, error) {
+	var id uint64
+	err := env.View(func(txn *lmdb.Txn) error {
+		id = uint64(txn.ID())
+		for _, name := range names {
+			dbi, err := openDBI(txn, name)
+			if err != nil {
+				return fmt.Errorf("lmdbexport: database %q: %w", name, err)
+			}
+			err = fn(txn, name, dbi)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+func openDBI(txn *lmdb.Txn, name string) (lmdb.DBI, error) {
+	if name == "" {
+		return txn.OpenRoot(0)
+	}
+	return txn.OpenDBI(name, 0)
+}
+
+// ArchiveHeader describes an archive written by WriteArchive.
+type ArchiveHeader struct {
+	Version  int       // ArchiveVersion
+	TxnID    uint64    // ID of the snapshot the archive was written from
+	Time     time.Time // Time the archive was written
+	Encoding Encoding  // Encoding of keys and values
+	DBs      []string  // Names of the archived databases, in order
+}
+
+// archiveLine is a line of an archive.  The first line is the header, a line
+// with DB set starts the items of a database and the other lines are items.
+type archiveLine struct {
+	Archive  string     `json:"archive,omitempty"`
+	Version  int        `json:"version,omitempty"`
+	TxnID    uint64     `json:"txn_id,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	Encoding string     `json:"encoding,omitempty"`
+	DBs      []string   `json:"dbs,omitempty"`
+
+	DB    *string `json:"db,omitempty"`
+	Flags uint    `json:"flags,omitempty"`
+
+	Key *string `json:"key,omitempty"`
+	Val *string `json:"val,omitempty"`
+}
+
+const archiveMagic = "lmdbexport"
+
+var encodingNames = []string{Base64: "base64", Hex: "hex"}
+
+var errArchive = errors.New("lmdbexport: malformed archive")
+
+// WriteArchive writes a consistent export of the named databases to w as
+// JSON lines, see Snapshot, and returns its header.  The archive starts with
+// a header line identifying the snapshot, followed for each database by a line
+// with its name and flags and a line for each of its items.  Options.Encoding
+// selects the encoding of keys and values.  A nil opt uses the zero Options.
+func WriteArchive(env *lmdb.Env, w io.Writer, names []string, opt *Options) (*ArchiveHeader, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	hdr := &ArchiveHeader{
+		Version:  ArchiveVersion,
+		Time:     time.Now().UTC(),
+		Encoding: opt.Encoding,
+		DBs:      names,
+	}
+	writeHeader := func(id uint64) error {
+		hdr.TxnID = id
+		return enc.Encode(archiveLine{
+			Archive:  archiveMagic,
+			Version:  hdr.Version,
+			TxnID:    hdr.TxnID,
+			Time:     &hdr.Time,
+			Encoding: encodingNames[hdr.Encoding],
+			DBs:      names,
+		})
+	}
+	id, err := Snapshot(env, names, func(txn *lmdb.Txn, name string, dbi lmdb.DBI) error {
+		if hdr.TxnID == 0 {
+			err := writeHeader(uint64(txn.ID()))
+			if err != nil {
+				return err
+			}
+		}
+		flags, err := txn.Flags(dbi)
+		if err != nil {
+			return err
+		}
+		db := name
+		err = enc.Encode(archiveLine{DB: &db, Flags: flags})
+		if err != nil {
+			return err
+		}
+		txn.RawRead = true
+		s := lmdbscan.New(txn, dbi)
+		defer s.Close()
+		for s.Scan() {
+			k, v := opt.Encoding.encode(s.Key()), opt.Encoding.encode(s.Val())
+			err = enc.Encode(archiveLine{Key: &k, Val: &v})
+			if err != nil {
+				return err
+			}
+		}
+		return s.Err()
+	})
+	if err == nil && hdr.TxnID == 0 {
+		err = writeHeader(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hdr, bw.Flush()
+}
+
+// RestoreArchive reads an archive written by WriteArchive from r into env,
+// creating its databases with their flags, and returns its header.  The items
+// of each database are written in transactions of Options.BatchSize items
+// with Options.PutFlags; other options are ignored.  If RestoreArchive fails
+// the items of the committed batches remain in the databases.
+func RestoreArchive(env *lmdb.Env, r io.Reader, opt *Options) (*ArchiveHeader, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	batch := opt.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var first archiveLine
+	err := dec.Decode(&first)
+	if err != nil || first.Archive != archiveMagic {
+		return nil, errArchive
+	}
+	if first.Version != ArchiveVersion {
+		return nil, fmt.Errorf("lmdbexport: unsupported archive version %d", first.Version)
+	}
+	hdr := &ArchiveHeader{
+		Version: first.Version,
+		TxnID:   first.TxnID,
+		DBs:     first.DBs,
+	}
+	if first.Time != nil {
+		hdr.Time = *first.Time
+	}
+	hdr.Encoding, err = ParseEncoding(first.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbi lmdb.DBI
+	var open bool
+	var line archiveLine
+	for done := false; !done; {
+		err = env.Update(func(txn *lmdb.Txn) error {
+			for n := 0; n < batch; n++ {
+				line = archiveLine{}
+				err := dec.Decode(&line)
+				if err == io.EOF {
+					done = true
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				switch {
+				case line.DB != nil:
+					if *line.DB == "" {
+						dbi, err = txn.OpenRoot(line.Flags)
+					} else {
+						dbi, err = txn.OpenDBI(*line.DB, line.Flags|lmdb.Create)
+					}
+					if err != nil {
+						return err
+					}
+					open = true
+				case line.Key != nil && line.Val != nil && open:
+					k, err := hdr.Encoding.decode(*line.Key)
+					if err != nil {
+						return err
+					}
+					v, err := hdr.Encoding.decode(*line.Val)
+					if err != nil {
+						return err
+					}
+					err = txn.Put(dbi, k, v, opt.PutFlags)
+					if err != nil {
+						return err
+					}
+				default:
+					return errArchive
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return hdr, err
+		}
+	}
+	return hdr, nil
+}
@@ -0,0 +1,140 @@
+package lmdbexport
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestSnapshot(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.Update(func(txn *lmdb.Txn) error {
+		for _, name := range []string{"a", "b"} {
+			dbi, err := txn.OpenDBI(name, lmdb.Create)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(dbi, []byte("k"), []byte(name), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	id, err := Snapshot(env, []string{"b", "a"}, func(txn *lmdb.Txn, name string, dbi lmdb.DBI) error {
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		seen = append(seen, name+"="+string(v))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []string{"b=b", "a=a"}) {
+		t.Errorf("seen %q", seen)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != uint64(info.LastTxnID) {
+		t.Errorf("snapshot %d (last txn %d)", id, info.LastTxnID)
+	}
+
+	_, err = Snapshot(env, []string{"missing"}, func(*lmdb.Txn, string, lmdb.DBI) error { return nil })
+	if !lmdb.IsNotFound(err) {
+		t.Errorf("missing database: %v", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.Update(func(txn *lmdb.Txn) error {
+		users, err := txn.OpenDBI("users", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		tags, err := txn.OpenDBI("tags", lmdb.Create|lmdb.DupSort)
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"alice", "bob", ""} {
+			err = txn.Put(users, []byte("u:"+k), []byte(k), 0)
+			if err != nil {
+				return err
+			}
+		}
+		for _, v := range []string{"x", "y", "z"} {
+			err = txn.Put(tags, []byte("t"), []byte(v), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	hdr, err := WriteArchive(env, &buf, []string{"users", "tags"}, &Options{Encoding: Hex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.TxnID == 0 || hdr.Encoding != Hex {
+		t.Errorf("header %+v", hdr)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 9 {
+		t.Errorf("%d lines:\n%s", n, buf.String())
+	}
+
+	dst, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(dst)
+	rhdr, err := RestoreArchive(dst, bytes.NewReader(buf.Bytes()), &Options{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rhdr.TxnID != hdr.TxnID || !rhdr.Time.Equal(hdr.Time) || !reflect.DeepEqual(rhdr.DBs, hdr.DBs) {
+		t.Errorf("restored header %+v, written %+v", rhdr, hdr)
+	}
+
+	// Compare the archives of both environments, ignoring the header.
+	var rbuf bytes.Buffer
+	_, err = WriteArchive(dst, &rbuf, []string{"users", "tags"}, &Options{Encoding: Hex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := func(s string) string { return s[strings.Index(s, "\n"):] }
+	if body(rbuf.String()) != body(buf.String()) {
+		t.Errorf("restored archive:\n%s\nwritten:\n%s", rbuf.String(), buf.String())
+	}
+
+	_, err = RestoreArchive(dst, strings.NewReader(`{"key":"00","val":"00"}`+"\n"), nil)
+	if err != errArchive {
+		t.Errorf("malformed archive: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Package lmdbexport streams the items of a database to and from JSON lines and
+CSV, so that data can be inspected, compared or loaded into other tools
+without custom code.
+
+Keys and values are arbitrary bytes, so both formats encode them as base64 or
+hex strings.  In the JSON lines format each line is an object
+
+	{"key":"azE=","val":"djE="}
+
+and in the CSV format each record has the fields key and val, following a
+header record naming them.  The JSON lines format with base64 encoding is the
+one written by lmdbtool dump -json.
+
+For audit dumps and point-in-time logical backups Snapshot and WriteArchive
+export several databases from a single read transaction, so that the export
+reflects one state of the environment, identified by the ID of the
+transaction.  RestoreArchive loads an archive back into an environment.
+*/
+package lmdbexport
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/PowerDNS/lmdb-go/lmdbscan"
+)
+
+// DefaultBatchSize is the number of items Import writes per transaction when
+// Options.BatchSize is not set.
+const DefaultBatchSize = 10000
+
+// Format is a file format for items.
+type Format int
+
+// The supported formats.
+const (
+	JSONL Format = iota // JSON lines
+	CSV
+)
+
+// Encoding is the text encoding of keys and values.
+type Encoding int
+
+// The supported encodings.
+const (
+	Base64 Encoding = iota // Standard base64 with padding
+	Hex
+)
+
+// ParseFormat returns the format named s, "jsonl" or "csv".
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "jsonl":
+		return JSONL, nil
+	case "csv":
+		return CSV, nil
+	}
+	return 0, fmt.Errorf("lmdbexport: unknown format %q", s)
+}
+
+// ParseEncoding returns the encoding named s, "base64" or "hex".
+func ParseEncoding(s string) (Encoding, error) {
+	switch s {
+	case "base64":
+		return Base64, nil
+	case "hex":
+		return Hex, nil
+	}
+	return 0, fmt.Errorf("lmdbexport: unknown encoding %q", s)
+}
+
+func (e Encoding) encode(b []byte) string {
+	if e == Hex {
+		return hex.EncodeToString(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func (e Encoding) decode(s string) ([]byte, error) {
+	if e == Hex {
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// Options configure Export and Import.  The zero Options use JSON lines with
+// base64 encoding.
+type Options struct {
+	Format   Format
+	Encoding Encoding
+
+	// BatchSize is the number of items Import writes per transaction.
+	BatchSize int
+
+	// PutFlags are passed to lmdb.Txn.Put by Import, for example
+	// lmdb.NoOverwrite.
+	PutFlags uint
+}
+
+// record is an item in the JSON lines format.
+type record struct {
+	Key string `json:"key"`
+	Val string `json:"val"`
+}
+
+// Export writes the items of dbi in txn to w and returns the number of items
+// written.  A nil opt uses the zero Options.
+func Export(txn *lmdb.Txn, dbi lmdb.DBI, w io.Writer, opt *Options) (uint64, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+
+	bw := bufio.NewWriter(w)
+	var enc *json.Encoder
+	var cw *csv.Writer
+	switch opt.Format {
+	case JSONL:
+		enc = json.NewEncoder(bw)
+	case CSV:
+		cw = csv.NewWriter(bw)
+		err := cw.Write([]string{"key", "val"})
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errUnknownFormat
+	}
+
+	var n uint64
+	s := lmdbscan.New(txn, dbi)
+	defer s.Close()
+	for s.Scan() {
+		k, v := opt.Encoding.encode(s.Key()), opt.Encoding.encode(s.Val())
+		var err error
+		if enc != nil {
+			err = enc.Encode(record{k, v})
+		} else {
+			err = cw.Write([]string{k, v})
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	if s.Err() != nil {
+		return n, s.Err()
+	}
+	if cw != nil {
+		cw.Flush()
+		if cw.Error() != nil {
+			return n, cw.Error()
+		}
+	}
+	return n, bw.Flush()
+}
+
+var errUnknownFormat = errors.New("lmdbexport: unknown format")
+
+// reader decodes items in a format.
+type reader struct {
+	enc  Encoding
+	dec  *json.Decoder
+	cr   *csv.Reader
+	line int
+}
+
+func newReader(r io.Reader, opt *Options) (*reader, error) {
+	rd := &reader{enc: opt.Encoding}
+	switch opt.Format {
+	case JSONL:
+		rd.dec = json.NewDecoder(bufio.NewReader(r))
+	case CSV:
+		rd.cr = csv.NewReader(bufio.NewReader(r))
+		rd.cr.FieldsPerRecord = 2
+		rd.cr.ReuseRecord = true
+	default:
+		return nil, errUnknownFormat
+	}
+	return rd, nil
+}
+
+// next returns the next item, or io.EOF after the last one.
+func (r *reader) next() (key, val []byte, err error) {
+	r.line++
+	var k, v string
+	if r.dec != nil {
+		var rec record
+		err = r.dec.Decode(&rec)
+		k, v = rec.Key, rec.Val
+	} else {
+		var fields []string
+		fields, err = r.cr.Read()
+		if err == nil && r.line == 1 && fields[0] == "key" && fields[1] == "val" {
+			// The header, which is not valid in either encoding.
+			r.line++
+			fields, err = r.cr.Read()
+		}
+		if err == nil {
+			k, v = fields[0], fields[1]
+		}
+	}
+	if err == io.EOF {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("lmdbexport: record %d: %v", r.line, err)
+	}
+	key, err = r.enc.decode(k)
+	if err == nil {
+		val, err = r.enc.decode(v)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("lmdbexport: record %d: %v", r.line, err)
+	}
+	return key, val, nil
+}
+
+// Import reads items from r and writes them to dbi in env, in transactions of
+// Options.BatchSize items, and returns the number of items written.  Items do
+// not need to be sorted.  If Import fails the items of the committed batches
+// remain in the database.  A nil opt uses the zero Options.
+func Import(env *lmdb.Env, dbi lmdb.DBI, r io.Reader, opt *Options) (uint64, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	batch := opt.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	rd, err := newReader(r, opt)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for done := false; !done; {
+		var count int
+		var rerr error
+		err = env.Update(func(txn *lmdb.Txn) error {
+			for count = 0; count < batch; count++ {
+				k, v, err := rd.next()
+				if err == io.EOF {
+					done = true
+					return nil
+				}
+				if err != nil {
+					// The items read so far are committed.
+					rerr = err
+					done = true
+					return nil
+				}
+				err = txn.Put(dbi, k, v, opt.PutFlags)
+				if err != nil {
+					return fmt.Errorf("lmdbexport: record %d: %w", rd.line, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		n += uint64(count)
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package lmdbexport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestExportImport(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	var src lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		src, err = txn.OpenDBI("src", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 25; i++ {
+			err = txn.Put(src, []byte(fmt.Sprintf("k%02d", i)), []byte{byte(i), 0, ','}, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, opt := range []*Options{
+		nil,
+		{Format: JSONL, Encoding: Hex},
+		{Format: CSV, Encoding: Base64, BatchSize: 10},
+		{Format: CSV, Encoding: Hex, BatchSize: 7},
+	} {
+		var buf bytes.Buffer
+		err = env.View(func(txn *lmdb.Txn) error {
+			n, err := Export(txn, src, &buf, opt)
+			if n != 25 {
+				t.Errorf("%d: exported %d items", i, n)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && !strings.HasPrefix(buf.String(), `{"key":"azAw","val":"AAAs"}`+"\n") {
+			t.Errorf("unexpected output: %.40q", buf.String())
+		}
+
+		var dst lmdb.DBI
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			dst, err = txn.OpenDBI(fmt.Sprint("dst", i), lmdb.Create)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := Import(env, dst, &buf, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 25 {
+			t.Errorf("%d: imported %d items", i, n)
+		}
+		err = env.View(func(txn *lmdb.Txn) error {
+			for j := 0; j < 25; j++ {
+				v, err := txn.Get(dst, []byte(fmt.Sprintf("k%02d", j)))
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(v, []byte{byte(j), 0, ','}) {
+					t.Errorf("%d: item %d: %q", i, j, v)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImport_malformed(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := "key,val\na2V5,dmFs\n!!,dmFs\n"
+	n, err := Import(env, dbi, strings.NewReader(in), &Options{Format: CSV})
+	if err == nil || !strings.Contains(err.Error(), "record 3") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("imported %d items", n)
+	}
+}
@@ -0,0 +1,16 @@
+package lmdbfault
+
+import "syscall"
+
+// rusageThread is RUSAGE_THREAD, which the syscall package does not define.
+const rusageThread = 1
+
+// majorFaults returns the number of major page faults of the calling thread.
+func majorFaults() (uint64, bool) {
+	var ru syscall.Rusage
+	err := syscall.Getrusage(rusageThread, &ru)
+	if err != nil {
+		return 0, false
+	}
+	return uint64(ru.Majflt), true
+}
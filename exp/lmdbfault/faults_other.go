@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package lmdbfault
+
+// majorFaults reports that per thread fault counts are not available.
+func majorFaults() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,139 @@
+/*
+Package lmdbfault samples the latency of reads to tell whether an application
+is slowed down by page faults.
+
+LMDB reads data directly from its memory map, so a read whose pages are not
+in the page cache blocks on disk I/O.  When the working set no longer fits in
+RAM the ratio of such cold reads rises even though nothing is wrong with the
+database itself.  A Sampler times a fraction of the reads made through it and
+classifies each sampled read as cold or warm.  Where the operating system
+reports major page faults per thread (Linux) a read that caused one is cold;
+elsewhere a read slower than Options.Threshold is assumed to be cold.
+*/
+package lmdbfault
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Defaults for Options.
+const (
+	DefaultRate      = 100
+	DefaultThreshold = 100 * time.Microsecond
+)
+
+// Options configure a Sampler.
+type Options struct {
+	// Rate is the inverse of the fraction of reads sampled, one read in Rate
+	// is timed.
+	Rate int
+
+	// Threshold is the latency above which a sampled read is classified as
+	// cold when page fault counts are not available.
+	Threshold time.Duration
+}
+
+// Stats are the counters of a Sampler.
+type Stats struct {
+	Reads    uint64        // Reads made through the Sampler
+	Sampled  uint64        // Reads which were timed
+	Cold     uint64        // Sampled reads classified as cold
+	Faults   uint64        // Major page faults during sampled reads, if known
+	ColdTime time.Duration // Total duration of cold reads
+	WarmTime time.Duration // Total duration of warm reads
+}
+
+// ColdRatio returns the fraction of sampled reads which were cold.
+func (s *Stats) ColdRatio() float64 {
+	if s.Sampled == 0 {
+		return 0
+	}
+	return float64(s.Cold) / float64(s.Sampled)
+}
+
+// Sampler times a fraction of reads.  A Sampler is safe for concurrent use.
+type Sampler struct {
+	rate      uint64
+	threshold time.Duration
+
+	reads    uint64
+	sampled  uint64
+	cold     uint64
+	faults   uint64
+	coldTime int64
+	warmTime int64
+}
+
+// New returns a Sampler configured by opt.
+func New(opt *Options) *Sampler {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.Rate <= 0 {
+		o.Rate = DefaultRate
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultThreshold
+	}
+	return &Sampler{rate: uint64(o.Rate), threshold: o.Threshold}
+}
+
+// Stats returns the counters of s.
+func (s *Sampler) Stats() Stats {
+	return Stats{
+		Reads:    atomic.LoadUint64(&s.reads),
+		Sampled:  atomic.LoadUint64(&s.sampled),
+		Cold:     atomic.LoadUint64(&s.cold),
+		Faults:   atomic.LoadUint64(&s.faults),
+		ColdTime: time.Duration(atomic.LoadInt64(&s.coldTime)),
+		WarmTime: time.Duration(atomic.LoadInt64(&s.warmTime)),
+	}
+}
+
+// Get calls txn.Get, timing the read if it is sampled.
+func (s *Sampler) Get(txn *lmdb.Txn, dbi lmdb.DBI, key []byte) (val []byte, err error) {
+	s.run(func() { val, err = txn.Get(dbi, key) })
+	return val, err
+}
+
+// CursorGet calls cur.Get, timing the read if it is sampled.
+func (s *Sampler) CursorGet(cur *lmdb.Cursor, setkey, setval []byte, op uint) (key, val []byte, err error) {
+	s.run(func() { key, val, err = cur.Get(setkey, setval, op) })
+	return key, val, err
+}
+
+func (s *Sampler) run(fn func()) {
+	if atomic.AddUint64(&s.reads, 1)%s.rate != 0 {
+		fn()
+		return
+	}
+
+	// fault counts are per thread so the goroutine must not migrate.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	f0, known := majorFaults()
+	start := time.Now()
+	fn()
+	d := time.Since(start)
+	f1, _ := majorFaults()
+
+	atomic.AddUint64(&s.sampled, 1)
+	var cold bool
+	if known {
+		atomic.AddUint64(&s.faults, f1-f0)
+		cold = f1 > f0
+	} else {
+		cold = d > s.threshold
+	}
+	if cold {
+		atomic.AddUint64(&s.cold, 1)
+		atomic.AddInt64(&s.coldTime, int64(d))
+	} else {
+		atomic.AddInt64(&s.warmTime, int64(d))
+	}
+}
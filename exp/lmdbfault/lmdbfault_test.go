@@ -0,0 +1,64 @@
+package lmdbfault
+
+import (
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestSampler(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(&Options{Rate: 2})
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 10; i++ {
+			v, err := s.Get(txn, dbi, []byte("k"))
+			if err != nil {
+				return err
+			}
+			if string(v) != "v" {
+				t.Errorf("unexpected value: %q", v)
+			}
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		k, _, err := s.CursorGet(cur, nil, nil, lmdb.First)
+		if err != nil {
+			return err
+		}
+		if string(k) != "k" {
+			t.Errorf("unexpected key: %q", k)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := s.Stats()
+	if stats.Reads != 11 || stats.Sampled != 5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if r := stats.ColdRatio(); r < 0 || r > 1 {
+		t.Errorf("unexpected cold ratio: %v", r)
+	}
+}
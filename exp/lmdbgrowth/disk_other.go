@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package lmdbgrowth
+
+// diskFree returns -1 because the free disk space cannot be determined on
+// this platform.
+func diskFree(path string) int64 {
+	return -1
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package lmdbgrowth
+
+import "syscall"
+
+// diskFree returns the number of bytes available to unprivileged users on the
+// file system containing path, or -1 if it cannot be determined.
+func diskFree(path string) int64 {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return -1
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
@@ -0,0 +1,323 @@
+/*
+Package lmdbgrowth tracks the growth of an LMDB environment over time and
+projects when the memory map or the disk holding it will be exhausted.
+
+A Monitor periodically samples the number of bytes used by the environment and
+persists the samples in a dedicated database of the environment, so that the
+growth rate survives restarts.  Each sample produces a Projection computed from
+the least squares growth rate of the retained samples.  When the projected
+exhaustion of the map or the disk falls within Options.Warn the Options.Alarm
+callback is called, turning an lmdb.MapFull error from a surprise into a
+scheduled maintenance item.
+*/
+package lmdbgrowth
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultDBName is the name of the database holding samples when
+// Options.DBName is not set.
+const DefaultDBName = "lmdbgrowth"
+
+// DefaultWindow is the number of samples retained when Options.Window is not
+// set.
+const DefaultWindow = 1000
+
+// Options configure a Monitor.
+type Options struct {
+	// DBName is the name of the database samples are stored in.  The
+	// environment must allow for one more named database than the
+	// application uses, see lmdb.Env.SetMaxDBs.
+	DBName string
+
+	// Window is the number of most recent samples used to compute the growth
+	// rate.  Older samples are deleted.
+	Window int
+
+	// Warn is how far ahead of the projected exhaustion of the map or the
+	// disk Alarm is called.
+	Warn time.Duration
+
+	// Alarm is called by Sample when the map or the disk is projected to be
+	// exhausted within Warn.
+	Alarm func(*Projection)
+}
+
+// Sample is a measurement of the space used by an environment.
+type Sample struct {
+	Time    time.Time
+	Used    int64 // Bytes used by the environment's pages
+	MapSize int64 // Size of the memory map
+}
+
+// Projection is the outcome of a Sample.
+type Projection struct {
+	Sample
+
+	// Rate is the growth rate, in bytes per second, over the retained
+	// samples.  Rate is zero until at least two samples have been taken.
+	Rate float64
+
+	// DiskFree is the number of bytes available on the disk holding the
+	// environment, or -1 if it is not known.
+	DiskFree int64
+
+	// MapFull and DiskFull are the times at which the map and the disk are
+	// projected to be full.  They are zero if the environment is not growing
+	// or the free disk space is not known.
+	MapFull  time.Time
+	DiskFull time.Time
+}
+
+// Alarm returns true if the map or the disk is projected to be exhausted
+// within d of the projection.
+func (p *Projection) Alarm(d time.Duration) bool {
+	deadline := p.Time.Add(d)
+	if !p.MapFull.IsZero() && p.MapFull.Before(deadline) {
+		return true
+	}
+	if !p.DiskFull.IsZero() && p.DiskFull.Before(deadline) {
+		return true
+	}
+	return false
+}
+
+// Monitor samples the growth of an environment.
+type Monitor struct {
+	env  *lmdb.Env
+	dbi  lmdb.DBI
+	opt  Options
+	path string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Monitor for env, which must be open, creating the database
+// holding samples if necessary.
+func New(env *lmdb.Env, opt *Options) (*Monitor, error) {
+	m := &Monitor{env: env}
+	if opt != nil {
+		m.opt = *opt
+	}
+	if m.opt.DBName == "" {
+		m.opt.DBName = DefaultDBName
+	}
+	if m.opt.Window <= 0 {
+		m.opt.Window = DefaultWindow
+	}
+	path, err := env.Path()
+	if err != nil {
+		return nil, err
+	}
+	flags, err := env.Flags()
+	if err != nil {
+		return nil, err
+	}
+	if flags&lmdb.NoSubdir != 0 {
+		path = filepath.Dir(path)
+	}
+	m.path = path
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		m.dbi, err = txn.OpenDBI(m.opt.DBName, lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sample measures the space used by the environment, stores the measurement
+// and returns a projection of future growth.  If the projection warrants an
+// alarm Options.Alarm is called before Sample returns.
+func (m *Monitor) Sample() (*Projection, error) {
+	info, err := m.env.Info()
+	if err != nil {
+		return nil, err
+	}
+	stat, err := m.env.Stat()
+	if err != nil {
+		return nil, err
+	}
+	s := Sample{
+		Time:    time.Now(),
+		Used:    (info.LastPNO + 1) * int64(stat.PSize),
+		MapSize: info.MapSize,
+	}
+
+	var samples []Sample
+	err = m.env.Update(func(txn *lmdb.Txn) (err error) {
+		err = txn.Put(m.dbi, encodeTime(s.Time), encodeSample(&s), 0)
+		if err != nil {
+			return err
+		}
+		samples, err = m.trim(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Projection{Sample: s, DiskFree: diskFree(m.path)}
+	p.Rate = growthRate(samples)
+	if p.Rate > 0 {
+		p.MapFull = s.Time.Add(secondsDuration(float64(s.MapSize-s.Used) / p.Rate))
+		if p.DiskFree >= 0 {
+			p.DiskFull = s.Time.Add(secondsDuration(float64(p.DiskFree) / p.Rate))
+		}
+	}
+	if m.opt.Alarm != nil && p.Alarm(m.opt.Warn) {
+		m.opt.Alarm(p)
+	}
+	return p, nil
+}
+
+// trim deletes samples outside the window and returns the remaining samples.
+func (m *Monitor) trim(txn *lmdb.Txn) ([]Sample, error) {
+	samples, err := readSamples(txn, m.dbi)
+	if err != nil {
+		return nil, err
+	}
+	for len(samples) > m.opt.Window {
+		err = txn.Del(m.dbi, encodeTime(samples[0].Time), nil)
+		if err != nil {
+			return nil, err
+		}
+		samples = samples[1:]
+	}
+	return samples, nil
+}
+
+// Samples returns the retained samples, oldest first.
+func (m *Monitor) Samples() ([]Sample, error) {
+	var samples []Sample
+	err := m.env.View(func(txn *lmdb.Txn) (err error) {
+		samples, err = readSamples(txn, m.dbi)
+		return err
+	})
+	return samples, err
+}
+
+// Start calls Sample every interval in a new goroutine until Stop is called.
+// Errors returned by Sample are passed to errfn, if it is not nil.
+func (m *Monitor) Start(interval time.Duration, errfn func(error)) error {
+	if m.stop != nil {
+		return errors.New("lmdbgrowth: monitor already started")
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			_, err := m.Sample()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start and waits for it to exit.
+func (m *Monitor) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+	m.done = nil
+}
+
+func readSamples(txn *lmdb.Txn, dbi lmdb.DBI) ([]Sample, error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	var samples []Sample
+	for {
+		k, v, err := cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, ok := decodeSample(k, v)
+		if !ok {
+			continue
+		}
+		samples = append(samples, s)
+	}
+}
+
+func encodeTime(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func encodeSample(s *Sample) []byte {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v, uint64(s.Used))
+	binary.BigEndian.PutUint64(v[8:], uint64(s.MapSize))
+	return v
+}
+
+func decodeSample(k, v []byte) (Sample, bool) {
+	if len(k) != 8 || len(v) != 16 {
+		return Sample{}, false
+	}
+	return Sample{
+		Time:    time.Unix(0, int64(binary.BigEndian.Uint64(k))),
+		Used:    int64(binary.BigEndian.Uint64(v)),
+		MapSize: int64(binary.BigEndian.Uint64(v[8:])),
+	}, true
+}
+
+// growthRate returns the least squares slope of used bytes over time, in
+// bytes per second.
+func growthRate(samples []Sample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	t0 := samples[0].Time
+	var sx, sy, sxx, sxy float64
+	n := float64(len(samples))
+	for _, s := range samples {
+		x := s.Time.Sub(t0).Seconds()
+		y := float64(s.Used)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
+
+func secondsDuration(sec float64) time.Duration {
+	const max = float64(1<<63 - 1)
+	d := sec * float64(time.Second)
+	if d > max {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,83 @@
+package lmdbgrowth
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestMonitor(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "data", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var alarms []*Projection
+	m, err := New(env, &Options{
+		Window: 3,
+		Warn:   100 * 365 * 24 * time.Hour,
+		Alarm:  func(p *Projection) { alarms = append(alarms, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p *Projection
+	val := bytes.Repeat([]byte("x"), 64<<10)
+	for i := 0; i < 5; i++ {
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			return txn.Put(dbi, []byte(fmt.Sprint(i)), val, 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+		p, err = m.Sample()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples, err := m.Samples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Errorf("unexpected number of samples: %d", len(samples))
+	}
+	if p.Rate <= 0 {
+		t.Errorf("unexpected rate: %v", p.Rate)
+	}
+	if p.MapFull.IsZero() || !p.MapFull.After(p.Time) {
+		t.Errorf("unexpected map full projection: %v", p.MapFull)
+	}
+	if len(alarms) != 4 {
+		t.Errorf("unexpected number of alarms: %d", len(alarms))
+	}
+}
+
+func TestGrowthRate(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	samples := []Sample{
+		{Time: t0, Used: 100},
+		{Time: t0.Add(time.Second), Used: 300},
+		{Time: t0.Add(2 * time.Second), Used: 500},
+	}
+	rate := growthRate(samples)
+	if rate != 200 {
+		t.Errorf("unexpected rate: %v", rate)
+	}
+	if rate := growthRate(samples[:1]); rate != 0 {
+		t.Errorf("unexpected rate for one sample: %v", rate)
+	}
+}
@@ -0,0 +1,103 @@
+package lmdbharness
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Names of the helpers registered by the package.  The helpers open the
+// environment passed to Start with default options.
+const (
+	// HoldReader begins a read transaction, sends "ready" and keeps the
+	// transaction open until it receives a line.  Killing the helper while
+	// it waits leaves a stale entry in the reader lock table.
+	HoldReader = "lmdbharness.holdreader"
+
+	// HoldWriter begins a write transaction, sends "locked" and holds the
+	// writer lock until it receives a line.  It then commits and sends
+	// "done".
+	HoldWriter = "lmdbharness.holdwriter"
+
+	// Grow sets the map size to its first argument, writes half that many
+	// bytes and sends "done".  The environment then outgrows maps smaller
+	// than half the size, so other processes using them see
+	// lmdb.MapResized.
+	Grow = "lmdbharness.grow"
+)
+
+func init() {
+	Register(HoldReader, holdReader)
+	Register(HoldWriter, holdWriter)
+	Register(Grow, grow)
+}
+
+func holdReader(h *Helper) error {
+	env, err := h.Open()
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	return env.View(func(txn *lmdb.Txn) error {
+		err := h.Send("ready")
+		if err != nil {
+			return err
+		}
+		_, err = h.Recv()
+		return err
+	})
+}
+
+func holdWriter(h *Helper) error {
+	env, err := h.Open()
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	err = env.Update(func(txn *lmdb.Txn) error {
+		err := h.Send("locked")
+		if err != nil {
+			return err
+		}
+		_, err = h.Recv()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return h.Send("done")
+}
+
+func grow(h *Helper) error {
+	if len(h.Args) != 1 {
+		return fmt.Errorf("%s: expected a map size", Grow)
+	}
+	size, err := strconv.ParseInt(h.Args[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	env, err := h.Open(lmdb.WithMapSize(size))
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	err = env.Update(func(txn *lmdb.Txn) error {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		val := make([]byte, 1<<10)
+		for i := int64(0); i < size/2/int64(len(val)); i++ {
+			err = txn.Put(dbi, []byte(Grow+strconv.FormatInt(i, 10)), val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return h.Send("done")
+}
@@ -0,0 +1,259 @@
+/*
+Package lmdbharness runs helper subprocesses for tests of applications that
+share LMDB environments between processes.
+
+Behavior such as lock contention, lmdb.MapResized errors and the cleanup of
+readers left behind by crashed processes can only be observed with more than
+one process, because a process must not open the same environment twice.
+Package lmdbharness re-executes the test binary to run helper functions in
+child processes and connects to them with a line-based protocol over their
+standard input and output.
+
+Helpers are registered by name, usually in an init function, and the test
+binary must call Main at the start of TestMain:
+
+	func TestMain(m *testing.M) {
+		lmdbharness.Main()
+		os.Exit(m.Run())
+	}
+
+A test starts a helper with Start and exchanges lines with it to coordinate
+the steps of a scenario.  The package registers a few helpers for common
+scenarios, see HoldReader, HoldWriter and Grow.
+*/
+package lmdbharness
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// envVar names the environment variable that selects the helper run by Main.
+const envVar = "LMDBHARNESS_HELPER"
+
+// ErrTimeout is returned by Process.Expect when no line arrives in time.
+var ErrTimeout = errors.New("lmdbharness: timeout waiting for helper")
+
+// Func is the body of a helper process.  A non-nil error is written to
+// standard error and makes the process exit with status 1.
+type Func func(h *Helper) error
+
+var (
+	mu      sync.Mutex
+	helpers = map[string]Func{}
+)
+
+// Register registers fn as the helper called name.  Register panics if name
+// is already registered.  Helpers must be registered before Main is called,
+// normally in an init function, so that the helper process knows them too.
+func Register(name string, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := helpers[name]; ok {
+		panic("lmdbharness: helper " + name + " registered twice")
+	}
+	helpers[name] = fn
+}
+
+// Main runs the helper selected by Start and exits if the process was started
+// by Start, and returns otherwise.
+func Main() {
+	name := os.Getenv(envVar)
+	if name == "" {
+		return
+	}
+	mu.Lock()
+	fn := helpers[name]
+	mu.Unlock()
+	if fn == nil {
+		fmt.Fprintf(os.Stderr, "lmdbharness: unknown helper %q\n", name)
+		os.Exit(2)
+	}
+	// The arguments follow the flag that keeps the test binary from running
+	// tests if Main is not called.
+	args := os.Args[2:]
+	h := &Helper{
+		Path: args[0],
+		Args: args[1:],
+		in:   bufio.NewReader(os.Stdin),
+		out:  os.Stdout,
+	}
+	err := fn(h)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Helper is the side of the protocol available to a helper function.
+type Helper struct {
+	// Path is the path of the environment passed to Start.
+	Path string
+
+	// Args are the additional arguments passed to Start.
+	Args []string
+
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Open opens the environment at h.Path with lmdb.Open.
+func (h *Helper) Open(opts ...lmdb.EnvOption) (*lmdb.Env, error) {
+	return lmdb.Open(h.Path, opts...)
+}
+
+// Send writes line to the test process.
+func (h *Helper) Send(line string) error {
+	_, err := fmt.Fprintln(h.out, line)
+	return err
+}
+
+// Recv reads a line from the test process.  It returns io.EOF when the test
+// process closed the input of the helper.
+func (h *Helper) Recv() (string, error) {
+	return readLine(h.in)
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// Process is a running helper.
+type Process struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+}
+
+// Start starts the helper called name in a new process running the test
+// binary.  The helper receives the environment path and args.  The process
+// must be waited for with Wait or Kill.
+func Start(name, path string, args ...string) (*Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	p := &Process{
+		name:  name,
+		cmd:   exec.Command(exe, append([]string{"-test.run=^$", path}, args...)...),
+		lines: make(chan string, 16),
+		done:  make(chan struct{}),
+	}
+	p.cmd.Env = append(os.Environ(), envVar+"="+name)
+	p.cmd.Stderr = &p.stderr
+	p.stdin, err = p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = p.cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	go p.read(stdout)
+	return p, nil
+}
+
+func (p *Process) read(stdout io.Reader) {
+	defer close(p.lines)
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		p.lines <- line
+	}
+}
+
+// Send writes line to the helper.
+func (p *Process) Send(line string) error {
+	_, err := fmt.Fprintln(p.stdin, line)
+	return err
+}
+
+// Recv returns the next line written by the helper, waiting at most timeout.
+// If the helper exited io.EOF is returned.
+func (p *Process) Recv(timeout time.Duration) (string, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	case <-t.C:
+		return "", ErrTimeout
+	}
+}
+
+// Expect reads the next line written by the helper and returns an error if it
+// is not want.
+func (p *Process) Expect(want string, timeout time.Duration) error {
+	line, err := p.Recv(timeout)
+	if err == io.EOF {
+		return fmt.Errorf("lmdbharness: %s exited waiting for %q: %v", p.name, want, p.Wait())
+	}
+	if err != nil {
+		return err
+	}
+	if line != want {
+		return fmt.Errorf("lmdbharness: %s sent %q (expected %q)", p.name, line, want)
+	}
+	return nil
+}
+
+// Wait closes the input of the helper and waits for it to exit.  The error
+// returned includes what the helper wrote to standard error.
+func (p *Process) Wait() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+	}
+	p.stdin.Close()
+	// Drain the output so that the helper does not block writing it.
+	for range p.lines {
+	}
+	err := p.cmd.Wait()
+	if err != nil {
+		err = fmt.Errorf("lmdbharness: %s: %v: %s", p.name, err, bytes.TrimSpace(p.stderr.Bytes()))
+	}
+	p.err = err
+	close(p.done)
+	return err
+}
+
+// Kill kills the helper without giving it a chance to clean up, as if it
+// crashed, and waits for it to exit.
+func (p *Process) Kill() error {
+	err := p.cmd.Process.Kill()
+	if err != nil {
+		return err
+	}
+	p.Wait()
+	return nil
+}
@@ -0,0 +1,160 @@
+package lmdbharness
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+const timeout = 10 * time.Second
+
+func TestMain(m *testing.M) {
+	Main()
+	os.Exit(m.Run())
+}
+
+func newEnv(t *testing.T, mapsize int64) (*lmdb.Env, string) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MapSize: mapsize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := env.Path()
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	return env, path
+}
+
+func TestHoldReader(t *testing.T) {
+	env, path := newEnv(t, 0)
+	defer lmdbtest.Destroy(env)
+
+	p, err := Start(HoldReader, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Expect("ready", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumReaders == 0 {
+		t.Errorf("reader of helper not registered")
+	}
+
+	err = p.Kill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := env.ReaderCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d stale readers cleared (!= 1)", n)
+	}
+}
+
+func TestHoldWriter(t *testing.T) {
+	env, path := newEnv(t, 0)
+	defer lmdbtest.Destroy(env)
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Start(HoldWriter, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Wait()
+	err = p.Expect("locked", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	committed := make(chan error, 1)
+	go func() {
+		committed <- env.Update(func(txn *lmdb.Txn) error {
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+	}()
+	select {
+	case err := <-committed:
+		t.Fatalf("write transaction not blocked by helper: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err = p.Send("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Expect("done", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-committed
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	env, path := newEnv(t, 1<<20)
+	defer lmdbtest.Destroy(env)
+
+	p, err := Start(Grow, path, strconv.Itoa(4<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Expect("done", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) error { return nil })
+	if !lmdb.IsMapResized(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = env.SetMapSize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 4<<20 {
+		t.Errorf("map size %d (!= %d)", info.MapSize, 4<<20)
+	}
+}
+
+func TestStart_error(t *testing.T) {
+	env, path := newEnv(t, 0)
+	defer lmdbtest.Destroy(env)
+
+	p, err := Start(Grow, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Expect("done", timeout)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Package lmdbindex maintains secondary indexes of an LMDB database.
+
+A Table couples a primary database with any number of indexes.  Each Index is
+a database with the lmdb.DupSort flag mapping derived keys to the keys of
+primary items, and its entries are computed by an IndexFunc.  Writes made
+through Table.Put and Table.Del update the primary database and every index
+in the same transaction, so the indexes cannot diverge from the primary data
+unless the primary database is modified directly.  Table.Rebuild and
+Table.Check repair and verify indexes which may have diverged.
+*/
+package lmdbindex
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// IndexFunc returns the index keys of the primary item key, val.  It may
+// return no keys if the item is not indexed.  IndexFunc must be
+// deterministic and must not retain key or val.
+type IndexFunc func(key, val []byte) [][]byte
+
+// Index is a secondary index.  DBI must have been opened with the
+// lmdb.DupSort flag.
+type Index struct {
+	Name string
+	DBI  lmdb.DBI
+	Func IndexFunc
+}
+
+// Table is a primary database and its indexes.
+type Table struct {
+	Primary lmdb.DBI
+	Indexes []*Index
+}
+
+// Put stores val under key in the primary database and updates the indexes
+// for the change.
+func (t *Table) Put(txn *lmdb.Txn, key, val []byte, flags uint) error {
+	old, err := t.get(txn, key)
+	if err != nil {
+		return err
+	}
+	err = txn.Put(t.Primary, key, val, flags)
+	if err != nil {
+		return err
+	}
+	for _, idx := range t.Indexes {
+		var oldkeys [][]byte
+		if old != nil {
+			oldkeys = idx.Func(key, old)
+		}
+		newkeys := idx.Func(key, val)
+		for _, ik := range oldkeys {
+			if !containsKey(newkeys, ik) {
+				err = delEntry(txn, idx, ik, key)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, ik := range newkeys {
+			if !containsKey(oldkeys, ik) {
+				err = putEntry(txn, idx, ik, key)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Del deletes key from the primary database and removes its index entries.
+func (t *Table) Del(txn *lmdb.Txn, key []byte) error {
+	old, err := t.get(txn, key)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		return txn.Del(t.Primary, key, nil)
+	}
+	err = txn.Del(t.Primary, key, nil)
+	if err != nil {
+		return err
+	}
+	for _, idx := range t.Indexes {
+		for _, ik := range idx.Func(key, old) {
+			err = delEntry(txn, idx, ik, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// get returns a copy of the primary value of key, or nil if key does not
+// exist.
+func (t *Table) get(txn *lmdb.Txn, key []byte) ([]byte, error) {
+	v, err := txn.Get(t.Primary, key)
+	if lmdb.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if txn.RawRead {
+		v = append([]byte(nil), v...)
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	return v, nil
+}
+
+func putEntry(txn *lmdb.Txn, idx *Index, ik, key []byte) error {
+	err := txn.Put(idx.DBI, ik, key, lmdb.NoDupData)
+	if lmdb.IsErrno(err, lmdb.KeyExist) {
+		return nil
+	}
+	return err
+}
+
+func delEntry(txn *lmdb.Txn, idx *Index, ik, key []byte) error {
+	err := txn.Del(idx.DBI, ik, key)
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func containsKey(keys [][]byte, k []byte) bool {
+	for _, x := range keys {
+		if bytes.Equal(x, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the primary keys indexed under ik in idx.
+func (t *Table) Lookup(txn *lmdb.Txn, idx *Index, ik []byte) ([][]byte, error) {
+	cur, err := txn.OpenCursor(idx.DBI)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	var keys [][]byte
+	_, v, err := cur.Get(ik, nil, lmdb.SetKey)
+	for err == nil {
+		keys = append(keys, append([]byte(nil), v...))
+		_, v, err = cur.Get(nil, nil, lmdb.NextDup)
+	}
+	if !lmdb.IsNotFound(err) {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Rebuild empties idx and recomputes its entries from the primary database.
+func (t *Table) Rebuild(txn *lmdb.Txn, idx *Index) error {
+	err := txn.Drop(idx.DBI, false)
+	if err != nil {
+		return err
+	}
+	return t.scan(txn, func(k, v []byte) error {
+		for _, ik := range idx.Func(k, v) {
+			err := putEntry(txn, idx, ik, k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (t *Table) scan(txn *lmdb.Txn, fn func(k, v []byte) error) error {
+	cur, err := txn.OpenCursor(t.Primary)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for {
+		k, v, err := cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(k, v)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Problem describes an inconsistency between an index and the primary
+// database.
+type Problem struct {
+	Index    string
+	IndexKey []byte
+	Key      []byte // The primary key
+	Missing  bool   // The entry is missing from the index, otherwise it is dangling
+}
+
+func (p *Problem) String() string {
+	if p.Missing {
+		return fmt.Sprintf("index %s: missing entry %q for key %q", p.Index, p.IndexKey, p.Key)
+	}
+	return fmt.Sprintf("index %s: dangling entry %q for key %q", p.Index, p.IndexKey, p.Key)
+}
+
+// Check verifies the indexes of t against the primary database and returns
+// the problems found: entries missing from an index and entries of an index
+// which do not correspond to a primary item.
+func (t *Table) Check(txn *lmdb.Txn) ([]*Problem, error) {
+	var problems []*Problem
+	for _, idx := range t.Indexes {
+		cur, err := txn.OpenCursor(idx.DBI)
+		if err != nil {
+			return nil, err
+		}
+		err = t.scan(txn, func(k, v []byte) error {
+			for _, ik := range idx.Func(k, v) {
+				_, _, err := cur.Get(ik, k, lmdb.GetBoth)
+				if lmdb.IsNotFound(err) {
+					problems = append(problems, &Problem{
+						Index:    idx.Name,
+						IndexKey: append([]byte(nil), ik...),
+						Key:      append([]byte(nil), k...),
+						Missing:  true,
+					})
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			problems, err = t.checkDangling(txn, idx, cur, problems)
+		}
+		cur.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+func (t *Table) checkDangling(txn *lmdb.Txn, idx *Index, cur *lmdb.Cursor, problems []*Problem) ([]*Problem, error) {
+	for op := uint(lmdb.First); ; op = lmdb.Next {
+		ik, k, err := cur.Get(nil, nil, op)
+		if lmdb.IsNotFound(err) {
+			return problems, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := txn.Get(t.Primary, k)
+		if err != nil && !lmdb.IsNotFound(err) {
+			return nil, err
+		}
+		if lmdb.IsNotFound(err) || !containsKey(idx.Func(k, v), ik) {
+			problems = append(problems, &Problem{
+				Index:    idx.Name,
+				IndexKey: append([]byte(nil), ik...),
+				Key:      append([]byte(nil), k...),
+			})
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package lmdbindex
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// byDomain indexes "user@domain" values by domain.
+func byDomain(key, val []byte) [][]byte {
+	i := bytes.IndexByte(val, '@')
+	if i < 0 {
+		return nil
+	}
+	return [][]byte{val[i+1:]}
+}
+
+func TestTable(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	table := &Table{}
+	idx := &Index{Name: "domain", Func: byDomain}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		table.Primary, err = txn.OpenDBI("users", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		idx.DBI, err = txn.OpenDBI("users_domain", lmdb.Create|lmdb.DupSort)
+		table.Indexes = []*Index{idx}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(txn *lmdb.Txn, domain string) []string {
+		keys, err := table.Lookup(txn, idx, []byte(domain))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, k := range keys {
+			s = append(s, string(k))
+		}
+		return s
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, kv := range [][2]string{
+			{"alice", "alice@a.example"},
+			{"bob", "bob@b.example"},
+			{"carol", "carol@a.example"},
+			{"dave", "no domain"},
+		} {
+			err = table.Put(txn, []byte(kv[0]), []byte(kv[1]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		if s := lookup(txn, "a.example"); !reflect.DeepEqual(s, []string{"alice", "carol"}) {
+			t.Errorf("unexpected lookup: %q", s)
+		}
+
+		// moving bob to a.example and deleting carol updates the index.
+		err = table.Put(txn, []byte("bob"), []byte("bob@a.example"), 0)
+		if err != nil {
+			return err
+		}
+		err = table.Del(txn, []byte("carol"))
+		if err != nil {
+			return err
+		}
+		if s := lookup(txn, "a.example"); !reflect.DeepEqual(s, []string{"alice", "bob"}) {
+			t.Errorf("unexpected lookup: %q", s)
+		}
+		if s := lookup(txn, "b.example"); len(s) != 0 {
+			t.Errorf("unexpected lookup: %q", s)
+		}
+
+		problems, err := table.Check(txn)
+		if err != nil {
+			return err
+		}
+		if len(problems) != 0 {
+			t.Errorf("unexpected problems: %v", problems)
+		}
+
+		// corrupt the index by modifying the primary database directly.
+		err = txn.Put(table.Primary, []byte("erin"), []byte("erin@e.example"), 0)
+		if err != nil {
+			return err
+		}
+		err = txn.Del(table.Primary, []byte("alice"), nil)
+		if err != nil {
+			return err
+		}
+		problems, err = table.Check(txn)
+		if err != nil {
+			return err
+		}
+		if len(problems) != 2 {
+			t.Fatalf("unexpected problems: %v", problems)
+		}
+		if !problems[0].Missing || string(problems[0].Key) != "erin" {
+			t.Errorf("unexpected problem: %v", problems[0])
+		}
+		if problems[1].Missing || string(problems[1].Key) != "alice" {
+			t.Errorf("unexpected problem: %v", problems[1])
+		}
+
+		err = table.Rebuild(txn, idx)
+		if err != nil {
+			return err
+		}
+		problems, err = table.Check(txn)
+		if err != nil {
+			return err
+		}
+		if len(problems) != 0 {
+			t.Errorf("unexpected problems after rebuild: %v", problems)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
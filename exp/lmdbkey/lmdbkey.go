@@ -0,0 +1,47 @@
+/*
+Package lmdbkey stores keys longer than LMDB allows by shortening them.
+
+LMDB limits keys to lmdb.Env.MaxKeySize bytes, 511 by default, and rejects
+longer ones with a *lmdb.KeyTooLongError.  Shorten replaces the tail of a long
+key with its SHA-256 hash, so that the result fits and distinct keys remain
+distinct.  The shortened key keeps the prefix of the original, so items still
+sort by their prefix and prefix scans shorter than the kept part still find
+them.
+
+Keys are shortened once they reach the maximum length, not only past it, so
+that a shortened key is always exactly max bytes long and can never equal a
+key stored as given, which is always shorter.
+*/
+package lmdbkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// HashSize is the number of bytes of a shortened key taken by the hash.
+const HashSize = sha256.Size
+
+// Shorten returns key if it is shorter than max bytes and otherwise the first
+// max-HashSize bytes of key followed by the SHA-256 hash of the whole key.
+// Shorten panics if max is not larger than HashSize.  The same max must be
+// used for all keys of a database, typically lmdb.Env.MaxKeySize.
+func Shorten(key []byte, max int) []byte {
+	if max <= HashSize {
+		panic(fmt.Sprintf("lmdbkey: maximum key size %d too small", max))
+	}
+	if len(key) < max {
+		return key
+	}
+	sum := sha256.Sum256(key)
+	short := make([]byte, max)
+	n := copy(short, key[:max-HashSize])
+	copy(short[n:], sum[:])
+	return short
+}
+
+// IsShortened returns true if key was shortened by Shorten with the same max,
+// in which case only its prefix is known.
+func IsShortened(key []byte, max int) bool {
+	return len(key) == max
+}
@@ -0,0 +1,62 @@
+package lmdbkey
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestShorten(t *testing.T) {
+	const max = 64
+	short := []byte("short")
+	if k := Shorten(short, max); !bytes.Equal(k, short) || IsShortened(k, max) {
+		t.Errorf("short key changed: %q", k)
+	}
+
+	a := bytes.Repeat([]byte("a"), 100)
+	b := append(bytes.Repeat([]byte("a"), 99), 'b')
+	ka, kb := Shorten(a, max), Shorten(b, max)
+	if len(ka) != max || !IsShortened(ka, max) {
+		t.Errorf("key of %d bytes", len(ka))
+	}
+	if !bytes.HasPrefix(ka, a[:max-HashSize]) {
+		t.Errorf("prefix not kept: %q", ka)
+	}
+	if bytes.Equal(ka, kb) {
+		t.Errorf("distinct keys shortened to the same key")
+	}
+
+	// A key of exactly max bytes is shortened, so that it cannot collide
+	// with a shortened key.
+	if k := Shorten(ka, max); bytes.Equal(k, ka) {
+		t.Errorf("key of %d bytes not shortened", max)
+	}
+}
+
+func TestShorten_env(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := bytes.Repeat([]byte("k"), env.MaxKeySize()+1)
+	err = env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, long, []byte("v"), 0)
+	})
+	if _, ok := err.(*lmdb.KeyTooLongError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, Shorten(long, env.MaxKeySize()), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
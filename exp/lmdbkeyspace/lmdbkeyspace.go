@@ -0,0 +1,240 @@
+/*
+Package lmdbkeyspace lets independent components of an application declare
+the parts of an environment's keyspace they own, so that accidental key
+collisions between them are detected.
+
+Large applications often embed several libraries which store data in the same
+environment.  Each component claims databases, or key prefixes within a
+database, in a Registry.  Claims are recorded in a dedicated database, so that
+they persist and are checked across processes and restarts, and a claim that
+overlaps a claim of another component is rejected.
+
+Components write through a Component handle.  When Options.Debug is set every
+Put and Del is checked and writes into a prefix claimed by another component
+fail with a *CollisionError.  Without Debug the checks are skipped and a
+Component adds no overhead beyond a function call.
+*/
+package lmdbkeyspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultDBName is the name of the database holding claims when
+// Options.DBName is not set.
+const DefaultDBName = "lmdbkeyspace"
+
+// Claim is a part of the keyspace owned by a component.
+type Claim struct {
+	Owner  string `json:"owner"`
+	DB     string `json:"db"`               // Name of the database, empty for the root database
+	Prefix []byte `json:"prefix,omitempty"` // Key prefix, empty to claim the whole database
+}
+
+func (c *Claim) overlaps(o *Claim) bool {
+	return c.DB == o.DB && (bytes.HasPrefix(c.Prefix, o.Prefix) || bytes.HasPrefix(o.Prefix, c.Prefix))
+}
+
+func (c *Claim) contains(db string, key []byte) bool {
+	return c.DB == db && bytes.HasPrefix(key, c.Prefix)
+}
+
+// CollisionError is returned when a claim overlaps a claim of another
+// component, or a component writes into a foreign claim.
+type CollisionError struct {
+	Owner string // The component making the claim or the write
+	DB    string
+	Key   []byte // The conflicting prefix or key
+	Claim Claim  // The existing claim
+}
+
+func (err *CollisionError) Error() string {
+	return fmt.Sprintf("lmdbkeyspace: %s: key %q in database %q is claimed by %s",
+		err.Owner, err.Key, err.DB, err.Claim.Owner)
+}
+
+// Options configure a Registry.
+type Options struct {
+	// DBName is the name of the database claims are stored in.
+	DBName string
+
+	// Debug enables checking of writes made through Components.
+	Debug bool
+}
+
+// Registry records the claims of components on an environment.
+type Registry struct {
+	env   *lmdb.Env
+	dbi   lmdb.DBI
+	debug bool
+
+	mu     sync.RWMutex
+	claims []Claim
+	names  map[lmdb.DBI]string
+}
+
+// Open returns the Registry of env, which must be open, creating the database
+// holding claims if necessary.
+func Open(env *lmdb.Env, opt *Options) (*Registry, error) {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.DBName == "" {
+		o.DBName = DefaultDBName
+	}
+	r := &Registry{env: env, debug: o.Debug, names: make(map[lmdb.DBI]string)}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		r.dbi, err = txn.OpenDBI(o.DBName, lmdb.Create)
+		if err != nil {
+			return err
+		}
+		r.claims, err = r.load(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Registry) load(txn *lmdb.Txn) ([]Claim, error) {
+	cur, err := txn.OpenCursor(r.dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	var claims []Claim
+	for {
+		_, v, err := cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return claims, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var c Claim
+		err = json.Unmarshal(v, &c)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+}
+
+// Claim records that owner owns the keys with prefix in the named database.
+// An empty prefix claims the whole database.  Claim returns a *CollisionError
+// if the claim overlaps a claim of another owner, including claims recorded
+// by other processes.  Claiming a prefix again is harmless.
+func (r *Registry) Claim(owner, db string, prefix []byte) error {
+	c := Claim{Owner: owner, DB: db, Prefix: append([]byte(nil), prefix...)}
+	return r.env.Update(func(txn *lmdb.Txn) error {
+		claims, err := r.load(txn)
+		if err != nil {
+			return err
+		}
+		for i := range claims {
+			if !claims[i].overlaps(&c) {
+				continue
+			}
+			if claims[i].Owner != owner {
+				return &CollisionError{Owner: owner, DB: db, Key: c.Prefix, Claim: claims[i]}
+			}
+			if bytes.Equal(claims[i].Prefix, c.Prefix) {
+				r.setClaims(claims)
+				return nil
+			}
+		}
+		v, err := json.Marshal(&c)
+		if err != nil {
+			return err
+		}
+		k := append(append([]byte(db), 0), c.Prefix...)
+		err = txn.Put(r.dbi, k, v, 0)
+		if err != nil {
+			return err
+		}
+		claims = append(claims, c)
+		txn.OnCommit(func(uintptr) { r.setClaims(claims) })
+		return nil
+	})
+}
+
+func (r *Registry) setClaims(claims []Claim) {
+	r.mu.Lock()
+	r.claims = claims
+	r.mu.Unlock()
+}
+
+// Claims returns the claims known to r.
+func (r *Registry) Claims() []Claim {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Claim(nil), r.claims...)
+}
+
+// AddDBI registers the name of the database opened as dbi, so that writes
+// through Components to dbi can be checked.  Name is empty for the root
+// database.
+func (r *Registry) AddDBI(name string, dbi lmdb.DBI) {
+	r.mu.Lock()
+	r.names[dbi] = name
+	r.mu.Unlock()
+}
+
+// check returns an error if key in dbi is claimed by another owner.
+func (r *Registry) check(owner string, dbi lmdb.DBI, key []byte) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	db, ok := r.names[dbi]
+	if !ok {
+		return fmt.Errorf("lmdbkeyspace: dbi %d is not registered", dbi)
+	}
+	for i := range r.claims {
+		c := &r.claims[i]
+		if c.Owner != owner && c.contains(db, key) {
+			return &CollisionError{Owner: owner, DB: db, Key: append([]byte(nil), key...), Claim: *c}
+		}
+	}
+	return nil
+}
+
+// Component writes on behalf of an owner.
+type Component struct {
+	r     *Registry
+	owner string
+}
+
+// Component returns a handle for writes made by owner.
+func (r *Registry) Component(owner string) *Component {
+	return &Component{r: r, owner: owner}
+}
+
+// Put calls txn.Put after checking, in debug mode, that key is not claimed by
+// another component.
+func (c *Component) Put(txn *lmdb.Txn, dbi lmdb.DBI, key, val []byte, flags uint) error {
+	if c.r.debug {
+		err := c.r.check(c.owner, dbi, key)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Put(dbi, key, val, flags)
+}
+
+// Del calls txn.Del after checking, in debug mode, that key is not claimed by
+// another component.
+func (c *Component) Del(txn *lmdb.Txn, dbi lmdb.DBI, key, val []byte) error {
+	if c.r.debug {
+		err := c.r.check(c.owner, dbi, key)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Del(dbi, key, val)
+}
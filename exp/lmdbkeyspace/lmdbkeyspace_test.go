@@ -0,0 +1,79 @@
+package lmdbkeyspace
+
+import (
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestRegistry(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	r, err := Open(env, &Options{Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Claim("sessions", "", []byte("sess/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Claim("sessions", "", []byte("sess/"))
+	if err != nil {
+		t.Errorf("claiming again: %v", err)
+	}
+	err = r.Claim("users", "", []byte("user/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Claim("cache", "", []byte("sess"))
+	if _, ok := err.(*CollisionError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = r.Claim("cache", "", nil)
+	if _, ok := err.(*CollisionError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// claims persist.
+	r, err = Open(env, &Options{Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims := r.Claims(); len(claims) != 2 {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+
+	root, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddDBI("", root)
+	users := r.Component("users")
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		err = users.Put(txn, root, []byte("user/1"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = users.Put(txn, root, []byte("other"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = users.Put(txn, root, []byte("sess/1"), []byte("v"), 0)
+		if err, ok := err.(*CollisionError); !ok || err.Claim.Owner != "sessions" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = users.Del(txn, root, []byte("sess/1"), nil)
+		if _, ok := err.(*CollisionError); !ok {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
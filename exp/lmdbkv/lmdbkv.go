@@ -0,0 +1,200 @@
+/*
+Package lmdbkv adapts an LMDB database to the generic key-value store
+interfaces used by libraries such as github.com/philippgille/gokv.
+
+Store implements the method set of gokv.Store (Set, Get, Delete and Close)
+without importing gokv, so applications written against that interface can use
+LMDB as a drop-in backend.  Values are serialized with a Marshaler, which is
+compatible with gokv's encoding.Codec, and may additionally be transformed by an
+lmdbcodec.Codec, for example to compress them.
+*/
+package lmdbkv
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbcodec"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Marshaler serializes values.  It has the method set of gokv's
+// encoding.Codec.
+type Marshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON is a Marshaler using encoding/json.
+var JSON Marshaler = jsonMarshaler{}
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonMarshaler) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+var errEmptyKey = errors.New("lmdbkv: the key must not be empty")
+var errNilValue = errors.New("lmdbkv: the value must not be nil")
+
+// Options configure a Store.
+type Options struct {
+	// Path is the directory of the environment, which is created if it does
+	// not exist.
+	Path string
+
+	// DBName is the name of the database the store uses.  If empty the root
+	// database is used.
+	DBName string
+
+	// MapSize is the size of the memory map.  If zero the LMDB default is
+	// used.
+	MapSize int64
+
+	// Marshaler serializes values.  If nil JSON is used.
+	Marshaler Marshaler
+
+	// Codec transforms serialized values before they are stored.
+	Codec lmdbcodec.Codec
+}
+
+// Store is a key-value store backed by an LMDB database.  A Store is safe for
+// concurrent use.
+type Store struct {
+	env   *lmdb.Env
+	db    lmdbcodec.DB
+	m     Marshaler
+	owned bool
+}
+
+// NewStore opens the environment at opt.Path and returns a Store using it.
+// Close closes the environment.
+func NewStore(opt Options) (*Store, error) {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = openEnv(env, &opt)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	s, err := Wrap(env, opt)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+func openEnv(env *lmdb.Env, opt *Options) error {
+	if opt.MapSize > 0 {
+		err := env.SetMapSize(opt.MapSize)
+		if err != nil {
+			return err
+		}
+	}
+	if opt.DBName != "" {
+		err := env.SetMaxDBs(1)
+		if err != nil {
+			return err
+		}
+	}
+	err := os.MkdirAll(opt.Path, 0755)
+	if err != nil {
+		return err
+	}
+	return env.Open(opt.Path, 0, 0644)
+}
+
+// Wrap returns a Store using env, which must be open.  The Path and MapSize
+// options are ignored.  Close does not close env.
+func Wrap(env *lmdb.Env, opt Options) (*Store, error) {
+	s := &Store{env: env, m: opt.Marshaler}
+	if s.m == nil {
+		s.m = JSON
+	}
+	s.db.Codec = opt.Codec
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		if opt.DBName == "" {
+			s.db.DBI, err = txn.OpenRoot(0)
+		} else {
+			s.db.DBI, err = txn.OpenDBI(opt.DBName, lmdb.Create)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Set stores the serialized form of v under k.
+func (s *Store) Set(k string, v interface{}) error {
+	if k == "" {
+		return errEmptyKey
+	}
+	if v == nil {
+		return errNilValue
+	}
+	data, err := s.m.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.env.Update(func(txn *lmdb.Txn) error {
+		if s.db.Codec == nil {
+			return txn.Put(s.db.DBI, []byte(k), data, 0)
+		}
+		return s.db.Put(txn, []byte(k), data, 0)
+	})
+}
+
+// Get deserializes the value stored under k into v, which must be a pointer.
+// If k does not exist found is false and v is not modified.
+func (s *Store) Get(k string, v interface{}) (found bool, err error) {
+	if k == "" {
+		return false, errEmptyKey
+	}
+	if v == nil {
+		return false, errNilValue
+	}
+	var data []byte
+	err = s.env.View(func(txn *lmdb.Txn) (err error) {
+		if s.db.Codec == nil {
+			data, err = txn.Get(s.db.DBI, []byte(k))
+		} else {
+			data, err = s.db.Get(txn, []byte(k))
+		}
+		return err
+	})
+	if lmdb.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, s.m.Unmarshal(data, v)
+}
+
+// Delete deletes k.  Deleting a key that does not exist is not an error.
+func (s *Store) Delete(k string) error {
+	if k == "" {
+		return errEmptyKey
+	}
+	err := s.env.Update(func(txn *lmdb.Txn) error {
+		return txn.Del(s.db.DBI, []byte(k), nil)
+	})
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Close closes the environment if it was opened by NewStore.
+func (s *Store) Close() error {
+	if s.owned {
+		return s.env.Close()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package lmdbkv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbcodec"
+)
+
+// gokvStore is the method set of gokv.Store.
+type gokvStore interface {
+	Set(k string, v interface{}) error
+	Get(k string, v interface{}) (found bool, err error)
+	Delete(k string) error
+	Close() error
+}
+
+var _ gokvStore = (*Store)(nil)
+
+type user struct {
+	Name  string
+	Email string
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lmdbkv-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewStore(Options{
+		Path:   filepath.Join(dir, "db"),
+		DBName: "users",
+		Codec:  lmdbcodec.Compress(lmdbcodec.Flate{}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var u user
+	found, err := s.Get("alice", &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Errorf("unexpected value found: %+v", u)
+	}
+
+	alice := user{Name: "Alice", Email: "alice@example.com"}
+	err = s.Set("alice", alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err = s.Get("alice", &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || u != alice {
+		t.Errorf("unexpected value: %v %+v", found, u)
+	}
+
+	err = s.Delete("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Delete("alice")
+	if err != nil {
+		t.Errorf("unexpected error deleting missing key: %v", err)
+	}
+	found, err = s.Get("alice", &u)
+	if err != nil || found {
+		t.Errorf("unexpected result after delete: %v %v", found, err)
+	}
+
+	if s.Set("", alice) == nil {
+		t.Errorf("expected error for empty key")
+	}
+	if s.Set("bob", nil) == nil {
+		t.Errorf("expected error for nil value")
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Package lmdblease protects environments opened with lmdb.NoLock from
+concurrent writers in different processes.
+
+With NoLock LMDB performs no locking at all and two processes writing at the
+same time silently corrupt the environment.  Applications which use NoLock
+across processes must coordinate writers themselves.  A Lease records an owner
+in a dedicated database and routes the owner's write transactions through
+Lease.Update, which fails fast when another owner holds the lease, and detects
+transactions committed by any other writer since the owner's last write by
+comparing transaction IDs.
+
+A Lease is a safety net for misconfiguration, not a lock.  It cannot prevent
+two processes which begin writing at the same instant from both proceeding,
+but it reports the conflict on the next write of either process.
+*/
+package lmdblease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultDBName is the name of the database holding the lease record when
+// Options.DBName is not set.
+const DefaultDBName = "lmdblease"
+
+// DefaultTTL is the lifetime of a lease when Options.TTL is not set.
+const DefaultTTL = 30 * time.Second
+
+// ErrReleased is returned by Lease.Update after Lease.Release.
+var ErrReleased = errors.New("lmdblease: lease released")
+
+var leaseKey = []byte("owner")
+
+// HeldError is returned when the lease is held by another owner.
+type HeldError struct {
+	Owner   string
+	Expires time.Time
+}
+
+func (err *HeldError) Error() string {
+	return fmt.Sprintf("lmdblease: write lease held by %s until %s", err.Owner, err.Expires.Format(time.RFC3339))
+}
+
+// ConflictError is returned by Lease.Update when a transaction was committed
+// by another writer since the last write through the lease.  The environment
+// may be corrupt and should be checked before writing continues.
+type ConflictError struct {
+	Expected int64 // ID of the last transaction committed through the lease
+	Found    int64 // ID of the last transaction committed in the environment
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("lmdblease: concurrent writer detected: last txnid %d, expected %d", err.Found, err.Expected)
+}
+
+// Options configure a Lease.
+type Options struct {
+	// DBName is the name of the database the lease record is stored in.
+	DBName string
+
+	// Owner identifies the holder of the lease.  The default is the host
+	// name and process ID.
+	Owner string
+
+	// TTL is how long the lease remains valid after each write.  A lease
+	// which has expired may be taken over by another owner.
+	TTL time.Duration
+}
+
+type record struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lease is a write lease on an environment.
+type Lease struct {
+	env  *lmdb.Env
+	dbi  lmdb.DBI
+	opt  Options
+	last int64
+}
+
+// Acquire takes the write lease on env.  Acquire returns a *HeldError if
+// another owner holds a lease which has not expired.
+func Acquire(env *lmdb.Env, opt *Options) (*Lease, error) {
+	l := &Lease{env: env}
+	if opt != nil {
+		l.opt = *opt
+	}
+	if l.opt.DBName == "" {
+		l.opt.DBName = DefaultDBName
+	}
+	if l.opt.TTL <= 0 {
+		l.opt.TTL = DefaultTTL
+	}
+	if l.opt.Owner == "" {
+		host, _ := os.Hostname()
+		l.opt.Owner = fmt.Sprintf("%s/%d", host, os.Getpid())
+	}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		l.dbi, err = txn.OpenDBI(l.opt.DBName, lmdb.Create)
+		if err != nil {
+			return err
+		}
+		return l.renew(txn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// renew checks that the lease is not held by another owner and extends it.
+// The ID of txn is remembered once it commits.
+func (l *Lease) renew(txn *lmdb.Txn) error {
+	v, err := txn.Get(l.dbi, leaseKey)
+	if err != nil && !lmdb.IsNotFound(err) {
+		return err
+	}
+	now := time.Now()
+	if err == nil {
+		var rec record
+		err = json.Unmarshal(v, &rec)
+		if err != nil {
+			return err
+		}
+		if rec.Owner != l.opt.Owner && now.Before(rec.Expires) {
+			return &HeldError{Owner: rec.Owner, Expires: rec.Expires}
+		}
+	}
+	v, err = json.Marshal(&record{Owner: l.opt.Owner, Expires: now.Add(l.opt.TTL)})
+	if err != nil {
+		return err
+	}
+	err = txn.Put(l.dbi, leaseKey, v, 0)
+	if err != nil {
+		return err
+	}
+	txn.OnCommit(func(id uintptr) { l.last = int64(id) })
+	return nil
+}
+
+// Update runs fn in a write transaction, like lmdb.Env.Update, after checking
+// that no other writer has committed since the last write through l and that
+// the lease is still held.  The lease is renewed by the transaction.
+func (l *Lease) Update(fn lmdb.TxnOp) error {
+	if l.last == 0 {
+		return ErrReleased
+	}
+	return l.env.Update(func(txn *lmdb.Txn) error {
+		if prev := int64(txn.ID()) - 1; prev != l.last {
+			return &ConflictError{Expected: l.last, Found: prev}
+		}
+		err := l.renew(txn)
+		if err != nil {
+			return err
+		}
+		return fn(txn)
+	})
+}
+
+// Release gives up the lease so that another owner may acquire it
+// immediately.
+func (l *Lease) Release() error {
+	if l.last == 0 {
+		return nil
+	}
+	err := l.env.Update(func(txn *lmdb.Txn) error {
+		v, err := txn.Get(l.dbi, leaseKey)
+		if err != nil {
+			return err
+		}
+		var rec record
+		err = json.Unmarshal(v, &rec)
+		if err != nil || rec.Owner != l.opt.Owner {
+			return err
+		}
+		return txn.Del(l.dbi, leaseKey, nil)
+	})
+	l.last = 0
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package lmdblease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestLease(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1, Flags: lmdb.NoLock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	}
+
+	a, err := Acquire(env, &Options{Owner: "a", TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Update(put)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Acquire(env, &Options{Owner: "b"})
+	if err, ok := err.(*HeldError); !ok || err.Owner != "a" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// a write which bypasses the lease is detected.
+	err = env.Update(put)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Update(put)
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = a.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Update(put)
+	if err != ErrReleased {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	b, err := Acquire(env, &Options{Owner: "b", TTL: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Update(put)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an expired lease may be taken over.
+	time.Sleep(2 * time.Millisecond)
+	_, err = Acquire(env, &Options{Owner: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Update(put)
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Package lmdbload provides a BulkLoader for efficiently importing large, sorted
+data sets into an LMDB database.
+
+Items given to a BulkLoader must be sorted according to the database's key
+order (and duplicate order for databases with the lmdb.DupSort flag).  Items
+are written with the lmdb.Append or lmdb.AppendDup flag in batches using
+lmdb.Txn.PutMany, and each batch of Options.ChunkSize items is committed in its
+own transaction so that no single transaction grows too large.  A batch that
+still fails with lmdb.TxnFull is split and retried in smaller transactions.
+
+Because each chunk is committed independently, a failed load leaves the items
+of all previously committed chunks in the database.  Applications requiring an
+all-or-nothing import should load into a fresh database and swap it in once
+the load succeeds.
+*/
+package lmdbload
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultChunkSize is the number of items committed per transaction when
+// Options.ChunkSize is not set.
+const DefaultChunkSize = 10000
+
+// Options configure a BulkLoader.
+type Options struct {
+	// ChunkSize is the number of items written in each transaction.
+	ChunkSize int
+
+	// DupSort must be set when loading into a database with the lmdb.DupSort
+	// flag.  Duplicate values for a key must be given in sorted order and are
+	// written with lmdb.AppendDup instead of lmdb.Append.
+	DupSort bool
+
+	// NoSync sets the lmdb.NoSync flag on the environment for the duration of
+	// the load.  The flag is cleared and the environment is synced by
+	// BulkLoader.Close.  Because environment flags are shared, other writers in
+	// the process also skip syncing while the load runs.
+	NoSync bool
+}
+
+// Source is a stream of items consumed by BulkLoader.Load.  Next returns io.EOF
+// when the stream is exhausted.  The slices returned by Next are copied and
+// may be reused by the Source.
+type Source interface {
+	Next() (key, val []byte, err error)
+}
+
+// BulkLoader writes sorted items into a database in chunked transactions.
+// A BulkLoader is not safe for concurrent use.
+type BulkLoader struct {
+	env   *lmdb.Env
+	dbi   lmdb.DBI
+	chunk int
+	flags uint
+
+	unsync bool // the loader set lmdb.NoSync and must clear it
+	closed bool
+
+	data  []byte
+	sizes []int
+	n     uint64
+	err   error
+
+	// commitFn replaces commit in tests.
+	commitFn func(pairs []lmdb.KV) error
+}
+
+// New returns a BulkLoader that writes to dbi in env.  Close must be called
+// after all items have been added to write any buffered items.
+func New(env *lmdb.Env, dbi lmdb.DBI, opt *Options) (*BulkLoader, error) {
+	l := &BulkLoader{
+		env:   env,
+		dbi:   dbi,
+		chunk: DefaultChunkSize,
+		flags: lmdb.Append,
+	}
+	if opt == nil {
+		return l, nil
+	}
+	if opt.ChunkSize > 0 {
+		l.chunk = opt.ChunkSize
+	}
+	if opt.DupSort {
+		l.flags = lmdb.AppendDup
+	}
+	if opt.NoSync {
+		flags, err := env.Flags()
+		if err != nil {
+			return nil, err
+		}
+		if flags&lmdb.NoSync == 0 {
+			err = env.SetFlags(lmdb.NoSync)
+			if err != nil {
+				return nil, err
+			}
+			l.unsync = true
+		}
+	}
+	return l, nil
+}
+
+// Add buffers an item and writes the buffered items once a full chunk has
+// been accumulated.  Add copies key and val.  After Add returns an error all
+// subsequent calls return the same error.
+func (l *BulkLoader) Add(key, val []byte) error {
+	if l.err != nil {
+		return l.err
+	}
+	if l.closed {
+		return fmt.Errorf("lmdbload: loader is closed")
+	}
+	l.data = append(l.data, key...)
+	l.data = append(l.data, val...)
+	l.sizes = append(l.sizes, len(key), len(val))
+	if len(l.sizes)/2 >= l.chunk {
+		return l.flush()
+	}
+	return nil
+}
+
+// Load adds all items from src to l.
+func (l *BulkLoader) Load(src Source) error {
+	for {
+		k, v, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = l.Add(k, v)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Count returns the number of items written to the database so far.  Buffered
+// items are not counted until they have been committed.
+func (l *BulkLoader) Count() uint64 {
+	return l.n
+}
+
+func (l *BulkLoader) flush() error {
+	if len(l.sizes) == 0 {
+		return nil
+	}
+
+	pairs := make([]lmdb.KV, len(l.sizes)/2)
+	var off int
+	for i := range pairs {
+		kn, vn := l.sizes[2*i], l.sizes[2*i+1]
+		pairs[i].Key = l.data[off : off+kn]
+		pairs[i].Val = l.data[off+kn : off+kn+vn]
+		off += kn + vn
+	}
+
+	err := l.write(pairs)
+	if err != nil {
+		l.err = err
+		return err
+	}
+
+	l.data = l.data[:0]
+	l.sizes = l.sizes[:0]
+	return nil
+}
+
+// write commits pairs.  If the transaction grows too large, which happens when
+// large values are loaded with a big Options.ChunkSize, the pairs are split in
+// halves which are committed in turn, and later chunks are made as small as
+// the halves.
+func (l *BulkLoader) write(pairs []lmdb.KV) error {
+	err := l.commit(pairs)
+	if !lmdb.IsErrno(err, lmdb.TxnFull) || len(pairs) < 2 {
+		if err == nil {
+			l.n += uint64(len(pairs))
+		}
+		return err
+	}
+	half := len(pairs) / 2
+	if l.chunk > half {
+		l.chunk = half
+	}
+	err = l.write(pairs[:half])
+	if err != nil {
+		return err
+	}
+	return l.write(pairs[half:])
+}
+
+// commit writes pairs in a single transaction.
+func (l *BulkLoader) commit(pairs []lmdb.KV) error {
+	if l.commitFn != nil {
+		return l.commitFn(pairs)
+	}
+	return l.env.Update(func(txn *lmdb.Txn) (err error) {
+		n, err := txn.PutMany(l.dbi, pairs, l.flags)
+		if lmdb.IsErrno(err, lmdb.KeyExist) {
+			return fmt.Errorf("lmdbload: item %d is out of order: %w", l.n+uint64(n), err)
+		}
+		return err
+	})
+}
+
+// Close writes any buffered items and restores the environment flags changed
+// by the loader.  If Options.NoSync was given Close syncs the environment to
+// disk.  Close returns the first error encountered by l.
+func (l *BulkLoader) Close() error {
+	if l.closed {
+		return l.err
+	}
+	l.closed = true
+
+	err := l.flush()
+	if l.unsync {
+		uerr := l.env.UnsetFlags(lmdb.NoSync)
+		if err == nil {
+			err = uerr
+		}
+		serr := l.env.Sync(true)
+		if err == nil {
+			err = serr
+		}
+	}
+	if l.err == nil {
+		l.err = err
+	}
+	return l.err
+}
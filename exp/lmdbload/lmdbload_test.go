@@ -0,0 +1,199 @@
+package lmdbload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+type sliceSource struct {
+	items [][2]string
+}
+
+func (s *sliceSource) Next() (key, val []byte, err error) {
+	if len(s.items) == 0 {
+		return nil, nil, io.EOF
+	}
+	item := s.items[0]
+	s.items = s.items[1:]
+	return []byte(item[0]), []byte(item[1]), nil
+}
+
+func TestBulkLoader(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &sliceSource{}
+	for i := 0; i < 25; i++ {
+		src.items = append(src.items, [2]string{fmt.Sprintf("k%03d", i), fmt.Sprintf("v%d", i)})
+	}
+
+	l, err := New(env, dbi, &Options{ChunkSize: 10, NoSync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags, err := env.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&lmdb.NoSync == 0 {
+		t.Errorf("NoSync not set during load")
+	}
+	err = l.Load(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Count() != 20 {
+		t.Errorf("unexpected count before close: %d", l.Count())
+	}
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Count() != 25 {
+		t.Errorf("unexpected count: %d", l.Count())
+	}
+	flags, err = env.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&lmdb.NoSync != 0 {
+		t.Errorf("NoSync not cleared after load")
+	}
+
+	stat, err := env.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Entries != 25 {
+		t.Errorf("unexpected entries: %d", stat.Entries)
+	}
+}
+
+func TestBulkLoader_unsorted(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := New(env, dbi, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "c", "b"} {
+		err = l.Add([]byte(k), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = l.Close()
+	var operr *lmdb.OpError
+	if !errors.As(err, &operr) || !lmdb.IsErrno(operr, lmdb.KeyExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "item 2") {
+		t.Errorf("error does not identify item: %v", err)
+	}
+}
+
+func TestBulkLoader_dupSort(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "dup", lmdb.Create|lmdb.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := New(env, dbi, &Options{ChunkSize: 2, DupSort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range [][2]string{{"a", "1"}, {"a", "2"}, {"a", "3"}, {"b", "1"}} {
+		err = l.Add([]byte(item[0]), []byte(item[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Count() != 4 {
+		t.Errorf("unexpected count: %d", l.Count())
+	}
+}
+
+func TestBulkLoader_splitTxnFull(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := New(env, dbi, &Options{ChunkSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batches []int
+	var keys []string
+	l.commitFn = func(pairs []lmdb.KV) error {
+		if len(pairs) > 3 {
+			return &lmdb.OpError{Op: "mdb_txn_commit", Errno: lmdb.TxnFull}
+		}
+		batches = append(batches, len(pairs))
+		for _, p := range pairs {
+			keys = append(keys, string(p.Key))
+		}
+		return nil
+	}
+	for i := 0; i < 16; i++ {
+		err = l.Add([]byte(fmt.Sprintf("k%02d", i)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Count() != 16 {
+		t.Errorf("unexpected count: %d", l.Count())
+	}
+	if fmt.Sprint(batches) != "[2 3 2 3 2 2 2]" {
+		t.Errorf("unexpected batches: %v", batches)
+	}
+	for i, k := range keys {
+		if k != fmt.Sprintf("k%02d", i) {
+			t.Fatalf("items out of order: %v", keys)
+		}
+	}
+}
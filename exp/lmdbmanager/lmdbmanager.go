@@ -0,0 +1,424 @@
+/*
+Package lmdbmanager manages many LMDB environments on behalf of an
+application, such as one environment per tenant or per zone.
+
+A Manager opens environments lazily on first use and hands them out with a
+reference count, so that it knows which environments are in use.  Its
+periodic checks, run by Start or by calling Check, close environments which
+have been idle for Options.IdleTimeout, grow the map of idle environments
+according to Options.Resize and clear stale reader slots.  Stats aggregates
+the state of all environments and Shutdown closes them once their users have
+released them.
+
+	m, err := lmdbmanager.New(&lmdbmanager.Options{
+		Open: func(name string) (*lmdb.Env, error) {
+			return lmdbdsn.Open("lmdb:///var/db/zones/" + name + "?mapsize=64MiB&maxdbs=4")
+		},
+		IdleTimeout: 10 * time.Minute,
+		Resize:      lmdbmanager.Grow(0.8, 2),
+	})
+	if err != nil {
+		return err
+	}
+	m.Start(time.Minute, func(err error) { log.Print(err) })
+	defer m.Shutdown(context.Background())
+
+	err = m.View("example.com", func(txn *lmdb.Txn) error {
+		// ...
+	})
+
+Environments must only be used between Acquire and the release of the
+environment, or within View and Update, so that the Manager never closes or
+resizes an environment with active transactions.
+*/
+package lmdbmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrClosed is returned when an environment is acquired from a Manager which
+// is shutting down.
+var ErrClosed = errors.New("lmdbmanager: manager is shut down")
+
+// ResizeFunc is a map size policy.  It returns the new map size of an idle
+// environment given its current map size and the bytes used by its pages, and
+// false to keep the current size.
+type ResizeFunc func(name string, mapSize, used int64) (int64, bool)
+
+// Grow returns a ResizeFunc which multiplies the map size by factor when more
+// than threshold, a fraction of the map size, is used.
+func Grow(threshold, factor float64) ResizeFunc {
+	return func(name string, mapSize, used int64) (int64, bool) {
+		if float64(used) <= threshold*float64(mapSize) {
+			return 0, false
+		}
+		return int64(float64(mapSize) * factor), true
+	}
+}
+
+// Options configure a Manager.
+type Options struct {
+	// Open opens the environment called name.  Open is required.
+	// lmdbdsn.Open is convenient for building the environment from a
+	// configuration string.
+	Open func(name string) (*lmdb.Env, error)
+
+	// IdleTimeout is how long an environment stays open after it was last
+	// released.  Idle environments are closed by Check.  If zero,
+	// environments stay open until Shutdown.
+	IdleTimeout time.Duration
+
+	// Resize is the map size policy applied by Check to idle environments.
+	// If nil, map sizes are left alone.
+	Resize ResizeFunc
+}
+
+// EnvStats describes an environment known to a Manager.
+type EnvStats struct {
+	Name     string
+	Open     bool
+	Refs     int       // Number of users holding the environment
+	LastUsed time.Time // Time the environment was last released
+
+	// Fields of open environments.
+	MapSize int64 // Size of the memory map
+	Used    int64 // Bytes used by the environment's pages
+	Readers uint  // Reader slots used
+
+	Opens        int // Times the environment was opened
+	IdleCloses   int // Times the environment was closed for being idle
+	Resizes      int // Times the map size was changed by Options.Resize
+	StaleReaders int // Stale reader slots cleared by Check
+}
+
+// Stats aggregates the EnvStats of a Manager.  The counters and sizes are
+// totals over all environments.
+type Stats struct {
+	Envs []EnvStats // Sorted by name
+	Open int        // Number of open environments
+	Used int        // Number of environments held by users
+
+	MapSize      int64
+	UsedBytes    int64
+	Opens        int
+	IdleCloses   int
+	Resizes      int
+	StaleReaders int
+}
+
+// entry is an environment known to a Manager.  An entry with refs but no env
+// is being opened.
+type entry struct {
+	env   *lmdb.Env
+	stats EnvStats
+}
+
+// Manager owns a set of environments identified by name.  A Manager is safe
+// for concurrent use.
+type Manager struct {
+	opt    Options
+	mu     sync.Mutex
+	cond   *sync.Cond
+	envs   map[string]*entry
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// New returns a Manager which has no open environments.
+func New(opt *Options) (*Manager, error) {
+	if opt == nil || opt.Open == nil {
+		return nil, errors.New("lmdbmanager: Options.Open is required")
+	}
+	m := &Manager{
+		opt:  *opt,
+		envs: make(map[string]*entry),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m, nil
+}
+
+// Acquire returns the environment called name, opening it if necessary, and
+// a function releasing it.  The environment must not be used after release
+// is called, and release must be called exactly once.
+func (m *Manager) Acquire(name string) (env *lmdb.Env, release func(), err error) {
+	m.mu.Lock()
+	e := m.envs[name]
+	for e != nil && e.env == nil && e.stats.Refs > 0 {
+		// Another goroutine is opening the environment.
+		m.cond.Wait()
+		e = m.envs[name]
+	}
+	if m.closed {
+		m.mu.Unlock()
+		return nil, nil, ErrClosed
+	}
+	if e == nil {
+		e = &entry{stats: EnvStats{Name: name}}
+		m.envs[name] = e
+	}
+	e.stats.Refs++
+	if e.env != nil {
+		m.mu.Unlock()
+		return e.env, m.releaseFunc(e), nil
+	}
+
+	m.mu.Unlock()
+	env, err = m.opt.Open(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.cond.Broadcast()
+	if err != nil {
+		e.stats.Refs--
+		return nil, nil, fmt.Errorf("lmdbmanager: env %q: %w", name, err)
+	}
+	e.env = env
+	e.stats.Open = true
+	e.stats.Opens++
+	return env, m.releaseFunc(e), nil
+}
+
+func (m *Manager) releaseFunc(e *entry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			e.stats.Refs--
+			e.stats.LastUsed = time.Now()
+			m.cond.Broadcast()
+			m.mu.Unlock()
+		})
+	}
+}
+
+// View runs fn in a read-only transaction on the environment called name.
+func (m *Manager) View(name string, fn lmdb.TxnOp) error {
+	env, release, err := m.Acquire(name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return env.View(fn)
+}
+
+// Update runs fn in a write transaction on the environment called name.
+func (m *Manager) Update(name string, fn lmdb.TxnOp) error {
+	env, release, err := m.Acquire(name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return env.Update(fn)
+}
+
+// Check clears the stale reader slots of the open environments, closes those
+// idle for longer than Options.IdleTimeout and resizes the remaining idle
+// ones according to Options.Resize.  Check returns the first error
+// encountered after checking all environments.
+func (m *Manager) Check() error {
+	var first error
+	report := func(name string, err error) {
+		if err != nil && first == nil {
+			first = fmt.Errorf("lmdbmanager: env %q: %w", name, err)
+		}
+	}
+
+	now := time.Now()
+	var idle []*lmdb.Env
+	var idleNames []string
+	m.mu.Lock()
+	for name, e := range m.envs {
+		if e.env == nil {
+			continue
+		}
+		n, err := e.env.ReaderCheck()
+		e.stats.StaleReaders += n
+		report(name, err)
+		if e.stats.Refs > 0 {
+			continue
+		}
+		if m.opt.IdleTimeout > 0 && now.Sub(e.stats.LastUsed) >= m.opt.IdleTimeout {
+			idle = append(idle, e.env)
+			idleNames = append(idleNames, name)
+			e.env = nil
+			e.stats.Open = false
+			e.stats.IdleCloses++
+			continue
+		}
+		if m.opt.Resize != nil {
+			report(name, m.resize(name, e))
+		}
+	}
+	m.mu.Unlock()
+
+	for i, env := range idle {
+		report(idleNames[i], env.Close())
+	}
+	return first
+}
+
+// resize applies the map size policy to the idle environment of e.  Holding
+// the lock of the Manager keeps the environment idle meanwhile.
+func (m *Manager) resize(name string, e *entry) error {
+	mapSize, used, err := usage(e.env)
+	if err != nil {
+		return err
+	}
+	size, ok := m.opt.Resize(name, mapSize, used)
+	if !ok || size == mapSize {
+		return nil
+	}
+	err = e.env.SetMapSize(size)
+	if err != nil {
+		return err
+	}
+	e.stats.Resizes++
+	return nil
+}
+
+// usage returns the map size of env and the bytes used by its pages.
+func usage(env *lmdb.Env) (mapSize, used int64, err error) {
+	info, err := env.Info()
+	if err != nil {
+		return 0, 0, err
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.MapSize, (info.LastPNO + 1) * int64(stat.PSize), nil
+}
+
+// Start runs Check every interval in a goroutine until Stop or Shutdown is
+// called.  Errors returned by Check are passed to errfn if it is not nil.
+func (m *Manager) Start(interval time.Duration, errfn func(error)) error {
+	if m.stop != nil {
+		return errors.New("lmdbmanager: checks already started")
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			err := m.Check()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start and waits for it to exit.
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+	m.done = nil
+}
+
+// Stats returns the state of all environments the Manager has opened.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var s Stats
+	for _, e := range m.envs {
+		es := e.stats
+		if e.env != nil {
+			es.MapSize, es.Used, _ = usage(e.env)
+			info, err := e.env.Info()
+			if err == nil {
+				es.Readers = info.NumReaders
+			}
+			s.Open++
+		}
+		if es.Refs > 0 {
+			s.Used++
+		}
+		s.MapSize += es.MapSize
+		s.UsedBytes += es.Used
+		s.Opens += es.Opens
+		s.IdleCloses += es.IdleCloses
+		s.Resizes += es.Resizes
+		s.StaleReaders += es.StaleReaders
+		s.Envs = append(s.Envs, es)
+	}
+	sort.Slice(s.Envs, func(i, j int) bool { return s.Envs[i].Name < s.Envs[j].Name })
+	return s
+}
+
+// Shutdown stops the periodic checks, rejects further acquisitions with
+// ErrClosed and closes every environment once it has been released.  If ctx
+// is done first Shutdown returns its error, leaving the environments still in
+// use open.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.Stop()
+
+	// Wake up the loop below when ctx is done.
+	returned := make(chan struct{})
+	defer close(returned)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			m.cond.Broadcast()
+			m.mu.Unlock()
+		case <-returned:
+		}
+	}()
+
+	m.mu.Lock()
+	m.closed = true
+
+	var err error
+	for {
+		for _, e := range m.envs {
+			if e.env != nil && e.stats.Refs == 0 {
+				cerr := e.env.Close()
+				if err == nil && cerr != nil {
+					err = fmt.Errorf("lmdbmanager: env %q: %w", e.stats.Name, cerr)
+				}
+				e.env = nil
+				e.stats.Open = false
+			}
+		}
+		if !m.inUse() {
+			break
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		m.cond.Wait()
+	}
+	m.mu.Unlock()
+	return err
+}
+
+// inUse returns true if any environment is acquired.
+func (m *Manager) inUse() bool {
+	for _, e := range m.envs {
+		if e.stats.Refs > 0 {
+			return true
+		}
+	}
+	return false
+}
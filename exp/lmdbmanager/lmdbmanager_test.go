@@ -0,0 +1,151 @@
+package lmdbmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func newManager(t *testing.T, opt *Options) *Manager {
+	dir, err := ioutil.TempDir("", "lmdbmanager-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	opt.Open = func(name string) (*lmdb.Env, error) {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(path, 0755)
+		if err != nil {
+			return nil, err
+		}
+		env, err := lmdb.NewEnv()
+		if err != nil {
+			return nil, err
+		}
+		err = env.SetMapSize(1 << 20)
+		if err == nil {
+			err = env.Open(path, 0, 0644)
+		}
+		if err != nil {
+			env.Close()
+			return nil, err
+		}
+		return env, nil
+	}
+	m, err := New(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func put(m *Manager, name string, n int) error {
+	return m.Update(name, func(txn *lmdb.Txn) error {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			err = txn.Put(dbi, []byte{byte(i >> 8), byte(i)}, make([]byte, 512), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestManager(t *testing.T) {
+	m := newManager(t, &Options{IdleTimeout: time.Nanosecond, Resize: Grow(0.5, 2)})
+
+	for _, name := range []string{"a", "b"} {
+		err := put(m, name, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, release, err := m.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := m.Stats()
+	if s.Open != 2 || s.Used != 1 || s.Opens != 2 || len(s.Envs) != 2 || s.Envs[0].Name != "a" {
+		t.Errorf("stats %+v", s)
+	}
+
+	// b is idle and closed, a is in use.
+	err = m.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = m.Stats()
+	if s.Open != 1 || !s.Envs[0].Open || s.Envs[1].Open || s.IdleCloses != 1 {
+		t.Errorf("stats after check %+v", s)
+	}
+	release()
+	release()
+	if m.Stats().Used != 0 {
+		t.Errorf("release called twice")
+	}
+
+	// Reopened on use.  Filling more than half of the map grows it once b
+	// is idle again.
+	err = put(m, "b", 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.opt.IdleTimeout = 0
+	err = m.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = m.Stats()
+	b := s.Envs[1]
+	if !b.Open || b.Opens != 2 || b.Resizes != 1 || b.MapSize != 2<<20 {
+		t.Errorf("stats of b %+v", b)
+	}
+
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Stats().Open != 0 {
+		t.Errorf("environments open after shutdown")
+	}
+	err = m.View("a", func(*lmdb.Txn) error { return nil })
+	if err != ErrClosed {
+		t.Errorf("view after shutdown: %v", err)
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	m := newManager(t, &Options{})
+	_, release, err := m.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("shutdown with environment in use: %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Stats().Open != 0 {
+		t.Errorf("environment open after shutdown")
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Package lmdbmetrics observes the commit sizes and sync times of an environment
+into histograms, the two distributions that drive most durability and
+throughput tuning.
+
+A Metrics receives the lmdb.CommitStats of every write transaction committed
+on the environment and observes the bytes and items written and the time
+taken by the commit, which includes flushing to disk unless the environment
+has the NoSync flag.  Explicit flushes are observed when made through
+Metrics.Sync instead of lmdb.Env.Sync.
+
+The histograms follow the Prometheus model, cumulative counts of observations
+at or below each bucket bound plus their sum and count, and WritePrometheus
+writes them in the Prometheus text format.  To keep lmdb-go free of
+dependencies the package does not import a Prometheus client, an application
+using one can read Histogram.Snapshot in a collector instead.
+*/
+package lmdbmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultSizeBuckets are the bounds of the byte and item histograms when
+// Options.SizeBuckets is not set, powers of 4 from 1 to 4^15 (1 GiB).
+var DefaultSizeBuckets = expBuckets(1, 4, 16)
+
+// DefaultTimeBuckets are the bounds of the duration histograms in seconds
+// when Options.TimeBuckets is not set, powers of 2 from 64µs to 4s.
+var DefaultTimeBuckets = expBuckets(64e-6, 2, 17)
+
+func expBuckets(start, factor float64, n int) []float64 {
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = start
+		start *= factor
+	}
+	return b
+}
+
+// Options configure a Metrics.
+type Options struct {
+	// SizeBuckets are the upper bounds of the buckets of the CommitBytes and
+	// CommitItems histograms.
+	SizeBuckets []float64
+
+	// TimeBuckets are the upper bounds, in seconds, of the buckets of the
+	// CommitSeconds and SyncSeconds histograms.
+	TimeBuckets []float64
+}
+
+// Metrics holds the histograms of an environment.
+type Metrics struct {
+	env *lmdb.Env
+
+	CommitBytes   *Histogram // Bytes of the keys and values written per commit
+	CommitItems   *Histogram // Items written and deleted per commit
+	CommitSeconds *Histogram // Time taken by mdb_txn_commit
+	SyncSeconds   *Histogram // Time taken by Sync
+}
+
+// New returns a Metrics observing the commits of env.  New sets the commit
+// statistics function of env with lmdb.Env.SetCommitStats, replacing any set
+// before.  An application that needs its own function must call ObserveCommit
+// from it.  New must not be called concurrently with write transactions.
+func New(env *lmdb.Env, opt *Options) *Metrics {
+	if opt == nil {
+		opt = &Options{}
+	}
+	sizes, times := opt.SizeBuckets, opt.TimeBuckets
+	if len(sizes) == 0 {
+		sizes = DefaultSizeBuckets
+	}
+	if len(times) == 0 {
+		times = DefaultTimeBuckets
+	}
+	m := &Metrics{
+		env:           env,
+		CommitBytes:   NewHistogram(sizes),
+		CommitItems:   NewHistogram(sizes),
+		CommitSeconds: NewHistogram(times),
+		SyncSeconds:   NewHistogram(times),
+	}
+	env.SetCommitStats(m.ObserveCommit)
+	return m
+}
+
+// ObserveCommit observes the size and duration of a committed transaction.
+func (m *Metrics) ObserveCommit(s *lmdb.CommitStats) {
+	m.CommitBytes.Observe(float64(s.PutBytes))
+	m.CommitItems.Observe(float64(s.Puts + s.Dels))
+	m.CommitSeconds.Observe(s.Duration.Seconds())
+}
+
+// Sync calls lmdb.Env.Sync and observes the time it took if it succeeded.
+func (m *Metrics) Sync(force bool) error {
+	start := time.Now()
+	err := m.env.Sync(force)
+	if err == nil {
+		m.SyncSeconds.Observe(time.Since(start).Seconds())
+	}
+	return err
+}
+
+// WritePrometheus writes the histograms of m to w in the Prometheus text
+// format, with metric names starting with prefix, for example "lmdb_".
+func (m *Metrics) WritePrometheus(w io.Writer, prefix string) error {
+	bw := bufio.NewWriter(w)
+	for _, h := range []struct {
+		name string
+		help string
+		h    *Histogram
+	}{
+		{"commit_bytes", "Bytes of the keys and values written per commit.", m.CommitBytes},
+		{"commit_items", "Items written and deleted per commit.", m.CommitItems},
+		{"commit_seconds", "Time taken to commit write transactions.", m.CommitSeconds},
+		{"sync_seconds", "Time taken to flush the environment to disk.", m.SyncSeconds},
+	} {
+		h.h.Snapshot().writePrometheus(bw, prefix+h.name, h.help)
+	}
+	return bw.Flush()
+}
+
+// Histogram counts observations in buckets.  A Histogram is safe for
+// concurrent use.
+type Histogram struct {
+	bounds []float64
+
+	mu     sync.Mutex
+	counts []uint64 // Observations per bucket, the last one is +Inf
+	sum    float64
+}
+
+// NewHistogram returns a histogram with buckets of the given upper bounds.
+// The bounds are sorted, a last bucket of +Inf is implied.
+func NewHistogram(bounds []float64) *Histogram {
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{bounds: b, counts: make([]uint64, len(b)+1)}
+}
+
+// Observe adds v to h.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// HistogramSnapshot is the state of a Histogram.
+type HistogramSnapshot struct {
+	Bounds []float64 // Upper bounds of the buckets, without +Inf
+	Counts []uint64  // Cumulative count of observations at or below each bound
+	Count  uint64    // Observations
+	Sum    float64   // Sum of the observations
+}
+
+// Snapshot returns the current state of h.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.bounds)),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, n := range h.counts {
+		s.Count += n
+		if i < len(s.Counts) {
+			s.Counts[i] = s.Count
+		}
+	}
+	s.Sum = h.sum
+	return s
+}
+
+func (s HistogramSnapshot) writePrometheus(w *bufio.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, b := range s.Bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatFloat(b), s.Counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, s.Count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(s.Sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, s.Count)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
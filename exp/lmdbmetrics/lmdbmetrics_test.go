@@ -0,0 +1,84 @@
+package lmdbmetrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{10, 1})
+	for _, v := range []float64{0.5, 1, 2, 20} {
+		h.Observe(v)
+	}
+	s := h.Snapshot()
+	if s.Count != 4 || s.Sum != 23.5 {
+		t.Errorf("count %d sum %v", s.Count, s.Sum)
+	}
+	if len(s.Counts) != 2 || s.Counts[0] != 2 || s.Counts[1] != 3 {
+		t.Errorf("bounds %v counts %v", s.Bounds, s.Counts)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	m := New(env, &Options{SizeBuckets: []float64{4, 16}})
+	err = env.Update(func(txn *lmdb.Txn) error {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi, []byte("k1"), []byte("v1"), 0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("k2"), []byte("v2"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Sync(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := m.CommitBytes.Snapshot(); s.Count != 1 || s.Sum != 8 || s.Counts[0] != 0 || s.Counts[1] != 1 {
+		t.Errorf("commit bytes %+v", s)
+	}
+	if s := m.CommitItems.Snapshot(); s.Count != 1 || s.Sum != 2 || s.Counts[0] != 1 {
+		t.Errorf("commit items %+v", s)
+	}
+	if s := m.CommitSeconds.Snapshot(); s.Count != 1 {
+		t.Errorf("commit seconds %+v", s)
+	}
+	if s := m.SyncSeconds.Snapshot(); s.Count != 1 || s.Sum <= 0 {
+		t.Errorf("sync seconds %+v", s)
+	}
+
+	var buf bytes.Buffer
+	err = m.WritePrometheus(&buf, "lmdb_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE lmdb_commit_bytes histogram",
+		`lmdb_commit_bytes_bucket{le="4"} 0`,
+		`lmdb_commit_bytes_bucket{le="16"} 1`,
+		`lmdb_commit_bytes_bucket{le="+Inf"} 1`,
+		"lmdb_commit_bytes_sum 8",
+		"lmdb_commit_items_count 1",
+		"lmdb_sync_seconds_count 1",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, buf.String())
+		}
+	}
+}
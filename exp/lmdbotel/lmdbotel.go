@@ -0,0 +1,183 @@
+/*
+Package lmdbotel traces the transactions of an environment, for services that
+report the time spent in their datastore through distributed tracing such as
+OpenTelemetry.
+
+An Env runs each View, Update and UpdateRetry in a span named after the
+method, ending when the transaction has terminated.  The span carries the
+operation counts of the transaction from lmdb.Txn.Stats, the bytes written,
+the time taken to commit, the number of retries and any attributes passed by
+the caller, such as the database used:
+
+	err := env.Update(ctx, func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, key, val, 0)
+	}, lmdbotel.DBI("users"))
+
+To keep lmdb-go free of dependencies the package does not import
+OpenTelemetry.  It defines the small Tracer and Span interfaces instead, which
+an application implements on top of its tracer in a few lines:
+
+	type otelTracer struct{ trace.Tracer }
+
+	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, lmdbotel.Span) {
+		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+		return ctx, otelSpan{span}
+	}
+
+	type otelSpan struct{ trace.Span }
+
+	func (s otelSpan) SetAttributes(attrs ...lmdbotel.Attribute) {
+		for _, a := range attrs {
+			s.Span.SetAttributes(attribute.String(a.Key, fmt.Sprint(a.Value)))
+		}
+	}
+
+	func (s otelSpan) RecordError(err error) {
+		s.Span.RecordError(err)
+		s.Span.SetStatus(codes.Error, err.Error())
+	}
+
+	func (s otelSpan) End() { s.Span.End() }
+
+The context passed to the methods of Env only parents the span; the
+transaction itself cannot be cancelled.
+*/
+package lmdbotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Keys of the attributes set on spans.  Values are int64 except for those of
+// KeySystem and KeyDBI, which are strings.
+const (
+	KeySystem        = "db.system"          // Always "lmdb"
+	KeyDBI           = "lmdb.dbi"           // Set by the caller with DBI
+	KeyGets          = "lmdb.gets"          // lmdb.TxnStats.Gets
+	KeyPuts          = "lmdb.puts"          // lmdb.TxnStats.Puts
+	KeyDels          = "lmdb.dels"          // lmdb.TxnStats.Dels
+	KeyCursorOps     = "lmdb.cursor_ops"    // lmdb.TxnStats.CursorOps
+	KeyBytesWritten  = "lmdb.bytes_written" // lmdb.TxnStats.PutBytes
+	KeyCommitLatency = "lmdb.commit_us"     // Microseconds taken to commit
+	KeyRetries       = "lmdb.retries"       // Attempts after the first, with UpdateRetry
+	KeyTxnID         = "lmdb.txn_id"        // ID of the committed transaction
+)
+
+// Attribute is a key-value pair set on a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// DBI returns the attribute naming the database used by a transaction.
+func DBI(name string) Attribute {
+	return Attribute{Key: KeyDBI, Value: name}
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+
+	// RecordError marks the span as failed with err.
+	RecordError(err error)
+
+	End()
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any, and returns
+	// a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Env is an lmdb.Env whose transactions run through View, Update and
+// UpdateRetry are traced.  The methods of the embedded lmdb.Env are not
+// traced.
+type Env struct {
+	*lmdb.Env
+	tracer Tracer
+}
+
+// New returns an Env tracing the transactions of env with tracer.
+func New(env *lmdb.Env, tracer Tracer) *Env {
+	return &Env{Env: env, tracer: tracer}
+}
+
+// View is like lmdb.Env.View, in a span named "lmdb.View" carrying attrs.
+func (e *Env) View(ctx context.Context, fn lmdb.TxnOp, attrs ...Attribute) error {
+	return e.trace(ctx, "lmdb.View", attrs, func(fn lmdb.TxnOp) error {
+		return e.Env.View(fn)
+	}, fn)
+}
+
+// Update is like lmdb.Env.Update, in a span named "lmdb.Update" carrying
+// attrs.
+func (e *Env) Update(ctx context.Context, fn lmdb.TxnOp, attrs ...Attribute) error {
+	return e.trace(ctx, "lmdb.Update", attrs, func(fn lmdb.TxnOp) error {
+		return e.Env.Update(fn)
+	}, fn)
+}
+
+// UpdateRetry is like lmdb.Env.UpdateRetry, in a span named
+// "lmdb.UpdateRetry" carrying attrs.  The span covers all attempts and
+// reports the statistics of the last.
+func (e *Env) UpdateRetry(ctx context.Context, policy *lmdb.RetryPolicy, fn lmdb.TxnOp, attrs ...Attribute) error {
+	return e.trace(ctx, "lmdb.UpdateRetry", attrs, func(fn lmdb.TxnOp) error {
+		return e.Env.UpdateRetry(policy, fn)
+	}, fn)
+}
+
+// trace calls run with a TxnOp wrapping fn in a span.
+func (e *Env) trace(ctx context.Context, name string, attrs []Attribute, run func(lmdb.TxnOp) error, fn lmdb.TxnOp) error {
+	_, span := e.tracer.Start(ctx, name)
+	defer span.End()
+
+	var (
+		attempts  int
+		stats     lmdb.TxnStats
+		returned  time.Time
+		txnID     uintptr
+		committed bool
+	)
+	err := run(func(txn *lmdb.Txn) error {
+		attempts++
+		committed = false
+		txn.OnCommit(func(id uintptr) {
+			committed = true
+			txnID = id
+		})
+		err := fn(txn)
+		stats = txn.Stats()
+		returned = time.Now()
+		return err
+	})
+
+	a := make([]Attribute, 0, len(attrs)+9)
+	a = append(a, Attribute{KeySystem, "lmdb"})
+	a = append(a, attrs...)
+	a = append(a,
+		Attribute{KeyGets, int64(stats.Gets)},
+		Attribute{KeyPuts, int64(stats.Puts)},
+		Attribute{KeyDels, int64(stats.Dels)},
+		Attribute{KeyCursorOps, int64(stats.CursorOps)},
+		Attribute{KeyBytesWritten, int64(stats.PutBytes)},
+	)
+	if attempts > 1 {
+		a = append(a, Attribute{KeyRetries, int64(attempts - 1)})
+	}
+	if committed && err == nil {
+		a = append(a,
+			Attribute{KeyCommitLatency, time.Since(returned).Microseconds()},
+			Attribute{KeyTxnID, int64(txnID)},
+		)
+	}
+	span.SetAttributes(a...)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
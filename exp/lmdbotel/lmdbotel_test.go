@@ -0,0 +1,115 @@
+package lmdbotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &testSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func TestEnv(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := &testTracer{}
+	tenv := New(env, tracer)
+	ctx := context.Background()
+
+	err = tenv.Update(ctx, func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("key"), []byte("value"), 0)
+	}, DBI("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := tracer.spans[0]
+	if s.name != "lmdb.Update" || !s.ended || s.err != nil {
+		t.Errorf("unexpected span: %+v", s)
+	}
+	for k, v := range map[string]interface{}{
+		KeySystem:       "lmdb",
+		KeyDBI:          "root",
+		KeyPuts:         int64(1),
+		KeyBytesWritten: int64(8),
+	} {
+		if s.attrs[k] != v {
+			t.Errorf("attribute %s = %v (!= %v)", k, s.attrs[k], v)
+		}
+	}
+	if _, ok := s.attrs[KeyCommitLatency]; !ok {
+		t.Errorf("no commit latency")
+	}
+	if _, ok := s.attrs[KeyRetries]; ok {
+		t.Errorf("retries reported without a retry")
+	}
+
+	errTest := errors.New("test")
+	err = tenv.View(ctx, func(txn *lmdb.Txn) error {
+		_, err := txn.Get(dbi, []byte("key"))
+		if err != nil {
+			return err
+		}
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s = tracer.spans[1]
+	if s.name != "lmdb.View" || s.err != errTest || s.attrs[KeyGets] != int64(1) {
+		t.Errorf("unexpected span: %+v", s)
+	}
+	if _, ok := s.attrs[KeyTxnID]; ok {
+		t.Errorf("transaction ID of a failed transaction reported")
+	}
+
+	attempt := 0
+	policy := &lmdb.RetryPolicy{Split: func() bool { return true }}
+	err = tenv.UpdateRetry(ctx, policy, func(txn *lmdb.Txn) error {
+		attempt++
+		if attempt < 3 {
+			return &lmdb.OpError{Op: "mdb_put", Errno: lmdb.TxnFull}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = tracer.spans[2]
+	if s.attrs[KeyRetries] != int64(2) {
+		t.Errorf("retries %v (!= 2)", s.attrs[KeyRetries])
+	}
+}
@@ -0,0 +1,198 @@
+package lmdbpages
+
+import (
+	"fmt"
+)
+
+// maxUnaccounted is the number of pages missing from the trees and the free
+// list which Check lists.
+const maxUnaccounted = 10
+
+// CheckResult is the outcome of Check.
+type CheckResult struct {
+	TxnID     uint64 // Transaction of the checked snapshot
+	Pages     uint64 // Pages in use, up to the last page of the snapshot
+	TreePages uint64 // Branch, leaf and overflow pages of all databases and the free list
+	FreePages uint64 // Pages on the free list
+	Problems  []string
+}
+
+// OK returns true if Check found no problem.
+func (r *CheckResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check walks every database of the last committed snapshot, including the
+// named databases, the sub-databases of DupSort databases and the free list.
+// It checks the headers of the pages visited and the page counts recorded for
+// each database, and that every page up to the last page of the snapshot is
+// either a meta page, a page of exactly one database or on the free list,
+// like mdb_audit in the C library.
+//
+// Check returns an error only if the data file has no valid meta page or
+// cannot be read.  Inconsistencies are listed in the Problems of the result.
+func (f *File) Check() (*CheckResult, error) {
+	m, err := f.Current()
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{
+		f:   f,
+		m:   m,
+		res: &CheckResult{TxnID: m.TxnID, Pages: m.LastPage + 1},
+	}
+	n := m.LastPage + 1
+	if n > f.NumPages() {
+		c.problem("data file has %d pages but pages up to %d are in use", f.NumPages(), m.LastPage)
+		n = f.NumPages()
+	}
+	c.seen = make([]uint64, (n+63)/64)
+
+	c.tree("free list", m.Free)
+	c.tree("main database", m.Main)
+	c.freelist()
+
+	if used := NumMetas + c.res.TreePages + c.res.FreePages; used != c.res.Pages {
+		var missing []uint64
+		for pgno := uint64(NumMetas); pgno < n && len(missing) < maxUnaccounted; pgno++ {
+			if !c.isSeen(pgno) {
+				missing = append(missing, pgno)
+			}
+		}
+		c.problem("%d pages in use but %d accounted for, missing pages include %v", c.res.Pages, used, missing)
+	}
+	return c.res, nil
+}
+
+type checker struct {
+	f    *File
+	m    *Meta
+	res  *CheckResult
+	seen []uint64 // bitset of the pages accounted for
+}
+
+func (c *checker) problem(format string, args ...interface{}) {
+	c.res.Problems = append(c.res.Problems, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) isSeen(pgno uint64) bool {
+	return pgno/64 < uint64(len(c.seen)) && c.seen[pgno/64]&(1<<(pgno%64)) != 0
+}
+
+// use accounts for the n pages starting at pgno, which belong to owner.  It
+// returns false if they cannot be used.
+func (c *checker) use(owner string, pgno, n uint64) bool {
+	if pgno < NumMetas || n == 0 || pgno > c.m.LastPage || n > c.m.LastPage+1-pgno {
+		c.problem("%s references pages %d-%d outside of the pages in use (%d-%d)", owner, pgno, pgno+n-1, NumMetas, c.m.LastPage)
+		return false
+	}
+	for i := pgno; i < pgno+n; i++ {
+		if c.isSeen(i) {
+			c.problem("page %d of %s is used more than once", i, owner)
+			return false
+		}
+	}
+	for i := pgno; i < pgno+n && i/64 < uint64(len(c.seen)); i++ {
+		c.seen[i/64] |= 1 << (i % 64)
+	}
+	return true
+}
+
+// counts are the pages of a database.
+type counts struct {
+	branch, leaf, overflow uint64
+}
+
+// tree checks the tree of db and its sub-databases.
+func (c *checker) tree(name string, db DB) {
+	if db.Root == InvalidPage {
+		if db.Depth != 0 || db.Entries != 0 {
+			c.problem("%s has no root page but depth %d and %d entries", name, db.Depth, db.Entries)
+		}
+		return
+	}
+	var n counts
+	c.page(name, db, db.Root, 1, &n)
+	if n.branch != db.BranchPages || n.leaf != db.LeafPages || n.overflow != db.OverflowPages {
+		c.problem("%s has %d branch, %d leaf and %d overflow pages but records %d, %d and %d",
+			name, n.branch, n.leaf, n.overflow, db.BranchPages, db.LeafPages, db.OverflowPages)
+	}
+	c.res.TreePages += n.branch + n.leaf + n.overflow
+}
+
+func (c *checker) page(name string, db DB, pgno uint64, depth int, n *counts) {
+	if !c.use(name, pgno, 1) {
+		return
+	}
+	p, err := c.f.Page(pgno)
+	if err != nil {
+		c.problem("%s: %v", name, err)
+		return
+	}
+	leaf := depth == int(db.Depth)
+	switch {
+	case p.Pgno != pgno:
+		c.problem("page %d of %s has page number %d", pgno, name, p.Pgno)
+		return
+	case leaf && p.Flags&LeafPage == 0, !leaf && p.Flags&BranchPage == 0:
+		c.problem("page %d of %s at depth %d of %d has flags %v", pgno, name, depth, db.Depth, p.Flags)
+		return
+	}
+	if !leaf {
+		n.branch++
+		for _, nd := range p.Nodes {
+			c.page(name, db, nd.Child, depth+1, n)
+		}
+		return
+	}
+
+	n.leaf++
+	for i := range p.Nodes {
+		nd := &p.Nodes[i]
+		switch {
+		case nd.Flags&BigData != 0:
+			ov, err := c.f.Page(nd.OverflowPage)
+			if err != nil {
+				c.problem("%s: %v", name, err)
+				continue
+			}
+			if ov.Pgno != nd.OverflowPage || ov.Flags&OverflowPage == 0 {
+				c.problem("overflow page %d of %s has page number %d and flags %v", nd.OverflowPage, name, ov.Pgno, ov.Flags)
+				continue
+			}
+			if c.use(name, nd.OverflowPage, uint64(ov.Pages)) {
+				n.overflow += uint64(ov.Pages)
+			}
+		case nd.Flags&SubData != 0:
+			sub, err := DecodeDB(nd.Data)
+			if err != nil {
+				c.problem("%s: key %q: %v", name, nd.Key, err)
+				continue
+			}
+			if nd.Flags&DupData != 0 {
+				c.tree(fmt.Sprintf("duplicates of key %q of %s", nd.Key, name), sub)
+			} else {
+				c.tree(fmt.Sprintf("database %q", nd.Key), sub)
+			}
+		}
+	}
+}
+
+// freelist accounts for the pages on the free list.
+func (c *checker) freelist() {
+	entries, err := c.f.Freelist(c.m)
+	if err != nil {
+		c.problem("free list: %v", err)
+		return
+	}
+	for _, e := range entries {
+		if e.TxnID > c.m.TxnID {
+			c.problem("free list record of transaction %d after the snapshot", e.TxnID)
+		}
+		for _, pgno := range e.Pages {
+			if c.use(fmt.Sprintf("free list record of transaction %d", e.TxnID), pgno, 1) {
+				c.res.FreePages++
+			}
+		}
+	}
+}
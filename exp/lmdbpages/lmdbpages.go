@@ -0,0 +1,550 @@
+/*
+Package lmdbpages decodes the pages of an LMDB data file, for debugging and
+investigating corruption.
+
+A File reads the data file directly, without the C library, so that it can
+inspect environments which LMDB would refuse to open or crash reading.  It
+decodes meta pages, the headers and nodes of branch, leaf and overflow pages,
+and the free list, and Check verifies that every page of the file is
+accounted for exactly once.
+
+	f, err := lmdbpages.Open("/var/db/example")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := f.Current()
+	if err != nil {
+		return err
+	}
+	err = f.Walk(m.Main, func(p *lmdbpages.Page, depth int) error {
+		fmt.Println(depth, p.Pgno, p.Flags, len(p.Nodes))
+		return nil
+	})
+
+The file format depends on the word size and byte order of the host which
+wrote it, and File assumes they are those of the current host.  Pages are
+read while the environment may be written to by other processes, so a File
+should only be used on environments which are not in use, or on copies.
+*/
+package lmdbpages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Constants of the file format.
+const (
+	metaMagic   = 0xBEEFC0DE
+	dataVersion = 1
+
+	// NumMetas is the number of meta pages at the start of the file.
+	NumMetas = 2
+
+	// InvalidPage is the root page of an empty database, P_INVALID.
+	InvalidPage = uint64(^uintptr(0))
+)
+
+// word is the size of size_t and pgno_t, which is that of the host.
+const word = int(unsafe.Sizeof(uintptr(0)))
+
+// PageHeaderSize is the size of a page header, PAGEHDRSZ.
+const PageHeaderSize = word + 8
+
+// nodeHeaderSize is the size of a node header, NODESIZE.
+const nodeHeaderSize = 8
+
+// dbSize is the size of an MDB_db record.
+const dbSize = 8 + 5*word
+
+// metaSize is the size of the header and MDB_meta of a meta page.
+const metaSize = PageHeaderSize + 8 + 2*word + 2*dbSize + 2*word
+
+// PageFlags are the flags of a page header.
+type PageFlags uint16
+
+// Page flags, P_BRANCH and so on in the C library.
+const (
+	BranchPage   PageFlags = 0x01
+	LeafPage     PageFlags = 0x02
+	OverflowPage PageFlags = 0x04
+	MetaPage     PageFlags = 0x08
+	DirtyPage    PageFlags = 0x10
+	Leaf2Page    PageFlags = 0x20 // Leaf page of a DupFixed sub-database
+	SubPage      PageFlags = 0x40 // Sub-page stored in a node
+)
+
+var pageFlagNames = []struct {
+	flag PageFlags
+	name string
+}{
+	{BranchPage, "branch"},
+	{LeafPage, "leaf"},
+	{OverflowPage, "overflow"},
+	{MetaPage, "meta"},
+	{DirtyPage, "dirty"},
+	{Leaf2Page, "leaf2"},
+	{SubPage, "sub"},
+}
+
+// String returns the names of the flags separated by "|".
+func (f PageFlags) String() string {
+	var names []string
+	for _, n := range pageFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+	if f != 0 || len(names) == 0 {
+		names = append(names, fmt.Sprintf("%#x", uint16(f)))
+	}
+	return strings.Join(names, "|")
+}
+
+// NodeFlags are the flags of a leaf node.
+type NodeFlags uint16
+
+// Node flags, F_BIGDATA and so on in the C library.
+const (
+	BigData NodeFlags = 0x01 // The value is stored on overflow pages
+	SubData NodeFlags = 0x02 // The value is the MDB_db of a sub-database
+	DupData NodeFlags = 0x04 // The value holds the duplicates of the key
+)
+
+// DB is a decoded MDB_db, the record of a database.
+type DB struct {
+	Pad           uint32 // Page size in the record of the free list
+	Flags         uint16 // Database flags such as lmdb.DupSort
+	Depth         uint16
+	BranchPages   uint64
+	LeafPages     uint64
+	OverflowPages uint64
+	Entries       uint64
+	Root          uint64 // InvalidPage if the database is empty
+}
+
+func uword(b []byte) uint64 {
+	if word == 4 {
+		return uint64(lmdb.NativeEndian.Uint32(b))
+	}
+	return lmdb.NativeEndian.Uint64(b)
+}
+
+// DecodeDB decodes an MDB_db record, such as the value of a named database in
+// the main database.
+func DecodeDB(b []byte) (DB, error) {
+	if len(b) < dbSize {
+		return DB{}, fmt.Errorf("lmdbpages: database record of %d bytes", len(b))
+	}
+	return DB{
+		Pad:           lmdb.NativeEndian.Uint32(b),
+		Flags:         lmdb.NativeEndian.Uint16(b[4:]),
+		Depth:         lmdb.NativeEndian.Uint16(b[6:]),
+		BranchPages:   uword(b[8:]),
+		LeafPages:     uword(b[8+word:]),
+		OverflowPages: uword(b[8+2*word:]),
+		Entries:       uword(b[8+3*word:]),
+		Root:          uword(b[8+4*word:]),
+	}, nil
+}
+
+// Meta is a decoded meta page.
+type Meta struct {
+	Pgno     uint64
+	Flags    PageFlags
+	Magic    uint32
+	Version  uint32
+	Address  uint64 // Address of a FixedMap environment
+	MapSize  uint64
+	Free     DB // The free list
+	Main     DB // The main database
+	LastPage uint64
+	TxnID    uint64
+}
+
+// PageSize returns the page size recorded in m.
+func (m *Meta) PageSize() int {
+	return int(m.Free.Pad)
+}
+
+// Validate returns an error if m is not a valid meta page.
+func (m *Meta) Validate() error {
+	switch psize := m.PageSize(); {
+	case m.Magic != metaMagic:
+		return fmt.Errorf("lmdbpages: meta page %d has invalid magic %#x", m.Pgno, m.Magic)
+	case m.Version != dataVersion:
+		return fmt.Errorf("lmdbpages: meta page %d has unsupported version %d", m.Pgno, m.Version)
+	case m.Flags&MetaPage == 0:
+		return fmt.Errorf("lmdbpages: meta page %d has page flags %v", m.Pgno, m.Flags)
+	case psize < 512 || psize&(psize-1) != 0:
+		return fmt.Errorf("lmdbpages: meta page %d has invalid page size %d", m.Pgno, psize)
+	}
+	return nil
+}
+
+// DecodeMeta decodes a meta page.  It does not validate it.
+func DecodeMeta(b []byte) (*Meta, error) {
+	if len(b) < metaSize {
+		return nil, fmt.Errorf("lmdbpages: meta page of %d bytes", len(b))
+	}
+	m := &Meta{
+		Pgno:  uword(b),
+		Flags: PageFlags(lmdb.NativeEndian.Uint16(b[word+2:])),
+	}
+	b = b[PageHeaderSize:]
+	m.Magic = lmdb.NativeEndian.Uint32(b)
+	m.Version = lmdb.NativeEndian.Uint32(b[4:])
+	m.Address = uword(b[8:])
+	m.MapSize = uword(b[8+word:])
+	b = b[8+2*word:]
+	m.Free, _ = DecodeDB(b)
+	m.Main, _ = DecodeDB(b[dbSize:])
+	b = b[2*dbSize:]
+	m.LastPage = uword(b)
+	m.TxnID = uword(b[word:])
+	return m, nil
+}
+
+// Node is a decoded node of a branch or leaf page.
+type Node struct {
+	Flags NodeFlags // Flags of a leaf node
+	Key   []byte
+
+	// Child is the page number of the child of a branch node.
+	Child uint64
+
+	// Data is the value of a leaf node, unless it has the BigData flag.
+	// DecodeDB decodes it if the node has the SubData flag, and DecodePage
+	// if it has the DupData flag only, in which case it is a sub-page.
+	Data     []byte
+	DataSize uint32 // Size of the value of a leaf node
+
+	// OverflowPage is the first overflow page of a node with the BigData
+	// flag.
+	OverflowPage uint64
+}
+
+// Page is a decoded page header, with the nodes of branch and leaf pages.
+type Page struct {
+	Pgno  uint64
+	Flags PageFlags
+	Pad   uint16 // Key size of Leaf2 pages
+	Lower uint16 // End of the node offsets
+	Upper uint16 // Start of the nodes
+
+	// Pages is the number of pages spanned by an overflow page.
+	Pages uint32
+
+	Nodes []Node   // Nodes of branch and leaf pages
+	Keys  [][]byte // Keys of Leaf2 pages
+}
+
+// Free returns the unused space of a branch or leaf page.
+func (p *Page) Free() int {
+	return int(p.Upper) - int(p.Lower)
+}
+
+// DecodePage decodes the page b, which references the returned Page.  Pages
+// which are neither branch nor leaf pages only have their header decoded.
+func DecodePage(b []byte) (*Page, error) {
+	if len(b) < PageHeaderSize {
+		return nil, fmt.Errorf("lmdbpages: page of %d bytes", len(b))
+	}
+	p := &Page{
+		Pgno:  uword(b),
+		Pad:   lmdb.NativeEndian.Uint16(b[word:]),
+		Flags: PageFlags(lmdb.NativeEndian.Uint16(b[word+2:])),
+		Lower: lmdb.NativeEndian.Uint16(b[word+4:]),
+		Upper: lmdb.NativeEndian.Uint16(b[word+6:]),
+	}
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("lmdbpages: page %d: %s", p.Pgno, fmt.Sprintf(format, args...))
+	}
+	if p.Flags&OverflowPage != 0 {
+		p.Pages = lmdb.NativeEndian.Uint32(b[word+4:])
+		p.Lower, p.Upper = 0, 0
+		return p, nil
+	}
+	if p.Flags&(BranchPage|LeafPage) == 0 {
+		return p, nil
+	}
+	if int(p.Lower) < PageHeaderSize || p.Lower > p.Upper || int(p.Upper) > len(b) {
+		return nil, errorf("invalid bounds %d-%d", p.Lower, p.Upper)
+	}
+	n := (int(p.Lower) - PageHeaderSize) / 2
+
+	if p.Flags&Leaf2Page != 0 {
+		ksize := int(p.Pad)
+		if PageHeaderSize+n*ksize > len(b) {
+			return nil, errorf("%d keys of %d bytes overflow the page", n, ksize)
+		}
+		for i := 0; i < n; i++ {
+			off := PageHeaderSize + i*ksize
+			p.Keys = append(p.Keys, b[off:off+ksize])
+		}
+		return p, nil
+	}
+
+	p.Nodes = make([]Node, n)
+	for i := range p.Nodes {
+		off := int(lmdb.NativeEndian.Uint16(b[PageHeaderSize+2*i:]))
+		if off < int(p.Upper) || off+nodeHeaderSize > len(b) {
+			return nil, errorf("node %d at invalid offset %d", i, off)
+		}
+		lohi := lmdb.NativeEndian.Uint32(b[off:])
+		flags := lmdb.NativeEndian.Uint16(b[off+4:])
+		ksize := int(lmdb.NativeEndian.Uint16(b[off+6:]))
+		kend := off + nodeHeaderSize + ksize
+		if kend > len(b) {
+			return nil, errorf("key of node %d overflows the page", i)
+		}
+		nd := &p.Nodes[i]
+		nd.Key = b[off+nodeHeaderSize : kend]
+		if p.Flags&BranchPage != 0 {
+			nd.Child = uint64(lohi)
+			if word > 4 {
+				nd.Child |= uint64(flags) << 32
+			}
+			continue
+		}
+		nd.Flags = NodeFlags(flags)
+		nd.DataSize = lohi
+		if nd.Flags&BigData != 0 {
+			if kend+word > len(b) {
+				return nil, errorf("overflow page of node %d overflows the page", i)
+			}
+			nd.OverflowPage = uword(b[kend:])
+			continue
+		}
+		if kend+int(lohi) > len(b) {
+			return nil, errorf("value of node %d overflows the page", i)
+		}
+		nd.Data = b[kend : kend+int(lohi)]
+	}
+	return p, nil
+}
+
+// File is an LMDB data file opened for decoding.
+type File struct {
+	f     *os.File
+	size  int64
+	psize int
+}
+
+// Open opens the data file of the environment at path, which is either the
+// environment directory or the data file itself, read-only.
+func Open(path string) (*File, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		path = filepath.Join(path, "data.mdb")
+		fi, err = os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{f: f, size: fi.Size(), psize: os.Getpagesize()}
+	b := make([]byte, metaSize)
+	_, err = f.ReadAt(b, 0)
+	if err == nil {
+		if m, _ := DecodeMeta(b); m.Validate() == nil {
+			file.psize = m.PageSize()
+		}
+	}
+	return file, nil
+}
+
+// Close closes the data file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Name returns the path of the data file.
+func (f *File) Name() string {
+	return f.f.Name()
+}
+
+// PageSize returns the page size recorded in the first meta page, or the page
+// size of the host if the first meta page is invalid.
+func (f *File) PageSize() int {
+	return f.psize
+}
+
+// NumPages returns the number of pages in the data file.
+func (f *File) NumPages() uint64 {
+	return uint64(f.size / int64(f.psize))
+}
+
+// ReadPage returns the raw contents of page pgno.
+func (f *File) ReadPage(pgno uint64) ([]byte, error) {
+	return f.readPages(pgno, 1)
+}
+
+func (f *File) readPages(pgno uint64, n int) ([]byte, error) {
+	if pgno >= f.NumPages() || uint64(n) > f.NumPages()-pgno {
+		return nil, fmt.Errorf("lmdbpages: page %d beyond the end of the file (%d pages)", pgno+uint64(n)-1, f.NumPages())
+	}
+	b := make([]byte, n*f.psize)
+	_, err := f.f.ReadAt(b, int64(pgno)*int64(f.psize))
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Page reads and decodes page pgno.
+func (f *File) Page(pgno uint64) (*Page, error) {
+	b, err := f.ReadPage(pgno)
+	if err != nil {
+		return nil, err
+	}
+	return DecodePage(b)
+}
+
+// Meta reads and decodes meta page i, 0 or 1.  It does not validate it.
+func (f *File) Meta(i int) (*Meta, error) {
+	if i < 0 || i >= NumMetas {
+		return nil, fmt.Errorf("lmdbpages: invalid meta page %d", i)
+	}
+	b, err := f.ReadPage(uint64(i))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMeta(b)
+}
+
+// Current returns the meta page of the last committed transaction, the valid
+// meta page with the highest transaction ID, as LMDB selects it.
+func (f *File) Current() (*Meta, error) {
+	var cur *Meta
+	var errs []string
+	for i := 0; i < NumMetas; i++ {
+		m, err := f.Meta(i)
+		if err == nil {
+			err = m.Validate()
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if cur == nil || m.TxnID > cur.TxnID {
+			cur = m
+		}
+	}
+	if cur == nil {
+		return nil, fmt.Errorf("lmdbpages: no valid meta page: %s", strings.Join(errs, "; "))
+	}
+	return cur, nil
+}
+
+// Overflow returns the value of size bytes stored on the overflow pages
+// starting at pgno.
+func (f *File) Overflow(pgno uint64, size uint32) ([]byte, error) {
+	n := (PageHeaderSize + int(size) + f.psize - 1) / f.psize
+	b, err := f.readPages(pgno, n)
+	if err != nil {
+		return nil, err
+	}
+	p, err := DecodePage(b)
+	if err != nil {
+		return nil, err
+	}
+	if p.Flags&OverflowPage == 0 || p.Pgno != pgno {
+		return nil, fmt.Errorf("lmdbpages: page %d is not an overflow page (page number %d, flags %v)", pgno, p.Pgno, p.Flags)
+	}
+	return b[PageHeaderSize : PageHeaderSize+int(size)], nil
+}
+
+// Value returns the value of the leaf node nd, reading its overflow pages if
+// it has the BigData flag.
+func (f *File) Value(nd *Node) ([]byte, error) {
+	if nd.Flags&BigData != 0 {
+		return f.Overflow(nd.OverflowPage, nd.DataSize)
+	}
+	return nd.Data, nil
+}
+
+// Walk calls fn with the branch and leaf pages of the tree of db, depth-first
+// in key order, and their depth starting at 1 for the root.  Sub-databases
+// and overflow pages are not visited.  Walk stops and returns the first error
+// returned by fn, or encountered reading the tree.
+func (f *File) Walk(db DB, fn func(p *Page, depth int) error) error {
+	if db.Root == InvalidPage {
+		return nil
+	}
+	return f.walk(db.Root, 1, int(db.Depth), fn)
+}
+
+func (f *File) walk(pgno uint64, depth, max int, fn func(*Page, int) error) error {
+	if depth > max {
+		return fmt.Errorf("lmdbpages: page %d deeper than the tree depth %d", pgno, max)
+	}
+	p, err := f.Page(pgno)
+	if err != nil {
+		return err
+	}
+	if p.Pgno != pgno {
+		return fmt.Errorf("lmdbpages: page %d has page number %d", pgno, p.Pgno)
+	}
+	if p.Flags&(BranchPage|LeafPage) == 0 {
+		return fmt.Errorf("lmdbpages: page %d in a tree has flags %v", pgno, p.Flags)
+	}
+	err = fn(p, depth)
+	if err != nil || p.Flags&BranchPage == 0 {
+		return err
+	}
+	for _, nd := range p.Nodes {
+		err = f.walk(nd.Child, depth+1, max, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FreeEntry is a record of the free list: the pages freed by a transaction.
+type FreeEntry struct {
+	TxnID uint64
+	Pages []uint64
+}
+
+// Freelist returns the free list of the snapshot of m, in the order of the
+// transaction IDs.
+func (f *File) Freelist(m *Meta) ([]FreeEntry, error) {
+	var entries []FreeEntry
+	err := f.Walk(m.Free, func(p *Page, depth int) error {
+		for i := range p.Nodes {
+			nd := &p.Nodes[i]
+			if len(nd.Key) != word {
+				return fmt.Errorf("lmdbpages: free list key of %d bytes on page %d", len(nd.Key), p.Pgno)
+			}
+			b, err := f.Value(nd)
+			if err != nil {
+				return err
+			}
+			if len(b) < word || uword(b) > uint64(len(b)/word-1) {
+				return fmt.Errorf("lmdbpages: invalid free list record on page %d", p.Pgno)
+			}
+			e := FreeEntry{TxnID: uword(nd.Key), Pages: make([]uint64, uword(b))}
+			for j := range e.Pages {
+				e.Pages[j] = uword(b[word*(j+1):])
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,246 @@
+package lmdbpages
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// fill creates databases exercising every kind of page and leaves pages on
+// the free list.
+func fill(t *testing.T, env *lmdb.Env) {
+	for round := 0; round < 3; round++ {
+		err := env.Update(func(txn *lmdb.Txn) (err error) {
+			items, err := txn.OpenDBI("items", lmdb.Create)
+			if err != nil {
+				return err
+			}
+			dups, err := txn.OpenDBI("dups", lmdb.Create|lmdb.DupSort)
+			if err != nil {
+				return err
+			}
+			fixed, err := txn.OpenDBI("fixed", lmdb.Create|lmdb.DupSort|lmdb.DupFixed)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 500; i++ {
+				key := []byte(fmt.Sprintf("key%04d", i))
+				err = txn.Put(items, key, bytes.Repeat([]byte{byte(round)}, 100), 0)
+				if err != nil {
+					return err
+				}
+				// Few duplicates are stored on sub-pages, many in
+				// sub-databases.
+				n := 2
+				if i%100 == 0 {
+					n = 1000
+				}
+				for j := 0; j < n; j++ {
+					val := make([]byte, 8)
+					binary.BigEndian.PutUint64(val, uint64(j))
+					err = txn.Put(dups, key, val, 0)
+					if err != nil {
+						return err
+					}
+					err = txn.Put(fixed, key, val, 0)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			err = txn.Put(items, []byte("big"), make([]byte, 20000), 0)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 500; i += 3 {
+				err = txn.Del(items, []byte(fmt.Sprintf("key%04d", i)), nil)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 4, MapSize: 64 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	fill(t, env)
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	res, err := f.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() {
+		t.Fatalf("problems: %v", res.Problems)
+	}
+	if res.FreePages == 0 {
+		t.Errorf("no free pages")
+	}
+
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := f.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TxnID != uint64(info.LastTxnID) || m.LastPage != uint64(info.LastPNO) {
+		t.Errorf("meta page of transaction %d, last page %d", m.TxnID, m.LastPage)
+	}
+	var entries []FreeEntry
+	var stat *lmdb.FreelistStat
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		stat, err = txn.FreelistStat()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = f.Freelist(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	free := 0
+	for _, e := range entries {
+		free += len(e.Pages)
+	}
+	if len(entries) != stat.Entries || int64(free) != stat.FreePages {
+		t.Errorf("%d free list records of %d pages, expected %d of %d", len(entries), free, stat.Entries, stat.FreePages)
+	}
+
+	// Find the leaf pages of the named databases, a sub-page and the big
+	// value.
+	dbs := map[string]DB{}
+	err = f.Walk(m.Main, func(p *Page, depth int) error {
+		for _, nd := range p.Nodes {
+			dbs[string(nd.Key)], err = DecodeDB(nd.Data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(dbs) != 3 {
+		t.Fatalf("databases %v: %v", dbs, err)
+	}
+	var leaf uint64
+	var big []byte
+	err = f.Walk(dbs["items"], func(p *Page, depth int) error {
+		for i := range p.Nodes {
+			nd := &p.Nodes[i]
+			if string(nd.Key) == "big" {
+				big, err = f.Value(nd)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		leaf = p.Pgno
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(big) != 20000 {
+		t.Errorf("big value of %d bytes", len(big))
+	}
+	subPages, leaf2 := 0, 0
+	err = f.Walk(dbs["dups"], func(p *Page, depth int) error {
+		for _, nd := range p.Nodes {
+			if nd.Flags&(DupData|SubData) != DupData {
+				continue
+			}
+			sub, err := DecodePage(nd.Data)
+			if err != nil {
+				return err
+			}
+			if sub.Flags&SubPage == 0 || len(sub.Nodes) != 2 {
+				return fmt.Errorf("sub-page %v with %d nodes", sub.Flags, len(sub.Nodes))
+			}
+			subPages++
+		}
+		return nil
+	})
+	if err != nil || subPages == 0 {
+		t.Errorf("%d sub-pages: %v", subPages, err)
+	}
+	err = f.Walk(dbs["fixed"], func(p *Page, depth int) error {
+		for _, nd := range p.Nodes {
+			if nd.Flags&SubData == 0 {
+				continue
+			}
+			sub, err := DecodeDB(nd.Data)
+			if err != nil {
+				return err
+			}
+			return f.Walk(sub, func(p *Page, depth int) error {
+				if p.Flags&Leaf2Page != 0 {
+					leaf2 += len(p.Keys)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil || leaf2 == 0 {
+		t.Errorf("%d keys on leaf2 pages: %v", leaf2, err)
+	}
+
+	// Overwrite a leaf page.
+	env.Close()
+	w, err := os.OpenFile(f.Name(), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WriteAt(make([]byte, f.PageSize()), int64(leaf)*int64(f.PageSize()))
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = f.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() || !strings.Contains(strings.Join(res.Problems, "\n"), fmt.Sprintf("page %d of database \"items\"", leaf)) {
+		t.Errorf("problems: %v", res.Problems)
+	}
+}
+
+func TestPageFlags_String(t *testing.T) {
+	for _, test := range []struct {
+		flags PageFlags
+		s     string
+	}{
+		{LeafPage | Leaf2Page, "leaf|leaf2"},
+		{BranchPage | 0x1000, "branch|0x1000"},
+		{0, "0x0"},
+	} {
+		if s := test.flags.String(); s != test.s {
+			t.Errorf("%#x: %q", uint16(test.flags), s)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Package lmdbreaders limits the number of concurrent read-only transactions on
+an environment so that the reader lock table is never exhausted.
+
+Every read-only transaction occupies a slot in the environment's reader lock
+table, which holds lmdb.Env.MaxReaders entries shared by all processes using
+the environment.  When the table is full new transactions fail with
+lmdb.ReadersFull.  A Pool caps the number of transactions it runs below that
+limit, reserving headroom for other processes, and queues excess work until a
+slot is free.  A Pool with a bounded queue rejects work with ErrSaturated
+instead of queueing without limit during request storms.
+*/
+package lmdbreaders
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrSaturated is returned by Pool.View when the queue of a Pool is full.
+var ErrSaturated = errors.New("lmdbreaders: pool saturated")
+
+// Options configure a Pool.
+type Options struct {
+	// Reserve is the number of reader slots left for use outside the Pool,
+	// by other processes or by transactions not run through the Pool.
+	Reserve int
+
+	// Limit, if positive, caps the number of concurrent transactions below
+	// the number allowed by Reserve.
+	Limit int
+
+	// MaxQueue is the maximum number of calls waiting for a slot.  When the
+	// queue is full View returns ErrSaturated.  Zero means no limit.
+	MaxQueue int
+}
+
+// Pool runs read-only transactions on an environment with bounded
+// concurrency.  A Pool is safe for concurrent use.
+type Pool struct {
+	env      *lmdb.Env
+	slots    chan struct{}
+	maxQueue int64
+
+	active    int64
+	waiting   int64
+	queued    uint64
+	rejected  uint64
+	completed uint64
+}
+
+// New returns a Pool running transactions on env, which must be open.
+func New(env *lmdb.Env, opt *Options) (*Pool, error) {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	max, err := env.MaxReaders()
+	if err != nil {
+		return nil, err
+	}
+	n := max - o.Reserve
+	if o.Limit > 0 && o.Limit < n {
+		n = o.Limit
+	}
+	if n < 1 {
+		return nil, errors.New("lmdbreaders: no reader slots available to the pool")
+	}
+	return &Pool{
+		env:      env,
+		slots:    make(chan struct{}, n),
+		maxQueue: int64(o.MaxQueue),
+	}, nil
+}
+
+// View runs fn in a read-only transaction, like lmdb.Env.View, once a slot
+// is available.
+func (p *Pool) View(fn lmdb.TxnOp) error {
+	return p.ViewContext(context.Background(), fn)
+}
+
+// ViewContext is like View but returns ctx.Err() if ctx is done before a
+// slot becomes available.
+func (p *Pool) ViewContext(ctx context.Context, fn lmdb.TxnOp) error {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		err := p.wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	atomic.AddInt64(&p.active, 1)
+	defer func() {
+		atomic.AddInt64(&p.active, -1)
+		atomic.AddUint64(&p.completed, 1)
+		<-p.slots
+	}()
+	return p.env.View(fn)
+}
+
+func (p *Pool) wait(ctx context.Context) error {
+	n := atomic.AddInt64(&p.waiting, 1)
+	defer atomic.AddInt64(&p.waiting, -1)
+	if p.maxQueue > 0 && n > p.maxQueue {
+		atomic.AddUint64(&p.rejected, 1)
+		return ErrSaturated
+	}
+	atomic.AddUint64(&p.queued, 1)
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats are the saturation metrics of a Pool.
+type Stats struct {
+	Capacity  int    // Maximum number of concurrent transactions
+	Active    int    // Transactions running
+	Waiting   int    // Calls waiting for a slot
+	Queued    uint64 // Calls which had to wait for a slot
+	Rejected  uint64 // Calls rejected with ErrSaturated
+	Completed uint64 // Transactions run
+}
+
+// Stats returns the current metrics of p.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Capacity:  cap(p.slots),
+		Active:    int(atomic.LoadInt64(&p.active)),
+		Waiting:   int(atomic.LoadInt64(&p.waiting)),
+		Queued:    atomic.LoadUint64(&p.queued),
+		Rejected:  atomic.LoadUint64(&p.rejected),
+		Completed: atomic.LoadUint64(&p.completed),
+	}
+}
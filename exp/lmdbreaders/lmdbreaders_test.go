@@ -0,0 +1,78 @@
+package lmdbreaders
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestPool(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxReaders: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	_, err = New(env, &Options{Reserve: 4})
+	if err == nil {
+		t.Errorf("expected error without available slots")
+	}
+
+	p, err := New(env, &Options{Reserve: 1, Limit: 2, MaxQueue: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stats().Capacity != 2 {
+		t.Fatalf("unexpected capacity: %d", p.Stats().Capacity)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	errc := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errc <- p.View(func(txn *lmdb.Txn) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+	}
+	<-started
+	<-started
+	for p.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	err = p.View(func(txn *lmdb.Txn) error { return nil })
+	if err != ErrSaturated {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	stats := p.Stats()
+	if stats.Active != 2 || stats.Queued != 1 || stats.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	close(release)
+	<-started
+	for i := 0; i < 3; i++ {
+		err = <-errc
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if p.Stats().Completed != 3 {
+		t.Errorf("unexpected stats: %+v", p.Stats())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = p.ViewContext(ctx, func(txn *lmdb.Txn) error { return nil })
+	if err != nil {
+		t.Errorf("unexpected error with free slots: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package lmdbrepl
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultStateDB is the name of the database in which a Follower records its
+// position.
+const DefaultStateDB = "lmdbrepl"
+
+var stateKey = []byte("last_txnid")
+
+// Follower applies frames to an environment.
+type Follower struct {
+	env   *lmdb.Env
+	state lmdb.DBI
+	dbs   map[string]lmdb.DBI
+}
+
+// NewFollower returns a Follower applying frames to env.  The environment
+// must allow for one more named database than the leader uses, in which the
+// follower records the ID of the last applied frame.
+func NewFollower(env *lmdb.Env) (*Follower, error) {
+	f := &Follower{env: env, dbs: make(map[string]lmdb.DBI)}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		f.state, err = txn.OpenDBI(DefaultStateDB, lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Last returns the leader transaction ID of the last frame applied, or zero
+// if no frame has been applied.
+func (f *Follower) Last() (uint64, error) {
+	var last uint64
+	err := f.env.View(func(txn *lmdb.Txn) (err error) {
+		last, err = f.last(txn)
+		return err
+	})
+	return last, err
+}
+
+func (f *Follower) last(txn *lmdb.Txn) (uint64, error) {
+	v, err := txn.Get(f.state, stateKey)
+	if lmdb.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// Apply applies the operations of fr in a single write transaction.  Frames
+// with a TxnID not greater than Last are ignored.
+func (f *Follower) Apply(fr *Frame) error {
+	return f.env.Update(func(txn *lmdb.Txn) (err error) {
+		last, err := f.last(txn)
+		if err != nil {
+			return err
+		}
+		if fr.TxnID <= last {
+			return nil
+		}
+		for i := range fr.Ops {
+			err = f.apply(txn, &fr.Ops[i])
+			if err != nil {
+				return err
+			}
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, fr.TxnID)
+		return txn.Put(f.state, stateKey, v, 0)
+	})
+}
+
+func (f *Follower) apply(txn *lmdb.Txn, op *Op) error {
+	dbi, err := f.dbi(txn, op)
+	if err != nil {
+		return err
+	}
+	switch op.Type {
+	case OpPut:
+		return txn.Put(dbi, op.Key, op.Val, 0)
+	default:
+		err = txn.Del(dbi, op.Key, op.Val)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+}
+
+// dbi returns the handle for the database of op, opening it if necessary.
+// Handles opened in a transaction that is aborted are invalid, so a handle is
+// only cached once the transaction commits.
+func (f *Follower) dbi(txn *lmdb.Txn, op *Op) (lmdb.DBI, error) {
+	if dbi, ok := f.dbs[op.DB]; ok {
+		return dbi, nil
+	}
+	var dbi lmdb.DBI
+	var err error
+	flags := op.Flags | lmdb.Create
+	if op.DB == "" {
+		dbi, err = txn.OpenRoot(flags &^ lmdb.Create)
+	} else {
+		dbi, err = txn.OpenDBI(op.DB, flags)
+	}
+	if err != nil {
+		return 0, err
+	}
+	name := op.DB
+	txn.OnCommit(func(uintptr) { f.dbs[name] = dbi })
+	return dbi, nil
+}
+
+// Run applies frames read from r until r is exhausted or an error occurs.
+// Run returns nil if the stream ends cleanly between frames.
+func (f *Follower) Run(r io.Reader) error {
+	dec := NewDecoder(r)
+	for {
+		fr, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = f.Apply(fr)
+		if err != nil {
+			return err
+		}
+	}
+}
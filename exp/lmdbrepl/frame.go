@@ -0,0 +1,177 @@
+package lmdbrepl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Op types.
+const (
+	OpPut byte = 1 + iota
+	OpDel
+)
+
+// maxFrameSize limits the size of a frame accepted by a Decoder.
+const maxFrameSize = 1 << 30
+
+var errFrameSize = errors.New("lmdbrepl: frame too large")
+
+// Op is a logical write operation.
+type Op struct {
+	Type  byte   // OpPut or OpDel
+	DB    string // Name of the database, empty for the root database
+	Flags uint   // Flags the database was opened with
+	Key   []byte
+	Val   []byte // The value stored by OpPut, or the duplicate removed by OpDel
+}
+
+// Frame holds the operations of a committed write transaction.
+type Frame struct {
+	TxnID uint64 // ID of the transaction on the leader
+	Ops   []Op
+}
+
+// Encoder writes frames to a stream.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes fr to the stream in a single call to Write.
+func (enc *Encoder) Encode(fr *Frame) error {
+	body := enc.buf[:0]
+	body = appendUvarint(body, fr.TxnID)
+	body = appendUvarint(body, uint64(len(fr.Ops)))
+	for i := range fr.Ops {
+		op := &fr.Ops[i]
+		body = append(body, op.Type)
+		body = appendUvarint(body, uint64(op.Flags))
+		body = appendBytes(body, []byte(op.DB))
+		body = appendBytes(body, op.Key)
+		body = appendBytes(body, op.Val)
+	}
+	enc.buf = body
+	p := appendUvarint(make([]byte, 0, len(body)+binary.MaxVarintLen64), uint64(len(body)))
+	_, err := enc.w.Write(append(p, body...))
+	return err
+}
+
+func appendUvarint(p []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(p, buf[:n]...)
+}
+
+func appendBytes(p, b []byte) []byte {
+	p = appendUvarint(p, uint64(len(b)))
+	return append(p, b...)
+}
+
+// Decoder reads frames from a stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next frame from the stream.  Decode returns io.EOF when
+// the stream ends cleanly between frames.
+func (dec *Decoder) Decode() (*Frame, error) {
+	n, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxFrameSize {
+		return nil, errFrameSize
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(dec.r, body)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrame(body)
+}
+
+func decodeFrame(body []byte) (*Frame, error) {
+	d := frameDecoder{p: body}
+	fr := &Frame{TxnID: d.uvarint()}
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(body)) {
+		d.err = io.ErrUnexpectedEOF
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		var op Op
+		op.Type = d.byte()
+		op.Flags = uint(d.uvarint())
+		op.DB = string(d.bytes())
+		op.Key = d.bytes()
+		op.Val = d.bytes()
+		if d.err == nil && op.Type != OpPut && op.Type != OpDel {
+			d.err = fmt.Errorf("lmdbrepl: unknown op type %d", op.Type)
+		}
+		fr.Ops = append(fr.Ops, op)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return fr, nil
+}
+
+type frameDecoder struct {
+	p   []byte
+	err error
+}
+
+func (d *frameDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.p)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.p = d.p[n:]
+	return x
+}
+
+func (d *frameDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.p) == 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := d.p[0]
+	d.p = d.p[1:]
+	return b
+}
+
+func (d *frameDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.p)) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.p[:n:n]
+	d.p = d.p[n:]
+	return b
+}
@@ -0,0 +1,235 @@
+/*
+Package lmdbrepl replicates the logical changes of write transactions from a
+leader environment to follower environments in other processes.
+
+Writers on the leader route their Put and Del calls through a Txn wrapper
+obtained from a Leader.  When the transaction commits the recorded operations
+are framed with the ID of the committed transaction and sent to every Stream
+attached to the Leader.  A Stream writes frames to an io.Writer, typically a
+network connection, from its own goroutine so that slow followers do not block
+the writer.  A Stream that falls too far behind is closed with ErrLagging
+rather than silently skipping frames.
+
+A Follower reads frames with a Decoder and applies each one in a single write
+transaction on its own environment, recording the ID of the last applied frame
+so that frames which were already applied are skipped after a reconnect.  A
+follower that has missed frames, because its stream was closed or it joined
+late, must be seeded with a copy of the leader (see lmdb.Env.Copy) before it
+follows again.
+
+Only changes made through the wrapper are replicated.  Databases are
+identified by name and must be registered with Leader.AddDBI.
+*/
+package lmdbrepl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrLagging is the error of a Stream which was closed because it could not
+// keep up with the leader.
+var ErrLagging = errors.New("lmdbrepl: follower stream lagging")
+
+// Leader captures the operations of write transactions and sends them to
+// attached streams.
+type Leader struct {
+	mu      sync.Mutex
+	dbs     map[lmdb.DBI]dbInfo
+	streams map[*Stream]struct{}
+}
+
+type dbInfo struct {
+	name  string
+	flags uint
+}
+
+// NewLeader returns a Leader with no registered databases.
+func NewLeader() *Leader {
+	return &Leader{
+		dbs:     make(map[lmdb.DBI]dbInfo),
+		streams: make(map[*Stream]struct{}),
+	}
+}
+
+// AddDBI registers the name of dbi so that operations on it can be applied by
+// followers.  Name is empty for the root database.  The flags of dbi are read
+// using txn.
+func (l *Leader) AddDBI(txn *lmdb.Txn, name string, dbi lmdb.DBI) error {
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.dbs[dbi] = dbInfo{name: name, flags: flags}
+	l.mu.Unlock()
+	return nil
+}
+
+// Stream sends frames from a Leader to a follower.
+type Stream struct {
+	l    *Leader
+	c    chan *Frame
+	done chan struct{}
+	err  error
+	once sync.Once
+}
+
+// Attach starts sending the frames of transactions committed from now on to
+// w.  Up to n frames are buffered while w is slow to accept them; a Stream
+// that exceeds its buffer is closed with ErrLagging.
+func (l *Leader) Attach(w io.Writer, n int) *Stream {
+	s := &Stream{
+		l:    l,
+		c:    make(chan *Frame, n),
+		done: make(chan struct{}),
+	}
+	l.mu.Lock()
+	l.streams[s] = struct{}{}
+	l.mu.Unlock()
+	go s.run(NewEncoder(w))
+	return s
+}
+
+func (s *Stream) run(enc *Encoder) {
+	defer close(s.done)
+	var err error
+	for fr := range s.c {
+		if err != nil {
+			continue
+		}
+		err = enc.Encode(fr)
+		if err != nil {
+			s.close(err)
+		}
+	}
+}
+
+// close detaches s from its leader.  The leader only sends to attached
+// streams while holding its lock, so s.c may be closed safely afterwards.
+func (s *Stream) close(err error) {
+	s.once.Do(func() {
+		s.l.mu.Lock()
+		delete(s.l.streams, s)
+		s.l.mu.Unlock()
+		s.err = err
+		close(s.c)
+	})
+}
+
+// Close detaches s from its Leader and waits until the frames already
+// buffered have been written.
+func (s *Stream) Close() {
+	s.close(nil)
+	<-s.done
+}
+
+// Done returns a channel that is closed when s has stopped.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error which caused s to stop, after Done is closed.  Err
+// returns nil if s was stopped by Close.
+func (s *Stream) Err() error {
+	<-s.done
+	return s.err
+}
+
+func (l *Leader) send(fr *Frame) {
+	l.mu.Lock()
+	var lagging []*Stream
+	for s := range l.streams {
+		select {
+		case s.c <- fr:
+		default:
+			lagging = append(lagging, s)
+		}
+	}
+	l.mu.Unlock()
+	for _, s := range lagging {
+		s.close(ErrLagging)
+	}
+}
+
+// Txn wraps a write transaction and records the operations of its Put and Del
+// methods.  Changes made by calling methods on the embedded lmdb.Txn, or
+// through cursors, are not replicated.
+type Txn struct {
+	*lmdb.Txn
+	l   *Leader
+	ops []Op
+}
+
+// Txn returns a wrapper for txn whose operations are sent to the streams of
+// l after txn commits.  A transaction should be wrapped at most once.
+func (l *Leader) Txn(txn *lmdb.Txn) *Txn {
+	t := &Txn{Txn: txn, l: l}
+	txn.OnCommit(t.commit)
+	return t
+}
+
+func (t *Txn) commit(id uintptr) {
+	if len(t.ops) == 0 {
+		return
+	}
+	t.l.send(&Frame{TxnID: uint64(id), Ops: t.ops})
+	t.ops = nil
+}
+
+func (t *Txn) record(typ byte, dbi lmdb.DBI, key, val []byte) error {
+	t.l.mu.Lock()
+	db, ok := t.l.dbs[dbi]
+	t.l.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("lmdbrepl: dbi %d is not registered", dbi)
+	}
+	t.ops = append(t.ops, Op{
+		Type:  typ,
+		DB:    db.name,
+		Flags: db.flags,
+		Key:   append([]byte(nil), key...),
+		Val:   append([]byte(nil), val...),
+	})
+	return nil
+}
+
+// Put calls lmdb.Txn.Put and records the operation if it succeeds.  Put
+// returns an error without writing if dbi has not been registered.
+func (t *Txn) Put(dbi lmdb.DBI, key, val []byte, flags uint) error {
+	err := t.record(OpPut, dbi, key, val)
+	if err != nil {
+		return err
+	}
+	err = t.Txn.Put(dbi, key, val, flags)
+	if err != nil {
+		t.ops = t.ops[:len(t.ops)-1]
+	}
+	return err
+}
+
+// Del calls lmdb.Txn.Del and records the operation if it succeeds.  Del
+// returns an error without deleting if dbi has not been registered.
+func (t *Txn) Del(dbi lmdb.DBI, key, val []byte) error {
+	err := t.record(OpDel, dbi, key, val)
+	if err != nil {
+		return err
+	}
+	err = t.Txn.Del(dbi, key, val)
+	if err != nil {
+		t.ops = t.ops[:len(t.ops)-1]
+	}
+	return err
+}
+
+// Update runs fn in a write transaction on env, like lmdb.Env.Update, with
+// the transaction wrapped for l.
+func (l *Leader) Update(env *lmdb.Env, fn func(txn *Txn) error) error {
+	return env.Update(func(txn *lmdb.Txn) error {
+		return fn(l.Txn(txn))
+	})
+}
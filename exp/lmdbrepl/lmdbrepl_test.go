@@ -0,0 +1,162 @@
+package lmdbrepl
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestFrame(t *testing.T) {
+	frames := []*Frame{
+		{TxnID: 1, Ops: []Op{
+			{Type: OpPut, DB: "a", Flags: lmdb.DupSort, Key: []byte("k"), Val: []byte("v")},
+			{Type: OpDel, Key: []byte("k"), Val: []byte{}},
+		}},
+		{TxnID: 300, Ops: []Op{{Type: OpPut, DB: "b", Key: []byte("x"), Val: []byte{}}}},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, fr := range frames {
+		err := enc.Encode(fr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	for i, expect := range frames {
+		fr, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fr, expect) {
+			t.Errorf("frame %d: %+v (!= %+v)", i, fr, expect)
+		}
+	}
+	_, err := dec.Decode()
+	if err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	enc.Encode(frames[0])
+	_, err = NewDecoder(bytes.NewReader(buf.Bytes()[:buf.Len()-1])).Decode()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("unexpected error for truncated frame: %v", err)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	leaderEnv, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(leaderEnv)
+	followerEnv, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(followerEnv)
+
+	l := NewLeader()
+	var dbi lmdb.DBI
+	err = leaderEnv.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenDBI("users", lmdb.Create|lmdb.DupSort)
+		if err != nil {
+			return err
+		}
+		return l.AddDBI(txn, "users", dbi)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	s := l.Attach(pw, 16)
+
+	f, err := NewFollower(followerEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- f.Run(pr) }()
+
+	err = l.Update(leaderEnv, func(txn *Txn) (err error) {
+		for _, v := range []string{"1", "2", "3"} {
+			err = txn.Put(dbi, []byte("alice"), []byte(v), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Put(dbi, []byte("bob"), []byte("1"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Update(leaderEnv, func(txn *Txn) (err error) {
+		err = txn.Del(dbi, []byte("alice"), []byte("2"))
+		if err != nil {
+			return err
+		}
+		return txn.Del(dbi, []byte("bob"), []byte("1"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+	pw.Close()
+	err = <-errc
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last uint64
+	err = leaderEnv.View(func(txn *lmdb.Txn) (err error) {
+		last = uint64(txn.ID())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flast, err := f.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flast != last {
+		t.Errorf("unexpected last txnid: %d (!= %d)", flast, last)
+	}
+
+	err = followerEnv.View(func(txn *lmdb.Txn) (err error) {
+		fdbi, err := txn.OpenDBI("users", 0)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(fdbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		var items []string
+		for {
+			k, v, err := cur.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			items = append(items, string(k)+"="+string(v))
+		}
+		expect := []string{"alice=1", "alice=3"}
+		if !reflect.DeepEqual(items, expect) {
+			t.Errorf("unexpected items: %q (!= %q)", items, expect)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,346 @@
+/*
+Package lmdbreplay records the operations of failing write transactions and
+replays them against another environment, to make failures that depend on the
+state of a particular environment reproducible elsewhere.
+
+A Recorder runs transactions like lmdb.Env.Update with the transaction wrapped
+in a Txn that logs each database operation and its result.  When the
+transaction fails the log is delivered as a Recording, which can be encoded as
+JSON and shipped along with a copy of the environment made with
+lmdb.Env.Copy.  Replay re-executes a Recording in a transaction on the copy and
+reports whether the original failure was reproduced.
+
+Recordings are made for debugging and may leave the process, so by default
+they contain neither keys nor values.  Keys are replaced by hashes of the same
+length, which preserves the sizes of keys but not their order.  Values are
+replaced by their length and replayed as zero bytes.  Failures that depend on
+the layout of pages, such as MDB_MAP_FULL, generally need keys recorded with
+KeyFull or KeyTruncate to reproduce.
+
+Only operations made through the methods of Txn are recorded.  Calls on the
+embedded lmdb.Txn, cursors and subtransactions are not.
+*/
+package lmdbreplay
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// KeyMode selects how keys appear in a Recording.
+type KeyMode int
+
+// KeyMode values.
+const (
+	// KeyHash replaces each key by a hash of the same length.  Equal keys
+	// have equal hashes so repeated operations on a key replay faithfully.
+	KeyHash KeyMode = iota
+
+	// KeyTruncate keeps at most RecorderOptions.KeyLen bytes of each key.
+	// Distinct keys with a common prefix replay as the same key.
+	KeyTruncate
+
+	// KeyFull records keys unmodified.
+	KeyFull
+)
+
+// RecorderOptions configures a Recorder.
+type RecorderOptions struct {
+	Keys   KeyMode
+	KeyLen int // Bytes kept by KeyTruncate
+
+	// Values records the values written instead of only their length.
+	Values bool
+
+	// OnFailure receives the recording of each transaction that failed.  It
+	// is called after the transaction has been aborted.
+	OnFailure func(*Recording)
+}
+
+// Op is a recorded operation.
+type Op struct {
+	Op      string   `json:"op"` // "open", "get", "put", "del" or "drop"
+	DBI     lmdb.DBI `json:"dbi"`
+	Name    string   `json:"name,omitempty"` // Database name of "open", empty for the root database
+	Key     []byte   `json:"key,omitempty"`
+	Val     []byte   `json:"val,omitempty"`   // Value written by "put" or deleted by "del", if recorded
+	ValSize int      `json:"val_size"`        // Size of Val in the original transaction
+	Flags   uint     `json:"flags,omitempty"` // Flags of "open" and "put", 1 for a deleting "drop"
+	Err     string   `json:"err,omitempty"`   // Error returned by the operation
+}
+
+// Recording is the log of a failed transaction.
+type Recording struct {
+	Time      time.Time `json:"time"`
+	LastTxnID int64     `json:"last_txn_id"` // Last committed transaction when the recording ended
+	MapSize   int64     `json:"map_size"`
+	Keys      KeyMode   `json:"keys"`
+	Ops       []Op      `json:"ops"`
+	Err       string    `json:"err"` // Error returned by the transaction
+}
+
+// Recorder runs write transactions that are recorded.  A Recorder is safe for
+// concurrent use.
+type Recorder struct {
+	opt RecorderOptions
+}
+
+// NewRecorder returns a Recorder configured by opt.  A nil opt records with
+// the zero RecorderOptions, hashing keys and discarding the recordings.
+func NewRecorder(opt *RecorderOptions) *Recorder {
+	r := &Recorder{}
+	if opt != nil {
+		r.opt = *opt
+	}
+	return r
+}
+
+// Update runs fn in a write transaction on env, like lmdb.Env.Update, with
+// the transaction wrapped for recording.  If the transaction fails its
+// recording is passed to the OnFailure function of r.
+func (r *Recorder) Update(env *lmdb.Env, fn func(txn *Txn) error) error {
+	var t *Txn
+	err := env.Update(func(txn *lmdb.Txn) error {
+		t = &Txn{Txn: txn, r: r}
+		return fn(t)
+	})
+	if err == nil || t == nil || r.opt.OnFailure == nil {
+		return err
+	}
+	rec := &Recording{
+		Time: time.Now(),
+		Keys: r.opt.Keys,
+		Ops:  t.ops,
+		Err:  err.Error(),
+	}
+	info, ierr := env.Info()
+	if ierr == nil {
+		rec.LastTxnID = info.LastTxnID
+		rec.MapSize = info.MapSize
+	}
+	r.opt.OnFailure(rec)
+	return err
+}
+
+// key transforms k according to the KeyMode of r.
+func (r *Recorder) key(k []byte) []byte {
+	switch r.opt.Keys {
+	case KeyFull:
+		return append([]byte(nil), k...)
+	case KeyTruncate:
+		if len(k) > r.opt.KeyLen {
+			k = k[:r.opt.KeyLen]
+		}
+		return append([]byte(nil), k...)
+	}
+	return hashKey(k)
+}
+
+// hashKey returns a hash of k with the length of k.  Hashes are extended by
+// hashing k with an increasing counter.
+func hashKey(k []byte) []byte {
+	h := make([]byte, 0, len(k)+sha256.Size)
+	var ctr [4]byte
+	for i := uint32(0); len(h) < len(k); i++ {
+		binary.BigEndian.PutUint32(ctr[:], i)
+		sum := sha256.Sum256(append(ctr[:], k...))
+		h = append(h, sum[:]...)
+	}
+	return h[:len(k)]
+}
+
+// Txn wraps a write transaction and records the operations made by its
+// methods.
+type Txn struct {
+	*lmdb.Txn
+	r   *Recorder
+	ops []Op
+}
+
+func (t *Txn) record(op Op, err error) {
+	if err != nil {
+		op.Err = err.Error()
+	}
+	t.ops = append(t.ops, op)
+}
+
+func (t *Txn) val(v []byte) []byte {
+	if !t.r.opt.Values || v == nil {
+		return nil
+	}
+	return append([]byte(nil), v...)
+}
+
+// OpenDBI calls lmdb.Txn.OpenDBI and records the handle opened.
+func (t *Txn) OpenDBI(name string, flags uint) (lmdb.DBI, error) {
+	dbi, err := t.Txn.OpenDBI(name, flags)
+	t.record(Op{Op: "open", DBI: dbi, Name: name, Flags: flags}, err)
+	return dbi, err
+}
+
+// OpenRoot calls lmdb.Txn.OpenRoot and records the handle opened.
+func (t *Txn) OpenRoot(flags uint) (lmdb.DBI, error) {
+	dbi, err := t.Txn.OpenRoot(flags)
+	t.record(Op{Op: "open", DBI: dbi, Flags: flags}, err)
+	return dbi, err
+}
+
+// Get calls lmdb.Txn.Get and records the read.
+func (t *Txn) Get(dbi lmdb.DBI, key []byte) ([]byte, error) {
+	val, err := t.Txn.Get(dbi, key)
+	t.record(Op{Op: "get", DBI: dbi, Key: t.r.key(key), ValSize: len(val)}, err)
+	return val, err
+}
+
+// Put calls lmdb.Txn.Put and records the write.
+func (t *Txn) Put(dbi lmdb.DBI, key, val []byte, flags uint) error {
+	err := t.Txn.Put(dbi, key, val, flags)
+	t.record(Op{Op: "put", DBI: dbi, Key: t.r.key(key), Val: t.val(val), ValSize: len(val), Flags: flags}, err)
+	return err
+}
+
+// Del calls lmdb.Txn.Del and records the deletion.
+func (t *Txn) Del(dbi lmdb.DBI, key, val []byte) error {
+	err := t.Txn.Del(dbi, key, val)
+	t.record(Op{Op: "del", DBI: dbi, Key: t.r.key(key), Val: t.val(val), ValSize: len(val)}, err)
+	return err
+}
+
+// Drop calls lmdb.Txn.Drop and records it.
+func (t *Txn) Drop(dbi lmdb.DBI, del bool) error {
+	err := t.Txn.Drop(dbi, del)
+	op := Op{Op: "drop", DBI: dbi}
+	if del {
+		op.Flags = 1
+	}
+	t.record(op, err)
+	return err
+}
+
+// Result describes the outcome of Replay.
+type Result struct {
+	// Ops is the number of operations executed.
+	Ops int
+
+	// Err is the last error returned by an operation, or nil if all
+	// operations succeeded.
+	Err error
+
+	// Reproduced is true if every operation returned its recorded error and
+	// at least one of them failed.  A recorded transaction whose operations
+	// all succeeded failed for another reason, such as an error returned by
+	// the application or by the commit, which Replay cannot reproduce.
+	Reproduced bool
+}
+
+// Divergence is returned by Replay when an operation returns a different
+// error than it did when recorded.  The replay stops at the operation.
+type Divergence struct {
+	Index int    // Index of the operation in Recording.Ops
+	Op    Op     // The recorded operation
+	Err   string // The error returned by the replayed operation, or empty
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("lmdbreplay: op %d (%s) returned %q, recorded %q", d.Index, d.Op.Op, d.Err, d.Op.Err)
+}
+
+// errReplayed aborts the transaction of a replay.
+var errReplayed = errors.New("lmdbreplay: replayed")
+
+// Replay executes the operations of rec in a write transaction on env, which
+// should be a copy of the environment the recording was made on.  Handles are
+// reopened by name, so handle numbers need not match.  The transaction is
+// always aborted.
+//
+// Replay returns a *Divergence if an operation returns an error that differs
+// from the recorded one, which means that the environment differs from the
+// original or that the failure is caused by something other than the
+// operations, such as the environment's flags or the state of the host.
+//
+// Keys which were hashed or truncated do not exist in the copy, so reads and
+// deletions of keys written before the recorded transaction miss.  Replay
+// tolerates those misses.  A write with NoOverwrite of such a key succeeds
+// instead of failing, which diverges.
+func Replay(env *lmdb.Env, rec *Recording) (*Result, error) {
+	res := &Result{}
+	var div *Divergence
+	err := env.Update(func(txn *lmdb.Txn) error {
+		dbis := make(map[lmdb.DBI]lmdb.DBI)
+		for i, op := range rec.Ops {
+			err := replayOp(txn, dbis, &op)
+			res.Ops++
+			var msg string
+			if err != nil {
+				msg = err.Error()
+			}
+			if rec.Keys != KeyFull && missed(&op, err) {
+				continue
+			}
+			if msg != op.Err {
+				div = &Divergence{Index: i, Op: op, Err: msg}
+				return errReplayed
+			}
+			if err != nil {
+				res.Err = err
+			}
+		}
+		return errReplayed
+	})
+	if err != errReplayed {
+		return res, err
+	}
+	if div != nil {
+		return res, div
+	}
+	res.Reproduced = res.Err != nil
+	return res, nil
+}
+
+func replayOp(txn *lmdb.Txn, dbis map[lmdb.DBI]lmdb.DBI, op *Op) error {
+	if op.Op == "open" {
+		var dbi lmdb.DBI
+		var err error
+		if op.Name == "" {
+			dbi, err = txn.OpenRoot(op.Flags)
+		} else {
+			dbi, err = txn.OpenDBI(op.Name, op.Flags)
+		}
+		if err == nil {
+			dbis[op.DBI] = dbi
+		}
+		return err
+	}
+
+	dbi, ok := dbis[op.DBI]
+	if !ok {
+		dbi = op.DBI
+	}
+	val := op.Val
+	if val == nil && op.ValSize > 0 {
+		val = make([]byte, op.ValSize)
+	}
+	switch op.Op {
+	case "get":
+		_, err := txn.Get(dbi, op.Key)
+		return err
+	case "put":
+		return txn.Put(dbi, op.Key, val, op.Flags)
+	case "del":
+		return txn.Del(dbi, op.Key, val)
+	case "drop":
+		return txn.Drop(dbi, op.Flags != 0)
+	}
+	return fmt.Errorf("lmdbreplay: unknown op %q", op.Op)
+}
+
+// missed returns true if a get or del succeeded when recorded but did not
+// find its key when replayed.
+func missed(op *Op, err error) bool {
+	return (op.Op == "get" || op.Op == "del") && op.Err == "" && lmdb.IsNotFound(err)
+}
@@ -0,0 +1,212 @@
+package lmdbreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestHashKey(t *testing.T) {
+	for _, n := range []int{0, 1, 31, 32, 33, 100} {
+		k := bytes.Repeat([]byte("k"), n)
+		h := hashKey(k)
+		if len(h) != n {
+			t.Errorf("hash of %d bytes has length %d", n, len(h))
+		}
+		if !bytes.Equal(h, hashKey(k)) {
+			t.Errorf("hash of %d bytes is not deterministic", n)
+		}
+		if n > 0 && bytes.Equal(h, k) {
+			t.Errorf("key of %d bytes was not hashed", n)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	const mapSize = 1 << 20
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MapSize: mapSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return txn.Put(dbi, []byte("existing"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []*Recording
+	// map full depends on the order of the keys, which hashing does not
+	// preserve.
+	r := NewRecorder(&RecorderOptions{
+		Keys:      KeyFull,
+		OnFailure: func(rec *Recording) { recs = append(recs, rec) },
+	})
+	val := make([]byte, 1000)
+	err = r.Update(env, func(txn *Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Get(dbi, []byte("existing"))
+		if err != nil {
+			return err
+		}
+		for i := 0; ; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprintf("key%06d", i)), val, 0)
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if !lmdb.IsMapFull(err) {
+		t.Fatalf("expected map full: %v", err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("unexpected recordings: %d", len(recs))
+	}
+	rec := recs[0]
+	for _, op := range rec.Ops {
+		if op.Val != nil {
+			t.Fatalf("recording leaks values: %+v", op)
+		}
+	}
+
+	// the recording survives encoding.
+	p, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = new(Recording)
+	err = json.Unmarshal(p, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := copyEnv(t, env)
+	defer os.RemoveAll(dir)
+
+	res, err := replay(t, dir, mapSize, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Reproduced || !lmdb.IsMapFull(res.Err) || res.Ops != len(rec.Ops) {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	// with a larger map the failing put succeeds.
+	_, err = replay(t, dir, 4*mapSize, rec)
+	div, ok := err.(*Divergence)
+	if !ok {
+		t.Fatalf("expected divergence: %v", err)
+	}
+	if div.Index != len(rec.Ops)-1 || div.Err != "" {
+		t.Errorf("unexpected divergence: %v", div)
+	}
+}
+
+func TestReplay_hashed(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("existing"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec *Recording
+	r := NewRecorder(&RecorderOptions{
+		OnFailure: func(r *Recording) { rec = r },
+	})
+	err = r.Update(env, func(txn *Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Get(dbi, []byte("existing"))
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi, []byte("secret"), []byte("v"), lmdb.NoOverwrite)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("secret"), []byte("w"), lmdb.NoOverwrite)
+	})
+	if !lmdb.IsErrno(err, lmdb.KeyExist) {
+		t.Fatalf("expected key exists: %v", err)
+	}
+	if rec == nil || len(rec.Ops) != 4 {
+		t.Fatalf("unexpected recording: %+v", rec)
+	}
+	for _, op := range rec.Ops {
+		if bytes.Contains(op.Key, []byte("secret")) || bytes.Contains(op.Key, []byte("existing")) {
+			t.Fatalf("recording leaks keys: %+v", op)
+		}
+	}
+
+	dir := copyEnv(t, env)
+	defer os.RemoveAll(dir)
+
+	// the hashed key read does not exist in the copy, which is tolerated.
+	res, err := replay(t, dir, 0, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Reproduced || !lmdb.IsErrno(res.Err, lmdb.KeyExist) {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func copyEnv(t *testing.T, env *lmdb.Env) string {
+	dir, err := ioutil.TempDir("", "lmdbreplay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Copy(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func replay(t *testing.T, dir string, mapSize int64, rec *Recording) (*Result, error) {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if mapSize > 0 {
+		err = env.SetMapSize(mapSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = env.Open(dir, 0, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Replay(env, rec)
+}
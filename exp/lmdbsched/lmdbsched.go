@@ -0,0 +1,220 @@
+/*
+Package lmdbsched serializes the write transactions of an application through
+a queue with priorities and deadlines.
+
+LMDB allows a single write transaction at a time, and goroutines calling
+lmdb.Env.Update acquire the writer lock in no particular order, so a flood of
+background writes can delay latency-sensitive ones indefinitely.  A Scheduler
+runs queued updates one at a time on a dedicated goroutine, always choosing
+the update with the highest priority.  Updates whose context is done before
+they start are dropped from the queue, and optional aging raises the priority
+of waiting updates so low priority work is delayed but never starved.
+
+All writes to the environment should go through the Scheduler for priorities
+to be effective.
+*/
+package lmdbsched
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrClosed is returned by Update after the Scheduler has been closed.
+var ErrClosed = errors.New("lmdbsched: scheduler is closed")
+
+// Options configure a Scheduler.
+type Options struct {
+	// Aging is the time after which a waiting update gains one level of
+	// priority.  Zero disables aging.
+	Aging time.Duration
+}
+
+// Stats are counters of a Scheduler.
+type Stats struct {
+	Queued   int    // Updates currently waiting
+	Run      uint64 // Updates run
+	Expired  uint64 // Updates dropped because their context was done
+	MaxQueue int    // Largest number of updates waiting at once
+}
+
+type request struct {
+	ctx      context.Context
+	prio     int
+	seq      uint64
+	enqueued time.Time
+	fn       lmdb.TxnOp
+	done     chan error
+}
+
+// Scheduler runs write transactions in priority order.  A Scheduler is safe
+// for concurrent use.
+type Scheduler struct {
+	env   *lmdb.Env
+	aging time.Duration
+
+	mu     sync.Mutex
+	queue  []*request
+	seq    uint64
+	wake   chan struct{}
+	closed bool
+	stats  Stats
+
+	wg sync.WaitGroup
+}
+
+// New returns a Scheduler running updates on env.  A nil opt uses the zero
+// Options.  The Scheduler must be closed to stop its goroutine.
+func New(env *lmdb.Env, opt *Options) *Scheduler {
+	s := &Scheduler{
+		env:  env,
+		wake: make(chan struct{}, 1),
+	}
+	if opt != nil {
+		s.aging = opt.Aging
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// Update queues fn to run in a write transaction, like lmdb.Env.Update, and
+// waits for it to complete.  Updates with a higher prio run first and updates
+// with equal effective priority run in the order they were queued.
+//
+// If ctx is done before fn starts Update returns ctx.Err() and fn is never
+// run.  Once fn has started Update waits for it to return regardless of ctx.
+func (s *Scheduler) Update(ctx context.Context, prio int, fn lmdb.TxnOp) error {
+	req := &request{
+		ctx:      ctx,
+		prio:     prio,
+		enqueued: time.Now(),
+		fn:       fn,
+		done:     make(chan error, 1),
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.seq++
+	req.seq = s.seq
+	s.queue = append(s.queue, req)
+	if len(s.queue) > s.stats.MaxQueue {
+		s.stats.MaxQueue = len(s.queue)
+	}
+	s.mu.Unlock()
+	s.signal()
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+	}
+	// The request may have started meanwhile, in which case its result must
+	// be awaited.
+	if s.remove(req) {
+		return ctx.Err()
+	}
+	return <-req.done
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove removes req from the queue and returns true if it had not started.
+func (s *Scheduler) remove(req *request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.queue {
+		if r == req {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.stats.Expired++
+			return true
+		}
+	}
+	return false
+}
+
+// next removes and returns the request to run next, or nil if the queue is
+// empty.  Requests whose context is done are dropped.
+func (s *Scheduler) next(now time.Time) *request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	best := -1
+	var bestPrio int
+	for i := 0; i < len(s.queue); i++ {
+		r := s.queue[i]
+		if r.ctx.Err() != nil {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.stats.Expired++
+			r.done <- r.ctx.Err()
+			i--
+			continue
+		}
+		p := r.prio
+		if s.aging > 0 {
+			p += int(now.Sub(r.enqueued) / s.aging)
+		}
+		if best < 0 || p > bestPrio || (p == bestPrio && r.seq < s.queue[best].seq) {
+			best, bestPrio = i, p
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	r := s.queue[best]
+	s.queue = append(s.queue[:best], s.queue[best+1:]...)
+	s.stats.Run++
+	return r
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+	for {
+		r := s.next(time.Now())
+		if r != nil {
+			r.done <- s.env.Update(r.fn)
+			continue
+		}
+		s.mu.Lock()
+		closed := s.closed && len(s.queue) == 0
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+		<-s.wake
+	}
+}
+
+// Stats returns the counters of s.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.Queued = len(s.queue)
+	return st
+}
+
+// Close stops accepting updates, waits for the queued updates to run and
+// stops the goroutine of s.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.closed = true
+	s.mu.Unlock()
+	s.signal()
+	s.wg.Wait()
+	return nil
+}
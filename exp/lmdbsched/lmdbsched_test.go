@@ -0,0 +1,105 @@
+package lmdbsched
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestScheduler(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	s := New(env, nil)
+	defer s.Close()
+
+	// Hold the writer while the other updates are queued.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Update(context.Background(), 0, func(txn *lmdb.Txn) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	var mu sync.Mutex
+	var order []int
+	queue := func(ctx context.Context, prio int) chan error {
+		errc := make(chan error, 1)
+		n := s.Stats().Queued
+		go func() {
+			errc <- s.Update(ctx, prio, func(txn *lmdb.Txn) error {
+				mu.Lock()
+				order = append(order, prio)
+				mu.Unlock()
+				return nil
+			})
+		}()
+		for s.Stats().Queued == n {
+			time.Sleep(time.Millisecond)
+		}
+		return errc
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var errcs []chan error
+	errcs = append(errcs, queue(context.Background(), 1))
+	errcs = append(errcs, queue(context.Background(), 5))
+	expired := queue(ctx, 9)
+	errcs = append(errcs, queue(context.Background(), 1))
+	errcs = append(errcs, queue(context.Background(), 3))
+	cancel()
+	if err := <-expired; err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	close(release)
+	for _, errc := range errcs {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(order, []int{5, 3, 1, 1}) {
+		t.Errorf("order %v", order)
+	}
+	stats := s.Stats()
+	if stats.Run != 5 || stats.Expired != 1 || stats.Queued != 0 || stats.MaxQueue != 5 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Update(context.Background(), 0, func(txn *lmdb.Txn) error { return nil })
+	if err != ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestScheduler_aging(t *testing.T) {
+	s := &Scheduler{aging: time.Second}
+	now := time.Now()
+	s.queue = []*request{
+		{ctx: context.Background(), prio: 0, seq: 1, enqueued: now.Add(-3 * time.Second)},
+		{ctx: context.Background(), prio: 2, seq: 2, enqueued: now},
+	}
+	if r := s.next(now); r == nil || r.seq != 1 {
+		t.Errorf("aged request did not run first")
+	}
+}
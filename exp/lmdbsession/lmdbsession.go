@@ -0,0 +1,141 @@
+/*
+Package lmdbsession provides read-your-writes consistency for clients whose
+reads and writes are served by different processes sharing an environment.
+
+A write returns a Token, the ID of the transaction that committed it.  The
+client carries the token, for example in a cookie or request header, and
+presents it with later reads.  A process serving such a read calls View, which
+waits until the environment has committed the token's transaction and then
+runs the read in a snapshot that includes it.
+
+LMDB does not notify other processes of commits, so View polls the ID of the
+last committed transaction with an exponential backoff.  Polling reads the
+shared meta pages of the environment and is cheap.
+*/
+package lmdbsession
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Polling intervals used by Wait and View.
+const (
+	MinPollInterval = time.Millisecond
+	MaxPollInterval = 50 * time.Millisecond
+)
+
+// errStale is returned internally when a snapshot predates a token.
+var errStale = errors.New("lmdbsession: snapshot older than token")
+
+// Token identifies a committed write transaction.  The zero Token is
+// satisfied by every snapshot.
+type Token uint64
+
+// String returns the decimal representation of t.
+func (t Token) String() string {
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+// ParseToken parses a token formatted by Token.String.  An empty string is
+// parsed as the zero Token.
+func ParseToken(s string) (Token, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("lmdbsession: invalid token " + strconv.Quote(s))
+	}
+	return Token(n), nil
+}
+
+// Update runs fn in a write transaction like lmdb.Env.Update and returns the
+// Token of the transaction if it commits.
+func Update(env *lmdb.Env, fn lmdb.TxnOp) (Token, error) {
+	var t Token
+	err := env.Update(func(txn *lmdb.Txn) error {
+		txn.OnCommit(func(id uintptr) { t = Token(id) })
+		return fn(txn)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return t, nil
+}
+
+// Current returns a Token for the last transaction committed in env by any
+// process.
+func Current(env *lmdb.Env) (Token, error) {
+	id, err := env.LastTxnID()
+	if err != nil {
+		return 0, err
+	}
+	return Token(id), nil
+}
+
+// Wait blocks until the transaction of t has been committed in env or ctx is
+// done.
+func Wait(ctx context.Context, env *lmdb.Env, t Token) error {
+	p := poller{}
+	for {
+		cur, err := Current(env)
+		if err != nil {
+			return err
+		}
+		if cur >= t {
+			return nil
+		}
+		err = p.wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// View runs fn in a read-only transaction, like lmdb.Env.View, whose snapshot
+// includes the transaction of t.  View waits for that transaction to commit
+// until ctx is done, in which case the context's error is returned.
+func View(ctx context.Context, env *lmdb.Env, t Token, fn lmdb.TxnOp) error {
+	p := poller{}
+	for {
+		err := env.View(func(txn *lmdb.Txn) error {
+			if Token(txn.ID()) < t {
+				return errStale
+			}
+			return fn(txn)
+		})
+		if err != errStale {
+			return err
+		}
+		err = p.wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// poller sleeps between polls with an exponential backoff.
+type poller struct {
+	d time.Duration
+}
+
+func (p *poller) wait(ctx context.Context) error {
+	if p.d == 0 {
+		p.d = MinPollInterval
+	} else if p.d *= 2; p.d > MaxPollInterval {
+		p.d = MaxPollInterval
+	}
+	timer := time.NewTimer(p.d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package lmdbsession
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestParseToken(t *testing.T) {
+	tok, err := ParseToken(Token(42).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != 42 {
+		t.Errorf("unexpected token: %v", tok)
+	}
+	tok, err = ParseToken("")
+	if err != nil || tok != 0 {
+		t.Errorf("unexpected result: %v %v", tok, err)
+	}
+	_, err = ParseToken("x")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestView(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := Update(env, func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v1"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := Current(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok == 0 || tok != cur {
+		t.Errorf("unexpected token: %v (current %v)", tok, cur)
+	}
+
+	err = View(context.Background(), env, tok, func(txn *lmdb.Txn) error {
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "v1" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a token from the future blocks until the transaction commits.
+	done := make(chan error, 1)
+	go func() {
+		done <- View(context.Background(), env, tok+1, func(txn *lmdb.Txn) error {
+			v, err := txn.Get(dbi, []byte("k"))
+			if err != nil {
+				return err
+			}
+			if string(v) != "v2" {
+				t.Errorf("unexpected value: %q", v)
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("view did not wait: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	_, err = Update(env, func(txn *lmdb.Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v2"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Wait(ctx, env, tok+100)
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
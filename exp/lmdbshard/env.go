@@ -0,0 +1,384 @@
+package lmdbshard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/PowerDNS/lmdb-go/exp/lmdbsync"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Partitioner assigns keys to the shards of a ShardedEnv.
+type Partitioner interface {
+	// Partition returns the shard of key, between 0 and n-1.
+	Partition(key []byte, n int) int
+}
+
+// Hash returns a Partitioner assigning keys to shards by their hash, which
+// spreads any key distribution evenly.  If hash is nil DefaultHash is used.
+func Hash(hash HashFunc) Partitioner {
+	if hash == nil {
+		hash = DefaultHash
+	}
+	return hashPartitioner(hash)
+}
+
+type hashPartitioner HashFunc
+
+func (p hashPartitioner) Partition(key []byte, n int) int {
+	return int(p(key) % uint64(n))
+}
+
+// Range returns a Partitioner assigning keys to shards by range: shard 0
+// holds the keys before bounds[0], shard i the keys from bounds[i-1] up to
+// bounds[i] and the last shard the keys from the last bound.  The bounds must
+// be in increasing order and the partitioner used with len(bounds)+1 shards.
+func Range(bounds ...[]byte) Partitioner {
+	return rangePartitioner(bounds)
+}
+
+type rangePartitioner [][]byte
+
+func (p rangePartitioner) Partition(key []byte, n int) int {
+	return sort.Search(len(p), func(i int) bool {
+		return bytes.Compare(key, p[i]) < 0
+	})
+}
+
+// TxnOp is a transaction run on a shard of a ShardedEnv.
+type TxnOp func(txn *lmdb.Txn, shard int) error
+
+// DBI holds the handles of a database in each shard, indexed by shard.
+type DBI []lmdb.DBI
+
+// ShardedEnv partitions keys across several environments, the shards, so that
+// updates of keys in different shards run concurrently instead of queueing
+// for the single writer of an environment.
+//
+// Transactions run on one shard at a time.  Operations spanning shards, such
+// as UpdateAll and UpdateKeys, commit a transaction per shard: they are
+// neither atomic nor isolated across shards.
+type ShardedEnv struct {
+	envs []*lmdbsync.Env
+	part Partitioner
+}
+
+// Options configure the environments opened by OpenEnv.
+type Options struct {
+	MapSize    int64       // Map size of each shard
+	MaxDBs     int         // Maximum number of named databases
+	MaxReaders int         // Maximum number of readers
+	Flags      uint        // Flags passed to lmdb.Env.Open
+	Mode       os.FileMode // Mode of the created files, 0644 if not set
+
+	// Handlers handle the transaction errors of each shard, see
+	// lmdbsync.NewEnv.
+	Handlers []lmdbsync.Handler
+}
+
+// NewEnv returns a ShardedEnv over the open environments envs, assigning keys
+// to them with p.  The shards must always be given in the same order.
+func NewEnv(envs []*lmdbsync.Env, p Partitioner) (*ShardedEnv, error) {
+	if len(envs) == 0 {
+		return nil, errors.New("lmdbshard: no shards")
+	}
+	if r, ok := p.(rangePartitioner); ok && len(r)+1 != len(envs) {
+		return nil, fmt.Errorf("lmdbshard: %d range bounds for %d shards", len(r), len(envs))
+	}
+	return &ShardedEnv{envs: envs, part: p}, nil
+}
+
+// OpenEnv opens a ShardedEnv of n shards stored in dir, which is created if
+// needed.  Shard i is stored in the subdirectory, or with lmdb.NoSubdir the
+// file, named after i with three digits.
+func OpenEnv(dir string, n int, p Partitioner, opt *Options) (*ShardedEnv, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	mode := opt.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	envs := make([]*lmdbsync.Env, 0, n)
+	for i := 0; i < n; i++ {
+		env, err := openShard(filepath.Join(dir, fmt.Sprintf("%03d", i)), mode, opt)
+		if err != nil {
+			closeAll(envs)
+			return nil, fmt.Errorf("lmdbshard: shard %d: %w", i, err)
+		}
+		envs = append(envs, env)
+	}
+	s, err := NewEnv(envs, p)
+	if err != nil {
+		closeAll(envs)
+		return nil, err
+	}
+	return s, nil
+}
+
+func openShard(path string, mode os.FileMode, opt *Options) (*lmdbsync.Env, error) {
+	if opt.Flags&lmdb.NoSubdir == 0 {
+		err := os.MkdirAll(path, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	if opt.MapSize > 0 {
+		err = env.SetMapSize(opt.MapSize)
+	}
+	if err == nil && opt.MaxDBs > 0 {
+		err = env.SetMaxDBs(opt.MaxDBs)
+	}
+	if err == nil && opt.MaxReaders > 0 {
+		err = env.SetMaxReaders(opt.MaxReaders)
+	}
+	var senv *lmdbsync.Env
+	if err == nil {
+		senv, err = lmdbsync.NewEnv(env, opt.Handlers...)
+	}
+	if err == nil {
+		err = senv.Open(path, opt.Flags, mode)
+	}
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	return senv, nil
+}
+
+func closeAll(envs []*lmdbsync.Env) {
+	for _, env := range envs {
+		env.Close()
+	}
+}
+
+// Close closes the environments of all shards.
+func (s *ShardedEnv) Close() error {
+	var err error
+	for _, env := range s.envs {
+		cerr := env.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Len returns the number of shards.
+func (s *ShardedEnv) Len() int {
+	return len(s.envs)
+}
+
+// Env returns the environment of shard i.
+func (s *ShardedEnv) Env(i int) *lmdbsync.Env {
+	return s.envs[i]
+}
+
+// Shard returns the shard holding key.
+func (s *ShardedEnv) Shard(key []byte) int {
+	return s.part.Partition(key, len(s.envs))
+}
+
+// OpenDBI opens the named database in every shard, see lmdb.Txn.OpenDBI.
+func (s *ShardedEnv) OpenDBI(name string, flags uint) (DBI, error) {
+	dbi := make(DBI, len(s.envs))
+	err := s.eachShard(false, func(i int) error {
+		return s.envs[i].Update(func(txn *lmdb.Txn) (err error) {
+			dbi[i], err = txn.OpenDBI(name, flags)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dbi, nil
+}
+
+// View runs op in a read-only transaction on the shard holding key.
+func (s *ShardedEnv) View(key []byte, op TxnOp) error {
+	i := s.Shard(key)
+	return s.envs[i].View(func(txn *lmdb.Txn) error { return op(txn, i) })
+}
+
+// Update runs op in a write transaction on the shard holding key.
+func (s *ShardedEnv) Update(key []byte, op TxnOp) error {
+	i := s.Shard(key)
+	return s.envs[i].Update(func(txn *lmdb.Txn) error { return op(txn, i) })
+}
+
+// ViewAll runs op in a read-only transaction on each shard in turn.  The
+// transactions do not share a snapshot.
+func (s *ShardedEnv) ViewAll(op TxnOp) error {
+	return s.eachShard(false, func(i int) error {
+		return s.envs[i].View(func(txn *lmdb.Txn) error { return op(txn, i) })
+	})
+}
+
+// UpdateAll runs op in a write transaction on every shard concurrently and
+// returns the error of the lowest failed shard.  The transactions of the
+// other shards commit regardless.
+func (s *ShardedEnv) UpdateAll(op TxnOp) error {
+	return s.eachShard(true, func(i int) error {
+		return s.envs[i].Update(func(txn *lmdb.Txn) error { return op(txn, i) })
+	})
+}
+
+// UpdateKeys groups keys by shard and runs op concurrently in a write
+// transaction on each shard holding any of them, with the keys of that shard
+// in their original order.  Like UpdateAll it returns the error of the lowest
+// failed shard.
+func (s *ShardedEnv) UpdateKeys(keys [][]byte, op func(txn *lmdb.Txn, shard int, keys [][]byte) error) error {
+	groups := make([][][]byte, len(s.envs))
+	for _, k := range keys {
+		i := s.Shard(k)
+		groups[i] = append(groups[i], k)
+	}
+	return s.eachShard(true, func(i int) error {
+		if len(groups[i]) == 0 {
+			return nil
+		}
+		return s.envs[i].Update(func(txn *lmdb.Txn) error { return op(txn, i, groups[i]) })
+	})
+}
+
+// Get returns a copy of the value of key in dbi.
+func (s *ShardedEnv) Get(dbi DBI, key []byte) ([]byte, error) {
+	var val []byte
+	err := s.View(key, func(txn *lmdb.Txn, i int) (err error) {
+		val, err = txn.Get(dbi[i], key)
+		return err
+	})
+	return val, err
+}
+
+// Put stores val for key in dbi, in a transaction of its own.
+func (s *ShardedEnv) Put(dbi DBI, key, val []byte, flags uint) error {
+	return s.Update(key, func(txn *lmdb.Txn, i int) error {
+		return txn.Put(dbi[i], key, val, flags)
+	})
+}
+
+// Scan calls fn for each item of dbi in all shards, in key order as given by
+// bytes.Compare, reading each shard in a read-only transaction held for the
+// whole scan.  The slices passed to fn are only valid until fn returns.  Scan
+// stops and returns the first error returned by fn.
+func (s *ShardedEnv) Scan(dbi DBI, fn func(key, val []byte) error) error {
+	return s.viewNested(make([]*lmdb.Txn, 0, len(s.envs)), func(txns []*lmdb.Txn) error {
+		return merge(txns, dbi, fn)
+	})
+}
+
+// viewNested runs fn with read-only transactions on all shards, nesting the
+// View of each shard in that of the previous one.
+func (s *ShardedEnv) viewNested(txns []*lmdb.Txn, fn func([]*lmdb.Txn) error) error {
+	if len(txns) == len(s.envs) {
+		return fn(txns)
+	}
+	return s.envs[len(txns)].View(func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+		return s.viewNested(append(txns, txn), fn)
+	})
+}
+
+// merge calls fn for the items of dbi in txns in key order.
+func merge(txns []*lmdb.Txn, dbi DBI, fn func(key, val []byte) error) error {
+	type head struct {
+		cur      *lmdb.Cursor
+		key, val []byte
+	}
+	var heads []*head
+	defer func() {
+		for _, h := range heads {
+			h.cur.Close()
+		}
+	}()
+	next := func(h *head, op uint) (bool, error) {
+		var err error
+		h.key, h.val, err = h.cur.Get(nil, nil, op)
+		if lmdb.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	var live []*head
+	for i, txn := range txns {
+		cur, err := txn.OpenCursor(dbi[i])
+		if err != nil {
+			return err
+		}
+		h := &head{cur: cur}
+		heads = append(heads, h)
+		ok, err := next(h, lmdb.First)
+		if err != nil {
+			return err
+		}
+		if ok {
+			live = append(live, h)
+		}
+	}
+	for len(live) > 0 {
+		min := 0
+		for j := 1; j < len(live); j++ {
+			if bytes.Compare(live[j].key, live[min].key) < 0 {
+				min = j
+			}
+		}
+		h := live[min]
+		err := fn(h.key, h.val)
+		if err != nil {
+			return err
+		}
+		ok, err := next(h, lmdb.Next)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			live = append(live[:min], live[min+1:]...)
+		}
+	}
+	return nil
+}
+
+// eachShard calls fn for every shard, concurrently if parallel is true, and
+// returns the error of the lowest failed shard.  Sequential calls stop at the
+// first error.
+func (s *ShardedEnv) eachShard(parallel bool, fn func(i int) error) error {
+	if !parallel {
+		for i := range s.envs {
+			err := fn(i)
+			if err != nil {
+				return fmt.Errorf("lmdbshard: shard %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+	errs := make([]error, len(s.envs))
+	var wg sync.WaitGroup
+	for i := range s.envs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("lmdbshard: shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
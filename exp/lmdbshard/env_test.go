@@ -0,0 +1,127 @@
+package lmdbshard
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func openTestEnv(t *testing.T, n int, p Partitioner) *ShardedEnv {
+	dir, err := ioutil.TempDir("", "lmdbshard-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	senv, err := OpenEnv(dir, n, p, &Options{MapSize: 1 << 20, MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { senv.Close() })
+	return senv
+}
+
+func TestShardedEnv(t *testing.T) {
+	senv := openTestEnv(t, 4, Hash(nil))
+	dbi, err := senv.OpenDBI("db", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("k%03d", i)))
+	}
+	err = senv.UpdateKeys(keys, func(txn *lmdb.Txn, shard int, keys [][]byte) error {
+		for _, k := range keys {
+			if senv.Shard(k) != shard {
+				return fmt.Errorf("key %q in shard %d", k, shard)
+			}
+			err := txn.Put(dbi[shard], k, k[1:], 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make([]int, senv.Len())
+	err = senv.ViewAll(func(txn *lmdb.Txn, shard int) error {
+		stat, err := txn.Stat(dbi[shard])
+		if err != nil {
+			return err
+		}
+		counts[shard] = int(stat.Entries)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range counts {
+		if n == 0 {
+			t.Errorf("shard %d is empty: %v", i, counts)
+		}
+	}
+
+	var i int
+	err = senv.Scan(dbi, func(k, v []byte) error {
+		if string(k) != string(keys[i]) || string(v) != string(keys[i][1:]) {
+			t.Errorf("item %d: %q=%q", i, k, v)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(keys) {
+		t.Errorf("scanned %d items", i)
+	}
+
+	err = senv.Put(dbi, []byte("x"), []byte("y"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := senv.Get(dbi, []byte("x"))
+	if err != nil || string(v) != "y" {
+		t.Errorf("get: %q %v", v, err)
+	}
+	_, err = senv.Get(dbi, []byte("missing"))
+	if !lmdb.IsNotFound(err) {
+		t.Errorf("get missing: %v", err)
+	}
+
+	err = senv.UpdateAll(func(txn *lmdb.Txn, shard int) error {
+		if shard == 2 {
+			return fmt.Errorf("fail")
+		}
+		return txn.Drop(dbi[shard], false)
+	})
+	if err == nil || err.Error() != "lmdbshard: shard 2: fail" {
+		t.Errorf("update all: %v", err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	p := Range([]byte("g"), []byte("p"))
+	for key, shard := range map[string]int{"": 0, "a": 0, "g": 1, "o": 1, "p": 2, "z": 2} {
+		if i := p.Partition([]byte(key), 3); i != shard {
+			t.Errorf("key %q: shard %d (expect %d)", key, i, shard)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "lmdbshard-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = OpenEnv(dir, 2, p, nil)
+	if err == nil {
+		t.Errorf("expected error for mismatched bounds")
+	}
+}
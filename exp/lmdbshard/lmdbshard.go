@@ -0,0 +1,200 @@
+/*
+Package lmdbshard spreads keys across a database by prefixing each key with a
+few bytes of its hash.
+
+Inserting monotonically increasing keys, such as timestamps or sequence
+numbers, always appends to the rightmost leaf page of the B-tree.  LMDB handles
+pure appends well (see lmdb.Append) but in mixed workloads the right edge
+becomes a hot spot where every insert dirties and splits the same pages.
+Prefixing keys with a hash distributes them uniformly over 256^N ranges of the
+key space at the cost of their global order: a Shard can only iterate keys in
+order within a single shard.
+
+Every process using a database must use a Shard with the same prefix length
+and hash function.
+
+# Sharded environments
+
+A write transaction holds the single writer lock of its environment, which
+limits write throughput on machines with many cores.  A ShardedEnv instead
+partitions keys across several environments, by hash or by range, so that
+writes to different shards proceed in parallel:
+
+	senv, err := lmdbshard.OpenEnv(dir, 8, lmdbshard.Hash(nil), &lmdbshard.Options{
+		MapSize: 1 << 30,
+		MaxDBs:  4,
+	})
+	if err != nil {
+		return err
+	}
+	dbi, err := senv.OpenDBI("events", lmdb.Create)
+	if err != nil {
+		return err
+	}
+	err = senv.UpdateKeys(keys, func(txn *lmdb.Txn, shard int, keys [][]byte) error {
+		for _, k := range keys {
+			err := txn.Put(dbi[shard], k, val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+Each shard is an lmdbsync.Env, so that Options.Handlers can resize shards
+independently.  Transactions never span shards.
+*/
+package lmdbshard
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// MaxPrefix is the maximum length of a shard prefix in bytes.
+const MaxPrefix = 8
+
+// ErrShortKey is returned by Shard.Strip when a key is too short to have a
+// shard prefix.
+var ErrShortKey = errors.New("lmdbshard: key shorter than shard prefix")
+
+// HashFunc computes the hash of a key.  The most significant bytes of the hash
+// become the shard prefix.
+type HashFunc func(key []byte) uint64
+
+// DefaultHash is the HashFunc used when none is given to New.  It is the
+// 64-bit FNV-1a hash of the key passed through the MurmurHash3 finalizer.
+// Plain FNV-1a is unsuitable because its most significant bytes barely change
+// when only the last bytes of a key differ, as in sequential keys.
+func DefaultHash(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Shard prepends and strips shard prefixes.
+type Shard struct {
+	n    int
+	hash HashFunc
+}
+
+// New returns a Shard which prefixes keys with n bytes of their hash.  If hash
+// is nil FNV1a is used.  New panics if n is not between 1 and MaxPrefix.
+func New(n int, hash HashFunc) *Shard {
+	if n < 1 || n > MaxPrefix {
+		panic("lmdbshard: invalid prefix length")
+	}
+	if hash == nil {
+		hash = DefaultHash
+	}
+	return &Shard{n: n, hash: hash}
+}
+
+// Len returns the length of the shard prefix in bytes.
+func (s *Shard) Len() int {
+	return s.n
+}
+
+// Prefix returns the shard prefix of key.
+func (s *Shard) Prefix(key []byte) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], s.hash(key))
+	return b[:s.n]
+}
+
+// Key returns key with its shard prefix prepended.
+func (s *Shard) Key(key []byte) []byte {
+	skey := make([]byte, 0, s.n+len(key))
+	skey = append(skey, s.Prefix(key)...)
+	return append(skey, key...)
+}
+
+// Strip returns the key which was prefixed to produce skey.  The returned
+// slice references skey.
+func (s *Shard) Strip(skey []byte) ([]byte, error) {
+	if len(skey) < s.n {
+		return nil, ErrShortKey
+	}
+	return skey[s.n:], nil
+}
+
+// Get retrieves the value of key from dbi.
+func (s *Shard) Get(txn *lmdb.Txn, dbi lmdb.DBI, key []byte) ([]byte, error) {
+	return txn.Get(dbi, s.Key(key))
+}
+
+// Put stores val for key in dbi.
+func (s *Shard) Put(txn *lmdb.Txn, dbi lmdb.DBI, key, val []byte, flags uint) error {
+	return txn.Put(dbi, s.Key(key), val, flags)
+}
+
+// Del deletes key, or the item key/val in a DupSort database, from dbi.
+func (s *Shard) Del(txn *lmdb.Txn, dbi lmdb.DBI, key, val []byte) error {
+	return txn.Del(dbi, s.Key(key), val)
+}
+
+// Scan calls fn for each item in dbi whose key was prefixed by s.  Items are
+// visited in database order, which is ordered by shard prefix first, so keys
+// are only in order within the items of a shard.  Scan stops and returns the
+// first error returned by fn.
+func (s *Shard) Scan(txn *lmdb.Txn, dbi lmdb.DBI, fn func(key, val []byte) error) error {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for {
+		skey, val, err := cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := s.Strip(skey)
+		if err != nil {
+			return err
+		}
+		err = fn(key, val)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ScanShard is like Scan but only visits the items in the shard which key
+// belongs to.  Keys are visited in order.
+func (s *Shard) ScanShard(txn *lmdb.Txn, dbi lmdb.DBI, key []byte, fn func(key, val []byte) error) error {
+	prefix := s.Prefix(key)
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	skey, val, err := cur.Get(prefix, nil, lmdb.SetRange)
+	for {
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(skey) < s.n || string(skey[:s.n]) != string(prefix) {
+			return nil
+		}
+		err = fn(skey[s.n:], val)
+		if err != nil {
+			return err
+		}
+		skey, val, err = cur.Get(nil, nil, lmdb.Next)
+	}
+}
@@ -0,0 +1,121 @@
+package lmdbshard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestShard_Key(t *testing.T) {
+	s := New(2, nil)
+	key := []byte("2016-01-01T00:00:00Z")
+	skey := s.Key(key)
+	if len(skey) != len(key)+2 {
+		t.Fatalf("unexpected key length: %d", len(skey))
+	}
+	if !bytes.Equal(skey[:2], s.Prefix(key)) {
+		t.Errorf("unexpected prefix: %x", skey[:2])
+	}
+	k, err := s.Strip(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k, key) {
+		t.Errorf("unexpected key: %q", k)
+	}
+	_, err = s.Strip([]byte("x"))
+	if err != ErrShortKey {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestShard(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(1, nil)
+	const n = 1000
+	key := func(i int) []byte {
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, uint64(i))
+		return k
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < n; i++ {
+			err = s.Put(txn, dbi, key(i), key(i), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return s.Del(txn, dbi, key(0), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		v, err := s.Get(txn, dbi, key(7))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(v, key(7)) {
+			t.Errorf("unexpected value: %x", v)
+		}
+
+		seen := make(map[string]bool)
+		ordered := true
+		var last []byte
+		err = s.Scan(txn, dbi, func(k, v []byte) error {
+			if !bytes.Equal(k, v) {
+				t.Errorf("unexpected item: %x %x", k, v)
+			}
+			if last != nil && bytes.Compare(k, last) < 0 {
+				ordered = false
+			}
+			last = append(last[:0], k...)
+			seen[string(k)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(seen) != n-1 || seen[string(key(0))] {
+			t.Errorf("unexpected number of keys: %d", len(seen))
+		}
+		if ordered {
+			t.Errorf("sequential keys were not spread across shards")
+		}
+
+		last = nil
+		count := 0
+		err = s.ScanShard(txn, dbi, key(7), func(k, v []byte) error {
+			if !bytes.Equal(s.Prefix(k), s.Prefix(key(7))) {
+				t.Errorf("key %x not in shard", k)
+			}
+			if last != nil && bytes.Compare(k, last) <= 0 {
+				t.Errorf("keys out of order: %x %x", last, k)
+			}
+			last = append(last[:0], k...)
+			count++
+			return nil
+		})
+		if count == 0 || count == n-1 {
+			t.Errorf("unexpected shard size: %d", count)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Package lmdbsimple is a key-value interface to an LMDB database for
+applications which want the performance of LMDB without managing transactions
+and cursors.
+
+Every method of a DB runs in a transaction of its own, managed internally.
+Values returned by Get and passed to Scan callbacks are copies, so they may be
+kept after the method returns.  Reads renew read-only transactions kept in a
+pool instead of beginning new ones.
+
+	db, err := lmdbsimple.Open("/var/db/example", nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Set(ctx, []byte("user:1"), []byte("alice"))
+	if err != nil {
+		return err
+	}
+	val, err := db.Get(ctx, []byte("user:1"))
+	if errors.Is(err, lmdb.ErrNotFound) {
+		// ...
+	}
+	err = db.Scan(ctx, &lmdbsimple.ScanOptions{Prefix: []byte("user:")}, func(key, val []byte) error {
+		// ...
+		return nil
+	})
+
+Methods check their context before starting a transaction, and Scan checks it
+periodically while iterating.  A write waiting for the writer lock of the
+environment cannot be cancelled.
+*/
+package lmdbsimple
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// ErrClosed is returned by the methods of a closed DB.
+var ErrClosed = errors.New("lmdbsimple: database is closed")
+
+// scanCheckInterval is the number of items Scan visits between checks of its
+// context.
+const scanCheckInterval = 256
+
+// Options configure a DB opened by Open.
+type Options struct {
+	// DBName is the name of the database used.  If empty the root database
+	// is used.
+	DBName string
+
+	// MapSize is the size of the memory map.  If zero the LMDB default is
+	// used.
+	MapSize int64
+
+	// Flags are passed to lmdb.Env.Open.
+	Flags uint
+
+	// Mode of the created files, 0644 if not set.
+	Mode os.FileMode
+
+	// PoolSize is the number of read-only transactions kept for reuse.  If
+	// zero twice runtime.GOMAXPROCS is used.
+	PoolSize int
+}
+
+// Item is a key and its value.
+type Item struct {
+	Key, Val []byte
+}
+
+// ScanOptions select the items visited by Scan.  The zero ScanOptions visit
+// all items in key order.
+type ScanOptions struct {
+	Prefix  []byte // Only visit keys with this prefix
+	Start   []byte // Only visit keys from Start, inclusive
+	End     []byte // Only visit keys before End, exclusive
+	Limit   int    // Stop after Limit items if positive
+	Reverse bool   // Visit keys in descending order
+}
+
+// DB is a database with transactions managed internally.  A DB is safe for
+// concurrent use.
+type DB struct {
+	env   *lmdb.Env
+	dbi   lmdb.DBI
+	owned bool
+	pool  chan *lmdb.Txn
+
+	// mu is held for reading by running methods and for writing by Close.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Open opens the environment in the directory path, which is created if it
+// does not exist, and returns a DB using it.  A nil opt uses the zero
+// Options.  Close closes the environment.
+func Open(path string, opt *Options) (*DB, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	db, err := open(env, path, opt)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	db.owned = true
+	return db, nil
+}
+
+func open(env *lmdb.Env, path string, opt *Options) (*DB, error) {
+	if opt.MapSize > 0 {
+		err := env.SetMapSize(opt.MapSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.DBName != "" {
+		err := env.SetMaxDBs(1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mode := opt.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if opt.Flags&lmdb.NoSubdir == 0 {
+		err := os.MkdirAll(path, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := env.Open(path, opt.Flags, mode)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(env, opt)
+}
+
+// Wrap returns a DB using env, which must be open.  Only the DBName and
+// PoolSize options are used.  Close does not close env.
+func Wrap(env *lmdb.Env, opt *Options) (*DB, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	size := opt.PoolSize
+	if size <= 0 {
+		size = 2 * runtime.GOMAXPROCS(0)
+	}
+	db := &DB{env: env, pool: make(chan *lmdb.Txn, size)}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		if opt.DBName == "" {
+			db.dbi, err = txn.OpenRoot(0)
+		} else {
+			db.dbi, err = txn.OpenDBI(opt.DBName, lmdb.Create)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Env returns the environment of db.
+func (db *DB) Env() *lmdb.Env {
+	return db.env
+}
+
+// Close aborts the pooled transactions and closes the environment if it was
+// opened by Open.  Close waits for running methods to return.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	for len(db.pool) > 0 {
+		(<-db.pool).Abort()
+	}
+	if db.owned {
+		return db.env.Close()
+	}
+	return nil
+}
+
+// view runs fn in a read-only transaction taken from the pool.
+func (db *DB) view(ctx context.Context, fn lmdb.TxnOp) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	var txn *lmdb.Txn
+	select {
+	case txn = <-db.pool:
+		txn.Pooled = false
+		err = txn.Renew()
+		if err != nil {
+			txn.Abort()
+			return err
+		}
+	default:
+		txn, err = db.env.BeginTxn(nil, lmdb.Readonly)
+		if err != nil {
+			return err
+		}
+	}
+	txn.RawRead = false
+	err = txn.RunOp(fn, false)
+	txn.Reset()
+	txn.Pooled = true
+	select {
+	case db.pool <- txn:
+	default:
+		txn.Abort()
+	}
+	return err
+}
+
+// update runs fn in a write transaction.
+func (db *DB) update(ctx context.Context, fn lmdb.TxnOp) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return db.env.Update(fn)
+}
+
+// Get returns a copy of the value of key.  If key does not exist Get returns
+// an error for which errors.Is(err, lmdb.ErrNotFound) is true.
+func (db *DB) Get(ctx context.Context, key []byte) ([]byte, error) {
+	var val []byte
+	err := db.view(ctx, func(txn *lmdb.Txn) (err error) {
+		val, err = txn.Get(db.dbi, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// Has returns true if key exists.
+func (db *DB) Has(ctx context.Context, key []byte) (bool, error) {
+	var found bool
+	err := db.view(ctx, func(txn *lmdb.Txn) error {
+		txn.RawRead = true
+		_, err := txn.Get(db.dbi, key)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	return found, err
+}
+
+// Set stores val under key, replacing any previous value.
+func (db *DB) Set(ctx context.Context, key, val []byte) error {
+	return db.update(ctx, func(txn *lmdb.Txn) error {
+		return txn.Put(db.dbi, key, val, 0)
+	})
+}
+
+// SetMany stores items in a single transaction.  Either all items are stored
+// or none.
+func (db *DB) SetMany(ctx context.Context, items []Item) error {
+	return db.update(ctx, func(txn *lmdb.Txn) error {
+		for _, item := range items {
+			err := txn.Put(db.dbi, item.Key, item.Val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete deletes key.  Deleting a key that does not exist is not an error.
+func (db *DB) Delete(ctx context.Context, key []byte) error {
+	return db.update(ctx, func(txn *lmdb.Txn) error {
+		err := txn.Del(db.dbi, key, nil)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Scan calls fn with copies of the items selected by opt, in a single
+// read-only transaction.  A nil opt visits all items.  Scan stops and returns
+// the first error returned by fn, or the error of ctx once it is done.
+func (db *DB) Scan(ctx context.Context, opt *ScanOptions, fn func(key, val []byte) error) error {
+	if opt == nil {
+		opt = &ScanOptions{}
+	}
+	return db.view(ctx, func(txn *lmdb.Txn) error {
+		cur, err := txn.OpenCursor(db.dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		key, val, err := seek(cur, opt)
+		for n := 0; opt.Limit <= 0 || n < opt.Limit; n++ {
+			if lmdb.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !inRange(key, opt) {
+				return nil
+			}
+			if n%scanCheckInterval == scanCheckInterval-1 {
+				err = ctx.Err()
+				if err != nil {
+					return err
+				}
+			}
+			err = fn(key, val)
+			if err != nil {
+				return err
+			}
+			if opt.Reverse {
+				key, val, err = cur.Get(nil, nil, lmdb.Prev)
+			} else {
+				key, val, err = cur.Get(nil, nil, lmdb.Next)
+			}
+		}
+		return nil
+	})
+}
+
+// seek positions cur at the first item selected by opt, skipping keys which
+// do not have the prefix but sort inside it.
+func seek(cur *lmdb.Cursor, opt *ScanOptions) (key, val []byte, err error) {
+	if !opt.Reverse {
+		start := opt.Start
+		if bytes.Compare(opt.Prefix, start) > 0 {
+			start = opt.Prefix
+		}
+		if len(start) == 0 {
+			return cur.Get(nil, nil, lmdb.First)
+		}
+		return cur.Get(start, nil, lmdb.SetRange)
+	}
+
+	// In reverse, start before the first key past both End and the keys
+	// with the prefix.
+	end := opt.End
+	if len(opt.Prefix) > 0 {
+		if p := prefixEnd(opt.Prefix); p != nil && (end == nil || bytes.Compare(p, end) < 0) {
+			end = p
+		}
+	}
+	if end == nil {
+		return cur.Get(nil, nil, lmdb.Last)
+	}
+	key, val, err = cur.Get(end, nil, lmdb.SetRange)
+	if lmdb.IsNotFound(err) {
+		return cur.Get(nil, nil, lmdb.Last)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return cur.Get(nil, nil, lmdb.Prev)
+}
+
+// inRange returns true if key is selected by opt, assuming the scan started
+// at the position returned by seek.
+func inRange(key []byte, opt *ScanOptions) bool {
+	if !bytes.HasPrefix(key, opt.Prefix) {
+		return false
+	}
+	if opt.Reverse {
+		return len(opt.Start) == 0 || bytes.Compare(key, opt.Start) >= 0
+	}
+	return opt.End == nil || bytes.Compare(key, opt.End) < 0
+}
+
+// prefixEnd returns the smallest key greater than all keys with prefix p, or
+// nil if there is none.
+func prefixEnd(p []byte) []byte {
+	end := append([]byte(nil), p...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
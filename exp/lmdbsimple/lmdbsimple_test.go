@@ -0,0 +1,138 @@
+package lmdbsimple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func openDB(t *testing.T) *DB {
+	dir, err := ioutil.TempDir("", "lmdbsimple-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	db, err := Open(dir, &Options{DBName: "kv", MapSize: 1 << 20, PoolSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDB(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	err := db.Set(ctx, []byte("a"), []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Has leaves RawRead set on the pooled transaction, which Get must not
+	// inherit.
+	ok, err := db.Has(ctx, []byte("a"))
+	if err != nil || !ok {
+		t.Errorf("has: %v %v", ok, err)
+	}
+	val, err := db.Get(ctx, []byte("a"))
+	if err != nil || string(val) != "1" {
+		t.Errorf("get: %q %v", val, err)
+	}
+	err = db.Set(ctx, []byte("a"), []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val[0] = 'x'
+	val, err = db.Get(ctx, []byte("a"))
+	if err != nil || string(val) != "2" {
+		t.Errorf("get after set: %q %v", val, err)
+	}
+
+	err = db.Delete(ctx, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Delete(ctx, []byte("a"))
+	if err != nil {
+		t.Errorf("delete missing key: %v", err)
+	}
+	_, err = db.Get(ctx, []byte("a"))
+	if !errors.Is(err, lmdb.ErrNotFound) {
+		t.Errorf("get deleted key: %v", err)
+	}
+	ok, err = db.Has(ctx, []byte("a"))
+	if err != nil || ok {
+		t.Errorf("has deleted key: %v %v", ok, err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = db.Set(cctx, []byte("b"), nil)
+	if err != context.Canceled {
+		t.Errorf("set with cancelled context: %v", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Get(ctx, []byte("a"))
+	if err != ErrClosed {
+		t.Errorf("get after close: %v", err)
+	}
+}
+
+func TestDB_Scan(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	var items []Item
+	for _, k := range []string{"a", "b1", "b2", "b3", "c", "d"} {
+		items = append(items, Item{[]byte(k), []byte("v" + k)})
+	}
+	err := db.SetMany(ctx, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		opt  *ScanOptions
+		keys []string
+	}{
+		{nil, []string{"a", "b1", "b2", "b3", "c", "d"}},
+		{&ScanOptions{Prefix: []byte("b")}, []string{"b1", "b2", "b3"}},
+		{&ScanOptions{Prefix: []byte("b"), Reverse: true}, []string{"b3", "b2", "b1"}},
+		{&ScanOptions{Start: []byte("b2"), End: []byte("c")}, []string{"b2", "b3"}},
+		{&ScanOptions{Start: []byte("b2"), End: []byte("c"), Reverse: true}, []string{"b3", "b2"}},
+		{&ScanOptions{Reverse: true, Limit: 2}, []string{"d", "c"}},
+		{&ScanOptions{End: []byte("zz"), Reverse: true, Limit: 1}, []string{"d"}},
+		{&ScanOptions{Prefix: []byte("e")}, nil},
+	} {
+		var keys []string
+		err := db.Scan(ctx, test.opt, func(key, val []byte) error {
+			if string(val) != "v"+string(key) {
+				return fmt.Errorf("value %q of key %q", val, key)
+			}
+			keys = append(keys, string(key))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("scan %+v: %q (expect %q)", test.opt, keys, test.keys)
+		}
+	}
+
+	errStop := errors.New("stop")
+	err = db.Scan(ctx, nil, func(key, val []byte) error { return errStop })
+	if err != errStop {
+		t.Errorf("scan error: %v", err)
+	}
+}
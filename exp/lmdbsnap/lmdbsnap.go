@@ -0,0 +1,293 @@
+/*
+Package lmdbsnap manages long-lived read transactions for applications that
+run several reads against one consistent snapshot of an environment.
+
+A read-only lmdb.Txn can be reset, which releases its snapshot but keeps its
+reader slot, and renewed, which takes a new snapshot without the cost of
+beginning a transaction.  Getting this right by hand is subtle: a transaction
+that is never reset pins old pages and makes the database grow, and one that
+is never aborted leaks a reader slot.  A Manager keeps a small pool of reset
+transactions and hands them out as Snapshots, which are renewed when acquired
+and reset when released.
+
+A Snapshot may be passed between goroutines, because package lmdb opens every
+environment with lmdb.NoTLS, but it must not be used by several goroutines at
+once.  Its methods serialize use to enforce this.
+*/
+package lmdbsnap
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultMaxIdle is the number of reset transactions a Manager keeps when
+// Options.MaxIdle is zero.
+const DefaultMaxIdle = 4
+
+var (
+	// ErrClosed is returned by Acquire after the Manager has been closed.
+	ErrClosed = errors.New("lmdbsnap: manager is closed")
+
+	// ErrReleased is returned by the methods of a Snapshot after it has
+	// been released.
+	ErrReleased = errors.New("lmdbsnap: snapshot is released")
+)
+
+// Options configure a Manager.
+type Options struct {
+	// MaxIdle is the number of reset transactions kept for reuse.  Released
+	// transactions beyond it are aborted, releasing their reader slot.
+	MaxIdle int
+
+	// IdleTimeout is how long a reset transaction is kept unused before it
+	// is aborted.  Zero keeps transactions until the Manager is closed.
+	IdleTimeout time.Duration
+}
+
+// Stats are counters of a Manager.
+type Stats struct {
+	Active  int    // Snapshots acquired and not released
+	Idle    int    // Reset transactions kept for reuse
+	Begun   uint64 // Transactions begun
+	Renewed uint64 // Transactions renewed, by Acquire or Refresh
+	Aborted uint64 // Transactions aborted
+}
+
+type idleTxn struct {
+	txn   *lmdb.Txn
+	since time.Time
+}
+
+// Manager hands out Snapshots of an environment backed by pooled read
+// transactions.  A Manager is safe for concurrent use.
+type Manager struct {
+	env     *lmdb.Env
+	maxIdle int
+	timeout time.Duration
+
+	mu     sync.Mutex
+	idle   []idleTxn // Ordered by the time they were released
+	active int
+	closed bool
+	stats  Stats
+}
+
+// New returns a Manager of snapshots of env.  A nil opt uses the zero Options.
+// The Manager must be closed before env.
+func New(env *lmdb.Env, opt *Options) *Manager {
+	m := &Manager{env: env, maxIdle: DefaultMaxIdle}
+	if opt != nil {
+		if opt.MaxIdle > 0 {
+			m.maxIdle = opt.MaxIdle
+		}
+		m.timeout = opt.IdleTimeout
+	}
+	return m
+}
+
+// Acquire returns a Snapshot of the latest committed state of the
+// environment.  The Snapshot must be released when it is no longer needed.
+func (m *Manager) Acquire() (*Snapshot, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	expired := m.expire(time.Now())
+	var txn *lmdb.Txn
+	if n := len(m.idle); n > 0 {
+		txn = m.idle[n-1].txn
+		m.idle[n-1] = idleTxn{}
+		m.idle = m.idle[:n-1]
+	}
+	m.active++
+	m.mu.Unlock()
+	m.abort(expired)
+
+	if txn != nil {
+		err := txn.Renew()
+		if err == nil {
+			m.count(&m.stats.Renewed)
+			return newSnapshot(m, txn), nil
+		}
+		// The slot is unusable, for example after the environment was
+		// resized by another process.  Fall back to a new transaction.
+		m.abort([]*lmdb.Txn{txn})
+	}
+	txn, err := m.env.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		m.mu.Lock()
+		m.active--
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.count(&m.stats.Begun)
+	return newSnapshot(m, txn), nil
+}
+
+// expire removes the idle transactions released before now minus the idle
+// timeout and returns them.  It is called with m.mu held.
+func (m *Manager) expire(now time.Time) []*lmdb.Txn {
+	if m.timeout <= 0 {
+		return nil
+	}
+	var expired []*lmdb.Txn
+	i := 0
+	for ; i < len(m.idle) && now.Sub(m.idle[i].since) >= m.timeout; i++ {
+		expired = append(expired, m.idle[i].txn)
+	}
+	if i > 0 {
+		m.idle = append(m.idle[:0], m.idle[i:]...)
+	}
+	return expired
+}
+
+// abort aborts txns and counts them.
+func (m *Manager) abort(txns []*lmdb.Txn) {
+	if len(txns) == 0 {
+		return
+	}
+	for _, txn := range txns {
+		txn.Abort()
+	}
+	m.mu.Lock()
+	m.stats.Aborted += uint64(len(txns))
+	m.mu.Unlock()
+}
+
+func (m *Manager) count(n *uint64) {
+	m.mu.Lock()
+	*n++
+	m.mu.Unlock()
+}
+
+// release takes back the reset transaction of a released Snapshot.
+func (m *Manager) release(txn *lmdb.Txn) {
+	now := time.Now()
+	m.mu.Lock()
+	m.active--
+	expired := m.expire(now)
+	if txn != nil {
+		if m.closed || len(m.idle) >= m.maxIdle {
+			expired = append(expired, txn)
+		} else {
+			m.idle = append(m.idle, idleTxn{txn: txn, since: now})
+		}
+	}
+	m.mu.Unlock()
+	m.abort(expired)
+}
+
+// Stats returns the counters of m.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stats
+	st.Active = m.active
+	st.Idle = len(m.idle)
+	return st
+}
+
+// Close aborts the idle transactions of m.  Snapshots acquired before Close
+// remain usable and their transactions are aborted when they are released,
+// which must happen before the environment is closed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.closed = true
+	var txns []*lmdb.Txn
+	for _, it := range m.idle {
+		txns = append(txns, it.txn)
+	}
+	m.idle = nil
+	m.mu.Unlock()
+	m.abort(txns)
+	return nil
+}
+
+// Snapshot is a read transaction acquired from a Manager.  All reads through
+// a Snapshot see the same state of the environment until Refresh is called.
+type Snapshot struct {
+	m *Manager
+
+	mu       sync.Mutex
+	txn      *lmdb.Txn
+	id       uintptr
+	acquired time.Time
+}
+
+func newSnapshot(m *Manager, txn *lmdb.Txn) *Snapshot {
+	return &Snapshot{m: m, txn: txn, id: txn.ID(), acquired: time.Now()}
+}
+
+// ID returns the ID of the transaction of s, which identifies the last write
+// transaction committed before the snapshot was taken.  It does not change
+// until Refresh is called.
+func (s *Snapshot) ID() uintptr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// Age returns the time since the snapshot was taken.  Old snapshots prevent
+// LMDB from reusing the pages freed after them.
+func (s *Snapshot) Age() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.acquired)
+}
+
+// View runs fn in the transaction of s.  fn must not reset, renew, commit or
+// abort the transaction, and must not retain it or values read through it
+// after returning unless the snapshot is still held.
+func (s *Snapshot) View(fn lmdb.TxnOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.txn == nil {
+		return ErrReleased
+	}
+	return fn(s.txn)
+}
+
+// Refresh moves s to the latest committed state of the environment.  The ID
+// of s changes if writes were committed since it was taken.  If Refresh fails
+// the snapshot is released.
+func (s *Snapshot) Refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.txn == nil {
+		return ErrReleased
+	}
+	s.txn.Reset()
+	err := s.txn.Renew()
+	if err != nil {
+		s.m.abort([]*lmdb.Txn{s.txn})
+		s.txn = nil
+		s.m.release(nil)
+		return err
+	}
+	s.m.count(&s.m.stats.Renewed)
+	s.id = s.txn.ID()
+	s.acquired = time.Now()
+	return nil
+}
+
+// Release resets the transaction of s and returns it to the Manager.  Release
+// may be called more than once.
+func (s *Snapshot) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.txn == nil {
+		return
+	}
+	s.txn.Reset()
+	s.m.release(s.txn)
+	s.txn = nil
+}
@@ -0,0 +1,141 @@
+package lmdbsnap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestSnapshot(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(v string) {
+		err := env.Update(func(txn *lmdb.Txn) error {
+			return txn.Put(dbi, []byte("k"), []byte(v), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(s *Snapshot) (v string) {
+		err := s.View(func(txn *lmdb.Txn) error {
+			b, err := txn.Get(dbi, []byte("k"))
+			v = string(b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	m := New(env, nil)
+	defer m.Close()
+
+	put("v1")
+	s, err := m.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := s.ID()
+	put("v2")
+	if v := get(s); v != "v1" {
+		t.Errorf("value %q (!= %q)", v, "v1")
+	}
+	if s.ID() != id {
+		t.Errorf("id %d (!= %d)", s.ID(), id)
+	}
+
+	err = s.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := get(s); v != "v2" {
+		t.Errorf("value %q (!= %q)", v, "v2")
+	}
+	if s.ID() <= id {
+		t.Errorf("id %d not greater than %d", s.ID(), id)
+	}
+
+	s.Release()
+	s.Release()
+	err = s.View(func(txn *lmdb.Txn) error { return nil })
+	if err != ErrReleased {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	put("v3")
+	s, err = m.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := get(s); v != "v3" {
+		t.Errorf("value %q (!= %q)", v, "v3")
+	}
+	s.Release()
+
+	st := m.Stats()
+	if st.Begun != 1 || st.Renewed != 2 || st.Active != 0 || st.Idle != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+func TestManager_idle(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	m := New(env, &Options{MaxIdle: 2, IdleTimeout: time.Millisecond})
+
+	var snaps []*Snapshot
+	for i := 0; i < 3; i++ {
+		s, err := m.Acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+		snaps = append(snaps, s)
+	}
+	for _, s := range snaps {
+		s.Release()
+	}
+	st := m.Stats()
+	if st.Idle != 2 || st.Aborted != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	s, err := m.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = m.Stats()
+	if st.Idle != 0 || st.Aborted != 3 || st.Begun != 4 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Acquire()
+	if err != ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	s.Release()
+	st = m.Stats()
+	if st.Active != 0 || st.Aborted != 4 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
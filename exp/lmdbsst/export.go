@@ -0,0 +1,45 @@
+//go:build cgo
+// +build cgo
+
+package lmdbsst
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/PowerDNS/lmdb-go/lmdbscan"
+)
+
+// unordered are the database flags under which LMDB does not order keys
+// bytewise or permits duplicate keys.
+const unordered = lmdb.ReverseKey | lmdb.IntegerKey | lmdb.DupSort
+
+// Export writes the records of dbi in the snapshot of txn to w as a table and
+// returns the number of records written.  Databases with the ReverseKey,
+// IntegerKey or DupSort flags cannot be exported, and neither can databases
+// ordered by a custom comparison function, which Export cannot detect.
+func Export(txn *lmdb.Txn, dbi lmdb.DBI, w io.Writer, opt *WriterOptions) (int, error) {
+	flags, err := txn.DBIFlags(dbi)
+	if err != nil {
+		return 0, err
+	}
+	if flags&unordered != 0 {
+		return 0, fmt.Errorf("lmdbsst: cannot export a database with flags %v", flags&unordered)
+	}
+
+	t := NewWriter(w, opt)
+	s := lmdbscan.New(txn, dbi)
+	defer s.Close()
+	for s.Scan() {
+		err = t.Add(s.Key(), s.Val())
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = s.Err()
+	if err != nil {
+		return 0, err
+	}
+	return int(t.count), t.Close()
+}
@@ -0,0 +1,64 @@
+//go:build cgo
+// +build cgo
+
+package lmdbsst
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestExport(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	var buf bytes.Buffer
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 100; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprint("key", i)), []byte(fmt.Sprint("val", i)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		n, err := Export(txn, dbi, &buf, nil)
+		if err != nil {
+			return err
+		}
+		if n != 100 {
+			t.Errorf("unexpected number of records: %d", n)
+		}
+
+		dups, err := txn.OpenDBI("dups", lmdb.Create|lmdb.DupSort)
+		if err != nil {
+			return err
+		}
+		_, err = Export(txn, dups, new(bytes.Buffer), nil)
+		if err == nil {
+			t.Errorf("DupSort database exported")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := r.Get([]byte("key42"))
+	if err != nil || string(val) != "val42" {
+		t.Errorf("unexpected value %q (%v)", val, err)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Package lmdbsst reads and writes static sorted tables: read-only files of
+sorted key-value pairs with an index, which can be queried by key without an
+LMDB environment.
+
+A table is written with a Writer, or exported from an LMDB database with
+Export.  Reading a table needs neither cgo nor a memory map, so that static
+datasets produced by an LMDB application can be shipped to constrained
+targets.  When the package is built without cgo only Export is unavailable.
+
+A table consists of a header, the records in ascending key order, a sparse
+index holding the key and offset of the first record in each block, and a
+fixed size footer locating the index.
+
+	header  "LMDBSST1"
+	record  uvarint(len(key)) uvarint(len(val)) key val
+	index   uvarint(len(key)) key uvarint(offset), one entry per block
+	footer  uint64(index offset) uint64(records) uint32(CRC-32 of the
+	        preceding bytes) uint32(0) "LMDBSSTF", integers big-endian
+
+Keys are compared bytewise and must be unique.
+*/
+package lmdbsst
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultBlockSize is the number of record bytes per index entry when
+// WriterOptions.BlockSize is not set.
+const DefaultBlockSize = 4096
+
+const (
+	headerMagic = "LMDBSST1"
+	footerMagic = "LMDBSSTF"
+	footerSize  = 32
+)
+
+// ErrNotFound is returned by Reader.Get when the table does not contain a key.
+var ErrNotFound = errors.New("lmdbsst: key not found")
+
+// ErrCorrupt is returned when a table is malformed or fails its checksum.
+var ErrCorrupt = errors.New("lmdbsst: corrupt table")
+
+// WriterOptions configures a Writer.
+type WriterOptions struct {
+	// BlockSize is the approximate number of record bytes between index
+	// entries.  Smaller blocks make lookups read less at the cost of a
+	// larger index, which a Reader holds in memory.
+	BlockSize int
+}
+
+// Writer writes a table.  Records must be added in ascending key order.
+type Writer struct {
+	w         *bufio.Writer
+	crc       hash.Hash32
+	blockSize int
+	off       uint64
+	block     uint64 // offset of the current block
+	count     uint64
+	index     []indexEntry
+	last      []byte
+	err       error
+}
+
+type indexEntry struct {
+	key []byte
+	off uint64
+}
+
+// NewWriter returns a Writer writing a table to w.  A nil opt uses the zero
+// WriterOptions.
+func NewWriter(w io.Writer, opt *WriterOptions) *Writer {
+	t := &Writer{crc: crc32.NewIEEE(), blockSize: DefaultBlockSize}
+	if opt != nil && opt.BlockSize > 0 {
+		t.blockSize = opt.BlockSize
+	}
+	t.w = bufio.NewWriter(io.MultiWriter(w, t.crc))
+	t.write([]byte(headerMagic))
+	return t
+}
+
+func (t *Writer) write(p []byte) {
+	if t.err != nil {
+		return
+	}
+	var n int
+	n, t.err = t.w.Write(p)
+	t.off += uint64(n)
+}
+
+func (t *Writer) writeUvarint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	t.write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+// Add appends a record to the table.  The key must be greater than the key of
+// the previous record.
+func (t *Writer) Add(key, val []byte) error {
+	if t.err != nil {
+		return t.err
+	}
+	if t.count > 0 && bytes.Compare(key, t.last) <= 0 {
+		return fmt.Errorf("lmdbsst: key %q added out of order", key)
+	}
+	if t.count == 0 || t.off-t.block >= uint64(t.blockSize) {
+		t.block = t.off
+		t.index = append(t.index, indexEntry{append([]byte(nil), key...), t.off})
+	}
+	t.writeUvarint(uint64(len(key)))
+	t.writeUvarint(uint64(len(val)))
+	t.write(key)
+	t.write(val)
+	t.last = append(t.last[:0], key...)
+	t.count++
+	return t.err
+}
+
+// Close writes the index and footer of the table.  Close does not close the
+// underlying writer.
+func (t *Writer) Close() error {
+	indexOff := t.off
+	for _, e := range t.index {
+		t.writeUvarint(uint64(len(e.key)))
+		t.write(e.key)
+		t.writeUvarint(e.off)
+	}
+	var footer [footerSize]byte
+	binary.BigEndian.PutUint64(footer[0:], indexOff)
+	binary.BigEndian.PutUint64(footer[8:], t.count)
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	binary.BigEndian.PutUint32(footer[16:], t.crc.Sum32())
+	copy(footer[24:], footerMagic)
+	t.write(footer[:])
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+// Reader queries a table.  The index of the table is held in memory and
+// records are read from the underlying io.ReaderAt on demand.  A Reader is
+// safe for concurrent use if its io.ReaderAt is.
+type Reader struct {
+	r        io.ReaderAt
+	size     int64
+	indexOff int64
+	count    uint64
+	crc      uint32
+	index    []indexEntry
+}
+
+// NewReader reads the index of the table of the given size in r.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(len(headerMagic))+footerSize {
+		return nil, ErrCorrupt
+	}
+	var header [len(headerMagic)]byte
+	_, err := r.ReadAt(header[:], 0)
+	if err != nil {
+		return nil, err
+	}
+	var footer [footerSize]byte
+	_, err = r.ReadAt(footer[:], size-footerSize)
+	if err != nil {
+		return nil, err
+	}
+	if string(header[:]) != headerMagic || string(footer[24:]) != footerMagic {
+		return nil, ErrCorrupt
+	}
+	t := &Reader{
+		r:        r,
+		size:     size,
+		indexOff: int64(binary.BigEndian.Uint64(footer[0:])),
+		count:    binary.BigEndian.Uint64(footer[8:]),
+		crc:      binary.BigEndian.Uint32(footer[16:]),
+	}
+	if t.indexOff < int64(len(headerMagic)) || t.indexOff > size-footerSize {
+		return nil, ErrCorrupt
+	}
+	buf := make([]byte, size-footerSize-t.indexOff)
+	_, err = r.ReadAt(buf, t.indexOff)
+	if err != nil {
+		return nil, err
+	}
+	for len(buf) > 0 {
+		var key []byte
+		key, buf, err = readBytes(buf)
+		if err != nil {
+			return nil, err
+		}
+		off, n := binary.Uvarint(buf)
+		if n <= 0 || off >= uint64(t.indexOff) {
+			return nil, ErrCorrupt
+		}
+		buf = buf[n:]
+		t.index = append(t.index, indexEntry{key, off})
+	}
+	return t, nil
+}
+
+// readBytes reads a length prefixed byte string from the start of buf.
+func readBytes(buf []byte) (p, rest []byte, err error) {
+	x, n := binary.Uvarint(buf)
+	if n <= 0 || x > uint64(len(buf)-n) {
+		return nil, nil, ErrCorrupt
+	}
+	return buf[n : n+int(x)], buf[n+int(x):], nil
+}
+
+// Len returns the number of records in the table.
+func (t *Reader) Len() int {
+	return int(t.count)
+}
+
+// block reads the records of the i-th block.
+func (t *Reader) block(i int) ([]byte, error) {
+	end := t.indexOff
+	if i+1 < len(t.index) {
+		end = int64(t.index[i+1].off)
+	}
+	buf := make([]byte, end-int64(t.index[i].off))
+	_, err := t.r.ReadAt(buf, int64(t.index[i].off))
+	return buf, err
+}
+
+// next decodes the record at the start of buf.
+func next(buf []byte) (key, val, rest []byte, err error) {
+	kn, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, nil, nil, ErrCorrupt
+	}
+	buf = buf[n:]
+	vn, n := binary.Uvarint(buf)
+	if n <= 0 || kn+vn > uint64(len(buf)-n) || kn+vn < kn {
+		return nil, nil, nil, ErrCorrupt
+	}
+	buf = buf[n:]
+	return buf[:kn], buf[kn : kn+vn], buf[kn+vn:], nil
+}
+
+// seek returns the index of the block which may contain key.
+func (t *Reader) seek(key []byte) int {
+	i := sort.Search(len(t.index), func(i int) bool {
+		return bytes.Compare(t.index[i].key, key) > 0
+	})
+	if i > 0 {
+		i--
+	}
+	return i
+}
+
+// Get returns the value of key, or ErrNotFound.
+func (t *Reader) Get(key []byte) ([]byte, error) {
+	if len(t.index) == 0 {
+		return nil, ErrNotFound
+	}
+	buf, err := t.block(t.seek(key))
+	if err != nil {
+		return nil, err
+	}
+	for len(buf) > 0 {
+		var k, v []byte
+		k, v, buf, err = next(buf)
+		if err != nil {
+			return nil, err
+		}
+		switch bytes.Compare(k, key) {
+		case 0:
+			return v, nil
+		case 1:
+			return nil, ErrNotFound
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Scan calls fn for each record with a key greater than or equal to from, in
+// ascending order, until fn returns false.  A nil from scans the whole table.
+// The slices passed to fn must not be retained after fn returns.
+func (t *Reader) Scan(from []byte, fn func(key, val []byte) bool) error {
+	if len(t.index) == 0 {
+		return nil
+	}
+	for i := t.seek(from); i < len(t.index); i++ {
+		buf, err := t.block(i)
+		if err != nil {
+			return err
+		}
+		for len(buf) > 0 {
+			var k, v []byte
+			k, v, buf, err = next(buf)
+			if err != nil {
+				return err
+			}
+			if bytes.Compare(k, from) < 0 {
+				continue
+			}
+			if !fn(k, v) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// Verify reads the whole table and checks its checksum.
+func (t *Reader) Verify() error {
+	crc := crc32.NewIEEE()
+	_, err := io.Copy(crc, io.NewSectionReader(t.r, 0, t.size-footerSize))
+	if err != nil {
+		return err
+	}
+	if crc.Sum32() != t.crc {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// File is a Reader of a table in a file.
+type File struct {
+	*Reader
+	f *os.File
+}
+
+// Open opens the table in the file at path.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil {
+		var r *Reader
+		r, err = NewReader(f, fi.Size())
+		if err == nil {
+			return &File{r, f}, nil
+		}
+	}
+	f.Close()
+	return nil, err
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,101 @@
+package lmdbsst
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func writeTable(t *testing.T, n int, opt *WriterOptions) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, opt)
+	for i := 0; i < n; i++ {
+		err := w.Add([]byte(fmt.Sprintf("key%05d", 2*i)), []byte(fmt.Sprint("val", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		p := writeTable(t, n, &WriterOptions{BlockSize: 64})
+		r, err := NewReader(bytes.NewReader(p), int64(len(p)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Len() != n {
+			t.Errorf("unexpected length: %d", r.Len())
+		}
+		err = r.Verify()
+		if err != nil {
+			t.Error(err)
+		}
+		for i := 0; i < n; i++ {
+			val, err := r.Get([]byte(fmt.Sprintf("key%05d", 2*i)))
+			if err != nil {
+				t.Fatalf("key %d: %v", i, err)
+			}
+			if string(val) != fmt.Sprint("val", i) {
+				t.Errorf("key %d: unexpected value %q", i, val)
+			}
+			_, err = r.Get([]byte(fmt.Sprintf("key%05d", 2*i+1)))
+			if err != ErrNotFound {
+				t.Errorf("key %d: unexpected error %v", 2*i+1, err)
+			}
+		}
+		_, err = r.Get([]byte("a"))
+		if err != ErrNotFound {
+			t.Errorf("unexpected error %v", err)
+		}
+
+		var keys []string
+		err = r.Scan([]byte("key00011"), func(key, val []byte) bool {
+			keys = append(keys, string(key))
+			return len(keys) < 2
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if n == 1000 && fmt.Sprint(keys) != "[key00012 key00014]" {
+			t.Errorf("unexpected scan: %q", keys)
+		}
+	}
+}
+
+func TestWriter_order(t *testing.T) {
+	w := NewWriter(new(bytes.Buffer), nil)
+	err := w.Add([]byte("b"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b"} {
+		err = w.Add([]byte(k), nil)
+		if err == nil {
+			t.Errorf("key %q accepted out of order", k)
+		}
+	}
+}
+
+func TestReader_corrupt(t *testing.T) {
+	p := writeTable(t, 100, nil)
+	p[20] ^= 0xff
+	r, err := NewReader(bytes.NewReader(p), int64(len(p)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Verify()
+	if err != ErrCorrupt {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = NewReader(bytes.NewReader(p[:len(p)-1]), int64(len(p)-1))
+	if err != ErrCorrupt {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Package lmdbstats records a history of environment and database statistics in
+a dedicated database of the environment.
+
+A Recorder periodically samples lmdb.Env.Info, the statistics of the root
+database and the statistics of selected named databases and stores each sample
+as a Record.  Records older than Options.MaxAge, or beyond Options.MaxRecords,
+are deleted as new records are written so the history behaves like a ring
+buffer.  The history can be read with Query, for example by the lmdb_stats
+command, without depending on an external metrics system.
+*/
+package lmdbstats
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultDBName is the name of the database holding records when
+// Options.DBName is not set.
+const DefaultDBName = "lmdbstats"
+
+// DefaultMaxRecords is the number of records retained when neither
+// Options.MaxRecords nor Options.MaxAge is set.
+const DefaultMaxRecords = 10000
+
+// Options configure a Recorder.
+type Options struct {
+	// DBName is the name of the database records are stored in.  The
+	// environment must allow for one more named database than the
+	// application uses, see lmdb.Env.SetMaxDBs.
+	DBName string
+
+	// DBs are the names of the named databases whose statistics are
+	// recorded.  The root database is always recorded.
+	DBs []string
+
+	// MaxRecords, if positive, is the number of most recent records
+	// retained.
+	MaxRecords int
+
+	// MaxAge, if positive, is the age after which records are deleted.
+	MaxAge time.Duration
+}
+
+// Record is a sample of the statistics of an environment.
+type Record struct {
+	Time       time.Time
+	MapSize    int64
+	LastPNO    int64
+	LastTxnID  int64
+	NumReaders uint
+	PSize      uint
+
+	// DBs holds the statistics of the recorded databases.  The root database
+	// has the empty name.
+	DBs map[string]lmdb.Stat
+}
+
+// Used returns the number of bytes used by the pages of the environment.
+func (r *Record) Used() int64 {
+	return (r.LastPNO + 1) * int64(r.PSize)
+}
+
+// Recorder writes records to an environment.
+type Recorder struct {
+	env  *lmdb.Env
+	dbi  lmdb.DBI
+	opt  Options
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Recorder for env, which must be open, creating the database
+// holding records if necessary.
+func New(env *lmdb.Env, opt *Options) (*Recorder, error) {
+	r := &Recorder{env: env}
+	if opt != nil {
+		r.opt = *opt
+	}
+	if r.opt.DBName == "" {
+		r.opt.DBName = DefaultDBName
+	}
+	if r.opt.MaxRecords <= 0 && r.opt.MaxAge <= 0 {
+		r.opt.MaxRecords = DefaultMaxRecords
+	}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		r.dbi, err = txn.OpenDBI(r.opt.DBName, lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record samples the statistics of the environment and stores them.
+func (r *Recorder) Record() (*Record, error) {
+	info, err := r.env.Info()
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{
+		Time:       time.Now(),
+		MapSize:    info.MapSize,
+		LastPNO:    info.LastPNO,
+		LastTxnID:  info.LastTxnID,
+		NumReaders: info.NumReaders,
+		DBs:        make(map[string]lmdb.Stat),
+	}
+	err = r.env.Update(func(txn *lmdb.Txn) (err error) {
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		stat, err := txn.Stat(root)
+		if err != nil {
+			return err
+		}
+		rec.PSize = stat.PSize
+		rec.DBs[""] = *stat
+		for _, name := range r.opt.DBs {
+			dbi, err := txn.OpenDBI(name, 0)
+			if lmdb.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			rec.DBs[name] = *stat
+		}
+		v, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(r.dbi, encodeTime(rec.Time), v, 0)
+		if err != nil {
+			return err
+		}
+		return r.trim(txn, rec.Time)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// trim deletes records beyond the retention limits.
+func (r *Recorder) trim(txn *lmdb.Txn, now time.Time) error {
+	stat, err := txn.Stat(r.dbi)
+	if err != nil {
+		return err
+	}
+	excess := 0
+	if r.opt.MaxRecords > 0 && stat.Entries > uint64(r.opt.MaxRecords) {
+		excess = int(stat.Entries - uint64(r.opt.MaxRecords))
+	}
+	cur, err := txn.OpenCursor(r.dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for {
+		k, _, err := cur.Get(nil, nil, lmdb.First)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		old := r.opt.MaxAge > 0 && len(k) == 8 && now.Sub(decodeTime(k)) > r.opt.MaxAge
+		if excess <= 0 && !old {
+			return nil
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return err
+		}
+		excess--
+	}
+}
+
+// Records returns the records taken between from and to, inclusive, oldest
+// first.  A zero from or to leaves the range unbounded on that side.
+func (r *Recorder) Records(from, to time.Time) ([]Record, error) {
+	var recs []Record
+	err := r.env.View(func(txn *lmdb.Txn) (err error) {
+		recs, err = query(txn, r.dbi, from, to)
+		return err
+	})
+	return recs, err
+}
+
+// Start calls Record every interval in a new goroutine until Stop is called.
+// Errors returned by Record are passed to errfn, if it is not nil.
+func (r *Recorder) Start(interval time.Duration, errfn func(error)) error {
+	if r.stop != nil {
+		return errors.New("lmdbstats: recorder already started")
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			_, err := r.Record()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(r.stop, r.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start and waits for it to exit.
+func (r *Recorder) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+	r.done = nil
+}
+
+// Query returns the records stored in the named database of env between from
+// and to, like Recorder.Records.  Query does not create the database and is
+// suitable for use on environments opened with the Readonly flag.
+func Query(env *lmdb.Env, dbname string, from, to time.Time) ([]Record, error) {
+	if dbname == "" {
+		dbname = DefaultDBName
+	}
+	var recs []Record
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenDBI(dbname, 0)
+		if err != nil {
+			return err
+		}
+		recs, err = query(txn, dbi, from, to)
+		return err
+	})
+	return recs, err
+}
+
+func query(txn *lmdb.Txn, dbi lmdb.DBI, from, to time.Time) ([]Record, error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	var recs []Record
+	var k, v []byte
+	if from.IsZero() {
+		k, v, err = cur.Get(nil, nil, lmdb.First)
+	} else {
+		k, v, err = cur.Get(encodeTime(from), nil, lmdb.SetRange)
+	}
+	for ; err == nil; k, v, err = cur.Get(nil, nil, lmdb.Next) {
+		if len(k) != 8 {
+			continue
+		}
+		if !to.IsZero() && decodeTime(k).After(to) {
+			return recs, nil
+		}
+		var rec Record
+		err = json.Unmarshal(v, &rec)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	if !lmdb.IsNotFound(err) {
+		return nil, err
+	}
+	return recs, nil
+}
+
+func encodeTime(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func decodeTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
@@ -0,0 +1,102 @@
+package lmdbstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestRecorder(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "items", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(env, &Options{DBs: []string{"items", "missing"}, MaxRecords: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var times []time.Time
+	for i := 0; i < 5; i++ {
+		err = env.Update(func(txn *lmdb.Txn) error {
+			return txn.Put(dbi, []byte{byte(i)}, []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := r.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.DBs["items"].Entries != uint64(i+1) {
+			t.Errorf("unexpected entries: %d", rec.DBs["items"].Entries)
+		}
+		if _, ok := rec.DBs["missing"]; ok {
+			t.Errorf("missing database recorded")
+		}
+		if rec.Used() <= 0 {
+			t.Errorf("unexpected used bytes: %d", rec.Used())
+		}
+		times = append(times, rec.Time)
+		time.Sleep(time.Millisecond)
+	}
+
+	recs, err := r.Records(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("unexpected number of records: %d", len(recs))
+	}
+	if !recs[0].Time.Equal(times[2]) {
+		t.Errorf("unexpected oldest record: %v (!= %v)", recs[0].Time, times[2])
+	}
+
+	recs, err = Query(env, "", times[3], times[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].DBs["items"].Entries != 4 {
+		t.Errorf("unexpected records: %v", recs)
+	}
+}
+
+func TestRecorder_MaxAge(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	r, err := New(env, &Options{MaxAge: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = r.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	_, err = r.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := r.Records(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 {
+		t.Errorf("unexpected number of records: %d", len(recs))
+	}
+}
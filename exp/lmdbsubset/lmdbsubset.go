@@ -0,0 +1,194 @@
+/*
+Package lmdbsubset copies selected databases and key ranges from one LMDB
+environment into another.
+
+Subsets of production environments make realistic yet compact fixtures for
+integration tests.  A ScrubFunc can rewrite or drop values while they are
+copied so that personal data does not leak into fixtures.
+*/
+package lmdbsubset
+
+import (
+	"bytes"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultBatchSize is the number of items written per transaction when
+// Options.BatchSize is not set.
+const DefaultBatchSize = 10000
+
+// DB selects the items of a database to copy.
+type DB struct {
+	// Name is the name of the database, or empty for the root database.
+	// Items of the root database which are records of named databases are
+	// never copied.
+	Name string
+
+	// Prefixes restricts the copy to keys with one of the given prefixes.
+	// All keys are copied if Prefixes is empty.
+	Prefixes [][]byte
+
+	// Limit is the maximum number of items copied from the database, or zero
+	// for no limit.
+	Limit int
+}
+
+// ScrubFunc is called for each item copied from the database named db and
+// returns the value to store in the destination.  Returning keep as false
+// skips the item.  The slices passed to a ScrubFunc are only valid until it
+// returns.
+type ScrubFunc func(db string, key, val []byte) (newval []byte, keep bool)
+
+// Options configure Copy.
+type Options struct {
+	// Scrub, if not nil, rewrites or filters the items copied.
+	Scrub ScrubFunc
+
+	// BatchSize is the number of items written to dst in each transaction.
+	BatchSize int
+}
+
+// Copy copies the items selected by dbs from src to dst, which must both be
+// open.  Named databases are created in dst with the flags they have in src,
+// so dst must have a sufficient number of named databases configured.  Items
+// are read in a single read-only transaction in src so the copy is a
+// consistent snapshot, but they are written in batches and a failed Copy may
+// leave a partial copy in dst.
+func Copy(dst, src *lmdb.Env, dbs []DB, opt *Options) error {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	return src.View(func(txn *lmdb.Txn) (err error) {
+		txn.RawRead = true
+		for i := range dbs {
+			err = copyDB(dst, txn, &dbs[i], &o)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyDB(dst *lmdb.Env, txn *lmdb.Txn, db *DB, opt *Options) error {
+	sdbi, err := openDBI(txn, db.Name, 0)
+	if err != nil {
+		return err
+	}
+	flags, err := txn.Flags(sdbi)
+	if err != nil {
+		return err
+	}
+	var ddbi lmdb.DBI
+	err = dst.Update(func(dtxn *lmdb.Txn) (err error) {
+		ddbi, err = openDBI(dtxn, db.Name, flags|lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	cur, err := txn.OpenCursor(sdbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	// records of named databases in the root database are not copied.
+	named := make(map[string]bool)
+	if db.Name == "" {
+		names, err := txn.ListDBIs()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			named[name] = true
+		}
+	}
+
+	w := &writer{env: dst, dbi: ddbi, n: opt.BatchSize}
+	copied := 0
+	prefixes := db.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = [][]byte{nil}
+	}
+	for _, prefix := range prefixes {
+		op := uint(lmdb.SetRange)
+		if len(prefix) == 0 {
+			op = lmdb.First
+		}
+		for k, v, err := cur.Get(prefix, nil, op); ; k, v, err = cur.Get(nil, nil, lmdb.Next) {
+			if lmdb.IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !bytes.HasPrefix(k, prefix) {
+				break
+			}
+			if db.Limit > 0 && copied >= db.Limit {
+				break
+			}
+			if named[string(k)] {
+				continue
+			}
+			if opt.Scrub != nil {
+				var keep bool
+				v, keep = opt.Scrub(db.Name, k, v)
+				if !keep {
+					continue
+				}
+			}
+			err = w.add(k, v)
+			if err != nil {
+				return err
+			}
+			copied++
+		}
+	}
+	return w.flush()
+}
+
+func openDBI(txn *lmdb.Txn, name string, flags uint) (lmdb.DBI, error) {
+	if name == "" {
+		return txn.OpenRoot(flags &^ lmdb.Create)
+	}
+	return txn.OpenDBI(name, flags)
+}
+
+// writer accumulates items and writes them to env in batches.
+type writer struct {
+	env   *lmdb.Env
+	dbi   lmdb.DBI
+	n     int
+	items []lmdb.KV
+}
+
+func (w *writer) add(k, v []byte) error {
+	w.items = append(w.items, lmdb.KV{
+		Key: append([]byte(nil), k...),
+		Val: append([]byte(nil), v...),
+	})
+	if len(w.items) >= w.n {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *writer) flush() error {
+	if len(w.items) == 0 {
+		return nil
+	}
+	err := w.env.Update(func(txn *lmdb.Txn) error {
+		_, err := txn.PutMany(w.dbi, w.items, 0)
+		return err
+	})
+	w.items = w.items[:0]
+	return err
+}
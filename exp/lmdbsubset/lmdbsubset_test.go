@@ -0,0 +1,122 @@
+package lmdbsubset
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestCopy(t *testing.T) {
+	src, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(src)
+	dst, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(dst)
+
+	err = src.Update(func(txn *lmdb.Txn) (err error) {
+		users, err := txn.OpenDBI("users", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		tags, err := txn.OpenDBI("tags", lmdb.Create|lmdb.DupSort)
+		if err != nil {
+			return err
+		}
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			err = txn.Put(users, []byte(fmt.Sprintf("eu/%d", i)), []byte(fmt.Sprintf("user%d@example.com", i)), 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(users, []byte(fmt.Sprintf("us/%d", i)), []byte("x"), 0)
+			if err != nil {
+				return err
+			}
+		}
+		for _, v := range []string{"a", "b", "c"} {
+			err = txn.Put(tags, []byte("t"), []byte(v), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Put(root, []byte("version"), []byte("1"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Copy(dst, src, []DB{
+		{Name: "users", Prefixes: [][]byte{[]byte("eu/")}, Limit: 3},
+		{Name: "tags"},
+		{Name: ""},
+	}, &Options{
+		BatchSize: 2,
+		Scrub: func(db string, k, v []byte) ([]byte, bool) {
+			if db == "users" {
+				return []byte("redacted"), string(k) != "eu/1"
+			}
+			return v, true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump := func(txn *lmdb.Txn, name string) []string {
+		dbi, err := openDBI(txn, name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		var items []string
+		for {
+			k, v, err := cur.Get(nil, nil, lmdb.Next)
+			if lmdb.IsNotFound(err) {
+				return items
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, string(k)+"="+string(v))
+		}
+	}
+	err = dst.View(func(txn *lmdb.Txn) (err error) {
+		expect := []string{"eu/0=redacted", "eu/2=redacted", "eu/3=redacted"}
+		if items := dump(txn, "users"); !reflect.DeepEqual(items, expect) {
+			t.Errorf("users: %q (!= %q)", items, expect)
+		}
+		expect = []string{"t=a", "t=b", "t=c"}
+		if items := dump(txn, "tags"); !reflect.DeepEqual(items, expect) {
+			t.Errorf("tags: %q (!= %q)", items, expect)
+		}
+		root := dump(txn, "")
+		found := false
+		for _, item := range root {
+			if item == "version=1" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("root: %q", root)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
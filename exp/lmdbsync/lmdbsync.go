@@ -75,8 +75,26 @@ Because updates may need to execute multiple times in the presence of
 lmdb.MapFull it is important to make sure their TxnOp functions are idempotent
 and do not cause unwanted additive change to the program state.
 
+GrowPercent returns a MapFullFunc growing the map by a percentage of its size
+up to a hard limit.
+
 See mdb_txn_commit and MDB_MAP_FULL.
 
+# Watermarks
+
+Growing the map only after lmdb.MapFull stalls an update, and every
+transaction waiting behind it, at the moment the map runs out.  A Watermark
+periodically checks the usage of the map, notifies the application as it
+crosses configured fractions of the map size and grows the map before it is
+full.  UsageHandler does the same after every transaction instead of at
+intervals.
+
+# Shrinking
+
+The data file of an environment never shrinks by itself.  A Shrinker compacts
+the environment in place once a large fraction of its pages is free and the
+process has been idle for a while.
+
 # MapResized
 
 When multiple processes access and resize an environment it is not uncommon to
@@ -88,6 +106,10 @@ The MapResizedHandler function configures an Env to automatically adopt a new
 map size when a lmdb.MapResized error is encountered and retry execution of the
 TxnOp.
 
+A ResizeNotifier, returned by Env.NotifyResizes, lets processes tell each
+other about new map sizes through a file next to the environment, so that
+most transactions never encounter lmdb.MapResized.
+
 See mdb_txn_begin and MDB_MAP_RESIZED.
 
 # NoLock
@@ -109,6 +131,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
@@ -131,11 +154,17 @@ import (
 // result in poor transaction performance or unspecified behavior in from the C
 // library.
 type Env struct {
+	// active is the time in Unix nanoseconds the last transaction
+	// terminated and running the number of transactions in progress.
+	active  int64
+	running int32
+
 	*lmdb.Env
 	Handlers HandlerChain
 	ctx      context.Context
 	noLock   bool
 	txnlock  sync.RWMutex
+	notifier *ResizeNotifier
 }
 
 // NewEnv returns an newly allocated Env that wraps env.  If env is nil then
@@ -197,10 +226,28 @@ func (r *Env) setMapSize(size int64, delay time.Duration) error {
 		time.Sleep(delay)
 	}
 	err := r.Env.SetMapSize(size)
+	if err == nil && size > 0 && r.notifier != nil {
+		// Publishing is best effort, see ResizeNotifier.
+		info, ierr := r.Env.Info()
+		if ierr == nil {
+			r.notifier.publish(info.MapSize)
+		}
+	}
 	r.txnlock.Unlock()
 	return err
 }
 
+// ShrinkInPlace is a proxy for r.Env.ShrinkInPlace() that blocks while
+// concurrent transactions are in progress, so that transactions of the process
+// wait for the environment to be reopened instead of preventing it.  Handles
+// of databases must be opened again afterwards.  ShrinkInPlace must not be
+// called from a transaction of r, which would deadlock.
+func (r *Env) ShrinkInPlace(mapSize int64) error {
+	r.txnlock.Lock()
+	defer r.txnlock.Unlock()
+	return r.Env.ShrinkInPlace(mapSize)
+}
+
 // BeginTxn overrides the r.Env.BeginTxn and always returns an error.  An
 // unmanaged transaction.
 func (r *Env) BeginTxn(parent *lmdb.Txn, flags uint) (*lmdb.Txn, error) {
@@ -262,6 +309,11 @@ func (r *Env) runHandler(readonly bool, fn func() error, h Handler) error {
 	}
 }
 func (r *Env) run(readonly bool, fn func() error) error {
+	atomic.AddInt32(&r.running, 1)
+	defer func() {
+		atomic.StoreInt64(&r.active, time.Now().UnixNano())
+		atomic.AddInt32(&r.running, -1)
+	}()
 	var err error
 	if r.noLock && !readonly {
 		r.txnlock.Lock()
@@ -548,3 +548,55 @@ func TestEnv_WithHandler_RunTxn(t *testing.T) {
 		t.Errorf("handler was not called")
 	}
 }
+
+func TestEnv_ShrinkInPlace(t *testing.T) {
+	env, err := newEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env.Env)
+
+	dbi, err := lmdbtest.OpenRoot(env.Env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a view running concurrently delays the shrink until it terminates.
+	started := make(chan struct{})
+	viewed := make(chan error)
+	go func() {
+		viewed <- env.View(func(txn *lmdb.Txn) (err error) {
+			close(started)
+			time.Sleep(10 * time.Millisecond)
+			_, err = txn.Get(dbi, []byte("k"))
+			return err
+		})
+	}()
+	<-started
+	err = env.ShrinkInPlace(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-viewed
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbi, err = lmdbtest.OpenRoot(env.Env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
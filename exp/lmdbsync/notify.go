@@ -0,0 +1,173 @@
+package lmdbsync
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultNotifyInterval is the interval at which a ResizeNotifier polls its
+// file when NotifyResizes is passed a non-positive interval.
+const DefaultNotifyInterval = time.Second
+
+// ResizeNotifier shares map sizes between the processes using an
+// environment through a small file next to it.  It is returned by
+// Env.NotifyResizes.
+//
+// Without a ResizeNotifier a process only learns that another process grew the
+// map when a transaction fails with lmdb.MapResized, which MapResizedHandler
+// handles by retrying.  With one, each Env that grows the map publishes the
+// new size, and the ResizeNotifiers of the other processes adopt it within an
+// interval, usually before any of their transactions fails.
+//
+// Notification is best effort.  When processes grow the map concurrently the
+// file may end up holding a smaller size than the largest one set, so
+// MapResizedHandler should remain configured as a fallback.
+type ResizeNotifier struct {
+	env  *Env
+	path string
+
+	mu   sync.Mutex
+	size int64 // Largest size published or adopted
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NotifyResizes publishes the map sizes set through r to the file at path and
+// polls the file every interval, adopting larger sizes published by other
+// processes.  If path is empty a file named "lmdbsync.mapsize" in the
+// directory of the environment is used.  The ResizeNotifier must be closed
+// when it is no longer needed.
+func (r *Env) NotifyResizes(path string, interval time.Duration) (*ResizeNotifier, error) {
+	if path == "" {
+		p, err := r.Env.Path()
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			p = filepath.Dir(p)
+		}
+		path = filepath.Join(p, "lmdbsync.mapsize")
+	}
+	if interval <= 0 {
+		interval = DefaultNotifyInterval
+	}
+	n := &ResizeNotifier{
+		env:  r,
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	info, err := r.Env.Info()
+	if err != nil {
+		return nil, err
+	}
+	n.size = info.MapSize
+	err = n.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	r.txnlock.Lock()
+	r.notifier = n
+	r.txnlock.Unlock()
+
+	go n.loop(interval)
+	return n, nil
+}
+
+func (n *ResizeNotifier) loop(interval time.Duration) {
+	defer close(n.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			// Errors are transient, such as a file being replaced, or will be
+			// reported by the transactions of the environment.
+			n.Check()
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+// Check reads the file and adopts the size it holds if it is larger than the
+// current map size.  Check is called periodically and is exported for
+// applications that want to check at other times, for example after catching
+// a signal.
+func (n *ResizeNotifier) Check() error {
+	b, err := ioutil.ReadFile(n.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	adopt := size > n.size
+	if adopt {
+		n.size = size
+	}
+	n.mu.Unlock()
+	if !adopt {
+		return nil
+	}
+	return n.env.setMapSize(size, 0)
+}
+
+// publish writes size to the file if it is larger than any size seen.  It is
+// called with the transaction lock of the Env held.
+func (n *ResizeNotifier) publish(size int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if size <= n.size {
+		return nil
+	}
+	n.size = size
+	f, err := ioutil.TempFile(filepath.Dir(n.path), ".lmdbsync-")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.FormatInt(size, 10) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), n.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Close stops polling.  Map sizes set through the Env are no longer
+// published.
+func (n *ResizeNotifier) Close() error {
+	n.env.txnlock.Lock()
+	if n.env.notifier == n {
+		n.env.notifier = nil
+	}
+	n.env.txnlock.Unlock()
+	select {
+	case <-n.stop:
+	default:
+		close(n.stop)
+	}
+	<-n.done
+	return nil
+}
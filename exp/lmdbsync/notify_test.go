@@ -0,0 +1,80 @@
+package lmdbsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+)
+
+func TestEnv_NotifyResizes(t *testing.T) {
+	const mapsize = 1 << 20
+	dir, err := ioutil.TempDir("", "lmdbsync-notify-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mapsize")
+
+	// The environments stand in for two processes sharing one environment.
+	env1, err := newEnv(&lmdbtest.EnvOptions{MapSize: mapsize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env1.Env)
+	env2, err := newEnv(&lmdbtest.EnvOptions{MapSize: mapsize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env2.Env)
+
+	n1, err := env1.NotifyResizes(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n1.Close()
+	n2, err := env2.NotifyResizes(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.Close()
+
+	err = env1.SetMapSize(4 * mapsize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := env2.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.MapSize == 4*mapsize {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("map size %d not adopted (%d)", 4*mapsize, info.MapSize)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Shrinking is not published.
+	err = env2.SetMapSize(2 * mapsize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n1.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := env1.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 4*mapsize {
+		t.Errorf("map size %d (!= %d)", info.MapSize, 4*mapsize)
+	}
+}
@@ -0,0 +1,201 @@
+package lmdbsync
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// GrowPercent returns a MapFullFunc that grows the map by pct percent of its
+// size, and by at least min bytes.  If max is positive the map is never grown
+// beyond max bytes, and the function reports false once the map has reached
+// it, so that lmdb.MapFull is returned to the application.
+func GrowPercent(pct float64, min, max int64) MapFullFunc {
+	return func(size int64) (int64, bool) {
+		next := size + int64(float64(size)*pct/100)
+		if next < size+min {
+			next = size + min
+		}
+		if max > 0 && next > max {
+			next = max
+		}
+		return next, next > size
+	}
+}
+
+// UsageHandler returns a Handler that grows the map with fn after a successful
+// transaction leaves the usage of the map at or above the fraction at, such as
+// 0.9.  Unlike a Watermark, which checks at intervals, UsageHandler checks
+// after every transaction, which costs a few calls into the C library each.
+// Growing the map has the same restrictions as with MapFullHandler.
+func UsageHandler(at float64, fn MapFullFunc) Handler {
+	return &usageHandler{at: at, fn: fn}
+}
+
+type usageHandler struct {
+	at float64
+	fn MapFullFunc
+}
+
+func (h *usageHandler) HandleTxnErr(ctx context.Context, env *Env, err error) (context.Context, error) {
+	if err != nil {
+		return ctx, err
+	}
+	u, uerr := env.usage()
+	if uerr != nil || u.Ratio() < h.at {
+		return ctx, nil
+	}
+	size, ok := h.fn(u.MapSize)
+	if ok && size > u.MapSize {
+		// The transaction succeeded, so failing to grow is not reported.
+		env.setMapSize(size, 0)
+	}
+	return ctx, nil
+}
+
+// usage measures the usage of the memory map of r.
+func (r *Env) usage() (Usage, error) {
+	info, err := r.Info()
+	if err != nil {
+		return Usage{}, err
+	}
+	stat, err := r.Stat()
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		Used:    (info.LastPNO + 1) * int64(stat.PSize),
+		MapSize: info.MapSize,
+	}, nil
+}
+
+// ShrinkOptions configure a Shrinker.
+type ShrinkOptions struct {
+	// FreeRatio is the fraction of the pages in use, such as 0.5, that must
+	// be free for the environment to be shrunk.
+	FreeRatio float64
+
+	// Idle is how long the process must not have run transactions through
+	// the Env before it is shrunk.
+	Idle time.Duration
+
+	// MapSize is the map size after shrinking.  If zero the map size is kept.
+	MapSize int64
+
+	// Reopened is called after the environment was shrunk and must open the
+	// database handles used by the application again, see
+	// lmdb.Env.ShrinkInPlace.  It is called while transactions of the Env are
+	// blocked and must use the underlying lmdb.Env.
+	Reopened func() error
+}
+
+// Shrinker compacts an environment in place when it has accumulated free
+// pages and the process has been idle, to return disk space after large
+// deletions.  Shrinking requires that no other process has the environment
+// open, see lmdb.Env.ShrinkInPlace, so it suits environments owned by a
+// single process.
+type Shrinker struct {
+	env *Env
+	opt ShrinkOptions
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewShrinker returns a Shrinker for r configured by opt.
+func (r *Env) NewShrinker(opt *ShrinkOptions) (*Shrinker, error) {
+	if opt == nil || opt.FreeRatio <= 0 || opt.FreeRatio > 1 {
+		return nil, errors.New("lmdbsync: free ratio must be between 0 and 1")
+	}
+	if opt.Reopened == nil {
+		return nil, errors.New("lmdbsync: shrinking requires a Reopened function")
+	}
+	return &Shrinker{env: r, opt: *opt}, nil
+}
+
+// Check shrinks the environment if the process has been idle for Idle and the
+// fraction of free pages is at least FreeRatio, and returns true if it did.
+func (s *Shrinker) Check() (bool, error) {
+	if time.Since(s.env.lastActive()) < s.opt.Idle {
+		return false, nil
+	}
+	stat, err := s.env.FreelistStat()
+	if err != nil {
+		return false, err
+	}
+	if stat.Pages == 0 || float64(stat.FreePages)/float64(stat.Pages) < s.opt.FreeRatio {
+		return false, nil
+	}
+
+	r := s.env
+	r.txnlock.Lock()
+	defer r.txnlock.Unlock()
+	// Transactions may have started while the free list was read.
+	if time.Since(r.lastActive()) < s.opt.Idle {
+		return false, nil
+	}
+	err = r.Env.ShrinkInPlace(s.opt.MapSize)
+	if err != nil {
+		return false, err
+	}
+	return true, s.opt.Reopened()
+}
+
+// Start calls Check every interval in a new goroutine until Stop is called.
+// Errors returned by Check, including lmdb.ErrEnvBusy, are passed to errfn, if
+// it is not nil.
+func (s *Shrinker) Start(interval time.Duration, errfn func(error)) error {
+	if interval <= 0 {
+		return errors.New("lmdbsync: shrinker interval must be positive")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return errors.New("lmdbsync: shrinker already started")
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			_, err := s.Check()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(s.stop, s.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start and waits for it to exit.
+func (s *Shrinker) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+	s.done = nil
+}
+
+// lastActive returns the time the last transaction run through r terminated,
+// or the current time if a transaction is running.
+func (r *Env) lastActive() time.Time {
+	if atomic.LoadInt32(&r.running) > 0 {
+		return time.Now()
+	}
+	return time.Unix(0, atomic.LoadInt64(&r.active))
+}
@@ -0,0 +1,152 @@
+package lmdbsync
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestGrowPercent(t *testing.T) {
+	fn := GrowPercent(50, 100, 1000)
+	for _, test := range []struct {
+		size, next int64
+		ok         bool
+	}{
+		{100, 200, true},
+		{400, 600, true},
+		{800, 1000, true},
+		{1000, 1000, false},
+	} {
+		next, ok := fn(test.size)
+		if next != test.next || ok != test.ok {
+			t.Errorf("%d: %d %v (!= %d %v)", test.size, next, ok, test.next, test.ok)
+		}
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	const mapsize = 1 << 20
+	env, err := newEnv(&lmdbtest.EnvOptions{MapSize: mapsize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env.Env)
+	env.Handlers = env.Handlers.Append(UsageHandler(0.5, GrowPercent(100, 0, 0)))
+
+	dbi, err := lmdbtest.OpenRoot(env.Env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			return txn.Put(dbi, []byte(fmt.Sprintf("key%04d", i)), make([]byte, 4000), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := env.usage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Ratio() >= 0.5 {
+			t.Fatalf("usage %v after update %d", u.Ratio(), i)
+		}
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize <= mapsize {
+		t.Errorf("map size %d was not grown", info.MapSize)
+	}
+}
+
+func TestShrinker(t *testing.T) {
+	env, err := newEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env.Env)
+
+	dbi, err := lmdbtest.OpenRoot(env.Env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := 0
+	s, err := env.NewShrinker(&ShrinkOptions{
+		FreeRatio: 0.5,
+		Idle:      time.Hour,
+		Reopened: func() error {
+			reopened++
+			dbi, err = lmdbtest.OpenRoot(env.Env, 0)
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500; i++ {
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			return txn.Put(dbi, []byte(fmt.Sprintf("key%04d", i)), make([]byte, 1000), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return txn.Drop(dbi, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More updates let the freed pages reach the free list.
+	for i := 0; i < 3; i++ {
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			return txn.Put(dbi, []byte("key"), []byte("val"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path + "/data.mdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shrunk, err := s.Check()
+	if err != nil || shrunk {
+		t.Fatalf("shrunk %v while active: %v", shrunk, err)
+	}
+	s.opt.Idle = 0
+	shrunk, err = s.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shrunk || reopened != 1 {
+		t.Fatalf("shrunk %v, reopened %d times", shrunk, reopened)
+	}
+	after, err := os.Stat(path + "/data.mdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("data file size %d (>= %d)", after.Size(), before.Size())
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("key"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,170 @@
+package lmdbsync
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage is the portion of an environment's memory map in use.
+type Usage struct {
+	Used    int64 // Bytes up to the last page in use
+	MapSize int64 // Size of the memory map
+}
+
+// Ratio returns the fraction of the memory map in use.
+func (u Usage) Ratio() float64 {
+	if u.MapSize <= 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(u.MapSize)
+}
+
+// WatermarkOptions configure a Watermark.
+type WatermarkOptions struct {
+	// Marks are the fractions of the map size, such as 0.8 and 0.95, at
+	// which Notify is called.
+	Marks []float64
+
+	// Notify is called by Check when the usage of the map rises to or above
+	// a mark.  It is called once per mark, and again only after the usage
+	// fell below the mark, for example because the map was grown.
+	Notify func(mark float64, u Usage)
+
+	// GrowAt is the fraction of the map size at which Check grows the map
+	// with Grow.  Growing is disabled if GrowAt is zero.
+	GrowAt float64
+
+	// Grow returns the new map size when the map is grown by Check, in the
+	// same way as the function passed to MapFullHandler.
+	Grow MapFullFunc
+}
+
+// Watermark watches the usage of an Env's memory map and grows it ahead of
+// time, so that writers rarely encounter lmdb.MapFull.  Growing the map
+// proactively still requires all transactions of the process to terminate,
+// but it happens at a time chosen by the application instead of while an
+// update waits to be retried.
+//
+// The usage is measured as the pages up to the last one in use, which
+// includes free pages that writers may reuse.  It is thus an upper bound on
+// the space needed for the data.
+type Watermark struct {
+	env   *Env
+	marks []float64
+	opt   WatermarkOptions
+
+	mu      sync.Mutex
+	reached []bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWatermark returns a Watermark for r configured by opt.
+func (r *Env) NewWatermark(opt *WatermarkOptions) (*Watermark, error) {
+	w := &Watermark{env: r}
+	if opt != nil {
+		w.opt = *opt
+	}
+	if w.opt.GrowAt < 0 || w.opt.GrowAt > 1 {
+		return nil, errors.New("lmdbsync: growth threshold must be between 0 and 1")
+	}
+	if w.opt.GrowAt > 0 && w.opt.Grow == nil {
+		return nil, errors.New("lmdbsync: growth threshold without a growth function")
+	}
+	w.marks = append([]float64(nil), w.opt.Marks...)
+	sort.Float64s(w.marks)
+	w.reached = make([]bool, len(w.marks))
+	return w, nil
+}
+
+// Check measures the usage of the map, calls Notify for marks that were
+// reached and grows the map if its usage is at or above GrowAt.  The usage
+// returned is the one measured before growing.
+//
+// Growing the map blocks until all transactions of the process have
+// terminated, see Env.SetMapSize, so Check must not be called while the
+// calling goroutine has an active transaction.
+func (w *Watermark) Check() (Usage, error) {
+	u, err := w.usage()
+	if err != nil {
+		return u, err
+	}
+
+	w.mu.Lock()
+	ratio := u.Ratio()
+	var notify []float64
+	for i, mark := range w.marks {
+		if ratio < mark {
+			w.reached[i] = false
+		} else if !w.reached[i] {
+			w.reached[i] = true
+			notify = append(notify, mark)
+		}
+	}
+	w.mu.Unlock()
+	if w.opt.Notify != nil {
+		for _, mark := range notify {
+			w.opt.Notify(mark, u)
+		}
+	}
+
+	if w.opt.GrowAt > 0 && ratio >= w.opt.GrowAt {
+		size, ok := w.opt.Grow(u.MapSize)
+		if ok && size > u.MapSize {
+			err = w.env.SetMapSize(size)
+			if err != nil {
+				return u, err
+			}
+			// the marks are re-armed by the next check.
+		}
+	}
+	return u, nil
+}
+
+func (w *Watermark) usage() (Usage, error) {
+	return w.env.usage()
+}
+
+// Start calls Check every interval in a new goroutine until Stop is called.
+// Errors returned by Check are passed to errfn, if it is not nil.
+func (w *Watermark) Start(interval time.Duration, errfn func(error)) error {
+	if interval <= 0 {
+		return errors.New("lmdbsync: watermark interval must be positive")
+	}
+	if w.stop != nil {
+		return errors.New("lmdbsync: watermark already started")
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			_, err := w.Check()
+			if err != nil && errfn != nil {
+				errfn(err)
+			}
+		}
+	}(w.stop, w.done)
+	return nil
+}
+
+// Stop stops the goroutine started by Start and waits for it to exit.
+func (w *Watermark) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+	w.done = nil
+}
@@ -0,0 +1,121 @@
+package lmdbsync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestWatermark(t *testing.T) {
+	const mapsize = 1 << 20
+	env, err := newEnv(&lmdbtest.EnvOptions{MapSize: mapsize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env.Env)
+
+	dbi, err := lmdbtest.OpenRoot(env.Env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []float64
+	w, err := env.NewWatermark(&WatermarkOptions{
+		Marks: []float64{0.8, 0.5},
+		Notify: func(mark float64, u Usage) {
+			if u.Ratio() < mark {
+				t.Errorf("notified for mark %v at %v", mark, u.Ratio())
+			}
+			notified = append(notified, mark)
+		},
+		GrowAt: 0.8,
+		Grow:   func(size int64) (int64, bool) { return 2 * size, true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := w.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.MapSize != mapsize || u.Used <= 0 || len(notified) != 0 {
+		t.Fatalf("unexpected usage: %+v %v", u, notified)
+	}
+
+	// fill the map to 60%.
+	fill := func(ratio float64) {
+		for i := 0; ; i++ {
+			u, err := w.usage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Ratio() >= ratio {
+				return
+			}
+			err = env.Update(func(txn *lmdb.Txn) (err error) {
+				key := fmt.Sprintf("%s-%08d", t.Name(), i)
+				return txn.Put(dbi, []byte(key), make([]byte, 4000), 0)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fill(0.6)
+	_, err = w.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(notified) != "[0.5]" {
+		t.Errorf("unexpected notifications: %v", notified)
+	}
+
+	fill(0.85)
+	u, err = w.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(notified) != "[0.5 0.8]" {
+		t.Errorf("unexpected notifications: %v", notified)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 2*mapsize {
+		t.Errorf("map was not grown: %d", info.MapSize)
+	}
+
+	// after growing the usage is below both marks and they are re-armed.
+	u, err = w.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Ratio() >= 0.5 || len(w.reached) != 2 || w.reached[0] || w.reached[1] {
+		t.Errorf("unexpected state after growing: %v %v", u.Ratio(), w.reached)
+	}
+}
+
+func TestNewWatermark_invalid(t *testing.T) {
+	env, err := newEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env.Env)
+
+	_, err = env.NewWatermark(&WatermarkOptions{GrowAt: 0.9})
+	if err == nil {
+		t.Errorf("expected error without a growth function")
+	}
+	_, err = env.NewWatermark(&WatermarkOptions{GrowAt: 2, Grow: func(int64) (int64, bool) { return 0, false }})
+	if err == nil {
+		t.Errorf("expected error for threshold")
+	}
+}
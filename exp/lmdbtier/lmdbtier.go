@@ -0,0 +1,319 @@
+/*
+Package lmdbtier moves old records of an LMDB database to object storage,
+keeping the environment small while historical records remain accessible.
+
+Tier.Archive uploads the value of each record older than a policy threshold as
+a blob to a BlobStore and replaces the value in the database with a short stub
+naming the blob.  Tier.Get returns values transparently, fetching archived
+ones from the store.  Tier.Restore moves a value back into the database and
+Tier.Del deletes a record together with its blob.
+
+The age of a record is determined by an AgeFunc supplied by the application,
+typically by decoding a timestamp from the key or value.  Uploads happen
+outside of write transactions so a slow store never blocks writers.  A record
+modified while its value is being uploaded is left in place.
+
+The package does not depend on a particular object storage SDK.  Clients for
+S3 or GCS are adapted by implementing BlobStore, and DirStore stores blobs in a
+local or network file system.
+*/
+package lmdbtier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/PowerDNS/lmdb-go/lmdbscan"
+)
+
+// DefaultBatchSize is the number of records archived per write transaction
+// when Options.BatchSize is not set.
+const DefaultBatchSize = 1000
+
+// stubMagic starts every stub value.  Values written by the application must
+// not start with it.
+var stubMagic = []byte("\x00lmdbtier\x00")
+
+// BlobStore stores the values of archived records.  Its methods may be called
+// concurrently.
+type BlobStore interface {
+	PutBlob(name string, data []byte) error
+	GetBlob(name string) ([]byte, error)
+	DeleteBlob(name string) error
+}
+
+// DirStore is a BlobStore keeping each blob in a file of the directory.
+type DirStore string
+
+// PutBlob writes the blob to a temporary file and renames it into place.
+func (d DirStore) PutBlob(name string, data []byte) error {
+	f, err := ioutil.TempFile(string(d), ".blob-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(string(d), name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// GetBlob reads the blob.
+func (d DirStore) GetBlob(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(d), name))
+}
+
+// DeleteBlob removes the blob.  Deleting a missing blob is not an error.
+func (d DirStore) DeleteBlob(name string) error {
+	err := os.Remove(filepath.Join(string(d), name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// AgeFunc returns the time a record was last modified.  Records for which ok
+// is false are never archived.
+type AgeFunc func(key, val []byte) (t time.Time, ok bool)
+
+// Options configure a Tier.
+type Options struct {
+	Store  BlobStore
+	Age    AgeFunc
+	MaxAge time.Duration // Records older than MaxAge are archived
+
+	// Prefix is prepended to the names of blobs, so that several databases
+	// can share a store.
+	Prefix string
+
+	// BatchSize is the number of records archived per write transaction.
+	BatchSize int
+}
+
+var errNoStore = errors.New("lmdbtier: Options.Store and Options.Age must be set")
+
+// Tier archives the old records of a database.  A Tier is safe for concurrent
+// use.
+type Tier struct {
+	env *lmdb.Env
+	dbi lmdb.DBI
+	opt Options
+}
+
+// New returns a Tier archiving records of dbi in env according to opt.  The
+// database must not have been opened with lmdb.DupSort.
+func New(env *lmdb.Env, dbi lmdb.DBI, opt *Options) (*Tier, error) {
+	if opt == nil || opt.Store == nil || opt.Age == nil {
+		return nil, errNoStore
+	}
+	t := &Tier{env: env, dbi: dbi, opt: *opt}
+	if t.opt.BatchSize <= 0 {
+		t.opt.BatchSize = DefaultBatchSize
+	}
+	return t, nil
+}
+
+// IsStub returns true if val is the stub of an archived record.
+func IsStub(val []byte) bool {
+	return bytes.HasPrefix(val, stubMagic)
+}
+
+// stub returns the stub referencing the blob name.
+func stub(name string) []byte {
+	return append(append([]byte(nil), stubMagic...), name...)
+}
+
+// blobName returns the name of the blob holding the value of key.
+func (t *Tier) blobName(key []byte) string {
+	sum := sha256.Sum256(key)
+	return t.opt.Prefix + hex.EncodeToString(sum[:])
+}
+
+// Get returns the value of key, fetching it from the store if the record has
+// been archived.  Unlike lmdb.Txn.Get the returned value is never memory
+// owned by LMDB.
+func (t *Tier) Get(txn *lmdb.Txn, key []byte) ([]byte, error) {
+	val, err := txn.Get(t.dbi, key)
+	if err != nil {
+		return nil, err
+	}
+	if !IsStub(val) {
+		return append([]byte(nil), val...), nil
+	}
+	return t.opt.Store.GetBlob(string(val[len(stubMagic):]))
+}
+
+// Del deletes key from the database.  If the record has been archived its blob
+// is deleted once txn commits.
+func (t *Tier) Del(txn *lmdb.Txn, key []byte) error {
+	val, err := txn.Get(t.dbi, key)
+	if err != nil {
+		return err
+	}
+	var name string
+	if IsStub(val) {
+		name = string(val[len(stubMagic):])
+	}
+	err = txn.Del(t.dbi, key, nil)
+	if err != nil || name == "" {
+		return err
+	}
+	txn.OnCommit(func(uintptr) {
+		t.opt.Store.DeleteBlob(name)
+	})
+	return nil
+}
+
+// Restore moves the value of an archived record back into the database and
+// deletes its blob.  Restoring a record which is not archived does nothing.
+func (t *Tier) Restore(key []byte) error {
+	var name string
+	err := t.env.View(func(txn *lmdb.Txn) (err error) {
+		val, err := txn.Get(t.dbi, key)
+		if err == nil && IsStub(val) {
+			name = string(val[len(stubMagic):])
+		}
+		return err
+	})
+	if err != nil || name == "" {
+		return err
+	}
+	data, err := t.opt.Store.GetBlob(name)
+	if err != nil {
+		return err
+	}
+	restored := false
+	err = t.env.Update(func(txn *lmdb.Txn) (err error) {
+		val, err := txn.Get(t.dbi, key)
+		if err != nil || !bytes.Equal(val, stub(name)) {
+			return err
+		}
+		restored = true
+		return txn.Put(t.dbi, key, data, 0)
+	})
+	if err != nil || !restored {
+		return err
+	}
+	return t.opt.Store.DeleteBlob(name)
+}
+
+// candidate is a record selected for archival.
+type candidate struct {
+	key, val []byte
+	name     string
+}
+
+// Archive moves the values of records older than Options.MaxAge at time now
+// to the store, and returns the number of records archived.  Archive may be
+// called periodically and resumes where a failed call stopped.
+func (t *Tier) Archive(now time.Time) (int, error) {
+	cutoff := now.Add(-t.opt.MaxAge)
+	var total int
+	var after []byte
+	for {
+		batch, err := t.candidates(after, cutoff)
+		if err != nil || len(batch) == 0 {
+			return total, err
+		}
+		after = batch[len(batch)-1].key
+
+		var uploaded []candidate
+		for _, c := range batch {
+			err = t.opt.Store.PutBlob(c.name, c.val)
+			if err != nil {
+				break
+			}
+			uploaded = append(uploaded, c)
+		}
+		n, uerr := t.replace(uploaded)
+		total += n
+		if err == nil {
+			err = uerr
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// candidates returns up to BatchSize records following the key after which
+// are older than cutoff.
+func (t *Tier) candidates(after []byte, cutoff time.Time) ([]candidate, error) {
+	var batch []candidate
+	err := t.env.View(func(txn *lmdb.Txn) (err error) {
+		s := lmdbscan.New(txn, t.dbi)
+		defer s.Close()
+		if after != nil {
+			s.SetNext(after, nil, lmdb.SetRange, lmdb.Next)
+		}
+		for len(batch) < t.opt.BatchSize && s.Scan() {
+			key, val := s.Key(), s.Val()
+			if after != nil && bytes.Equal(key, after) {
+				continue
+			}
+			if IsStub(val) {
+				continue
+			}
+			mod, ok := t.opt.Age(key, val)
+			if !ok || !mod.Before(cutoff) {
+				continue
+			}
+			batch = append(batch, candidate{
+				key:  append([]byte(nil), key...),
+				val:  append([]byte(nil), val...),
+				name: t.blobName(key),
+			})
+		}
+		return s.Err()
+	})
+	return batch, err
+}
+
+// replace stubs the uploaded records which have not changed since they were
+// read.  The blobs of changed records are deleted.
+func (t *Tier) replace(uploaded []candidate) (int, error) {
+	var stale []string
+	var n int
+	err := t.env.Update(func(txn *lmdb.Txn) (err error) {
+		stale, n = nil, 0
+		for _, c := range uploaded {
+			val, err := txn.Get(t.dbi, c.key)
+			if lmdb.IsNotFound(err) || (err == nil && !bytes.Equal(val, c.val)) {
+				stale = append(stale, c.name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = txn.Put(t.dbi, c.key, stub(c.name), 0)
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range stale {
+		t.opt.Store.DeleteBlob(name)
+	}
+	return n, nil
+}
@@ -0,0 +1,138 @@
+package lmdbtier
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestTier(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	dir, err := ioutil.TempDir("", "lmdbtier-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values start with the unix time they were written.
+	now := time.Unix(1000000, 0)
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 10; i++ {
+			val := make([]byte, 8, 16)
+			binary.BigEndian.PutUint64(val, uint64(now.Add(-time.Duration(i)*time.Hour).Unix()))
+			val = append(val, fmt.Sprint("value", i)...)
+			err = txn.Put(dbi, []byte(fmt.Sprint("key", i)), val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tier, err := New(env, dbi, &Options{
+		Store:  DirStore(dir),
+		MaxAge: 5*time.Hour + time.Minute,
+		Age: func(key, val []byte) (time.Time, bool) {
+			if len(val) < 8 {
+				return time.Time{}, false
+			}
+			return time.Unix(int64(binary.BigEndian.Uint64(val)), 0), true
+		},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := tier.Archive(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("unexpected number of archived records: %d", n)
+	}
+	n, err = tier.Archive(now)
+	if err != nil || n != 0 {
+		t.Errorf("records archived twice: %d %v", n, err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 10; i++ {
+			key := []byte(fmt.Sprint("key", i))
+			raw, err := txn.Get(dbi, key)
+			if err != nil {
+				return err
+			}
+			if IsStub(raw) != (i > 5) {
+				t.Errorf("key%d: unexpected stub state %v", i, IsStub(raw))
+			}
+			val, err := tier.Get(txn, key)
+			if err != nil {
+				return err
+			}
+			if string(val[8:]) != fmt.Sprint("value", i) {
+				t.Errorf("key%d: unexpected value %q", i, val)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := func() int {
+		names, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(names)
+	}
+	if blobs() != 4 {
+		t.Errorf("unexpected number of blobs: %d", blobs())
+	}
+
+	err = tier.Restore([]byte("key9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return tier.Del(txn, []byte("key8"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobs() != 2 {
+		t.Errorf("unexpected number of blobs: %d", blobs())
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		val, err := txn.Get(dbi, []byte("key9"))
+		if err == nil && IsStub(val) {
+			t.Errorf("key9 was not restored")
+		}
+		_, err = txn.Get(dbi, []byte("key8"))
+		if !lmdb.IsNotFound(err) {
+			t.Errorf("key8 was not deleted: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Package lmdbtuple encodes tuples of values as keys which sort like the tuples
+themselves, so that range scans over keys of several fields behave as
+expected.
+
+Pack encodes strings, byte slices, signed and unsigned integers and times.
+Comparing two packed tuples with bytes.Compare, the order LMDB uses by
+default, compares their elements in turn: strings and byte slices
+lexicographically, integers numerically and times chronologically.  A tuple
+sorts before the tuples it is a prefix of, so all keys starting with the same
+elements form a contiguous range, see Range.
+
+	key, err := lmdbtuple.Pack("user", uint64(42), time.Now())
+
+An element wrapped with Desc sorts in descending order instead, for example
+to scan the newest events of a user first:
+
+	key, err := lmdbtuple.Pack("event", uint64(42), lmdbtuple.Desc(t))
+
+Each element is encoded as a type tag followed by its value.  Strings and
+byte slices are terminated by 0x00 0x01, with each 0x00 byte they contain
+escaped as 0x00 0xff.  Integers are 8 bytes big-endian, with the sign bit of
+signed integers flipped, and times are a signed integer of seconds since the
+Unix epoch followed by 4 bytes of nanoseconds.  The bytes of a descending
+element are inverted.  Elements of different types sort by their tags, in
+the order bytes, string, signed integer, unsigned integer and time.
+*/
+package lmdbtuple
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Type tags of the elements.
+const (
+	tagBytes  = 0x01
+	tagString = 0x02
+	tagInt    = 0x03
+	tagUint   = 0x04
+	tagTime   = 0x05
+)
+
+// ErrTruncated is returned when a key ends within an element.
+var ErrTruncated = errors.New("lmdbtuple: truncated element")
+
+// desc is an element sorting in descending order.
+type desc struct {
+	v interface{}
+}
+
+// Desc returns v as an element of a tuple sorting in descending order.
+func Desc(v interface{}) interface{} {
+	return desc{v}
+}
+
+// Pack returns the encoding of the tuple of elems.  See the package
+// documentation for the supported types.
+func Pack(elems ...interface{}) ([]byte, error) {
+	return Append(nil, elems...)
+}
+
+// Append appends the encoding of the tuple of elems to dst.  Appending the
+// encodings of two tuples results in the encoding of their concatenation.
+func Append(dst []byte, elems ...interface{}) ([]byte, error) {
+	for _, v := range elems {
+		var err error
+		if d, ok := v.(desc); ok {
+			n := len(dst)
+			dst, err = appendElem(dst, d.v)
+			for i := n; i < len(dst); i++ {
+				dst[i] ^= 0xff
+			}
+		} else {
+			dst, err = appendElem(dst, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func appendElem(dst []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return appendEscaped(append(dst, tagBytes), v), nil
+	case string:
+		return appendEscaped(append(dst, tagString), []byte(v)), nil
+	case int:
+		return appendInt(dst, int64(v)), nil
+	case int8:
+		return appendInt(dst, int64(v)), nil
+	case int16:
+		return appendInt(dst, int64(v)), nil
+	case int32:
+		return appendInt(dst, int64(v)), nil
+	case int64:
+		return appendInt(dst, v), nil
+	case uint:
+		return appendUint(dst, uint64(v)), nil
+	case uint8:
+		return appendUint(dst, uint64(v)), nil
+	case uint16:
+		return appendUint(dst, uint64(v)), nil
+	case uint32:
+		return appendUint(dst, uint64(v)), nil
+	case uint64:
+		return appendUint(dst, v), nil
+	case time.Time:
+		var b [13]byte
+		b[0] = tagTime
+		binary.BigEndian.PutUint64(b[1:], uint64(v.Unix())^1<<63)
+		binary.BigEndian.PutUint32(b[9:], uint32(v.Nanosecond()))
+		return append(dst, b[:]...), nil
+	case desc:
+		return nil, errors.New("lmdbtuple: nested Desc")
+	}
+	return nil, fmt.Errorf("lmdbtuple: unsupported element type %T", v)
+}
+
+func appendInt(dst []byte, v int64) []byte {
+	return appendFixed(dst, tagInt, uint64(v)^1<<63)
+}
+
+func appendUint(dst []byte, v uint64) []byte {
+	return appendFixed(dst, tagUint, v)
+}
+
+func appendFixed(dst []byte, tag byte, v uint64) []byte {
+	var b [9]byte
+	b[0] = tag
+	binary.BigEndian.PutUint64(b[1:], v)
+	return append(dst, b[:]...)
+}
+
+func appendEscaped(dst, b []byte) []byte {
+	for _, c := range b {
+		if c == 0x00 {
+			dst = append(dst, 0x00, 0xff)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, 0x00, 0x01)
+}
+
+// Unpack decodes the tuple encoded in key.  Elements are returned as []byte,
+// string, int64, uint64 or time.Time values, in UTC, whether they were
+// encoded in ascending or descending order.
+func Unpack(key []byte) ([]interface{}, error) {
+	var elems []interface{}
+	d := NewDecoder(key)
+	for d.More() {
+		v, err := d.Next()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+	}
+	return elems, nil
+}
+
+// Range returns the range of keys of the tuples starting with the elements
+// prefix: keys from start, inclusive, to end, exclusive.
+func Range(prefix ...interface{}) (start, end []byte, err error) {
+	start, err = Pack(prefix...)
+	if err != nil {
+		return nil, nil, err
+	}
+	// No encoded element starts with 0xff, the inverse of a zero tag.
+	return start, append(start[:len(start):len(start)], 0xff), nil
+}
+
+// A Decoder decodes the elements of a tuple one at a time.
+type Decoder struct {
+	b []byte
+}
+
+// NewDecoder returns a Decoder of the tuple encoded in key.
+func NewDecoder(key []byte) *Decoder {
+	return &Decoder{b: key}
+}
+
+// More returns true if elements remain to be decoded.
+func (d *Decoder) More() bool {
+	return len(d.b) > 0
+}
+
+// Next decodes the next element as Unpack does.
+func (d *Decoder) Next() (interface{}, error) {
+	if len(d.b) == 0 {
+		return nil, ErrTruncated
+	}
+	tag, _ := d.tag()
+	switch tag {
+	case tagBytes:
+		return d.Bytes()
+	case tagString:
+		return d.String()
+	case tagInt:
+		return d.Int64()
+	case tagUint:
+		return d.Uint64()
+	case tagTime:
+		return d.Time()
+	}
+	return nil, fmt.Errorf("lmdbtuple: unknown element tag %#x", d.b[0])
+}
+
+// tag returns the tag of the next element and the mask inverting its bytes.
+func (d *Decoder) tag() (tag, mask byte) {
+	tag = d.b[0]
+	if tag >= 0x80 {
+		return tag ^ 0xff, 0xff
+	}
+	return tag, 0
+}
+
+// start checks that the next element has the tag want and consumes the tag.
+func (d *Decoder) start(want byte) (mask byte, err error) {
+	if len(d.b) == 0 {
+		return 0, ErrTruncated
+	}
+	tag, mask := d.tag()
+	if tag != want {
+		return 0, fmt.Errorf("lmdbtuple: element tag %#x, expected %#x", tag, want)
+	}
+	d.b = d.b[1:]
+	return mask, nil
+}
+
+// Bytes decodes the next element, which must be a byte slice.
+func (d *Decoder) Bytes() ([]byte, error) {
+	mask, err := d.start(tagBytes)
+	if err != nil {
+		return nil, err
+	}
+	return d.escaped(mask)
+}
+
+// String decodes the next element, which must be a string.
+func (d *Decoder) String() (string, error) {
+	mask, err := d.start(tagString)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.escaped(mask)
+	return string(b), err
+}
+
+func (d *Decoder) escaped(mask byte) ([]byte, error) {
+	out := []byte{}
+	for i := 0; i+1 < len(d.b); i++ {
+		c := d.b[i] ^ mask
+		if c != 0x00 {
+			out = append(out, c)
+			continue
+		}
+		switch d.b[i+1] ^ mask {
+		case 0xff:
+			out = append(out, 0x00)
+			i++
+		case 0x01:
+			d.b = d.b[i+2:]
+			return out, nil
+		default:
+			return nil, fmt.Errorf("lmdbtuple: invalid escape 0x00 %#x", d.b[i+1]^mask)
+		}
+	}
+	return nil, ErrTruncated
+}
+
+func (d *Decoder) fixed(tag byte, n int) ([]byte, error) {
+	mask, err := d.start(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) < n {
+		return nil, ErrTruncated
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = d.b[i] ^ mask
+	}
+	d.b = d.b[n:]
+	return b, nil
+}
+
+// Int64 decodes the next element, which must be a signed integer.
+func (d *Decoder) Int64() (int64, error) {
+	b, err := d.fixed(tagInt, 8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b) ^ 1<<63), nil
+}
+
+// Uint64 decodes the next element, which must be an unsigned integer.
+func (d *Decoder) Uint64() (uint64, error) {
+	b, err := d.fixed(tagUint, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Time decodes the next element, which must be a time, in UTC.
+func (d *Decoder) Time() (time.Time, error) {
+	b, err := d.fixed(tagTime, 12)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec := int64(binary.BigEndian.Uint64(b) ^ 1<<63)
+	nsec := int64(binary.BigEndian.Uint32(b[8:]))
+	return time.Unix(sec, nsec).UTC(), nil
+}
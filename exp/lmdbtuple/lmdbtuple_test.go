@@ -0,0 +1,181 @@
+package lmdbtuple
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestPackUnpack(t *testing.T) {
+	now := time.Unix(1700000000, 123456789).UTC()
+	elems := []interface{}{
+		"a\x00b", []byte{0x00, 0xff, 0x01}, int64(-5), uint64(7), now, "",
+	}
+	for _, descending := range []bool{false, true} {
+		in := elems
+		if descending {
+			in = nil
+			for _, v := range elems {
+				in = append(in, Desc(v))
+			}
+		}
+		key, err := Pack(in...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Unpack(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, elems) {
+			t.Errorf("descending %v: %#v", descending, out)
+		}
+	}
+
+	key, err := Pack(int8(-1), uint16(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(key)
+	if _, err := d.String(); err == nil {
+		t.Errorf("integer decoded as a string")
+	}
+	if n, err := d.Int64(); err != nil || n != -1 {
+		t.Errorf("%v %v", n, err)
+	}
+	if n, err := d.Uint64(); err != nil || n != 2 || d.More() {
+		t.Errorf("%v %v", n, err)
+	}
+
+	if _, err := Pack(1.5); err == nil {
+		t.Errorf("float packed")
+	}
+	if _, err := Unpack(key[:len(key)-1]); err != ErrTruncated {
+		t.Errorf("truncated key: %v", err)
+	}
+	if _, err := Unpack([]byte{tagString, 'a', 0x00}); err != ErrTruncated {
+		t.Errorf("unterminated string: %v", err)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	tuples := [][]interface{}{
+		{""},
+		{"", ""},
+		{"\x00"},
+		{"\x00", ""},
+		{"\x00\x00"},
+		{"\x01"},
+		{"a"},
+		{"a", int64(-1 << 63)},
+		{"a", int64(-1)},
+		{"a", int64(0)},
+		{"a", int64(1 << 40)},
+		{"a", uint64(0), "z"},
+		{"a", uint64(1)},
+		{"a", uint64(1<<64 - 1)},
+		{"a", t0.Add(-time.Nanosecond)},
+		{"a", t0},
+		{"a", t0.Add(time.Second)},
+		{"a\x00"},
+		{"ab"},
+		{"b", Desc(uint64(9))},
+		{"b", Desc(uint64(2)), "x"},
+		{"b", Desc(uint64(2)), "y"},
+		{"b", Desc("b")},
+		{"b", Desc("a\x00")},
+		{"b", Desc("a")},
+		{"b", Desc("")},
+	}
+	var keys [][]byte
+	for _, tuple := range tuples {
+		key, err := Pack(tuple...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Errorf("%v not before %v", tuples[i-1], tuples[i])
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	base := time.Unix(1700000000, 0)
+	var want []int64
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for _, user := range []uint64{1, 2, 10} {
+			for i := int64(0); i < 5; i++ {
+				key, err := Pack("event", user, Desc(base.Add(time.Duration(i)*time.Second)), i)
+				if err != nil {
+					return err
+				}
+				err = txn.Put(dbi, key, nil, 0)
+				if err != nil {
+					return err
+				}
+				if user == 2 {
+					want = append(want, i)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] > want[j] })
+
+	start, end, err := Range("event", uint64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		k, _, err := cur.Get(start, nil, lmdb.SetRange)
+		for ; err == nil && bytes.Compare(k, end) < 0; k, _, err = cur.Get(nil, nil, lmdb.Next) {
+			elems, err := Unpack(k)
+			if err != nil {
+				return err
+			}
+			got = append(got, elems[3].(int64))
+		}
+		if lmdb.IsNotFound(err) {
+			err = nil
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan: %v, expected newest first %v", got, want)
+	}
+}
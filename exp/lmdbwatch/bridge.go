@@ -0,0 +1,185 @@
+package lmdbwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Notification summarizes the keys of one DBI changed by a committed
+// transaction.  Notifications are published by a Bridge so that processes
+// caching data read from copies of the environment know what to invalidate.
+type Notification struct {
+	TxnID uintptr  `json:"txn"`
+	DBI   lmdb.DBI `json:"dbi"`
+
+	// Prefixes are the distinct key prefixes changed in DBI, in ascending
+	// order.  An empty list means that every key in DBI must be considered
+	// changed.
+	Prefixes [][]byte `json:"prefixes"`
+}
+
+// Publisher sends a message to a subject of a message bus.  Publish is only
+// called from the goroutine of a Bridge.
+//
+// Clients for Redis pub/sub and NATS are adapted with a PublisherFunc, for
+// example
+//
+//	lmdbwatch.PublisherFunc(func(subject string, msg []byte) error {
+//		return rdb.Publish(ctx, subject, msg).Err()
+//	})
+//
+// and
+//
+//	lmdbwatch.PublisherFunc(nc.Publish)
+type Publisher interface {
+	Publish(subject string, msg []byte) error
+}
+
+// PublisherFunc is a function implementing Publisher.
+type PublisherFunc func(subject string, msg []byte) error
+
+// Publish calls fn.
+func (fn PublisherFunc) Publish(subject string, msg []byte) error {
+	return fn(subject, msg)
+}
+
+// BridgeOptions configures a Bridge.
+type BridgeOptions struct {
+	// Subject is the subject or channel notifications are published to.
+	Subject string
+
+	// PrefixLen truncates changed keys to at most PrefixLen bytes before they
+	// are added to a notification.  Zero publishes whole keys.
+	PrefixLen int
+
+	// MaxPrefixes bounds the size of a notification.  A transaction changing
+	// more distinct prefixes in a DBI is published with an empty prefix list,
+	// invalidating the whole DBI.  Zero means no bound.
+	MaxPrefixes int
+
+	// Buffer is the number of committed transactions queued for publishing.
+	// Transactions committed while the queue is full are dropped and
+	// counted, see Bridge.Dropped.
+	Buffer int
+
+	// OnError, if not nil, is called with errors returned by the Publisher.
+	OnError func(error)
+}
+
+// Bridge publishes a Notification for every DBI changed by a transaction
+// committed through its Watcher.  Publishing happens on a separate goroutine
+// so a slow message bus never blocks writers.
+type Bridge struct {
+	w       *Watcher
+	pub     Publisher
+	opt     BridgeOptions
+	c       chan []Event
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+// Bridge starts publishing the changes committed through w to pub.  A nil
+// opt uses the zero BridgeOptions.  The returned Bridge must be closed to
+// stop its goroutine.
+func (w *Watcher) Bridge(pub Publisher, opt *BridgeOptions) *Bridge {
+	b := &Bridge{
+		w:    w,
+		pub:  pub,
+		done: make(chan struct{}),
+	}
+	if opt != nil {
+		b.opt = *opt
+	}
+	b.c = make(chan []Event, b.opt.Buffer)
+	go b.loop()
+
+	w.mu.Lock()
+	if w.bridges == nil {
+		w.bridges = make(map[*Bridge]struct{})
+	}
+	w.bridges[b] = struct{}{}
+	w.mu.Unlock()
+	return b
+}
+
+// Dropped returns the number of committed transactions that were not
+// published because the queue of b was full.
+func (b *Bridge) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Close stops b after publishing the transactions already queued.
+func (b *Bridge) Close() {
+	b.once.Do(func() {
+		b.w.mu.Lock()
+		delete(b.w.bridges, b)
+		b.w.mu.Unlock()
+		close(b.c)
+		<-b.done
+	})
+}
+
+// enqueue is called by the Watcher with its lock held.
+func (b *Bridge) enqueue(events []Event) {
+	select {
+	case b.c <- events:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+func (b *Bridge) loop() {
+	defer close(b.done)
+	for events := range b.c {
+		for _, n := range b.notifications(events) {
+			msg, err := json.Marshal(n)
+			if err == nil {
+				err = b.pub.Publish(b.opt.Subject, msg)
+			}
+			if err != nil && b.opt.OnError != nil {
+				b.opt.OnError(err)
+			}
+		}
+	}
+}
+
+// notifications groups the events of a transaction by DBI.
+func (b *Bridge) notifications(events []Event) []*Notification {
+	var ns []*Notification
+	byDBI := make(map[lmdb.DBI]*Notification)
+	seen := make(map[lmdb.DBI]map[string]struct{})
+	for _, ev := range events {
+		n, ok := byDBI[ev.DBI]
+		if !ok {
+			n = &Notification{TxnID: ev.TxnID, DBI: ev.DBI, Prefixes: [][]byte{}}
+			byDBI[ev.DBI] = n
+			seen[ev.DBI] = make(map[string]struct{})
+			ns = append(ns, n)
+		}
+		prefix := ev.Key
+		if b.opt.PrefixLen > 0 && len(prefix) > b.opt.PrefixLen {
+			prefix = prefix[:b.opt.PrefixLen]
+		}
+		if _, ok := seen[ev.DBI][string(prefix)]; ok {
+			continue
+		}
+		seen[ev.DBI][string(prefix)] = struct{}{}
+		n.Prefixes = append(n.Prefixes, prefix)
+	}
+	for _, n := range ns {
+		if b.opt.MaxPrefixes > 0 && len(n.Prefixes) > b.opt.MaxPrefixes {
+			n.Prefixes = [][]byte{}
+			continue
+		}
+		sort.Slice(n.Prefixes, func(i, j int) bool {
+			return bytes.Compare(n.Prefixes[i], n.Prefixes[j]) < 0
+		})
+	}
+	return ns
+}
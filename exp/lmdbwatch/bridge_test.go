@@ -0,0 +1,94 @@
+package lmdbwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestBridge(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var msgs []Notification
+	pub := PublisherFunc(func(subject string, msg []byte) error {
+		if subject != "cache" {
+			return fmt.Errorf("unexpected subject %q", subject)
+		}
+		var n Notification
+		err := json.Unmarshal(msg, &n)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		msgs = append(msgs, n)
+		mu.Unlock()
+		return nil
+	})
+	var errs []error
+	w := New()
+	b := w.Bridge(pub, &BridgeOptions{
+		Subject:     "cache",
+		PrefixLen:   5,
+		MaxPrefixes: 3,
+		Buffer:      10,
+		OnError:     func(err error) { errs = append(errs, err) },
+	})
+
+	update := func(keys ...string) {
+		err := w.Update(env, func(txn *Txn) (err error) {
+			for _, k := range keys {
+				err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	update("user/b", "user/a", "host/x")
+	update("a", "b", "c", "d")
+	b.Close()
+
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("unexpected notifications: %v", msgs)
+	}
+	if msgs[0].DBI != dbi || msgs[0].TxnID == 0 || fmt.Sprintf("%q", msgs[0].Prefixes) != `["host/" "user/"]` {
+		t.Errorf("unexpected notification: %+v", msgs[0])
+	}
+	if len(msgs[1].Prefixes) != 0 {
+		t.Errorf("expected the whole DBI to be invalidated: %+v", msgs[1])
+	}
+	if b.Dropped() != 0 {
+		t.Errorf("unexpected number of dropped transactions: %d", b.Dropped())
+	}
+
+	// a closed bridge receives nothing.
+	update("user/c")
+	if len(msgs) != 2 {
+		t.Errorf("unexpected notifications after close: %v", msgs)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Package lmdbwatch notifies in-process subscribers of changes to keys in an
+LMDB environment, similar to the watch primitive of etcd.
+
+Changes are only observed when they are made through a Txn wrapper created by
+a Watcher.  The wrapper records the keys written and deleted by a transaction
+and, once the transaction commits successfully, delivers an Event to each
+Subscription whose DBI and key prefix match.  Changes made in transactions that
+are aborted are never delivered.
+
+Delivery never blocks the writer.  If the channel of a Subscription is full
+when an event is delivered the event is dropped and counted, see
+Subscription.Dropped.  Subscribers which must not miss changes should use a
+buffered channel large enough for their workload and re-read the database when
+events have been dropped.
+
+A Bridge forwards the same changes to other processes by publishing a
+Notification per changed DBI to a message bus such as Redis pub/sub or NATS.
+Fleets of readers sharing a replicated snapshot of the environment can use
+the notifications to invalidate their caches.
+*/
+package lmdbwatch
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Event describes a change to a key committed by a transaction.
+type Event struct {
+	TxnID uintptr  // ID of the committed transaction
+	DBI   lmdb.DBI // The database containing Key
+	Key   []byte   // The key written or deleted
+	Del   bool     // Del is true if Key was deleted
+}
+
+// Watcher routes events from committed transactions to subscriptions.  A
+// Watcher is safe for concurrent use.
+type Watcher struct {
+	mu      sync.RWMutex
+	subs    map[*Subscription]struct{}
+	bridges map[*Bridge]struct{}
+}
+
+// New returns a Watcher without any subscriptions.
+func New() *Watcher {
+	return &Watcher{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the events for keys with a given prefix in a DBI.
+type Subscription struct {
+	// C receives events in commit order.  C is not closed by Close.
+	C <-chan Event
+
+	c       chan Event
+	w       *Watcher
+	dbi     lmdb.DBI
+	prefix  []byte
+	dropped uint64
+}
+
+// Watch subscribes to changes of keys in dbi starting with prefix.  An empty
+// prefix matches every key in dbi.  The channel of the returned Subscription
+// has a buffer of size n.
+func (w *Watcher) Watch(dbi lmdb.DBI, prefix []byte, n int) *Subscription {
+	c := make(chan Event, n)
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		w:      w,
+		dbi:    dbi,
+		prefix: append([]byte(nil), prefix...),
+	}
+	w.mu.Lock()
+	w.subs[s] = struct{}{}
+	w.mu.Unlock()
+	return s
+}
+
+// Dropped returns the number of events that could not be delivered to s
+// because its channel was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops the delivery of events to s.
+func (s *Subscription) Close() {
+	s.w.mu.Lock()
+	delete(s.w.subs, s)
+	s.w.mu.Unlock()
+}
+
+func (s *Subscription) match(ev *Event) bool {
+	return ev.DBI == s.dbi && bytes.HasPrefix(ev.Key, s.prefix)
+}
+
+func (w *Watcher) deliver(events []Event) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for b := range w.bridges {
+		b.enqueue(events)
+	}
+	for i := range events {
+		for s := range w.subs {
+			if !s.match(&events[i]) {
+				continue
+			}
+			select {
+			case s.c <- events[i]:
+			default:
+				atomic.AddUint64(&s.dropped, 1)
+			}
+		}
+	}
+}
+
+// Txn wraps a write transaction and records the keys changed by its Put and
+// Del methods.  Changes made by calling methods on the embedded lmdb.Txn, or
+// through cursors, are not recorded.
+type Txn struct {
+	*lmdb.Txn
+	w      *Watcher
+	events []Event
+}
+
+// Txn returns a wrapper for txn that delivers events to the subscriptions of
+// w after txn commits.  A transaction should be wrapped at most once.  The
+// changes of a wrapped subtransaction are delivered when its top-level
+// transaction commits.
+func (w *Watcher) Txn(txn *lmdb.Txn) *Txn {
+	t := &Txn{Txn: txn, w: w}
+	txn.OnCommit(t.commit)
+	return t
+}
+
+func (t *Txn) commit(id uintptr) {
+	if len(t.events) == 0 {
+		return
+	}
+	for i := range t.events {
+		t.events[i].TxnID = id
+	}
+	t.w.deliver(t.events)
+	t.events = nil
+}
+
+func (t *Txn) record(dbi lmdb.DBI, key []byte, del bool) {
+	t.events = append(t.events, Event{
+		DBI: dbi,
+		Key: append([]byte(nil), key...),
+		Del: del,
+	})
+}
+
+// Put calls lmdb.Txn.Put and records the change if it succeeds.
+func (t *Txn) Put(dbi lmdb.DBI, key, val []byte, flags uint) error {
+	err := t.Txn.Put(dbi, key, val, flags)
+	if err == nil {
+		t.record(dbi, key, false)
+	}
+	return err
+}
+
+// Del calls lmdb.Txn.Del and records the change if it succeeds.
+func (t *Txn) Del(dbi lmdb.DBI, key, val []byte) error {
+	err := t.Txn.Del(dbi, key, val)
+	if err == nil {
+		t.record(dbi, key, true)
+	}
+	return err
+}
+
+// Update runs fn in a write transaction on env, like lmdb.Env.Update, with
+// the transaction wrapped for w.
+func (w *Watcher) Update(env *lmdb.Env, fn func(txn *Txn) error) error {
+	return env.Update(func(txn *lmdb.Txn) error {
+		return fn(w.Txn(txn))
+	})
+}
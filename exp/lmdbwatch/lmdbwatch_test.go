@@ -0,0 +1,81 @@
+package lmdbwatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestWatcher(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := New()
+	users := w.Watch(dbi, []byte("user/"), 10)
+	defer users.Close()
+	small := w.Watch(dbi, nil, 1)
+	defer small.Close()
+
+	err = w.Update(env, func(txn *Txn) (err error) {
+		for _, k := range []string{"user/a", "group/a", "user/b"} {
+			err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Del(dbi, []byte("user/a"), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes in an aborted transaction are not delivered.
+	err = w.Update(env, func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("user/c"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var events []Event
+	for len(users.C) > 0 {
+		events = append(events, <-users.C)
+	}
+	expect := []struct {
+		key string
+		del bool
+	}{{"user/a", false}, {"user/b", false}, {"user/a", true}}
+	if len(events) != len(expect) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	for i, ev := range events {
+		if string(ev.Key) != expect[i].key || ev.Del != expect[i].del || ev.DBI != dbi || ev.TxnID == 0 {
+			t.Errorf("event %d: unexpected %+v", i, ev)
+		}
+	}
+
+	if len(small.C) != 1 {
+		t.Errorf("unexpected number of buffered events: %d", len(small.C))
+	}
+	if small.Dropped() != 3 {
+		t.Errorf("unexpected number of dropped events: %d", small.Dropped())
+	}
+}
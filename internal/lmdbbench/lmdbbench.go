@@ -0,0 +1,94 @@
+/*
+Package lmdbbench benchmarks the ways package lmdb moves data across the cgo
+boundary, so that changes made for performance can be measured and
+regressions caught.
+
+The benchmarks compare, on the same reproducible dataset, point reads, range
+scans, full cursor iteration and batched writes with Txn.RawRead off and on,
+with a Txn.Arena, with the destination buffers of GetTo and with the batched
+APIs Cursor.GetBatch, Txn.PutMany and Cursor.PutMultiSlices.  Run them with
+
+	go test -run NONE -bench . -benchmem ./internal/lmdbbench
+
+and compare runs before and after a change with benchstat.  The tests of the
+package check the allocations of the paths documented not to allocate, which
+fail without running benchmarks.
+*/
+package lmdbbench
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// loadBatch is the number of items Load writes per transaction.
+const loadBatch = 10000
+
+// Dataset is a reproducible set of items, with keys in ascending order.
+type Dataset struct {
+	Keys [][]byte
+	Vals [][]byte
+}
+
+// NewDataset returns n items with keys of keySize bytes, at least 8, and
+// values of valSize bytes, generated from seed.  Keys are spread unevenly
+// over the key space, as real keys are, and the same arguments always return
+// the same items.
+func NewDataset(seed int64, n, keySize, valSize int) *Dataset {
+	if keySize < 8 {
+		panic("lmdbbench: keys must have at least 8 bytes")
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	d := &Dataset{
+		Keys: make([][]byte, n),
+		Vals: make([][]byte, n),
+	}
+	var next uint64
+	for i := range d.Keys {
+		// The first 8 bytes ascend by random gaps, the others are random.
+		next += 1 + uint64(rnd.Intn(1000))
+		k := make([]byte, keySize)
+		binary.BigEndian.PutUint64(k, next)
+		rnd.Read(k[8:])
+		d.Keys[i] = k
+		v := make([]byte, valSize)
+		rnd.Read(v)
+		d.Vals[i] = v
+	}
+	return d
+}
+
+// Shuffled returns the keys of d in an order generated from seed, for point
+// reads.
+func (d *Dataset) Shuffled(seed int64) [][]byte {
+	keys := append([][]byte(nil), d.Keys...)
+	rnd := rand.New(rand.NewSource(seed))
+	rnd.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	return keys
+}
+
+// Load writes the items of d to dbi, which must be empty, with the Append flag
+// in transactions of loadBatch items.
+func (d *Dataset) Load(env *lmdb.Env, dbi lmdb.DBI) error {
+	for start := 0; start < len(d.Keys); start += loadBatch {
+		end := start + loadBatch
+		if end > len(d.Keys) {
+			end = len(d.Keys)
+		}
+		err := env.Update(func(txn *lmdb.Txn) error {
+			for i := start; i < end; i++ {
+				err := txn.Put(dbi, d.Keys[i], d.Vals[i], lmdb.Append)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package lmdbbench
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+const (
+	benchSeed    = 1
+	benchItems   = 100000
+	benchKeySize = 16
+	benchValSize = 100
+
+	scanLen    = 100  // Items read by each range scan
+	batchLen   = 256  // Items read by each call to GetBatch
+	writeBatch = 1000 // Items written by each write transaction
+	arenaReset = 1000 // Reads between resets of the arena
+)
+
+var (
+	datasetOnce sync.Once
+	dataset     *Dataset
+)
+
+func benchDataset() *Dataset {
+	datasetOnce.Do(func() {
+		dataset = NewDataset(benchSeed, benchItems, benchKeySize, benchValSize)
+	})
+	return dataset
+}
+
+// loadEnv returns an environment holding the benchmark dataset in its root
+// database.
+func loadEnv(tb testing.TB) (*lmdb.Env, lmdb.DBI) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{
+		MaxDBs:  1,
+		MapSize: 1 << 30,
+		Flags:   lmdb.NoSync,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		return err
+	})
+	if err == nil {
+		err = benchDataset().Load(env, dbi)
+	}
+	if err != nil {
+		lmdbtest.Destroy(env)
+		tb.Fatal(err)
+	}
+	return env, dbi
+}
+
+// readMode is a way of returning the items read by a transaction.
+type readMode struct {
+	name  string
+	raw   bool
+	arena bool
+}
+
+var readModes = []readMode{
+	{name: "copy"},
+	{name: "raw", raw: true},
+	{name: "arena", arena: true},
+}
+
+// view runs fn in a read-only transaction set up for m, after resetting the
+// timer of b.  fn calls reset after every read.
+func (m readMode) view(b *testing.B, env *lmdb.Env, fn func(txn *lmdb.Txn, reset func(i int)) error) {
+	err := env.View(func(txn *lmdb.Txn) error {
+		txn.RawRead = m.raw
+		reset := func(int) {}
+		if m.arena {
+			txn.Arena = lmdb.NewArena(0)
+			reset = func(i int) {
+				if i%arenaReset == arenaReset-1 {
+					txn.Arena.Reset()
+				}
+			}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		defer b.StopTimer()
+		return fn(txn, reset)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkGet reads items at random.
+func BenchmarkGet(b *testing.B) {
+	env, dbi := loadEnv(b)
+	defer lmdbtest.Destroy(env)
+	keys := benchDataset().Shuffled(benchSeed)
+
+	for _, m := range readModes {
+		b.Run(m.name, func(b *testing.B) {
+			m.view(b, env, func(txn *lmdb.Txn, reset func(int)) error {
+				for i := 0; i < b.N; i++ {
+					_, err := txn.Get(dbi, keys[i%len(keys)])
+					if err != nil {
+						return err
+					}
+					reset(i)
+				}
+				return nil
+			})
+		})
+	}
+	b.Run("getto", func(b *testing.B) {
+		readMode{}.view(b, env, func(txn *lmdb.Txn, _ func(int)) error {
+			buf := make([]byte, benchValSize)
+			for i := 0; i < b.N; i++ {
+				var err error
+				buf, err = txn.GetTo(dbi, keys[i%len(keys)], buf)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	b.Run("val", func(b *testing.B) {
+		readMode{}.view(b, env, func(txn *lmdb.Txn, _ func(int)) error {
+			for i := 0; i < b.N; i++ {
+				_, err := txn.GetVal(dbi, keys[i%len(keys)])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// BenchmarkRange reads scanLen items from a random key.
+func BenchmarkRange(b *testing.B) {
+	env, dbi := loadEnv(b)
+	defer lmdbtest.Destroy(env)
+	keys := benchDataset().Shuffled(benchSeed)
+
+	scan := func(m readMode, batch bool) func(b *testing.B) {
+		return func(b *testing.B) {
+			m.view(b, env, func(txn *lmdb.Txn, reset func(int)) error {
+				cur, err := txn.OpenCursor(dbi)
+				if err != nil {
+					return err
+				}
+				defer cur.Close()
+				for i := 0; i < b.N; i++ {
+					_, _, err = cur.Get(keys[i%len(keys)], nil, lmdb.SetRange)
+					if batch && err == nil {
+						_, _, err = cur.GetBatch(scanLen - 1)
+					}
+					for j := 1; !batch && j < scanLen && err == nil; j++ {
+						_, _, err = cur.Get(nil, nil, lmdb.Next)
+					}
+					if err != nil && !lmdb.IsNotFound(err) {
+						return err
+					}
+					reset(i)
+				}
+				return nil
+			})
+		}
+	}
+	for _, m := range readModes {
+		b.Run(m.name, scan(m, false))
+	}
+	b.Run("batch_copy", scan(readMode{}, true))
+	b.Run("batch_raw", scan(readMode{raw: true}, true))
+}
+
+// BenchmarkIterate reads the whole database with a cursor.  The ns/item
+// metric is the time per item read.
+func BenchmarkIterate(b *testing.B) {
+	env, dbi := loadEnv(b)
+	defer lmdbtest.Destroy(env)
+
+	iterate := func(m readMode, batch bool) func(b *testing.B) {
+		return func(b *testing.B) {
+			start := time.Now()
+			m.view(b, env, func(txn *lmdb.Txn, reset func(int)) error {
+				cur, err := txn.OpenCursor(dbi)
+				if err != nil {
+					return err
+				}
+				defer cur.Close()
+				start = time.Now()
+				for i := 0; i < b.N; i++ {
+					n := 0
+					_, _, err = cur.Get(nil, nil, lmdb.First)
+					for err == nil {
+						n++
+						reset(n)
+						if batch {
+							var keys [][]byte
+							keys, _, err = cur.GetBatch(batchLen)
+							if err == nil {
+								n += len(keys) - 1
+							}
+						} else {
+							_, _, err = cur.Get(nil, nil, lmdb.Next)
+						}
+					}
+					if !lmdb.IsNotFound(err) {
+						return err
+					}
+					if n != benchItems {
+						b.Fatalf("iterated %d items", n)
+					}
+				}
+				return nil
+			})
+			b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*benchItems), "ns/item")
+		}
+	}
+	for _, m := range readModes {
+		b.Run(m.name, iterate(m, false))
+	}
+	b.Run("batch_copy", iterate(readMode{}, true))
+	b.Run("batch_raw", iterate(readMode{raw: true}, true))
+}
+
+// BenchmarkPut overwrites writeBatch random items per transaction.
+func BenchmarkPut(b *testing.B) {
+	env, dbi := loadEnv(b)
+	defer lmdbtest.Destroy(env)
+	keys := benchDataset().Shuffled(benchSeed)
+	val := make([]byte, benchValSize)
+
+	write := func(fn func(txn *lmdb.Txn, keys [][]byte) error) func(b *testing.B) {
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				off := i * writeBatch % (len(keys) - writeBatch)
+				err := env.Update(func(txn *lmdb.Txn) error {
+					return fn(txn, keys[off:off+writeBatch])
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	b.Run("put", write(func(txn *lmdb.Txn, keys [][]byte) error {
+		for _, k := range keys {
+			err := txn.Put(dbi, k, val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	pairs := make([]lmdb.KV, writeBatch)
+	b.Run("putmany", write(func(txn *lmdb.Txn, keys [][]byte) error {
+		for i, k := range keys {
+			pairs[i] = lmdb.KV{Key: k, Val: val}
+		}
+		_, err := txn.PutMany(dbi, pairs, 0)
+		return err
+	}))
+	b.Run("cursor", write(func(txn *lmdb.Txn, keys [][]byte) error {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for _, k := range keys {
+			err = cur.Put(k, val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	b.Run("reserve", write(func(txn *lmdb.Txn, keys [][]byte) error {
+		for _, k := range keys {
+			buf, err := txn.PutReserve(dbi, k, len(val), 0)
+			if err != nil {
+				return err
+			}
+			copy(buf, val)
+		}
+		return nil
+	}))
+}
+
+// BenchmarkPutDupFixed writes writeBatch duplicates of a key to an emptied
+// DupFixed database per transaction.
+func BenchmarkPutDupFixed(b *testing.B) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1, MapSize: 1 << 30, Flags: lmdb.NoSync})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	var dbi lmdb.DBI
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		dbi, err = txn.OpenDBI("fixed", lmdb.Create|lmdb.DupSort|lmdb.DupFixed)
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	key := []byte("key")
+	vals := make([][]byte, writeBatch)
+	for i := range vals {
+		vals[i] = make([]byte, 8)
+		binary.BigEndian.PutUint64(vals[i], uint64(i))
+	}
+
+	write := func(fn func(cur *lmdb.Cursor) error) func(b *testing.B) {
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := env.Update(func(txn *lmdb.Txn) error {
+					err := txn.Drop(dbi, false)
+					if err != nil {
+						return err
+					}
+					cur, err := txn.OpenCursor(dbi)
+					if err != nil {
+						return err
+					}
+					defer cur.Close()
+					return fn(cur)
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	b.Run("put", write(func(cur *lmdb.Cursor) error {
+		for _, v := range vals {
+			err := cur.Put(key, v, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	b.Run("multi", write(func(cur *lmdb.Cursor) error {
+		return cur.PutMultiSlices(key, vals, 0)
+	}))
+}
+
+func TestDataset(t *testing.T) {
+	d := NewDataset(benchSeed, 1000, benchKeySize, benchValSize)
+	again := NewDataset(benchSeed, 1000, benchKeySize, benchValSize)
+	for i := range d.Keys {
+		if !bytes.Equal(d.Keys[i], again.Keys[i]) || !bytes.Equal(d.Vals[i], again.Vals[i]) {
+			t.Fatalf("item %d differs between datasets of the same seed", i)
+		}
+		if i > 0 && bytes.Compare(d.Keys[i-1], d.Keys[i]) >= 0 {
+			t.Fatalf("key %d not in ascending order", i)
+		}
+	}
+	if other := NewDataset(benchSeed+1, 1000, benchKeySize, benchValSize); bytes.Equal(d.Vals[0], other.Vals[0]) {
+		t.Errorf("datasets of different seeds are equal")
+	}
+	if k := d.Shuffled(benchSeed); len(k) != len(d.Keys) || bytes.Equal(k[0], d.Keys[0]) && bytes.Equal(k[1], d.Keys[1]) {
+		t.Errorf("keys not shuffled")
+	}
+}
+
+// TestAllocs checks the paths documented not to allocate.
+func TestAllocs(t *testing.T) {
+	env, dbi := loadEnv(t)
+	defer lmdbtest.Destroy(env)
+	keys := benchDataset().Shuffled(benchSeed)
+
+	err := env.View(func(txn *lmdb.Txn) error {
+		buf := make([]byte, benchValSize)
+		i := 0
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _ = txn.GetTo(dbi, keys[i], buf)
+			i++
+		})
+		if allocs != 0 {
+			t.Errorf("Txn.GetTo: %v allocations", allocs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) error {
+		dbi, err := txn.OpenDBI("fixed", lmdb.Create|lmdb.DupSort|lmdb.DupFixed)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		vals := make([][]byte, 16)
+		for i := range vals {
+			vals[i] = make([]byte, 8)
+			binary.BigEndian.PutUint64(vals[i], uint64(i))
+		}
+		key := []byte("key")
+		err = cur.PutMultiSlices(key, vals, 0)
+		if err != nil {
+			return err
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			err = cur.PutMultiSlices(key, vals, 0)
+		})
+		if err != nil {
+			return err
+		}
+		if allocs != 0 {
+			t.Errorf("Cursor.PutMultiSlices: %v allocations", allocs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
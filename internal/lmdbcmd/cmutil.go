@@ -1,6 +1,7 @@
 package lmdbcmd
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,12 +12,19 @@ import (
 
 var flagPrintVersion bool
 var flagOpenNoSubDir bool
+var flagJSON bool
 
 func init() {
 	flag.BoolVar(&flagPrintVersion, "V", false, "Write the library version number to the standard output, and exit.")
 	flag.BoolVar(&flagOpenNoSubDir, "n", false, "Open LDMB environment(s) which do not use subdirectories.")
 }
 
+// RegisterJSON defines the -json flag returned by JSON.  Commands that can
+// write their output as JSON call RegisterJSON before flag.Parse.
+func RegisterJSON() {
+	flag.BoolVar(&flagJSON, "json", false, "Write output as JSON instead of human readable text.")
+}
+
 func printVersion(w io.Writer) {
 	fmt.Fprintln(w, lmdb.VersionString())
 }
@@ -40,3 +48,16 @@ func OpenFlag() uint {
 	}
 	return flag
 }
+
+// JSON returns true if commands should write their output as JSON.  It is
+// always false for a command that did not call RegisterJSON.
+func JSON() bool {
+	return flagJSON
+}
+
+// WriteJSON writes v to os.Stdout as an indented JSON document.
+func WriteJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
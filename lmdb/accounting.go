@@ -0,0 +1,128 @@
+package lmdb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DBIStats counts the writes to one database within a transaction.
+type DBIStats struct {
+	DBI      DBI
+	Puts     uint64 // Items written
+	Dels     uint64 // Items deleted
+	PutBytes uint64 // Bytes of the keys and values of the items written
+}
+
+// DBIStats returns the writes counted in txn since it began, per database,
+// ordered by DBI.  Like Stats, the writes of subtransactions are included.
+// Writes are only counted per database in transactions begun while env has a
+// commit statistics function, see Env.SetCommitStats, DBIStats returns nil
+// otherwise.
+func (txn *Txn) DBIStats() []DBIStats {
+	if txn.dbiStats == nil {
+		return nil
+	}
+	s := append([]DBIStats(nil), *txn.dbiStats...)
+	sort.Slice(s, func(i, j int) bool { return s[i].DBI < s[j].DBI })
+	return s
+}
+
+// countPut counts n items of size bytes written to dbi.
+func (txn *Txn) countPut(dbi DBI, n, size uint64) {
+	txn.stats.Puts += n
+	txn.stats.PutBytes += size
+	if txn.dbiStats == nil {
+		return
+	}
+	s := txn.dbiStat(dbi)
+	s.Puts += n
+	s.PutBytes += size
+}
+
+// countDel counts an item deleted from dbi.
+func (txn *Txn) countDel(dbi DBI) {
+	txn.stats.Dels++
+	if txn.dbiStats != nil {
+		txn.dbiStat(dbi).Dels++
+	}
+}
+
+// dbiStat returns the counters of dbi in txn.  Transactions write to few
+// databases, so a linear search is cheaper than a map.
+func (txn *Txn) dbiStat(dbi DBI) *DBIStats {
+	s := *txn.dbiStats
+	for i := range s {
+		if s[i].DBI == dbi {
+			return &s[i]
+		}
+	}
+	*txn.dbiStats = append(s, DBIStats{DBI: dbi})
+	return &(*txn.dbiStats)[len(s)]
+}
+
+// CommitStats describes a committed write transaction.
+type CommitStats struct {
+	TxnID    uintptr
+	Duration time.Duration // Time taken by mdb_txn_commit
+	TxnStats
+	DBIs []DBIStats // Writes per database, see Txn.DBIStats
+}
+
+// SetCommitStats sets a function called with the statistics of every write
+// transaction committed on env, so that write amplification can be measured
+// per database.  fn is called in the goroutine that committed the
+// transaction, after the write lock has been released and before the
+// functions registered with Txn.OnCommit.  A nil fn removes the function.
+// Writes are counted per database only while a function is set.
+// SetCommitStats must not be called concurrently with write transactions.
+func (env *Env) SetCommitStats(fn func(*CommitStats)) {
+	env.commitStats = fn
+}
+
+// EnvCounters are cumulative counts of the write transactions of an
+// environment in the current process.  Puts, Dels and PutBytes only include
+// the writes of committed transactions.
+type EnvCounters struct {
+	Commits  uint64 // Write transactions committed
+	Aborts   uint64 // Write transactions aborted, or whose commit failed
+	Retries  uint64 // Transactions attempted again by Retry
+	Puts     uint64
+	Dels     uint64
+	PutBytes uint64
+}
+
+// envCounters accumulates the EnvCounters of an Env.
+type envCounters struct {
+	mu sync.Mutex
+	c  EnvCounters
+}
+
+// Counters returns the counts of the write transactions of env since it was
+// created.
+func (env *Env) Counters() EnvCounters {
+	env.counters.mu.Lock()
+	defer env.counters.mu.Unlock()
+	return env.counters.c
+}
+
+func (c *envCounters) commit(s *TxnStats) {
+	c.mu.Lock()
+	c.c.Commits++
+	c.c.Puts += s.Puts
+	c.c.Dels += s.Dels
+	c.c.PutBytes += s.PutBytes
+	c.mu.Unlock()
+}
+
+func (c *envCounters) abort() {
+	c.mu.Lock()
+	c.c.Aborts++
+	c.mu.Unlock()
+}
+
+func (c *envCounters) retry() {
+	c.mu.Lock()
+	c.c.Retries++
+	c.mu.Unlock()
+}
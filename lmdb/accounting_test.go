@@ -0,0 +1,154 @@
+package lmdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_DBIStats(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var commits []*CommitStats
+	env.SetCommitStats(func(s *CommitStats) { commits = append(commits, s) })
+
+	var a, b DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		a, err = txn.OpenDBI("a", Create)
+		if err != nil {
+			return err
+		}
+		b, err = txn.OpenDBI("b", Create)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats []DBIStats
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(b, []byte("k1"), []byte("v1"), 0)
+		if err != nil {
+			return err
+		}
+		_, err = txn.PutMany(a, []KV{{[]byte("k1"), []byte("v")}, {[]byte("k2"), []byte("v")}}, 0)
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) error {
+			return txn.Del(a, []byte("k1"), nil)
+		})
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(b)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		err = cur.Put([]byte("k2"), []byte("v2"), 0)
+		if err != nil {
+			return err
+		}
+		stats = txn.DBIStats()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []DBIStats{
+		{DBI: a, Puts: 2, Dels: 1, PutBytes: 6},
+		{DBI: b, Puts: 2, PutBytes: 8},
+	}
+	if a > b {
+		expect[0], expect[1] = expect[1], expect[0]
+	}
+	if !reflect.DeepEqual(stats, expect) {
+		t.Errorf("stats %+v (expect %+v)", stats, expect)
+	}
+
+	if len(commits) != 2 {
+		t.Fatalf("%d commit stats", len(commits))
+	}
+	c := commits[1]
+	if c.TxnID == 0 || c.Puts != 4 || c.Dels != 1 || c.PutBytes != 14 || !reflect.DeepEqual(c.DBIs, expect) {
+		t.Errorf("commit stats %+v", c)
+	}
+
+	// without a commit statistics function writes are not counted per
+	// database.
+	env.SetCommitStats(nil)
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(a, []byte("k3"), []byte("v3"), 0)
+		if err != nil {
+			return err
+		}
+		if s := txn.DBIStats(); s != nil {
+			t.Errorf("stats %+v", s)
+		}
+		if s := txn.Stats(); s.Puts != 1 {
+			t.Errorf("txn stats %+v", s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnv_Counters(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := env.Counters()
+
+	err = env.Update(func(txn *Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFail := errors.New("fail")
+	err = env.Update(func(txn *Txn) error {
+		err := txn.Put(dbi, []byte("x"), []byte("y"), 0)
+		if err != nil {
+			return err
+		}
+		return errFail
+	})
+	if err != errFail {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	err = env.UpdateRetry(&RetryPolicy{MaxRetries: 2}, func(txn *Txn) error {
+		attempts++
+		if attempts == 1 {
+			return MapResized
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := env.Counters()
+	c.Commits -= before.Commits
+	c.Aborts -= before.Aborts
+	c.Retries -= before.Retries
+	c.Puts -= before.Puts
+	c.Dels -= before.Dels
+	c.PutBytes -= before.PutBytes
+	expect := EnvCounters{Commits: 2, Aborts: 2, Retries: 1, Puts: 1, PutBytes: 2}
+	if c != expect {
+		t.Errorf("counters %+v (expect %+v)", c, expect)
+	}
+}
@@ -0,0 +1,121 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Advice describes the expected access pattern of the memory map to the
+// operating system.  See Env.Advise.
+type Advice int
+
+// Advice values, corresponding to those of madvise(2).
+const (
+	AdviseNormal     Advice = iota // No special treatment
+	AdviseRandom                   // Expect random access, disabling readahead
+	AdviseSequential               // Expect sequential access, reading ahead aggressively
+	AdviseWillNeed                 // Read the data pages into the page cache now
+	AdviseDontNeed                 // Release the data pages from the process
+)
+
+// errMapUnknown is returned by Advise and Warmup when the address of the
+// memory map cannot be determined.
+var errMapUnknown = errors.New("lmdb: cannot locate the memory map of an empty environment")
+
+// Advise passes advice about the access pattern of the memory map of env to
+// the operating system with madvise(2).  AdviseRandom suits point reads of an
+// environment larger than RAM and AdviseSequential suits full scans.
+// AdviseWillNeed and AdviseDontNeed apply to the pages in use, the others to
+// the whole map.
+//
+// Advice lasts until the map is replaced, which happens when the map size
+// changes, after which Advise must be called again.  The NoReadahead flag,
+// see WithReadahead, makes LMDB apply AdviseRandom itself whenever it maps
+// the environment.
+//
+// Advise locates the map with mdb_env_info if env was opened with FixedMap and
+// otherwise through the root database, in which case it returns an error if
+// the environment holds no items or databases.  It is not supported on
+// Windows.
+func (env *Env) Advise(advice Advice) error {
+	if advice < AdviseNormal || advice > AdviseDontNeed {
+		return errors.New("lmdb: invalid advice")
+	}
+	var base unsafe.Pointer
+	var size uintptr
+	err := env.View(func(txn *Txn) (err error) {
+		base, err = txn.mapBase()
+		if err != nil {
+			return err
+		}
+		info, err := env.Info()
+		if err != nil {
+			return err
+		}
+		size = uintptr(info.MapSize)
+		if advice == AdviseWillNeed || advice == AdviseDontNeed {
+			stat, err := env.Stat()
+			if err != nil {
+				return err
+			}
+			size = uintptr(info.LastPNO+1) * uintptr(stat.PSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return madvise(base, size, advice)
+}
+
+// mapBase returns the address of the memory map read by txn.  LMDB reports the
+// address in mdb_env_info only for an environment opened with FixedMap.
+// Otherwise mapBase finds the leaf page holding the first key of the root
+// database, which lies in the map for read-only transactions, and subtracts
+// the offset of the page given by the page number in its header.  It returns
+// errMapUnknown if the environment holds no items or databases.
+func (txn *Txn) mapBase() (unsafe.Pointer, error) {
+	var info C.MDB_envinfo
+	ret := C.mdb_env_info(txn.env._env, &info)
+	if ret != success {
+		return nil, operrno("mdb_env_info", ret)
+	}
+	if info.me_mapaddr != nil {
+		return info.me_mapaddr, nil
+	}
+
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+
+	root, err := txn.OpenRoot(0)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := txn.OpenCursor(root)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	k, _, err := cur.Get(nil, nil, First)
+	if IsNotFound(err) {
+		return nil, errMapUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	stat, err := txn.env.Stat()
+	if err != nil {
+		return nil, err
+	}
+	psize := uintptr(stat.PSize)
+	p := unsafe.Pointer(&k[0])
+	page := unsafe.Add(p, -int(uintptr(p)&(psize-1)))
+	pgno := *(*uintptr)(page)
+	return unsafe.Add(page, -int(pgno*psize)), nil
+}
@@ -0,0 +1,115 @@
+package lmdb
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestEnv_Advise(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Advise(AdviseRandom)
+	if err != errMapUnknown {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for i := 0; i < 1000; i++ {
+			err = txn.Put(dbi, []byte{byte(i >> 8), byte(i)}, make([]byte, 100), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The map starts with a meta page holding the magic number.
+	err = env.View(func(txn *Txn) error {
+		base, err := txn.mapBase()
+		if err != nil {
+			return err
+		}
+		magic := *(*uint32)(unsafe.Add(base, filePageHeader))
+		if magic != fileMagic {
+			t.Errorf("magic %#x (!= %#x)", magic, fileMagic)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, advice := range []Advice{AdviseRandom, AdviseSequential, AdviseWillNeed, AdviseDontNeed, AdviseNormal} {
+		err = env.Advise(advice)
+		if err != nil {
+			t.Errorf("advice %d: %v", advice, err)
+		}
+	}
+	err = env.Advise(Advice(-1))
+	if err == nil {
+		t.Errorf("expected error")
+	}
+
+	err = env.View(func(txn *Txn) error {
+		v, err := txn.Get(dbi, []byte{0, 1})
+		if err != nil {
+			return err
+		}
+		if len(v) != 100 {
+			t.Errorf("len %d (!= 100)", len(v))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnv_Advise_fixedMap(t *testing.T) {
+	env := setupFlags(t, FixedMap)
+	defer clean(env, t)
+
+	// LMDB reports the address of a fixed map, also when it is empty.
+	err := env.Advise(AdviseRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) error {
+		base, err := txn.mapBase()
+		if err != nil {
+			return err
+		}
+		magic := *(*uint32)(unsafe.Add(base, filePageHeader))
+		if magic != fileMagic {
+			t.Errorf("magic %#x (!= %#x)", magic, fileMagic)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithReadahead(t *testing.T) {
+	path := t.TempDir()
+	env, err := Open(path, WithReadahead(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	flags, err := env.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&NoReadahead == 0 {
+		t.Errorf("NoReadahead is not set")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package lmdb
+
+/*
+#include <errno.h>
+#include <sys/mman.h>
+
+static int lmdbgo_populate_read(void *addr, size_t size) {
+#if defined(__linux__)
+	// MADV_POPULATE_READ, available since Linux 5.14.
+	return madvise(addr, size, 22);
+#else
+	errno = ENOSYS;
+	return -1;
+#endif
+}
+*/
+import "C"
+
+import "unsafe"
+
+func madvise(addr unsafe.Pointer, size uintptr, advice Advice) error {
+	var a C.int
+	switch advice {
+	case AdviseNormal:
+		a = C.MADV_NORMAL
+	case AdviseRandom:
+		a = C.MADV_RANDOM
+	case AdviseSequential:
+		a = C.MADV_SEQUENTIAL
+	case AdviseWillNeed:
+		a = C.MADV_WILLNEED
+	case AdviseDontNeed:
+		a = C.MADV_DONTNEED
+	}
+	ret, err := C.madvise(addr, C.size_t(size), a)
+	if ret != 0 {
+		return &OpError{Op: "madvise", Errno: err}
+	}
+	return nil
+}
+
+// populate faults in the pages of a region with MADV_POPULATE_READ and
+// returns false if the system does not support it.
+func populate(addr unsafe.Pointer, size uintptr) bool {
+	return C.lmdbgo_populate_read(addr, C.size_t(size)) == 0
+}
@@ -0,0 +1,14 @@
+package lmdb
+
+import (
+	"errors"
+	"unsafe"
+)
+
+func madvise(addr unsafe.Pointer, size uintptr, advice Advice) error {
+	return errors.New("lmdb: Advise is not supported on Windows")
+}
+
+func populate(addr unsafe.Pointer, size uintptr) bool {
+	return false
+}
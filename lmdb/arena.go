@@ -0,0 +1,113 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+// DefaultArenaChunk is the size of the chunks allocated by an Arena created
+// with a non-positive chunk size.
+const DefaultArenaChunk = 64 << 10
+
+// Arena is a bump allocator for copies of keys and values read by a
+// transaction.  When Txn.Arena is set and Txn.RawRead is false, Get and
+// Cursor.Get copy into the arena instead of allocating a new slice per read.
+// Slices remain valid until the arena is reset, independently of the
+// transaction, so an arena trades per-read allocations for memory held until
+// Reset.
+//
+// An Arena is not safe for concurrent use.  Applications serving many
+// concurrent reads typically keep arenas in a sync.Pool and reset them when
+// the results of a request are no longer referenced.
+type Arena struct {
+	size   int
+	chunk  []byte
+	chunks [][]byte // retired chunks available after Reset
+	used   [][]byte // chunks filled since the last Reset
+}
+
+// NewArena returns an Arena allocating memory in chunks of the given size.
+// Values larger than a quarter of a chunk are allocated individually.
+func NewArena(chunk int) *Arena {
+	if chunk <= 0 {
+		chunk = DefaultArenaChunk
+	}
+	return &Arena{size: chunk}
+}
+
+// Copy returns a copy of b allocated from a.
+func (a *Arena) Copy(b []byte) []byte {
+	n := len(b)
+	if n > a.size/4 {
+		return append([]byte(nil), b...)
+	}
+	if cap(a.chunk)-len(a.chunk) < n {
+		if a.chunk != nil {
+			a.used = append(a.used, a.chunk)
+		}
+		if k := len(a.chunks); k > 0 {
+			a.chunk = a.chunks[k-1][:0]
+			a.chunks = a.chunks[:k-1]
+		} else {
+			a.chunk = make([]byte, 0, a.size)
+		}
+	}
+	off := len(a.chunk)
+	a.chunk = append(a.chunk, b...)
+	return a.chunk[off : off+n : off+n]
+}
+
+// Reset makes the memory of a available for reuse.  Slices previously
+// returned by a must no longer be referenced.
+func (a *Arena) Reset() {
+	if a.chunk != nil {
+		a.chunks = append(a.chunks, a.chunk[:0])
+		a.chunk = nil
+	}
+	for _, c := range a.used {
+		a.chunks = append(a.chunks, c[:0])
+	}
+	a.used = a.used[:0]
+}
+
+// GetTo retrieves an item from database dbi like Get but copies the value into
+// dst, which is grown if it is too small, and returns the resulting slice.
+// GetTo does not allocate when dst has sufficient capacity, regardless of
+// txn.RawRead and txn.Arena.
+//
+// See mdb_get.
+func (txn *Txn) GetTo(dbi DBI, key, dst []byte) ([]byte, error) {
+	raw := txn.RawRead
+	txn.RawRead = true
+	val, err := txn.Get(dbi, key)
+	txn.RawRead = raw
+	if err != nil {
+		return dst[:0], err
+	}
+	return append(dst[:0], val...), nil
+}
+
+// GetTo moves the cursor like Get but copies the key and value into kdst and
+// vdst, which are grown if they are too small, and returns the resulting
+// slices.  GetTo does not allocate when the buffers have sufficient capacity.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetTo(setkey, setval []byte, op uint, kdst, vdst []byte) (key, val []byte, err error) {
+	raw := c.txn.RawRead
+	c.txn.RawRead = true
+	k, v, err := c.Get(setkey, setval, op)
+	c.txn.RawRead = raw
+	if err != nil {
+		return kdst[:0], vdst[:0], err
+	}
+	return append(kdst[:0], k...), append(vdst[:0], v...), nil
+}
+
+// arenaBytes copies val into the arena of txn.
+func (txn *Txn) arenaBytes(val *C.MDB_val) []byte {
+	b := getBytes(val)
+	if b == nil {
+		return nil
+	}
+	return txn.Arena.Copy(b)
+}
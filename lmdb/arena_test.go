@@ -0,0 +1,127 @@
+package lmdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena(64)
+	var bs [][]byte
+	for i := 0; i < 20; i++ {
+		bs = append(bs, a.Copy([]byte(fmt.Sprintf("value%02d", i))))
+	}
+	big := a.Copy(bytes.Repeat([]byte("x"), 100))
+	if len(big) != 100 {
+		t.Errorf("len %d (!= 100)", len(big))
+	}
+	for i, b := range bs {
+		if string(b) != fmt.Sprintf("value%02d", i) {
+			t.Errorf("copy %d: %q", i, b)
+		}
+		if cap(b) != len(b) {
+			t.Errorf("copy %d: capacity %d (!= %d)", i, cap(b), len(b))
+		}
+	}
+
+	a.Reset()
+	allocs := testing.AllocsPerRun(100, func() {
+		for i := 0; i < 20; i++ {
+			a.Copy([]byte("value00"))
+		}
+		a.Reset()
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations after Reset (!= 0)", allocs)
+	}
+}
+
+func TestTxn_GetTo(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprintf("v%d", i)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		buf := make([]byte, 0, 16)
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, err = txn.GetTo(dbi, []byte("k3"), buf)
+		})
+		if err != nil {
+			return err
+		}
+		if string(buf) != "v3" {
+			t.Errorf("value %q (!= %q)", buf, "v3")
+		}
+		if allocs > 1 {
+			t.Errorf("GetTo made %v allocations", allocs)
+		}
+		if txn.RawRead {
+			t.Error("GetTo left RawRead set")
+		}
+
+		_, err = txn.GetTo(dbi, []byte("missing"), buf)
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		var k, v []byte
+		for i := 0; ; i++ {
+			k, v, err = cur.GetTo(nil, nil, Next, k, v)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if string(k) != fmt.Sprintf("k%d", i) || string(v) != fmt.Sprintf("v%d", i) {
+				t.Errorf("item %d: %q=%q", i, k, v)
+			}
+		}
+
+		txn.Arena = NewArena(0)
+		v1, err := txn.Get(dbi, []byte("k1"))
+		if err != nil {
+			return err
+		}
+		if string(v1) != "v1" {
+			t.Errorf("value %q (!= %q)", v1, "v1")
+		}
+		key := []byte("k2")
+		allocs = testing.AllocsPerRun(100, func() {
+			_, err = txn.Get(dbi, key)
+		})
+		if err != nil {
+			return err
+		}
+		if allocs > 0 {
+			t.Errorf("Get with an Arena made %v allocations", allocs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package lmdb
+
+/*
+#include <stdlib.h>
+#include "lmdbgo.h"
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// AssertError is the value of the panic raised when LMDB detects an internal
+// inconsistency in an environment with a handler installed by SetAssert.
+type AssertError struct {
+	Path string // Path of the environment
+	Msg  string // Message of the failed assertion, including its location in mdb.c
+}
+
+func (err *AssertError) Error() string {
+	return "lmdb: assertion failed: " + err.Msg + " (" + err.Path + ")"
+}
+
+// assertEnvs maps the C environments with an assertion handler to their Env,
+// because MDB_assert_func receives no context argument.
+var assertEnvs = map[*C.MDB_env]*Env{}
+var assertEnvsLock sync.RWMutex
+
+// SetAssert installs fn as the handler of assertion failures in the LMDB
+// library for env.  LMDB prints the message of a failed assertion and aborts
+// the process, leaving no trace of the Go side of the program.  With a handler
+// installed, fn is called with the message and, when fn returns, a panic with
+// an *AssertError is raised in the goroutine calling into LMDB instead.  The
+// panic can be recovered to log the stack and any application context.
+//
+// A panic raised by the handler unwinds through the C frames of LMDB, leaving
+// its locks and the transaction in an undefined state.  The environment must
+// not be used after an assertion failure and the process should exit once the
+// failure has been reported.  Passing a nil fn removes the handler.
+//
+// Assertions are compiled into LMDB unless it is built with NDEBUG, which this
+// package does not define.
+//
+// See mdb_env_set_assert.
+func (env *Env) SetAssert(fn func(env *Env, msg string)) error {
+	on := 0
+	if fn != nil {
+		on = 1
+	}
+	assertEnvsLock.Lock()
+	defer assertEnvsLock.Unlock()
+	ret := C.lmdbgo_mdb_env_set_assert(env._env, C.int(on))
+	if ret != success {
+		return operrno("mdb_env_set_assert", ret)
+	}
+	env.assert = fn
+	if fn != nil {
+		assertEnvs[env._env] = env
+	} else {
+		delete(assertEnvs, env._env)
+	}
+	return nil
+}
+
+// lmdbgoAssertBridge is called by lmdbgo_assert_proxy when an assertion fails
+// in an environment with a handler.  If the handler was removed concurrently
+// the bridge returns and LMDB aborts.
+//
+//export lmdbgoAssertBridge
+func lmdbgoAssertBridge(_env *C.MDB_env, cmsg C.lmdbgo_ConstCString) {
+	assertEnvsLock.RLock()
+	env := assertEnvs[_env]
+	assertEnvsLock.RUnlock()
+	if env == nil || env.assert == nil {
+		return
+	}
+	err := &AssertError{Msg: C.GoString(cmsg.p)}
+	err.Path, _ = env.Path()
+	env.assert(env, err.Msg)
+	panic(err)
+}
+
+// releaseAssert removes the handler of env when its C environment is closed.
+func (env *Env) releaseAssert() {
+	assertEnvsLock.Lock()
+	delete(assertEnvs, env._env)
+	assertEnvsLock.Unlock()
+}
+
+// _assert is for use by tests that can't import C.  It fails an assertion in
+// env as LMDB would.
+func (env *Env) _assert(msg string) {
+	cmsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cmsg))
+	C.lmdbgo_assert_proxy(env._env, cmsg)
+}
@@ -0,0 +1,51 @@
+package lmdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnv_SetAssert(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var msgs []string
+	err := env.SetAssert(func(env *Env, msg string) {
+		msgs = append(msgs, msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			e := recover()
+			err, ok := e.(*AssertError)
+			if !ok {
+				t.Fatalf("unexpected panic: %v", e)
+			}
+			if err.Msg != "mdb.c:1: Assertion 'x' failed" {
+				t.Errorf("unexpected message: %q", err.Msg)
+			}
+			path, _ := env.Path()
+			if err.Path != path || !strings.Contains(err.Error(), path) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		env._assert("mdb.c:1: Assertion 'x' failed")
+	}()
+	if len(msgs) != 1 {
+		t.Errorf("unexpected handler calls: %q", msgs)
+	}
+
+	err = env.SetAssert(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEnvsLock.RLock()
+	n := len(assertEnvs)
+	assertEnvsLock.RUnlock()
+	if n != 0 {
+		t.Errorf("handler was not removed")
+	}
+}
@@ -93,6 +93,46 @@ func BenchmarkTxn_Put(b *testing.B) {
 	}
 }
 
+// repeatedly put (overwrite) keys in batches using the PutMany method.
+func BenchmarkTxn_PutMany(b *testing.B) {
+	initRandSource(b)
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := openBenchDBI(b, env)
+
+	rc := newRandSourceCursor()
+	ps, err := populateBenchmarkDB(env, dbi, &rc)
+	if err != nil {
+		b.Errorf("populate db: %v", err)
+		return
+	}
+
+	const batch = 100
+	pairs := make([]KV, 0, batch)
+	err = env.Update(func(txn *Txn) (err error) {
+		b.ResetTimer()
+		defer b.StopTimer()
+		for i := 0; i < b.N; i++ {
+			k := ps[rand.Intn(len(ps)/2)*2]
+			v := makeBenchDBVal(&rc)
+			pairs = append(pairs, KV{Key: k, Val: v})
+			if len(pairs) == batch || i == b.N-1 {
+				_, err := txn.PutMany(dbi, pairs, 0)
+				if err != nil {
+					return err
+				}
+				pairs = pairs[:0]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+		return
+	}
+}
+
 // repeatedly put (overwrite) keys using the PutReserve method.
 func BenchmarkTxn_PutReserve(b *testing.B) {
 	initRandSource(b)
@@ -1495,6 +1535,37 @@ func BenchmarkScan_10000_alloc_ro_raw(b *testing.B) {
 	}
 }
 
+func BenchmarkScan_10000_batch_ro_raw(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := openBenchDBI(b, env)
+
+	if !populateDBI(b, env, dbi, testRecordSetSized(benchmarkScanDBSize)) {
+		return
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := env.View(func(txn *Txn) (err error) {
+			txn.RawRead = true
+
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+
+			return benchmarkScanDBIBatch(cur, dbi, 10000, 100)
+		})
+
+		if err != nil {
+			b.Error(err)
+			return
+		}
+	}
+}
+
 func BenchmarkScan_10000_renew_ro_raw(b *testing.B) {
 	env := setup(b)
 	defer clean(env, b)
@@ -1593,6 +1664,19 @@ func benchmarkScanDBI(cur *Cursor, dbi DBI, n int) error {
 	return nil
 }
 
+func benchmarkScanDBIBatch(cur *Cursor, dbi DBI, n, batch int) error {
+	for i := 0; n < 0 || i < n; i += batch {
+		_, _, err := cur.GetBatch(batch)
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func openBenchDBI(b *testing.B, env *Env) DBI {
 	var dbi DBI
 	err := env.Update(func(txn *Txn) (err error) {
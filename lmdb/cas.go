@@ -0,0 +1,67 @@
+package lmdb
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrValueMismatch is returned by Txn.CompareAndSwap and Txn.CompareAndDelete
+// when the stored value differs from the expected one.
+var ErrValueMismatch = errors.New("lmdb: value does not match expected value")
+
+// CompareAndSwap stores val for key in dbi if the value currently stored for
+// key equals old.  A nil old requires that key is absent, while an empty
+// non-nil old matches an empty value.  If the stored value differs
+// CompareAndSwap returns ErrValueMismatch and dbi is unchanged.
+//
+// The comparison and the write happen in txn, so the outcome holds for as long
+// as txn is not aborted.  CompareAndSwap is not meaningful for databases with
+// the DupSort flag.
+func (txn *Txn) CompareAndSwap(dbi DBI, key, old, val []byte) error {
+	err := txn.compare(dbi, key, old)
+	if err != nil {
+		return err
+	}
+	return txn.Put(dbi, key, val, 0)
+}
+
+// CompareAndDelete deletes key from dbi if its value equals old.  If the
+// stored value differs, or key is absent, CompareAndDelete returns
+// ErrValueMismatch and dbi is unchanged.
+func (txn *Txn) CompareAndDelete(dbi DBI, key, old []byte) error {
+	if old == nil {
+		old = []byte{}
+	}
+	err := txn.compare(dbi, key, old)
+	if err != nil {
+		return err
+	}
+	return txn.Del(dbi, key, nil)
+}
+
+// compare returns nil if the value of key in dbi is old, or key is absent and
+// old is nil.
+func (txn *Txn) compare(dbi DBI, key, old []byte) error {
+	cur, err := txn.Get(dbi, key)
+	if IsNotFound(err) {
+		if old == nil {
+			return nil
+		}
+		return ErrValueMismatch
+	}
+	if err != nil {
+		return err
+	}
+	if old == nil || !bytes.Equal(cur, old) {
+		return ErrValueMismatch
+	}
+	return nil
+}
+
+// PutIfAbsent stores val for key in dbi if key is absent.  If key is present
+// PutIfAbsent returns an error for which IsErrno(err, KeyExist) is true and
+// dbi is unchanged.  PutIfAbsent is shorthand for Put with the NoOverwrite
+// flag.
+func (txn *Txn) PutIfAbsent(dbi DBI, key, val []byte) error {
+	return txn.Put(dbi, key, val, NoOverwrite)
+}
@@ -0,0 +1,76 @@
+package lmdb
+
+import (
+	"testing"
+)
+
+func TestTxn_CompareAndSwap(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		k := []byte("k")
+		err = txn.CompareAndSwap(dbi, k, []byte{}, []byte("v1"))
+		if err != ErrValueMismatch {
+			t.Errorf("unexpected error for absent key: %v", err)
+		}
+		err = txn.CompareAndSwap(dbi, k, nil, []byte("v1"))
+		if err != nil {
+			return err
+		}
+		err = txn.CompareAndSwap(dbi, k, nil, []byte("v2"))
+		if err != ErrValueMismatch {
+			t.Errorf("unexpected error for present key: %v", err)
+		}
+		err = txn.CompareAndSwap(dbi, k, []byte("v0"), []byte("v2"))
+		if err != ErrValueMismatch {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.CompareAndSwap(dbi, k, []byte("v1"), []byte("v2"))
+		if err != nil {
+			return err
+		}
+		v, err := txn.Get(dbi, k)
+		if err != nil {
+			return err
+		}
+		if string(v) != "v2" {
+			t.Errorf("unexpected value: %q", v)
+		}
+
+		err = txn.PutIfAbsent(dbi, k, []byte("v3"))
+		if !IsErrno(err, KeyExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.PutIfAbsent(dbi, []byte("k2"), []byte("v3"))
+		if err != nil {
+			return err
+		}
+
+		err = txn.CompareAndDelete(dbi, k, []byte("v1"))
+		if err != ErrValueMismatch {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.CompareAndDelete(dbi, k, []byte("v2"))
+		if err != nil {
+			return err
+		}
+		_, err = txn.Get(dbi, k)
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.CompareAndDelete(dbi, k, nil)
+		if err != ErrValueMismatch {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package lmdb
+
+/*
+#include <stdlib.h>
+#include "lmdb.h"
+#include "lmdbgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// Compare is a function that defines the order of keys or duplicate values in
+// a database.  A Compare is either one of the built-in C functions provided by
+// the package or a Go function registered with RegisterCompare.
+//
+// See MDB_cmp_func.
+type Compare struct {
+	fn *C.MDB_cmp_func
+}
+
+// Built-in comparison functions, implemented in C.
+var (
+	// CompareDescending orders values in reverse (descending) lexicographic
+	// order.  Unlike the ReverseKey flag it compares bytes from the start of
+	// each value.
+	CompareDescending = &Compare{fn: (*C.MDB_cmp_func)(C.lmdbgo_cmp_descending)}
+
+	// CompareCaseInsensitive orders values lexicographically after mapping
+	// ASCII upper case letters to lower case.
+	CompareCaseInsensitive = &Compare{fn: (*C.MDB_cmp_func)(C.lmdbgo_cmp_caseinsensitive)}
+
+	// CompareUint32BE orders values by interpreting their first 4 bytes as a
+	// big-endian unsigned integer.  Shorter values are treated as if padded
+	// with zeros on the left and ties are broken by comparing any remaining
+	// bytes lexicographically.
+	CompareUint32BE = &Compare{fn: (*C.MDB_cmp_func)(C.lmdbgo_cmp_uint32be)}
+
+	// CompareUint64BE is like CompareUint32BE for 8 byte integers.
+	CompareUint64BE = &Compare{fn: (*C.MDB_cmp_func)(C.lmdbgo_cmp_uint64be)}
+)
+
+// errCompareSlots is returned by RegisterCompare when all slots are in use.
+var errCompareSlots = errors.New("lmdb: no comparison function slots available")
+
+// errCompareNil is returned by SetCompare and SetDupCompare for a nil Compare.
+var errCompareNil = errors.New("lmdb: nil Compare")
+
+// cmpslots holds the Go functions registered with RegisterCompare.  A slot is
+// never released because LMDB may call its function for as long as any
+// environment using it is open.
+var cmpslots [C.LMDBGO_CMP_SLOTS]func(a, b []byte) int
+var cmpslotn int
+var cmpslotlock sync.RWMutex
+
+// RegisterCompare returns a Compare that calls fn to order values.  The number
+// of functions that may be registered in a process is small (16) and
+// registered functions cannot be released, so applications should register
+// their functions once during initialization.
+//
+// The slices passed to fn reference memory owned by LMDB and must not be
+// modified or retained after fn returns.  Calling back into Go from C for
+// every comparison is considerably slower than using a built-in Compare.
+func RegisterCompare(fn func(a, b []byte) int) (*Compare, error) {
+	cmpslotlock.Lock()
+	defer cmpslotlock.Unlock()
+	if cmpslotn >= len(cmpslots) {
+		return nil, errCompareSlots
+	}
+	i := cmpslotn
+	cmpslots[i] = fn
+	cmpslotn++
+	return &Compare{fn: C.lmdbgo_cmp_slot(C.int(i))}, nil
+}
+
+// lmdbgoCompareBridge provides the implementation for the static C functions
+// returned by lmdbgo_cmp_slot.  It dispatches the comparison to the Go function
+// registered in the given slot.
+//
+//export lmdbgoCompareBridge
+func lmdbgoCompareBridge(slot C.int, a, b *C.MDB_val) C.int {
+	cmpslotlock.RLock()
+	fn := cmpslots[slot]
+	cmpslotlock.RUnlock()
+	return C.int(fn(cmpBytes(a), cmpBytes(b)))
+}
+
+func cmpBytes(val *C.MDB_val) []byte {
+	if val.mv_size == 0 {
+		return nil
+	}
+	return getBytes(val)
+}
+
+// SetCompare sets the function used to order the keys in dbi.  SetCompare must
+// be called before any data in dbi is accessed, and every process using the
+// database must set the same function whenever it opens dbi or the database
+// will become corrupt.  It is recommended to call SetCompare in the same
+// transaction that opens dbi.  SetCompare returns an error if cmp is nil, the
+// default order of a database cannot be restored once changed.
+//
+// See mdb_set_compare.
+func (txn *Txn) SetCompare(dbi DBI, cmp *Compare) error {
+	if cmp == nil {
+		return errCompareNil
+	}
+	ret := C.mdb_set_compare(txn._txn, C.MDB_dbi(dbi), cmp.fn)
+	return operrno("mdb_set_compare", ret)
+}
+
+// SetDupCompare sets the function used to order duplicate values in dbi, which
+// must have the DupSort flag.  The same restrictions apply as for SetCompare,
+// and cmp must not be nil either.
+//
+// See mdb_set_dupsort.
+func (txn *Txn) SetDupCompare(dbi DBI, cmp *Compare) error {
+	if cmp == nil {
+		return errCompareNil
+	}
+	ret := C.mdb_set_dupsort(txn._txn, C.MDB_dbi(dbi), cmp.fn)
+	return operrno("mdb_set_dupsort", ret)
+}
+
+// Cmp compares the keys a and b as they would be ordered in dbi and returns a
+// negative value, zero, or a positive value if a is less than, equal to, or
+// greater than b respectively.
+//
+// See mdb_cmp.
+func (txn *Txn) Cmp(dbi DBI, a, b []byte) int {
+	adata, an := valBytes(a)
+	bdata, bn := valBytes(b)
+	return int(C.lmdbgo_mdb_cmp(
+		txn._txn, C.MDB_dbi(dbi),
+		(*C.char)(unsafe.Pointer(&adata[0])), C.size_t(an),
+		(*C.char)(unsafe.Pointer(&bdata[0])), C.size_t(bn),
+	))
+}
+
+// DCmp compares the duplicate values a and b as they would be ordered in dbi,
+// which must have the DupSort flag.  DCmp returns a value with the same
+// meaning as Cmp.
+//
+// See mdb_dcmp.
+func (txn *Txn) DCmp(dbi DBI, a, b []byte) int {
+	adata, an := valBytes(a)
+	bdata, bn := valBytes(b)
+	return int(C.lmdbgo_mdb_dcmp(
+		txn._txn, C.MDB_dbi(dbi),
+		(*C.char)(unsafe.Pointer(&adata[0])), C.size_t(an),
+		(*C.char)(unsafe.Pointer(&bdata[0])), C.size_t(bn),
+	))
+}
@@ -0,0 +1,175 @@
+package lmdb
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_SetCompare(t *testing.T) {
+	byLen, err := RegisterCompare(func(a, b []byte) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return bytes.Compare(a, b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		cmp    *Compare
+		keys   []string
+		expect []string
+	}{
+		{"descending", CompareDescending, []string{"a", "ab", "b"}, []string{"b", "ab", "a"}},
+		{"caseinsensitive", CompareCaseInsensitive, []string{"b", "A", "c"}, []string{"A", "b", "c"}},
+		{"uint32be", CompareUint32BE, []string{"\x02", "\x00\x01", "\x00\x00\x01\x00"}, []string{"\x00\x01", "\x02", "\x00\x00\x01\x00"}},
+		{"uint64be", CompareUint64BE, []string{"\x01\x00", "\x00\x00\x00\x02", "\x03"}, []string{"\x00\x00\x00\x02", "\x03", "\x01\x00"}},
+		{"go", byLen, []string{"bb", "a", "ccc", "c"}, []string{"a", "c", "bb", "ccc"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			env := setup(t)
+			defer clean(env, t)
+
+			var dbi DBI
+			err := env.Update(func(txn *Txn) (err error) {
+				dbi, err = txn.OpenDBI("cmp", Create)
+				if err != nil {
+					return err
+				}
+				err = txn.SetCompare(dbi, test.cmp)
+				if err != nil {
+					return err
+				}
+				for _, k := range test.keys {
+					err = txn.Put(dbi, []byte(k), []byte(k), 0)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var keys []string
+			err = env.View(func(txn *Txn) (err error) {
+				cur, err := txn.OpenCursor(dbi)
+				if err != nil {
+					return err
+				}
+				defer cur.Close()
+				for {
+					k, _, err := cur.Get(nil, nil, Next)
+					if IsNotFound(err) {
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+					keys = append(keys, string(k))
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, test.expect) {
+				t.Errorf("unexpected order: %q (!= %q)", keys, test.expect)
+			}
+		})
+	}
+}
+
+func TestTxn_SetDupCompare(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var vals []string
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("dupcmp", Create|DupSort)
+		if err != nil {
+			return err
+		}
+		if err := txn.SetCompare(dbi, nil); err != errCompareNil {
+			t.Errorf("SetCompare: unexpected error: %v", err)
+		}
+		if err := txn.SetDupCompare(dbi, nil); err != errCompareNil {
+			t.Errorf("SetDupCompare: unexpected error: %v", err)
+		}
+		err = txn.SetDupCompare(dbi, CompareDescending)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			err = txn.Put(dbi, []byte("k"), []byte(fmt.Sprint(i)), 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for {
+			_, v, err := cur.Get(nil, nil, Next)
+			if IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			vals = append(vals, string(v))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vals, []string{"2", "1", "0"}) {
+		t.Errorf("unexpected order: %q", vals)
+	}
+}
+
+func TestTxn_Cmp(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("cmp", Create|DupSort|ReverseDup)
+		if err != nil {
+			return err
+		}
+		if txn.Cmp(dbi, []byte("a"), []byte("b")) >= 0 {
+			t.Errorf("Cmp: a >= b")
+		}
+		if txn.Cmp(dbi, []byte("a"), []byte("a")) != 0 {
+			t.Errorf("Cmp: a != a")
+		}
+
+		// ReverseDup compares duplicates starting from their last byte.
+		if txn.DCmp(dbi, []byte("ab"), []byte("ba")) <= 0 {
+			t.Errorf("DCmp: ab <= ba")
+		}
+
+		rev, err := txn.OpenDBI("rev", Create)
+		if err != nil {
+			return err
+		}
+		err = txn.SetCompare(rev, CompareDescending)
+		if err != nil {
+			return err
+		}
+		if txn.Cmp(rev, []byte("a"), []byte("b")) <= 0 {
+			t.Errorf("Cmp: a <= b with CompareDescending")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,179 @@
+package lmdb
+
+/*
+#include <stdlib.h>
+#include "lmdb.h"
+#include "lmdbgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// ErrEnvBusy is returned by Env.ShrinkInPlace when the environment is in use
+// by transactions of the process or by other processes.
+var ErrEnvBusy = errors.New("lmdb: environment is in use")
+
+// Compact writes a compacted copy of env to path, omitting free pages and
+// renumbering the remaining ones.  The copy is usually smaller than the data
+// file of env, which never shrinks.  Like Copy, path is a directory unless
+// env has the NoSubdir flag.
+//
+// See mdb_env_copy2 and MDB_CP_COMPACT.
+func (env *Env) Compact(path string) error {
+	return env.CopyFlag(path, CopyCompact)
+}
+
+// ShrinkInPlace compacts the data file of env and reopens env on the
+// compacted file, which reclaims the disk space held by free pages.  If
+// mapSize is positive the environment is reopened with that map size (LMDB
+// increases it if the data does not fit), otherwise the map size is kept.
+//
+// The compacted copy is written next to the data file and renamed over it,
+// so a crash leaves either the old or the new file in place.  Write
+// transactions are blocked (see Env.Freeze) and read-only transactions wait
+// while the copy is made and env is reopened.  A freeze in effect before the
+// call is kept.  ShrinkInPlace returns ErrEnvBusy if other processes hold
+// reader slots or transactions of the process are active, including
+// read-only transactions which were reset and a write transaction of the
+// calling goroutine.  Other processes must not have env open at all, which
+// cannot be detected reliably.  Use lmdbsync.Env.ShrinkInPlace to wait for
+// the transactions of the process to terminate.
+//
+// Handles of databases opened before ShrinkInPlace must not be used
+// afterwards and must be opened again.  Handles cached by Env.DB are
+// released.  On failure after the environment was closed env is left closed.
+func (env *Env) ShrinkInPlace(mapSize int64) error {
+	flags, err := env.Flags()
+	if err != nil {
+		return err
+	}
+	if flags&Readonly != 0 {
+		return fmt.Errorf("lmdb: cannot shrink a read-only environment")
+	}
+	path, err := env.Path()
+	if err != nil {
+		return err
+	}
+
+	// Write transactions are blocked and new read-only transactions wait
+	// on closeLock until env is reopened.
+	restore, ok := env.freeze.hold(FreezeBlock)
+	if !ok {
+		return ErrEnvBusy
+	}
+	defer restore()
+	env.closeLock.Lock()
+	defer env.closeLock.Unlock()
+
+	readers, err := env.Readers()
+	if err != nil {
+		return err
+	}
+	if len(readers) > 0 {
+		return ErrEnvBusy
+	}
+
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	if mapSize <= 0 {
+		mapSize = info.MapSize
+	}
+	maxReaders, err := env.MaxReaders()
+	if err != nil {
+		return err
+	}
+
+	data := dataFile(path, flags)
+	fi, err := os.Stat(data)
+	if err != nil {
+		return err
+	}
+	tmp := data + ".compact"
+	err = env.compactFile(tmp, fi.Mode())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return env.reopen(path, flags, fi.Mode(), tmp, data, mapSize, maxReaders)
+}
+
+// compactFile writes a compacted copy of env to the file at path and syncs
+// it.
+func (env *Env) compactFile(path string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	err = env.CopyFDFlag(f.Fd(), CopyCompact)
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// reopen closes the C environment of env, renames the file at tmp to data
+// and opens a new C environment with the given configuration in its place.
+// The caller must hold env.closeLock.
+func (env *Env) reopen(path string, flags uint, mode os.FileMode, tmp, data string, mapSize int64, maxReaders int) error {
+	err := env.unmap()
+	if err != nil {
+		return err
+	}
+
+	env.releaseAssert()
+	C.mdb_env_close(env._env)
+	env._env = nil
+	env.dbis.reset()
+
+	err = os.Rename(tmp, data)
+	if err != nil {
+		os.Remove(tmp)
+		// fall through to reopen the uncompacted file.
+	}
+
+	ret := C.mdb_env_create(&env._env)
+	if ret != success {
+		env._env = nil
+		return operrno("mdb_env_create", ret)
+	}
+	ret = C.mdb_env_set_mapsize(env._env, C.size_t(mapSize))
+	if ret == success {
+		ret = C.mdb_env_set_maxreaders(env._env, C.uint(maxReaders))
+	}
+	if ret == success && env.maxDBs > 0 {
+		ret = C.mdb_env_set_maxdbs(env._env, C.MDB_dbi(env.maxDBs))
+	}
+	if ret == success && env.userctx != 0 {
+		ret = C.lmdbgo_mdb_env_set_userctx(env._env, C.size_t(env.userctx))
+	}
+	if ret == success && env.assert != nil {
+		ret = C.lmdbgo_mdb_env_set_assert(env._env, 1)
+		if ret == success {
+			assertEnvsLock.Lock()
+			assertEnvs[env._env] = env
+			assertEnvsLock.Unlock()
+		}
+	}
+	if ret == success {
+		cpath := C.CString(path)
+		defer C.free(unsafe.Pointer(cpath))
+		ret = C.mdb_env_open(env._env, cpath, C.uint(NoTLS|flags), C.mdb_mode_t(mode))
+	}
+	if ret != success {
+		C.mdb_env_close(env._env)
+		env._env = nil
+		return operrno("mdb_env_open", ret)
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package lmdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnv_ShrinkInPlace(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := env.DB("db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for i := 0; i < 300; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprintf("%04d", i)), make([]byte, 1000), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for i := 10; i < 300; i++ {
+			err = txn.Del(dbi, []byte(fmt.Sprintf("%04d", i)), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(filepath.Join(path, "data.mdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an active transaction prevents shrinking.
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.ShrinkInPlace(0)
+	if err != ErrEnvBusy {
+		t.Errorf("unexpected error: %v", err)
+	}
+	txn.Abort()
+	err = env.Update(func(txn *Txn) (err error) {
+		return env.ShrinkInPlace(0)
+	})
+	if err != ErrEnvBusy {
+		t.Errorf("unexpected error in write transaction: %v", err)
+	}
+
+	// a freeze in effect is kept.
+	env.Freeze(FreezeFail)
+	err = env.ShrinkInPlace(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.Frozen() {
+		t.Errorf("environment thawed")
+	}
+	err = env.Update(func(txn *Txn) (err error) { return nil })
+	if err != ErrFrozen {
+		t.Errorf("unexpected error: %v", err)
+	}
+	env.Thaw()
+	after, err := os.Stat(filepath.Join(path, "data.mdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("data file did not shrink: %d >= %d", after.Size(), before.Size())
+	}
+
+	dbi, err = env.DB("db", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) (err error) {
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != 10 {
+			t.Errorf("unexpected entries: %d", stat.Entries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package lmdb
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// copyChunk is the size of the chunks in which CopyTo reads the copy.
+const copyChunk = 1 << 20
+
+// CopyOptions configure Env.CopyTo and Env.CopyWith.
+type CopyOptions struct {
+	// Flags are passed to mdb_env_copyfd2, see CopyCompact.
+	Flags uint
+
+	// Rate limits the copy to Rate bytes per second on average, so that a
+	// hot backup of a large environment leaves disk bandwidth to the live
+	// workload.  If zero the copy is not limited.
+	Rate int64
+
+	// Progress is called after every chunk written with the totals so far.
+	Progress func(CopyProgress)
+}
+
+// CopyProgress reports the progress of a copy.
+type CopyProgress struct {
+	Bytes   int64         // Bytes written
+	Pages   int64         // Pages written, of the page size of the environment
+	Elapsed time.Duration // Time since the copy started
+}
+
+// CopyTo writes a copy of env to w, like CopyFDFlag, and returns the number
+// of bytes written.  The copy is made through a pipe so that it can be
+// throttled and its progress reported.  If writing to w fails the copy made
+// by LMDB still runs to completion before CopyTo returns the error, as
+// interrupting it could raise SIGPIPE in a thread of the C library.
+//
+// See mdb_env_copyfd2.
+func (env *Env) CopyTo(w io.Writer, opt *CopyOptions) (int64, error) {
+	if opt == nil {
+		opt = &CopyOptions{}
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		return 0, err
+	}
+	psize := int64(stat.PSize)
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer pr.Close()
+	copyErr := make(chan error, 1)
+	go func() {
+		err := env.CopyFDFlag(pw.Fd(), opt.Flags)
+		pw.Close()
+		copyErr <- err
+	}()
+
+	start := time.Now()
+	buf := make([]byte, copyChunk)
+	var n int64
+	var werr error
+	for {
+		m, rerr := pr.Read(buf)
+		if m > 0 && werr == nil {
+			_, werr = w.Write(buf[:m])
+			n += int64(m)
+			if werr == nil {
+				throttle(start, n, opt.Rate)
+				if opt.Progress != nil {
+					opt.Progress(CopyProgress{
+						Bytes:   n,
+						Pages:   n / psize,
+						Elapsed: time.Since(start),
+					})
+				}
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			if werr == nil {
+				werr = rerr
+			}
+			// Drain the pipe so that the copy can complete.
+			io.Copy(io.Discard, pr)
+			break
+		}
+	}
+	err = <-copyErr
+	if err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// throttle sleeps until n bytes are due at rate bytes per second since start.
+func throttle(start time.Time, n, rate int64) {
+	if rate <= 0 {
+		return
+	}
+	due := time.Duration(float64(n) / float64(rate) * float64(time.Second))
+	if d := due - time.Since(start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// CopyWith copies env to an environment at path, like CopyFlag, with the
+// throttling and progress reporting of CopyTo.  Unless env has the NoSubdir
+// flag path is an existing directory in which the data file is created.  The
+// file must not exist.
+func (env *Env) CopyWith(path string, opt *CopyOptions) error {
+	flags, err := env.Flags()
+	if err != nil {
+		return err
+	}
+	if flags&NoSubdir == 0 {
+		path = filepath.Join(path, "data.mdb")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = env.CopyTo(f, opt)
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
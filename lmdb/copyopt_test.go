@@ -0,0 +1,122 @@
+package lmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnv_CopyWith(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) error {
+		for i := 0; i < 100; i++ {
+			err := txn.Put(dbi, []byte(fmt.Sprintf("k%03d", i)), make([]byte, 1000), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := env.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, flags := range []uint{0, CopyCompact} {
+		dir, err := ioutil.TempDir("", "test-env-copywith-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		var last CopyProgress
+		const rate = 1 << 20
+		start := time.Now()
+		err = env.CopyWith(dir, &CopyOptions{
+			Flags:    flags,
+			Rate:     rate,
+			Progress: func(p CopyProgress) { last = p },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		fi, err := os.Stat(dir + "/data.mdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.Bytes != fi.Size() || last.Pages != fi.Size()/int64(stat.PSize) {
+			t.Errorf("flags %#x: progress %+v, size %d", flags, last, fi.Size())
+		}
+		if min := time.Duration(fi.Size()) * time.Second / rate; elapsed < min*9/10 {
+			t.Errorf("flags %#x: copy of %d bytes took %v", flags, fi.Size(), elapsed)
+		}
+
+		cp, err := NewEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cp.Open(dir, 0, 0644)
+		if err == nil {
+			err = cp.View(func(txn *Txn) error {
+				stat, err := txn.Stat(dbi)
+				if err == nil && stat.Entries != 100 {
+					err = fmt.Errorf("%d entries", stat.Entries)
+				}
+				return err
+			})
+		}
+		cp.Close()
+		if err != nil {
+			t.Errorf("flags %#x: %v", flags, err)
+		}
+
+		err = env.CopyWith(dir, nil)
+		if !os.IsExist(err) {
+			t.Errorf("flags %#x: copy to existing file: %v", flags, err)
+		}
+	}
+}
+
+type failWriter struct{ n int }
+
+var errWrite = errors.New("write failed")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errWrite
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestEnv_CopyTo_error(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	for _, flags := range []uint{0, CopyCompact} {
+		_, err := env.CopyTo(&failWriter{}, &CopyOptions{Flags: flags})
+		if err != errWrite {
+			t.Errorf("flags %#x: %v", flags, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := env.CopyTo(&buf, nil)
+	if err != nil || n != int64(buf.Len()) || n == 0 {
+		t.Errorf("copy: %d %v (%d bytes)", n, err, buf.Len())
+	}
+}
@@ -0,0 +1,118 @@
+package lmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// ErrCounterRange is returned by Txn.Increment when the result would be
+// negative or overflow a uint64.
+var ErrCounterRange = errors.New("lmdb: counter out of range")
+
+// Increment adds delta to the counter stored under key in dbi and returns the
+// new value.  Counters are stored as 8 byte big-endian unsigned integers, so
+// that counters stored as keys sort numerically in a database without the
+// IntegerKey flag.  A missing counter is treated as zero.  Increment returns
+// ErrCounterRange, and leaves the counter unchanged, if the result would be
+// out of range.
+func (txn *Txn) Increment(dbi DBI, key []byte, delta int64) (uint64, error) {
+	n, err := txn.Counter(dbi, key)
+	if err != nil {
+		return 0, err
+	}
+	if delta >= 0 {
+		if n > math.MaxUint64-uint64(delta) {
+			return 0, ErrCounterRange
+		}
+		n += uint64(delta)
+	} else {
+		// -(delta+1) does not overflow for math.MinInt64.
+		abs := uint64(-(delta + 1)) + 1
+		if n < abs {
+			return 0, ErrCounterRange
+		}
+		n -= abs
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, n)
+	return n, txn.Put(dbi, key, v, 0)
+}
+
+// Counter returns the value of the counter stored under key in dbi, or zero if
+// there is none.  See Increment.
+func (txn *Txn) Counter(dbi DBI, key []byte) (uint64, error) {
+	v, err := txn.Get(dbi, key)
+	if IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, fmt.Errorf("lmdb: counter value has %d bytes, expected 8", len(v))
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// NativeEndian is the byte order of the host, which LMDB uses to compare the
+// keys of IntegerKey databases and the values of IntegerDup databases.
+var NativeEndian binary.ByteOrder = nativeEndian()
+
+func nativeEndian() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// sizeofSizeT is the size of a C size_t, one of the two integer sizes LMDB
+// accepts for IntegerKey and IntegerDup.
+const sizeofSizeT = unsafe.Sizeof(uintptr(0))
+
+// EncodeUint returns v as a native size_t, suitable as a key of an IntegerKey
+// database or a value of an IntegerDup database.  EncodeUint panics if v does
+// not fit in a size_t.
+func EncodeUint(v uint64) []byte {
+	b := make([]byte, sizeofSizeT)
+	if sizeofSizeT == 4 {
+		if v > math.MaxUint32 {
+			panic("lmdb: value overflows size_t")
+		}
+		NativeEndian.PutUint32(b, uint32(v))
+	} else {
+		NativeEndian.PutUint64(b, v)
+	}
+	return b
+}
+
+// DecodeUint decodes a native size_t encoded by EncodeUint.
+func DecodeUint(b []byte) (uint64, error) {
+	if uintptr(len(b)) != sizeofSizeT {
+		return 0, fmt.Errorf("lmdb: integer has %d bytes, expected %d", len(b), sizeofSizeT)
+	}
+	if sizeofSizeT == 4 {
+		return uint64(NativeEndian.Uint32(b)), nil
+	}
+	return NativeEndian.Uint64(b), nil
+}
+
+// EncodeUint32 returns v as a native unsigned int, the other integer size
+// LMDB accepts for IntegerKey and IntegerDup.  All keys of a database must
+// have the same size.
+func EncodeUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	NativeEndian.PutUint32(b, v)
+	return b
+}
+
+// DecodeUint32 decodes a native unsigned int encoded by EncodeUint32.
+func DecodeUint32(b []byte) (uint32, error) {
+	if len(b) != 4 {
+		return 0, fmt.Errorf("lmdb: integer has %d bytes, expected 4", len(b))
+	}
+	return NativeEndian.Uint32(b), nil
+}
@@ -0,0 +1,137 @@
+package lmdb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTxn_Increment(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		k := []byte("seq")
+		for i := uint64(1); i <= 3; i++ {
+			n, err := txn.Increment(dbi, k, 1)
+			if err != nil {
+				return err
+			}
+			if n != i {
+				t.Errorf("unexpected value: %d (!= %d)", n, i)
+			}
+		}
+		n, err := txn.Increment(dbi, k, -3)
+		if err != nil {
+			return err
+		}
+		if n != 0 {
+			t.Errorf("unexpected value: %d", n)
+		}
+		_, err = txn.Increment(dbi, k, -1)
+		if err != ErrCounterRange {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, err = txn.Increment(dbi, k, math.MinInt64)
+		if err != ErrCounterRange {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, err = txn.Increment(dbi, k, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Increment(dbi, k, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Increment(dbi, k, 2)
+		if err != ErrCounterRange {
+			t.Errorf("unexpected error: %v", err)
+		}
+		n, err = txn.Counter(dbi, k)
+		if err != nil {
+			return err
+		}
+		if n != math.MaxUint64-1 {
+			t.Errorf("unexpected value: %d", n)
+		}
+
+		err = txn.Put(dbi, []byte("bad"), []byte("x"), 0)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Increment(dbi, []byte("bad"), 1)
+		if err == nil {
+			t.Errorf("expected error for malformed counter")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeUint(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("int", Create|IntegerKey)
+		if err != nil {
+			return err
+		}
+		// native order differs from lexicographic order on little-endian
+		// hosts.
+		for _, v := range []uint64{256, 1, 65536, 2} {
+			err = txn.Put(dbi, EncodeUint(v), nil, 0)
+			if err != nil {
+				return err
+			}
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		var keys []uint64
+		for {
+			k, _, err := cur.Get(nil, nil, Next)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			v, err := DecodeUint(k)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, v)
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1] >= keys[i] {
+				t.Errorf("keys out of order: %v", keys)
+			}
+		}
+		if len(keys) != 4 {
+			t.Errorf("unexpected keys: %v", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := DecodeUint32(EncodeUint32(42))
+	if err != nil || v != 42 {
+		t.Errorf("unexpected result: %d %v", v, err)
+	}
+	_, err = DecodeUint([]byte{1})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -61,10 +61,14 @@ const (
 type Cursor struct {
 	txn *Txn
 	_c  *C.MDB_cursor
+	dbi DBI
+
+	// page is reused by PutMultiSlices to assemble values.
+	page []byte
 }
 
 func openCursor(txn *Txn, db DBI) (*Cursor, error) {
-	c := &Cursor{txn: txn}
+	c := &Cursor{txn: txn, dbi: db}
 	ret := C.mdb_cursor_open(txn._txn, C.MDB_dbi(db), &c._c)
 	if ret != success {
 		return nil, operrno("mdb_cursor_open", ret)
@@ -128,7 +132,7 @@ func (c *Cursor) DBI() DBI {
 	if c._c == nil {
 		return dbiInvalid
 	}
-	return DBI(C.mdb_cursor_dbi(c._c))
+	return c.dbi
 }
 
 // Get retrieves items from the database. If c.Txn().RawRead is true the slices
@@ -144,6 +148,9 @@ func (c *Cursor) DBI() DBI {
 //
 // See mdb_cursor_get.
 func (c *Cursor) Get(setkey, setval []byte, op uint) (key, val []byte, err error) {
+	if s := c.stats(); s != nil {
+		s.CursorOps++
+	}
 	switch {
 	case len(setkey) == 0:
 		err = c.getVal0(op)
@@ -183,13 +190,83 @@ func (c *Cursor) Get(setkey, setval []byte, op uint) (key, val []byte, err error
 	return key, val, nil
 }
 
+// GetCopy is like Get but always returns newly allocated copies of the key and
+// value, regardless of the RawRead and Arena settings of c.Txn().
+func (c *Cursor) GetCopy(setkey, setval []byte, op uint) (key, val []byte, err error) {
+	txn := c.txn
+	raw, arena := txn.RawRead, txn.Arena
+	txn.RawRead, txn.Arena = false, nil
+	key, val, err = c.Get(setkey, setval, op)
+	txn.RawRead, txn.Arena = raw, arena
+	return key, val, err
+}
+
+// GetRaw is like Get with c.Txn().RawRead set.  The returned slices reference
+// readonly sections of memory that must not be accessed after the transaction
+// has terminated.
+func (c *Cursor) GetRaw(setkey, setval []byte, op uint) (key, val []byte, err error) {
+	txn := c.txn
+	raw := txn.RawRead
+	txn.RawRead = true
+	key, val, err = c.Get(setkey, setval, op)
+	txn.RawRead = raw
+	return key, val, err
+}
+
+// GetBatch retrieves up to n successive items from the database, moving the
+// cursor as if Get(nil, nil, Next) were called repeatedly.  All items are
+// read within a single call into the C library which avoids paying the cgo
+// overhead for each item.  The slices in keys and vals follow the same rules
+// regarding c.Txn().RawRead as slices returned by Get.
+//
+// GetBatch returns fewer than n items when the end of the database is reached.
+// A NotFound error is only returned when no items could be retrieved.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetBatch(n int) (keys, vals [][]byte, err error) {
+	if n <= 0 {
+		return nil, nil, nil
+	}
+	if s := c.stats(); s != nil {
+		s.CursorOps++
+	}
+
+	buf := C.malloc(C.size_t(2*n) * C.sizeof_MDB_val)
+	defer C.free(buf)
+	ckeys := unsafe.Slice((*C.MDB_val)(buf), n)
+	cvals := unsafe.Slice((*C.MDB_val)(unsafe.Pointer(
+		uintptr(buf)+uintptr(n)*uintptr(C.sizeof_MDB_val),
+	)), n)
+
+	var count C.size_t
+	ret := C.lmdbgo_mdb_cursor_getbatch(c._c, &ckeys[0], &cvals[0], C.size_t(n), C.MDB_cursor_op(Next), &count)
+	err = c.errno("mdb_cursor_get", ret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys = make([][]byte, count)
+	vals = make([][]byte, count)
+	for i := range keys {
+		keys[i] = c.txn.bytes(&ckeys[i])
+		vals[i] = c.txn.bytes(&cvals[i])
+	}
+	return keys, vals, nil
+}
+
 // getVal0 retrieves items from the database without using given key or value
 // data for reference (Next, First, Last, etc).
 //
+// The key and value of the transaction are cleared first so that an op which
+// takes a key, such as Set, is passed an empty key rather than what the
+// buffers hold from an earlier call.
+//
 // See mdb_cursor_get.
 func (c *Cursor) getVal0(op uint) error {
+	*c.txn.key = C.MDB_val{}
+	*c.txn.val = C.MDB_val{}
 	ret := C.mdb_cursor_get(c._c, c.txn.key, c.txn.val, C.MDB_cursor_op(op))
-	return operrno("mdb_cursor_get", ret)
+	return c.errno("mdb_cursor_get", ret)
 }
 
 // getVal1 retrieves items from the database using key data for reference
@@ -197,13 +274,16 @@ func (c *Cursor) getVal0(op uint) error {
 //
 // See mdb_cursor_get.
 func (c *Cursor) getVal1(setkey []byte, op uint) error {
+	if err := c.checkKey("mdb_cursor_get", setkey); err != nil {
+		return err
+	}
 	ret := C.lmdbgo_mdb_cursor_get1(
 		c._c,
 		(*C.char)(unsafe.Pointer(&setkey[0])), C.size_t(len(setkey)),
 		c.txn.key, c.txn.val,
 		C.MDB_cursor_op(op),
 	)
-	return operrno("mdb_cursor_get", ret)
+	return c.errno("mdb_cursor_get", ret)
 }
 
 // getVal2 retrieves items from the database using key and value data for
@@ -211,6 +291,9 @@ func (c *Cursor) getVal1(setkey []byte, op uint) error {
 //
 // See mdb_cursor_get.
 func (c *Cursor) getVal2(setkey, setval []byte, op uint) error {
+	if err := c.checkKey("mdb_cursor_get", setkey); err != nil {
+		return err
+	}
 	ret := C.lmdbgo_mdb_cursor_get2(
 		c._c,
 		(*C.char)(unsafe.Pointer(&setkey[0])), C.size_t(len(setkey)),
@@ -218,18 +301,31 @@ func (c *Cursor) getVal2(setkey, setval []byte, op uint) error {
 		c.txn.key, c.txn.val,
 		C.MDB_cursor_op(op),
 	)
-	return operrno("mdb_cursor_get", ret)
+	return c.errno("mdb_cursor_get", ret)
 }
 
 func (c *Cursor) putNilKey(flags uint) error {
 	ret := C.lmdbgo_mdb_cursor_put2(c._c, nil, 0, nil, 0, C.uint(flags))
-	return operrno("mdb_cursor_put", ret)
+	return c.errno("mdb_cursor_put", ret)
 }
 
 // Put stores an item in the database.
 //
 // See mdb_cursor_put.
 func (c *Cursor) Put(key, val []byte, flags uint) error {
+	if err := c.checkKey("mdb_cursor_put", key); err != nil {
+		return err
+	}
+	if err := checkVal("mdb_cursor_put", len(val)); err != nil {
+		return err
+	}
+	if c.txn != nil {
+		c.txn.countPut(c.DBI(), 1, uint64(len(key)+len(val)))
+	}
+	c.invalidateVals()
+	if in := c.intent(); in != nil {
+		in.put(c.DBI(), key)
+	}
 	kn := len(key)
 	if kn == 0 {
 		return c.putNilKey(flags)
@@ -244,13 +340,29 @@ func (c *Cursor) Put(key, val []byte, flags uint) error {
 		(*C.char)(unsafe.Pointer(&val[0])), C.size_t(vn),
 		C.uint(flags),
 	)
-	return operrno("mdb_cursor_put", ret)
+	if ret == success && c.dryRun() != nil {
+		c.txn.dry.record("put", c.DBI(), key, vn)
+	}
+	return c.errno("mdb_cursor_put", ret)
 }
 
 // PutReserve returns a []byte of length n that can be written to, potentially
 // avoiding a memcopy.  The returned byte slice is only valid in txn's thread,
 // before it has terminated.
 func (c *Cursor) PutReserve(key []byte, n int, flags uint) ([]byte, error) {
+	if err := c.checkKey("mdb_cursor_put", key); err != nil {
+		return nil, err
+	}
+	if err := checkVal("mdb_cursor_put", n); err != nil {
+		return nil, err
+	}
+	if c.txn != nil {
+		c.txn.countPut(c.DBI(), 1, uint64(len(key)+n))
+	}
+	c.invalidateVals()
+	if in := c.intent(); in != nil {
+		in.put(c.DBI(), key)
+	}
 	if len(key) == 0 {
 		return nil, c.putNilKey(flags)
 	}
@@ -262,11 +374,14 @@ func (c *Cursor) PutReserve(key []byte, n int, flags uint) ([]byte, error) {
 		c.txn.val,
 		C.uint(flags|C.MDB_RESERVE),
 	)
-	err := operrno("mdb_cursor_put", ret)
+	err := c.errno("mdb_cursor_put", ret)
 	if err != nil {
 		*c.txn.val = C.MDB_val{}
 		return nil, err
 	}
+	if c.dryRun() != nil {
+		c.txn.dry.record("put", c.DBI(), key, n)
+	}
 	b := getBytes(c.txn.val)
 	*c.txn.val = C.MDB_val{}
 	return b, nil
@@ -274,10 +389,21 @@ func (c *Cursor) PutReserve(key []byte, n int, flags uint) ([]byte, error) {
 
 // PutMulti stores a set of contiguous items with stride size under key.
 // PutMulti panics if len(page) is not a multiple of stride.  The cursor's
-// database must be DupFixed and DupSort.
+// database must be DupFixed and DupSort.  If key has a single value equal to
+// the first item LMDB stores none of the other items.
 //
 // See mdb_cursor_put.
 func (c *Cursor) PutMulti(key []byte, page []byte, stride int, flags uint) error {
+	if err := c.checkKey("mdb_cursor_put", key); err != nil {
+		return err
+	}
+	if c.txn != nil {
+		c.txn.countPut(c.DBI(), 1, uint64(len(key)+len(page)))
+	}
+	c.invalidateVals()
+	if in := c.intent(); in != nil {
+		in.put(c.DBI(), key)
+	}
 	if len(key) == 0 {
 		return c.putNilKey(flags)
 	}
@@ -292,25 +418,68 @@ func (c *Cursor) PutMulti(key []byte, page []byte, stride int, flags uint) error
 		(*C.char)(unsafe.Pointer(&page[0])), C.size_t(vn), C.size_t(stride),
 		C.uint(flags|C.MDB_MULTIPLE),
 	)
-	return operrno("mdb_cursor_put", ret)
+	if ret == success && c.dryRun() != nil {
+		c.txn.dry.record("put", c.DBI(), key, len(page))
+	}
+	return c.errno("mdb_cursor_put", ret)
+}
+
+// PutMultiSlices stores vals under key like PutMulti, without requiring the
+// values to be contiguous in memory.  The values are copied into a page
+// buffer held by the cursor, which is reused by later calls, so repeated
+// writes do not allocate.  PutMultiSlices panics if the values do not all
+// have the same non-zero length, and does nothing if vals is empty.  The
+// cursor's database must be DupFixed and DupSort.
+//
+// See mdb_cursor_put.
+func (c *Cursor) PutMultiSlices(key []byte, vals [][]byte, flags uint) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	stride := len(vals[0])
+	c.page = c.page[:0]
+	for _, v := range vals {
+		if len(v) != stride || stride == 0 {
+			panic("lmdb: PutMultiSlices values must have the same non-zero length")
+		}
+		c.page = append(c.page, v...)
+	}
+	return c.PutMulti(key, c.page, stride, flags)
 }
 
 // Del deletes the item referred to by the cursor from the database.
 //
 // See mdb_cursor_del.
 func (c *Cursor) Del(flags uint) error {
+	if c.txn != nil {
+		c.txn.countDel(c.DBI())
+	}
+	c.invalidateVals()
+	if in := c.intent(); in != nil {
+		in.del(c.DBI(), nil)
+	}
+	var key []byte
+	if c.dryRun() != nil {
+		key = c.dryKey()
+	}
 	ret := C.mdb_cursor_del(c._c, C.uint(flags))
-	return operrno("mdb_cursor_del", ret)
+	if ret == success && c.dryRun() != nil {
+		c.txn.dry.record("del", c.DBI(), key, 0)
+	}
+	return c.errno("mdb_cursor_del", ret)
 }
 
 // Count returns the number of duplicates for the current key.
 //
 // See mdb_cursor_count.
 func (c *Cursor) Count() (uint64, error) {
+	if s := c.stats(); s != nil {
+		s.CursorOps++
+	}
 	var _size C.size_t
 	ret := C.mdb_cursor_count(c._c, &_size)
 	if ret != success {
-		return 0, operrno("mdb_cursor_count", ret)
+		return 0, c.errno("mdb_cursor_count", ret)
 	}
 	return uint64(_size), nil
 }
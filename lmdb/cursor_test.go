@@ -261,6 +261,72 @@ func TestCursor_Get_KV(t *testing.T) {
 	}
 }
 
+func TestCursor_GetBatch(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			k := fmt.Sprintf("k%d", i)
+			v := fmt.Sprintf("v%d", i)
+			err = txn.Put(dbi, []byte(k), []byte(v), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, raw := range []bool{false, true} {
+		err = env.View(func(txn *Txn) (err error) {
+			txn.RawRead = raw
+
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+
+			var batches []int
+			var i int
+			for {
+				keys, vals, err := cur.GetBatch(4)
+				if IsNotFound(err) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				batches = append(batches, len(keys))
+				for j := range keys {
+					if string(keys[j]) != fmt.Sprintf("k%d", i) {
+						t.Errorf("unexpected key: %q", keys[j])
+					}
+					if string(vals[j]) != fmt.Sprintf("v%d", i) {
+						t.Errorf("unexpected value: %q", vals[j])
+					}
+					i++
+				}
+			}
+			if !reflect.DeepEqual(batches, []int{4, 4, 2}) {
+				t.Errorf("unexpected batch sizes: %v", batches)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestCursor_Get_op_Set_bytesBuffer(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -337,6 +403,46 @@ func TestCursor_Get_op_Set_bytesBuffer(t *testing.T) {
 	}
 }
 
+// TestCursor_Get_op_Set_emptyKey checks that an empty key is passed to LMDB
+// for a Set op, not what the key buffer of the transaction holds, which is
+// uninitialized in read-only transactions.
+func TestCursor_Get_op_Set_emptyKey(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		_, _, err = cur.Get(nil, nil, Set)
+		return err
+	}
+	for i := 0; i < 100; i++ {
+		err = env.View(get)
+		if !IsErrno(err, BadValSize) {
+			t.Fatalf("view: %v", err)
+		}
+		err = env.Update(get)
+		if !IsErrno(err, BadValSize) {
+			t.Fatalf("update: %v", err)
+		}
+	}
+}
+
 func TestCursor_Get_DupFixed(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -973,3 +1079,65 @@ func BenchmarkCursor_Renew(b *testing.B) {
 		return nil
 	})
 }
+
+func TestCursor_PutMultiSlices(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	vals := [][]byte{
+		[]byte("v3"),
+		[]byte("v0"),
+		[]byte("v2"),
+		[]byte("v1"),
+	}
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenRoot(Create | DupSort | DupFixed)
+		if err != nil {
+			return err
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		err = cur.PutMultiSlices([]byte("k1"), vals[:2], 0)
+		if err != nil {
+			return err
+		}
+		return cur.PutMultiSlices([]byte("k2"), vals, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"k1=v0", "k1=v3", "k2=v0", "k2=v1", "k2=v2", "k2=v3"}
+	var items []string
+	err = env.View(func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		for {
+			k, v, err := cur.Get(nil, nil, Next)
+			if IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			items = append(items, string(k)+"="+string(v))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, expect) {
+		t.Errorf("unexpected items: %q (!= %q)", items, expect)
+	}
+}
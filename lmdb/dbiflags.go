@@ -0,0 +1,92 @@
+package lmdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// persistentFlags are the database flags that LMDB stores with a database.
+// They are fixed when the database is created.
+const persistentFlags = ReverseKey | DupSort | IntegerKey | DupFixed | IntegerDup | ReverseDup
+
+// DBFlags is a set of persistent database flags.
+type DBFlags uint
+
+var dbFlagNames = []struct {
+	flag DBFlags
+	name string
+}{
+	{ReverseKey, "ReverseKey"},
+	{DupSort, "DupSort"},
+	{IntegerKey, "IntegerKey"},
+	{DupFixed, "DupFixed"},
+	{IntegerDup, "IntegerDup"},
+	{ReverseDup, "ReverseDup"},
+}
+
+// String returns the names of the flags in f joined by "|", or "0" if f is
+// empty.
+func (f DBFlags) String() string {
+	var names []string
+	for _, fn := range dbFlagNames {
+		if f&fn.flag != 0 {
+			names = append(names, fn.name)
+			f &^= fn.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint(f)))
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// DBIFlags returns the persistent flags of the database for handle dbi:
+// ReverseKey, DupSort, IntegerKey, DupFixed, IntegerDup and ReverseDup.
+// DBIFlags is Flags with a result that formats itself.
+func (txn *Txn) DBIFlags(dbi DBI) (DBFlags, error) {
+	flags, err := txn.Flags(dbi)
+	return DBFlags(flags) & persistentFlags, err
+}
+
+// DBIFlagsError is returned by Txn.CheckDBI when a database does not have the
+// expected flags.
+type DBIFlagsError struct {
+	DBI  DBI
+	Want DBFlags
+	Got  DBFlags
+}
+
+func (err *DBIFlagsError) Error() string {
+	msg := fmt.Sprintf("lmdb: database %d has flags %v, expected %v", err.DBI, err.Got, err.Want)
+	var diff []string
+	if missing := err.Want &^ err.Got; missing != 0 {
+		diff = append(diff, "missing "+missing.String())
+	}
+	if extra := err.Got &^ err.Want; extra != 0 {
+		diff = append(diff, "unexpected "+extra.String())
+	}
+	return msg + " (" + strings.Join(diff, ", ") + ")"
+}
+
+// CheckDBI returns a *DBIFlagsError if the persistent flags of the database
+// for handle dbi differ from those in want.  Flags in want that are not
+// stored with a database, such as Create, are ignored, so CheckDBI may be
+// passed the flags given to OpenDBI.
+//
+// LMDB opens an existing database with the flags it was created with, not
+// those passed to OpenDBI.  Code that assumes, for example, that a database
+// has the DupSort flag should check it after opening the handle.
+func (txn *Txn) CheckDBI(dbi DBI, want uint) error {
+	got, err := txn.DBIFlags(dbi)
+	if err != nil {
+		return err
+	}
+	w := DBFlags(want) & persistentFlags
+	if got != w {
+		return &DBIFlagsError{DBI: dbi, Want: w, Got: got}
+	}
+	return nil
+}
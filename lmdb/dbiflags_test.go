@@ -0,0 +1,62 @@
+package lmdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTxn_CheckDBI(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dups DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dups, err = txn.OpenDBI("dups", Create|DupSort|DupFixed)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		// the flags passed when opening an existing database are not used.
+		dbi, err := txn.OpenDBI("dups", 0)
+		if err != nil {
+			return err
+		}
+		flags, err := txn.DBIFlags(dbi)
+		if err != nil {
+			return err
+		}
+		if flags != DupSort|DupFixed {
+			t.Errorf("unexpected flags: %v", flags)
+		}
+		if flags.String() != "DupSort|DupFixed" {
+			t.Errorf("unexpected string: %q", flags.String())
+		}
+
+		err = txn.CheckDBI(dups, Create|DupSort|DupFixed)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.CheckDBI(dups, DupSort|IntegerDup)
+		ferr, ok := err.(*DBIFlagsError)
+		if !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ferr.Got != DupSort|DupFixed || ferr.Want != DupSort|IntegerDup {
+			t.Errorf("unexpected error: %#v", ferr)
+		}
+		if !strings.Contains(err.Error(), "missing IntegerDup, unexpected DupFixed") {
+			t.Errorf("unexpected message: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if DBFlags(0).String() != "0" {
+		t.Errorf("unexpected string: %q", DBFlags(0).String())
+	}
+}
@@ -0,0 +1,88 @@
+package lmdb
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// sizeofDB is the size of the record of a named database in the root
+// database, a C struct MDB_db.
+const sizeofDB = 4 + 2*2 + 5*unsafe.Sizeof(uintptr(0))
+
+// ListDBIs returns the names of the named databases in the environment, in
+// the order of their keys in the root database.
+//
+// Named databases are stored as records in the root database, which may also
+// hold ordinary items.  ListDBIs recognizes a record by its size and confirms
+// it by opening the database, so the environment must allow for as many
+// named databases as it contains (see Env.SetMaxDBs).  Handles opened by
+// ListDBIs remain open like those returned by OpenDBI.
+func (txn *Txn) ListDBIs() ([]string, error) {
+	root, err := txn.OpenRoot(0)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := txn.OpenCursor(root)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	var names []string
+	for {
+		k, v, err := cur.Get(nil, nil, Next)
+		if IsNotFound(err) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if uintptr(len(v)) != sizeofDB || len(k) == 0 || bytes.IndexByte(k, 0) >= 0 {
+			continue
+		}
+		name := string(k)
+		_, err = txn.OpenDBI(name, 0)
+		if IsErrno(err, Incompatible) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+}
+
+// StatAll returns the statistics of the root database, under the empty name,
+// and of every named database in env, read in a single transaction.  The
+// same restrictions apply as for Txn.ListDBIs.
+func (env *Env) StatAll() (map[string]*Stat, error) {
+	stats := make(map[string]*Stat)
+	err := env.View(func(txn *Txn) (err error) {
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		stats[""], err = txn.Stat(root)
+		if err != nil {
+			return err
+		}
+		names, err := txn.ListDBIs()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			dbi, err := txn.OpenDBI(name, 0)
+			if err != nil {
+				return err
+			}
+			stats[name], err = txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,60 @@
+package lmdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxn_ListDBIs(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		a, err := txn.OpenDBI("a", Create)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(a, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		_, err = txn.OpenDBI("b", Create|DupSort)
+		if err != nil {
+			return err
+		}
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		// an ordinary item the size of a database record.
+		return txn.Put(root, []byte("fake"), make([]byte, sizeofDB), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		names, err := txn.ListDBIs()
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(names, []string{"a", "b"}) {
+			t.Errorf("unexpected names: %q", names)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := env.StatAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if stats[""].Entries != 3 || stats["a"].Entries != 1 || stats["b"].Entries != 0 {
+		t.Errorf("unexpected entries: %d %d %d", stats[""].Entries, stats["a"].Entries, stats["b"].Entries)
+	}
+}
@@ -0,0 +1,86 @@
+package lmdb
+
+// DelRange deletes the items in dbi with keys from start, inclusive, up to
+// end, exclusive, and returns the number of items deleted.  A nil start
+// begins at the first key and a nil end continues to the last key.  Keys are
+// compared with the ordering of dbi, so custom comparison functions and the
+// ReverseKey flag are respected.  In a DupSort database all duplicates of
+// each key in the range are deleted and counted.
+func (txn *Txn) DelRange(dbi DBI, start, end []byte) (int, error) {
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		return 0, err
+	}
+	dupsort := flags&DupSort != 0
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close()
+
+	var k []byte
+	if start == nil {
+		k, _, err = cur.Get(nil, nil, First)
+	} else {
+		k, _, err = cur.Get(start, nil, SetRange)
+	}
+	n := 0
+	for ; err == nil; k, _, err = cur.Get(nil, nil, NextNoDup) {
+		if end != nil && txn.Cmp(dbi, k, end) >= 0 {
+			return n, nil
+		}
+		delflags := uint(0)
+		count := uint64(1)
+		if dupsort {
+			count, err = cur.Count()
+			if err != nil {
+				return n, err
+			}
+			delflags = NoDupData
+		}
+		err = cur.Del(delflags)
+		if err != nil {
+			return n, err
+		}
+		n += int(count)
+	}
+	if IsNotFound(err) {
+		return n, nil
+	}
+	return n, err
+}
+
+// DelDupRange deletes the duplicates of key in dbi, which must have the
+// DupSort flag, with values from start, inclusive, up to end, exclusive, and
+// returns the number of items deleted.  A nil start or end leaves the range
+// unbounded on that side.  Values are compared with the duplicate ordering of
+// dbi.
+func (txn *Txn) DelDupRange(dbi DBI, key, start, end []byte) (int, error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close()
+
+	var v []byte
+	if start == nil {
+		_, v, err = cur.Get(key, nil, Set)
+	} else {
+		_, v, err = cur.Get(key, start, GetBothRange)
+	}
+	n := 0
+	for ; err == nil; _, v, err = cur.Get(nil, nil, NextDup) {
+		if end != nil && txn.DCmp(dbi, v, end) >= 0 {
+			return n, nil
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	if IsNotFound(err) {
+		return n, nil
+	}
+	return n, err
+}
@@ -0,0 +1,115 @@
+package lmdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_DelRange(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	keys := func(txn *Txn, dbi DBI) (items []string) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		for {
+			k, v, err := cur.Get(nil, nil, Next)
+			if IsNotFound(err) {
+				return items
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, string(k)+string(v))
+		}
+	}
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("plain", Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprint(i)), nil, 0)
+			if err != nil {
+				return err
+			}
+		}
+		n, err := txn.DelRange(dbi, []byte("2"), []byte("5"))
+		if err != nil {
+			return err
+		}
+		if n != 3 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		n, err = txn.DelRange(dbi, []byte("8"), nil)
+		if err != nil {
+			return err
+		}
+		if n != 2 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		n, err = txn.DelRange(dbi, nil, []byte("1"))
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		if items := keys(txn, dbi); !reflect.DeepEqual(items, []string{"1", "5", "6", "7"}) {
+			t.Errorf("unexpected items: %q", items)
+		}
+
+		dup, err := txn.OpenDBI("dup", Create|DupSort)
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "b", "c", "d"} {
+			for _, v := range []string{"1", "2", "3", "4"} {
+				err = txn.Put(dup, []byte(k), []byte(v), 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		n, err = txn.DelRange(dup, []byte("b"), []byte("d"))
+		if err != nil {
+			return err
+		}
+		if n != 8 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		n, err = txn.DelDupRange(dup, []byte("a"), []byte("2"), []byte("4"))
+		if err != nil {
+			return err
+		}
+		if n != 2 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		n, err = txn.DelDupRange(dup, []byte("d"), nil, nil)
+		if err != nil {
+			return err
+		}
+		if n != 4 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		n, err = txn.DelDupRange(dup, []byte("x"), nil, nil)
+		if err != nil {
+			return err
+		}
+		if n != 0 {
+			t.Errorf("unexpected count: %d", n)
+		}
+		if items := keys(txn, dup); !reflect.DeepEqual(items, []string{"a1", "a4"}) {
+			t.Errorf("unexpected items: %q", items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
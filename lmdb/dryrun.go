@@ -0,0 +1,124 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+)
+
+// DryRunOp is a write operation performed by the function passed to
+// Env.DryRun.
+type DryRunOp struct {
+	Op      string // "put", "del" or "drop"
+	DBI     DBI
+	Key     []byte // nil for "drop"
+	ValSize int    // Size of the value stored by "put"
+}
+
+// DryRunReport describes the writes a transaction would have made.  Writes
+// made in subtransactions that were aborted are not included.
+type DryRunReport struct {
+	Ops      []DryRunOp
+	Puts     int
+	Dels     int
+	Drops    int
+	KeyBytes int64 // Total size of the keys written or deleted
+	ValBytes int64 // Total size of the values written
+}
+
+// dryRun logs the write operations of a transaction run by Env.DryRun.
+// Subtransactions share the log of their parent.
+type dryRun struct {
+	ops []DryRunOp
+}
+
+func (d *dryRun) record(op string, dbi DBI, key []byte, vn int) {
+	if key != nil {
+		key = append([]byte(nil), key...)
+	}
+	d.ops = append(d.ops, DryRunOp{Op: op, DBI: dbi, Key: key, ValSize: vn})
+}
+
+// rollback discards the operations logged after the first n.
+func (d *dryRun) rollback(n int) {
+	d.ops = d.ops[:n]
+}
+
+func (d *dryRun) report() *DryRunReport {
+	r := &DryRunReport{Ops: d.ops}
+	for _, op := range d.ops {
+		switch op.Op {
+		case "put":
+			r.Puts++
+		case "del":
+			r.Dels++
+		case "drop":
+			r.Drops++
+		}
+		r.KeyBytes += int64(len(op.Key))
+		r.ValBytes += int64(op.ValSize)
+	}
+	return r
+}
+
+// DryRun executes fn in a write transaction which is always aborted, and
+// reports the writes fn made.  Reads within fn observe its own writes, so
+// that migrations and administrative operations can be previewed by running
+// them unmodified.  DryRun returns the report along with any error returned
+// by fn, which is useful to preview an operation that fails part way.
+//
+// Because LMDB closes a database handle deleted with Txn.Drop immediately,
+// even if the transaction is aborted, a deleting Drop only empties the
+// database during a dry run.  Hooks registered with Txn.OnAbort still run.
+// DryRun holds the write lock of the environment like Update.
+func (env *Env) DryRun(fn TxnOp) (*DryRunReport, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	txn, err := beginTxn(env, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	dry := new(dryRun)
+	txn.dry = dry
+	err = txn.runOpTerm(func(txn *Txn) error {
+		err := fn(txn)
+		if err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return dry.report(), err
+}
+
+// errDryRun aborts the transaction of a dry run which otherwise succeeded.
+var errDryRun = errors.New("lmdb: dry run")
+
+// dryRun returns the dry run log of the cursor's transaction.  A closed cursor
+// has no transaction.
+func (c *Cursor) dryRun() *dryRun {
+	if c.txn == nil {
+		return nil
+	}
+	return c.txn.dry
+}
+
+// dryKey returns a copy of the key at the cursor's position for the dry run
+// log.
+func (c *Cursor) dryKey() []byte {
+	err := c.getVal0(GetCurrent)
+	if err != nil {
+		return nil
+	}
+	key := append([]byte(nil), getBytes(c.txn.key)...)
+	*c.txn.key = C.MDB_val{}
+	*c.txn.val = C.MDB_val{}
+	return key
+}
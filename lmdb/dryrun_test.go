@@ -0,0 +1,124 @@
+package lmdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnv_DryRun(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("old"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := env.DryRun(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k1"), []byte("abc"), 0)
+		if err != nil {
+			return err
+		}
+		// failed writes are not reported.
+		err = txn.Put(dbi, []byte("k1"), []byte("abc"), NoOverwrite)
+		if !IsErrno(err, KeyExist) {
+			return err
+		}
+		err = txn.Del(dbi, []byte("old"), nil)
+		if err != nil {
+			return err
+		}
+		// writes are visible within the dry run.
+		_, err = txn.Get(dbi, []byte("k1"))
+		if err != nil {
+			return err
+		}
+
+		// writes of aborted subtransactions are not reported.
+		err = txn.Sub(func(txn *Txn) error {
+			err := txn.Put(dbi, []byte("k2"), []byte("x"), 0)
+			if err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		if err == nil {
+			return errors.New("subtransaction did not fail")
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		_, _, err = cur.Get([]byte("k1"), nil, Set)
+		if err != nil {
+			return err
+		}
+		return cur.Del(0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []DryRunOp{
+		{"put", dbi, []byte("k1"), 3},
+		{"del", dbi, []byte("old"), 0},
+		{"del", dbi, []byte("k1"), 0},
+	}
+	if len(report.Ops) != len(expect) {
+		t.Fatalf("unexpected ops: %v", report.Ops)
+	}
+	for i, op := range report.Ops {
+		if op.Op != expect[i].Op || op.DBI != expect[i].DBI || string(op.Key) != string(expect[i].Key) || op.ValSize != expect[i].ValSize {
+			t.Errorf("op %d: %v (!= %v)", i, op, expect[i])
+		}
+	}
+	if report.Puts != 1 || report.Dels != 2 || report.KeyBytes != 7 || report.ValBytes != 3 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// nothing was written.
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k1"))
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, err = txn.Get(dbi, []byte("old"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// errors from fn are returned along with the report.
+	fail := errors.New("fail")
+	report, err = env.DryRun(func(txn *Txn) (err error) {
+		err = txn.Drop(dbi, true)
+		if err != nil {
+			return err
+		}
+		return fail
+	})
+	if err != fail {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if report.Drops != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// a deleting drop leaves the handle usable.
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("old"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Durability is a preset combination of the NoSync, NoMetaSync and MapAsync
+// flags, which determine what a committed transaction survives.  All presets
+// survive the death of the process.  They differ in what is guaranteed after
+// a crash of the operating system or a power failure.
+type Durability int
+
+// Durability presets, from the most to the least durable.
+const (
+	// DurabilityFull syncs data and meta pages on every commit.  A committed
+	// transaction survives a system crash.
+	DurabilityFull Durability = iota
+
+	// DurabilityNoMetaSync syncs data pages but not the meta page on commit.
+	// A system crash may undo the last committed transaction but the
+	// environment remains intact.
+	DurabilityNoMetaSync
+
+	// DurabilityAsync starts flushing the memory map on commit without
+	// waiting for it to complete.  It requires the WriteMap flag.  A system
+	// crash may undo recent transactions or corrupt the environment unless
+	// Env.Sync was called since the last commit.
+	DurabilityAsync
+
+	// DurabilityVolatile leaves flushing to the operating system.  A system
+	// crash may undo recent transactions or corrupt the environment unless
+	// Env.Sync was called since the last commit.  It is intended for bulk
+	// loads which can be repeated from scratch.
+	DurabilityVolatile
+
+	// DurabilityCustom is returned by Env.Durability when the flags of an
+	// environment were set to a combination not matching a preset.  It
+	// cannot be passed to Env.SetDurability.
+	DurabilityCustom
+)
+
+const durabilityFlags = NoSync | NoMetaSync | MapAsync
+
+var durabilityNames = []string{
+	DurabilityFull:       "full",
+	DurabilityNoMetaSync: "nometasync",
+	DurabilityAsync:      "async",
+	DurabilityVolatile:   "volatile",
+	DurabilityCustom:     "custom",
+}
+
+func (d Durability) String() string {
+	if d < 0 || int(d) >= len(durabilityNames) {
+		return fmt.Sprintf("Durability(%d)", int(d))
+	}
+	return durabilityNames[d]
+}
+
+// flags returns the environment flags set by d.
+func (d Durability) flags() (uint, bool) {
+	switch d {
+	case DurabilityFull:
+		return 0, true
+	case DurabilityNoMetaSync:
+		return NoMetaSync, true
+	case DurabilityAsync:
+		return MapAsync, true
+	case DurabilityVolatile:
+		return NoSync, true
+	}
+	return 0, false
+}
+
+var errDurabilityWriteMap = errors.New("lmdb: async durability requires the WriteMap flag")
+
+// SetDurability changes the durability of transactions committed through env.
+// The flags are changed within a write transaction, so that no transaction of
+// the process commits while they are partially applied.  When the new preset
+// is more durable than the old one the environment is synced, so that the
+// guarantees of the new preset also hold for transactions committed before.
+// An application may thus load data with DurabilityVolatile and switch to
+// DurabilityFull once the load completes.
+//
+// SetDurability must not be called from a goroutine with an active write
+// transaction on env, which would deadlock.  Durability only affects the
+// calling process; each process sets its own.
+func (env *Env) SetDurability(d Durability) error {
+	set, ok := d.flags()
+	if !ok {
+		return fmt.Errorf("lmdb: invalid durability %v", d)
+	}
+	flags, err := env.Flags()
+	if err != nil {
+		return err
+	}
+	if d == DurabilityAsync && flags&WriteMap == 0 {
+		return errDurabilityWriteMap
+	}
+	old := durabilityOf(flags)
+	if flags&Readonly != 0 {
+		return env.setDurabilityFlags(set)
+	}
+	err = env.Update(func(txn *Txn) error {
+		return env.setDurabilityFlags(set)
+	})
+	if err != nil {
+		return err
+	}
+	if d < old {
+		return env.Sync(true)
+	}
+	return nil
+}
+
+func (env *Env) setDurabilityFlags(set uint) error {
+	err := env.UnsetFlags(durabilityFlags &^ set)
+	if err != nil {
+		return err
+	}
+	if set != 0 {
+		return env.SetFlags(set)
+	}
+	return nil
+}
+
+// Durability returns the durability preset matching the flags of env, or
+// DurabilityCustom if none does.
+func (env *Env) Durability() (Durability, error) {
+	flags, err := env.Flags()
+	if err != nil {
+		return 0, err
+	}
+	return durabilityOf(flags), nil
+}
+
+func durabilityOf(flags uint) Durability {
+	switch flags & durabilityFlags {
+	case 0:
+		return DurabilityFull
+	case NoMetaSync:
+		return DurabilityNoMetaSync
+	case MapAsync:
+		return DurabilityAsync
+	case NoSync, NoSync | NoMetaSync, NoSync | MapAsync, durabilityFlags:
+		// NoSync overrides the other flags.
+		return DurabilityVolatile
+	}
+	return DurabilityCustom
+}
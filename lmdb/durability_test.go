@@ -0,0 +1,68 @@
+package lmdb
+
+import "testing"
+
+func TestEnv_SetDurability(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	d, err := env.Durability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != DurabilityFull {
+		t.Errorf("unexpected durability: %v", d)
+	}
+
+	for _, d := range []Durability{DurabilityVolatile, DurabilityNoMetaSync, DurabilityFull} {
+		err = env.SetDurability(d)
+		if err != nil {
+			t.Fatalf("%v: %v", d, err)
+		}
+		got, err := env.Durability()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != d {
+			t.Errorf("unexpected durability: %v (!= %v)", got, d)
+		}
+	}
+
+	err = env.SetDurability(DurabilityAsync)
+	if err != errDurabilityWriteMap {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = env.SetDurability(DurabilityCustom)
+	if err == nil {
+		t.Errorf("expected error for custom durability")
+	}
+
+	err = env.SetFlags(NoMetaSync | MapAsync)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = env.Durability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != DurabilityCustom {
+		t.Errorf("unexpected durability: %v", d)
+	}
+}
+
+func TestEnv_SetDurability_writeMap(t *testing.T) {
+	env := setupFlags(t, WriteMap)
+	defer clean(env, t)
+
+	err := env.SetDurability(DurabilityAsync)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := env.Durability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != DurabilityAsync {
+		t.Errorf("unexpected durability: %v", d)
+	}
+}
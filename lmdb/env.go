@@ -13,6 +13,7 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -40,6 +41,12 @@ const (
 	NoLock      = C.MDB_NOLOCK     // Danger zone. LMDB does not use any locks.
 	NoReadahead = C.MDB_NORDAHEAD  // Disable readahead. Requires OS support.
 	NoMemInit   = C.MDB_NOMEMINIT  // Disable LMDB memory initialization.
+
+	// PrevSnapshot is the value of MDB_PREVSNAPSHOT, which opens the
+	// snapshot preceding the last commit in LMDB 0.9.90 and later.  The
+	// bundled LMDB does not support it and Env.Open rejects it, see
+	// OpenPrevSnapshot instead.
+	PrevSnapshot = 0x2000000
 )
 
 // These flags are exclusively used in the Env.CopyFlags and Env.CopyFDFlags
@@ -65,11 +72,58 @@ type Env struct {
 	_env *C.MDB_env
 
 	// closeLock is used to allow the Txn finalizer to check if the Env has
-	// been closed, so that it may know if it must abort.
+	// been closed, so that it may know if it must abort.  Read-only
+	// transactions begin under it so that ShrinkInPlace can hold them off.
 	closeLock sync.RWMutex
 
 	ckey *C.MDB_val
 	cval *C.MDB_val
+
+	journal *intentJournal
+
+	checker *readerChecker
+
+	syncer *envSyncer
+
+	mmap envMmap
+
+	tracker txnTracker
+
+	watchdog *WriteWatchdog
+
+	limiter *WriteLimiter
+
+	dbis dbiRegistry
+
+	freeze envFreeze
+
+	filecheck bool
+
+	// maxDBs is the value passed to SetMaxDBs, which LMDB does not report.
+	maxDBs int
+
+	// guard is set by OpenReadonly to reject write transactions.
+	guard bool
+
+	// tempdir is the directory created by NewTempEnv, removed by Close.
+	tempdir string
+
+	// userctx is the key of the value attached by SetUserContext.
+	userctx userctx
+
+	// assert is the handler installed by SetAssert.
+	assert func(env *Env, msg string)
+
+	// logger and slowCommit are set by SetLogger.
+	logger     Logger
+	slowCommit time.Duration
+
+	starvation *starvationCheck
+
+	// commitStats is set by SetCommitStats.
+	commitStats func(*CommitStats)
+
+	counters envCounters
 }
 
 // NewEnv allocates and initializes a new Env.
@@ -93,12 +147,38 @@ func NewEnv() (*Env, error) {
 //
 // See mdb_env_open.
 func (env *Env) Open(path string, flags uint, mode os.FileMode) error {
+	if flags&PrevSnapshot != 0 {
+		return errPrevSnapshot
+	}
 	cpath := C.CString(path)
 	defer C.free(unsafe.Pointer(cpath))
 	ret := C.mdb_env_open(env._env, cpath, C.uint(NoTLS|flags), C.mdb_mode_t(mode))
-	return operrno("mdb_env_open", ret)
+	err := operrno("mdb_env_open", ret)
+	env.logResult("lmdb: open environment", err, "path", path, "flags", flags)
+	return err
 }
 
+// OpenReadonly opens an environment handle like Open with the Readonly flag and
+// additionally guards env against accidental writes.  Any attempt to begin a
+// write transaction on env (through Update, UpdateLocked, RunTxn, BeginTxn or
+// Txn.Sub) fails immediately with ErrReadonly instead of an error from the C
+// library.  OpenReadonly is intended for analytics and batch processes that
+// must never modify production data.
+//
+// See mdb_env_open.
+func (env *Env) OpenReadonly(path string, flags uint, mode os.FileMode) error {
+	err := env.Open(path, flags|Readonly, mode)
+	if err != nil {
+		return err
+	}
+	env.guard = true
+	return nil
+}
+
+// ErrReadonly is returned when a write transaction is attempted on an Env
+// opened with OpenReadonly.
+var ErrReadonly = errors.New("lmdb: write transaction on environment opened with OpenReadonly")
+
 var errNotOpen = errors.New("enivornment is not open")
 var errNegSize = errors.New("negative size")
 
@@ -129,7 +209,7 @@ func (env *Env) FD() (uintptr, error) {
 
 // ReaderList dumps the contents of the reader lock table as text.  Readers
 // start on the second line as space-delimited fields described by the first
-// line.
+// line.  Readers returns the same information in a structured form.
 //
 // See mdb_reader_list.
 func (env *Env) ReaderList(fn func(string) error) error {
@@ -159,7 +239,11 @@ func (env *Env) ReaderList(fn func(string) error) error {
 func (env *Env) ReaderCheck() (int, error) {
 	var _dead C.int
 	ret := C.mdb_reader_check(env._env, &_dead)
-	return int(_dead), operrno("mdb_reader_check", ret)
+	err := operrno("mdb_reader_check", ret)
+	if _dead > 0 || err != nil {
+		env.logResult("lmdb: reader check", err, "cleared", int(_dead))
+	}
+	return int(_dead), err
 }
 
 func (env *Env) close() bool {
@@ -167,6 +251,16 @@ func (env *Env) close() bool {
 		return false
 	}
 
+	env.StopReaderCheck()
+	env.StopSync()
+	env.unmap()
+
+	var path string
+	if env.logger != nil {
+		path, _ = env.Path()
+	}
+
+	env.releaseAssert()
 	env.closeLock.Lock()
 	C.mdb_env_close(env._env)
 	env._env = nil
@@ -176,6 +270,17 @@ func (env *Env) close() bool {
 	C.free(unsafe.Pointer(env.cval))
 	env.ckey = nil
 	env.cval = nil
+
+	if env.journal != nil {
+		env.journal.close()
+		env.journal = nil
+	}
+	env.releaseUserContext()
+	if env.tempdir != "" {
+		os.RemoveAll(env.tempdir)
+		env.tempdir = ""
+	}
+	env.logResult("lmdb: close environment", nil, "path", path)
 	return true
 }
 
@@ -287,13 +392,35 @@ func (env *Env) Info() (*EnvInfo, error) {
 	return &info, nil
 }
 
+// LastTxnID returns the ID of the last transaction committed to the
+// environment by any process, without beginning a read transaction.
+//
+// See mdb_env_info.
+func (env *Env) LastTxnID() (uintptr, error) {
+	var _info C.MDB_envinfo
+	ret := C.mdb_env_info(env._env, &_info)
+	if ret != success {
+		return 0, operrno("mdb_env_info", ret)
+	}
+	return uintptr(_info.me_last_txnid), nil
+}
+
 // Sync flushes buffers to disk.  If force is true a synchronous flush occurs
 // and ignores any NoSync or MapAsync flag on the environment.
 //
 // See mdb_env_sync.
 func (env *Env) Sync(force bool) error {
+	start := time.Now()
 	ret := C.mdb_env_sync(env._env, cbool(force))
-	return operrno("mdb_env_sync", ret)
+	err := operrno("mdb_env_sync", ret)
+	if l := env.logger; l != nil {
+		if err != nil {
+			l.Error("lmdb: sync failed", "force", force, "err", err)
+		} else {
+			l.Debug("lmdb: sync", "force", force, "duration", time.Since(start))
+		}
+	}
+	return err
 }
 
 // SetFlags sets flags in the environment.
@@ -340,15 +467,22 @@ func (env *Env) Path() (string, error) {
 	return C.GoString(cpath), nil
 }
 
-// SetMapSize sets the size of the environment memory map.
+// SetMapSize sets the size of the environment memory map.  Any view returned
+// by Mmap is released.
 //
 // See mdb_env_set_mapsize.
 func (env *Env) SetMapSize(size int64) error {
 	if size < 0 {
 		return errNegSize
 	}
+	err := env.unmap()
+	if err != nil {
+		return err
+	}
 	ret := C.mdb_env_set_mapsize(env._env, C.size_t(size))
-	return operrno("mdb_env_set_mapsize", ret)
+	err = operrno("mdb_env_set_mapsize", ret)
+	env.logResult("lmdb: set map size", err, "size", size)
+	return err
 }
 
 // SetMaxReaders sets the maximum number of reader slots in the environment.
@@ -389,6 +523,9 @@ func (env *Env) SetMaxDBs(size int) error {
 		return errNegSize
 	}
 	ret := C.mdb_env_set_maxdbs(env._env, C.MDB_dbi(size))
+	if ret == success {
+		env.maxDBs = size
+	}
 	return operrno("mdb_env_set_maxdbs", ret)
 }
 
@@ -518,5 +655,6 @@ func (env *Env) run(lock bool, flags uint, fn TxnOp) error {
 //
 // See mdb_dbi_close.
 func (env *Env) CloseDBI(db DBI) {
+	env.dbis.forget(db)
 	C.mdb_dbi_close(env._env, C.MDB_dbi(db))
 }
@@ -94,6 +94,66 @@ func TestEnv_Open(t *testing.T) {
 	}
 }
 
+func TestEnv_OpenReadonly(t *testing.T) {
+	env := setup(t)
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	env.Close()
+
+	env, err = NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	err = env.OpenReadonly(path, 0, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "v" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		t.Error("update executed")
+		return nil
+	})
+	if err != ErrReadonly {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err = env.BeginTxn(nil, 0)
+	if err != ErrReadonly {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestEnv_FD(t *testing.T) {
 	env, err := NewEnv()
 	if err != nil {
@@ -0,0 +1,144 @@
+package lmdb
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Category is a class of errors that applications typically handle alike,
+// whatever the operation or errno.
+//
+//	switch lmdb.ErrorCategory(err) {
+//	case lmdb.CategoryResize:
+//		// grow the map and retry
+//	case lmdb.CategoryCorruption:
+//		// restore from a backup
+//	}
+type Category int
+
+// The categories of errors.  Errors not in any category, such as EINVAL or
+// BadTxn, which usually indicate a bug in the application, are Uncategorized.
+const (
+	Uncategorized Category = iota
+
+	// CategoryNotFound is the category of NotFound.
+	CategoryNotFound
+
+	// CategoryConflict is the category of KeyExist.
+	CategoryConflict
+
+	// CategoryResize contains the errors resolved by changing the map size,
+	// MapFull and MapResized.
+	CategoryResize
+
+	// CategoryCorruption contains the errors reporting a damaged or
+	// incompatible environment: Corrupted, PageNotFound, Panic, Invalid,
+	// VersionMismatch, *AssertError and *FileSizeError.
+	CategoryCorruption
+
+	// CategoryResource contains the errors reporting an exhausted limit
+	// other than the map size: DBsFull, ReadersFull, TLSFull, TxnFull,
+	// CursorFull, PageFull, ENOMEM and ENOSPC.
+	CategoryResource
+)
+
+var categoryNames = []string{
+	Uncategorized:      "uncategorized",
+	CategoryNotFound:   "not found",
+	CategoryConflict:   "conflict",
+	CategoryResize:     "resize",
+	CategoryCorruption: "corruption",
+	CategoryResource:   "resource",
+}
+
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return "unknown"
+	}
+	return categoryNames[c]
+}
+
+// categoryError is the type of the sentinel errors of the categories.
+type categoryError Category
+
+func (err categoryError) Error() string {
+	return "lmdb: " + Category(err).String()
+}
+
+// Sentinel errors of the categories, for use with errors.Is.  For example
+// errors.Is(err, lmdb.ErrNotFound) is true for the error returned by Txn.Get
+// for a missing key, even when the application wrapped it with fmt.Errorf and
+// the %w verb.
+var (
+	ErrNotFound   error = categoryError(CategoryNotFound)
+	ErrConflict   error = categoryError(CategoryConflict)
+	ErrResize     error = categoryError(CategoryResize)
+	ErrCorruption error = categoryError(CategoryCorruption)
+	ErrResource   error = categoryError(CategoryResource)
+)
+
+// ErrorCategory returns the category of err, looking through the errors it
+// wraps.
+func ErrorCategory(err error) Category {
+	var errno Errno
+	if errors.As(err, &errno) {
+		return errno.category()
+	}
+	var sys syscall.Errno
+	if errors.As(err, &sys) {
+		switch sys {
+		case syscall.ENOMEM, syscall.ENOSPC:
+			return CategoryResource
+		}
+		return Uncategorized
+	}
+	var aerr *AssertError
+	var ferr *FileSizeError
+	if errors.As(err, &aerr) || errors.As(err, &ferr) {
+		return CategoryCorruption
+	}
+	return Uncategorized
+}
+
+func (e Errno) category() Category {
+	switch e {
+	case NotFound:
+		return CategoryNotFound
+	case KeyExist:
+		return CategoryConflict
+	case MapFull, MapResized:
+		return CategoryResize
+	case Corrupted, PageNotFound, Panic, Invalid, VersionMismatch:
+		return CategoryCorruption
+	case DBsFull, ReadersFull, TLSFull, TxnFull, CursorFull, PageFull:
+		return CategoryResource
+	}
+	return Uncategorized
+}
+
+// isCategory implements the Is methods of the error types for the sentinel
+// errors of the categories.
+func isCategory(err, target error) bool {
+	c, ok := target.(categoryError)
+	return ok && ErrorCategory(err) == Category(c)
+}
+
+// Is returns true if target is the sentinel error of the category of e.
+func (e Errno) Is(target error) bool {
+	return isCategory(e, target)
+}
+
+// Is returns true if target is the sentinel error of the category of err.
+func (err *OpError) Is(target error) bool {
+	return isCategory(err, target)
+}
+
+// Is returns true if target is ErrCorruption.
+func (err *AssertError) Is(target error) bool {
+	return target == ErrCorruption
+}
+
+// Is returns true if target is ErrCorruption.
+func (err *FileSizeError) Is(target error) bool {
+	return target == ErrCorruption
+}
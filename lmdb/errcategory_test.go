@@ -0,0 +1,97 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestErrorCategory(t *testing.T) {
+	for _, test := range []struct {
+		err error
+		cat Category
+	}{
+		{nil, Uncategorized},
+		{&OpError{Op: "mdb_get", Errno: NotFound}, CategoryNotFound},
+		{&OpError{Op: "mdb_put", Errno: KeyExist}, CategoryConflict},
+		{&OpError{Op: "mdb_put", Errno: MapFull}, CategoryResize},
+		{MapResized, CategoryResize},
+		{&OpError{Op: "mdb_get", Errno: Corrupted}, CategoryCorruption},
+		{&FileSizeError{Size: 1, Required: 2}, CategoryCorruption},
+		{&OpError{Op: "mdb_put", Errno: TxnFull, Puts: 1}, CategoryResource},
+		{&OpError{Op: "mdb_env_open", Errno: syscall.ENOSPC}, CategoryResource},
+		{&OpError{Op: "mdb_put", Errno: syscall.EINVAL}, Uncategorized},
+		{&OpError{Op: "mdb_put", Errno: MapFull, DBI: 2}, CategoryResize},
+		{fmt.Errorf("load: %w", &OpError{Op: "mdb_get", Errno: NotFound}), CategoryNotFound},
+	} {
+		if cat := ErrorCategory(test.err); cat != test.cat {
+			t.Errorf("%v: category %v (!= %v)", test.err, cat, test.cat)
+		}
+	}
+
+	err := fmt.Errorf("load: %w", &OpError{Op: "mdb_get", Errno: NotFound})
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, NotFound) {
+		t.Errorf("%v is not NotFound", err)
+	}
+	if errors.Is(err, ErrConflict) || errors.Is(err, KeyExist) {
+		t.Errorf("%v is KeyExist", err)
+	}
+	if !IsNotFound(err) {
+		t.Errorf("IsNotFound(%v) is false", err)
+	}
+	if !errors.Is(&AssertError{}, ErrCorruption) {
+		t.Errorf("assertion failure is not corruption")
+	}
+}
+
+func TestOpError_dbi(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("users", Create|DupSort)
+		if err != nil {
+			return err
+		}
+
+		// Ordinary results don't carry the database.
+		_, err = txn.Get(dbi, []byte("missing"))
+		if operr, ok := err.(*OpError); !ok || operr.DBI != 0 || !errors.Is(err, ErrNotFound) {
+			t.Errorf("unexpected error: %#v", err)
+		}
+
+		// Values of a DupSort database are limited like keys.
+		err = txn.Put(dbi, []byte("k"), make([]byte, env.MaxKeySize()+1), 0)
+		operr, ok := err.(*OpError)
+		if !ok {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+		if operr.Op != "mdb_put" || operr.DBI != dbi || operr.Name != "users" {
+			t.Errorf("%s dbi %d %q", operr.Op, operr.DBI, operr.Name)
+		}
+		if !strings.Contains(err.Error(), `"users"`) {
+			t.Errorf("unexpected message: %v", err)
+		}
+		if !IsErrno(err, BadValSize) {
+			t.Errorf("errno is not BadValSize: %v", err)
+		}
+
+		// Cursor reads carry the database like writes.
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		_, _, err = cur.Get(nil, nil, Set)
+		operr, ok = err.(*OpError)
+		if !ok || operr.DBI != dbi || operr.Name != "users" || !IsErrno(err, BadValSize) {
+			t.Errorf("unexpected error: %#v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,6 +6,8 @@ package lmdb
 import "C"
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"syscall"
 )
@@ -16,11 +18,58 @@ import (
 type OpError struct {
 	Op    string
 	Errno error
+
+	// DBI and Name identify the database of a failed Txn or Cursor operation
+	// reading or writing items, for diagnostics.  DBI is zero if the operation
+	// was not on a database.  Name is empty for the root database or if the
+	// name is unknown.  They are not set for NotFound and KeyExist, which are
+	// ordinary results of the operations.
+	DBI  DBI
+	Name string
+
+	// Puts and Dels are the items written and deleted by the transaction and
+	// its subtransactions when Errno is TxnFull, because the transaction has
+	// accumulated more dirty pages than LMDB can track (roughly 128k pages).
+	// Splitting the work into transactions of a fraction of Puts+Dels
+	// operations is usually sufficient.
+	Puts uint64
+	Dels uint64
 }
 
 // Error implements the error interface.
 func (err *OpError) Error() string {
-	return err.Op + ": " + err.Errno.Error()
+	msg := err.Op + ": " + err.Errno.Error()
+	if err.DBI != 0 {
+		if err.Name == "" {
+			msg += fmt.Sprintf(" (dbi %d)", err.DBI)
+		} else {
+			msg += fmt.Sprintf(" (dbi %d %q)", err.DBI, err.Name)
+		}
+	}
+	if err.Puts != 0 || err.Dels != 0 {
+		msg += fmt.Sprintf(" (after %d puts and %d deletes, split the transaction)", err.Puts, err.Dels)
+	}
+	return msg
+}
+
+// Unwrap returns err.Errno, so that errors.Is(err, lmdb.NotFound) is true for
+// an *OpError with errno NotFound.
+func (err *OpError) Unwrap() error {
+	return err.Errno
+}
+
+// dbiErrno is like txn.errno for an operation on dbi, and records dbi and its
+// name in errors other than NotFound and KeyExist.
+func (txn *Txn) dbiErrno(op string, dbi DBI, ret C.int) error {
+	err := txn.errno(op, ret)
+	if ret == success || ret == C.MDB_NOTFOUND || ret == C.MDB_KEYEXIST {
+		return err
+	}
+	if operr, ok := err.(*OpError); ok {
+		operr.DBI = dbi
+		operr.Name, _ = txn.env.dbis.name(dbi)
+	}
+	return err
 }
 
 // The most common error codes do not need to be handled explicity.  Errors can
@@ -117,14 +166,15 @@ func IsErrnoSys(err error, errno syscall.Errno) bool {
 }
 
 // IsErrnoFn calls fn on the error underlying err and returns the result.  If
-// err is an *OpError then err.Errno is passed to fn.  Otherwise err is passed
-// directly to fn.
+// err is or wraps an *OpError then its Errno is passed to fn.
+// Otherwise err is passed directly to fn.
 func IsErrnoFn(err error, fn func(error) bool) bool {
 	if err == nil {
 		return false
 	}
-	if err, ok := err.(*OpError); ok {
-		return fn(err.Errno)
+	var operr *OpError
+	if errors.As(err, &operr) {
+		return fn(operr.Errno)
 	}
 	return fn(err)
 }
@@ -7,7 +7,7 @@ import (
 )
 
 func TestErrno_Error(t *testing.T) {
-	operr := &OpError{"testop", fmt.Errorf("testmsg")}
+	operr := &OpError{Op: "testop", Errno: fmt.Errorf("testmsg")}
 	msg := operr.Error()
 	if msg != "testop: testmsg" {
 		t.Errorf("message: %q", msg)
@@ -22,7 +22,7 @@ func BenchmarkErrno_Error(b *testing.B) {
 			MapResized,
 			MapFull,
 		} {
-			operr := &OpError{"mdb_testop", errno}
+			operr := &OpError{Op: "mdb_testop", Errno: errno}
 			msg := operr.Error()
 			if msg == "" {
 				b.Fatal("empty message")
@@ -0,0 +1,84 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// FileSizeError is returned when the data file of an environment is smaller
+// than the pages in use, because it was truncated or replaced by another
+// process or is served by a misbehaving network file system.  Reading the
+// missing pages through the memory map would raise SIGBUS and abort the
+// process.
+type FileSizeError struct {
+	Size     int64 // Size of the data file
+	Required int64 // Bytes occupied by pages in use
+}
+
+func (err *FileSizeError) Error() string {
+	return fmt.Sprintf("lmdb: data file has %d bytes but %d are in use (truncated?)", err.Size, err.Required)
+}
+
+// CheckFileSize returns a *FileSizeError if the data file of env is smaller
+// than the pages in use.
+func (env *Env) CheckFileSize() error {
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		return err
+	}
+	fd, err := env.FD()
+	if err != nil {
+		return err
+	}
+	size, err := fileSize(fd)
+	if err != nil {
+		return err
+	}
+	required := (info.LastPNO + 1) * int64(stat.PSize)
+	if size < required {
+		return &FileSizeError{Size: size, Required: required}
+	}
+	return nil
+}
+
+// SetFileCheck enables calling CheckFileSize before each read-only
+// transaction begins, so that a truncated data file is reported as an error
+// rather than crashing the process in a later read.  The check costs a few
+// system calls per transaction.  It narrows but cannot close the window in
+// which the file may be truncated during a transaction; see Safe.
+func (env *Env) SetFileCheck(enabled bool) {
+	env.filecheck = enabled
+}
+
+// ErrFault is returned by Safe when fn accessed memory which could not be
+// read, such as the memory map beyond the end of a truncated file.
+var ErrFault = errors.New("lmdb: memory fault while reading the memory map")
+
+// Safe calls fn and converts a memory fault raised by Go code in fn, such as
+// SIGBUS from reading a value returned with RawRead, into ErrFault.  Faults
+// raised within the C library cannot be recovered and still abort the
+// process, so Safe is best combined with SetFileCheck.
+//
+// Safe uses debug.SetPanicOnFault, which applies to the calling goroutine
+// only.  Faults are only recovered if they occur in that goroutine.
+func Safe(fn func() error) (err error) {
+	old := debug.SetPanicOnFault(true)
+	defer debug.SetPanicOnFault(old)
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		}
+		if _, ok := e.(interface{ Addr() uintptr }); ok {
+			err = ErrFault
+			return
+		}
+		panic(e)
+	}()
+	return fn()
+}
@@ -0,0 +1,89 @@
+package lmdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnv_CheckFileSize(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		v := make([]byte, 4096)
+		for i := 0; i < 16; i++ {
+			err = txn.Put(dbi, []byte{byte(i)}, v, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.CheckFileSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// truncate the file but keep the meta pages, which are read by the
+	// check itself.
+	stat, err := env.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Truncate(filepath.Join(path, "data.mdb"), 2*int64(stat.PSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.CheckFileSize()
+	if err, ok := err.(*FileSizeError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err.Size != 2*int64(stat.PSize) || err.Required <= err.Size {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// read transactions only fail once the check is enabled.
+	err = env.View(func(txn *Txn) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.SetFileCheck(true)
+	err = env.View(func(txn *Txn) error {
+		t.Errorf("transaction began on a truncated file")
+		return nil
+	})
+	if _, ok := err.(*FileSizeError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSafe(t *testing.T) {
+	err := Safe(func() error { return nil })
+	if err != nil {
+		t.Error(err)
+	}
+	err = Safe(func() error { return ErrFrozen })
+	if err != ErrFrozen {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic to propagate")
+		}
+	}()
+	Safe(func() error { panic("boom") })
+}
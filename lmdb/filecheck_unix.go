@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package lmdb
+
+import "syscall"
+
+func fileSize(fd uintptr) (int64, error) {
+	var st syscall.Stat_t
+	err := syscall.Fstat(int(fd), &st)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size, nil
+}
@@ -0,0 +1,12 @@
+package lmdb
+
+import "syscall"
+
+func fileSize(fd uintptr) (int64, error) {
+	var info syscall.ByHandleFileInformation
+	err := syscall.GetFileInformationByHandle(syscall.Handle(fd), &info)
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.FileSizeHigh)<<32 | int64(info.FileSizeLow), nil
+}
@@ -0,0 +1,135 @@
+//go:build go1.18
+// +build go1.18
+
+package lmdb
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// GetDupFixed reads all duplicate values of key from the DupFixed database of
+// cur into a new []T.  Values are read a page at a time using GetMultiple and
+// NextMultiple and each page is copied into the result with a single copy,
+// avoiding the overhead of decoding elements individually.  The cursor is
+// left positioned at the last duplicate of key.
+//
+// T must be a fixed size type which contains no pointers, such as an integer
+// or a struct of integers, and its size (including any padding) must equal
+// the size of the values in the database.  Values are copied as they are
+// stored so T must also match their byte order.  GetDupFixed returns an error
+// if these conditions are not met.  If key does not exist a NotFound error is
+// returned.
+func GetDupFixed[T any](cur *Cursor, key []byte) ([]T, error) {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	err := checkFixed(reflect.TypeOf(zero), size)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := cur.Txn()
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+
+	_, first, err := cur.Get(key, nil, SetKey)
+	if err != nil {
+		return nil, err
+	}
+	n, err := cur.Count()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, n)
+	dst := unsafe.Slice((*byte)(unsafe.Pointer(&out[0])), len(out)*size)
+	if n == 1 {
+		// A single value is not stored on a page of duplicates so
+		// GetMultiple has no page to return.
+		if len(first) != size {
+			return nil, fmt.Errorf("lmdb: value size is not a multiple of %d bytes", size)
+		}
+		copy(dst, first)
+		return out, nil
+	}
+	off := 0
+	for op := uint(GetMultiple); ; op = NextMultiple {
+		_, page, err := cur.Get(nil, nil, op)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(page)%size != 0 {
+			return nil, fmt.Errorf("lmdb: value size is not a multiple of %d bytes", size)
+		}
+		if off+len(page) > len(dst) {
+			return nil, fmt.Errorf("lmdb: more duplicates than counted")
+		}
+		off += copy(dst[off:], page)
+	}
+	return out[:off/size], nil
+}
+
+// CastDupFixed returns a []T that shares the memory of page, a page of values
+// returned by Cursor.Get with the GetMultiple or NextMultiple ops.  No data is
+// copied.  When the Txn of the cursor has RawRead set the result references
+// memory owned by LMDB and must not be modified or used after the transaction
+// terminates.
+//
+// T is subject to the same restrictions as in GetDupFixed.  CastDupFixed
+// returns an error if the length of page is not a multiple of the size of T
+// or page is not suitably aligned for T.  Pages of a memory map are aligned
+// but values within them are only guaranteed to be aligned to 2 bytes.
+func CastDupFixed[T any](page []byte) ([]T, error) {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	err := checkFixed(reflect.TypeOf(zero), size)
+	if err != nil {
+		return nil, err
+	}
+	if len(page) == 0 {
+		return nil, nil
+	}
+	if len(page)%size != 0 {
+		return nil, fmt.Errorf("lmdb: value size is not a multiple of %d bytes", size)
+	}
+	if uintptr(unsafe.Pointer(&page[0]))%unsafe.Alignof(zero) != 0 {
+		return nil, fmt.Errorf("lmdb: page is not aligned to %d bytes", unsafe.Alignof(zero))
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&page[0])), len(page)/size), nil
+}
+
+// checkFixed returns an error if values of type t cannot be copied from the
+// database as raw bytes.
+func checkFixed(t reflect.Type, size int) error {
+	if t == nil || size == 0 {
+		return fmt.Errorf("lmdb: %v is not a fixed size type", t)
+	}
+	if hasPointers(t) {
+		return fmt.Errorf("lmdb: %v contains pointers", t)
+	}
+	return nil
+}
+
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+//go:build go1.18
+// +build go1.18
+
+package lmdb
+
+import (
+	"testing"
+	"unsafe"
+)
+
+type fixedRecord struct {
+	ID    uint32
+	Score uint32
+}
+
+func TestGetDupFixed(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	const n = 5000
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdupfixed", Create|DupSort|DupFixed)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			rec := fixedRecord{ID: uint32(i), Score: uint32(2 * i)}
+			val := (*[8]byte)(unsafe.Pointer(&rec))[:]
+			err = txn.Put(dbi, []byte("key"), val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Put(dbi, []byte("other"), make([]byte, 8), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		recs, err := GetDupFixed[fixedRecord](cur, []byte("key"))
+		if err != nil {
+			return err
+		}
+		if len(recs) != n {
+			t.Fatalf("unexpected number of records: %d", len(recs))
+		}
+		// duplicates are sorted by their bytes so only check membership.
+		seen := make(map[uint32]bool)
+		for _, rec := range recs {
+			if rec.Score != 2*rec.ID {
+				t.Errorf("unexpected record: %+v", rec)
+			}
+			seen[rec.ID] = true
+		}
+		if len(seen) != n {
+			t.Errorf("unexpected number of distinct records: %d", len(seen))
+		}
+
+		_, err = GetDupFixed[uint32](cur, []byte("key"))
+		if err == nil {
+			t.Errorf("expected error for mismatched size")
+		}
+		_, err = GetDupFixed[*int](cur, []byte("key"))
+		if err == nil {
+			t.Errorf("expected error for pointer type")
+		}
+		_, err = GetDupFixed[fixedRecord](cur, []byte("missing"))
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		recs, err = GetDupFixed[fixedRecord](cur, []byte("other"))
+		if err != nil {
+			return err
+		}
+		if len(recs) != 1 || recs[0] != (fixedRecord{}) {
+			t.Errorf("unexpected records for a single value: %v", recs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCastDupFixed(t *testing.T) {
+	buf := make([]uint64, 3)
+	page := unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), 24)
+	recs, err := CastDupFixed[fixedRecord](page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("unexpected length: %d", len(recs))
+	}
+	recs[1].ID = 7
+	if buf[1] == 0 {
+		t.Errorf("expected result to share memory with page")
+	}
+	_, err = CastDupFixed[fixedRecord](page[1:9])
+	if err == nil {
+		t.Errorf("expected alignment error")
+	}
+	_, err = CastDupFixed[fixedRecord](page[:12])
+	if err == nil {
+		t.Errorf("expected size error")
+	}
+}
@@ -0,0 +1,103 @@
+package lmdb
+
+import (
+	"sort"
+)
+
+// freeDBI is the handle of the free list, FREE_DBI in the C library.
+const freeDBI DBI = 0
+
+// FreelistStat describes the pages on the free list of an environment.  Free
+// pages were released by a committed transaction and are reused by writers
+// once no reader holds a snapshot older than the transaction that freed them.
+// A free list that keeps growing indicates long running readers, and a
+// fragmented one forces writers needing several consecutive pages (for large
+// values) to extend the file instead of reusing free pages.
+type FreelistStat struct {
+	Entries       int     // Records on the free list, one per freeing transaction
+	FreePages     int64   // Pages on the free list
+	Runs          int64   // Runs of consecutive free page numbers
+	LargestRun    int64   // Pages in the longest run
+	Fragmentation float64 // 1 - LargestRun/FreePages, or zero without free pages
+	Unsorted      int     // Records whose pages are not in descending order
+	Pages         int64   // Pages in use by the environment, free or not
+}
+
+// FreelistStat reads the free list in the snapshot of txn.  The list is read
+// entirely, which takes time proportional to the number of free pages.
+func (txn *Txn) FreelistStat() (*FreelistStat, error) {
+	info, err := txn.env.Info()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := txn.OpenCursor(freeDBI)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	stat := &FreelistStat{Pages: info.LastPNO + 1}
+	var pages []uint64
+	for {
+		_, v, err := cur.Get(nil, nil, Next)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		stat.Entries++
+		// the record is an IDL, a count followed by page numbers in
+		// descending order, each of the size of size_t.
+		w := fileWord
+		if len(v) < w {
+			stat.Unsorted++
+			continue
+		}
+		n := int(fileUint(v))
+		if len(v) < (n+1)*w {
+			n = len(v)/w - 1
+			stat.Unsorted++
+		}
+		prev := ^uint64(0)
+		for i := 1; i <= n; i++ {
+			pg := fileUint(v[i*w:])
+			if pg > prev {
+				stat.Unsorted++
+			}
+			prev = pg
+			pages = append(pages, pg)
+		}
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	stat.FreePages = int64(len(pages))
+	var run int64
+	for i, pg := range pages {
+		if i > 0 && pg == pages[i-1]+1 {
+			run++
+		} else {
+			stat.Runs++
+			run = 1
+		}
+		if run > stat.LargestRun {
+			stat.LargestRun = run
+		}
+	}
+	if stat.FreePages > 0 {
+		stat.Fragmentation = 1 - float64(stat.LargestRun)/float64(stat.FreePages)
+	}
+	return stat, nil
+}
+
+// FreelistStat reads the free list of env in a read-only transaction.  See
+// Txn.FreelistStat.
+func (env *Env) FreelistStat() (*FreelistStat, error) {
+	var stat *FreelistStat
+	err := env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		stat, err = txn.FreelistStat()
+		return err
+	})
+	return stat, err
+}
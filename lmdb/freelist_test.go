@@ -0,0 +1,60 @@
+package lmdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEnv_FreelistStat(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	stat, err := env.FreelistStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.FreePages != 0 || stat.Entries != 0 || stat.Fragmentation != 0 {
+		t.Errorf("unexpected stat for empty environment: %+v", stat)
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 4; n++ {
+		err = env.Update(func(txn *Txn) (err error) {
+			for i := 0; i < 100; i++ {
+				key := []byte(fmt.Sprintf("%03d", i))
+				if n%2 == 1 && i%2 == 0 {
+					err = txn.Del(dbi, key, nil)
+				} else {
+					err = txn.Put(dbi, key, make([]byte, 500), 0)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stat, err = env.FreelistStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Entries == 0 || stat.FreePages == 0 {
+		t.Fatalf("expected free pages: %+v", stat)
+	}
+	if stat.Unsorted != 0 {
+		t.Errorf("unsorted records: %+v", stat)
+	}
+	if stat.Runs == 0 || stat.LargestRun == 0 || stat.LargestRun > stat.FreePages || stat.FreePages >= stat.Pages {
+		t.Errorf("inconsistent stat: %+v", stat)
+	}
+	if stat.Fragmentation < 0 || stat.Fragmentation >= 1 {
+		t.Errorf("unexpected fragmentation: %+v", stat)
+	}
+}
@@ -0,0 +1,124 @@
+package lmdb
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrFrozen is returned when a write transaction is attempted on an Env
+// frozen with FreezeFail.
+var ErrFrozen = errors.New("lmdb: environment is frozen")
+
+// FreezeMode determines what happens to write transactions begun while an Env
+// is frozen.
+type FreezeMode int
+
+// Freeze modes.
+const (
+	FreezeFail  FreezeMode = iota // Write transactions fail with ErrFrozen
+	FreezeBlock                   // Write transactions wait for Thaw
+)
+
+// envFreeze is the freeze state of an Env and counts the active top-level
+// write transactions so that Freeze can wait for them.
+type envFreeze struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	frozen  bool
+	mode    FreezeMode
+	writers int
+}
+
+func (f *envFreeze) init() {
+	if f.cond == nil {
+		f.cond = sync.NewCond(&f.mu)
+	}
+}
+
+// enter registers a write transaction about to begin.
+func (f *envFreeze) enter() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	for f.frozen {
+		if f.mode == FreezeFail {
+			return ErrFrozen
+		}
+		f.cond.Wait()
+	}
+	f.writers++
+	return nil
+}
+
+// hold freezes f with mode unless it is already frozen.  Unlike Env.Freeze it
+// does not wait for write transactions, it returns false if one is active.
+// Otherwise it returns a function restoring the previous state of f.
+func (f *envFreeze) hold(mode FreezeMode) (func(), bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	if f.writers > 0 {
+		return nil, false
+	}
+	if f.frozen {
+		return func() {}, true
+	}
+	f.frozen = true
+	f.mode = mode
+	return func() {
+		f.mu.Lock()
+		f.frozen = false
+		f.mu.Unlock()
+		f.cond.Broadcast()
+	}, true
+}
+
+// exit unregisters a write transaction that terminated.
+func (f *envFreeze) exit() {
+	f.mu.Lock()
+	f.init()
+	f.writers--
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+// Freeze stops new write transactions from beginning in env, which either
+// fail with ErrFrozen or wait depending on mode, and waits until the write
+// transaction in progress, if any, has terminated.  Afterwards env is
+// read-only for the process until Thaw is called, which gives maintenance
+// tasks such as backups or compaction a consistent environment.  Freeze only
+// affects transactions begun through env and not other processes.
+//
+// Freeze must not be called by a goroutine with an active write transaction
+// on env, which would deadlock.  Calling Freeze on a frozen Env changes its
+// mode.
+func (env *Env) Freeze(mode FreezeMode) {
+	f := &env.freeze
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	f.frozen = true
+	f.mode = mode
+	for f.writers > 0 {
+		f.cond.Wait()
+	}
+}
+
+// Thaw allows write transactions in env again, waking those waiting since
+// Freeze.
+func (env *Env) Thaw() {
+	f := &env.freeze
+	f.mu.Lock()
+	f.init()
+	f.frozen = false
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+// Frozen returns true if env is frozen.
+func (env *Env) Frozen() bool {
+	f := &env.freeze
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frozen
+}
@@ -0,0 +1,75 @@
+package lmdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnv_Freeze(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	nop := func(txn *Txn) error { return nil }
+
+	env.Freeze(FreezeFail)
+	if !env.Frozen() {
+		t.Errorf("env not frozen")
+	}
+	err := env.Update(nop)
+	if err != ErrFrozen {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = env.View(nop)
+	if err != nil {
+		t.Errorf("read-only transaction failed: %v", err)
+	}
+	env.Thaw()
+	err = env.Update(nop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a blocked writer proceeds after Thaw.
+	env.Freeze(FreezeBlock)
+	done := make(chan error)
+	go func() { done <- env.Update(nop) }()
+	select {
+	case err := <-done:
+		t.Fatalf("writer did not block: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	env.Thaw()
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Freeze waits for the active writer.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		done <- env.Update(func(txn *Txn) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	frozen := make(chan struct{})
+	go func() {
+		env.Freeze(FreezeFail)
+		close(frozen)
+	}()
+	select {
+	case <-frozen:
+		t.Fatalf("Freeze did not wait for the active writer")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-frozen
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Thaw()
+}
@@ -0,0 +1,871 @@
+//go:build go1.18
+// +build go1.18
+
+package lmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets in this file run operations against an environment and
+// compare the results with a model of its content.  The seed corpus runs with
+// go test, new inputs are generated with
+//
+//	go test -run NONE -fuzz FuzzOps ./lmdb
+//	go test -run NONE -fuzz FuzzPutGet ./lmdb
+
+// fuzzMaxKeySize is the default maximum key size of LMDB, which the fuzz
+// targets assume.  They are skipped for an environment with another limit.
+const fuzzMaxKeySize = 511
+
+// fuzzKeys are the keys used by FuzzOps.  The first and the last are invalid.
+var fuzzKeys = [][]byte{
+	{},
+	[]byte("a"),
+	[]byte("ab"),
+	[]byte("b"),
+	[]byte("ba"),
+	[]byte("c"),
+	bytes.Repeat([]byte("k"), fuzzMaxKeySize),
+	bytes.Repeat([]byte("k"), fuzzMaxKeySize+1),
+}
+
+// fuzzDB is a database used by FuzzOps.
+type fuzzDB struct {
+	name  string
+	flags uint
+	puts  []uint // Flags of put operations
+	vals  [][]byte
+}
+
+// fuzzDBs are the databases used by FuzzOps.  Values stored in DupSort
+// databases are not empty, LMDB cannot store an empty duplicate next to
+// others.  The values of the DupFixed database are generated by fuzzFixed.
+var fuzzDBs = []fuzzDB{
+	{
+		name: "plain",
+		puts: []uint{0, NoOverwrite, Append},
+		vals: [][]byte{
+			nil,
+			{},
+			[]byte("v"),
+			[]byte("vw"),
+			[]byte("w"),
+			bytes.Repeat([]byte("o"), 600),
+			bytes.Repeat([]byte("O"), 5000), // overflow pages
+		},
+	},
+	{
+		name:  "dupsort",
+		flags: DupSort,
+		puts:  []uint{0, NoOverwrite, NoDupData, AppendDup},
+		vals: [][]byte{
+			[]byte("v"),
+			[]byte("vw"),
+			[]byte("w"),
+			[]byte("x"),
+			bytes.Repeat([]byte("z"), fuzzMaxKeySize),
+		},
+	},
+	{
+		name:  "dupfixed",
+		flags: DupSort | DupFixed,
+		puts:  []uint{0, NoOverwrite, NoDupData, AppendDup},
+	},
+}
+
+// fuzzFixed returns the i-th value of the DupFixed database.  Values are
+// spaced so that PutMulti writes ranges overlapping those of other puts.
+func fuzzFixed(i int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(i))
+	return b
+}
+
+func (db *fuzzDB) dupSort() bool  { return db.flags&DupSort != 0 }
+func (db *fuzzDB) dupFixed() bool { return db.flags&DupFixed != 0 }
+
+func (db *fuzzDB) val(b byte) []byte {
+	if db.dupFixed() {
+		return fuzzFixed(int(b) * 4)
+	}
+	return db.vals[int(b)%len(db.vals)]
+}
+
+func validKey(key []byte) bool {
+	return len(key) > 0 && len(key) <= fuzzMaxKeySize
+}
+
+// fuzzModel is the expected content of a database, the sorted values of each
+// key.
+type fuzzModel map[string][]string
+
+type fuzzItem struct {
+	key, val string
+}
+
+func (m fuzzModel) clone() fuzzModel {
+	c := make(fuzzModel, len(m))
+	for k, vals := range m {
+		c[k] = append([]string(nil), vals...)
+	}
+	return c
+}
+
+// items returns the items of m in the order of the database.
+func (m fuzzModel) items() []fuzzItem {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var items []fuzzItem
+	for _, k := range keys {
+		for _, v := range m[k] {
+			items = append(items, fuzzItem{k, v})
+		}
+	}
+	return items
+}
+
+func (m fuzzModel) has(k, v string) bool {
+	vals := m[k]
+	i := sort.SearchStrings(vals, v)
+	return i < len(vals) && vals[i] == v
+}
+
+func (m fuzzModel) add(k, v string) {
+	vals := m[k]
+	i := sort.SearchStrings(vals, v)
+	if i < len(vals) && vals[i] == v {
+		return
+	}
+	vals = append(vals, "")
+	copy(vals[i+1:], vals[i:])
+	vals[i] = v
+	m[k] = vals
+}
+
+func (m fuzzModel) remove(k, v string) {
+	vals := m[k]
+	i := sort.SearchStrings(vals, v)
+	if i == len(vals) || vals[i] != v {
+		return
+	}
+	vals = append(vals[:i], vals[i+1:]...)
+	if len(vals) == 0 {
+		delete(m, k)
+		return
+	}
+	m[k] = vals
+}
+
+// fuzzResult reduces err to the outcomes predicted by the model.
+func fuzzResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case IsNotFound(err):
+		return "not found"
+	case IsErrno(err, KeyExist):
+		return "key exists"
+	case IsErrno(err, BadValSize):
+		return "bad size"
+	}
+	return err.Error()
+}
+
+// fuzzRun runs the operations decoded from the input of FuzzOps.
+type fuzzRun struct {
+	t         *testing.T
+	env       *Env
+	txn       *Txn
+	dbis      []DBI
+	models    []fuzzModel // Content in txn
+	committed []fuzzModel // Content of the last committed transaction
+	in        []byte
+	ops       []string // Operations run, for failure messages
+}
+
+func (r *fuzzRun) next() byte {
+	if len(r.in) == 0 {
+		return 0
+	}
+	b := r.in[0]
+	r.in = r.in[1:]
+	return b
+}
+
+func (r *fuzzRun) logf(format string, args ...interface{}) {
+	r.ops = append(r.ops, fmt.Sprintf(format, args...))
+}
+
+func (r *fuzzRun) fatalf(format string, args ...interface{}) {
+	r.t.Helper()
+	r.t.Fatalf("%s\noperations:\n\t%s", fmt.Sprintf(format, args...), strings.Join(r.ops, "\n\t"))
+}
+
+// expect fails if err is not the predicted result.
+func (r *fuzzRun) expect(err error, want string) {
+	r.t.Helper()
+	if got := fuzzResult(err); got != want {
+		r.fatalf("result %q, expected %q", got, want)
+	}
+}
+
+// expectItem fails if the item returned by an operation is not items[i].
+func (r *fuzzRun) expectItem(items []fuzzItem, i int, key, val []byte, checkKey bool) {
+	r.t.Helper()
+	if (checkKey && string(key) != items[i].key) || string(val) != items[i].val {
+		r.fatalf("item %.10q=%.10q, expected %.10q=%.10q", key, val, items[i].key, items[i].val)
+	}
+}
+
+func (r *fuzzRun) begin() {
+	txn, err := r.env.BeginTxn(nil, 0)
+	if err != nil {
+		r.fatalf("begin: %v", err)
+	}
+	r.txn = txn
+}
+
+func (r *fuzzRun) run() {
+	r.begin()
+	defer func() {
+		if r.txn != nil {
+			r.txn.Abort()
+		}
+	}()
+	for len(r.in) > 0 {
+		i := r.step()
+		if i >= 0 {
+			r.compare(r.txn, i, r.models[i])
+		}
+	}
+	r.commit()
+}
+
+// compare fails if the content of database i in txn is not m.
+func (r *fuzzRun) compare(txn *Txn, i int, m fuzzModel) {
+	r.t.Helper()
+	cur, err := txn.OpenCursor(r.dbis[i])
+	if err != nil {
+		r.fatalf("cursor: %v", err)
+	}
+	defer cur.Close()
+	want := m.items()
+	n := 0
+	for {
+		k, v, err := cur.Get(nil, nil, Next)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			r.fatalf("%s: %v", fuzzDBs[i].name, err)
+		}
+		if n == len(want) || string(k) != want[n].key || string(v) != want[n].val {
+			r.fatalf("%s: item %d is %.10q=%.10q, expected %v", fuzzDBs[i].name, n, k, v, want[n:])
+		}
+		n++
+	}
+	if n != len(want) {
+		r.fatalf("%s: %d items, expected %d", fuzzDBs[i].name, n, len(want))
+	}
+	stat, err := txn.Stat(r.dbis[i])
+	if err != nil {
+		r.fatalf("%s: %v", fuzzDBs[i].name, err)
+	}
+	if stat.Entries != uint64(len(want)) {
+		r.fatalf("%s: %d entries, expected %d", fuzzDBs[i].name, stat.Entries, len(want))
+	}
+}
+
+// commit commits the transaction and checks the content of every database in
+// a new read transaction.
+func (r *fuzzRun) commit() {
+	r.logf("commit")
+	err := r.txn.Commit()
+	r.txn = nil
+	if err != nil {
+		r.fatalf("commit: %v", err)
+	}
+	for i := range r.models {
+		r.committed[i] = r.models[i].clone()
+	}
+	err = r.env.View(func(txn *Txn) error {
+		for i := range r.committed {
+			r.compare(txn, i, r.committed[i])
+		}
+		return nil
+	})
+	if err != nil {
+		r.fatalf("view: %v", err)
+	}
+}
+
+// step runs an operation and returns the database it modified, or -1.
+func (r *fuzzRun) step() int {
+	op := r.next() % 13
+	i := int(r.next()) % len(fuzzDBs)
+	db, dbi, m := &fuzzDBs[i], r.dbis[i], r.models[i]
+	key := fuzzKeys[int(r.next())%len(fuzzKeys)]
+	val := db.val(r.next())
+
+	switch op {
+	case 0, 1:
+		flags := db.puts[int(r.next())%len(db.puts)]
+		r.logf("put %s %.10q=%.10q flags %#x cursor %t", db.name, key, val, flags, op == 1)
+		var err error
+		if op == 0 {
+			err = r.txn.Put(dbi, key, val, flags)
+		} else {
+			err = r.withCursor(dbi, func(cur *Cursor) error {
+				return cur.Put(key, val, flags)
+			})
+		}
+		r.expect(err, r.put(db, m, key, val, flags))
+	case 2, 3:
+		if op == 2 {
+			val = nil
+		}
+		r.logf("del %s %.10q=%.10q", db.name, key, val)
+		err := r.txn.Del(dbi, key, val)
+		want := "ok"
+		switch {
+		case !validKey(key):
+			want = "bad size"
+		case val == nil || !db.dupSort():
+			if len(m[string(key)]) == 0 {
+				want = "not found"
+			}
+			delete(m, string(key))
+		case !m.has(string(key), string(val)):
+			want = "not found"
+		default:
+			m.remove(string(key), string(val))
+		}
+		r.expect(err, want)
+	case 4:
+		r.logf("get %s %.10q", db.name, key)
+		v, err := r.txn.Get(dbi, key)
+		switch {
+		case !validKey(key):
+			r.expect(err, "bad size")
+		case len(m[string(key)]) == 0:
+			r.expect(err, "not found")
+		default:
+			r.expect(err, "ok")
+			if string(v) != m[string(key)][0] {
+				r.fatalf("value %.10q, expected %.10q", v, m[string(key)][0])
+			}
+		}
+		return -1
+	case 5:
+		r.cursorGet(db, dbi, m, key, val)
+		return -1
+	case 6:
+		r.cursorDel(db, dbi, m, key, val)
+	case 7:
+		r.cursorCurrent(db, dbi, m, key, val)
+	case 8:
+		if !db.dupFixed() {
+			return -1
+		}
+		r.putMulti(dbi, m, key, int(r.next())*4, 1+int(r.next())*4, r.next()%2 == 1)
+	case 9:
+		if !db.dupFixed() {
+			return -1
+		}
+		r.logf("getdupfixed %s %.10q", db.name, key)
+		var vals [][4]byte
+		err := r.withCursor(dbi, func(cur *Cursor) (err error) {
+			vals, err = GetDupFixed[[4]byte](cur, key)
+			return err
+		})
+		switch {
+		case !validKey(key):
+			r.expect(err, "bad size")
+		case len(m[string(key)]) == 0:
+			r.expect(err, "not found")
+		default:
+			r.expect(err, "ok")
+			want := m[string(key)]
+			if len(vals) != len(want) {
+				r.fatalf("%d values, expected %d", len(vals), len(want))
+			}
+			for j := range vals {
+				if string(vals[j][:]) != want[j] {
+					r.fatalf("value %d is %x, expected %x", j, vals[j], want[j])
+				}
+			}
+		}
+		return -1
+	case 10, 11:
+		if op == 10 {
+			r.commit()
+		} else {
+			r.logf("abort")
+			r.txn.Abort()
+			r.txn = nil
+			for i := range r.models {
+				r.models[i] = r.committed[i].clone()
+			}
+		}
+		r.begin()
+		return -1
+	case 12:
+		r.txn.RawRead = !r.txn.RawRead
+		r.logf("rawread %t", r.txn.RawRead)
+		return -1
+	}
+	return i
+}
+
+func (r *fuzzRun) withCursor(dbi DBI, fn func(cur *Cursor) error) error {
+	cur, err := r.txn.OpenCursor(dbi)
+	if err != nil {
+		r.fatalf("cursor: %v", err)
+	}
+	defer cur.Close()
+	return fn(cur)
+}
+
+// put returns the predicted result of a put and updates m.
+func (r *fuzzRun) put(db *fuzzDB, m fuzzModel, key, val []byte, flags uint) string {
+	k, v := string(key), string(val)
+	vals := m[k]
+	switch {
+	case !validKey(key):
+		return "bad size"
+	case flags&NoOverwrite != 0 && len(vals) > 0:
+		return "key exists"
+	case flags&NoDupData != 0 && m.has(k, v):
+		return "key exists"
+	case flags&AppendDup != 0 && len(vals) > 0 && v <= vals[len(vals)-1]:
+		return "key exists"
+	case flags&Append != 0:
+		for other := range m {
+			if other >= k {
+				return "key exists"
+			}
+		}
+	}
+	if db.dupSort() {
+		m.add(k, v)
+	} else {
+		m[k] = []string{v}
+	}
+	return "ok"
+}
+
+// position returns the predicted result of positioning a cursor with op and
+// the index of the item it is positioned at.
+func position(items []fuzzItem, op uint, key, val []byte) (string, int) {
+	if op != First && op != Last && !validKey(key) {
+		return "bad size", -1
+	}
+	k, v := string(key), string(val)
+	for i, it := range items {
+		var ok bool
+		switch op {
+		case First:
+			ok = true
+		case Last:
+			ok = i == len(items)-1
+		case Set, SetKey:
+			ok = it.key == k
+		case SetRange:
+			ok = it.key >= k
+		case GetBoth:
+			ok = it.key == k && it.val == v
+		case GetBothRange:
+			ok = it.key == k && it.val >= v
+		}
+		if ok {
+			return "ok", i
+		}
+	}
+	return "not found", -1
+}
+
+// move returns the index of the item a cursor at item i moves to with op, or
+// -1.
+func move(items []fuzzItem, i int, op uint) int {
+	first, last := i, i
+	for first > 0 && items[first-1].key == items[i].key {
+		first--
+	}
+	for last < len(items)-1 && items[last+1].key == items[i].key {
+		last++
+	}
+	j := -1
+	switch op {
+	case GetCurrent:
+		j = i
+	case Next:
+		j = i + 1
+	case Prev:
+		j = i - 1
+	case NextDup:
+		if i < last {
+			j = i + 1
+		}
+	case PrevDup:
+		if i > first {
+			j = i - 1
+		}
+	case NextNoDup:
+		j = last + 1
+	case PrevNoDup:
+		j = first - 1
+	case FirstDup:
+		j = first
+	case LastDup:
+		j = last
+	}
+	if j >= len(items) {
+		return -1
+	}
+	return j
+}
+
+var (
+	fuzzPositionOps = []uint{First, Last, Set, SetKey, SetRange}
+	fuzzDupOps      = []uint{GetBoth, GetBothRange}
+	fuzzMoveOps     = []uint{GetCurrent, Next, Prev, NextNoDup, PrevNoDup}
+	fuzzDupMoveOps  = []uint{NextDup, PrevDup, FirstDup, LastDup}
+)
+
+// cursorGet positions a cursor and moves it by a few items.
+func (r *fuzzRun) cursorGet(db *fuzzDB, dbi DBI, m fuzzModel, key, val []byte) {
+	positions, moves := fuzzPositionOps, fuzzMoveOps
+	if db.dupSort() {
+		positions = append(positions[:len(positions):len(positions)], fuzzDupOps...)
+		moves = append(moves[:len(moves):len(moves)], fuzzDupMoveOps...)
+	}
+	op := positions[int(r.next())%len(positions)]
+	n := int(r.next()) % 4
+	ops := make([]uint, n)
+	for j := range ops {
+		ops[j] = moves[int(r.next())%len(moves)]
+	}
+	r.logf("cursor %s %.10q=%.10q op %d moves %v", db.name, key, val, op, ops)
+
+	items := m.items()
+	err := r.withCursor(dbi, func(cur *Cursor) error {
+		var setkey, setval []byte
+		switch op {
+		case Set, SetKey, SetRange:
+			setkey = key
+		case GetBoth, GetBothRange:
+			setkey, setval = key, val
+		}
+		k, v, err := cur.Get(setkey, setval, op)
+		want, i := position(items, op, key, val)
+		r.expect(err, want)
+		if err != nil {
+			return nil
+		}
+		// The key is not returned when positioning on a duplicate.
+		r.expectItem(items, i, k, v, op != GetBoth && op != GetBothRange)
+		for _, op := range ops {
+			k, v, err = cur.Get(nil, nil, op)
+			j := move(items, i, op)
+			if j < 0 {
+				r.expect(err, "not found")
+				return nil
+			}
+			r.expect(err, "ok")
+			r.expectItem(items, j, k, v, op != FirstDup && op != LastDup)
+			i = j
+		}
+		return nil
+	})
+	if err != nil {
+		r.fatalf("%v", err)
+	}
+}
+
+// cursorDel deletes the first value of key, val or all values of key with a
+// cursor.
+func (r *fuzzRun) cursorDel(db *fuzzDB, dbi DBI, m fuzzModel, key, val []byte) {
+	op, flags := uint(Set), uint(0)
+	if db.dupSort() {
+		op = []uint{Set, GetBoth}[r.next()%2]
+		flags = []uint{0, NoDupData}[r.next()%2]
+	}
+	r.logf("cursordel %s %.10q=%.10q op %d flags %#x", db.name, key, val, op, flags)
+	items := m.items()
+	err := r.withCursor(dbi, func(cur *Cursor) error {
+		setval := val
+		if op == Set {
+			setval = nil
+		}
+		_, _, err := cur.Get(key, setval, op)
+		want, i := position(items, op, key, val)
+		r.expect(err, want)
+		if err != nil {
+			return nil
+		}
+		r.expect(cur.Del(flags), "ok")
+		if flags&NoDupData != 0 {
+			delete(m, items[i].key)
+		} else {
+			m.remove(items[i].key, items[i].val)
+		}
+		return nil
+	})
+	if err != nil {
+		r.fatalf("%v", err)
+	}
+}
+
+// cursorCurrent replaces the first value of key with the Current flag.  A
+// duplicate can only be replaced by a value which sorts the same, which LMDB
+// does not check, so duplicates are replaced by themselves.
+func (r *fuzzRun) cursorCurrent(db *fuzzDB, dbi DBI, m fuzzModel, key, val []byte) {
+	r.logf("current %s %.10q=%.10q", db.name, key, val)
+	items := m.items()
+	err := r.withCursor(dbi, func(cur *Cursor) error {
+		_, _, err := cur.Get(key, nil, Set)
+		want, i := position(items, Set, key, nil)
+		r.expect(err, want)
+		if err != nil {
+			return nil
+		}
+		k := items[i].key
+		if len(m[k]) > 1 {
+			val = []byte(items[i].val)
+		}
+		r.expect(cur.Put(key, val, Current), "ok")
+		m[k][0] = string(val)
+		return nil
+	})
+	if err != nil {
+		r.fatalf("%v", err)
+	}
+}
+
+// putMulti writes n consecutive DupFixed values from the start-th with
+// PutMulti or PutMultiSlices.
+func (r *fuzzRun) putMulti(dbi DBI, m fuzzModel, key []byte, start, n int, slices bool) {
+	r.logf("putmulti %.10q values %d-%d slices %t", key, start, start+n-1, slices)
+	vals := make([][]byte, n)
+	var page []byte
+	for j := range vals {
+		vals[j] = fuzzFixed(start + j)
+		page = append(page, vals[j]...)
+	}
+	err := r.withCursor(dbi, func(cur *Cursor) error {
+		if slices {
+			return cur.PutMultiSlices(key, vals, 0)
+		}
+		return cur.PutMulti(key, page, 4, 0)
+	})
+	if !validKey(key) {
+		r.expect(err, "bad size")
+		return
+	}
+	r.expect(err, "ok")
+	if cur := m[string(key)]; len(cur) == 1 && cur[0] == string(vals[0]) {
+		// LMDB stops after the first value when it replaces the single
+		// value of key.
+		return
+	}
+	for _, v := range vals {
+		m.add(string(key), string(v))
+	}
+}
+
+// FuzzOps runs sequences of operations decoded from its input on a plain, a
+// DupSort and a DupFixed database, and checks their results and the content
+// of the databases after every operation against a model.  Every operation
+// is decoded from four bytes, the operation, the database, the key and the
+// value, followed by bytes for the flags and arguments of some operations.
+func FuzzOps(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{
+		0, 0, 1, 2, 0, // put plain a=v
+		0, 0, 1, 3, 1, // put plain a=vw no overwrite
+		0, 0, 6, 6, 0, // put plain max key
+		1, 0, 3, 1, 2, // cursor put plain b= append
+		4, 0, 1, 0, // get plain a
+		3, 0, 2, 0, // del plain ab
+		10, 0, 0, 0, // commit
+		0, 0, 7, 0, 0, // put plain too long key
+		5, 0, 4, 0, 4, 3, 1, 2, 4, // set range plain ba, next, prev, prev no dup
+		7, 0, 1, 5, // current plain a
+		12, 0, 0, 0, // raw read
+		6, 0, 6, 0, // cursor del plain max key
+		2, 0, 0, 0, // del plain empty key
+	})
+	f.Add([]byte{
+		0, 1, 1, 0, 0, // put dupsort a=v
+		0, 1, 1, 2, 0, // put dupsort a=w
+		0, 1, 1, 4, 0, // put dupsort a=zz..
+		0, 1, 1, 2, 2, // put dupsort a=w no dup data
+		1, 1, 1, 1, 3, // cursor put dupsort a=vw append dup
+		0, 1, 3, 3, 1, // put dupsort b=x no overwrite
+		5, 1, 1, 1, 6, 3, 5, 6, 8, // get both range a=vw, next dup, prev dup, last dup
+		5, 1, 3, 0, 1, 3, 2, 4, 7, // last, prev, prev no dup, first dup
+		7, 1, 1, 0, // current a=v
+		7, 1, 3, 0, // current b=v
+		11, 1, 1, 0, // abort
+		6, 1, 1, 2, 1, 0, // cursor del get both a=w
+		3, 1, 1, 4, // del dupsort a=zz..
+		2, 1, 1, 0, // del dupsort a
+	})
+	f.Add([]byte{
+		0, 2, 1, 3, 0, // put dupfixed a=12
+		8, 2, 1, 0, 0, 200, 0, // put multi a 0-800
+		8, 2, 2, 0, 100, 255, 1, // put multi slices ab 400-1420
+		9, 2, 1, 0, // get dup fixed a
+		0, 2, 1, 255, 3, // put dupfixed a=1020 append dup
+		5, 2, 2, 50, 3, 3, 6, 5, 7, // set key ab, prev dup, next dup, first dup
+		6, 2, 1, 10, 1, 1, // cursor del all of a
+		10, 2, 0, 0, // commit
+		9, 2, 2, 0, // get dup fixed ab
+		2, 2, 2, 0, // del dupfixed ab
+	})
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		env := setup(t)
+		defer clean(env, t)
+		if env.MaxKeySize() != fuzzMaxKeySize {
+			t.Skipf("maximum key size %d", env.MaxKeySize())
+		}
+		r := &fuzzRun{t: t, env: env, in: in}
+		err := env.Update(func(txn *Txn) error {
+			for _, db := range fuzzDBs {
+				dbi, err := txn.OpenDBI(db.name, Create|db.flags)
+				if err != nil {
+					return err
+				}
+				r.dbis = append(r.dbis, dbi)
+				r.models = append(r.models, fuzzModel{})
+				r.committed = append(r.committed, fuzzModel{})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		r.run()
+	})
+}
+
+// FuzzPutGet stores a key and a value in a plain and a DupSort database, then
+// reads and deletes them, with Txn and Cursor methods.
+func FuzzPutGet(f *testing.F) {
+	f.Add([]byte("k"), []byte("v"))
+	f.Add([]byte{}, []byte("v"))
+	f.Add([]byte("k"), []byte{})
+	f.Add([]byte{0}, []byte{0, 0})
+	f.Add(bytes.Repeat([]byte("k"), fuzzMaxKeySize), bytes.Repeat([]byte("v"), fuzzMaxKeySize))
+	f.Add(bytes.Repeat([]byte("k"), fuzzMaxKeySize+1), []byte("v"))
+	f.Add([]byte("k"), bytes.Repeat([]byte("v"), fuzzMaxKeySize+1))
+
+	env := setup(f)
+	defer clean(env, f)
+	if env.MaxKeySize() != fuzzMaxKeySize {
+		f.Skipf("maximum key size %d", env.MaxKeySize())
+	}
+	var plain, dups DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		plain, err = txn.OpenDBI("plain", Create)
+		if err != nil {
+			return err
+		}
+		dups, err = txn.OpenDBI("dupsort", Create|DupSort)
+		return err
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, key, val []byte) {
+		// LMDB stores duplicates as keys of a sub-database.  An empty
+		// duplicate can be stored but not found by its value.
+		dupOK := len(val) > 0 && len(val) <= fuzzMaxKeySize
+		want := func(err error, want string) {
+			t.Helper()
+			if got := fuzzResult(err); got != want {
+				t.Fatalf("key %.10q value %.10q: %q, expected %q", key, val, got, want)
+			}
+		}
+		expectVal := func(v []byte) {
+			t.Helper()
+			if !bytes.Equal(v, val) {
+				t.Fatalf("key %.10q: value %.10q, expected %.10q", key, v, val)
+			}
+		}
+		err := env.Update(func(txn *Txn) error {
+			err := txn.Put(plain, key, val, 0)
+			if !validKey(key) {
+				want(err, "bad size")
+				_, err = txn.Get(plain, key)
+				want(err, "bad size")
+				want(txn.Put(dups, key, val, 0), "bad size")
+				return nil
+			}
+			want(err, "ok")
+			v, err := txn.Get(plain, key)
+			want(err, "ok")
+			expectVal(v)
+
+			cur, err := txn.OpenCursor(plain)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+			k, v, err := cur.Get(key, nil, SetKey)
+			want(err, "ok")
+			expectVal(v)
+			if !bytes.Equal(k, key) {
+				t.Fatalf("key %.10q, expected %.10q", k, key)
+			}
+			want(cur.Put(key, val, NoOverwrite), "key exists")
+			want(cur.Del(0), "ok")
+			_, err = txn.Get(plain, key)
+			want(err, "not found")
+
+			err = txn.Put(dups, key, val, 0)
+			if len(val) > fuzzMaxKeySize {
+				want(err, "bad size")
+				return nil
+			}
+			want(err, "ok")
+			if dupOK {
+				want(txn.Put(dups, key, val, NoDupData), "key exists")
+				dcur, err := txn.OpenCursor(dups)
+				if err != nil {
+					return err
+				}
+				defer dcur.Close()
+				_, v, err = dcur.Get(key, val, GetBoth)
+				want(err, "ok")
+				expectVal(v)
+				want(txn.Del(dups, key, val), "ok")
+			} else {
+				v, err = txn.Get(dups, key)
+				want(err, "ok")
+				expectVal(v)
+				want(txn.Del(dups, key, nil), "ok")
+			}
+			_, err = txn.Get(dups, key)
+			want(err, "not found")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
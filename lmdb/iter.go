@@ -0,0 +1,77 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdb
+
+import "iter"
+
+// All returns an iterator over the items of the database of c in order,
+// starting from the first item.  Iteration moves c, which must not be used
+// otherwise until the loop terminates.  Breaking out of the loop leaves c
+// positioned at the last item yielded.
+//
+// Keys and values yielded follow the same rules regarding c.Txn().RawRead as
+// slices returned by Get.  If iteration stops because of an error other than
+// NotFound the error is stored in *errp, which must not be nil.
+//
+//	var err error
+//	for k, v := range cur.All(&err) {
+//		...
+//	}
+//	if err != nil {
+//		return err
+//	}
+func (c *Cursor) All(errp *error) iter.Seq2[[]byte, []byte] {
+	return c.Range(nil, nil, errp)
+}
+
+// Range returns an iterator over the items of the database of c with keys in
+// the half-open interval [start, end), as ordered by the database's
+// comparison function.  An empty start iterates from the first item and an
+// empty end iterates through the last item.  Range otherwise behaves like All.
+func (c *Cursor) Range(start, end []byte, errp *error) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		*errp = nil
+		var k, v []byte
+		var err error
+		if len(start) == 0 {
+			k, v, err = c.Get(nil, nil, First)
+		} else {
+			k, v, err = c.Get(start, nil, SetRange)
+		}
+		for err == nil {
+			if len(end) != 0 && c.txn.Cmp(c.DBI(), k, end) >= 0 {
+				return
+			}
+			if !yield(k, v) {
+				return
+			}
+			k, v, err = c.Get(nil, nil, Next)
+		}
+		if !IsNotFound(err) {
+			*errp = err
+		}
+	}
+}
+
+// Range returns an iterator over the items of dbi with keys in [start, end)
+// like Cursor.Range.  Each iteration opens a cursor which is closed when the
+// loop terminates, including when breaking out of it.  The iterator must only
+// be used while txn is active.  Errors, including failure to open the cursor,
+// are stored in *errp, which must not be nil.
+//
+//	var err error
+//	for k, v := range txn.Range(dbi, []byte("a"), []byte("b"), &err) {
+//		...
+//	}
+func (txn *Txn) Range(dbi DBI, start, end []byte, errp *error) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			*errp = err
+			return
+		}
+		defer cur.Close()
+		cur.Range(start, end, errp)(yield)
+	}
+}
@@ -0,0 +1,99 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTxn_Range(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			err = txn.Put(dbi, []byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprint(i)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		start, end string
+		keys       []string
+	}{
+		{"", "", []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9"}},
+		{"k3", "k6", []string{"k3", "k4", "k5"}},
+		{"k35", "", []string{"k4", "k5", "k6", "k7", "k8", "k9"}},
+		{"", "k2", []string{"k0", "k1"}},
+		{"z", "", nil},
+	} {
+		var keys []string
+		err = env.View(func(txn *Txn) (err error) {
+			for k, v := range txn.Range(dbi, []byte(test.start), []byte(test.end), &err) {
+				if string(v) != string(k[1:]) {
+					t.Errorf("key %q: value %q", k, v)
+				}
+				keys = append(keys, string(k))
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(test.keys) {
+			t.Errorf("[%q, %q): keys %q (!= %q)", test.start, test.end, keys, test.keys)
+		}
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		var n int
+		for range cur.All(&err) {
+			n++
+			if n == 4 {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		k, _, err := cur.Get(nil, nil, GetCurrent)
+		if err != nil {
+			return err
+		}
+		if string(k) != "k3" {
+			t.Errorf("cursor at %q after break (!= %q)", k, "k3")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		for range txn.Range(123, nil, nil, &err) {
+			t.Error("loop should not execute")
+		}
+		return err
+	})
+	if err == nil {
+		t.Error("expected error ranging over an invalid dbi")
+	}
+}
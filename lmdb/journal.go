@@ -0,0 +1,188 @@
+package lmdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Intent is a summary of the changes a write transaction was attempting to
+// commit.  Intents are recorded in a journal file before each commit when
+// Env.SetIntentJournal has been called, and cleared once the commit returns.
+// An Intent found in a journal after a crash describes the transaction that
+// was in progress when the process died.
+//
+// LMDB itself guarantees that the environment is consistent after a crash.
+// The journal only exists to aid in post-mortem investigations.
+type Intent struct {
+	PID      int       `json:"pid"`
+	Time     time.Time `json:"time"`
+	Puts     uint64    `json:"puts"`
+	Dels     uint64    `json:"dels"`
+	Drops    uint64    `json:"drops"`
+	DBIs     []DBI     `json:"dbis"`
+	FirstKey []byte    `json:"first_key,omitempty"` // The lowest key written or deleted
+	LastKey  []byte    `json:"last_key,omitempty"`  // The highest key written or deleted
+}
+
+// txnIntent accumulates an Intent over the lifetime of a write transaction.
+// Subtransactions share the intent of their parent.
+type txnIntent struct {
+	Intent
+	dbis map[DBI]struct{}
+}
+
+func (in *txnIntent) touch(dbi DBI, key []byte) {
+	if in.dbis == nil {
+		in.dbis = make(map[DBI]struct{})
+	}
+	in.dbis[dbi] = struct{}{}
+	if len(key) == 0 {
+		return
+	}
+	if in.FirstKey == nil || bytes.Compare(key, in.FirstKey) < 0 {
+		in.FirstKey = append([]byte(nil), key...)
+	}
+	if in.LastKey == nil || bytes.Compare(key, in.LastKey) > 0 {
+		in.LastKey = append([]byte(nil), key...)
+	}
+}
+
+func (in *txnIntent) put(dbi DBI, key []byte) {
+	in.Puts++
+	in.touch(dbi, key)
+}
+
+func (in *txnIntent) del(dbi DBI, key []byte) {
+	in.Dels++
+	in.touch(dbi, key)
+}
+
+func (in *txnIntent) drop(dbi DBI) {
+	in.Drops++
+	in.touch(dbi, nil)
+}
+
+// intent returns the intent of the cursor's transaction.  A closed cursor has
+// no transaction.
+func (c *Cursor) intent() *txnIntent {
+	if c.txn == nil {
+		return nil
+	}
+	return c.txn.intent
+}
+
+// intentJournal is the side file that intents are written to.  Only one write
+// transaction may be active at a time but the mutex guards against a
+// concurrent call to Env.SetIntentJournal or Env.Close.
+type intentJournal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openIntentJournal(path string) (*intentJournal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Truncate(0)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &intentJournal{f: f}, nil
+}
+
+func (j *intentJournal) record(in *txnIntent) error {
+	in.PID = os.Getpid()
+	in.Time = time.Now()
+	in.DBIs = in.DBIs[:0]
+	for dbi := range in.dbis {
+		in.DBIs = append(in.DBIs, dbi)
+	}
+	sort.Slice(in.DBIs, func(i, k int) bool { return in.DBIs[i] < in.DBIs[k] })
+
+	p, err := json.Marshal(&in.Intent)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	_, err = j.f.WriteAt(append(p, '\n'), 0)
+	return err
+}
+
+func (j *intentJournal) clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	return j.f.Truncate(0)
+}
+
+func (j *intentJournal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// SetIntentJournal enables intent journaling for write transactions in env.
+// Before each top-level write transaction commits a summary of its changes
+// (see Intent) is written to the file at path, and the file is truncated
+// after the commit returns.  An empty path disables journaling.
+//
+// The journal is not synced to disk.  It survives the death of the process
+// but not necessarily a crash of the operating system.  Any intent left over
+// from a previous process is discarded when SetIntentJournal is called, so
+// ReadIntentJournal should be used beforehand to inspect it.
+//
+// SetIntentJournal must not be called while a write transaction is active.
+func (env *Env) SetIntentJournal(path string) error {
+	var j *intentJournal
+	if path != "" {
+		var err error
+		j, err = openIntentJournal(path)
+		if err != nil {
+			return err
+		}
+	}
+	old := env.journal
+	env.journal = j
+	if old != nil {
+		return old.close()
+	}
+	return nil
+}
+
+// ReadIntentJournal returns the Intent recorded in the journal file at path.
+// If the file is empty, because the last transaction completed its commit,
+// ReadIntentJournal returns nil and no error.
+func ReadIntentJournal(path string) (*Intent, error) {
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p = bytes.TrimSpace(p)
+	if len(p) == 0 {
+		return nil, nil
+	}
+	in := new(Intent)
+	err = json.Unmarshal(p, in)
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
@@ -0,0 +1,91 @@
+package lmdb
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEnv_SetIntentJournal(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpath := filepath.Join(path, "intent.journal")
+	err = env.SetIntentJournal(jpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := ReadIntentJournal(jpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in != nil {
+		t.Errorf("unexpected intent after commit: %#v", in)
+	}
+
+	// simulate a crash by recording the intent of a transaction without
+	// committing it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	for _, k := range []string{"b", "c", "a"} {
+		err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = txn.Del(dbi, []byte("k"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txn.journal.record(txn.intent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err = ReadIntentJournal(jpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in == nil {
+		t.Fatal("no intent recorded")
+	}
+	if in.PID != os.Getpid() {
+		t.Errorf("unexpected pid: %d", in.PID)
+	}
+	if in.Puts != 3 || in.Dels != 1 {
+		t.Errorf("unexpected counts: puts=%d dels=%d", in.Puts, in.Dels)
+	}
+	if len(in.DBIs) != 1 || in.DBIs[0] != dbi {
+		t.Errorf("unexpected dbis: %v", in.DBIs)
+	}
+	if string(in.FirstKey) != "a" || string(in.LastKey) != "k" {
+		t.Errorf("unexpected key range: %q %q", in.FirstKey, in.LastKey)
+	}
+
+	err = env.SetIntentJournal("")
+	if err != nil {
+		t.Error(err)
+	}
+}
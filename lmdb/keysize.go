@@ -0,0 +1,85 @@
+package lmdb
+
+import "fmt"
+
+// maxDataSize is the largest value LMDB accepts, MAXDATASIZE.
+const maxDataSize = 1<<32 - 1
+
+// KeyTooLongError is returned in place of an *OpError with errno BadValSize
+// when a key is longer than Env.MaxKeySize, before calling into LMDB.  Keys
+// of that length can be stored by shortening them, see package
+// exp/lmdbkey.  IsErrno(err, BadValSize) is true for a KeyTooLongError.
+type KeyTooLongError struct {
+	*OpError
+	Len int // Length of the key
+	Max int // Maximum length of keys
+}
+
+func (err *KeyTooLongError) Error() string {
+	return fmt.Sprintf("%s (key of %d bytes, the maximum is %d)", err.OpError.Error(), err.Len, err.Max)
+}
+
+// Unwrap returns the underlying *OpError.
+func (err *KeyTooLongError) Unwrap() error {
+	return err.OpError
+}
+
+// ValTooLongError is returned in place of an *OpError with errno BadValSize
+// when a value is longer than LMDB can store.  IsErrno(err, BadValSize) is
+// true for a ValTooLongError.
+type ValTooLongError struct {
+	*OpError
+	Len int // Length of the value
+}
+
+func (err *ValTooLongError) Error() string {
+	return fmt.Sprintf("%s (value of %d bytes, the maximum is %d)", err.OpError.Error(), err.Len, uint64(maxDataSize))
+}
+
+// Unwrap returns the underlying *OpError.
+func (err *ValTooLongError) Unwrap() error {
+	return err.OpError
+}
+
+// maxKeySize returns the largest key the environment of txn accepts.  The
+// limit is queried from the environment on first use, a system LMDB built with
+// MDB_MAXKEYSIZE=0 derives it from the page size of each environment.
+func (txn *Txn) maxKeySize() int {
+	if txn.maxKey == 0 {
+		txn.maxKey = txn.env.MaxKeySize()
+	}
+	return txn.maxKey
+}
+
+// checkKey returns a *KeyTooLongError if key is too long for op in txn.
+func (txn *Txn) checkKey(op string, key []byte) error {
+	if max := txn.maxKeySize(); len(key) > max {
+		return &KeyTooLongError{
+			OpError: &OpError{Op: op, Errno: BadValSize},
+			Len:     len(key),
+			Max:     max,
+		}
+	}
+	return nil
+}
+
+// checkKey is like Txn.checkKey for the transaction of c.  Keys are not
+// checked for a closed cursor, LMDB rejects the operation.
+func (c *Cursor) checkKey(op string, key []byte) error {
+	if c.txn == nil {
+		return nil
+	}
+	return c.txn.checkKey(op, key)
+}
+
+// checkVal returns a *ValTooLongError if a value of n bytes is too long for
+// op.
+func checkVal(op string, n int) error {
+	if uint64(n) > maxDataSize {
+		return &ValTooLongError{
+			OpError: &OpError{Op: op, Errno: BadValSize},
+			Len:     n,
+		}
+	}
+	return nil
+}
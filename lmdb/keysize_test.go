@@ -0,0 +1,76 @@
+package lmdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyTooLongError(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte("k"), env.MaxKeySize()+1)
+
+	check := func(op string, err error) {
+		t.Helper()
+		kerr, ok := err.(*KeyTooLongError)
+		if !ok {
+			t.Errorf("%s: unexpected error: %v", op, err)
+			return
+		}
+		if kerr.Len != len(key) || kerr.Max != env.MaxKeySize() {
+			t.Errorf("%s: length %d, maximum %d", op, kerr.Len, kerr.Max)
+		}
+		if !IsErrno(err, BadValSize) {
+			t.Errorf("%s: errno is not BadValSize", op)
+		}
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		check("Put", txn.Put(dbi, key, []byte("v"), 0))
+		_, err = txn.PutReserve(dbi, key, 1, 0)
+		check("PutReserve", err)
+		check("Del", txn.Del(dbi, key, nil))
+		_, err = txn.Get(dbi, key)
+		check("Get", err)
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		check("Cursor.Put", cur.Put(key, []byte("v"), 0))
+		_, _, err = cur.Get(key, nil, SetKey)
+		check("Cursor.Get", err)
+
+		// PutMany rejects the batch before storing any pair.
+		n, err := txn.PutMany(dbi, []KV{{[]byte("a"), []byte("v")}, {key, []byte("v")}}, 0)
+		check("PutMany", err)
+		if n != 0 {
+			t.Errorf("PutMany: %d pairs stored", n)
+		}
+		n, err = txn.PutMany(dbi, []KV{{[]byte("a"), []byte("v")}, {nil, []byte("v")}}, 0)
+		if !IsErrno(err, BadValSize) || n != 0 {
+			t.Errorf("PutMany: empty key: %d %v", n, err)
+		}
+
+		// Rejected operations are not counted.
+		if stats := txn.Stats(); stats.Puts != 0 || stats.Dels != 0 || stats.Gets != 0 {
+			t.Errorf("stats: %+v", stats)
+		}
+		_, err = txn.Get(dbi, []byte("a"))
+		if !IsNotFound(err) {
+			t.Errorf("PutMany: stored a pair of a rejected batch: %v", err)
+		}
+
+		// A key of the maximum length is accepted.
+		return txn.Put(dbi, key[1:], []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package lmdb
+
+import (
+	"errors"
+	"runtime"
+)
+
+// ReadLinearizable calls fn with a transaction that observes every write
+// acknowledged before ReadLinearizable was called, for the occasional read
+// that needs stronger guarantees than a snapshot.  The transaction is a brief
+// write transaction, so the read waits for a write transaction in progress,
+// including one whose Update has not returned yet, and no other write can
+// commit while fn runs.  The transaction is aborted after fn returns, writes
+// made by fn are discarded.
+//
+// Reads through ReadLinearizable serialize with all writers of the
+// environment, so they should be rare and fn should be short.  A read-only
+// transaction begun by View after a write has returned already observes the
+// write.  ReadLinearizable requires write access to the environment and
+// returns the error of fn.
+func (env *Env) ReadLinearizable(fn TxnOp) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	txn, err := beginTxn(env, nil, 0)
+	if err != nil {
+		return err
+	}
+	err = txn.runOpTerm(func(txn *Txn) error {
+		err := fn(txn)
+		if err != nil {
+			return err
+		}
+		return errReadLinearizable
+	})
+	if err == errReadLinearizable {
+		err = nil
+	}
+	return err
+}
+
+// errReadLinearizable aborts the transaction of a linearizable read which
+// otherwise succeeded.
+var errReadLinearizable = errors.New("lmdb: linearizable read")
@@ -0,0 +1,78 @@
+package lmdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnv_ReadLinearizable(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A write in progress is observed once it commits, unlike by View.
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- env.Update(func(txn *Txn) error {
+			err := txn.Put(dbi, []byte("k"), []byte("v"), 0)
+			close(locked)
+			<-release
+			return err
+		})
+	}()
+	<-locked
+	err = env.View(func(txn *Txn) error {
+		_, err := txn.Get(dbi, []byte("k"))
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	err = env.ReadLinearizable(func(txn *Txn) error {
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "v" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		return txn.Put(dbi, []byte("k"), []byte("discarded"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes made by fn are discarded.
+	err = env.View(func(txn *Txn) error {
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "v" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errTest := errors.New("test")
+	err = env.ReadLinearizable(func(txn *Txn) error { return errTest })
+	if err != errTest {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
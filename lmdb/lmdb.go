@@ -105,6 +105,14 @@ uses a finalizer to abort unreachable Txn objects.  But of course, applications
 must still be careful not to leak unterminated Txn objects in a way such that
 they fail get garbage collected.
 
+# System library
+
+By default the package compiles the LMDB C library it includes.  With the
+lmdb_system build tag it links the library installed on the system instead,
+which it locates with pkg-config under the name lmdb.  The installed library
+must be an LMDB 0.9 release, whose ABI matches the header included with the
+package.  The pwritev build tag has no effect on the system library.
+
 # Caveats
 
 Write transactions (those created without the Readonly flag) must be created in
@@ -127,8 +135,10 @@ details about dealing with such situations.
 package lmdb
 
 /*
-#cgo CFLAGS: -pthread -W -Wall -Wno-unused-parameter -Wno-format-extra-args -Wbad-function-cast -Wno-missing-field-initializers -Wno-stringop-overflow -Wno-unknown-warning-option -O2 -g
-#cgo linux,pwritev CFLAGS: -DMDB_USE_PWRITEV
+#cgo CFLAGS: -pthread -O2 -g
+#cgo !lmdb_system CFLAGS: -W -Wall -Wno-unused-parameter -Wno-format-extra-args -Wbad-function-cast -Wno-missing-field-initializers -Wno-stringop-overflow -Wno-unknown-warning-option
+#cgo linux,pwritev,!lmdb_system CFLAGS: -DMDB_USE_PWRITEV
+#cgo lmdb_system pkg-config: lmdb
 
 #include "lmdb.h"
 */
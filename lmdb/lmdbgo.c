@@ -1,6 +1,8 @@
 /* lmdbgo.c
  * Helper utilities for github.com/PowerDNS/lmdb-go/lmdb
  * */
+#include <ctype.h>
+#include <string.h>
 #include "lmdb.h"
 #include "lmdbgo.h"
 #include "_cgo_export.h"
@@ -23,9 +25,31 @@ int lmdbgo_mdb_reader_list(MDB_env *env, size_t ctx) {
 	return mdb_reader_list(env, 0, (void *)ctx);
 }
 
+int lmdbgo_mdb_env_set_userctx(MDB_env *env, size_t ctx) {
+    return mdb_env_set_userctx(env, (void *)ctx);
+}
+
+void lmdbgo_assert_proxy(MDB_env *env, const char *msg) {
+    lmdbgo_ConstCString s;
+    s.p = msg;
+    lmdbgoAssertBridge(env, s);
+}
+
+int lmdbgo_mdb_env_set_assert(MDB_env *env, int on) {
+    return mdb_env_set_assert(env, on ? &lmdbgo_assert_proxy : NULL);
+}
+
+size_t lmdbgo_mdb_env_get_userctx(MDB_env *env) {
+    void *ctx = mdb_env_get_userctx(env);
+    return (size_t)ctx;
+}
+
 int lmdbgo_mdb_del(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, char *vdata, size_t vn) {
     MDB_val key, val;
     LMDBGO_SET_VAL(&key, kn, kdata);
+    if (vdata == NULL) {
+        return mdb_del(txn, dbi, &key, NULL);
+    }
     LMDBGO_SET_VAL(&val, vn, vdata);
     return mdb_del(txn, dbi, &key, &val);
 }
@@ -43,6 +67,25 @@ int lmdbgo_mdb_put2(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, char *vda
     return mdb_put(txn, dbi, &key, &val, flags);
 }
 
+int lmdbgo_mdb_putmany(MDB_txn *txn, MDB_dbi dbi, char *data, size_t *sizes, size_t n, unsigned int flags, size_t *count) {
+    // data holds n key-value pairs packed back to back.  sizes holds the
+    // length of each key followed by the length of its value.
+    MDB_val key, val;
+    int rc = MDB_SUCCESS;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        LMDBGO_SET_VAL(&key, sizes[2*i], data);
+        data += sizes[2*i];
+        LMDBGO_SET_VAL(&val, sizes[2*i+1], data);
+        data += sizes[2*i+1];
+        rc = mdb_put(txn, dbi, &key, &val, flags);
+        if (rc != MDB_SUCCESS)
+            break;
+    }
+    *count = i;
+    return rc;
+}
+
 int lmdbgo_mdb_put1(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, MDB_val *val, unsigned int flags) {
     MDB_val key;
     LMDBGO_SET_VAL(&key, kn, kdata);
@@ -70,6 +113,20 @@ int lmdbgo_mdb_cursor_putmulti(MDB_cursor *cur, char *kdata, size_t kn, char *vd
     return mdb_cursor_put(cur, &key, &val[0], flags);
 }
 
+int lmdbgo_mdb_cmp(MDB_txn *txn, MDB_dbi dbi, char *adata, size_t an, char *bdata, size_t bn) {
+    MDB_val a, b;
+    LMDBGO_SET_VAL(&a, an, adata);
+    LMDBGO_SET_VAL(&b, bn, bdata);
+    return mdb_cmp(txn, dbi, &a, &b);
+}
+
+int lmdbgo_mdb_dcmp(MDB_txn *txn, MDB_dbi dbi, char *adata, size_t an, char *bdata, size_t bn) {
+    MDB_val a, b;
+    LMDBGO_SET_VAL(&a, an, adata);
+    LMDBGO_SET_VAL(&b, bn, bdata);
+    return mdb_dcmp(txn, dbi, &a, &b);
+}
+
 int lmdbgo_mdb_cursor_get1(MDB_cursor *cur, char *kdata, size_t kn, MDB_val *key, MDB_val *val, MDB_cursor_op op) {
     LMDBGO_SET_VAL(key, kn, kdata);
     return mdb_cursor_get(cur, key, val, op);
@@ -80,3 +137,107 @@ int lmdbgo_mdb_cursor_get2(MDB_cursor *cur, char *kdata, size_t kn, char *vdata,
     LMDBGO_SET_VAL(val, vn, vdata);
     return mdb_cursor_get(cur, key, val, op);
 }
+
+int lmdbgo_mdb_cursor_getbatch(MDB_cursor *cur, MDB_val *keys, MDB_val *vals, size_t n, MDB_cursor_op op, size_t *count) {
+    int rc = MDB_SUCCESS;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        rc = mdb_cursor_get(cur, &keys[i], &vals[i], op);
+        if (rc != MDB_SUCCESS)
+            break;
+    }
+    *count = i;
+    if (rc == MDB_NOTFOUND && i > 0)
+        return MDB_SUCCESS;
+    return rc;
+}
+
+static int lmdbgo_memcmp(const MDB_val *a, const MDB_val *b) {
+    size_t n = a->mv_size < b->mv_size ? a->mv_size : b->mv_size;
+    int diff = n ? memcmp(a->mv_data, b->mv_data, n) : 0;
+    if (diff)
+        return diff;
+    return a->mv_size < b->mv_size ? -1 : a->mv_size > b->mv_size;
+}
+
+int lmdbgo_cmp_descending(const MDB_val *a, const MDB_val *b) {
+    return lmdbgo_memcmp(b, a);
+}
+
+int lmdbgo_cmp_caseinsensitive(const MDB_val *a, const MDB_val *b) {
+    const unsigned char *pa = a->mv_data, *pb = b->mv_data;
+    size_t n = a->mv_size < b->mv_size ? a->mv_size : b->mv_size;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        int diff = tolower(pa[i]) - tolower(pb[i]);
+        if (diff)
+            return diff;
+    }
+    return a->mv_size < b->mv_size ? -1 : a->mv_size > b->mv_size;
+}
+
+/* lmdbgo_cmp_uintbe compares the leading width bytes of a and b as big-endian
+ * unsigned integers.  Values shorter than width are treated as if they were
+ * padded with zeros on the left.  Ties are broken by comparing the remaining
+ * bytes.
+ * */
+static int lmdbgo_cmp_uintbe(const MDB_val *a, const MDB_val *b, size_t width) {
+    const unsigned char *pa = a->mv_data, *pb = b->mv_data;
+    size_t na = a->mv_size < width ? a->mv_size : width;
+    size_t nb = b->mv_size < width ? b->mv_size : width;
+    unsigned long long ua = 0, ub = 0;
+    size_t i;
+    MDB_val ra, rb;
+    for (i = 0; i < na; i++)
+        ua = (ua << 8) | pa[i];
+    for (i = 0; i < nb; i++)
+        ub = (ub << 8) | pb[i];
+    if (ua != ub)
+        return ua < ub ? -1 : 1;
+    LMDBGO_SET_VAL(&ra, a->mv_size - na, (char *)a->mv_data + na);
+    LMDBGO_SET_VAL(&rb, b->mv_size - nb, (char *)b->mv_data + nb);
+    return lmdbgo_memcmp(&ra, &rb);
+}
+
+int lmdbgo_cmp_uint32be(const MDB_val *a, const MDB_val *b) {
+    return lmdbgo_cmp_uintbe(a, b, 4);
+}
+
+int lmdbgo_cmp_uint64be(const MDB_val *a, const MDB_val *b) {
+    return lmdbgo_cmp_uintbe(a, b, 8);
+}
+
+#define LMDBGO_CMP_SLOT(i) \
+    static int lmdbgo_cmp_slot##i(const MDB_val *a, const MDB_val *b) { \
+        return lmdbgoCompareBridge(i, (MDB_val *)a, (MDB_val *)b); \
+    }
+
+LMDBGO_CMP_SLOT(0)
+LMDBGO_CMP_SLOT(1)
+LMDBGO_CMP_SLOT(2)
+LMDBGO_CMP_SLOT(3)
+LMDBGO_CMP_SLOT(4)
+LMDBGO_CMP_SLOT(5)
+LMDBGO_CMP_SLOT(6)
+LMDBGO_CMP_SLOT(7)
+LMDBGO_CMP_SLOT(8)
+LMDBGO_CMP_SLOT(9)
+LMDBGO_CMP_SLOT(10)
+LMDBGO_CMP_SLOT(11)
+LMDBGO_CMP_SLOT(12)
+LMDBGO_CMP_SLOT(13)
+LMDBGO_CMP_SLOT(14)
+LMDBGO_CMP_SLOT(15)
+
+static MDB_cmp_func *lmdbgo_cmp_slots[LMDBGO_CMP_SLOTS] = {
+    lmdbgo_cmp_slot0, lmdbgo_cmp_slot1, lmdbgo_cmp_slot2, lmdbgo_cmp_slot3,
+    lmdbgo_cmp_slot4, lmdbgo_cmp_slot5, lmdbgo_cmp_slot6, lmdbgo_cmp_slot7,
+    lmdbgo_cmp_slot8, lmdbgo_cmp_slot9, lmdbgo_cmp_slot10, lmdbgo_cmp_slot11,
+    lmdbgo_cmp_slot12, lmdbgo_cmp_slot13, lmdbgo_cmp_slot14, lmdbgo_cmp_slot15,
+};
+
+MDB_cmp_func *lmdbgo_cmp_slot(int i) {
+    if (i < 0 || i >= LMDBGO_CMP_SLOTS)
+        return 0;
+    return lmdbgo_cmp_slots[i];
+}
@@ -21,12 +21,22 @@ int lmdbgo_mdb_del(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, char *vdat
 int lmdbgo_mdb_get(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, MDB_val *val);
 int lmdbgo_mdb_put1(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, MDB_val *val, unsigned int flags);
 int lmdbgo_mdb_put2(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t kn, char *vdata, size_t vn, unsigned int flags);
+int lmdbgo_mdb_putmany(MDB_txn *txn, MDB_dbi dbi, char *data, size_t *sizes, size_t n, unsigned int flags, size_t *count);
 int lmdbgo_mdb_cursor_put1(MDB_cursor *cur, char *kdata, size_t kn, MDB_val *val, unsigned int flags);
 int lmdbgo_mdb_cursor_put2(MDB_cursor *cur, char *kdata, size_t kn, char *vdata, size_t vn, unsigned int flags);
 int lmdbgo_mdb_cursor_putmulti(MDB_cursor *cur, char *kdata, size_t kn, char *vdata, size_t vn, size_t vstride, unsigned int flags);
+int lmdbgo_mdb_cmp(MDB_txn *txn, MDB_dbi dbi, char *adata, size_t an, char *bdata, size_t bn);
+int lmdbgo_mdb_dcmp(MDB_txn *txn, MDB_dbi dbi, char *adata, size_t an, char *bdata, size_t bn);
 int lmdbgo_mdb_cursor_get1(MDB_cursor *cur, char *kdata, size_t kn, MDB_val *key, MDB_val *val, MDB_cursor_op op);
 int lmdbgo_mdb_cursor_get2(MDB_cursor *cur, char *kdata, size_t kn, char *vdata, size_t vn, MDB_val *key, MDB_val *val, MDB_cursor_op op);
 
+/* lmdbgo_mdb_cursor_getbatch calls mdb_cursor_get with op up to n times,
+ * storing the results in the arrays keys and vals.  The number of items
+ * retrieved is stored in count.  If at least one item was retrieved before
+ * the cursor reached the end of the database MDB_SUCCESS is returned.
+ * */
+int lmdbgo_mdb_cursor_getbatch(MDB_cursor *cur, MDB_val *keys, MDB_val *vals, size_t n, MDB_cursor_op op, size_t *count);
+
 /* ConstCString wraps a null-terminated (const char *) because Go's type system
  * does not represent the 'cosnt' qualifier directly on a function argument and
  * causes warnings to be emitted during linking.
@@ -39,4 +49,34 @@ typedef struct{ const char *p; } lmdbgo_ConstCString;
  * */
 int lmdbgo_mdb_reader_list(MDB_env *env, size_t ctx);
 
+/* lmdbgo_mdb_env_set_userctx and lmdbgo_mdb_env_get_userctx store an integer
+ * key into the Go registry of user context values in place of the pointer
+ * taken by mdb_env_set_userctx.
+ * */
+int lmdbgo_mdb_env_set_userctx(MDB_env *env, size_t ctx);
+size_t lmdbgo_mdb_env_get_userctx(MDB_env *env);
+
+/* lmdbgo_mdb_env_set_assert installs lmdbgo_assert_proxy as the assertion
+ * callback of env if on is non-zero, or removes it.  The proxy relays the
+ * message to the exported Go func lmdbgoAssertBridge.
+ * */
+int lmdbgo_mdb_env_set_assert(MDB_env *env, int on);
+void lmdbgo_assert_proxy(MDB_env *env, const char *msg);
+
+/* Built-in comparison functions that may be passed to mdb_set_compare and
+ * mdb_set_dupsort.
+ * */
+int lmdbgo_cmp_descending(const MDB_val *a, const MDB_val *b);
+int lmdbgo_cmp_caseinsensitive(const MDB_val *a, const MDB_val *b);
+int lmdbgo_cmp_uint32be(const MDB_val *a, const MDB_val *b);
+int lmdbgo_cmp_uint64be(const MDB_val *a, const MDB_val *b);
+
+/* Comparison functions implemented in Go are called through a fixed number of
+ * static slots because MDB_cmp_func takes no context argument.
+ * lmdbgo_cmp_slot returns the function for slot i, which relays comparisons
+ * to the exported Go func lmdbgoCompareBridge.
+ * */
+#define LMDBGO_CMP_SLOTS 16
+MDB_cmp_func *lmdbgo_cmp_slot(int i);
+
 #endif
@@ -0,0 +1,44 @@
+package lmdb
+
+import "time"
+
+// Logger receives the operational events of an environment as structured log
+// records, a message followed by alternating keys and values.  *slog.Logger
+// from the standard library implements Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// SetLogger sets the logger receiving the operational events of env, which
+// are logged at the levels
+//
+//	Info   opening and closing env, setting the map size, reader checks that
+//	       cleared stale entries
+//	Debug  calls to Sync, with their duration
+//	Warn   commits of write transactions taking at least slowCommit, write
+//	       transactions starved of the writer lock, see SetStarvationCheck
+//	Error  failures of the operations above
+//
+// A zero slowCommit disables logging slow commits and a nil l disables
+// logging.  SetLogger should be called before Open for the opening to be
+// logged, and must not be called while transactions are active.
+func (env *Env) SetLogger(l Logger, slowCommit time.Duration) {
+	env.logger = l
+	env.slowCommit = slowCommit
+}
+
+// logResult logs the outcome of op at level Info, or Error if err is not nil.
+func (env *Env) logResult(msg string, err error, args ...interface{}) {
+	l := env.logger
+	if l == nil {
+		return
+	}
+	if err != nil {
+		l.Error(msg+" failed", append(args, "err", err)...)
+		return
+	}
+	l.Info(msg, args...)
+}
@@ -0,0 +1,85 @@
+package lmdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *testLogger) log(level, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, fmt.Sprintf("%s %s %v", level, msg, args))
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args...) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args...) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args...) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args...) }
+
+func TestEnv_SetLogger(t *testing.T) {
+	path, err := ioutil.TempDir("", "mdb_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	env, err := NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &testLogger{}
+	env.SetLogger(l, time.Nanosecond)
+	err = env.Open(path, 0, 0644)
+	if err != nil {
+		env.Close()
+		t.Fatal(err)
+	}
+	err = env.SetMapSize(32 << 20)
+	if err != nil {
+		t.Error(err)
+	}
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	err = env.Update(func(txn *Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	err = env.Sync(true)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = env.ReaderCheck()
+	if err != nil {
+		t.Error(err)
+	}
+	env.Close()
+
+	expect := []string{
+		"INFO lmdb: open environment",
+		"INFO lmdb: set map size",
+		"WARN lmdb: slow commit",
+		"DEBUG lmdb: sync",
+		"INFO lmdb: close environment",
+	}
+	var msgs []string
+	for _, r := range l.records {
+		msgs = append(msgs, r[:strings.Index(r, " [")])
+	}
+	if strings.Join(msgs, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("unexpected records:\n%s", strings.Join(l.records, "\n"))
+	}
+}
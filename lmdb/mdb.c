@@ -1,3 +1,6 @@
+//go:build !lmdb_system
+// +build !lmdb_system
+
 /** @file mdb.c
  *	@brief Lightning memory-mapped database library
  *
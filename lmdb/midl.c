@@ -1,3 +1,6 @@
+//go:build !lmdb_system
+// +build !lmdb_system
+
 /**	@file midl.c
  *	@brief ldap bdb back-end ID List functions */
 /* $OpenLDAP$ */
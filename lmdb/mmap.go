@@ -0,0 +1,135 @@
+package lmdb
+
+import (
+	"errors"
+	"sync"
+)
+
+// errMmapUnsupported is returned by Env.Mmap on platforms where it is not
+// implemented.
+var errMmapUnsupported = errors.New("lmdb: Mmap is not supported on this platform")
+
+// ErrMmapInvalid is returned by the methods of an MmapView once its mapping
+// has been released.
+var ErrMmapInvalid = errors.New("lmdb: view of the data file is no longer valid")
+
+// envMmap tracks the views returned by Env.Mmap so that Close, SetMapSize and
+// ShrinkInPlace can release them.
+type envMmap struct {
+	mu    sync.Mutex
+	views map[*MmapView]struct{}
+}
+
+// MmapView is a read-only view of the environment's data file returned by
+// Env.Mmap.  The view is released by its Close method and by the Close,
+// SetMapSize and ShrinkInPlace methods of the environment, after which Bytes
+// and Read return ErrMmapInvalid.  An MmapView is safe for concurrent use.
+type MmapView struct {
+	env *Env
+	mu  sync.RWMutex
+	b   []byte
+}
+
+// Mmap returns a read-only view of the environment's data file, mapped into
+// memory separately from the map used by LMDB.  The view covers the pages in
+// use when Mmap is called, (EnvInfo.LastPNO+1)*Stat.PSize bytes, all of which
+// are backed by the file.  Pages allocated later are not part of the view,
+// call Mmap again to see them.  Modifying the mapped bytes will crash the
+// program.
+//
+// The view is intended for specialized consumers, such as page analyzers or
+// zero-copy decoders, that need to read many pages without calling into C for
+// each value.  Data in the view is not protected by a transaction.  Pages may
+// be reused by writers as soon as no reader holds a snapshot referencing
+// them, so consumers must keep a read-only transaction open while they read
+// the view and must only follow page numbers reachable from that snapshot.
+//
+// Each call to Mmap creates a new view, which should be released with its
+// Close method once it is no longer needed.
+func (env *Env) Mmap() (*MmapView, error) {
+	// The lock is held so that a concurrent SetMapSize, ShrinkInPlace or
+	// Close cannot miss the new view.
+	env.mmap.mu.Lock()
+	defer env.mmap.mu.Unlock()
+	fd, err := env.FD()
+	if err != nil {
+		return nil, err
+	}
+	info, err := env.Info()
+	if err != nil {
+		return nil, err
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		return nil, err
+	}
+	b, err := mmapFile(fd, (info.LastPNO+1)*int64(stat.PSize))
+	if err != nil {
+		return nil, err
+	}
+	v := &MmapView{env: env, b: b}
+	if env.mmap.views == nil {
+		env.mmap.views = make(map[*MmapView]struct{})
+	}
+	env.mmap.views[v] = struct{}{}
+	return v, nil
+}
+
+// Bytes returns the mapped bytes of v, or ErrMmapInvalid if v has been
+// released.  The returned slice must not be used after v is released, use Read
+// when v may be released concurrently.
+func (v *MmapView) Bytes() ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.b == nil {
+		return nil, ErrMmapInvalid
+	}
+	return v.b, nil
+}
+
+// Read calls fn with the mapped bytes of v and returns its error, or returns
+// ErrMmapInvalid if v has been released.  The view is not released while fn
+// runs, releasing it waits for fn to return.  So fn must not retain b and must
+// not call the Close, SetMapSize or ShrinkInPlace methods of the environment.
+func (v *MmapView) Read(fn func(b []byte) error) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.b == nil {
+		return ErrMmapInvalid
+	}
+	return fn(v.b)
+}
+
+// Close releases the mapping of v.  Close may be called after the view has
+// been released by the environment.
+func (v *MmapView) Close() error {
+	v.env.mmap.mu.Lock()
+	delete(v.env.mmap.views, v)
+	v.env.mmap.mu.Unlock()
+	return v.release()
+}
+
+func (v *MmapView) release() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.b == nil {
+		return nil
+	}
+	err := munmapFile(v.b)
+	v.b = nil
+	return err
+}
+
+// unmap releases the views created by Mmap.
+func (env *Env) unmap() error {
+	env.mmap.mu.Lock()
+	defer env.mmap.mu.Unlock()
+	var err error
+	for v := range env.mmap.views {
+		if e := v.release(); e != nil && err == nil {
+			err = e
+		}
+	}
+	env.mmap.views = nil
+	return err
+}
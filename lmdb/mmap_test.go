@@ -0,0 +1,106 @@
+package lmdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEnv_Mmap(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := []byte("a distinctive value stored in the map")
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), val, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v *MmapView
+	err = env.View(func(txn *Txn) (err error) {
+		v, err = env.Mmap()
+		if err != nil {
+			return err
+		}
+		m, err := v.Bytes()
+		if err != nil {
+			return err
+		}
+		if size := (info.LastPNO + 1) * int64(stat.PSize); int64(len(m)) != size {
+			t.Errorf("unexpected map length: %d (!= %d)", len(m), size)
+		}
+		if !bytes.Contains(m, val) {
+			t.Errorf("value not found in map")
+		}
+		return v.Read(func(b []byte) error {
+			if &b[0] != &m[0] {
+				t.Errorf("expected Read and Bytes to share the mapping")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2, err := env.Mmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v2.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v2.Bytes(); err != ErrMmapInvalid {
+		t.Errorf("unexpected error after Close: %v", err)
+	}
+	if _, err := v.Bytes(); err != nil {
+		t.Errorf("view released by closing another: %v", err)
+	}
+
+	// Resizing the map releases the views.
+	err = env.SetMapSize(2 * info.MapSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Bytes(); err != ErrMmapInvalid {
+		t.Errorf("unexpected error after SetMapSize: %v", err)
+	}
+	err = v.Read(func(b []byte) error {
+		t.Errorf("Read called fn after SetMapSize")
+		return nil
+	})
+	if err != ErrMmapInvalid {
+		t.Errorf("unexpected error from Read: %v", err)
+	}
+	err = v.Close()
+	if err != nil {
+		t.Errorf("close of a released view: %v", err)
+	}
+
+	v, err = env.Mmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := v.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(m, val) {
+		t.Errorf("value not found in map after resize")
+	}
+}
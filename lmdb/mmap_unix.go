@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package lmdb
+
+import "syscall"
+
+func mmapFile(fd uintptr, size int64) ([]byte, error) {
+	return syscall.Mmap(int(fd), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
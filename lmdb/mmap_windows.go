@@ -0,0 +1,9 @@
+package lmdb
+
+func mmapFile(fd uintptr, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmapFile(b []byte) error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package lmdb
+
+import (
+	"fmt"
+	"os"
+)
+
+// envFlags is the set of flags accepted by Env.Open.
+const envFlags = FixedMap | NoSubdir | Readonly | WriteMap | NoMetaSync |
+	NoSync | MapAsync | NoTLS | NoLock | NoReadahead | NoMemInit
+
+// EnvOption configures an environment opened with Open.
+type EnvOption func(*envConfig)
+
+type envConfig struct {
+	mapSize    int64
+	maxDBs     int
+	maxReaders int
+	flags      uint
+	mode       os.FileMode
+	selfCheck  bool
+}
+
+// WithMapSize sets the size of the memory map.  See Env.SetMapSize.
+func WithMapSize(size int64) EnvOption {
+	return func(c *envConfig) { c.mapSize = size }
+}
+
+// WithMaxDBs sets the maximum number of named databases.  See Env.SetMaxDBs.
+func WithMaxDBs(n int) EnvOption {
+	return func(c *envConfig) { c.maxDBs = n }
+}
+
+// WithMaxReaders sets the maximum number of reader slots.  See
+// Env.SetMaxReaders.
+func WithMaxReaders(n int) EnvOption {
+	return func(c *envConfig) { c.maxReaders = n }
+}
+
+// WithFlags adds flags to those passed to Env.Open.  WithFlags may be given
+// more than once.
+func WithFlags(flags uint) EnvOption {
+	return func(c *envConfig) { c.flags |= flags }
+}
+
+// WithReadahead enables or disables the readahead of the operating system on
+// the memory map.  Readahead is enabled by default and suits sequential
+// scans.  Disabling it, which passes NoReadahead to Env.Open, keeps random
+// point reads of environments larger than RAM from evicting useful pages.
+// See also Env.Advise.
+func WithReadahead(enabled bool) EnvOption {
+	return func(c *envConfig) {
+		if enabled {
+			c.flags &^= NoReadahead
+		} else {
+			c.flags |= NoReadahead
+		}
+	}
+}
+
+// WithMode sets the permissions of files created by Open.  The default mode is
+// 0644.
+func WithMode(mode os.FileMode) EnvOption {
+	return func(c *envConfig) { c.mode = mode }
+}
+
+// Open creates an Env, applies opts in the order required by LMDB and opens
+// the environment at path.  Unlike a failed call to Env.Open, a failed Open
+// does not leave an Env that must be closed.
+//
+// Open validates the configuration before touching the file system and
+// returns descriptive errors for invalid sizes, unknown flags and flag
+// combinations which are contradictory or have no effect.  Note that database
+// flags such as Create share values with environment flags (Create is
+// NoMetaSync), so passing them to WithFlags is reported as a conflict rather
+// than silently changing the behavior of the environment.
+func Open(path string, opts ...EnvOption) (*Env, error) {
+	c := envConfig{mode: 0644}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	err := c.validate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ck *selfCheck
+	if c.selfCheck {
+		ck, err = openSelfCheck(dataFile(path, c.flags))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ck != nil {
+		defer ck.f.Close()
+		if d := ck.checkFile(); d != nil {
+			return nil, d
+		}
+	}
+
+	env, err := NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = c.apply(env, path)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	if ck != nil && c.maxDBs > 0 {
+		d, err := ck.checkNamed(env)
+		if err == nil && d != nil {
+			err = d
+		}
+		if err != nil {
+			env.Close()
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+func (c *envConfig) validate(path string) error {
+	if path == "" {
+		return fmt.Errorf("lmdb: empty environment path")
+	}
+	if c.mapSize < 0 {
+		return fmt.Errorf("lmdb: negative map size %d", c.mapSize)
+	}
+	if c.maxDBs < 0 {
+		return fmt.Errorf("lmdb: negative maximum number of databases %d", c.maxDBs)
+	}
+	if c.maxReaders < 0 {
+		return fmt.Errorf("lmdb: negative maximum number of readers %d", c.maxReaders)
+	}
+	if c.flags&PrevSnapshot != 0 {
+		return errPrevSnapshot
+	}
+	if unknown := c.flags &^ envFlags; unknown != 0 {
+		return fmt.Errorf("lmdb: unknown environment flags %#x", unknown)
+	}
+	if c.flags&Readonly != 0 {
+		for _, f := range []struct {
+			flag uint
+			name string
+		}{
+			{WriteMap, "WriteMap"},
+			{NoMetaSync, "NoMetaSync (or the database flag Create)"},
+			{NoSync, "NoSync"},
+			{MapAsync, "MapAsync"},
+		} {
+			if c.flags&f.flag != 0 {
+				return fmt.Errorf("lmdb: Readonly environment cannot be opened with %s", f.name)
+			}
+		}
+	}
+	if c.flags&MapAsync != 0 && c.flags&WriteMap == 0 {
+		return fmt.Errorf("lmdb: MapAsync has no effect without WriteMap")
+	}
+	if c.flags&NoSubdir == 0 {
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("lmdb: environment directory %q does not exist", path)
+		}
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("lmdb: environment path %q is not a directory (use NoSubdir)", path)
+		}
+	}
+	return nil
+}
+
+func (c *envConfig) apply(env *Env, path string) error {
+	if c.mapSize != 0 {
+		err := env.SetMapSize(c.mapSize)
+		if err != nil {
+			return err
+		}
+	}
+	if c.maxDBs != 0 {
+		err := env.SetMaxDBs(c.maxDBs)
+		if err != nil {
+			return err
+		}
+	}
+	if c.maxReaders != 0 {
+		err := env.SetMaxReaders(c.maxReaders)
+		if err != nil {
+			return err
+		}
+	}
+	return env.Open(path, c.flags, c.mode)
+}
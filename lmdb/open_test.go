@@ -0,0 +1,93 @@
+package lmdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdb_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := Open(dir, WithMapSize(64<<20), WithMaxDBs(4), WithMaxReaders(16), WithFlags(NoSync), WithMode(0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 64<<20 {
+		t.Errorf("unexpected map size: %d", info.MapSize)
+	}
+	if info.MaxReaders != 16 {
+		t.Errorf("unexpected max readers: %d", info.MaxReaders)
+	}
+	flags, err := env.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&NoSync == 0 {
+		t.Errorf("NoSync not set: %#x", flags)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			_, err = txn.OpenDBI(name, Create)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "data.mdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode: %v", fi.Mode())
+	}
+}
+
+func TestOpen_invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdb_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		path string
+		opts []EnvOption
+		msg  string
+	}{
+		{"", nil, "empty"},
+		{dir, []EnvOption{WithMapSize(-1)}, "map size"},
+		{dir, []EnvOption{WithMaxDBs(-1)}, "databases"},
+		{dir, []EnvOption{WithFlags(DupSort)}, "unknown"},
+		{dir, []EnvOption{WithFlags(Readonly | Create)}, "Create"},
+		{dir, []EnvOption{WithFlags(Readonly | WriteMap)}, "WriteMap"},
+		{dir, []EnvOption{WithFlags(MapAsync)}, "MapAsync"},
+		{filepath.Join(dir, "missing"), nil, "does not exist"},
+	} {
+		env, err := Open(test.path, test.opts...)
+		if err == nil {
+			env.Close()
+			t.Errorf("%q %d: expected error", test.path, len(test.opts))
+			continue
+		}
+		if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%q: unexpected error: %v", test.msg, err)
+		}
+	}
+}
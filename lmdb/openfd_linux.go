@@ -0,0 +1,66 @@
+package lmdb
+
+/*
+#define _GNU_SOURCE
+#include <errno.h>
+#include <stdlib.h>
+#include <unistd.h>
+#include <sys/syscall.h>
+
+#ifndef MFD_CLOEXEC
+#define MFD_CLOEXEC 0x0001U
+#endif
+
+static int lmdbgo_memfd_create(const char *name) {
+#ifdef SYS_memfd_create
+	return syscall(SYS_memfd_create, name, MFD_CLOEXEC);
+#else
+	errno = ENOSYS;
+	return -1;
+#endif
+}
+*/
+import "C"
+
+import (
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// OpenFD opens the environment stored in the file open as fd, such as a
+// descriptor inherited from a parent process or created with O_TMPFILE.  The
+// file is reopened through /proc/self/fd with the NoSubdir and NoLock flags
+// added to flags, so no named path is touched and fd may be closed once
+// OpenFD returns.  Env.Path returns the /proc path, which is meaningless after
+// OpenFD returns.
+//
+// Without a lock file LMDB does not coordinate transactions.  The application
+// must not open the file in another Env and must ensure that write
+// transactions do not overlap each other or any read transaction, see NoLock.
+//
+// If OpenFD fails Close must be called to discard the Env handle.
+//
+// See mdb_env_open.
+func (env *Env) OpenFD(fd uintptr, flags uint) error {
+	path := "/proc/self/fd/" + strconv.FormatUint(uint64(fd), 10)
+	return env.Open(path, flags|NoSubdir|NoLock, 0)
+}
+
+// OpenMemory opens a new, empty environment in an anonymous memory file
+// created with memfd_create.  The environment never touches the file system
+// and its contents are freed when env is closed.  The name is used only for
+// debugging, as the link target of the descriptor in /proc.  OpenMemory has
+// the restrictions of OpenFD.
+//
+// If OpenMemory fails Close must be called to discard the Env handle.
+func (env *Env) OpenMemory(name string, flags uint) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	fd, err := C.lmdbgo_memfd_create(cname)
+	if fd < 0 {
+		return &OpError{Op: "memfd_create", Errno: err}
+	}
+	defer syscall.Close(int(fd))
+	return env.OpenFD(uintptr(fd), flags)
+}
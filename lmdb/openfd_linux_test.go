@@ -0,0 +1,87 @@
+package lmdb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestEnv_OpenMemory(t *testing.T) {
+	env, err := NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	err = env.SetMapSize(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.OpenMemory("lmdb-test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) error {
+		v, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "v" {
+			t.Errorf("value %q (!= %q)", v, "v")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnv_OpenFD(t *testing.T) {
+	f, err := ioutil.TempFile("", "lmdb-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	env, err := NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	err = env.OpenFD(f.Fd(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() == 0 {
+		t.Errorf("environment not written to the file")
+	}
+	_, err = os.Stat(f.Name() + "-lock")
+	if !os.IsNotExist(err) {
+		t.Errorf("lock file created: %v", err)
+	}
+}
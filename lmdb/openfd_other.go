@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package lmdb
+
+import "errors"
+
+var errOpenFD = errors.New("lmdb: opening environments from descriptors requires Linux")
+
+// OpenFD opens the environment stored in the file open as fd.  It is only
+// supported on Linux and returns an error on other platforms.
+func (env *Env) OpenFD(fd uintptr, flags uint) error {
+	return errOpenFD
+}
+
+// OpenMemory opens a new environment in an anonymous memory file.  It is only
+// supported on Linux and returns an error on other platforms.
+func (env *Env) OpenMemory(name string, flags uint) error {
+	return errOpenFD
+}
@@ -0,0 +1,111 @@
+package lmdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a write transaction cannot begin because
+// the WriteLimiter of its Env is out of tokens.
+var ErrRateLimited = errors.New("lmdb: write transaction rate limit exceeded")
+
+// WriteLimiter limits the rate at which top-level write transactions begin
+// in an Env using a token bucket.  Because LMDB permits only one writer at a
+// time, throttling background writers leaves room for latency sensitive ones.
+// A WriteLimiter applies to every write transaction of the Env, so background
+// jobs are typically throttled by giving them their own Env handle or by
+// installing a limiter only while they run.
+type WriteLimiter struct {
+	rate    float64
+	burst   float64
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  WriteLimitStats
+}
+
+// WriteLimitStats counts the write transactions seen by a WriteLimiter.
+type WriteLimitStats struct {
+	Allowed  uint64        // Transactions which began without waiting
+	Delayed  uint64        // Transactions which waited for a token
+	Rejected uint64        // Transactions which failed with ErrRateLimited
+	Waited   time.Duration // Total time spent waiting
+}
+
+// NewWriteLimiter returns a WriteLimiter which allows rate write transactions
+// per second on average and bursts of up to burst transactions.  When no
+// token is available a transaction waits for one if the wait is no longer
+// than maxWait and fails with ErrRateLimited otherwise.  A maxWait of zero
+// never waits and a negative maxWait always waits.
+func NewWriteLimiter(rate float64, burst int, maxWait time.Duration) *WriteLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &WriteLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		maxWait: maxWait,
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Stats returns the counters of l.
+func (l *WriteLimiter) Stats() WriteLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// reserve takes a token from l and returns the time to wait before using it.
+func (l *WriteLimiter) reserve() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		l.stats.Allowed++
+		return 0, nil
+	}
+	if l.rate <= 0 {
+		l.stats.Rejected++
+		return 0, ErrRateLimited
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if l.maxWait >= 0 && wait > l.maxWait {
+		l.stats.Rejected++
+		return 0, ErrRateLimited
+	}
+	l.tokens--
+	l.stats.Delayed++
+	l.stats.Waited += wait
+	return wait, nil
+}
+
+// wait blocks until l permits a write transaction to begin.
+func (l *WriteLimiter) wait() error {
+	d, err := l.reserve()
+	if err != nil {
+		return err
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+	return nil
+}
+
+// SetWriteLimiter sets the limiter applied to top-level write transactions
+// begun in env through Update, UpdateLocked, RunTxn and BeginTxn.  A nil l
+// removes the limit.  SetWriteLimiter must not be called while a write
+// transaction is active.
+func (env *Env) SetWriteLimiter(l *WriteLimiter) {
+	env.limiter = l
+}
@@ -0,0 +1,57 @@
+package lmdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnv_SetWriteLimiter(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	nop := func(txn *Txn) error { return nil }
+
+	l := NewWriteLimiter(0.001, 2, 0)
+	env.SetWriteLimiter(l)
+	for i := 0; i < 2; i++ {
+		err := env.Update(nop)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := env.Update(nop)
+	if err != ErrRateLimited {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = env.View(nop)
+	if err != nil {
+		t.Errorf("read-only transaction limited: %v", err)
+	}
+	stats := l.Stats()
+	if stats.Allowed != 2 || stats.Rejected != 1 || stats.Delayed != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	l = NewWriteLimiter(100, 1, -1)
+	env.SetWriteLimiter(l)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := env.Update(nop)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("transactions were not delayed: %v", d)
+	}
+	stats = l.Stats()
+	if stats.Allowed != 1 || stats.Delayed != 2 || stats.Waited <= 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	env.SetWriteLimiter(nil)
+	err = env.Update(nop)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
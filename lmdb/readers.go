@@ -0,0 +1,155 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReaderInfo describes a slot in the reader lock table that is in use.
+type ReaderInfo struct {
+	PID    int    // Process ID of the reader
+	Thread uint64 // Thread ID of the reader, as reported by the OS
+	TxnID  int64  // ID of the snapshot held by the reader, or -1 if it holds none
+}
+
+// Readers returns the entries of the reader lock table that are in use.  A
+// slot remains in use while its thread has a read-only transaction, even one
+// that has been reset, and is only released when the transaction is aborted
+// or its thread exits.
+//
+// Readers parses the output of ReaderList.
+func (env *Env) Readers() ([]ReaderInfo, error) {
+	var readers []ReaderInfo
+	var perr error
+	err := env.ReaderList(func(msg string) error {
+		for _, line := range strings.Split(msg, "\n") {
+			r, ok, err := parseReaderLine(line)
+			if err != nil {
+				perr = err
+				return err
+			}
+			if ok {
+				readers = append(readers, r)
+			}
+		}
+		return nil
+	})
+	if perr != nil {
+		return nil, perr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readers, nil
+}
+
+// parseReaderLine parses a line of mdb_reader_list output.  Header and status
+// lines are skipped.
+func parseReaderLine(line string) (r ReaderInfo, ok bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || strings.HasPrefix(line, "(") || fields[0] == "pid" {
+		return r, false, nil
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return r, false, fmt.Errorf("lmdb: invalid reader pid %q", fields[0])
+	}
+	tid, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return r, false, fmt.Errorf("lmdb: invalid reader thread %q", fields[1])
+	}
+	txnid := int64(-1)
+	if fields[2] != "-" {
+		txnid, err = strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return r, false, fmt.Errorf("lmdb: invalid reader txnid %q", fields[2])
+		}
+	}
+	return ReaderInfo{PID: pid, Thread: tid, TxnID: txnid}, true, nil
+}
+
+// StaleReaders returns the readers holding a snapshot older than the last
+// committed transaction.  Pages freed after such a snapshot cannot be reused
+// by writers until the reader finishes, so a long running or stuck reader
+// causes the database to grow.  A reader lags behind by
+// EnvInfo.LastTxnID - ReaderInfo.TxnID transactions.
+func (env *Env) StaleReaders() ([]ReaderInfo, error) {
+	info, err := env.Info()
+	if err != nil {
+		return nil, err
+	}
+	readers, err := env.Readers()
+	if err != nil {
+		return nil, err
+	}
+	var stale []ReaderInfo
+	for _, r := range readers {
+		if r.TxnID >= 0 && r.TxnID < info.LastTxnID {
+			stale = append(stale, r)
+		}
+	}
+	return stale, nil
+}
+
+// readerChecker is the background goroutine started by Env.StartReaderCheck.
+type readerChecker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartReaderCheck starts a goroutine that calls ReaderCheck every interval to
+// clear reader lock table entries left behind by processes that exited
+// without closing their transactions.  Such entries pin the snapshots they
+// reference and prevent writers from reusing free pages until they are
+// cleared.  If fn is not nil it is called from the goroutine whenever entries
+// were cleared or ReaderCheck returned an error.
+//
+// The goroutine runs until StopReaderCheck or Close is called.  Because it
+// references env, an Env with a running reader check is never finalized and
+// must be closed explicitly.  StartReaderCheck returns an error if a reader
+// check is already running.
+func (env *Env) StartReaderCheck(interval time.Duration, fn func(cleared int, err error)) error {
+	if interval <= 0 {
+		return errors.New("lmdb: reader check interval must be positive")
+	}
+	if env.checker != nil {
+		return errors.New("lmdb: reader check already running")
+	}
+	c := &readerChecker{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	env.checker = c
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+			n, err := env.ReaderCheck()
+			if fn != nil && (n > 0 || err != nil) {
+				fn(n, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// StopReaderCheck stops the goroutine started by StartReaderCheck and waits
+// for it to exit.  StopReaderCheck does nothing if no reader check is running.
+func (env *Env) StopReaderCheck() {
+	c := env.checker
+	if c == nil {
+		return
+	}
+	env.checker = nil
+	close(c.stop)
+	<-c.done
+}
@@ -0,0 +1,145 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnv_Readers(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers, err := env.Readers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 0 {
+		t.Errorf("unexpected readers: %v", readers)
+	}
+
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	fin := make(chan error)
+	go func() {
+		fin <- env.View(func(txn *Txn) (err error) {
+			ready <- struct{}{}
+			<-done
+			return nil
+		})
+	}()
+	<-ready
+
+	readers, err = env.Readers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 1 {
+		t.Fatalf("unexpected readers: %v", readers)
+	}
+	if readers[0].PID != os.Getpid() {
+		t.Errorf("unexpected pid: %d", readers[0].PID)
+	}
+	if readers[0].TxnID < 0 {
+		t.Errorf("unexpected txnid: %d", readers[0].TxnID)
+	}
+
+	stale, err := env.StaleReaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("unexpected stale readers: %v", stale)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err = env.StaleReaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0] != readers[0] {
+		t.Errorf("unexpected stale readers: %v", stale)
+	}
+
+	close(done)
+	err = <-fin
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseReaderLine(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		r    ReaderInfo
+		ok   bool
+		err  bool
+	}{
+		{"    pid     thread     txnid", ReaderInfo{}, false, false},
+		{"(no active readers)", ReaderInfo{}, false, false},
+		{"      1234 7f3a2c 42", ReaderInfo{1234, 0x7f3a2c, 42}, true, false},
+		{"      1234 7f3a2c -", ReaderInfo{1234, 0x7f3a2c, -1}, true, false},
+		{"      1234 xyz 42", ReaderInfo{}, false, true},
+	} {
+		r, ok, err := parseReaderLine(test.line)
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error: %v", test.line, err)
+		}
+		if ok != test.ok || r != test.r {
+			t.Errorf("%q: unexpected result: %v %v", test.line, r, ok)
+		}
+	}
+}
+
+func TestEnv_StartReaderCheck(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.StartReaderCheck(0, nil)
+	if err == nil {
+		t.Errorf("expected error for zero interval")
+	}
+
+	called := make(chan error, 1)
+	err = env.StartReaderCheck(time.Millisecond, func(n int, err error) {
+		select {
+		case called <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.StartReaderCheck(time.Millisecond, nil)
+	if err == nil {
+		t.Errorf("expected error starting a second reader check")
+	}
+
+	// there are no stale readers so fn must not be called.
+	time.Sleep(10 * time.Millisecond)
+	env.StopReaderCheck()
+	select {
+	case err := <-called:
+		t.Errorf("unexpected callback: %v", err)
+	default:
+	}
+
+	// stopping twice is harmless and the check may be restarted.
+	env.StopReaderCheck()
+	err = env.StartReaderCheck(time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// clean closes env, which stops the reader check.
+}
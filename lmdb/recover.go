@@ -0,0 +1,186 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errPrevSnapshot = errors.New("lmdb: PrevSnapshot is not supported by this version of LMDB, use OpenPrevSnapshot")
+
+// RecoverOptions configure OpenPrevSnapshot.
+type RecoverOptions struct {
+	// Flags are the flags the environment is normally opened with.  Only
+	// NoSubdir is used.
+	Flags uint
+
+	// MaxDBs allows for the named databases of the snapshot, which are then
+	// checked like with WithSelfCheck.
+	MaxDBs int
+
+	// Dir is the directory in which the working copy of the data file is
+	// made.  If empty a temporary directory is created.
+	Dir string
+}
+
+// Recovery is the previous snapshot of an environment, opened read-only by
+// OpenPrevSnapshot.
+type Recovery struct {
+	Env   *Env   // The snapshot, read-only
+	TxnID uint64 // ID of the transaction which committed the snapshot
+
+	dir    string
+	tmpDir bool
+}
+
+// OpenPrevSnapshot opens the snapshot of the environment at path preceding
+// its last commit, like the MDB_PREVSNAPSHOT flag of later LMDB versions.  It
+// is a recovery path for an environment whose last commit was lost or
+// damaged, for example by a crash with NoMetaSync, and leaves the environment
+// at path untouched.
+//
+// The data file is copied to opt.Dir and the meta page of the last commit is
+// replaced by that of the previous one in the copy, which is opened
+// read-only after passing the checks of WithSelfCheck.  If the checks fail
+// OpenPrevSnapshot returns a *Diagnosis.  The snapshot may then be read
+// through Recovery.Env or copied to a new environment with Recovery.Copy.
+func OpenPrevSnapshot(path string, opt *RecoverOptions) (*Recovery, error) {
+	if opt == nil {
+		opt = &RecoverOptions{}
+	}
+	ck, err := openSelfCheck(dataFile(path, opt.Flags))
+	if err != nil {
+		return nil, err
+	}
+	if ck == nil {
+		return nil, fmt.Errorf("lmdb: no environment at %q", path)
+	}
+	defer ck.f.Close()
+	page, txnID, err := ck.prevMetaPage()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recovery{TxnID: txnID, dir: opt.Dir}
+	if r.dir == "" {
+		r.dir, err = ioutil.TempDir("", "lmdb-prevsnapshot-")
+		if err != nil {
+			return nil, err
+		}
+		r.tmpDir = true
+	}
+	err = r.copyFile(ck.f, page, ck.psize)
+	if err == nil {
+		opts := []EnvOption{WithFlags(Readonly), WithSelfCheck()}
+		if opt.MaxDBs > 0 {
+			opts = append(opts, WithMaxDBs(opt.MaxDBs))
+		}
+		r.Env, err = Open(r.dir, opts...)
+	}
+	if err != nil {
+		r.remove()
+		return nil, err
+	}
+	return r, nil
+}
+
+// prevMetaPage returns the meta page of the previous snapshot, which is the
+// older of the two meta pages, or the only valid one.
+func (ck *selfCheck) prevMetaPage() (page []byte, txnID uint64, err error) {
+	m0, err := ck.readMeta(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	ck.psize = int64(m0.dbs[0].pad)
+	valid0 := ck.checkMeta(0, m0) && ck.psize >= 512 && ck.psize&(ck.psize-1) == 0
+	if !valid0 {
+		// Meta page 0 is damaged; assume the default page size.
+		ck.psize = int64(os.Getpagesize())
+	}
+	m1, err := ck.readMeta(ck.psize)
+	if err != nil {
+		return nil, 0, err
+	}
+	valid1 := ck.checkMeta(1, m1)
+
+	var prev int
+	switch {
+	case valid0 && valid1:
+		if m0.txnID == m1.txnID {
+			return nil, 0, errors.New("lmdb: meta pages refer to the same transaction, there is no previous snapshot")
+		}
+		if m1.txnID < m0.txnID {
+			prev = 1
+		}
+	case valid0:
+		prev = 0
+	case valid1:
+		prev = 1
+	default:
+		return nil, 0, fmt.Errorf("lmdb: no valid meta page: %s", strings.Join(ck.problems, "; "))
+	}
+	page = make([]byte, ck.psize)
+	_, err = ck.f.ReadAt(page, int64(prev)*ck.psize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, [fileNumMetas]*fileMeta{m0, m1}[prev].txnID, nil
+}
+
+// copyFile copies the data file f to the recovery directory, with both meta
+// pages replaced by page.
+func (r *Recovery) copyFile(f *os.File, page []byte, psize int64) error {
+	dst, err := os.OpenFile(filepath.Join(r.dir, "data.mdb"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	for i := 0; i < fileNumMetas && err == nil; i++ {
+		// The page header starts with the page number.
+		if fileWord == 4 {
+			NativeEndian.PutUint32(page, uint32(i))
+		} else {
+			NativeEndian.PutUint64(page, uint64(i))
+		}
+		_, err = dst.WriteAt(page, int64(i)*psize)
+	}
+	cerr := dst.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Copy copies the snapshot to a new environment at path, see Env.CopyFlag.
+// Passing CopyCompact omits the pages freed by the snapshot.
+func (r *Recovery) Copy(path string, flags uint) error {
+	return r.Env.CopyFlag(path, flags)
+}
+
+// Close closes the snapshot and removes its working copy.
+func (r *Recovery) Close() error {
+	err := r.Env.Close()
+	rerr := r.remove()
+	if err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (r *Recovery) remove() error {
+	if r.tmpDir {
+		return os.RemoveAll(r.dir)
+	}
+	err := os.Remove(filepath.Join(r.dir, "data.mdb"))
+	if err == nil || os.IsNotExist(err) {
+		err = os.Remove(filepath.Join(r.dir, "lock.mdb"))
+	}
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package lmdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenPrevSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdb_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := Open(dir, WithMaxDBs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Open(dir, WithFlags(PrevSnapshot))
+	if err != errPrevSnapshot {
+		t.Errorf("Open with PrevSnapshot: %v", err)
+	}
+	var prevID uintptr
+	for _, key := range []string{"a", "b"} {
+		err = env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenDBI("db", Create)
+			if err != nil {
+				return err
+			}
+			if key == "a" {
+				txn.OnCommit(func(id uintptr) { prevID = id })
+			}
+			return txn.Put(dbi, []byte(key), []byte(key), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	check := func(env *Env, want string) {
+		t.Helper()
+		err := env.View(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenDBI("db", 0)
+			if err != nil {
+				return err
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			if stat.Entries != uint64(len(want)) {
+				t.Errorf("%d entries, expected %q", stat.Entries, want)
+			}
+			_, err = txn.Get(dbi, []byte(want[len(want)-1:]))
+			return err
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	r, err := OpenPrevSnapshot(dir, &RecoverOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TxnID != uint64(prevID) || r.TxnID != uint64(info.LastTxnID)-1 {
+		t.Errorf("snapshot of transaction %d, expected %d", r.TxnID, prevID)
+	}
+	check(r.Env, "a")
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.dir); !os.IsNotExist(err) {
+		t.Errorf("working copy not removed: %v", err)
+	}
+
+	// Damage the meta page of the last commit and recover the previous
+	// snapshot into a new environment.
+	stat := func() int64 {
+		env, err := Open(dir, WithFlags(Readonly))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.Close()
+		s, err := env.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int64(s.PSize)
+	}
+	psize := stat()
+	f, err := os.OpenFile(filepath.Join(dir, "data.mdb"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt(make([]byte, 64), int64(info.LastTxnID%2)*psize)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, err := Open(dir, WithMaxDBs(1)); err == nil {
+		env.Close()
+		t.Fatalf("damaged environment opened")
+	}
+
+	work := filepath.Join(dir, "work")
+	out := filepath.Join(dir, "out")
+	for _, d := range []string{work, out} {
+		err = os.Mkdir(d, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err = OpenPrevSnapshot(dir, &RecoverOptions{MaxDBs: 1, Dir: work})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Copy(out, CopyCompact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(work, "*")); len(names) != 0 {
+		t.Errorf("working copy not removed: %v", names)
+	}
+	env, err = Open(out, WithMaxDBs(1), WithSelfCheck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	check(env, "a")
+}
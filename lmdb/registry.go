@@ -0,0 +1,112 @@
+package lmdb
+
+import (
+	"sync"
+)
+
+// dbiRegistry caches the handles opened by Env.DB and remembers the names of
+// all handles opened, for the messages of errors.
+type dbiRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]DBI
+	names  map[DBI]string
+}
+
+func (r *dbiRegistry) get(name string) (DBI, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dbi, ok := r.byName[name]
+	return dbi, ok
+}
+
+func (r *dbiRegistry) add(name string, dbi DBI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byName == nil {
+		r.byName = make(map[string]DBI)
+	}
+	r.byName[name] = dbi
+}
+
+// opened records the name of dbi, which was opened by a transaction.
+func (r *dbiRegistry) opened(name string, dbi DBI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names == nil {
+		r.names = make(map[DBI]string)
+	}
+	r.names[dbi] = name
+}
+
+// name returns the name dbi was opened with, or false if it is unknown.
+func (r *dbiRegistry) name(dbi DBI) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.names[dbi]
+	return name, ok
+}
+
+// forget removes dbi from the registry after it has been closed.
+func (r *dbiRegistry) forget(dbi DBI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.names, dbi)
+	for name, d := range r.byName {
+		if d == dbi {
+			delete(r.byName, name)
+		}
+	}
+}
+
+// DB returns a handle for the named database in env, opening it with flags in
+// a short transaction the first time the name is requested and returning the
+// cached handle afterwards.  An empty name refers to the root database.  The
+// flags are only used when the database is opened; Create causes a missing
+// database to be created.  In an environment opened with the Readonly flag
+// the database is opened in a read-only transaction.
+//
+// DB is safe for concurrent use.  Handles are removed from the cache when
+// they are closed with Env.CloseDBI or deleted with Txn.Drop.  Because DB may
+// begin a write transaction it must not be called by a goroutine that has a
+// write transaction open on env.
+func (env *Env) DB(name string, flags uint) (DBI, error) {
+	if dbi, ok := env.dbis.get(name); ok {
+		return dbi, nil
+	}
+	envflags, err := env.Flags()
+	if err != nil {
+		return 0, err
+	}
+	open := func(txn *Txn) (err error) {
+		var dbi DBI
+		if name == "" {
+			dbi, err = txn.OpenRoot(flags)
+		} else {
+			dbi, err = txn.OpenDBI(name, flags)
+		}
+		if err != nil {
+			return err
+		}
+		txn.OnCommit(func(uintptr) { env.dbis.add(name, dbi) })
+		return nil
+	}
+	if envflags&Readonly != 0 || env.guard {
+		err = env.View(open)
+	} else {
+		err = env.Update(open)
+	}
+	if err != nil {
+		return 0, err
+	}
+	dbi, _ := env.dbis.get(name)
+	return dbi, nil
+}
+
+// reset removes all handles from the registry after the environment was
+// reopened.
+func (r *dbiRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byName = nil
+	r.names = nil
+}
@@ -0,0 +1,75 @@
+package lmdb
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEnv_DB(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	_, err := env.DB("missing", 0)
+	if !IsNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	dbis := make([]DBI, 8)
+	errs := make([]error, len(dbis))
+	for i := range dbis {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dbis[i], errs[i] = env.DB("items", Create)
+		}(i)
+	}
+	wg.Wait()
+	for i := range dbis {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if dbis[i] != dbis[0] {
+			t.Errorf("handles differ: %d %d", dbis[i], dbis[0])
+		}
+	}
+	dbi := dbis[0]
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := env.DB("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == dbi {
+		t.Errorf("root handle equals named handle")
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Drop(dbi, true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.dbis.get("items"); ok {
+		t.Errorf("dropped handle still cached")
+	}
+	_, err = env.DB("items", 0)
+	if !IsNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	dbi, err = env.DB("items", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.CloseDBI(dbi)
+	if _, ok := env.dbis.get("items"); ok {
+		t.Errorf("closed handle still cached")
+	}
+}
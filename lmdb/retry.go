@@ -0,0 +1,157 @@
+package lmdb
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DefaultRetries is the number of times Retry attempts a transaction again
+// when RetryPolicy.MaxRetries is zero.
+const DefaultRetries = 3
+
+// RetryPolicy configures the remediation applied by Retry.  The zero value
+// adopts map sizes set by other processes and clears stale reader slots but
+// does not grow the map or split transactions.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a transaction is attempted
+	// again.
+	MaxRetries int
+
+	// Readonly runs fn in read-only transactions.
+	Readonly bool
+
+	// Grow returns the map size to adopt after a transaction of an
+	// environment with map size cur failed with MapFull.  If Grow is nil, or
+	// returns a value not greater than cur, MapFull is returned to the
+	// caller.
+	Grow func(cur int64) int64
+
+	// Split is called after a transaction failed with TxnFull.  If Split
+	// returns true the transaction is attempted again, so Split should
+	// reduce the amount of work fn performs, for example by halving a batch
+	// size captured by fn.  If Split is nil TxnFull is returned to the
+	// caller.
+	Split func() bool
+
+	// Delay, if not nil, returns the time to wait before the given retry,
+	// starting from 1.
+	Delay func(retry int) time.Duration
+}
+
+// GrowDouble is a RetryPolicy.Grow function which doubles the map size up to
+// max bytes.
+func GrowDouble(max int64) func(cur int64) int64 {
+	return func(cur int64) int64 {
+		if cur >= max/2 {
+			return max
+		}
+		return 2 * cur
+	}
+}
+
+// ExponentialBackoff is a RetryPolicy.Delay function which waits a random
+// duration between zero and the minimum of max and base*factor^(retry-1), so
+// that processes retrying at the same time spread out.
+func ExponentialBackoff(base, max time.Duration, factor float64) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		limit := math.Min(float64(base)*math.Pow(factor, float64(retry-1)), float64(max))
+		if limit < 1 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(limit)))
+	}
+}
+
+// UpdateRetry runs fn in a write transaction with Retry.  Transactions which
+// fail with MapResized, MapFull, TxnFull or BadRSlot are remedied according
+// to policy and attempted again.  See Retry for the restrictions on
+// concurrent transactions.
+func (env *Env) UpdateRetry(policy *RetryPolicy, fn TxnOp) error {
+	if policy != nil && policy.Readonly {
+		p := *policy
+		p.Readonly = false
+		policy = &p
+	}
+	return Retry(env, policy, fn)
+}
+
+// Retry runs fn in a write transaction, or a read-only transaction if
+// policy.Readonly is set, and remedies errors which a later attempt may not
+// encounter:
+//
+//	MapResized  the map size set by another process is adopted
+//	MapFull     the map is grown according to policy.Grow
+//	TxnFull     policy.Split is consulted
+//	BadRSlot    stale reader slots are cleared with Env.ReaderCheck
+//
+// The transaction is then attempted again, up to policy.MaxRetries times.
+// Other errors are returned immediately.  A nil policy is equivalent to a
+// zero RetryPolicy.
+//
+// Changing the map size requires that the calling process has no active
+// transactions on env, so Retry must not be called from within a
+// transaction, and other goroutines must not run transactions concurrently
+// with a Retry which may grow the map.  For more elaborate coordination see
+// the lmdbsync package.
+func Retry(env *Env, policy *RetryPolicy, fn TxnOp) error {
+	var p RetryPolicy
+	if policy != nil {
+		p = *policy
+	}
+	if p.MaxRetries <= 0 {
+		p.MaxRetries = DefaultRetries
+	}
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			env.counters.retry()
+			if p.Delay != nil {
+				time.Sleep(p.Delay(retry))
+			}
+		}
+		var err error
+		if p.Readonly {
+			err = env.View(fn)
+		} else {
+			err = env.Update(fn)
+		}
+		if err == nil || retry >= p.MaxRetries {
+			return err
+		}
+		ok, rerr := p.remedy(env, err)
+		if rerr != nil {
+			return rerr
+		}
+		if !ok {
+			return err
+		}
+	}
+}
+
+// remedy attempts to address the cause of err.  It returns true if the
+// transaction should be attempted again.
+func (p *RetryPolicy) remedy(env *Env, err error) (bool, error) {
+	switch {
+	case IsMapResized(err):
+		return true, env.SetMapSize(0)
+	case IsMapFull(err):
+		if p.Grow == nil {
+			return false, nil
+		}
+		info, ierr := env.Info()
+		if ierr != nil {
+			return false, ierr
+		}
+		size := p.Grow(info.MapSize)
+		if size <= info.MapSize {
+			return false, nil
+		}
+		return true, env.SetMapSize(size)
+	case IsErrno(err, TxnFull):
+		return p.Split != nil && p.Split(), nil
+	case IsErrno(err, BadRSlot):
+		_, cerr := env.ReaderCheck()
+		return true, cerr
+	}
+	return false, nil
+}
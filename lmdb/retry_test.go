@@ -0,0 +1,151 @@
+package lmdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry_MapFull(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.SetMapSize(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := make([]byte, 4<<10)
+	put := func(txn *Txn) (err error) {
+		for i := 0; i < 1024; i++ {
+			err = txn.Put(dbi, []byte{byte(i >> 8), byte(i)}, val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = Retry(env, nil, put)
+	if !IsMapFull(err) {
+		t.Fatalf("expected MapFull: %v", err)
+	}
+
+	attempts := 0
+	err = Retry(env, &RetryPolicy{MaxRetries: 10, Grow: GrowDouble(64 << 20)}, func(txn *Txn) error {
+		attempts++
+		return put(txn)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts < 2 {
+		t.Errorf("unexpected attempts: %d", attempts)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize <= 1<<20 || info.MapSize > 64<<20 {
+		t.Errorf("unexpected map size: %d", info.MapSize)
+	}
+}
+
+func TestRetry_TxnFull(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	batch := 8
+	var attempts int
+	fn := func(txn *Txn) error {
+		attempts++
+		if batch > 2 {
+			return TxnFull
+		}
+		return nil
+	}
+
+	err := Retry(env, nil, fn)
+	if !IsErrno(err, TxnFull) {
+		t.Errorf("expected TxnFull: %v", err)
+	}
+
+	policy := &RetryPolicy{
+		Split: func() bool {
+			batch /= 2
+			return true
+		},
+	}
+	attempts = 0
+	err = Retry(env, policy, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch != 2 || attempts != 3 {
+		t.Errorf("unexpected batch %d after %d attempts", batch, attempts)
+	}
+
+	// other errors are not retried.
+	errOther := errors.New("other")
+	attempts = 0
+	err = Retry(env, policy, func(txn *Txn) error {
+		attempts++
+		return errOther
+	})
+	if err != errOther || attempts != 1 {
+		t.Errorf("unexpected result %v after %d attempts", err, attempts)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	delay := ExponentialBackoff(time.Millisecond, 10*time.Millisecond, 2)
+	for retry := 1; retry <= 10; retry++ {
+		limit := time.Millisecond << uint(retry-1)
+		if limit > 10*time.Millisecond {
+			limit = 10 * time.Millisecond
+		}
+		for i := 0; i < 100; i++ {
+			d := delay(retry)
+			if d < 0 || d >= limit {
+				t.Fatalf("retry %d: delay %v not in [0, %v)", retry, d, limit)
+			}
+		}
+	}
+}
+
+func TestEnv_UpdateRetry(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.SetMapSize(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := make([]byte, 4<<10)
+	err = env.UpdateRetry(&RetryPolicy{
+		MaxRetries: 10,
+		Readonly:   true,
+		Grow:       GrowDouble(64 << 20),
+		Delay:      ExponentialBackoff(time.Microsecond, time.Millisecond, 2),
+	}, func(txn *Txn) (err error) {
+		for i := 0; i < 1024; i++ {
+			err = txn.Put(dbi, []byte{byte(i >> 8), byte(i)}, val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// ErrSavepointEnded is returned by the methods of a Savepoint that has been
+// rolled back or released, either directly or because an enclosing savepoint
+// or its transaction ended.
+var ErrSavepointEnded = errors.New("lmdb: savepoint has ended")
+
+// Savepoint marks a point in a write transaction that its changes can be
+// rolled back to.  Unlike Sub, which runs a function in a separate Txn, a
+// savepoint redirects the operations of the Txn it was created on, so code
+// ported from SQL stores can keep using a single transaction handle.
+//
+// Savepoints are implemented with nested transactions.  Cursors opened before
+// a savepoint remain usable while it is active and are restored to their
+// previous position when it is rolled back.  Cursors opened while a savepoint
+// is active are closed when it ends.
+type Savepoint struct {
+	txn    *Txn
+	name   string
+	parent *C.MDB_txn
+
+	// Lengths of the hook lists and dry run log of txn when the savepoint
+	// was created.
+	onCommit int
+	onAbort  int
+	drymark  int
+
+	cursors []*Cursor
+	ended   bool
+}
+
+// Savepoint creates a savepoint called name in txn, which must be a write
+// transaction.  Savepoints may be nested and names need not be unique, see
+// Txn.SavepointNamed.  The savepoint must be rolled back or released before
+// txn terminates, otherwise it is released by Commit and rolled back by
+// Abort.
+//
+// See mdb_txn_begin.
+func (txn *Txn) Savepoint(name string) (*Savepoint, error) {
+	if txn.readonly {
+		return nil, errors.New("lmdb: savepoint in a read-only transaction")
+	}
+	var child *C.MDB_txn
+	ret := C.mdb_txn_begin(txn.env._env, txn._txn, 0, &child)
+	if ret != success {
+		return nil, operrno("mdb_txn_begin", ret)
+	}
+	sp := &Savepoint{
+		txn:      txn,
+		name:     name,
+		parent:   txn._txn,
+		onCommit: len(txn.onCommit),
+		onAbort:  len(txn.onAbort),
+	}
+	if txn.dry != nil {
+		sp.drymark = len(txn.dry.ops)
+	}
+	txn._txn = child
+	txn.savepoints = append(txn.savepoints, sp)
+	return sp, nil
+}
+
+// SavepointNamed returns the innermost active savepoint of txn called name, or
+// nil if there is none.
+func (txn *Txn) SavepointNamed(name string) *Savepoint {
+	for i := len(txn.savepoints) - 1; i >= 0; i-- {
+		if txn.savepoints[i].name == name {
+			return txn.savepoints[i]
+		}
+	}
+	return nil
+}
+
+// Name returns the name of sp.
+func (sp *Savepoint) Name() string {
+	return sp.name
+}
+
+// Rollback discards the changes made in the transaction since sp was created
+// and ends sp, along with any savepoints created after it.  OnAbort hooks
+// registered since sp was created are called and OnCommit hooks are
+// discarded.
+//
+// See mdb_txn_abort.
+func (sp *Savepoint) Rollback() error {
+	i, err := sp.index()
+	if err != nil {
+		return err
+	}
+	sp.txn.endSavepoints(i, false)
+	return nil
+}
+
+// Release ends sp, and any savepoints created after it, keeping their changes
+// in the transaction.  If Release fails the changes made since sp was created
+// are discarded, as by Rollback.
+//
+// See mdb_txn_commit.
+func (sp *Savepoint) Release() error {
+	i, err := sp.index()
+	if err != nil {
+		return err
+	}
+	return sp.txn.endSavepoints(i, true)
+}
+
+func (sp *Savepoint) index() (int, error) {
+	if !sp.ended {
+		for i, s := range sp.txn.savepoints {
+			if s == sp {
+				return i, nil
+			}
+		}
+	}
+	return 0, ErrSavepointEnded
+}
+
+// endSavepoints ends the savepoints of txn from index i, innermost first,
+// committing the nested transactions if commit is true.  If a commit fails
+// the remaining savepoints are rolled back.
+func (txn *Txn) endSavepoints(i int, commit bool) error {
+	var err error
+	for len(txn.savepoints) > i {
+		n := len(txn.savepoints) - 1
+		sp := txn.savepoints[n]
+		txn.savepoints[n] = nil
+		txn.savepoints = txn.savepoints[:n]
+		if commit && err == nil {
+			ret := C.mdb_txn_commit(txn._txn)
+			err = operrno("mdb_txn_commit", ret)
+		} else {
+			C.mdb_txn_abort(txn._txn)
+		}
+		if !commit || err != nil {
+			sp.undo()
+		}
+		sp.end()
+	}
+	return err
+}
+
+// dropSavepoints ends all savepoints of txn without terminating their nested
+// transactions, which the caller aborts along with txn.
+func (txn *Txn) dropSavepoints() {
+	for n := len(txn.savepoints) - 1; n >= 0; n-- {
+		sp := txn.savepoints[n]
+		txn.savepoints[n] = nil
+		sp.undo()
+		sp.end()
+	}
+	txn.savepoints = nil
+}
+
+// undo restores the hooks and dry run log of the transaction of sp.
+func (sp *Savepoint) undo() {
+	txn := sp.txn
+	*txn.gen++
+	hooks := txn.onAbort[sp.onAbort:]
+	txn.onCommit = txn.onCommit[:sp.onCommit]
+	txn.onAbort = txn.onAbort[:sp.onAbort]
+	if txn.dry != nil {
+		txn.dry.rollback(sp.drymark)
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// end marks sp ended after its nested transaction has terminated and makes
+// its parent current again.
+func (sp *Savepoint) end() {
+	sp.txn._txn = sp.parent
+	for _, c := range sp.cursors {
+		// LMDB frees the cursors of nested transactions.
+		c.txn = nil
+		c._c = nil
+	}
+	sp.cursors = nil
+	sp.ended = true
+}
@@ -0,0 +1,188 @@
+package lmdb
+
+import (
+	"testing"
+)
+
+func TestTxn_Savepoint(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	has := func(txn *Txn, k string) bool {
+		_, err := txn.Get(dbi, []byte(k))
+		if err != nil && !IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k1"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+
+		var aborted bool
+		sp, err := txn.Savepoint("a")
+		if err != nil {
+			return err
+		}
+		txn.OnAbort(func() { aborted = true })
+		err = txn.Put(dbi, []byte("k2"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		if txn.SavepointNamed("a") != sp {
+			t.Errorf("savepoint %q not found", "a")
+		}
+		err = sp.Rollback()
+		if err != nil {
+			return err
+		}
+		if !aborted {
+			t.Errorf("abort hook not called")
+		}
+		if !has(txn, "k1") || has(txn, "k2") {
+			t.Errorf("rollback did not restore the transaction")
+		}
+		if err := sp.Rollback(); err != ErrSavepointEnded {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if txn.SavepointNamed("a") != nil {
+			t.Errorf("ended savepoint found")
+		}
+
+		sp1, err := txn.Savepoint("outer")
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi, []byte("k3"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		sp2, err := txn.Savepoint("inner")
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi, []byte("k4"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = sp1.Release()
+		if err != nil {
+			return err
+		}
+		if err := sp2.Release(); err != ErrSavepointEnded {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !has(txn, "k3") || !has(txn, "k4") {
+			t.Errorf("release did not keep changes")
+		}
+
+		// A savepoint left active is released by Commit.
+		_, err = txn.Savepoint("left")
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("k5"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		for _, k := range []string{"k1", "k3", "k4", "k5"} {
+			if !has(txn, k) {
+				t.Errorf("key %q not committed", k)
+			}
+		}
+		if has(txn, "k2") {
+			t.Errorf("rolled back key committed")
+		}
+		_, err = txn.Savepoint("ro")
+		if err == nil {
+			t.Errorf("savepoint in a read-only transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSavepoint_cursors(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		for _, k := range []string{"a", "b", "c"} {
+			err = txn.Put(dbi, []byte(k), []byte(k), 0)
+			if err != nil {
+				return err
+			}
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		_, _, err = cur.Get([]byte("a"), nil, SetKey)
+		if err != nil {
+			return err
+		}
+
+		sp, err := txn.Savepoint("sp")
+		if err != nil {
+			return err
+		}
+		k, _, err := cur.Get(nil, nil, Next)
+		if err != nil {
+			return err
+		}
+		if string(k) != "b" {
+			t.Errorf("key %q (!= %q)", k, "b")
+		}
+		inner, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		err = inner.Put([]byte("d"), []byte("d"), 0)
+		if err != nil {
+			return err
+		}
+		err = sp.Rollback()
+		if err != nil {
+			return err
+		}
+
+		k, _, err = cur.Get(nil, nil, GetCurrent)
+		if err != nil {
+			return err
+		}
+		if string(k) != "a" {
+			t.Errorf("cursor not restored: key %q (!= %q)", k, "a")
+		}
+		if inner.Txn() != nil {
+			t.Errorf("cursor opened in savepoint not closed")
+		}
+		inner.Close()
+		_, err = txn.Get(dbi, []byte("d"))
+		if !IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package lmdb
+
+import "bytes"
+
+// Helpers positioning a cursor for common access patterns.  They return the
+// item at the new position like Get, or an error for which IsNotFound is true
+// if no item qualifies, in which case the position of the cursor is
+// unspecified.
+
+// notFound returns the error of a positioning helper finding no item.
+func notFound() error {
+	return &OpError{Op: "mdb_cursor_get", Errno: NotFound}
+}
+
+// SeekPrefix positions c at the first item whose key starts with prefix.  An
+// empty prefix positions c at the first item.  Prefixes are only meaningful
+// in databases ordered lexicographically, the default, where the keys with a
+// prefix are contiguous.
+func (c *Cursor) SeekPrefix(prefix []byte) (key, val []byte, err error) {
+	if len(prefix) == 0 {
+		return c.Get(nil, nil, First)
+	}
+	key, val, err = c.Get(prefix, nil, SetRange)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(key, prefix) {
+		return nil, nil, notFound()
+	}
+	return key, val, nil
+}
+
+// LastWithPrefix positions c at the last item whose key starts with prefix,
+// the last duplicate in a DupSort database.  Like SeekPrefix it assumes
+// lexicographic ordering.
+func (c *Cursor) LastWithPrefix(prefix []byte) (key, val []byte, err error) {
+	key, val, err = c.seekBefore(prefixEnd(prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(key, prefix) {
+		return nil, nil, notFound()
+	}
+	return key, val, nil
+}
+
+// FirstInRange positions c at the first item with a key in the half-open
+// interval [start, end), as ordered by the database's comparison function.
+// A nil start or end leaves the interval unbounded on that side.
+func (c *Cursor) FirstInRange(start, end []byte) (key, val []byte, err error) {
+	if start == nil {
+		key, val, err = c.Get(nil, nil, First)
+	} else {
+		key, val, err = c.Get(start, nil, SetRange)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if end != nil && c.txn.Cmp(c.DBI(), key, end) >= 0 {
+		return nil, nil, notFound()
+	}
+	return key, val, nil
+}
+
+// LastInRange positions c at the last item with a key in [start, end), like
+// FirstInRange.  In a DupSort database c is positioned at the last duplicate
+// of the key, so that LastInRange(nil, key) returns the last duplicate of
+// the key preceding key.
+func (c *Cursor) LastInRange(start, end []byte) (key, val []byte, err error) {
+	key, val, err = c.seekBefore(end)
+	if err != nil {
+		return nil, nil, err
+	}
+	if start != nil && c.txn.Cmp(c.DBI(), key, start) < 0 {
+		return nil, nil, notFound()
+	}
+	return key, val, nil
+}
+
+// seekBefore positions c at the last duplicate of the last key before end, or
+// at the last item if end is nil.
+func (c *Cursor) seekBefore(end []byte) (key, val []byte, err error) {
+	if end == nil {
+		return c.Get(nil, nil, Last)
+	}
+	_, _, err = c.Get(end, nil, SetRange)
+	if IsNotFound(err) {
+		return c.Get(nil, nil, Last)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Get(nil, nil, PrevNoDup)
+}
+
+// SeekLastDup positions c at the last duplicate of key.  In a database
+// without the DupSort flag it positions c at key like the Set op.
+func (c *Cursor) SeekLastDup(key []byte) (val []byte, err error) {
+	flags, err := c.txn.Flags(c.DBI())
+	if err != nil {
+		return nil, err
+	}
+	_, val, err = c.Get(key, nil, Set)
+	if err != nil || flags&DupSort == 0 {
+		return val, err
+	}
+	_, val, err = c.Get(nil, nil, LastDup)
+	return val, err
+}
+
+// prefixEnd returns the smallest key greater than all keys starting with p, or
+// nil if there is none.
+func prefixEnd(p []byte) []byte {
+	end := append([]byte(nil), p...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
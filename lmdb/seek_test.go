@@ -0,0 +1,89 @@
+package lmdb
+
+import "testing"
+
+func TestCursor_seek(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("seek", Create|DupSort)
+		if err != nil {
+			return err
+		}
+		for _, item := range [][2]string{
+			{"a", "1"}, {"b/1", "1"}, {"b/1", "2"}, {"b/2", "1"}, {"b/2", "3"}, {"c", "1"},
+		} {
+			err = txn.Put(dbi, []byte(item[0]), []byte(item[1]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		check := func(what, wantk, wantv string, k, v []byte, err error) {
+			t.Helper()
+			if wantk == "" {
+				if !IsNotFound(err) {
+					t.Errorf("%s: %q %q %v, expected NotFound", what, k, v, err)
+				}
+				return
+			}
+			if err != nil || string(k) != wantk || string(v) != wantv {
+				t.Errorf("%s: %q %q %v, expected %q %q", what, k, v, err, wantk, wantv)
+			}
+		}
+
+		k, v, err := cur.SeekPrefix([]byte("b/"))
+		check("SeekPrefix", "b/1", "1", k, v, err)
+		k, v, err = cur.SeekPrefix([]byte("bb"))
+		check("SeekPrefix missing", "", "", k, v, err)
+		k, v, err = cur.SeekPrefix(nil)
+		check("SeekPrefix empty", "a", "1", k, v, err)
+		k, v, err = cur.LastWithPrefix([]byte("b/"))
+		check("LastWithPrefix", "b/2", "3", k, v, err)
+		k, v, err = cur.LastWithPrefix([]byte("c"))
+		check("LastWithPrefix at end", "c", "1", k, v, err)
+		k, v, err = cur.LastWithPrefix([]byte("0"))
+		check("LastWithPrefix missing", "", "", k, v, err)
+
+		k, v, err = cur.FirstInRange([]byte("aa"), []byte("c"))
+		check("FirstInRange", "b/1", "1", k, v, err)
+		k, v, err = cur.FirstInRange(nil, []byte("a"))
+		check("FirstInRange empty", "", "", k, v, err)
+		k, v, err = cur.LastInRange([]byte("a"), []byte("c"))
+		check("LastInRange", "b/2", "3", k, v, err)
+		k, v, err = cur.LastInRange(nil, []byte("b/2"))
+		check("LastInRange before key", "b/1", "2", k, v, err)
+		k, v, err = cur.LastInRange([]byte("b"), nil)
+		check("LastInRange unbounded", "c", "1", k, v, err)
+		k, v, err = cur.LastInRange([]byte("b/3"), []byte("c"))
+		check("LastInRange empty", "", "", k, v, err)
+
+		v, err = cur.SeekLastDup([]byte("b/1"))
+		check("SeekLastDup", "b/1", "2", []byte("b/1"), v, err)
+		_, err = cur.SeekLastDup([]byte("b"))
+		check("SeekLastDup missing", "", "", nil, nil, err)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for _, test := range []struct{ p, end string }{
+		{"a", "b"},
+		{"a\xff", "b"},
+		{"\xff\xff", ""},
+	} {
+		end := prefixEnd([]byte(test.p))
+		if string(end) != test.end || (test.end == "") != (end == nil) {
+			t.Errorf("prefixEnd(%q) = %q", test.p, end)
+		}
+	}
+}
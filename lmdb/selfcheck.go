@@ -0,0 +1,316 @@
+package lmdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+// Diagnosis is returned by Open when an environment opened with WithSelfCheck
+// fails the check.  It lists the problems found and suggests how the
+// environment may be recovered.
+type Diagnosis struct {
+	Path     string   // Path of the data file
+	Problems []string // Inconsistencies found in the data file
+	Recovery []string // Suggested recovery steps
+}
+
+func (d *Diagnosis) Error() string {
+	return fmt.Sprintf("lmdb: %s failed self-check: %s", d.Path, strings.Join(d.Problems, "; "))
+}
+
+// WithSelfCheck makes Open check the consistency of an existing environment
+// before returning it.  The check is bounded: it reads the meta pages and the
+// root page of the free list, the root database and each named database from
+// the data file, without walking the trees.  It catches truncated and
+// overwritten files, which would otherwise crash the process during a later
+// read, but not corruption deeper in the trees.  On failure Open returns a
+// *Diagnosis.
+//
+// Named databases are only checked when WithMaxDBs allows for all of them.
+func WithSelfCheck() EnvOption {
+	return func(c *envConfig) { c.selfCheck = true }
+}
+
+// Constants of the LMDB file format.
+const (
+	fileMagic       = 0xBEEFC0DE
+	fileVersion     = 1
+	filePageBranch  = 0x01
+	filePageLeaf    = 0x02
+	filePageMeta    = 0x08
+	filePageInvalid = ^uint64(0)
+	fileNumMetas    = 2
+)
+
+// fileWord is the size of size_t and pgno_t in the file format, which is
+// that of the host.
+const fileWord = int(unsafe.Sizeof(uintptr(0)))
+
+// filePageHeader is the size of a page header, PAGEHDRSZ.
+const filePageHeader = fileWord + 8
+
+// fileDB is a decoded MDB_db.
+type fileDB struct {
+	pad     uint32
+	flags   uint16
+	depth   uint16
+	entries uint64
+	root    uint64
+}
+
+// fileMeta is a decoded meta page.
+type fileMeta struct {
+	flags   uint16
+	magic   uint32
+	version uint32
+	dbs     [2]fileDB // free list and root database
+	lastPg  uint64
+	txnID   uint64
+}
+
+func fileUint(b []byte) uint64 {
+	if fileWord == 4 {
+		return uint64(NativeEndian.Uint32(b))
+	}
+	return NativeEndian.Uint64(b)
+}
+
+func decodeFileDB(b []byte) fileDB {
+	return fileDB{
+		pad:     NativeEndian.Uint32(b),
+		flags:   NativeEndian.Uint16(b[4:]),
+		depth:   NativeEndian.Uint16(b[6:]),
+		entries: fileUint(b[8+3*fileWord:]),
+		root:    fileUint(b[8+4*fileWord:]),
+	}
+}
+
+// selfCheck reads and checks the data file of an environment.
+type selfCheck struct {
+	f        *os.File
+	size     int64
+	psize    int64
+	meta     *fileMeta
+	problems []string
+	recovery []string
+}
+
+func (ck *selfCheck) problem(format string, args ...interface{}) {
+	ck.problems = append(ck.problems, fmt.Sprintf(format, args...))
+}
+
+func (ck *selfCheck) suggest(s string) {
+	for _, r := range ck.recovery {
+		if r == s {
+			return
+		}
+	}
+	ck.recovery = append(ck.recovery, s)
+}
+
+func (ck *selfCheck) readMeta(off int64) (*fileMeta, error) {
+	b := make([]byte, filePageHeader+8+2*fileWord+2*int(sizeofDB)+2*fileWord)
+	_, err := ck.f.ReadAt(b, off)
+	if err != nil {
+		return nil, err
+	}
+	m := &fileMeta{flags: NativeEndian.Uint16(b[fileWord+2:])}
+	b = b[filePageHeader:]
+	m.magic = NativeEndian.Uint32(b)
+	m.version = NativeEndian.Uint32(b[4:])
+	b = b[8+2*fileWord:]
+	m.dbs[0] = decodeFileDB(b)
+	m.dbs[1] = decodeFileDB(b[sizeofDB:])
+	b = b[2*sizeofDB:]
+	m.lastPg = fileUint(b)
+	m.txnID = fileUint(b[fileWord:])
+	return m, nil
+}
+
+// checkMeta returns true if m is a valid meta page of a file of the checked
+// size.
+func (ck *selfCheck) checkMeta(i int, m *fileMeta) bool {
+	switch {
+	case m.magic != fileMagic:
+		ck.problem("meta page %d has invalid magic %#x", i, m.magic)
+	case m.version != fileVersion:
+		ck.problem("meta page %d has unsupported version %d", i, m.version)
+	case m.flags&filePageMeta == 0:
+		ck.problem("meta page %d has page flags %#x", i, m.flags)
+	default:
+		return true
+	}
+	return false
+}
+
+// checkMetas selects the current meta page like LMDB does and checks that it
+// fits the file.
+func (ck *selfCheck) checkMetas() {
+	m0, err := ck.readMeta(0)
+	if err != nil {
+		ck.problem("reading meta page 0: %v", err)
+		ck.suggest("The data file is too short to be an environment.  Restore it from a backup.")
+		return
+	}
+	ck.psize = int64(m0.dbs[0].pad)
+	if !ck.checkMeta(0, m0) || ck.psize < 512 || ck.psize&(ck.psize-1) != 0 {
+		if len(ck.problems) == 0 {
+			ck.problem("meta page 0 has invalid page size %d", ck.psize)
+		}
+		ck.suggest("The start of the data file was overwritten.  Restore it from a backup.")
+		return
+	}
+
+	m1, err := ck.readMeta(ck.psize)
+	if err != nil {
+		ck.problem("reading meta page 1: %v", err)
+		ck.suggest("The data file was truncated.  Restore it from a backup.")
+		return
+	}
+	valid := [fileNumMetas]bool{true, ck.checkMeta(1, m1)}
+	metas := [fileNumMetas]*fileMeta{m0, m1}
+	cur := 0
+	if valid[1] && m1.txnID > m0.txnID {
+		cur = 1
+	}
+	ck.meta = metas[cur]
+
+	required := (int64(ck.meta.lastPg) + 1) * ck.psize
+	if ck.size < required {
+		ck.problem("data file has %d bytes but pages up to %d are in use (%d bytes)", ck.size, ck.meta.lastPg, required)
+		ck.suggest("The data file was truncated.  Restore it from a backup.")
+	}
+	prev := metas[1-cur]
+	if len(ck.problems) > 0 && valid[1-cur] && prev.txnID < ck.meta.txnID {
+		ck.suggest(fmt.Sprintf("The previous snapshot (transaction %d) is referenced by meta page %d.  "+
+			"It can be opened read-only and copied to a new environment with OpenPrevSnapshot.",
+			prev.txnID, 1-cur))
+	}
+}
+
+// checkDB checks the record of a database and reads its root page.
+func (ck *selfCheck) checkDB(name string, db fileDB) {
+	if db.root == filePageInvalid {
+		if db.depth != 0 || db.entries != 0 {
+			ck.problem("%s has no root page but depth %d and %d entries", name, db.depth, db.entries)
+		}
+		return
+	}
+	if db.root < fileNumMetas || db.root > ck.meta.lastPg {
+		ck.problem("%s has root page %d outside of the pages in use (2-%d)", name, db.root, ck.meta.lastPg)
+		return
+	}
+	if db.depth == 0 {
+		ck.problem("%s has root page %d but depth 0", name, db.root)
+		return
+	}
+	b := make([]byte, filePageHeader)
+	_, err := ck.f.ReadAt(b, int64(db.root)*ck.psize)
+	if err != nil {
+		ck.problem("reading root page %d of %s: %v", db.root, name, err)
+		return
+	}
+	pgno := fileUint(b)
+	flags := NativeEndian.Uint16(b[fileWord+2:])
+	expect := uint16(filePageBranch)
+	if db.depth == 1 {
+		expect = filePageLeaf
+	}
+	if pgno != db.root || flags&(filePageBranch|filePageLeaf) != expect {
+		ck.problem("root page %d of %s is invalid (page number %d, flags %#x)", db.root, name, pgno, flags)
+	}
+}
+
+func (ck *selfCheck) diagnosis() *Diagnosis {
+	if len(ck.problems) == 0 {
+		return nil
+	}
+	ck.suggest("Env.CopyFlag with CopyCompact may salvage readable databases into a new environment.")
+	return &Diagnosis{
+		Path:     ck.f.Name(),
+		Problems: ck.problems,
+		Recovery: ck.recovery,
+	}
+}
+
+// dataFile returns the path of the data file of the environment at path.
+func dataFile(path string, flags uint) string {
+	if flags&NoSubdir != 0 {
+		return path
+	}
+	return filepath.Join(path, "data.mdb")
+}
+
+// openSelfCheck opens the data file at path for checking.  It returns nil if
+// the file does not exist or is empty, in which case Env.Open creates a new
+// environment.
+func openSelfCheck(path string) (*selfCheck, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		f.Close()
+		return nil, nil
+	}
+	return &selfCheck{f: f, size: fi.Size()}, nil
+}
+
+// checkFile checks the meta pages and the roots of the free list and the
+// root database.  It runs before the environment is opened, as the C library
+// may crash reading pages of an inconsistent file.
+func (ck *selfCheck) checkFile() *Diagnosis {
+	ck.checkMetas()
+	if ck.meta != nil && len(ck.problems) == 0 {
+		ck.checkDB("free list", ck.meta.dbs[0])
+		ck.checkDB("root database", ck.meta.dbs[1])
+		if len(ck.problems) > 0 {
+			ck.suggest("Restore the environment from a backup.")
+		}
+	}
+	return ck.diagnosis()
+}
+
+// checkNamed checks the roots of the named databases of the opened env.
+func (ck *selfCheck) checkNamed(env *Env) (*Diagnosis, error) {
+	err := env.View(func(txn *Txn) (err error) {
+		names, err := txn.ListDBIs()
+		if err != nil {
+			return err
+		}
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			v, err := txn.Get(root, []byte(name))
+			if err != nil {
+				return err
+			}
+			ck.checkDB(fmt.Sprintf("database %q", name), decodeFileDB(v))
+		}
+		return nil
+	})
+	if IsErrno(err, DBsFull) {
+		// the remaining databases cannot be opened to be checked.
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ck.problems) > 0 {
+		ck.suggest("Copy the intact databases into a new environment and restore the others from a backup.")
+	}
+	return ck.diagnosis(), nil
+}
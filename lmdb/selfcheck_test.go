@@ -0,0 +1,125 @@
+package lmdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createSelfCheckEnv creates an environment in dir with a named database and
+// returns the root page of the database and the page size.
+func createSelfCheckEnv(t *testing.T, dir string) (root uint64, psize int64) {
+	env, err := Open(dir, WithMaxDBs(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	err = env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("db", Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 100; i++ {
+			err = txn.Put(dbi, []byte{byte(i)}, make([]byte, 100), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) (err error) {
+		main, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		v, err := txn.Get(main, []byte("db"))
+		if err != nil {
+			return err
+		}
+		root = decodeFileDB(v).root
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := env.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, int64(stat.PSize)
+}
+
+func TestOpen_selfCheck(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		corrupt func(f *os.File, root uint64, psize int64) error
+		problem string
+	}{
+		{"ok", func(f *os.File, root uint64, psize int64) error { return nil }, ""},
+		{"truncated", func(f *os.File, root uint64, psize int64) error {
+			return f.Truncate(3 * psize)
+		}, "pages up to"},
+		{"magic", func(f *os.File, root uint64, psize int64) error {
+			_, err := f.WriteAt(make([]byte, 64), 0)
+			return err
+		}, "meta page 0"},
+		{"root", func(f *os.File, root uint64, psize int64) error {
+			_, err := f.WriteAt(make([]byte, psize), int64(root)*psize)
+			return err
+		}, `database "db"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "mdb_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// a new environment passes the check.
+			env, err := Open(dir, WithSelfCheck())
+			if err != nil {
+				t.Fatal(err)
+			}
+			env.Close()
+
+			root, psize := createSelfCheckEnv(t, dir)
+			f, err := os.OpenFile(filepath.Join(dir, "data.mdb"), os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.corrupt(f, root, psize)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			env, err = Open(dir, WithMaxDBs(2), WithSelfCheck())
+			if test.problem == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				env.Close()
+				return
+			}
+			if err == nil {
+				env.Close()
+				t.Fatalf("corruption not detected")
+			}
+			d, ok := err.(*Diagnosis)
+			if !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(d.Error(), test.problem) {
+				t.Errorf("unexpected diagnosis: %v", d)
+			}
+			if len(d.Recovery) == 0 {
+				t.Errorf("no recovery suggested")
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package lmdb
+
+// The methods in this file treat a DupSort database as a map from keys to
+// sets of members, the duplicate values of each key.
+
+// AddMember adds member to the set stored under key in the DupSort database
+// dbi.  AddMember returns false if member was already in the set.
+func (txn *Txn) AddMember(dbi DBI, key, member []byte) (bool, error) {
+	err := txn.Put(dbi, key, member, NoDupData)
+	if IsErrno(err, KeyExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RemoveMember removes member from the set stored under key in the DupSort
+// database dbi.  The key is deleted with its last member.  RemoveMember
+// returns false if member was not in the set.
+func (txn *Txn) RemoveMember(dbi DBI, key, member []byte) (bool, error) {
+	err := txn.Del(dbi, key, member)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// HasMember returns true if member is in the set stored under key in the
+// DupSort database dbi.
+func (txn *Txn) HasMember(dbi DBI, key, member []byte) (bool, error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return false, err
+	}
+	defer cur.Close()
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+	_, _, err = cur.Get(key, member, GetBoth)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetCard returns the number of members of the set stored under key in the
+// DupSort database dbi, zero if key does not exist.
+//
+// See mdb_cursor_count.
+func (txn *Txn) SetCard(dbi DBI, key []byte) (uint64, error) {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close()
+	raw := txn.RawRead
+	txn.RawRead = true
+	defer func() { txn.RawRead = raw }()
+	_, _, err = cur.Get(key, nil, SetKey)
+	if IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cur.Count()
+}
+
+// Members calls fn with each member of the set stored under key in the
+// DupSort database dbi, in order, and returns the first error returned by fn.
+// Members of a DupFixed database are read a page at a time with GetMultiple.
+// Like the values returned by Txn.Get, members reference memory owned by LMDB
+// if txn.RawRead is set and are only valid until fn returns.  A missing key
+// is an empty set.
+func (txn *Txn) Members(dbi DBI, key []byte, fn func(member []byte) error) error {
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		return err
+	}
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	_, first, err := cur.Get(key, nil, SetKey)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if flags&DupFixed != 0 && len(first) > 0 {
+		// A single member is not stored on a page of duplicates and
+		// is read like other members.
+		n, err := cur.Count()
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			return membersFixed(cur, len(first), fn)
+		}
+	}
+	for {
+		err = fn(first)
+		if err != nil {
+			return err
+		}
+		_, first, err = cur.Get(nil, nil, NextDup)
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// membersFixed calls fn with the members of size bytes of the current key of
+// cur, which is positioned at its first duplicate.
+func membersFixed(cur *Cursor, size int, fn func(member []byte) error) error {
+	for op := uint(GetMultiple); ; op = NextMultiple {
+		_, page, err := cur.Get(nil, nil, op)
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i+size <= len(page); i += size {
+			err = fn(page[i : i+size : i+size])
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package lmdb
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_Members(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	for _, flags := range []uint{DupSort, DupSort | DupFixed} {
+		err := env.Update(func(txn *Txn) (err error) {
+			name := "set"
+			if flags&DupFixed != 0 {
+				name = "fixed"
+			}
+			dbi, err := txn.OpenDBI(name, Create|flags)
+			if err != nil {
+				return err
+			}
+
+			// Enough members to span several pages with GetMultiple.
+			const n = 3000
+			member := func(i int) []byte {
+				b := make([]byte, 8)
+				binary.BigEndian.PutUint64(b, uint64(i))
+				return b
+			}
+			for i := n - 1; i >= 0; i-- {
+				ok, err := txn.AddMember(dbi, []byte("k"), member(i))
+				if err != nil || !ok {
+					t.Fatalf("%s: add %d: %v %v", name, i, ok, err)
+				}
+			}
+			ok, err := txn.AddMember(dbi, []byte("k"), member(0))
+			if err != nil || ok {
+				t.Errorf("%s: add existing member: %v %v", name, ok, err)
+			}
+			_, err = txn.AddMember(dbi, []byte("l"), member(0))
+			if err != nil {
+				return err
+			}
+
+			card, err := txn.SetCard(dbi, []byte("k"))
+			if err != nil || card != n {
+				t.Errorf("%s: card %d %v", name, card, err)
+			}
+			card, err = txn.SetCard(dbi, []byte("missing"))
+			if err != nil || card != 0 {
+				t.Errorf("%s: card of missing key %d %v", name, card, err)
+			}
+
+			for i, expect := range []bool{true, false} {
+				ok, err := txn.HasMember(dbi, []byte("k"), member(i*n))
+				if err != nil || ok != expect {
+					t.Errorf("%s: has member %d: %v %v", name, i*n, ok, err)
+				}
+			}
+
+			var got []int
+			err = txn.Members(dbi, []byte("k"), func(m []byte) error {
+				got = append(got, int(binary.BigEndian.Uint64(m)))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(got) != n {
+				t.Fatalf("%s: %d members", name, len(got))
+			}
+			for i := range got {
+				if got[i] != i {
+					t.Fatalf("%s: member %d is %d", name, i, got[i])
+				}
+			}
+
+			got = got[:0]
+			err = txn.Members(dbi, []byte("l"), func(m []byte) error {
+				got = append(got, int(binary.BigEndian.Uint64(m)))
+				return nil
+			})
+			if err != nil || !reflect.DeepEqual(got, []int{0}) {
+				t.Errorf("%s: members of a single member set %v %v", name, got, err)
+			}
+
+			ok, err = txn.RemoveMember(dbi, []byte("l"), member(0))
+			if err != nil || !ok {
+				t.Errorf("%s: remove: %v %v", name, ok, err)
+			}
+			ok, err = txn.RemoveMember(dbi, []byte("l"), member(0))
+			if err != nil || ok {
+				t.Errorf("%s: remove missing member: %v %v", name, ok, err)
+			}
+			for _, key := range []string{"l", "missing"} {
+				var none [][]byte
+				err = txn.Members(dbi, []byte(key), func(m []byte) error {
+					none = append(none, m)
+					return nil
+				})
+				if err != nil || !reflect.DeepEqual(none, [][]byte(nil)) {
+					t.Errorf("%s: members of empty set %q: %q %v", name, key, none, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package lmdb
+
+import (
+	"sort"
+	"time"
+)
+
+// StarvationReport describes the state of an environment while a write
+// transaction waits for the writer lock.
+//
+// The writer lock is held by another write transaction, which LMDB does not
+// identify.  Writers are also slowed down by readers holding old snapshots:
+// pages freed after the oldest snapshot in use cannot be reused, so writers
+// must allocate new pages and the database grows.  The report lists those
+// readers so that a stuck process or thread can be found.
+type StarvationReport struct {
+	Waited    time.Duration // Time the write transaction had been waiting
+	LastTxnID int64         // ID of the last committed transaction
+
+	// Readers are the readers holding a snapshot older than the last
+	// committed transaction, oldest first.  A reader lags behind by
+	// LastTxnID - ReaderInfo.TxnID transactions.
+	Readers []ReaderInfo
+}
+
+// starvationCheck is the configuration set by SetStarvationCheck.
+type starvationCheck struct {
+	threshold time.Duration
+	fn        func(*StarvationReport)
+}
+
+// SetStarvationCheck reports write transactions which have been waiting for
+// the writer lock for threshold.  The report is logged with the Logger set by
+// SetLogger at level Warn and passed to fn, if it is not nil, from a separate
+// goroutine while the transaction is still waiting.  A zero threshold
+// disables the check.  SetStarvationCheck must not be called while a write
+// transaction is beginning.
+func (env *Env) SetStarvationCheck(threshold time.Duration, fn func(*StarvationReport)) {
+	if threshold <= 0 {
+		env.starvation = nil
+		return
+	}
+	env.starvation = &starvationCheck{threshold: threshold, fn: fn}
+}
+
+// StarvationReport returns a report for a write transaction that has been
+// waiting for the writer lock for waited.  It is used by SetStarvationCheck
+// and may be called directly, for example by a handler for a signal.
+func (env *Env) StarvationReport(waited time.Duration) (*StarvationReport, error) {
+	info, err := env.Info()
+	if err != nil {
+		return nil, err
+	}
+	stale, err := env.StaleReaders()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].TxnID < stale[j].TxnID })
+	return &StarvationReport{
+		Waited:    waited,
+		LastTxnID: info.LastTxnID,
+		Readers:   stale,
+	}, nil
+}
+
+// watchWriterLock runs the starvation check of env if a write transaction
+// beginning now is still waiting for the writer lock after the threshold.
+// The returned function must be called once the lock was acquired.
+func (env *Env) watchWriterLock() (stop func()) {
+	c := env.starvation
+	if c == nil {
+		return func() {}
+	}
+	start := time.Now()
+	t := time.AfterFunc(c.threshold, func() {
+		r, err := env.StarvationReport(time.Since(start))
+		if l := env.logger; l != nil {
+			if err != nil {
+				l.Error("lmdb: starvation report failed", "err", err)
+			} else {
+				args := []interface{}{"waited", r.Waited, "last_txn", r.LastTxnID, "stale_readers", len(r.Readers)}
+				if len(r.Readers) > 0 {
+					oldest := r.Readers[0]
+					args = append(args, "oldest_pid", oldest.PID, "oldest_thread", oldest.Thread,
+						"oldest_txn", oldest.TxnID, "readers", r.Readers)
+				}
+				l.Warn("lmdb: write transaction waiting for the writer lock", args...)
+			}
+		}
+		if err == nil && c.fn != nil {
+			c.fn(r)
+		}
+	})
+	return func() { t.Stop() }
+}
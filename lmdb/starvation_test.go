@@ -0,0 +1,80 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnv_SetStarvationCheck(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(txn *Txn) error {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	}
+	err = env.Update(put)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Abort()
+	err = env.Update(put)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan *StarvationReport, 1)
+	env.SetStarvationCheck(10*time.Millisecond, func(r *StarvationReport) {
+		reports <- r
+	})
+	defer env.SetStarvationCheck(0, nil)
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 2)
+	go func() {
+		done <- env.Update(func(txn *Txn) error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+	go func() {
+		done <- env.Update(put)
+	}()
+
+	var r *StarvationReport
+	select {
+	case r = <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no starvation report")
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if r.Waited < 10*time.Millisecond {
+		t.Errorf("waited %v", r.Waited)
+	}
+	if len(r.Readers) != 1 {
+		t.Fatalf("readers %+v", r.Readers)
+	}
+	if r.Readers[0].PID != os.Getpid() || r.Readers[0].TxnID != int64(reader.ID()) {
+		t.Errorf("reader %+v (txn %d)", r.Readers[0], reader.ID())
+	}
+	if r.LastTxnID <= r.Readers[0].TxnID {
+		t.Errorf("last txn %d", r.LastTxnID)
+	}
+}
@@ -0,0 +1,84 @@
+package lmdb
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// envSyncer is the background goroutine started by Env.StartSync.
+type envSyncer struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartSync starts a goroutine that calls Sync(force) about every interval.
+// It bounds the transactions lost in a system crash when env has the NoSync or
+// MapAsync flag (see DurabilityVolatile and DurabilityAsync), which is what
+// force should be false for: the environment is then only flushed if those
+// flags are set.  Each wait is randomized by up to a tenth of interval so that
+// processes syncing several environments don't flush them all at once.  If fn
+// is not nil it is called from the goroutine with any error returned by Sync.
+//
+// The goroutine runs until StopSync or Close is called, which sync env a last
+// time.  Because it references env, an Env with a running sync is never
+// finalized and must be closed explicitly.  StartSync returns an error if a
+// sync goroutine is already running.
+func (env *Env) StartSync(interval time.Duration, force bool, fn func(err error)) error {
+	if interval <= 0 {
+		return errors.New("lmdb: sync interval must be positive")
+	}
+	if env.syncer != nil {
+		return errors.New("lmdb: sync already running")
+	}
+	s := &envSyncer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	env.syncer = s
+	sync := func() {
+		err := env.Sync(force)
+		if err != nil && fn != nil {
+			fn(err)
+		}
+	}
+	go func() {
+		defer close(s.done)
+		timer := time.NewTimer(syncJitter(interval))
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.stop:
+				sync()
+				return
+			case <-timer.C:
+			}
+			sync()
+			timer.Reset(syncJitter(interval))
+		}
+	}()
+	return nil
+}
+
+// syncJitter returns interval randomized by up to a tenth in either
+// direction.
+func syncJitter(interval time.Duration) time.Duration {
+	j := int64(interval / 10)
+	if j <= 0 {
+		return interval
+	}
+	return interval - time.Duration(j) + time.Duration(rand.Int63n(2*j+1))
+}
+
+// StopSync stops the goroutine started by StartSync after it synced env a
+// last time and waits for it to exit.  StopSync does nothing if no sync is
+// running.
+func (env *Env) StopSync() {
+	s := env.syncer
+	if s == nil {
+		return
+	}
+	env.syncer = nil
+	close(s.stop)
+	<-s.done
+}
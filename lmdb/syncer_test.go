@@ -0,0 +1,70 @@
+package lmdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnv_StartSync(t *testing.T) {
+	env := setupFlags(t, NoSync)
+	defer clean(env, t)
+
+	err := env.StartSync(0, false, nil)
+	if err == nil {
+		t.Errorf("expected error for zero interval")
+	}
+
+	errs := make(chan error, 1)
+	err = env.StartSync(time.Millisecond, false, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.StartSync(time.Millisecond, false, nil)
+	if err == nil {
+		t.Errorf("expected error starting a second sync")
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	env.StopSync()
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error: %v", err)
+	default:
+	}
+
+	// stopping twice is harmless and the sync may be restarted.
+	env.StopSync()
+	err = env.StartSync(time.Millisecond, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// clean closes env, which stops the sync.
+}
+
+func TestSyncJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := syncJitter(time.Second)
+		if d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("jitter out of range: %v", d)
+		}
+	}
+	if d := syncJitter(5); d != 5 {
+		t.Errorf("unexpected jitter: %v", d)
+	}
+}
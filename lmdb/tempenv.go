@@ -0,0 +1,60 @@
+package lmdb
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// Defaults of environments opened by NewTempEnv.
+const (
+	DefaultTempMapSize = 64 << 20
+	DefaultTempMaxDBs  = 16
+)
+
+// NewTempEnv opens an environment in a new temporary directory, which is
+// removed when the Env is closed.  The environment is intended for tests: its
+// map size is DefaultTempMapSize, it allows DefaultTempMaxDBs named databases
+// and it is opened with NoSync, since its contents need not survive a crash.
+// Options given in opts are applied after these defaults.
+//
+// The directory is created in the default directory for temporary files,
+// which is named by $TMPDIR on Unix systems.  Pointing it at a tmpfs keeps
+// test environments in memory.
+func NewTempEnv(opts ...EnvOption) (*Env, error) {
+	dir, err := ioutil.TempDir("", "lmdb-")
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]EnvOption{
+		WithMapSize(DefaultTempMapSize),
+		WithMaxDBs(DefaultTempMaxDBs),
+		WithFlags(NoSync),
+	}, opts...)
+	env, err := Open(dir, opts...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	env.tempdir = dir
+	return env, nil
+}
+
+// TB is the part of testing.TB used by NewTestEnv.
+type TB interface {
+	Helper()
+	Fatal(args ...interface{})
+	Cleanup(func())
+}
+
+// NewTestEnv returns an environment opened by NewTempEnv and closes it when
+// the test finishes.  The test fails immediately if the environment cannot be
+// opened.
+func NewTestEnv(tb TB, opts ...EnvOption) *Env {
+	tb.Helper()
+	env, err := NewTempEnv(opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { env.Close() })
+	return env
+}
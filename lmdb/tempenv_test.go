@@ -0,0 +1,60 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNewTempEnv(t *testing.T) {
+	env, err := NewTempEnv(WithMapSize(1 << 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MapSize != 1<<20 {
+		t.Errorf("map size %d (!= %d)", info.MapSize, 1<<20)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		_, err = txn.OpenDBI("named", Create)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("directory not removed: %v", err)
+	}
+}
+
+func TestNewTestEnv(t *testing.T) {
+	var path string
+	t.Run("sub", func(t *testing.T) {
+		env := NewTestEnv(t)
+		var err error
+		path, err = env.Path()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = openRoot(env, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	_, err := os.Stat(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("directory not removed: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package lmdb
+
+import (
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TxnTrace describes a read-only transaction tracked by an Env with
+// transaction tracking enabled.
+type TxnTrace struct {
+	ID    uintptr   // The snapshot viewed by the transaction
+	Start time.Time // Time the transaction began or was last renewed
+	Stack string    // Stack of the goroutine that began or renewed the transaction
+}
+
+// txnTracker holds the read-only transactions active in an Env.
+type txnTracker struct {
+	enabled int32
+	mu      sync.Mutex
+	next    uint64
+	txns    map[uint64]TxnTrace
+}
+
+func (t *txnTracker) add(txn *Txn) {
+	if atomic.LoadInt32(&t.enabled) == 0 {
+		return
+	}
+	buf := make([]byte, 4096)
+	buf = buf[:runtime.Stack(buf, false)]
+	trace := TxnTrace{
+		ID:    txn.ID(),
+		Start: time.Now(),
+		Stack: string(buf),
+	}
+	t.mu.Lock()
+	if t.txns == nil {
+		t.txns = make(map[uint64]TxnTrace)
+	}
+	t.next++
+	txn.trackid = t.next
+	t.txns[txn.trackid] = trace
+	t.mu.Unlock()
+}
+
+func (t *txnTracker) remove(txn *Txn) {
+	if txn.trackid == 0 {
+		return
+	}
+	t.mu.Lock()
+	delete(t.txns, txn.trackid)
+	t.mu.Unlock()
+	txn.trackid = 0
+}
+
+// SetTxnTracking enables or disables the tracking of read-only transactions
+// in env.  While tracking is enabled the time and stack trace at which each
+// read-only transaction begins, or is renewed, are recorded until the
+// transaction terminates or is reset.  LongTxns reports the transactions that
+// have been open for too long.
+//
+// A read-only transaction that is never terminated, a leaked reader, prevents
+// the reuse of pages freed after its snapshot and causes the database to grow
+// without bound.  Tracking is intended for locating such leaks and capturing
+// stacks adds noticeable overhead to each transaction.  Disabling tracking
+// discards the transactions tracked so far.
+func (env *Env) SetTxnTracking(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&env.tracker.enabled, 1)
+		return
+	}
+	atomic.StoreInt32(&env.tracker.enabled, 0)
+	env.tracker.mu.Lock()
+	env.tracker.txns = nil
+	env.tracker.mu.Unlock()
+}
+
+// LongTxns returns the tracked read-only transactions that have been open for
+// at least d, oldest first.  LongTxns returns nil unless SetTxnTracking has
+// been called to enable tracking.
+func (env *Env) LongTxns(d time.Duration) []TxnTrace {
+	now := time.Now()
+	var traces []TxnTrace
+	env.tracker.mu.Lock()
+	for _, trace := range env.tracker.txns {
+		if now.Sub(trace.Start) >= d {
+			traces = append(traces, trace)
+		}
+	}
+	env.tracker.mu.Unlock()
+	sort.Slice(traces, func(i, j int) bool { return traces[i].Start.Before(traces[j].Start) })
+	return traces
+}
+
+// LogLongTxns logs the transactions returned by LongTxns(d) using the standard
+// logger and returns the number logged.
+func (env *Env) LogLongTxns(d time.Duration) int {
+	traces := env.LongTxns(d)
+	for _, trace := range traces {
+		log.Printf("lmdb: read-only transaction %d open for %v, begun at:\n%s",
+			trace.ID, time.Since(trace.Start), trace.Stack)
+	}
+	return len(traces)
+}
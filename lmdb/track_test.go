@@ -0,0 +1,69 @@
+package lmdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnv_SetTxnTracking(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traces := env.LongTxns(0); len(traces) != 0 {
+		t.Errorf("unexpected traces with tracking disabled: %v", traces)
+	}
+	txn.Abort()
+
+	env.SetTxnTracking(true)
+
+	txn, err = env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	traces := env.LongTxns(0)
+	if len(traces) != 1 {
+		t.Fatalf("unexpected traces: %v", traces)
+	}
+	if !strings.Contains(traces[0].Stack, "TestEnv_SetTxnTracking") {
+		t.Errorf("unexpected stack: %s", traces[0].Stack)
+	}
+	if traces := env.LongTxns(time.Hour); len(traces) != 0 {
+		t.Errorf("unexpected long traces: %v", traces)
+	}
+
+	txn.Reset()
+	if traces := env.LongTxns(0); len(traces) != 0 {
+		t.Errorf("unexpected traces after reset: %v", traces)
+	}
+	err = txn.Renew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traces := env.LongTxns(0); len(traces) != 1 {
+		t.Errorf("unexpected traces after renew: %v", traces)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		if n := env.LogLongTxns(0); n != 2 {
+			t.Errorf("unexpected number of long transactions: %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn.Abort()
+	if traces := env.LongTxns(0); len(traces) != 0 {
+		t.Errorf("unexpected traces after abort: %v", traces)
+	}
+
+	env.SetTxnTracking(false)
+}
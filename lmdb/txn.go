@@ -3,6 +3,7 @@ package lmdb
 /*
 #include <stdlib.h>
 #include <stdio.h>
+#include <errno.h>
 #include "lmdb.h"
 #include "lmdbgo.h"
 */
@@ -11,6 +12,7 @@ import "C"
 import (
 	"log"
 	"runtime"
+	"time"
 	"unsafe"
 )
 
@@ -55,6 +57,11 @@ type Txn struct {
 	// finalizations.
 	Pooled bool
 
+	// If Arena is not nil and RawRead is false, []byte values retrieved from
+	// Get() calls on the Txn and its cursors are copied into Arena rather than
+	// newly allocated.  Such slices remain valid until the Arena is reset.
+	Arena *Arena
+
 	managed  bool
 	readonly bool
 
@@ -63,6 +70,9 @@ type Txn struct {
 	// reset/renewed
 	id uintptr
 
+	// committed is the ID of a top-level write txn once it has committed.
+	committed uintptr
+
 	// Pointer to scratch space for key and val in readonly transactions
 	cbuf unsafe.Pointer
 
@@ -71,12 +81,82 @@ type Txn struct {
 	key  *C.MDB_val
 	val  *C.MDB_val
 
+	// maxKey caches the largest key accepted by env, see maxKeySize.
+	maxKey int
+
 	errLogf func(format string, v ...interface{})
+
+	// intent summarizes the changes made by a write transaction when intent
+	// journaling is enabled.  It is shared with subtransactions but only the
+	// top-level transaction holds the journal it is recorded in.
+	intent  *txnIntent
+	journal *intentJournal
+
+	// stats points to statsv, or to the counters of the parent in a
+	// subtransaction.
+	stats  *TxnStats
+	statsv TxnStats
+
+	// dbiStats points to dbiStatsv, or to the counters of the parent in a
+	// subtransaction.  It is nil when writes are not counted per database.
+	dbiStats  *[]DBIStats
+	dbiStatsv []DBIStats
+
+	// dry logs the writes of a transaction run by Env.DryRun.  drymark is
+	// the length of the log when a subtransaction began.
+	dry     *dryRun
+	drymark int
+
+	// gen is incremented when Vals obtained from txn are invalidated.  It
+	// points to genv, or to the generation of the parent in a
+	// subtransaction.
+	gen  *uint64
+	genv uint64
+
+	// trackid identifies a read-only txn held by env.tracker.  It is zero
+	// when txn is not tracked.
+	trackid uint64
+
+	// watch monitors the duration of a top-level write txn when env has a
+	// WriteWatchdog.
+	watch *txnWatch
+
+	// parent is set for subtransactions, which pass their hooks to parent
+	// when they commit.
+	parent   *Txn
+	onCommit []func(txnID uintptr)
+	onAbort  []func()
+
+	// savepoints are the active savepoints of txn, innermost last.  While
+	// one is active _txn is its nested transaction.
+	savepoints []*Savepoint
 }
 
 // beginTxn does not lock the OS thread which is a prerequisite for creating a
 // write transaction.
 func beginTxn(env *Env, parent *Txn, flags uint) (*Txn, error) {
+	if env.guard && flags&Readonly == 0 {
+		return nil, ErrReadonly
+	}
+	if flags&Readonly != 0 && env.filecheck {
+		err := env.CheckFileSize()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parent == nil && flags&Readonly == 0 {
+		err := env.freeze.enter()
+		if err != nil {
+			return nil, err
+		}
+		if env.limiter != nil {
+			err = env.limiter.wait()
+			if err != nil {
+				env.freeze.exit()
+				return nil, err
+			}
+		}
+	}
 	txn := &Txn{
 		readonly: (flags&Readonly != 0),
 		env:      env,
@@ -111,11 +191,56 @@ func beginTxn(env *Env, parent *Txn, flags uint) (*Txn, error) {
 		ptxn = parent._txn
 		txn.key = parent.key
 		txn.val = parent.val
+		txn.intent = parent.intent
+		txn.stats = parent.stats
+		txn.dbiStats = parent.dbiStats
+		txn.gen = parent.gen
+		txn.parent = parent
+		if parent.dry != nil {
+			txn.dry = parent.dry
+			txn.drymark = len(parent.dry.ops)
+		}
+	}
+	if txn.stats == nil {
+		txn.stats = &txn.statsv
+		if env.commitStats != nil {
+			txn.dbiStats = &txn.dbiStatsv
+		}
+	}
+	if txn.gen == nil {
+		txn.gen = &txn.genv
+	}
+	if parent == nil && flags&Readonly == 0 && env.journal != nil {
+		txn.intent = new(txnIntent)
+		txn.journal = env.journal
+	}
+	var ret C.int
+	if parent == nil && !txn.readonly {
+		stop := env.watchWriterLock()
+		ret = C.mdb_txn_begin(env._env, ptxn, C.uint(flags), &txn._txn)
+		stop()
+	} else {
+		// Close and ShrinkInPlace hold closeLock while the C environment
+		// is replaced.
+		env.closeLock.RLock()
+		if env._env == nil {
+			ret = C.EINVAL
+		} else {
+			ret = C.mdb_txn_begin(env._env, ptxn, C.uint(flags), &txn._txn)
+		}
+		env.closeLock.RUnlock()
 	}
-	ret := C.mdb_txn_begin(env._env, ptxn, C.uint(flags), &txn._txn)
 	if ret != success {
+		if parent == nil && !txn.readonly {
+			env.freeze.exit()
+		}
 		return nil, operrno("mdb_txn_begin", ret)
 	}
+	if txn.readonly {
+		env.tracker.add(txn)
+	} else if parent == nil {
+		env.watch(txn)
+	}
 	return txn, nil
 }
 
@@ -206,9 +331,121 @@ func (txn *Txn) Commit() error {
 }
 
 func (txn *Txn) commit() error {
+	if len(txn.savepoints) > 0 {
+		err := txn.endSavepoints(0, true)
+		if err != nil {
+			txn.abort()
+			return err
+		}
+	}
+	if txn.watch != nil && txn.watch.isExpired() {
+		txn.abort()
+		return ErrTxnTimeout
+	}
+	if txn.journal != nil {
+		err := txn.journal.record(txn.intent)
+		if err != nil {
+			txn.abort()
+			return err
+		}
+	}
+	var id uintptr
+	if txn.parent == nil && !txn.readonly {
+		id = txn.ID()
+	}
+	var start time.Time
+	if id != 0 && (txn.env.slowCommit > 0 && txn.env.logger != nil || txn.env.commitStats != nil) {
+		start = time.Now()
+	}
 	ret := C.mdb_txn_commit(txn._txn)
+	var d time.Duration
+	if !start.IsZero() {
+		d = time.Since(start)
+	}
+	if txn.env.slowCommit > 0 && txn.env.logger != nil && d >= txn.env.slowCommit {
+		txn.env.logger.Warn("lmdb: slow commit", "txn", id, "duration", d,
+			"puts", txn.stats.Puts, "dels", txn.stats.Dels)
+	}
 	txn.clearTxn()
-	return operrno("mdb_txn_commit", ret)
+	if txn.journal != nil {
+		// A failure to clear the journal is ignored.  The intent left behind
+		// is stale but the outcome of the commit matters more to the caller.
+		txn.journal.clear()
+		txn.journal = nil
+	}
+	if id != 0 {
+		if ret == success {
+			txn.env.counters.commit(txn.stats)
+		} else {
+			txn.env.counters.abort()
+		}
+	}
+	if ret != success {
+		txn.runAbortHooks()
+	} else if txn.parent != nil {
+		txn.parent.onCommit = append(txn.parent.onCommit, txn.onCommit...)
+		txn.parent.onAbort = append(txn.parent.onAbort, txn.onAbort...)
+	} else {
+		txn.committed = id
+		if fn := txn.env.commitStats; fn != nil && id != 0 {
+			fn(&CommitStats{
+				TxnID:    id,
+				Duration: d,
+				TxnStats: *txn.stats,
+				DBIs:     txn.DBIStats(),
+			})
+		}
+		txn.runCommitHooks(id)
+	}
+	return txn.errno("mdb_txn_commit", ret)
+}
+
+// CommitID returns the ID of txn after it has been committed successfully.
+// Commit IDs increase with every write transaction committed to the
+// environment, so they can serve as sequence numbers for replication or
+// cache invalidation.  CommitID returns 0 if txn is read-only, is a
+// subtransaction, or has not been committed.  Transactions run by Env.Update
+// can use OnCommit to learn their ID instead.
+func (txn *Txn) CommitID() uintptr {
+	return txn.committed
+}
+
+// OnCommit registers fn to be called after txn has been committed
+// successfully.  The ID of the committed transaction is passed to fn.
+// Functions are called in the order they were registered, in the goroutine
+// that commits txn and after the write lock has been released.  If txn is a
+// subtransaction fn is only called once the top-level transaction commits.
+//
+// OnCommit allows side effects such as cache invalidation or notifications
+// to be deferred until the outcome of a transaction is known.
+func (txn *Txn) OnCommit(fn func(txnID uintptr)) {
+	txn.onCommit = append(txn.onCommit, fn)
+}
+
+// OnAbort registers fn to be called after txn is aborted, either explicitly
+// or because Commit failed.  Functions are called in the order they were
+// registered.  If txn is a subtransaction that commits then fn is called only
+// if its parent is aborted.
+func (txn *Txn) OnAbort(fn func()) {
+	txn.onAbort = append(txn.onAbort, fn)
+}
+
+func (txn *Txn) runCommitHooks(id uintptr) {
+	hooks := txn.onCommit
+	txn.onCommit = nil
+	txn.onAbort = nil
+	for _, fn := range hooks {
+		fn(id)
+	}
+}
+
+func (txn *Txn) runAbortHooks() {
+	hooks := txn.onAbort
+	txn.onCommit = nil
+	txn.onAbort = nil
+	for _, fn := range hooks {
+		fn()
+	}
 }
 
 // Abort discards pending writes in the transaction and clears the finalizer on
@@ -228,6 +465,10 @@ func (txn *Txn) abort() {
 	if txn._txn == nil {
 		return
 	}
+	if len(txn.savepoints) > 0 {
+		// mdb_txn_abort also aborts the nested transactions.
+		txn.dropSavepoints()
+	}
 
 	// Get a read-lock on the environment so we can abort txn if needed.
 	// txn.env **should** terminate all readers otherwise when it closes.
@@ -236,11 +477,29 @@ func (txn *Txn) abort() {
 		C.mdb_txn_abort(txn._txn)
 	}
 	txn.env.closeLock.RUnlock()
+	if txn.parent == nil && !txn.readonly {
+		txn.env.counters.abort()
+	}
 
+	if txn.dry != nil && txn.parent != nil {
+		txn.dry.rollback(txn.drymark)
+	}
 	txn.clearTxn()
+	txn.runAbortHooks()
 }
 
 func (txn *Txn) clearTxn() {
+	txn.env.tracker.remove(txn)
+	// The Vals of a subtransaction share the generation of its parent.
+	*txn.gen++
+	if txn.parent == nil && !txn.readonly && txn._txn != nil {
+		txn.env.freeze.exit()
+	}
+	if txn.watch != nil {
+		txn.watch.stop(txn)
+		txn.watch = nil
+	}
+
 	// Clear the C object to prevent any potential future use of the freed
 	// pointer.
 	txn._txn = nil
@@ -283,6 +542,8 @@ func (txn *Txn) Reset() {
 }
 
 func (txn *Txn) reset() {
+	txn.env.tracker.remove(txn)
+	txn.genv++
 	C.mdb_txn_reset(txn._txn)
 }
 
@@ -309,6 +570,11 @@ func (txn *Txn) renew() error {
 	// results in the freeing of stale pages the Txn has been holding, though
 	// this has not been confirmed in any way by bmatsuo as of 2017-02-15.
 	txn.resetID()
+	txn.statsv = TxnStats{}
+	txn.dbiStatsv = txn.dbiStatsv[:0]
+	if ret == success {
+		txn.env.tracker.add(txn)
+	}
 
 	return operrno("mdb_txn_renew", ret)
 }
@@ -329,6 +595,9 @@ func (txn *Txn) OpenDBI(name string, flags uint) (DBI, error) {
 	cname := C.CString(name)
 	dbi, err := txn.openDBI(cname, flags)
 	C.free(unsafe.Pointer(cname))
+	if err == nil {
+		txn.env.dbis.opened(name, dbi)
+	}
 	return dbi, err
 }
 
@@ -348,7 +617,11 @@ func (txn *Txn) Flags(dbi DBI) (uint, error) {
 // does not require env.SetMaxDBs() to be called beforehand.  And, OpenRoot can
 // be called without flags in a View transaction.
 func (txn *Txn) OpenRoot(flags uint) (DBI, error) {
-	return txn.openDBI(nil, flags)
+	dbi, err := txn.openDBI(nil, flags)
+	if err == nil {
+		txn.env.dbis.opened("", dbi)
+	}
+	return dbi, err
 }
 
 // openDBI returns returns whatever DBI value was set by mdb_open_dbi.  In an
@@ -385,8 +658,23 @@ func (txn *Txn) Stat(dbi DBI) (*Stat, error) {
 //
 // See mdb_drop.
 func (txn *Txn) Drop(dbi DBI, del bool) error {
+	if txn.intent != nil {
+		txn.intent.drop(dbi)
+	}
+	*txn.gen++
+	if txn.dry != nil {
+		// A deleted handle would not be restored by aborting the dry run.
+		del = false
+	}
 	ret := C.mdb_drop(txn._txn, C.MDB_dbi(dbi), cbool(del))
-	return operrno("mdb_drop", ret)
+	if ret == success && txn.dry != nil {
+		txn.dry.record("drop", dbi, nil, 0)
+	}
+	if ret == success && del {
+		// mdb_drop closes the handle immediately, even if txn is aborted.
+		txn.env.dbis.forget(dbi)
+	}
+	return txn.dbiErrno("mdb_drop", dbi, ret)
 }
 
 // Sub executes fn in a subtransaction.  Sub commits the subtransaction iff a
@@ -424,6 +712,9 @@ func (txn *Txn) bytes(val *C.MDB_val) []byte {
 	if txn.RawRead {
 		return getBytes(val)
 	}
+	if txn.Arena != nil {
+		return txn.arenaBytes(val)
+	}
 	return getBytesCopy(val)
 }
 
@@ -433,13 +724,17 @@ func (txn *Txn) bytes(val *C.MDB_val) []byte {
 //
 // See mdb_get.
 func (txn *Txn) Get(dbi DBI, key []byte) ([]byte, error) {
+	if err := txn.checkKey("mdb_get", key); err != nil {
+		return nil, err
+	}
+	txn.stats.Gets++
 	kdata, kn := valBytes(key)
 	ret := C.lmdbgo_mdb_get(
 		txn._txn, C.MDB_dbi(dbi),
 		(*C.char)(unsafe.Pointer(&kdata[0])), C.size_t(kn),
 		txn.val,
 	)
-	err := operrno("mdb_get", ret)
+	err := txn.dbiErrno("mdb_get", dbi, ret)
 	if err != nil {
 		*txn.val = C.MDB_val{}
 		return nil, err
@@ -449,16 +744,49 @@ func (txn *Txn) Get(dbi DBI, key []byte) ([]byte, error) {
 	return b, nil
 }
 
+// GetCopy is like Get but always returns a newly allocated copy of the value,
+// regardless of txn.RawRead and txn.Arena.  It suits values retained after txn
+// terminates in a transaction that otherwise reads raw values.
+func (txn *Txn) GetCopy(dbi DBI, key []byte) ([]byte, error) {
+	raw, arena := txn.RawRead, txn.Arena
+	txn.RawRead, txn.Arena = false, nil
+	val, err := txn.Get(dbi, key)
+	txn.RawRead, txn.Arena = raw, arena
+	return val, err
+}
+
+// GetRaw is like Get with txn.RawRead set.  The returned slice references a
+// readonly section of memory that must not be accessed after txn has
+// terminated.
+func (txn *Txn) GetRaw(dbi DBI, key []byte) ([]byte, error) {
+	raw := txn.RawRead
+	txn.RawRead = true
+	val, err := txn.Get(dbi, key)
+	txn.RawRead = raw
+	return val, err
+}
+
 func (txn *Txn) putNilKey(dbi DBI, flags uint) error {
 	// mdb_put with an empty key will always fail
 	ret := C.lmdbgo_mdb_put2(txn._txn, C.MDB_dbi(dbi), nil, 0, nil, 0, C.uint(flags))
-	return operrno("mdb_put", ret)
+	return txn.dbiErrno("mdb_put", dbi, ret)
 }
 
 // Put stores an item in database dbi.
 //
 // See mdb_put.
 func (txn *Txn) Put(dbi DBI, key []byte, val []byte, flags uint) error {
+	if err := txn.checkKey("mdb_put", key); err != nil {
+		return err
+	}
+	if err := checkVal("mdb_put", len(val)); err != nil {
+		return err
+	}
+	txn.countPut(dbi, 1, uint64(len(key)+len(val)))
+	*txn.gen++
+	if txn.intent != nil {
+		txn.intent.put(dbi, key)
+	}
 	kn := len(key)
 	if kn == 0 {
 		return txn.putNilKey(dbi, flags)
@@ -474,13 +802,96 @@ func (txn *Txn) Put(dbi DBI, key []byte, val []byte, flags uint) error {
 		(*C.char)(unsafe.Pointer(&val[0])), C.size_t(vn),
 		C.uint(flags),
 	)
-	return operrno("mdb_put", ret)
+	if ret == success && txn.dry != nil {
+		txn.dry.record("put", dbi, key, vn)
+	}
+	return txn.dbiErrno("mdb_put", dbi, ret)
+}
+
+// KV is a key-value pair stored by Txn.PutMany.
+type KV struct {
+	Key []byte
+	Val []byte
+}
+
+// PutMany stores the items in pairs in database dbi, in order, with a single
+// call into the C library.  The keys and values are copied into one buffer
+// before the call so that the cgo overhead is paid once per batch instead of
+// once per item.  Combined with the Append flag and sorted pairs PutMany is an
+// efficient way to bulk load a database.
+//
+// PutMany returns the number of pairs stored.  A pair with an empty or too
+// long key or a too long value is reported before any pair is stored, with
+// the error Put would return.  If another error is encountered the pairs
+// before it remain stored in txn and callers will typically want to abort the
+// transaction.
+//
+// See mdb_put.
+func (txn *Txn) PutMany(dbi DBI, pairs []KV, flags uint) (int, error) {
+	if len(pairs) == 0 {
+		return 0, nil
+	}
+
+	// The pairs are checked like Put checks them before any is stored.
+	size := 1
+	for i := range pairs {
+		if len(pairs[i].Key) == 0 {
+			return 0, txn.dbiErrno("mdb_put", dbi, C.MDB_BAD_VALSIZE)
+		}
+		if err := txn.checkKey("mdb_put", pairs[i].Key); err != nil {
+			return 0, err
+		}
+		if err := checkVal("mdb_put", len(pairs[i].Val)); err != nil {
+			return 0, err
+		}
+		size += len(pairs[i].Key) + len(pairs[i].Val)
+	}
+	// The extra byte ensures that data[0] may be referenced even when all keys
+	// and values are empty.
+	data := make([]byte, 0, size)
+	sizes := make([]C.size_t, 2*len(pairs))
+	txn.countPut(dbi, uint64(len(pairs)), uint64(size-1))
+	*txn.gen++
+	for i := range pairs {
+		if txn.intent != nil {
+			txn.intent.put(dbi, pairs[i].Key)
+		}
+		data = append(data, pairs[i].Key...)
+		data = append(data, pairs[i].Val...)
+		sizes[2*i] = C.size_t(len(pairs[i].Key))
+		sizes[2*i+1] = C.size_t(len(pairs[i].Val))
+	}
+	data = append(data, 0)
+
+	var count C.size_t
+	ret := C.lmdbgo_mdb_putmany(
+		txn._txn, C.MDB_dbi(dbi),
+		(*C.char)(unsafe.Pointer(&data[0])), &sizes[0], C.size_t(len(pairs)),
+		C.uint(flags), &count,
+	)
+	if txn.dry != nil {
+		for i := 0; i < int(count); i++ {
+			txn.dry.record("put", dbi, pairs[i].Key, len(pairs[i].Val))
+		}
+	}
+	return int(count), txn.dbiErrno("mdb_put", dbi, ret)
 }
 
 // PutReserve returns a []byte of length n that can be written to, potentially
 // avoiding a memcopy.  The returned byte slice is only valid in txn's thread,
 // before it has terminated.
 func (txn *Txn) PutReserve(dbi DBI, key []byte, n int, flags uint) ([]byte, error) {
+	if err := txn.checkKey("mdb_put", key); err != nil {
+		return nil, err
+	}
+	if err := checkVal("mdb_put", n); err != nil {
+		return nil, err
+	}
+	txn.countPut(dbi, 1, uint64(len(key)+n))
+	*txn.gen++
+	if txn.intent != nil {
+		txn.intent.put(dbi, key)
+	}
 	if len(key) == 0 {
 		return nil, txn.putNilKey(dbi, flags)
 	}
@@ -491,29 +902,117 @@ func (txn *Txn) PutReserve(dbi DBI, key []byte, n int, flags uint) ([]byte, erro
 		txn.val,
 		C.uint(flags|C.MDB_RESERVE),
 	)
-	err := operrno("mdb_put", ret)
+	err := txn.dbiErrno("mdb_put", dbi, ret)
 	if err != nil {
 		*txn.val = C.MDB_val{}
 		return nil, err
 	}
+	if txn.dry != nil {
+		txn.dry.record("put", dbi, key, n)
+	}
 	b := getBytes(txn.val)
 	*txn.val = C.MDB_val{}
 	return b, nil
 }
 
+// PutReserveFunc reserves n bytes for the value of key like PutReserve and
+// calls fill to write the value.  The buffer passed to fill must not be
+// retained after fill returns; it references pages that later writes in txn
+// may move or reuse.  Unlike with PutReserve the buffer is not returned, so
+// it cannot accidentally be written after later writes in txn or after txn
+// terminates.
+//
+// If fill returns an error PutReserveFunc returns it and the value of key is
+// left with unspecified contents, so the transaction should be aborted.
+func (txn *Txn) PutReserveFunc(dbi DBI, key []byte, n int, flags uint, fill func(b []byte) error) error {
+	b, err := txn.PutReserve(dbi, key, n, flags)
+	if err != nil {
+		return err
+	}
+	return fill(b)
+}
+
 // Del deletes an item from database dbi.  Del ignores val unless dbi has the
-// DupSort flag.
+// DupSort flag.  If dbi has the DupSort flag and val is nil all duplicates of
+// key are deleted, otherwise only the duplicate val is deleted.
 //
 // See mdb_del.
 func (txn *Txn) Del(dbi DBI, key, val []byte) error {
+	if err := txn.checkKey("mdb_del", key); err != nil {
+		return err
+	}
+	txn.countDel(dbi)
+	*txn.gen++
+	if txn.intent != nil {
+		txn.intent.del(dbi, key)
+	}
 	kdata, kn := valBytes(key)
-	vdata, vn := valBytes(val)
+	var vp *C.char
+	var vn int
+	if val != nil {
+		var vdata []byte
+		vdata, vn = valBytes(val)
+		vp = (*C.char)(unsafe.Pointer(&vdata[0]))
+	}
 	ret := C.lmdbgo_mdb_del(
 		txn._txn, C.MDB_dbi(dbi),
 		(*C.char)(unsafe.Pointer(&kdata[0])), C.size_t(kn),
-		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
+		vp, C.size_t(vn),
 	)
-	return operrno("mdb_del", ret)
+	if ret == success && txn.dry != nil {
+		txn.dry.record("del", dbi, key, 0)
+	}
+	return txn.dbiErrno("mdb_del", dbi, ret)
+}
+
+// UpdateFunc computes the new value for key given its current value, old.  If
+// key does not exist old is nil.  Returning a non-nil value stores it under
+// key, returning del as true deletes key, and returning a nil value with del
+// as false leaves key unchanged.
+type UpdateFunc func(key, old []byte) (val []byte, del bool, err error)
+
+// Update applies fn to each key in keys using a single cursor, storing or
+// deleting items in dbi according to its result.  Update stops and returns the
+// first error returned by fn or encountered writing to dbi.  Processing keys in
+// sorted order gives the best performance.  Update must not be used on
+// databases with the DupSort flag.
+//
+// Update is not related to Env.Update, it must be called on a write
+// transaction.
+func (txn *Txn) Update(dbi DBI, keys [][]byte, fn UpdateFunc) error {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	for _, key := range keys {
+		exists := true
+		_, old, err := cur.Get(key, nil, SetKey)
+		if IsNotFound(err) {
+			exists = false
+		} else if err != nil {
+			return err
+		}
+
+		val, del, err := fn(key, old)
+		if err != nil {
+			return err
+		}
+		switch {
+		case del && exists:
+			err = cur.Del(0)
+		case del || val == nil:
+		case exists:
+			err = cur.Put(key, val, Current)
+		default:
+			err = cur.Put(key, val, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // OpenCursor allocates and initializes a Cursor to database dbi.
@@ -524,6 +1023,10 @@ func (txn *Txn) OpenCursor(dbi DBI) (*Cursor, error) {
 	if cur != nil && txn.readonly {
 		runtime.SetFinalizer(cur, (*Cursor).close)
 	}
+	if cur != nil && len(txn.savepoints) > 0 {
+		sp := txn.savepoints[len(txn.savepoints)-1]
+		sp.cursors = append(sp.cursors, cur)
+	}
 	return cur, err
 }
 
@@ -269,6 +269,44 @@ func TestTxn_Del_dup(t *testing.T) {
 	}
 }
 
+func TestTxn_Del_dupAll(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	for _, flags := range []uint{DupSort, DupSort | DupFixed} {
+		err := env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenDBI(fmt.Sprintf("dup%#x", flags), Create|flags)
+			if err != nil {
+				return err
+			}
+			for _, v := range []string{"v1", "v2", "v3"} {
+				err = txn.Put(dbi, []byte("k"), []byte(v), 0)
+				if err != nil {
+					return err
+				}
+			}
+
+			// A nil value deletes all duplicates.
+			err = txn.Del(dbi, []byte("k"), nil)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Get(dbi, []byte("k"))
+			if !IsNotFound(err) {
+				t.Errorf("%#x: get: %v", flags, err)
+			}
+			err = txn.Del(dbi, []byte("k"), nil)
+			if !IsNotFound(err) {
+				t.Errorf("%#x: del: %v", flags, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestTexn_Put_emptyValue(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -337,6 +375,174 @@ func TestTxn_PutReserve(t *testing.T) {
 	}
 }
 
+func TestTxn_PutReserveFunc(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var db DBI
+	errFill := fmt.Errorf("fill failed")
+	err := env.Update(func(txn *Txn) (err error) {
+		db, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		err = txn.PutReserveFunc(db, []byte("k"), 5, 0, func(b []byte) error {
+			if len(b) != 5 || cap(b) != 5 {
+				t.Errorf("buffer len %d cap %d (!= 5)", len(b), cap(b))
+			}
+			copy(b, "hello")
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = txn.PutReserveFunc(db, []byte("k"), 1, NoOverwrite, func(b []byte) error {
+			t.Error("fill called for an existing key")
+			return nil
+		})
+		if !IsErrno(err, KeyExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		err = txn.PutReserveFunc(db, []byte("k2"), 1, 0, func(b []byte) error {
+			return errFill
+		})
+		if err != errFill {
+			t.Errorf("unexpected error: %v (!= %v)", err, errFill)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		v, err := txn.Get(db, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "hello" {
+			return fmt.Errorf("value: %q", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxn_PutMany(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var db DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		db, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		pairs := []KV{
+			{Key: []byte("k0"), Val: []byte("v0")},
+			{Key: []byte("k1"), Val: nil},
+			{Key: []byte("k2"), Val: []byte("v2")},
+		}
+		n, err := txn.PutMany(db, pairs, Append)
+		if err != nil {
+			return err
+		}
+		if n != len(pairs) {
+			t.Errorf("stored: %d (!= %d)", n, len(pairs))
+		}
+
+		// the second pair violates the Append ordering.
+		n, err = txn.PutMany(db, []KV{{Key: []byte("k3")}, {Key: []byte("k")}}, Append)
+		if !IsErrno(err, KeyExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("stored: %d (!= 1)", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		for k, expect := range map[string]string{"k0": "v0", "k1": "", "k2": "v2", "k3": ""} {
+			v, err := txn.Get(db, []byte(k))
+			if err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			if string(v) != expect {
+				t.Errorf("value %s: %q (!= %q)", k, v, expect)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxn_Update_keys(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var db DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		db, err = txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			err = txn.Put(db, []byte(k), []byte(k), 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+		return txn.Update(db, keys, func(key, old []byte) ([]byte, bool, error) {
+			switch string(key) {
+			case "a":
+				return append([]byte("new-"), old...), false, nil
+			case "b":
+				return nil, true, nil
+			case "c":
+				return nil, false, nil
+			default:
+				if old != nil {
+					t.Errorf("unexpected old value for %q: %q", key, old)
+				}
+				return []byte("created"), false, nil
+			}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		for k, expect := range map[string]string{"a": "new-a", "c": "c", "d": "created"} {
+			v, err := txn.Get(db, []byte(k))
+			if err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			if string(v) != expect {
+				t.Errorf("value %s: %q (!= %q)", k, v, expect)
+			}
+		}
+		_, err = txn.Get(db, []byte("b"))
+		if !IsNotFound(err) {
+			t.Errorf("key b not deleted: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestTxn_bytesBuffer(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -1458,3 +1664,271 @@ func openDBI(env *Env, key string, flags uint) (DBI, error) {
 	}
 	return db, nil
 }
+
+func TestTxn_Stats(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		for _, k := range []string{"a", "b", "c"} {
+			err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = txn.Get(dbi, []byte("a"))
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) (err error) {
+			return txn.Del(dbi, []byte("c"), nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		_, _, err = cur.Get(nil, nil, First)
+		if err != nil {
+			return err
+		}
+		err = cur.Put([]byte("d"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+
+		stats := txn.Stats()
+		expect := TxnStats{Gets: 1, Puts: 4, Dels: 1, PutBytes: 8, CursorOps: 1}
+		if stats != expect {
+			t.Errorf("unexpected stats: %+v (!= %+v)", stats, expect)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	_, err = txn.Get(dbi, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.Stats().Gets != 1 {
+		t.Errorf("unexpected stats: %+v", txn.Stats())
+	}
+	txn.Reset()
+	err = txn.Renew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.Stats() != (TxnStats{}) {
+		t.Errorf("unexpected stats after renew: %+v", txn.Stats())
+	}
+}
+
+func TestTxn_OnCommit(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	var commitID uintptr
+	var txnID uintptr
+	err = env.Update(func(txn *Txn) (err error) {
+		txnID = txn.ID()
+		txn.OnCommit(func(id uintptr) {
+			commitID = id
+			events = append(events, "commit1")
+		})
+		txn.OnAbort(func() { events = append(events, "abort1") })
+		err = txn.Sub(func(txn *Txn) (err error) {
+			txn.OnCommit(func(uintptr) { events = append(events, "commit2") })
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) (err error) {
+			txn.OnCommit(func(uintptr) { events = append(events, "commit3") })
+			txn.OnAbort(func() { events = append(events, "abort3") })
+			return fmt.Errorf("sub aborted")
+		})
+		if err == nil {
+			return fmt.Errorf("expected error")
+		}
+		if len(events) != 1 || events[0] != "abort3" {
+			t.Errorf("unexpected events after sub abort: %q", events)
+		}
+		events = nil
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != "commit1" || events[1] != "commit2" {
+		t.Errorf("unexpected events after commit: %q", events)
+	}
+	if commitID != txnID {
+		t.Errorf("unexpected txn id: %d (!= %d)", commitID, txnID)
+	}
+
+	events = nil
+	err = env.Update(func(txn *Txn) (err error) {
+		txn.OnCommit(func(uintptr) { events = append(events, "commit") })
+		err = txn.Sub(func(txn *Txn) (err error) {
+			txn.OnAbort(func() { events = append(events, "abort2") })
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		txn.OnAbort(func() { events = append(events, "abort1") })
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(events) != 2 || events[0] != "abort2" || events[1] != "abort1" {
+		t.Errorf("unexpected events after abort: %q", events)
+	}
+}
+
+func TestTxn_CommitID(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := env.LastTxnID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	if err != nil {
+		txn.Abort()
+		t.Fatal(err)
+	}
+	if id := txn.CommitID(); id != 0 {
+		t.Errorf("commit id %d before commit", id)
+	}
+	err = txn.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := txn.CommitID()
+	if id != last+1 {
+		t.Errorf("commit id %d (!= %d)", id, last+1)
+	}
+	last, err = env.LastTxnID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != id {
+		t.Errorf("last txn id %d (!= %d)", last, id)
+	}
+
+	txn, err = env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txn.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := txn.CommitID(); id != 0 {
+		t.Errorf("commit id %d for a read-only txn", id)
+	}
+}
+
+func TestTxn_GetCopy(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		txn.Arena = NewArena(0)
+		v1, err := txn.GetRaw(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		v2, err := txn.GetCopy(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(v1) != "v" || string(v2) != "v" {
+			t.Errorf("values %q %q", v1, v2)
+		}
+		if &v1[0] == &v2[0] {
+			t.Errorf("GetCopy returned raw memory")
+		}
+		v3, err := txn.Get(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if &v1[0] != &v3[0] {
+			t.Errorf("GetRaw did not return raw memory")
+		}
+		if !txn.RawRead || txn.Arena == nil {
+			t.Errorf("settings of txn not restored")
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		k1, v1, err := cur.GetRaw(nil, nil, First)
+		if err != nil {
+			return err
+		}
+		k2, v2, err := cur.GetCopy(nil, nil, GetCurrent)
+		if err != nil {
+			return err
+		}
+		if string(k2) != "k" || string(v2) != "v" {
+			t.Errorf("item %q=%q", k2, v2)
+		}
+		if &k1[0] == &k2[0] || &v1[0] == &v2[0] {
+			t.Errorf("Cursor.GetCopy returned raw memory")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,31 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+// errno is like operrno but records the operations of txn in the *OpError
+// returned for MDB_TXN_FULL.
+func (txn *Txn) errno(op string, ret C.int) error {
+	err := operrno(op, ret)
+	if ret == C.MDB_TXN_FULL {
+		if operr, ok := err.(*OpError); ok {
+			operr.Puts, operr.Dels = txn.stats.Puts, txn.stats.Dels
+		}
+	}
+	return err
+}
+
+// errno is like Txn.dbiErrno for the cursor's transaction and database.
+func (c *Cursor) errno(op string, ret C.int) error {
+	if c.txn == nil || ret == success {
+		return operrno(op, ret)
+	}
+	return c.txn.dbiErrno(op, c.DBI(), ret)
+}
+
+// _errno is for use by tests that can't import C.
+func (txn *Txn) _errno(op string, ret int) error {
+	return txn.errno(op, C.int(ret))
+}
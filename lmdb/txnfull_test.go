@@ -0,0 +1,51 @@
+package lmdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTxn_errnoTxnFull(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = txn.Del(dbi, []byte("k"), nil)
+		if err != nil {
+			return err
+		}
+
+		err = txn._errno("mdb_put", int(TxnFull))
+		if !IsErrno(err, TxnFull) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		operr, ok := err.(*OpError)
+		if !ok {
+			t.Fatalf("unexpected error type: %T", err)
+		}
+		if operr.Puts != 1 || operr.Dels != 1 {
+			t.Errorf("unexpected counts: %+v", operr)
+		}
+		if !strings.Contains(err.Error(), "1 puts") {
+			t.Errorf("unexpected message: %v", err)
+		}
+
+		// other errors are returned unchanged.
+		err = txn._errno("mdb_put", int(MapFull))
+		if operr, ok := err.(*OpError); !ok || !IsMapFull(err) || operr.Puts != 0 {
+			t.Errorf("unexpected error: %#v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+package lmdb
+
+// TxnStats counts the operations performed within a transaction.  The
+// operations of a subtransaction are included in the counts of its parent,
+// even if the subtransaction is aborted.  Operations are always counted, the
+// counters are plain increments in the transaction's memory.
+type TxnStats struct {
+	Gets      uint64 // Calls to Txn.Get
+	Puts      uint64 // Items written with Txn or Cursor methods
+	Dels      uint64 // Items deleted with Txn or Cursor methods
+	PutBytes  uint64 // Bytes of the keys and values of the items written
+	CursorOps uint64 // Calls to Cursor.Get, Cursor.GetBatch, and Cursor.Count
+}
+
+// Stats returns the operations counted in txn since it began or was last
+// renewed.  Stats is not to be confused with Stat which returns statistics
+// about a database.
+func (txn *Txn) Stats() TxnStats {
+	return *txn.stats
+}
+
+// stats returns the operation counters of the cursor's transaction.  A closed
+// cursor has no transaction.
+func (c *Cursor) stats() *TxnStats {
+	if c.txn == nil {
+		return nil
+	}
+	return c.txn.stats
+}
@@ -0,0 +1,77 @@
+package lmdb
+
+/*
+#include "lmdbgo.h"
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// userctx is the key of a Go value attached to an environment, which is stored
+// in place of the pointer taken by mdb_env_set_userctx.  Go values are kept in
+// an external map for the same reason as msgctx values.  The zero userctx
+// refers to no value.
+type userctx uintptr
+
+var userctxn uint32
+var userctxm = map[userctx]interface{}{}
+var userctxmlock sync.RWMutex
+
+func (ctx userctx) get() interface{} {
+	userctxmlock.RLock()
+	v := userctxm[ctx]
+	userctxmlock.RUnlock()
+	return v
+}
+
+// SetUserContext attaches v to env, replacing any value attached before.  The
+// value can be retrieved with Env.UserContext or Txn.UserContext, which lets
+// transaction functions and handlers that only receive an Env or a Txn reach
+// application state without global variables.  A nil v detaches the current
+// value.  The value is released when env is closed.
+//
+// See mdb_env_set_userctx.
+func (env *Env) SetUserContext(v interface{}) error {
+	userctxmlock.Lock()
+	defer userctxmlock.Unlock()
+	var ctx userctx
+	if v != nil {
+		ctx = userctx(atomic.AddUint32(&userctxn, 1))
+		userctxm[ctx] = v
+	}
+	ret := C.lmdbgo_mdb_env_set_userctx(env._env, C.size_t(ctx))
+	if ret != success {
+		delete(userctxm, ctx)
+		return operrno("mdb_env_set_userctx", ret)
+	}
+	delete(userctxm, env.userctx)
+	env.userctx = ctx
+	return nil
+}
+
+// UserContext returns the value attached to env with SetUserContext, or nil.
+//
+// See mdb_env_get_userctx.
+func (env *Env) UserContext() interface{} {
+	if env._env == nil {
+		return nil
+	}
+	return userctx(C.lmdbgo_mdb_env_get_userctx(env._env)).get()
+}
+
+// UserContext returns the value attached to the environment of txn with
+// Env.SetUserContext, or nil.
+func (txn *Txn) UserContext() interface{} {
+	return txn.env.UserContext()
+}
+
+// releaseUserContext detaches the value of env when it is closed.
+func (env *Env) releaseUserContext() {
+	userctxmlock.Lock()
+	delete(userctxm, env.userctx)
+	env.userctx = 0
+	userctxmlock.Unlock()
+}
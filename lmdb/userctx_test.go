@@ -0,0 +1,76 @@
+package lmdb
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnv_SetUserContext(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	if env.UserContext() != nil {
+		t.Errorf("unexpected context: %v", env.UserContext())
+	}
+
+	type config struct{ name string }
+	cfg := &config{"a"}
+	err := env.SetUserContext(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) (err error) {
+		if txn.UserContext().(*config) != cfg {
+			t.Errorf("unexpected context: %v", txn.UserContext())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replacing the value releases the previous one.
+	err = env.SetUserContext("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.UserContext() != "b" {
+		t.Errorf("unexpected context: %v", env.UserContext())
+	}
+	userctxmlock.RLock()
+	n := len(userctxm)
+	userctxmlock.RUnlock()
+	if n != 1 {
+		t.Errorf("unexpected number of registered values: %d", n)
+	}
+
+	err = env.SetUserContext(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.UserContext() != nil {
+		t.Errorf("unexpected context: %v", env.UserContext())
+	}
+}
+
+func TestEnv_SetUserContext_close(t *testing.T) {
+	env := setup(t)
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.SetUserContext(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := env.userctx
+	env.Close()
+	defer os.RemoveAll(path)
+
+	if ctx.get() != nil {
+		t.Errorf("value was not released on close")
+	}
+	if env.UserContext() != nil {
+		t.Errorf("unexpected context after close: %v", env.UserContext())
+	}
+}
@@ -112,6 +112,10 @@ func wrapVal(b []byte) *C.MDB_val {
 }
 
 func getBytes(val *C.MDB_val) []byte {
+	// Some cursor ops, such as GetMultiple, do not set the key.
+	if val.mv_data == nil {
+		return nil
+	}
 	return (*[valMaxSize]byte)(unsafe.Pointer(val.mv_data))[:val.mv_size:val.mv_size]
 }
 
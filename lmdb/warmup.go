@@ -0,0 +1,68 @@
+package lmdb
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// warmupChunk is the number of bytes Warmup faults in between checks of its
+// context.
+const warmupChunk = 8 << 20
+
+// Warmup faults in the pages of env in use, so that they are in the page
+// cache before the application serves requests from a cold start.  Where
+// available Warmup uses MADV_POPULATE_READ, and otherwise it reads one byte of
+// every page of the memory map.  Warmup runs in a read-only transaction and
+// takes a while for large environments.  If progress is not nil it is called
+// after every few megabytes with the number of bytes faulted in and the total.
+//
+// Warmup returns ctx.Err() if ctx is done before it completes.  Like
+// Env.Advise it returns an error if the memory map cannot be located, which
+// is the case for an empty environment not opened with FixedMap.  Whether the
+// pages stay in memory is up to the operating system.
+func (env *Env) Warmup(ctx context.Context, progress func(done, total int64)) error {
+	return env.View(func(txn *Txn) error {
+		base, err := txn.mapBase()
+		if err != nil {
+			return err
+		}
+		info, err := env.Info()
+		if err != nil {
+			return err
+		}
+		stat, err := env.Stat()
+		if err != nil {
+			return err
+		}
+		total := (info.LastPNO + 1) * int64(stat.PSize)
+		pagesize := os.Getpagesize()
+		canPopulate := true
+		var x byte
+		for off := int64(0); off < total; off += warmupChunk {
+			err = ctx.Err()
+			if err != nil {
+				return err
+			}
+			n := total - off
+			if n > warmupChunk {
+				n = warmupChunk
+			}
+			chunk := unsafe.Add(base, off)
+			if canPopulate {
+				canPopulate = populate(chunk, uintptr(n))
+			}
+			if !canPopulate {
+				for i := 0; i < int(n); i += pagesize {
+					x += *(*byte)(unsafe.Add(chunk, i))
+				}
+			}
+			if progress != nil {
+				progress(off+n, total)
+			}
+		}
+		runtime.KeepAlive(x)
+		return nil
+	})
+}
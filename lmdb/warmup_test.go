@@ -0,0 +1,59 @@
+package lmdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnv_Warmup(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Warmup(context.Background(), nil)
+	if err != errMapUnknown {
+		t.Errorf("unexpected error for an empty environment: %v", err)
+	}
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for i := 0; i < 2000; i++ {
+			err = txn.Put(dbi, []byte{byte(i >> 8), byte(i)}, make([]byte, 100), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var done, total int64
+	err = env.Warmup(context.Background(), func(d, t int64) { done, total = d, t })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total == 0 || done != total {
+		t.Errorf("progress %d/%d", done, total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = env.Warmup(ctx, nil)
+	if err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnv_Warmup_fixedMap(t *testing.T) {
+	env := setupFlags(t, FixedMap)
+	defer clean(env, t)
+
+	err := env.Warmup(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
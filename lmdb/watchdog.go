@@ -0,0 +1,161 @@
+package lmdb
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTxnTimeout is returned by Txn.Commit when the transaction was aborted
+// because it exceeded the timeout of an Env's WriteWatchdog.
+var ErrTxnTimeout = errors.New("lmdb: write transaction exceeded watchdog timeout")
+
+// WriteWatchdog monitors the duration of write transactions.  Because LMDB
+// permits only one writer at a time a slow write transaction blocks every
+// other writer in the application.
+type WriteWatchdog struct {
+	// Timeout is the duration after which a write transaction is considered
+	// slow.
+	Timeout time.Duration
+
+	// Func is called from a separate goroutine when a write transaction has
+	// been running for Timeout, and called again from the transaction's
+	// goroutine once the slow transaction terminates.  Func should return
+	// quickly because Commit and Abort do not return until the second call
+	// does.
+	Func func(*SlowTxn)
+
+	// If Abort is true a transaction that exceeds Timeout cannot be
+	// committed.  Commit aborts it and returns ErrTxnTimeout instead.
+	// Operations performed by the transaction after the timeout are not
+	// interrupted, since a Txn may only be used by one goroutine.
+	Abort bool
+}
+
+// SlowTxn describes a write transaction that exceeded the timeout of a
+// WriteWatchdog.
+type SlowTxn struct {
+	Start   time.Time     // Time the transaction began
+	Elapsed time.Duration // Time the transaction had been running
+	Stack   string        // Stack of the goroutine running the transaction when the timeout fired
+
+	// Done is false when the transaction exceeded the timeout and true when
+	// the transaction has terminated.  Stats is only set when Done is true.
+	Done  bool
+	Stats TxnStats
+}
+
+// txnWatch is the state of a write transaction monitored by a WriteWatchdog.
+type txnWatch struct {
+	w       *WriteWatchdog
+	start   time.Time
+	gid     []byte
+	timer   *time.Timer
+	fired   int32
+	expired int32
+	slow    SlowTxn
+}
+
+// SetWriteWatchdog sets the watchdog monitoring write transactions in env.  A
+// nil w disables monitoring.  SetWriteWatchdog must not be called while a
+// write transaction is active.
+func (env *Env) SetWriteWatchdog(w *WriteWatchdog) {
+	env.watchdog = w
+}
+
+// watch begins monitoring txn if env has a watchdog.
+func (env *Env) watch(txn *Txn) {
+	w := env.watchdog
+	if w == nil || w.Timeout <= 0 {
+		return
+	}
+	tw := &txnWatch{
+		w:     w,
+		start: time.Now(),
+		gid:   goroutineID(),
+	}
+	tw.timer = time.AfterFunc(w.Timeout, tw.fire)
+	txn.watch = tw
+}
+
+func (tw *txnWatch) fire() {
+	tw.slow = SlowTxn{
+		Start:   tw.start,
+		Elapsed: time.Since(tw.start),
+		Stack:   goroutineStack(tw.gid),
+	}
+	if tw.w.Abort {
+		atomic.StoreInt32(&tw.expired, 1)
+	}
+	if tw.w.Func != nil {
+		tw.w.Func(&tw.slow)
+	}
+	atomic.StoreInt32(&tw.fired, 1)
+}
+
+// stop ends monitoring of txn.  If the timeout fired the watchdog function is
+// called with the final statistics of txn.
+func (tw *txnWatch) stop(txn *Txn) {
+	if tw.timer.Stop() {
+		return
+	}
+	// The timer has fired.  Wait for fire to return so that the watchdog
+	// function is never called concurrently for a single transaction.
+	for atomic.LoadInt32(&tw.fired) == 0 {
+		runtime.Gosched()
+	}
+	if tw.w.Func != nil {
+		slow := tw.slow
+		slow.Elapsed = time.Since(tw.start)
+		slow.Done = true
+		slow.Stats = *txn.stats
+		tw.w.Func(&slow)
+	}
+}
+
+func (tw *txnWatch) isExpired() bool {
+	return atomic.LoadInt32(&tw.expired) != 0
+}
+
+// goroutineID returns the id of the calling goroutine as it appears in stack
+// traces.
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	i := bytes.IndexByte(buf, ' ')
+	if i < 0 {
+		return nil
+	}
+	if _, err := strconv.ParseUint(string(buf[:i]), 10, 64); err != nil {
+		return nil
+	}
+	return buf[:i]
+}
+
+// goroutineStack returns the stack of the goroutine with the given id, or an
+// empty string if it cannot be found.
+func goroutineStack(gid []byte) string {
+	if gid == nil {
+		return ""
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := append(append([]byte("goroutine "), gid...), " ["...)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, prefix) {
+			return string(stack)
+		}
+	}
+	return ""
+}
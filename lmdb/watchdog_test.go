@@ -0,0 +1,101 @@
+package lmdb
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnv_SetWriteWatchdog(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var events []SlowTxn
+	env.SetWriteWatchdog(&WriteWatchdog{
+		Timeout: 10 * time.Millisecond,
+		Func: func(slow *SlowTxn) {
+			mu.Lock()
+			events = append(events, *slow)
+			mu.Unlock()
+		},
+	})
+
+	// a fast transaction does not trigger the watchdog
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	n := len(events)
+	mu.Unlock()
+	if n != 0 {
+		t.Errorf("unexpected events: %v", events)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if events[0].Done || !strings.Contains(events[0].Stack, "TestEnv_SetWriteWatchdog") {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+	if !events[1].Done || events[1].Elapsed < 50*time.Millisecond || events[1].Stats.Puts != 1 {
+		t.Errorf("unexpected event: %+v", events[1])
+	}
+}
+
+func TestEnv_SetWriteWatchdog_abort(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.SetWriteWatchdog(&WriteWatchdog{
+		Timeout: 10 * time.Millisecond,
+		Abort:   true,
+	})
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != ErrTxnTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env.SetWriteWatchdog(nil)
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k"))
+		return err
+	})
+	if !IsNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package lmdb
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrValInvalid is returned by Val.Err when the memory referenced by a Val may
+// no longer be valid.
+var ErrValInvalid = errors.New("lmdb: Val used after its transaction ended or was written to")
+
+// Val is a zero-copy reference to a key or value in the memory map, returned
+// by Txn.GetVal and Cursor.GetVal.  A Val is only valid until its transaction
+// terminates or is reset, or until the next write in the transaction (or one
+// of its subtransactions), which may move or overwrite the referenced pages.
+// Reading the bytes of an invalid Val can return garbage or crash the process
+// with SIGBUS after the environment is resized or closed.
+//
+// Val tracks its validity.  When the package is built with the lmdbdebug
+// build tag Bytes and Copy panic if the Val is invalid, turning
+// use-after-free bugs into immediate, debuggable failures.  In normal builds
+// the check is left to the application through Valid and Err.
+type Val struct {
+	b   []byte
+	txn *Txn
+	gen uint64
+}
+
+func (txn *Txn) newVal(b []byte) Val {
+	return Val{b: b, txn: txn, gen: *txn.gen}
+}
+
+// Valid returns true if the memory referenced by v may still be accessed.
+func (v Val) Valid() bool {
+	return v.txn != nil && *v.txn.gen == v.gen
+}
+
+// Err returns ErrValInvalid if v is no longer valid and nil otherwise.
+func (v Val) Err() error {
+	if !v.Valid() {
+		return ErrValInvalid
+	}
+	return nil
+}
+
+// Len returns the length of the referenced bytes.
+func (v Val) Len() int {
+	return len(v.b)
+}
+
+// Bytes returns the referenced bytes, which must not be modified.
+func (v Val) Bytes() []byte {
+	if valDebug && !v.Valid() {
+		panic(ErrValInvalid)
+	}
+	return v.b
+}
+
+// Copy returns a copy of the referenced bytes that remains valid after v is
+// invalidated.
+func (v Val) Copy() []byte {
+	return append([]byte(nil), v.Bytes()...)
+}
+
+// String returns the referenced bytes as a string.
+func (v Val) String() string {
+	return string(v.Bytes())
+}
+
+// GetVal is like Get but returns a Val referencing the value in the memory
+// map without copying it, regardless of txn.RawRead.
+func (txn *Txn) GetVal(dbi DBI, key []byte) (Val, error) {
+	raw := txn.RawRead
+	txn.RawRead = true
+	b, err := txn.Get(dbi, key)
+	txn.RawRead = raw
+	if err != nil {
+		return Val{}, err
+	}
+	return txn.newVal(b), nil
+}
+
+// GetVal is like Get but returns Vals referencing the key and value in the
+// memory map without copying them, regardless of the RawRead field of the
+// cursor's transaction.
+func (c *Cursor) GetVal(setkey, setval []byte, op uint) (key, val Val, err error) {
+	txn := c.txn
+	raw := txn.RawRead
+	txn.RawRead = true
+	k, v, err := c.Get(setkey, setval, op)
+	txn.RawRead = raw
+	if err != nil {
+		return Val{}, Val{}, err
+	}
+	return txn.newVal(k), txn.newVal(v), nil
+}
+
+// ValReader reads the bytes referenced by a Val, implementing io.Reader,
+// io.ReaderAt, io.Seeker and io.WriterTo.  Reads are served from the memory
+// map without copying the whole value, so large values can be streamed or
+// decoded directly.  Every read checks that the Val is still valid and fails
+// with ErrValInvalid otherwise.
+type ValReader struct {
+	v   Val
+	off int64
+}
+
+// NewReader returns a ValReader reading v from the start.
+func (v Val) NewReader() *ValReader {
+	return &ValReader{v: v}
+}
+
+// Len returns the number of unread bytes.
+func (r *ValReader) Len() int {
+	if r.off >= int64(len(r.v.b)) {
+		return 0
+	}
+	return len(r.v.b) - int(r.off)
+}
+
+// Read implements io.Reader.
+func (r *ValReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *ValReader) ReadAt(p []byte, off int64) (int, error) {
+	if !r.v.Valid() {
+		return 0, ErrValInvalid
+	}
+	if off < 0 {
+		return 0, errors.New("lmdb: ValReader.ReadAt: negative offset")
+	}
+	if off >= int64(len(r.v.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.v.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (r *ValReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += int64(len(r.v.b))
+	default:
+		return 0, errors.New("lmdb: ValReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("lmdb: ValReader.Seek: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// WriteTo implements io.WriterTo.  It writes the unread bytes to w directly
+// from the memory map.  The transaction must not be written to or terminated
+// until WriteTo returns.
+func (r *ValReader) WriteTo(w io.Writer) (int64, error) {
+	if !r.v.Valid() {
+		return 0, ErrValInvalid
+	}
+	if r.off >= int64(len(r.v.b)) {
+		return 0, nil
+	}
+	n, err := w.Write(r.v.b[r.off:])
+	r.off += int64(n)
+	return int64(n), err
+}
+
+// GetReader is like GetVal but returns a ValReader of the value.  The reader
+// may only be used while the value remains valid, as described for Val.
+func (txn *Txn) GetReader(dbi DBI, key []byte) (*ValReader, error) {
+	v, err := txn.GetVal(dbi, key)
+	if err != nil {
+		return nil, err
+	}
+	return v.NewReader(), nil
+}
+
+// invalidateVals invalidates the Vals of the cursor's transaction before a
+// write.
+func (c *Cursor) invalidateVals() {
+	if c.txn != nil {
+		*c.txn.gen++
+	}
+}
@@ -0,0 +1,37 @@
+//go:build lmdbdebug
+// +build lmdbdebug
+
+package lmdb
+
+import (
+	"testing"
+)
+
+func TestVal_debugPanic(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v Val
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		v, err = txn.GetVal(dbi, []byte("k"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if e := recover(); e != ErrValInvalid {
+			t.Errorf("unexpected panic: %v", e)
+		}
+	}()
+	v.Bytes()
+}
@@ -0,0 +1,7 @@
+//go:build !lmdbdebug
+// +build !lmdbdebug
+
+package lmdb
+
+// valDebug enables the validity checks of Val.Bytes.
+const valDebug = false
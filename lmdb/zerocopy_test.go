@@ -0,0 +1,181 @@
+package lmdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTxn_GetVal(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var escaped Val
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		v, err := txn.GetVal(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if !v.Valid() || v.String() != "v" || v.Len() != 1 {
+			t.Errorf("unexpected val: %q %v", v.Copy(), v.Valid())
+		}
+		if txn.RawRead {
+			t.Errorf("RawRead changed")
+		}
+
+		// a write invalidates the val, also in a subtransaction.
+		err = txn.Put(dbi, []byte("k2"), []byte("v2"), 0)
+		if err != nil {
+			return err
+		}
+		if v.Valid() || v.Err() != ErrValInvalid {
+			t.Errorf("val valid after write")
+		}
+		v, err = txn.GetVal(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) error {
+			return txn.Put(dbi, []byte("k3"), []byte("v3"), 0)
+		})
+		if err != nil {
+			return err
+		}
+		if v.Valid() {
+			t.Errorf("val valid after write in subtransaction")
+		}
+
+		// a val read in a subtransaction is invalid once it aborts.
+		var sub Val
+		err = txn.Sub(func(txn *Txn) (err error) {
+			sub, err = txn.GetVal(dbi, []byte("k"))
+			if err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		if err == nil {
+			t.Errorf("subtransaction not aborted")
+		}
+		if sub.Valid() {
+			t.Errorf("val valid after subtransaction aborted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		k, v, err := cur.GetVal(nil, nil, First)
+		if err != nil {
+			return err
+		}
+		if k.String() != "k" || v.String() != "v" {
+			t.Errorf("unexpected item: %q %q", k.Copy(), v.Copy())
+		}
+		escaped = v
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escaped.Valid() {
+		t.Errorf("val valid after transaction ended")
+	}
+	if (Val{}).Valid() {
+		t.Errorf("zero val valid")
+	}
+}
+
+func TestTxn_GetReader(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := bytes.Repeat([]byte("0123456789"), 1000)
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), val, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		var r io.ReadSeeker
+		r, err = txn.GetReader(dbi, []byte("k"))
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(b, val) {
+			t.Error("value differs")
+		}
+
+		_, err = r.Seek(-5, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		p := make([]byte, 10)
+		n, err := r.Read(p)
+		if n != 5 || string(p[:n]) != "56789" || err != nil {
+			t.Errorf("Read at end: %d %q %v", n, p[:n], err)
+		}
+		n, err = r.Read(p)
+		if n != 0 || err != io.EOF {
+			t.Errorf("Read past end: %d %v", n, err)
+		}
+
+		_, err = r.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		m, err := r.(io.WriterTo).WriteTo(&buf)
+		if err != nil {
+			return err
+		}
+		if m != int64(len(val)) || !bytes.Equal(buf.Bytes(), val) {
+			t.Errorf("WriteTo wrote %d bytes", m)
+		}
+
+		err = txn.Put(dbi, []byte("k2"), nil, 0)
+		if err != nil {
+			return err
+		}
+		_, err = r.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = r.Read(p)
+		if err != ErrValInvalid {
+			t.Errorf("Read after write: %v (!= %v)", err, ErrValInvalid)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
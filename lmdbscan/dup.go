@@ -0,0 +1,148 @@
+package lmdbscan
+
+import (
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DupScanner is a construct for scanning databases opened with the
+// lmdb.DupSort flag one key at a time.  Each call to Scan moves the cursor to
+// the next unique key and collects all of its duplicate values.  When the
+// database also has the lmdb.DupFixed flag the duplicates are read a page at a
+// time using lmdb.GetMultiple and lmdb.NextMultiple.
+type DupScanner struct {
+	cur     *lmdb.Cursor
+	fixed   bool
+	started bool
+	key     []byte
+	vals    [][]byte
+	err     error
+}
+
+// NewDup allocates and initializes a DupScanner for dbi within txn.  When the
+// DupScanner returned by NewDup is no longer needed its Close method must be
+// called.
+func NewDup(txn *lmdb.Txn, dbi lmdb.DBI) *DupScanner {
+	s := &DupScanner{}
+
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.fixed = flags&lmdb.DupFixed != 0
+
+	s.cur, s.err = txn.OpenCursor(dbi)
+	return s
+}
+
+// Cursor returns the lmdb.Cursor underlying s.  Cursor returns nil if s is
+// closed.
+func (s *DupScanner) Cursor() *lmdb.Cursor {
+	return s.cur
+}
+
+// Key returns the key read during the last call to Scan.
+func (s *DupScanner) Key() []byte {
+	return s.key
+}
+
+// Vals returns the duplicate values for s.Key() read during the last call to
+// Scan, in database order.
+func (s *DupScanner) Vals() [][]byte {
+	return s.vals
+}
+
+// Scan moves the cursor to the next unique key and reads all of its values.
+// Scan returns false when keys are exhausted or another error is encountered.
+func (s *DupScanner) Scan() bool {
+	if s.cur == nil {
+		if s.err == nil {
+			s.err = errClosed
+		}
+		return false
+	}
+
+	op := uint(lmdb.NextNoDup)
+	if !s.started {
+		op = lmdb.First
+		s.started = true
+	}
+
+	var first []byte
+	s.key, first, s.err = s.cur.Get(nil, nil, op)
+	if s.err != nil {
+		s.key, s.vals = nil, nil
+		return false
+	}
+	s.vals = [][]byte{first}
+
+	if s.fixed {
+		s.err = s.scanMulti(len(first))
+	} else {
+		s.err = s.scanDup()
+	}
+	return s.err == nil
+}
+
+// scanDup reads the remaining values for the current key one at a time.
+func (s *DupScanner) scanDup() error {
+	for {
+		_, v, err := s.cur.Get(nil, nil, lmdb.NextDup)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.vals = append(s.vals, v)
+	}
+}
+
+// scanMulti replaces the values for the current key with those read a page at
+// a time.  When the key has a single value the cursor has no duplicate page to
+// read and the value already scanned is kept.
+func (s *DupScanner) scanMulti(stride int) error {
+	n, err := s.cur.Count()
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return nil
+	}
+
+	vals := make([][]byte, 0, n)
+	op := uint(lmdb.GetMultiple)
+	for {
+		_, page, err := s.cur.Get(nil, nil, op)
+		if lmdb.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		vals = append(vals, lmdb.WrapMulti(page, stride).Vals()...)
+		op = lmdb.NextMultiple
+	}
+	s.vals = vals
+	return nil
+}
+
+// Err returns a non-nil error if and only if the previous call to s.Scan()
+// resulted in an error other than lmdb.ErrNotFound.
+func (s *DupScanner) Err() error {
+	if lmdb.IsNotFound(s.err) {
+		return nil
+	}
+	return s.err
+}
+
+// Close closes the cursor underlying s.  Close does not attempt to terminate
+// the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (s *DupScanner) Close() {
+	if s.cur != nil {
+		s.cur.Close()
+		s.cur = nil
+	}
+}
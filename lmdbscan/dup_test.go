@@ -0,0 +1,111 @@
+package lmdbscan
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestDupScanner_Scan(t *testing.T) {
+	testDupScannerScan(t, lmdb.DupSort)
+}
+
+func TestDupScanner_Scan_dupFixed(t *testing.T) {
+	testDupScannerScan(t, lmdb.DupSort|lmdb.DupFixed)
+}
+
+func testDupScannerScan(t *testing.T, flags uint) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "dup", flags|lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the number of values for "k1" is large enough to span multiple pages
+	// when read with lmdb.GetMultiple.
+	expect := map[string][]string{
+		"k0": {"v0000"},
+		"k2": {"v0000", "v0001", "v0002"},
+	}
+	for i := 0; i < 2000; i++ {
+		expect["k1"] = append(expect["k1"], fmt.Sprintf("v%04d", i))
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for k, vals := range expect {
+			for _, v := range vals {
+				err = txn.Put(dbi, []byte(k), []byte(v), 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	scanned := map[string][]string{}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewDup(txn, dbi)
+		defer s.Close()
+
+		for s.Scan() {
+			k := string(s.Key())
+			keys = append(keys, k)
+			for _, v := range s.Vals() {
+				scanned[k] = append(scanned[k], string(v))
+			}
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"k0", "k1", "k2"}) {
+		t.Errorf("unexpected keys: %q", keys)
+	}
+	if !reflect.DeepEqual(scanned, expect) {
+		t.Errorf("unexpected values: %d keys (expected %d)", len(scanned), len(expect))
+		for k := range expect {
+			if len(scanned[k]) != len(expect[k]) {
+				t.Errorf("key %q: %d values (!= %d)", k, len(scanned[k]), len(expect[k]))
+			}
+		}
+	}
+}
+
+func TestDupScanner_closed(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+
+		s := NewDup(txn, dbi)
+		s.Close()
+
+		for s.Scan() {
+			t.Error("loop should not execute")
+		}
+		return s.Err()
+	})
+	if err != errClosed {
+		t.Errorf("unexpected error: %q (!= %q)", err, errClosed)
+	}
+}
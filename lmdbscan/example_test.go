@@ -133,3 +133,27 @@ func ExampleScanner_SetNext_getMultiple() {
 		panic(err)
 	}
 }
+
+// This example demonstrates finding the users present in two databases whose
+// keys are prefixed with a fixed length user id.
+func ExampleJoin() {
+	var users, orders lmdb.DBI
+	userID := func(key []byte) []byte {
+		if len(key) < 8 {
+			return key
+		}
+		return key[:8]
+	}
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		join := lmdbscan.NewIntersect(txn, []lmdb.DBI{users, orders}, userID)
+		defer join.Close()
+
+		for join.Scan() {
+			log.Printf("user=%x orders=%d", join.Key(), len(join.Keys(1)))
+		}
+		return join.Err()
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdbscan
+
+import "iter"
+
+// Iter returns an iterator yielding the key-value pairs read by successive
+// calls to s.Scan().  After the loop terminates s.Err() reports any error that
+// stopped it, and s.Key() and s.Val() hold the last pair yielded if the loop
+// was broken out of.  Iter does not close s.
+//
+//	s := lmdbscan.New(txn, dbi)
+//	defer s.Close()
+//	for k, v := range s.Iter() {
+//		...
+//	}
+//	return s.Err()
+func (s *Scanner) Iter() iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		for s.Scan() {
+			if !yield(s.key, s.val) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdbscan
+
+import (
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestScanner_Iter(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	items := lmdbtest.SimpleItemList{
+		{K: "k0", V: "v0"},
+		{K: "k1", V: "v1"},
+		{K: "k2", V: "v2"},
+		{K: "k3", V: "v3"},
+	}
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := New(txn, dbi)
+		defer s.Close()
+		var n int
+		for k, v := range s.Iter() {
+			if string(k) != items[n].K || string(v) != items[n].V {
+				t.Errorf("item %d: %q=%q (!= %q=%q)", n, k, v, items[n].K, items[n].V)
+			}
+			n++
+			if n == 2 {
+				break
+			}
+		}
+		if string(s.Key()) != "k1" {
+			t.Errorf("key after break %q (!= %q)", s.Key(), "k1")
+		}
+		for k := range s.Iter() {
+			if string(k) != items[n].K {
+				t.Errorf("item %d: key %q (!= %q)", n, k, items[n].K)
+			}
+			n++
+		}
+		if n != len(items) {
+			t.Errorf("iterated %d items (!= %d)", n, len(items))
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
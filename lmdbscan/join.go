@@ -0,0 +1,252 @@
+package lmdbscan
+
+import (
+	"bytes"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// KeyFunc returns the part of key that is matched between databases by a
+// Join, typically an encoded prefix such as a fixed length identifier or the
+// bytes preceding a separator.  KeyFunc must return a prefix of key and the
+// prefixes it returns must not decrease as keys are scanned in database order.
+// A nil KeyFunc matches whole keys.
+type KeyFunc func(key []byte) []byte
+
+// Join performs a sorted merge of cursors in several databases, which must
+// use the default (lexicographic) key order.  Each call to Scan advances to
+// the next join key, as returned by a KeyFunc, and collects the records with
+// that key from every database.
+//
+// A Join returned by NewIntersect only stops at keys present in all databases
+// and moves lagging cursors forward with lmdb.SetRange, so the cost of an
+// intersection depends on the smallest database rather than the largest.  A
+// Join returned by NewMerge stops at every key present in any database.
+//
+// Keys and values returned by a Join reference memory owned by LMDB and are
+// subject to the same restrictions as those returned by lmdb.Cursor.Get.
+type Join struct {
+	inputs    []*joinInput
+	keyfn     KeyFunc
+	intersect bool
+	started   bool
+	closed    bool
+	match     []byte
+	err       error
+}
+
+// joinInput is the state of a single cursor in a Join.  The cursor is
+// positioned at the first record not yet returned by Scan.
+type joinInput struct {
+	cur  *lmdb.Cursor
+	key  []byte
+	jk   []byte
+	val  []byte
+	done bool
+
+	keys [][]byte
+	vals [][]byte
+}
+
+// NewIntersect allocates and initializes a Join which returns the join keys
+// present in each of dbis.  When the Join returned by NewIntersect is no
+// longer needed its Close method must be called.
+func NewIntersect(txn *lmdb.Txn, dbis []lmdb.DBI, fn KeyFunc) *Join {
+	return newJoin(txn, dbis, fn, true)
+}
+
+// NewMerge allocates and initializes a Join which returns the join keys
+// present in any of dbis.  When the Join returned by NewMerge is no longer
+// needed its Close method must be called.
+func NewMerge(txn *lmdb.Txn, dbis []lmdb.DBI, fn KeyFunc) *Join {
+	return newJoin(txn, dbis, fn, false)
+}
+
+func newJoin(txn *lmdb.Txn, dbis []lmdb.DBI, fn KeyFunc, intersect bool) *Join {
+	j := &Join{
+		keyfn:     fn,
+		intersect: intersect,
+	}
+	for _, dbi := range dbis {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			j.Close()
+			j.err = err
+			return j
+		}
+		j.inputs = append(j.inputs, &joinInput{cur: cur})
+	}
+	return j
+}
+
+// Len returns the number of databases in j.
+func (j *Join) Len() int {
+	return len(j.inputs)
+}
+
+// Key returns the join key read during the last call to Scan.
+func (j *Join) Key() []byte {
+	return j.match
+}
+
+// Matched returns true if the i-th database contained the join key read
+// during the last call to Scan.  Matched is always true for an intersection.
+func (j *Join) Matched(i int) bool {
+	return len(j.inputs[i].keys) > 0
+}
+
+// Keys returns the keys in the i-th database with the join key read during
+// the last call to Scan, in database order.  Keys returns nil if the database
+// has no such keys.
+func (j *Join) Keys(i int) [][]byte {
+	return j.inputs[i].keys
+}
+
+// Vals returns the values corresponding to Keys(i).
+func (j *Join) Vals(i int) [][]byte {
+	return j.inputs[i].vals
+}
+
+// Scan advances to the next join key and collects the matching records from
+// each database.  Scan returns false when join keys are exhausted or another
+// error is encountered.
+func (j *Join) Scan() bool {
+	if j.err != nil {
+		return false
+	}
+	if j.closed {
+		j.err = errClosed
+		return false
+	}
+	if !j.started {
+		j.started = true
+		for _, in := range j.inputs {
+			j.err = j.get(in, lmdb.First, nil)
+			if j.err != nil {
+				return false
+			}
+		}
+	}
+
+	var ok bool
+	if j.intersect {
+		ok = j.align()
+	} else {
+		ok = j.lowest()
+	}
+	if !ok {
+		j.match = nil
+		for _, in := range j.inputs {
+			in.keys, in.vals = nil, nil
+		}
+		return false
+	}
+
+	for _, in := range j.inputs {
+		in.keys, in.vals = nil, nil
+		for !in.done && bytes.Equal(in.jk, j.match) {
+			in.keys = append(in.keys, in.key)
+			in.vals = append(in.vals, in.val)
+			j.err = j.get(in, lmdb.Next, nil)
+			if j.err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// align moves the cursors forward until they all have the same join key and
+// sets j.match.  The return value is false if any cursor was exhausted or an
+// error occurred.
+func (j *Join) align() bool {
+	if len(j.inputs) == 0 {
+		return false
+	}
+	for {
+		max := -1
+		for i, in := range j.inputs {
+			if in.done {
+				return false
+			}
+			if max < 0 || bytes.Compare(in.jk, j.inputs[max].jk) > 0 {
+				max = i
+			}
+		}
+		target := j.inputs[max].jk
+		aligned := true
+		for _, in := range j.inputs {
+			if bytes.Compare(in.jk, target) < 0 {
+				aligned = false
+				j.err = j.get(in, lmdb.SetRange, target)
+				if j.err != nil {
+					return false
+				}
+			}
+		}
+		if aligned {
+			j.match = target
+			return true
+		}
+	}
+}
+
+// lowest sets j.match to the lowest join key of any cursor.  The return value
+// is false if all cursors are exhausted.
+func (j *Join) lowest() bool {
+	j.match = nil
+	found := false
+	for _, in := range j.inputs {
+		if in.done {
+			continue
+		}
+		if !found || bytes.Compare(in.jk, j.match) < 0 {
+			j.match = in.jk
+			found = true
+		}
+	}
+	return found
+}
+
+// get moves the cursor of in and reads the join key of its new position.
+// Running out of records is not an error.
+func (j *Join) get(in *joinInput, op uint, key []byte) error {
+	var err error
+	in.key, in.val, err = in.cur.Get(key, nil, op)
+	if lmdb.IsNotFound(err) {
+		in.key, in.val, in.jk = nil, nil, nil
+		in.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	in.jk = in.key
+	if j.keyfn != nil {
+		in.jk = j.keyfn(in.key)
+	}
+	return nil
+}
+
+// Err returns a non-nil error if and only if the previous call to j.Scan()
+// resulted in an error other than lmdb.ErrNotFound.
+func (j *Join) Err() error {
+	if lmdb.IsNotFound(j.err) {
+		return nil
+	}
+	return j.err
+}
+
+// Close closes the cursors underlying j.  Close does not attempt to terminate
+// the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (j *Join) Close() {
+	for _, in := range j.inputs {
+		if in.cur != nil {
+			in.cur.Close()
+			in.cur = nil
+		}
+	}
+	j.closed = true
+}
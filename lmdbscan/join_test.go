@@ -0,0 +1,141 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// joinPrefix matches keys on the bytes preceding the first ':'.
+func joinPrefix(key []byte) []byte {
+	i := bytes.IndexByte(key, ':')
+	if i < 0 {
+		return key
+	}
+	return key[:i]
+}
+
+func TestJoin(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	data := [][]string{
+		{"a:1", "b:1", "c:1", "c:2", "e:1"},
+		{"b:x", "c:x", "d:x", "e:x"},
+		{"a:y", "c:y", "e:y", "f:y"},
+	}
+	var dbis []lmdb.DBI
+	for i, keys := range data {
+		dbi, err := lmdbtest.OpenDBI(env, string(rune('0'+i)), lmdb.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbis = append(dbis, dbi)
+		err = env.Update(func(txn *lmdb.Txn) (err error) {
+			for _, k := range keys {
+				err = txn.Put(dbi, []byte(k), []byte(k), 0)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func(j *Join) (rows []string) {
+		defer j.Close()
+		for j.Scan() {
+			row := string(j.Key()) + "="
+			for i := 0; i < j.Len(); i++ {
+				if !j.Matched(i) {
+					row += "-"
+				}
+				for k, key := range j.Keys(i) {
+					if !bytes.Equal(key, j.Vals(i)[k]) {
+						t.Errorf("unexpected value for %q: %q", key, j.Vals(i)[k])
+					}
+					row += string(key[len(key)-1])
+				}
+				row += ","
+			}
+			rows = append(rows, row)
+		}
+		if j.Err() != nil {
+			t.Error(j.Err())
+		}
+		return rows
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		rows := scan(NewIntersect(txn, dbis, joinPrefix))
+		expect := []string{"c=12,x,y,", "e=1,x,y,"}
+		if !reflect.DeepEqual(rows, expect) {
+			t.Errorf("intersect: %q (!= %q)", rows, expect)
+		}
+
+		rows = scan(NewIntersect(txn, dbis[:2], joinPrefix))
+		expect = []string{"b=1,x,", "c=12,x,", "e=1,x,"}
+		if !reflect.DeepEqual(rows, expect) {
+			t.Errorf("intersect: %q (!= %q)", rows, expect)
+		}
+
+		rows = scan(NewMerge(txn, dbis, joinPrefix))
+		expect = []string{
+			"a=1,-,y,",
+			"b=1,x,-,",
+			"c=12,x,y,",
+			"d=-,x,-,",
+			"e=1,x,y,",
+			"f=-,-,y,",
+		}
+		if !reflect.DeepEqual(rows, expect) {
+			t.Errorf("merge: %q (!= %q)", rows, expect)
+		}
+
+		// whole keys never match between these databases.
+		rows = scan(NewIntersect(txn, dbis, nil))
+		if len(rows) != 0 {
+			t.Errorf("intersect: %q", rows)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJoin_Closed(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		j := NewMerge(txn, []lmdb.DBI{dbi}, nil)
+		j.Close()
+		if j.Scan() {
+			t.Errorf("scan after close")
+		}
+		if j.Err() != errClosed {
+			t.Errorf("unexpected error: %v", j.Err())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
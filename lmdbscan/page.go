@@ -0,0 +1,212 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// DefaultPageSize is the number of items in a page when PageOptions.Limit is
+// not set.
+const DefaultPageSize = 100
+
+// ErrPageToken is returned when a page token is malformed or was not issued
+// for the requested prefix and databases.
+var ErrPageToken = errors.New("lmdbscan: invalid page token")
+
+// PageOptions configure pagination.
+type PageOptions struct {
+	// Prefix restricts pages to keys with the prefix.
+	Prefix []byte
+
+	// Limit is the maximum number of items in a page.
+	Limit int
+}
+
+// PageItem is an item of a Page.  Key and Val are copies which remain valid
+// after the transaction the page was read in terminates.
+type PageItem struct {
+	DB  int // Index of the database of the item in the dbis argument
+	Key []byte
+	Val []byte
+}
+
+// Page is a page of items in ascending key order.  Items with equal keys in
+// several databases are ordered by the index of their database.
+type Page struct {
+	Items []PageItem
+
+	// Next is the token requesting the following page, or empty if this is
+	// the last page.
+	Next string
+}
+
+// Paginate reads the page following token from dbis in a read-only
+// transaction on env, so that each page is a consistent snapshot.  An empty
+// token requests the first page.  See ReadPage.
+func Paginate(env *lmdb.Env, dbis []lmdb.DBI, token string, opt *PageOptions) (*Page, error) {
+	var page *Page
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		txn.RawRead = true
+		page, err = ReadPage(txn, dbis, token, opt)
+		return err
+	})
+	return page, err
+}
+
+// ReadPage reads the page following token from the merged contents of dbis in
+// txn.  An empty token requests the first page.
+//
+// A token holds the key of the last item of the previous page rather than an
+// offset, so pages stay stable while the databases are modified between
+// requests: items inserted or deleted before the boundary do not shift later
+// pages, and a page continues after the boundary key even if that key has
+// since been deleted.  Each item is returned at most once across pages read
+// from unmodified databases.
+//
+// The databases must use the default (lexicographic) key order and must not
+// have the DupSort flag, whose duplicates cannot be told apart by a key.
+func ReadPage(txn *lmdb.Txn, dbis []lmdb.DBI, token string, opt *PageOptions) (*Page, error) {
+	var prefix []byte
+	limit := DefaultPageSize
+	if opt != nil {
+		prefix = opt.Prefix
+		if opt.Limit > 0 {
+			limit = opt.Limit
+		}
+	}
+	last, after, err := decodePageToken(token, len(dbis))
+	if err != nil {
+		return nil, err
+	}
+	if after != nil && !bytes.HasPrefix(after, prefix) {
+		return nil, ErrPageToken
+	}
+
+	inputs := make([]*pageInput, len(dbis))
+	defer func() {
+		for _, in := range inputs {
+			if in != nil {
+				in.cur.Close()
+			}
+		}
+	}()
+	for i, dbi := range dbis {
+		flags, err := txn.DBIFlags(dbi)
+		if err != nil {
+			return nil, err
+		}
+		if flags&(lmdb.DupSort|lmdb.ReverseKey|lmdb.IntegerKey) != 0 {
+			return nil, fmt.Errorf("lmdbscan: cannot paginate database with flags %v", flags&(lmdb.DupSort|lmdb.ReverseKey|lmdb.IntegerKey))
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return nil, err
+		}
+		in := &pageInput{cur: cur, prefix: prefix}
+		inputs[i] = in
+		err = in.seek(after, after != nil && i <= last)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &Page{}
+	for {
+		min := -1
+		for i, in := range inputs {
+			if !in.done && (min < 0 || bytes.Compare(in.key, inputs[min].key) < 0) {
+				min = i
+			}
+		}
+		if min < 0 {
+			return page, nil
+		}
+		if len(page.Items) == limit {
+			item := page.Items[limit-1]
+			page.Next = encodePageToken(item.DB, item.Key)
+			return page, nil
+		}
+		in := inputs[min]
+		page.Items = append(page.Items, PageItem{
+			DB:  min,
+			Key: append([]byte(nil), in.key...),
+			Val: append([]byte(nil), in.val...),
+		})
+		err = in.get(nil, lmdb.Next)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pageInput is the cursor of a database being paginated.  The cursor is
+// positioned at the next item to return unless done is true.
+type pageInput struct {
+	cur    *lmdb.Cursor
+	prefix []byte
+	key    []byte
+	val    []byte
+	done   bool
+}
+
+// seek positions the cursor at the first item with the prefix following
+// after, skipping an item with key after if skip is true.
+func (in *pageInput) seek(after []byte, skip bool) error {
+	start := in.prefix
+	if after != nil {
+		start = after
+	}
+	var err error
+	if len(start) == 0 {
+		err = in.get(nil, lmdb.First)
+	} else {
+		err = in.get(start, lmdb.SetRange)
+	}
+	if err == nil && skip && !in.done && bytes.Equal(in.key, after) {
+		err = in.get(nil, lmdb.Next)
+	}
+	return err
+}
+
+// get moves the cursor.  Running out of records or past the prefix is not an
+// error.
+func (in *pageInput) get(key []byte, op uint) error {
+	var err error
+	in.key, in.val, err = in.cur.Get(key, nil, op)
+	if lmdb.IsNotFound(err) || (err == nil && !bytes.HasPrefix(in.key, in.prefix)) {
+		in.key, in.val = nil, nil
+		in.done = true
+		return nil
+	}
+	return err
+}
+
+// encodePageToken returns a token for the item with key in the db-th
+// database.
+func encodePageToken(db int, key []byte) string {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(key))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(db))], key...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodePageToken decodes a token for a merge of n databases.  An empty token
+// decodes to a nil key.
+func decodePageToken(token string, n int) (db int, key []byte, err error) {
+	if token == "" {
+		return 0, nil, nil
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, nil, ErrPageToken
+	}
+	x, k := binary.Uvarint(buf)
+	if k <= 0 || x >= uint64(n) {
+		return 0, nil, ErrPageToken
+	}
+	return int(x), buf[k:], nil
+}
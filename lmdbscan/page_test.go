@@ -0,0 +1,108 @@
+package lmdbscan
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+func TestPaginate(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi0, err := lmdbtest.OpenDBI(env, "db0", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi1, err := lmdbtest.OpenDBI(env, "db1", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbis := []lmdb.DBI{dbi0, dbi1}
+	err = lmdbtest.Put(env, dbi0, lmdbtest.SimpleItemList{
+		{K: "a1", V: "0"}, {K: "a3", V: "0"}, {K: "a5", V: "0"}, {K: "b1", V: "0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbi1, lmdbtest.SimpleItemList{
+		{K: "a2", V: "1"}, {K: "a3", V: "1"}, {K: "a4", V: "1"}, {K: "c1", V: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := func(opt *PageOptions, between func(page int)) []string {
+		var items []string
+		var token string
+		for i := 0; ; i++ {
+			page, err := Paginate(env, dbis, token, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range page.Items {
+				items = append(items, fmt.Sprintf("%s/%d", item.Key, item.DB))
+			}
+			if page.Next == "" {
+				return items
+			}
+			token = page.Next
+			if between != nil {
+				between(i)
+			}
+		}
+	}
+
+	all := []string{"a1/0", "a2/1", "a3/0", "a3/1", "a4/1", "a5/0", "b1/0", "c1/1"}
+	for limit := 1; limit <= 9; limit++ {
+		items := pages(&PageOptions{Limit: limit}, nil)
+		if !reflect.DeepEqual(items, all) {
+			t.Errorf("limit %d: %q (!= %q)", limit, items, all)
+		}
+	}
+
+	items := pages(&PageOptions{Prefix: []byte("a"), Limit: 2}, nil)
+	if !reflect.DeepEqual(items, all[:6]) {
+		t.Errorf("prefix: %q (!= %q)", items, all[:6])
+	}
+
+	// Deleting the boundary key between requests does not disturb the next
+	// page.
+	items = pages(&PageOptions{Limit: 3}, func(page int) {
+		if page != 0 {
+			return
+		}
+		err := env.Update(func(txn *lmdb.Txn) (err error) {
+			err = txn.Del(dbi0, []byte("a3"), nil)
+			if err != nil {
+				return err
+			}
+			return txn.Put(dbi0, []byte("a0"), []byte("0"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !reflect.DeepEqual(items, all) {
+		t.Errorf("modified: %q (!= %q)", items, all)
+	}
+
+	_, err = Paginate(env, dbis, "!", nil)
+	if err != ErrPageToken {
+		t.Errorf("unexpected error: %v (!= %v)", err, ErrPageToken)
+	}
+	_, err = Paginate(env, dbis[:1], encodePageToken(1, []byte("a1")), nil)
+	if err != ErrPageToken {
+		t.Errorf("unexpected error: %v (!= %v)", err, ErrPageToken)
+	}
+	_, err = Paginate(env, dbis, encodePageToken(0, []byte("b1")), &PageOptions{Prefix: []byte("a")})
+	if err != ErrPageToken {
+		t.Errorf("unexpected error: %v (!= %v)", err, ErrPageToken)
+	}
+}
@@ -0,0 +1,234 @@
+//go:build go1.18
+// +build go1.18
+
+package lmdbscan
+
+import (
+	"encoding/json"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Codec converts between a Go type and the bytes stored in a database.
+// Decode must not retain b, which may be memory owned by LMDB, so that
+// decoded values remain valid after the transaction terminates.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// Bytes is a Codec for raw bytes.  Decode returns a copy.
+var Bytes Codec[[]byte] = bytesCodec{}
+
+// String is a Codec storing strings as their bytes.
+var String Codec[string] = stringCodec{}
+
+// Uint is a Codec for integers encoded by lmdb.EncodeUint, as stored in
+// databases with the lmdb.IntegerKey or lmdb.IntegerDup flags.
+var Uint Codec[uint64] = uintCodec{}
+
+type bytesCodec struct{}
+
+func (bytesCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+func (bytesCodec) Decode(b []byte) ([]byte, error) { return append([]byte(nil), b...), nil }
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(v string) ([]byte, error) { return []byte(v), nil }
+func (stringCodec) Decode(b []byte) (string, error) { return string(b), nil }
+
+type uintCodec struct{}
+
+func (uintCodec) Encode(v uint64) ([]byte, error) { return lmdb.EncodeUint(v), nil }
+func (uintCodec) Decode(b []byte) (uint64, error) { return lmdb.DecodeUint(b) }
+
+// JSON returns a Codec storing values of T encoded as JSON.
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(v T) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// Typed is a Scanner which decodes the keys and values it reads with codecs.
+// Decoded keys and values are Go values which remain valid after the
+// transaction terminates, unlike the slices returned by Scanner.
+type Typed[K, V any] struct {
+	s   *Scanner
+	kc  Codec[K]
+	vc  Codec[V]
+	key K
+	val V
+	err error
+}
+
+// NewTyped allocates and initializes a Typed scanner for dbi within txn which
+// decodes keys with kc and values with vc.  When the Typed scanner returned by
+// NewTyped is no longer needed its Close method must be called.
+func NewTyped[K, V any](txn *lmdb.Txn, dbi lmdb.DBI, kc Codec[K], vc Codec[V]) *Typed[K, V] {
+	return &Typed[K, V]{s: New(txn, dbi), kc: kc, vc: vc}
+}
+
+// Cursor returns the lmdb.Cursor underlying s.  Cursor returns nil if s is
+// closed.
+func (s *Typed[K, V]) Cursor() *lmdb.Cursor {
+	return s.s.Cursor()
+}
+
+// Key returns the key decoded during the last call to Scan.
+func (s *Typed[K, V]) Key() K {
+	return s.key
+}
+
+// Val returns the value decoded during the last call to Scan.
+func (s *Typed[K, V]) Val() V {
+	return s.val
+}
+
+// Set moves the cursor to the encoded key k with s.Cursor().Get(k, nil,
+// opset) and decodes the item found.  The cursor will not move in the next
+// call to Scan.
+func (s *Typed[K, V]) Set(k K, opset uint) bool {
+	return s.SetNext(k, opset, s.s.op)
+}
+
+// SetNext moves the cursor like s.Set(k, opset) for the next call to s.Scan().
+// Subsequent calls to s.Scan() move the cursor as c.Get(nil, nil, opnext).
+func (s *Typed[K, V]) SetNext(k K, opset, opnext uint) bool {
+	if s.err != nil {
+		return false
+	}
+	b, err := s.kc.Encode(k)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	return s.decode(s.s.SetNext(b, nil, opset, opnext))
+}
+
+// Scan reads and decodes the next item.  Scan returns false when items are
+// exhausted, an item fails to decode, or another error is encountered.
+func (s *Typed[K, V]) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	return s.decode(s.s.Scan())
+}
+
+func (s *Typed[K, V]) decode(ok bool) bool {
+	var zk K
+	var zv V
+	s.key, s.val = zk, zv
+	if !ok {
+		return false
+	}
+	s.key, s.err = s.kc.Decode(s.s.Key())
+	if s.err == nil {
+		s.val, s.err = s.vc.Decode(s.s.Val())
+	}
+	return s.err == nil
+}
+
+// Err returns a non-nil error if and only if the previous call to s.Scan()
+// resulted in an error other than lmdb.ErrNotFound, including a decoding
+// error.
+func (s *Typed[K, V]) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.s.Err()
+}
+
+// Close closes the cursor underlying s.  Close does not attempt to terminate
+// the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (s *Typed[K, V]) Close() {
+	s.s.Close()
+}
+
+// TypedDup is a DupScanner which decodes the keys and duplicate values it
+// reads with codecs.
+type TypedDup[K, V any] struct {
+	s    *DupScanner
+	kc   Codec[K]
+	vc   Codec[V]
+	key  K
+	vals []V
+	err  error
+}
+
+// NewTypedDup allocates and initializes a TypedDup scanner for dbi within txn,
+// which must have the lmdb.DupSort flag.  When the TypedDup scanner returned
+// by NewTypedDup is no longer needed its Close method must be called.
+func NewTypedDup[K, V any](txn *lmdb.Txn, dbi lmdb.DBI, kc Codec[K], vc Codec[V]) *TypedDup[K, V] {
+	return &TypedDup[K, V]{s: NewDup(txn, dbi), kc: kc, vc: vc}
+}
+
+// Cursor returns the lmdb.Cursor underlying s.  Cursor returns nil if s is
+// closed.
+func (s *TypedDup[K, V]) Cursor() *lmdb.Cursor {
+	return s.s.Cursor()
+}
+
+// Key returns the key decoded during the last call to Scan.
+func (s *TypedDup[K, V]) Key() K {
+	return s.key
+}
+
+// Vals returns the duplicate values for s.Key() decoded during the last call
+// to Scan, in database order.
+func (s *TypedDup[K, V]) Vals() []V {
+	return s.vals
+}
+
+// Scan moves the cursor to the next unique key and decodes it and all of its
+// values.  Scan returns false when keys are exhausted, an item fails to
+// decode, or another error is encountered.
+func (s *TypedDup[K, V]) Scan() bool {
+	var zk K
+	s.key, s.vals = zk, nil
+	if s.err != nil || !s.s.Scan() {
+		return false
+	}
+	s.key, s.err = s.kc.Decode(s.s.Key())
+	if s.err != nil {
+		return false
+	}
+	vals := make([]V, 0, len(s.s.Vals()))
+	for _, b := range s.s.Vals() {
+		var v V
+		v, s.err = s.vc.Decode(b)
+		if s.err != nil {
+			return false
+		}
+		vals = append(vals, v)
+	}
+	s.vals = vals
+	return true
+}
+
+// Err returns a non-nil error if and only if the previous call to s.Scan()
+// resulted in an error other than lmdb.ErrNotFound, including a decoding
+// error.
+func (s *TypedDup[K, V]) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.s.Err()
+}
+
+// Close closes the cursor underlying s.  Close does not attempt to terminate
+// the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (s *TypedDup[K, V]) Close() {
+	s.s.Close()
+}
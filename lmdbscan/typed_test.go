@@ -0,0 +1,153 @@
+//go:build go1.18
+// +build go1.18
+
+package lmdbscan
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PowerDNS/lmdb-go/internal/lmdbtest"
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+type typedRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestTyped(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]typedRecord{
+		"a": {"alpha", 1},
+		"b": {"beta", 2},
+		"c": {"gamma", 3},
+	}
+	vc := JSON[typedRecord]()
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for k, r := range records {
+			v, err := vc.Encode(r)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(dbi, []byte(k), v, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]typedRecord{}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewTyped(txn, dbi, String, vc)
+		defer s.Close()
+		for s.Scan() {
+			got[s.Key()] = s.Val()
+		}
+		err = s.Err()
+		if err != nil {
+			return err
+		}
+
+		s = NewTyped(txn, dbi, String, vc)
+		defer s.Close()
+		if !s.Set("b", lmdb.SetKey) {
+			t.Errorf("Set failed: %v", s.Err())
+		}
+		var keys []string
+		for s.Scan() {
+			keys = append(keys, s.Key())
+		}
+		if !reflect.DeepEqual(keys, []string{"b", "c"}) {
+			t.Errorf("keys %q (!= %q)", keys, []string{"b", "c"})
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("records %v (!= %v)", got, records)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewTyped(txn, dbi, Bytes, Uint)
+		defer s.Close()
+		for s.Scan() {
+			t.Error("loop should not execute")
+		}
+		return s.Err()
+	})
+	if err == nil {
+		t.Error("expected a decoding error")
+	}
+}
+
+func TestTypedDup(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "typeddup", lmdb.Create|lmdb.DupSort|lmdb.IntegerKey|lmdb.IntegerDup|lmdb.DupFixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for k := uint64(1); k <= 3; k++ {
+			for v := uint64(0); v < k*100; v++ {
+				err = txn.Put(dbi, lmdb.EncodeUint(k), lmdb.EncodeUint(v), 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewTypedDup(txn, dbi, Uint, Uint)
+		defer s.Close()
+		var k uint64
+		for s.Scan() {
+			k++
+			if s.Key() != k {
+				t.Errorf("key %d (!= %d)", s.Key(), k)
+			}
+			vals := s.Vals()
+			if uint64(len(vals)) != k*100 {
+				t.Errorf("key %d: %d values (!= %d)", k, len(vals), k*100)
+			}
+			for i, v := range vals {
+				if v != uint64(i) {
+					t.Errorf("key %d: value %d is %d", k, i, v)
+					break
+				}
+			}
+		}
+		if k != 3 {
+			t.Errorf("scanned %d keys (!= 3)", k)
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
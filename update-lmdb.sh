@@ -30,7 +30,11 @@ tmp_dir=$(mktemp -d -t lmdb-update)
 echo "Temp dir: $tmp_dir"
  
 curl -L "https://git.openldap.org/openldap/openldap/-/archive/LMDB_${version}/openldap-LMDB_${version}.tar.gz" | tar -C "$tmp_dir" -xvz
-cp "$tmp_dir/openldap-LMDB_${version}/libraries/liblmdb/mdb.c" lmdb/mdb.c
+# The build constraint excludes the C library when linking the system library
+{
+    printf '//go:build !lmdb_system\n// +build !lmdb_system\n\n'
+    cat "$tmp_dir/openldap-LMDB_${version}/libraries/liblmdb/mdb.c"
+} > lmdb/mdb.c
 cp "$tmp_dir/openldap-LMDB_${version}/libraries/liblmdb/lmdb.h" lmdb/lmdb.h
 cp "$tmp_dir/openldap-LMDB_${version}/libraries/liblmdb/CHANGES" CHANGES.lmdb.txt
  